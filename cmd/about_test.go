@@ -10,7 +10,6 @@ import (
 
 	"github.com/majohn-r/output"
 	"github.com/spf13/cobra"
-	"golang.org/x/sys/windows"
 )
 
 func TestAboutRun(t *testing.T) {
@@ -22,7 +21,7 @@ func TestAboutRun(t *testing.T) {
 	originalCreation := cmd.Creation
 	originalApplicationPath := cmd.ApplicationPath
 	originalPlainFileExists := cmd.PlainFileExists
-	originalIsElevatedFunc := cmd.IsElevated
+	originalProcessIsElevated := cmd.ProcessIsElevated
 	defer func() {
 		cmd.BusGetter = originalBusGetter
 		cmd.LogCommandStart = originalLogCommandStart
@@ -32,7 +31,7 @@ func TestAboutRun(t *testing.T) {
 		cmd.Creation = originalCreation
 		cmd.ApplicationPath = originalApplicationPath
 		cmd.PlainFileExists = originalPlainFileExists
-		cmd.IsElevated = originalIsElevatedFunc
+		cmd.ProcessIsElevated = originalProcessIsElevated
 	}()
 	cmd.InterpretBuildData = func() (string, []string) {
 		return "go1.22.x", []string{
@@ -50,7 +49,7 @@ func TestAboutRun(t *testing.T) {
 		return "/my/files/apppath"
 	}
 	cmd.PlainFileExists = func(_ string) bool { return true }
-	cmd.IsElevated = func(_ windows.Token) bool {
+	cmd.ProcessIsElevated = func() bool {
 		return true
 	}
 	type args struct {
@@ -89,7 +88,7 @@ func TestAboutRun(t *testing.T) {
 				bus.Log(output.Info, "executing command", map[string]any{"command": "about"})
 			}
 			cmd.AboutRun(tt.args.in0, tt.args.in1)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("AboutRun() %s", difference)
 				}
@@ -161,8 +160,7 @@ func TestOutputAbout(t *testing.T) {
 	originalCreation := cmd.Creation
 	originalApplicationPath := cmd.ApplicationPath
 	originalPlainFileExists := cmd.PlainFileExists
-	originalGetCurrentProcessToken := cmd.GetCurrentProcessToken
-	originalIsElevatedFunc := cmd.IsElevated
+	originalProcessIsElevated := cmd.ProcessIsElevated
 	originalIsTerminal := cmd.IsTerminal
 	originalIsCygwinTerminal := cmd.IsCygwinTerminal
 	originalLookupEnv := cmd.LookupEnv
@@ -173,8 +171,7 @@ func TestOutputAbout(t *testing.T) {
 		cmd.Creation = originalCreation
 		cmd.ApplicationPath = originalApplicationPath
 		cmd.PlainFileExists = originalPlainFileExists
-		cmd.GetCurrentProcessToken = originalGetCurrentProcessToken
-		cmd.IsElevated = originalIsElevatedFunc
+		cmd.ProcessIsElevated = originalProcessIsElevated
 		cmd.IsTerminal = originalIsTerminal
 		cmd.IsCygwinTerminal = originalIsCygwinTerminal
 		cmd.LookupEnv = originalLookupEnv
@@ -194,9 +191,6 @@ func TestOutputAbout(t *testing.T) {
 	cmd.ApplicationPath = func() string {
 		return "/my/files/apppath"
 	}
-	cmd.GetCurrentProcessToken = func() (t windows.Token) {
-		return
-	}
 	tests := map[string]struct {
 		plainFileExists      func(string) bool
 		forceElevated        bool
@@ -284,7 +278,7 @@ func TestOutputAbout(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			cmd.PlainFileExists = tt.plainFileExists
-			cmd.IsElevated = func(_ windows.Token) bool {
+			cmd.ProcessIsElevated = func() bool {
 				return tt.forceElevated
 			}
 			cmd.IsTerminal = func(_ uintptr) bool {
@@ -302,8 +296,12 @@ func TestOutputAbout(t *testing.T) {
 					return "false", true
 				}
 			}
-			if got := cmd.GatherOutput(output.NewNilBus()); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GatherAbout() got %v, want %v", got, tt.want)
+			want := make([]string, len(tt.want))
+			for k, s := range tt.want {
+				want[k] = nativeText(s)
+			}
+			if got := cmd.GatherOutput(output.NewNilBus()); !reflect.DeepEqual(got, want) {
+				t.Errorf("GatherAbout() got %v, want %v", got, want)
 			}
 		})
 	}
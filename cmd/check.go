@@ -4,6 +4,7 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"mp3/internal/files"
 	"slices"
@@ -38,6 +39,20 @@ import (
 //     genre field, and that the ID3V1 and ID3V2 genre agree as closely as possible.
 //   - contain the same MCDI (music CD identifier) ID3V2 frame.
 
+// The integrity check, on the other hand, ignores the metadata and walks the MPEG
+// audio frames of each mp3 file, reporting places where frame synchronization is
+// lost, a final frame that is cut short, Layer III frames whose CRC (if they have
+// one) does not match their contents, and Xing headers whose frame or byte counts
+// do not match the frames that were actually found.
+
+// The duplicates check lists, under each album, the other directories holding an
+// album of the same name by the same artist, as happens when the artist is found
+// under several top directories, each holding a copy of the album.
+
+// The names check lists, under each album, the track files whose names cannot be
+// parsed into a track number and name; those files take no part in the other
+// checks.
+
 // About name matching:
 
 //   File names and their corresponding metadata values cannot always be identical, as
@@ -60,23 +75,33 @@ import (
 //     encodes genre as free-form text.
 
 const (
-	CheckCommand       = "check"
-	CheckEmpty         = "empty"
-	CheckEmptyAbbr     = "e"
-	CheckEmptyFlag     = "--" + CheckEmpty
-	CheckFiles         = "files"
-	CheckFilesAbbr     = "f"
-	CheckFilesFlag     = "--" + CheckFiles
-	CheckNumbering     = "numbering"
-	CheckNumberingAbbr = "n"
-	CheckNumberingFlag = "--" + CheckNumbering
+	CheckCommand        = "check"
+	CheckDuplicates     = "duplicates"
+	CheckDuplicatesAbbr = "d"
+	CheckDuplicatesFlag = "--" + CheckDuplicates
+	CheckEmpty          = "empty"
+	CheckEmptyAbbr      = "e"
+	CheckEmptyFlag      = "--" + CheckEmpty
+	CheckFiles          = "files"
+	CheckFilesAbbr      = "f"
+	CheckFilesFlag      = "--" + CheckFiles
+	CheckIntegrity      = "integrity"
+	CheckIntegrityAbbr  = "i"
+	CheckIntegrityFlag  = "--" + CheckIntegrity
+	CheckNames          = "names"
+	CheckNamesAbbr      = "N"
+	CheckNamesFlag      = "--" + CheckNames
+	CheckNumbering      = "numbering"
+	CheckNumberingAbbr  = "n"
+	CheckNumberingFlag  = "--" + CheckNumbering
 )
 
 var (
 	// CheckCmd represents the check command
 	CheckCmd = &cobra.Command{
-		Use: CheckCommand + " [" + CheckEmptyFlag + "] [" +
-			CheckFilesFlag + "] [" + CheckNumberingFlag + "] " + searchUsage,
+		Use: CheckCommand + " [" + CheckDuplicatesFlag + "] [" + CheckEmptyFlag + "] [" +
+			CheckFilesFlag + "] [" + CheckIntegrityFlag + "] [" + CheckNamesFlag + "] [" +
+			CheckNumberingFlag + "] " + searchUsage,
 		DisableFlagsInUseLine: true,
 		Short: "" +
 			"Runs checks on mp3 files and their directories and reports" + " problems",
@@ -84,22 +109,41 @@ var (
 			"%q runs checks on mp3 files and their containing directories and reports any"+
 				" problems detected", CheckCommand),
 		Example: "" +
+			CheckCommand + " " + CheckDuplicatesFlag + "\n" +
+			"  reports albums found more than once for the same artist\n" +
 			CheckCommand + " " + CheckEmptyFlag + "\n" +
 			"  reports empty artist and album directories\n" +
 			CheckCommand + " " + CheckFilesFlag + "\n" +
 			"  reads each mp3 file's metadata and reports any inconsistencies found\n" +
+			CheckCommand + " " + CheckIntegrityFlag + "\n" +
+			"  reads each mp3 file's audio frames and reports lost synchronization, truncated\n" +
+			"  final frames, CRC failures, and Xing header frame and byte counts that do not\n" +
+			"  match the audio\n" +
+			CheckCommand + " " + CheckNamesFlag + "\n" +
+			"  reports mp3 files whose names cannot be parsed into a track number and name\n" +
 			CheckCommand + " " + CheckNumberingFlag + "\n" +
 			"  reports errors in the track numbers of mp3 files",
 		RunE: CheckRun,
 	}
 	CheckFlags = NewSectionFlags().WithSectionName(CheckCommand).WithFlags(
 		map[string]*FlagDetails{
+			CheckDuplicates: NewFlagDetails().WithAbbreviatedName(
+				CheckDuplicatesAbbr).WithUsage(
+				"report albums found more than once for the same artist",
+			).WithExpectedType(BoolType).WithDefaultValue(false),
 			CheckEmpty: NewFlagDetails().WithAbbreviatedName(CheckEmptyAbbr).WithUsage(
 				"report empty album and artist directories").WithExpectedType(
 				BoolType).WithDefaultValue(false),
 			CheckFiles: NewFlagDetails().WithAbbreviatedName(CheckFilesAbbr).WithUsage(
 				"report metadata/file inconsistencies").WithExpectedType(
 				BoolType).WithDefaultValue(false),
+			CheckIntegrity: NewFlagDetails().WithAbbreviatedName(
+				CheckIntegrityAbbr).WithUsage(
+				"report damaged or inconsistent mp3 audio frames",
+			).WithExpectedType(BoolType).WithDefaultValue(false),
+			CheckNames: NewFlagDetails().WithAbbreviatedName(CheckNamesAbbr).WithUsage(
+				"report mp3 files whose names cannot be parsed").WithExpectedType(
+				BoolType).WithDefaultValue(false),
 			CheckNumbering: NewFlagDetails().WithAbbreviatedName(
 				CheckNumberingAbbr).WithUsage(
 				"report missing track numbers and duplicated track numbering",
@@ -117,36 +161,58 @@ func CheckRun(cmd *cobra.Command, _ []string) error {
 	if ProcessFlagErrors(o, eSlice) && searchFlagsOk {
 		if cs, ok := ProcessCheckFlags(o, values); ok {
 			details := map[string]any{
-				CheckEmptyFlag:       cs.empty,
-				"empty-user-set":     cs.emptyUserSet,
-				CheckFilesFlag:       cs.files,
-				"files-user-set":     cs.filesUserSet,
-				CheckNumberingFlag:   cs.numbering,
-				"numbering-user-set": cs.numberingUserSet,
+				CheckDuplicatesFlag:   cs.duplicates,
+				"duplicates-user-set": cs.duplicatesUserSet,
+				CheckEmptyFlag:        cs.empty,
+				"empty-user-set":      cs.emptyUserSet,
+				CheckFilesFlag:        cs.files,
+				"files-user-set":      cs.filesUserSet,
+				CheckIntegrityFlag:    cs.integrity,
+				"integrity-user-set":  cs.integrityUserSet,
+				CheckNamesFlag:        cs.names,
+				"names-user-set":      cs.namesUserSet,
+				CheckNumberingFlag:    cs.numbering,
+				"numbering-user-set":  cs.numberingUserSet,
 			}
 			for k, v := range searchSettings.Values() {
 				details[k] = v
 			}
 			LogCommandStart(o, CheckCommand, details)
-			exitError = cs.MaybeDoWork(o, searchSettings)
+			exitError = cs.MaybeDoWork(commandContext(cmd), o, searchSettings)
 		}
 	}
 	return ToErrorInterface(exitError)
 }
 
 type CheckSettings struct {
-	empty            bool
-	emptyUserSet     bool
-	files            bool
-	filesUserSet     bool
-	numbering        bool
-	numberingUserSet bool
+	duplicates        bool
+	duplicatesUserSet bool
+	empty             bool
+	emptyUserSet      bool
+	files             bool
+	filesUserSet      bool
+	integrity         bool
+	integrityUserSet  bool
+	names             bool
+	namesUserSet      bool
+	numbering         bool
+	numberingUserSet  bool
 }
 
 func NewCheckSettings() *CheckSettings {
 	return &CheckSettings{}
 }
 
+func (cs *CheckSettings) WithDuplicates(b bool) *CheckSettings {
+	cs.duplicates = b
+	return cs
+}
+
+func (cs *CheckSettings) WithDuplicatesUserSet(b bool) *CheckSettings {
+	cs.duplicatesUserSet = b
+	return cs
+}
+
 func (cs *CheckSettings) WithEmpty(b bool) *CheckSettings {
 	cs.empty = b
 	return cs
@@ -167,6 +233,26 @@ func (cs *CheckSettings) WithFilesUserSet(b bool) *CheckSettings {
 	return cs
 }
 
+func (cs *CheckSettings) WithIntegrity(b bool) *CheckSettings {
+	cs.integrity = b
+	return cs
+}
+
+func (cs *CheckSettings) WithIntegrityUserSet(b bool) *CheckSettings {
+	cs.integrityUserSet = b
+	return cs
+}
+
+func (cs *CheckSettings) WithNames(b bool) *CheckSettings {
+	cs.names = b
+	return cs
+}
+
+func (cs *CheckSettings) WithNamesUserSet(b bool) *CheckSettings {
+	cs.namesUserSet = b
+	return cs
+}
+
 func (cs *CheckSettings) WithNumbering(b bool) *CheckSettings {
 	cs.numbering = b
 	return cs
@@ -177,47 +263,70 @@ func (cs *CheckSettings) WithNumberingUserSet(b bool) *CheckSettings {
 	return cs
 }
 
-func (cs *CheckSettings) MaybeDoWork(o output.Bus, ss *SearchSettings) (err *ExitError) {
+func (cs *CheckSettings) MaybeDoWork(ctx context.Context, o output.Bus,
+	ss *SearchSettings) (err *ExitError) {
 	err = NewExitUserError(CheckCommand)
 	if cs.HasWorkToDo(o) {
-		allArtists, loaded := ss.Load(o)
-		err = cs.PerformChecks(o, allArtists, loaded, ss)
+		allArtists, loaded := ss.Load(ctx, o)
+		err = cs.PerformChecks(ctx, o, allArtists, loaded, ss)
 	}
 	return
 }
 
-func (cs *CheckSettings) PerformChecks(o output.Bus, artists []*files.Artist,
-	artistsLoaded bool, ss *SearchSettings) (err *ExitError) {
+// PerformChecks runs the selected analyses and reports the concerns found; when
+// the context is cancelled, the partial results are not reported, as they would
+// be misleading
+func (cs *CheckSettings) PerformChecks(ctx context.Context, o output.Bus,
+	artists []*files.Artist, artistsLoaded bool, ss *SearchSettings) (err *ExitError) {
 	err = NewExitUserError(CheckCommand)
 	if artistsLoaded && len(artists) > 0 {
 		err = nil
 		concernedArtists := PrepareConcernedArtists(artists)
+		duplicateConcernsFound := cs.PerformDuplicateAnalysis(concernedArtists)
 		emptyConcernsFound := cs.PerformEmptyAnalysis(concernedArtists)
+		nameConcernsFound := cs.PerformNamesAnalysis(concernedArtists)
 		numberingConcernsFound := cs.PerformNumberingAnalysis(concernedArtists)
-		fileConcernsFound := cs.PerformFileAnalysis(o, concernedArtists, ss)
+		fileConcernsFound := cs.PerformFileAnalysis(ctx, o, concernedArtists, ss)
+		integrityConcernsFound := cs.PerformIntegrityAnalysis(ctx, o, concernedArtists, ss)
+		if ctx.Err() != nil {
+			o.WriteCanonicalError("The checks were interrupted before they were complete," +
+				" so their results are not reported")
+			return NewExitInterruptedError(CheckCommand)
+		}
 		for _, artist := range concernedArtists {
 			artist.ToConsole(o)
 		}
-		cs.MaybeReportCleanResults(o, emptyConcernsFound, numberingConcernsFound,
-			fileConcernsFound)
+		cs.MaybeReportCleanResults(o, duplicateConcernsFound, emptyConcernsFound,
+			nameConcernsFound, numberingConcernsFound, fileConcernsFound,
+			integrityConcernsFound)
 	}
 	return
 }
 
-func (cs *CheckSettings) MaybeReportCleanResults(o output.Bus, emptyConcerns,
-	numberingConcerns, fileConcerns bool) {
+func (cs *CheckSettings) MaybeReportCleanResults(o output.Bus, duplicateConcerns,
+	emptyConcerns, nameConcerns, numberingConcerns, fileConcerns,
+	integrityConcerns bool) {
+	if !duplicateConcerns && cs.duplicates {
+		o.WriteCanonicalConsole("Duplicate Album Analysis: no duplicated albums found")
+	}
 	if !emptyConcerns && cs.empty {
 		o.WriteCanonicalConsole("Empty Folder Analysis: no empty folders found")
 	}
+	if !nameConcerns && cs.names {
+		o.WriteCanonicalConsole("Name Analysis: no unparseable track names found")
+	}
 	if !numberingConcerns && cs.numbering {
 		o.WriteCanonicalConsole("Numbering Analysis: no missing or duplicate tracks found")
 	}
 	if !fileConcerns && cs.files {
 		o.WriteCanonicalConsole("File Analysis: no inconsistencies found")
 	}
+	if !integrityConcerns && cs.integrity {
+		o.WriteCanonicalConsole("Integrity Analysis: no damaged audio found")
+	}
 }
 
-func (cs *CheckSettings) PerformFileAnalysis(o output.Bus,
+func (cs *CheckSettings) PerformFileAnalysis(ctx context.Context, o output.Bus,
 	concernedArtists []*ConcernedArtist, ss *SearchSettings) bool {
 	foundConcerns := false
 	if cs.files {
@@ -226,7 +335,9 @@ func (cs *CheckSettings) PerformFileAnalysis(o output.Bus,
 			artists = append(artists, cAr.Artist())
 		}
 		if filteredArtists, filtered := ss.Filter(o, artists); filtered {
-			ReadMetadata(o, filteredArtists)
+			if ReadMetadata(ctx, o, filteredArtists) != nil {
+				return false
+			}
 			for _, artist := range filteredArtists {
 				for _, album := range artist.Albums() {
 					for _, track := range album.Tracks() {
@@ -243,14 +354,55 @@ func (cs *CheckSettings) PerformFileAnalysis(o output.Bus,
 	return foundConcerns
 }
 
+// PerformIntegrityAnalysis walks the audio frames of each filtered track and
+// records any problems found as integrity concerns; it stops early when the
+// context is cancelled
+func (cs *CheckSettings) PerformIntegrityAnalysis(ctx context.Context, o output.Bus,
+	concernedArtists []*ConcernedArtist, ss *SearchSettings) bool {
+	foundConcerns := false
+	if cs.integrity {
+		artists := make([]*files.Artist, 0, len(concernedArtists))
+		for _, cAr := range concernedArtists {
+			artists = append(artists, cAr.Artist())
+		}
+		if filteredArtists, filtered := ss.Filter(o, artists); filtered {
+			for _, artist := range filteredArtists {
+				for _, album := range artist.Albums() {
+					for _, track := range album.Tracks() {
+						if ctx.Err() != nil {
+							return foundConcerns
+						}
+						concerns := track.ReportAudioProblems()
+						if found := RecordIntegrityConcerns(concernedArtists, track,
+							concerns); found {
+							foundConcerns = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return foundConcerns
+}
+
 func RecordFileConcerns(concernedArtists []*ConcernedArtist, track *files.Track,
 	concerns []string) (foundConcerns bool) {
+	return recordTrackConcerns(concernedArtists, track, FilesConcern, concerns)
+}
+
+func RecordIntegrityConcerns(concernedArtists []*ConcernedArtist, track *files.Track,
+	concerns []string) (foundConcerns bool) {
+	return recordTrackConcerns(concernedArtists, track, IntegrityConcern, concerns)
+}
+
+func recordTrackConcerns(concernedArtists []*ConcernedArtist, track *files.Track,
+	concernType ConcernType, concerns []string) (foundConcerns bool) {
 	if len(concerns) > 0 {
 		foundConcerns = true
 		for _, cAr := range concernedArtists {
 			if cT := cAr.Lookup(track); cT != nil {
 				for _, s := range concerns {
-					cT.AddConcern(FilesConcern, s)
+					cT.AddConcern(concernType, s)
 				}
 				break
 			}
@@ -259,24 +411,36 @@ func RecordFileConcerns(concernedArtists []*ConcernedArtist, track *files.Track,
 	return foundConcerns
 }
 
+// PerformNamesAnalysis reports each album's track files whose names could not
+// be parsed; as they have no track, the concerns belong to the album
+func (cs *CheckSettings) PerformNamesAnalysis(concernedArtists []*ConcernedArtist) bool {
+	foundConcerns := false
+	if cs.names {
+		for _, cAr := range concernedArtists {
+			for _, cAl := range cAr.Albums() {
+				for _, fileName := range cAl.Album().UnparsedTracks() {
+					cAl.AddConcern(NamesConcern, fmt.Sprintf(
+						"the track file %q has a name that cannot be parsed", fileName))
+					foundConcerns = true
+				}
+			}
+		}
+	}
+	return foundConcerns
+}
+
+// PerformNumberingAnalysis checks the numbering of each album's tracks
 func (cs *CheckSettings) PerformNumberingAnalysis(
 	concernedArtists []*ConcernedArtist) bool {
 	foundConcerns := false
 	if cs.numbering {
 		for _, cAr := range concernedArtists {
 			for _, cAl := range cAr.Albums() {
-				trackMap := map[int][]string{}
-				maxTrack := len(cAl.Tracks())
+				tracks := make([]*files.Track, 0, len(cAl.Tracks()))
 				for _, cT := range cAl.Tracks() {
-					track := cT.Track()
-					trackNumber := track.Number()
-					trackMap[trackNumber] = append(trackMap[trackNumber], cT.name())
-					if trackNumber > maxTrack {
-						maxTrack = trackNumber
-					}
+					tracks = append(tracks, cT.Track())
 				}
-				concerns := GenerateNumberingConcerns(trackMap, maxTrack)
-				if len(concerns) > 0 {
+				if concerns := NumberingConcerns(tracks); len(concerns) > 0 {
 					foundConcerns = true
 					for _, s := range concerns {
 						cAl.AddConcern(NumberingConcern, s)
@@ -288,6 +452,60 @@ func (cs *CheckSettings) PerformNumberingAnalysis(
 	return foundConcerns
 }
 
+// NumberingConcerns returns the problems with the numbering of an album's
+// tracks; the tracks of a multi-disc album are numbered per disc, so each disc
+// is checked separately
+func NumberingConcerns(tracks []*files.Track) []string {
+	discMap := map[int][]*files.Track{}
+	for _, track := range tracks {
+		discMap[track.Disc()] = append(discMap[track.Disc()], track)
+	}
+	discs := make([]int, 0, len(discMap))
+	for disc := range discMap {
+		discs = append(discs, disc)
+	}
+	slices.Sort(discs)
+	concerns := GenerateDiscConcerns(discs)
+	for _, disc := range discs {
+		trackMap := map[int][]string{}
+		maxTrack := len(discMap[disc])
+		for _, track := range discMap[disc] {
+			trackNumber := track.Number()
+			trackMap[trackNumber] = append(trackMap[trackNumber], track.CommonName())
+			if trackNumber > maxTrack {
+				maxTrack = trackNumber
+			}
+		}
+		for _, s := range GenerateNumberingConcerns(trackMap, maxTrack) {
+			if disc != 0 {
+				s = fmt.Sprintf("disc %d: %s", disc, s)
+			}
+			concerns = append(concerns, s)
+		}
+	}
+	return concerns
+}
+
+// GenerateDiscConcerns reports the discs missing from a multi-disc album, given
+// the sorted disc numbers of its tracks; disc 0 holds the tracks that are not
+// on any disc
+func GenerateDiscConcerns(discs []int) []string {
+	missingNumbers := []string{}
+	previous := 0
+	for _, disc := range discs {
+		if disc > previous+1 {
+			missingNumbers = append(missingNumbers, GenerateMissingNumbers(previous+1,
+				disc-1))
+		}
+		previous = disc
+	}
+	if len(missingNumbers) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("missing discs identified: %s",
+		strings.Join(missingNumbers, ", "))}
+}
+
 func GenerateNumberingConcerns(m map[int][]string, maxTrack int) []string {
 	concerns := make([]string, 0, len(m)+1)
 	numbers := []int{}
@@ -343,6 +561,34 @@ func GenerateMissingNumbers(low, high int) string {
 	}
 }
 
+// PerformDuplicateAnalysis records a concern for each album that its artist has
+// more than once, as happens when the artist is found under several top
+// directories, each holding a copy of the album
+func (cs *CheckSettings) PerformDuplicateAnalysis(concernedArtists []*ConcernedArtist) bool {
+	foundConcerns := false
+	if !cs.duplicates {
+		return foundConcerns
+	}
+	for _, cAr := range concernedArtists {
+		paths := map[string][]string{}
+		for _, cAl := range cAr.Albums() {
+			album := cAl.Album()
+			paths[album.Name()] = append(paths[album.Name()], album.Path())
+		}
+		for _, cAl := range cAr.Albums() {
+			album := cAl.Album()
+			for _, path := range paths[album.Name()] {
+				if path != album.Path() {
+					cAl.AddConcern(DuplicateConcern,
+						fmt.Sprintf("the album is also found in %q", path))
+					foundConcerns = true
+				}
+			}
+		}
+	}
+	return foundConcerns
+}
+
 func (cs *CheckSettings) PerformEmptyAnalysis(concernedArtists []*ConcernedArtist) bool {
 	emptyFoldersFound := false
 	if cs.empty {
@@ -352,7 +598,8 @@ func (cs *CheckSettings) PerformEmptyAnalysis(concernedArtists []*ConcernedArtis
 				emptyFoldersFound = true
 			} else {
 				for _, concernedAlbum := range concernedArtist.Albums() {
-					if !concernedAlbum.Album().HasTracks() {
+					album := concernedAlbum.Album()
+					if !album.HasTracks() && len(album.UnparsedTracks()) == 0 {
 						concernedAlbum.AddConcern(EmptyConcern, "no tracks found")
 						emptyFoldersFound = true
 					}
@@ -364,41 +611,43 @@ func (cs *CheckSettings) PerformEmptyAnalysis(concernedArtists []*ConcernedArtis
 }
 
 func (cs *CheckSettings) HasWorkToDo(o output.Bus) bool {
-	if cs.empty || cs.files || cs.numbering {
+	if cs.duplicates || cs.empty || cs.files || cs.integrity || cs.names || cs.numbering {
 		return true
 	}
-	userPartiallyAtFault := cs.emptyUserSet || cs.filesUserSet || cs.numberingUserSet
+	userPartiallyAtFault := cs.duplicatesUserSet || cs.emptyUserSet || cs.filesUserSet ||
+		cs.integrityUserSet || cs.namesUserSet || cs.numberingUserSet
 	o.WriteCanonicalError("No checks will be executed.\nWhy?\n")
 	if userPartiallyAtFault {
-		flagsUserSet := make([]string, 0, 3)
-		flagsFromConfig := make([]string, 0, 3)
-		if cs.emptyUserSet {
-			flagsUserSet = append(flagsUserSet, CheckEmptyFlag)
-		} else {
-			flagsFromConfig = append(flagsFromConfig, CheckEmptyFlag)
-		}
-		if cs.filesUserSet {
-			flagsUserSet = append(flagsUserSet, CheckFilesFlag)
-		} else {
-			flagsFromConfig = append(flagsFromConfig, CheckFilesFlag)
-		}
-		if cs.numberingUserSet {
-			flagsUserSet = append(flagsUserSet, CheckNumberingFlag)
-		} else {
-			flagsFromConfig = append(flagsFromConfig, CheckNumberingFlag)
+		flagsUserSet := make([]string, 0, 6)
+		flagsFromConfig := make([]string, 0, 6)
+		for _, flag := range []struct {
+			name    string
+			userSet bool
+		}{
+			{name: CheckDuplicatesFlag, userSet: cs.duplicatesUserSet},
+			{name: CheckEmptyFlag, userSet: cs.emptyUserSet},
+			{name: CheckFilesFlag, userSet: cs.filesUserSet},
+			{name: CheckIntegrityFlag, userSet: cs.integrityUserSet},
+			{name: CheckNamesFlag, userSet: cs.namesUserSet},
+			{name: CheckNumberingFlag, userSet: cs.numberingUserSet},
+		} {
+			if flag.userSet {
+				flagsUserSet = append(flagsUserSet, flag.name)
+			} else {
+				flagsFromConfig = append(flagsFromConfig, flag.name)
+			}
 		}
 		if len(flagsFromConfig) == 0 {
-			o.WriteCanonicalError("You explicitly set %s, %s, and %s false",
-				CheckEmptyFlag, CheckFilesFlag, CheckNumberingFlag)
+			o.WriteCanonicalError("You explicitly set %s false", listFlags(flagsUserSet))
 		} else {
 			o.WriteCanonicalError(
 				"In addition to %s configured false, you explicitly set %s false",
-				strings.Join(flagsFromConfig, " and "),
-				strings.Join(flagsUserSet, " and "))
+				listFlags(flagsFromConfig), listFlags(flagsUserSet))
 		}
 	} else {
-		o.WriteCanonicalError("The flags %s, %s, and %s are all configured false",
-			CheckEmptyFlag, CheckFilesFlag, CheckNumberingFlag)
+		o.WriteCanonicalError("The flags %s are all configured false", listFlags([]string{
+			CheckDuplicatesFlag, CheckEmptyFlag, CheckFilesFlag, CheckIntegrityFlag, CheckNamesFlag,
+			CheckNumberingFlag}))
 	}
 	o.WriteError("What to do:\n")
 	o.WriteCanonicalError("Either:\n[1] Edit the configuration file so that at least one" +
@@ -407,10 +656,23 @@ func (cs *CheckSettings) HasWorkToDo(o output.Bus) bool {
 	return false
 }
 
+// listFlags joins flag names into an English list: "a", "a and b", or
+// "a, b, and c"
+func listFlags(flags []string) string {
+	if len(flags) < 3 {
+		return strings.Join(flags, " and ")
+	}
+	return strings.Join(flags[:len(flags)-1], ", ") + ", and " + flags[len(flags)-1]
+}
+
 func ProcessCheckFlags(o output.Bus, values map[string]*FlagValue) (*CheckSettings, bool) {
 	settings := &CheckSettings{}
 	ok := true // optimistic
 	var err error
+	if settings.duplicates, settings.duplicatesUserSet, err = GetBool(o, values,
+		CheckDuplicates); err != nil {
+		ok = false
+	}
 	if settings.empty, settings.emptyUserSet, err = GetBool(o, values,
 		CheckEmpty); err != nil {
 		ok = false
@@ -419,6 +681,14 @@ func ProcessCheckFlags(o output.Bus, values map[string]*FlagValue) (*CheckSettin
 		CheckFiles); err != nil {
 		ok = false
 	}
+	if settings.integrity, settings.integrityUserSet, err = GetBool(o, values,
+		CheckIntegrity); err != nil {
+		ok = false
+	}
+	if settings.names, settings.namesUserSet, err = GetBool(o, values,
+		CheckNames); err != nil {
+		ok = false
+	}
 	if settings.numbering, settings.numberingUserSet, err = GetBool(o, values,
 		CheckNumbering); err != nil {
 		ok = false
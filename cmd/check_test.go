@@ -4,13 +4,16 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd_test
 
 import (
+	"context"
 	"fmt"
 	"mp3/cmd"
 	"mp3/internal/files"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
@@ -30,10 +33,17 @@ func TestProcessCheckFlags(t *testing.T) {
 			want1:  false,
 			WantedRecording: output.WantedRecording{
 				Error: "" +
+					"An internal error occurred: flag \"duplicates\" is not found.\n" +
 					"An internal error occurred: flag \"empty\" is not found.\n" +
 					"An internal error occurred: flag \"files\" is not found.\n" +
+					"An internal error occurred: flag \"integrity\" is not found.\n" +
+					"An internal error occurred: flag \"names\" is not found.\n" +
 					"An internal error occurred: flag \"numbering\" is not found.\n",
 				Log: "" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='duplicates'" +
+					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
 					" flag='empty'" +
@@ -44,28 +54,44 @@ func TestProcessCheckFlags(t *testing.T) {
 					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
+					" flag='integrity'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='names'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
 					" flag='numbering'" +
 					" msg='internal error'\n",
 			},
 		},
 		"out of the box": {
 			values: map[string]*cmd.FlagValue{
-				"empty":     cmd.NewFlagValue().WithValue(false),
-				"files":     cmd.NewFlagValue().WithValue(false),
-				"numbering": cmd.NewFlagValue().WithValue(false),
+				"duplicates": cmd.NewFlagValue().WithValue(false),
+				"empty":      cmd.NewFlagValue().WithValue(false),
+				"files":      cmd.NewFlagValue().WithValue(false),
+				"integrity":  cmd.NewFlagValue().WithValue(false),
+				"names":      cmd.NewFlagValue().WithValue(false),
+				"numbering":  cmd.NewFlagValue().WithValue(false),
 			},
 			want:  cmd.NewCheckSettings(),
 			want1: true,
 		},
 		"overridden": {
 			values: map[string]*cmd.FlagValue{
-				"empty":     cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
-				"files":     cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
-				"numbering": cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"duplicates": cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"empty":      cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"files":      cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"integrity":  cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"names":      cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
+				"numbering":  cmd.NewFlagValue().WithValue(true).WithExplicitlySet(true),
 			},
-			want: cmd.NewCheckSettings().WithEmpty(true).WithEmptyUserSet(
-				true).WithFiles(true).WithFilesUserSet(true).WithNumbering(
-				true).WithNumberingUserSet(true),
+			want: cmd.NewCheckSettings().WithDuplicates(true).WithDuplicatesUserSet(
+				true).WithEmpty(true).WithEmptyUserSet(true).WithFiles(true).WithFilesUserSet(
+				true).WithIntegrity(true).WithIntegrityUserSet(true).WithNames(
+				true).WithNamesUserSet(
+				true).WithNumbering(true).WithNumberingUserSet(true),
 			want1: true,
 		},
 	}
@@ -101,7 +127,8 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"The flags --empty, --files, and --numbering are all configured false.\n" +
+					"The flags --duplicates, --empty, --files, --integrity, --names, and" +
+					" --numbering are all configured false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -117,8 +144,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --files and --numbering configured false, you" +
-					" explicitly set --empty false.\n" +
+					"In addition to --duplicates, --files, --integrity, --names, and" +
+					" --numbering configured false," +
+					" you explicitly set --empty false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -134,8 +162,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --empty and --numbering configured false, you" +
-					" explicitly set --files false.\n" +
+					"In addition to --duplicates, --empty, --integrity, --names, and" +
+					" --numbering configured false," +
+					" you explicitly set --files false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -151,8 +180,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --empty and --files configured false, you explicitly" +
-					" set --numbering false.\n" +
+					"In addition to --duplicates, --empty, --files, --integrity, and --names" +
+					" configured false," +
+					" you explicitly set --numbering false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -168,8 +198,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --numbering configured false, you explicitly set" +
-					" --empty and --files false.\n" +
+					"In addition to --duplicates, --integrity, --names, and --numbering" +
+					" configured false, you" +
+					" explicitly set --empty and --files false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -185,8 +216,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --files configured false, you explicitly set --empty" +
-					" and --numbering false.\n" +
+					"In addition to --duplicates, --files, --integrity, and --names configured" +
+					" false, you" +
+					" explicitly set --empty and --numbering false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -202,8 +234,9 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"In addition to --empty configured false, you explicitly set --files" +
-					" and --numbering false.\n" +
+					"In addition to --duplicates, --empty, --integrity, and --names configured" +
+					" false, you" +
+					" explicitly set --files and --numbering false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -212,7 +245,25 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 					" line.\n",
 			},
 		},
-		"no work, all flags configured that way": {
+		"no work, integrity configured that way": {
+			cs:   cmd.NewCheckSettings().WithIntegrityUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No checks will be executed.\n" +
+					"Why?\n" +
+					"In addition to --duplicates, --empty, --files, --names, and --numbering" +
+					" configured false," +
+					" you explicitly set --integrity false.\n" +
+					"What to do:\n" +
+					"Either:\n" +
+					"[1] Edit the configuration file so that at least one of these flags" +
+					" is true, or\n" +
+					"[2] explicitly set at least one of these flags true on the command" +
+					" line.\n",
+			},
+		},
+		"no work, all but integrity configured that way": {
 			cs: cmd.NewCheckSettings().WithNumberingUserSet(true).WithFilesUserSet(
 				true).WithEmptyUserSet(true),
 			want: false,
@@ -220,7 +271,65 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"You explicitly set --empty, --files, and --numbering false.\n" +
+					"In addition to --duplicates, --integrity, and --names configured false," +
+					" you explicitly" +
+					" set --empty, --files, and --numbering false.\n" +
+					"What to do:\n" +
+					"Either:\n" +
+					"[1] Edit the configuration file so that at least one of these flags" +
+					" is true, or\n" +
+					"[2] explicitly set at least one of these flags true on the command" +
+					" line.\n",
+			},
+		},
+		"no work, names configured that way": {
+			cs:   cmd.NewCheckSettings().WithNamesUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No checks will be executed.\n" +
+					"Why?\n" +
+					"In addition to --duplicates, --empty, --files, --integrity, and" +
+					" --numbering configured" +
+					" false, you explicitly set --names false.\n" +
+					"What to do:\n" +
+					"Either:\n" +
+					"[1] Edit the configuration file so that at least one of these flags" +
+					" is true, or\n" +
+					"[2] explicitly set at least one of these flags true on the command" +
+					" line.\n",
+			},
+		},
+		"no work, all but names configured that way": {
+			cs: cmd.NewCheckSettings().WithNumberingUserSet(true).WithFilesUserSet(
+				true).WithEmptyUserSet(true).WithIntegrityUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No checks will be executed.\n" +
+					"Why?\n" +
+					"In addition to --duplicates and --names configured false, you explicitly" +
+					" set --empty," +
+					" --files, --integrity, and --numbering false.\n" +
+					"What to do:\n" +
+					"Either:\n" +
+					"[1] Edit the configuration file so that at least one of these flags" +
+					" is true, or\n" +
+					"[2] explicitly set at least one of these flags true on the command" +
+					" line.\n",
+			},
+		},
+		"no work, all flags configured that way": {
+			cs: cmd.NewCheckSettings().WithNumberingUserSet(true).WithFilesUserSet(
+				true).WithEmptyUserSet(true).WithIntegrityUserSet(true).WithNamesUserSet(
+				true).WithDuplicatesUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No checks will be executed.\n" +
+					"Why?\n" +
+					"You explicitly set --duplicates, --empty, --files, --integrity, --names," +
+					" and --numbering false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -237,6 +346,14 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 			cs:   cmd.NewCheckSettings().WithFiles(true),
 			want: true,
 		},
+		"check integrity": {
+			cs:   cmd.NewCheckSettings().WithIntegrity(true),
+			want: true,
+		},
+		"check names": {
+			cs:   cmd.NewCheckSettings().WithNames(true),
+			want: true,
+		},
 		"check numbering": {
 			cs:   cmd.NewCheckSettings().WithNumbering(true),
 			want: true,
@@ -254,7 +371,8 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 			want: true,
 		},
 		"check everything": {
-			cs:   cmd.NewCheckSettings().WithEmpty(true).WithFiles(true).WithNumbering(true),
+			cs: cmd.NewCheckSettings().WithEmpty(true).WithFiles(true).WithIntegrity(
+				true).WithNames(true).WithNumbering(true),
 			want: true,
 		},
 	}
@@ -273,7 +391,69 @@ func TestCheckSettings_HasWorkToDo(t *testing.T) {
 	}
 }
 
+func TestCheckSettings_PerformDuplicateAnalysis(t *testing.T) {
+	merged := files.NewArtist("artist", filepath.Join("music", "artist"))
+	merged.AddAlbum(files.NewAlbum("album", merged, filepath.Join("music", "artist", "album")))
+	overflow := files.NewArtist("artist", filepath.Join("overflow", "artist"))
+	overflow.AddAlbum(files.NewAlbum("album", overflow,
+		filepath.Join("overflow", "artist", "album")))
+	overflow.AddAlbum(files.NewAlbum("other album", overflow,
+		filepath.Join("overflow", "artist", "other album")))
+	merged.MergeAlbums(overflow)
+	tests := map[string]struct {
+		cs      *cmd.CheckSettings
+		artists []*files.Artist
+		want    bool
+		output.WantedRecording
+	}{
+		"not requested": {
+			cs:      cmd.NewCheckSettings().WithDuplicates(false),
+			artists: []*files.Artist{merged},
+		},
+		"no duplicates": {
+			cs:      cmd.NewCheckSettings().WithDuplicates(true),
+			artists: generateArtists(2, 3, 4),
+		},
+		"duplicate album": {
+			cs:      cmd.NewCheckSettings().WithDuplicates(true),
+			artists: []*files.Artist{merged},
+			want:    true,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"Artist \"artist\"\n" +
+					"  Album \"album\"\n" +
+					"  * [duplicate] the album is also found in" +
+					" \"overflow\\\\artist\\\\album\"\n" +
+					"  Album \"album\"\n" +
+					"  * [duplicate] the album is also found in" +
+					" \"music\\\\artist\\\\album\"\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			concernedArtists := cmd.PrepareConcernedArtists(tt.artists)
+			if got := tt.cs.PerformDuplicateAnalysis(concernedArtists); got != tt.want {
+				t.Errorf("CheckSettings.PerformDuplicateAnalysis() = %v, want %v", got, tt.want)
+			}
+			o := output.NewRecorder()
+			for _, cAr := range concernedArtists {
+				cAr.ToConsole(o)
+			}
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
+				for _, difference := range differences {
+					t.Errorf("CheckSettings.PerformDuplicateAnalysis() %s", difference)
+				}
+			}
+		})
+	}
+}
+
 func TestCheckSettings_PerformEmptyAnalysis(t *testing.T) {
+	misnamedArtists := generateArtists(1, 2, 0)
+	for _, album := range misnamedArtists[0].Albums() {
+		album.AddUnparsedTrack("bonus track.mp3")
+	}
 	tests := map[string]struct {
 		cs             *cmd.CheckSettings
 		checkedArtists []*cmd.ConcernedArtist
@@ -298,6 +478,10 @@ func TestCheckSettings_PerformEmptyAnalysis(t *testing.T) {
 			checkedArtists: cmd.PrepareConcernedArtists(generateArtists(4, 6, 0)),
 			want:           true,
 		},
+		"albums holding only unparsed track files": {
+			cs:             cmd.NewCheckSettings().WithEmpty(true),
+			checkedArtists: cmd.PrepareConcernedArtists(misnamedArtists),
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -318,6 +502,60 @@ func TestCheckSettings_PerformEmptyAnalysis(t *testing.T) {
 	}
 }
 
+func TestCheckSettings_PerformNamesAnalysis(t *testing.T) {
+	artist := files.NewArtist("artist", filepath.Join("music", "artist"))
+	album := files.NewAlbum("album", artist, filepath.Join("music", "artist", "album"))
+	artist.AddAlbum(album)
+	album.AddTrack(files.NewTrack(album, "1 intro.mp3", "intro", 1))
+	album.AddUnparsedTrack("outro.mp3")
+	album.AddUnparsedTrack("hidden track.mp3")
+	tests := map[string]struct {
+		cs      *cmd.CheckSettings
+		artists []*files.Artist
+		want    bool
+		output.WantedRecording
+	}{
+		"do nothing": {
+			cs:      cmd.NewCheckSettings(),
+			artists: []*files.Artist{artist},
+		},
+		"all names parsed": {
+			cs:      cmd.NewCheckSettings().WithNames(true),
+			artists: generateArtists(2, 2, 2),
+		},
+		"unparsed names": {
+			cs:      cmd.NewCheckSettings().WithNames(true),
+			artists: []*files.Artist{artist},
+			want:    true,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"Artist \"artist\"\n" +
+					"  Album \"album\"\n" +
+					"  * [names] the track file \"hidden track.mp3\" has a name that cannot be" +
+					" parsed\n" +
+					"  * [names] the track file \"outro.mp3\" has a name that cannot be parsed\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			concernedArtists := cmd.PrepareConcernedArtists(tt.artists)
+			if got := tt.cs.PerformNamesAnalysis(concernedArtists); got != tt.want {
+				t.Errorf("CheckSettings.PerformNamesAnalysis() = %v, want %v", got, tt.want)
+			}
+			o := output.NewRecorder()
+			for _, cAr := range concernedArtists {
+				cAr.ToConsole(o)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("CheckSettings.PerformNamesAnalysis() %s", difference)
+				}
+			}
+		})
+	}
+}
+
 func TestGenerateMissingNumbers(t *testing.T) {
 	type args struct {
 		low  int
@@ -395,6 +633,25 @@ func TestGenerateNumberingConcerns(t *testing.T) {
 	}
 }
 
+func TestGenerateDiscConcerns(t *testing.T) {
+	tests := map[string]struct {
+		discs []int
+		want  []string
+	}{
+		"single disc":    {discs: []int{0}, want: nil},
+		"complete":       {discs: []int{1, 2, 3}, want: nil},
+		"missing discs":  {discs: []int{2, 5}, want: []string{"missing discs identified: 1, 3-4"}},
+		"missing middle": {discs: []int{1, 4}, want: []string{"missing discs identified: 2-3"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmd.GenerateDiscConcerns(tt.discs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateDiscConcerns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCheckSettings_PerformNumberingAnalysis(t *testing.T) {
 	defectiveArtists := []*files.Artist{}
 	for r := 0; r < 4; r++ {
@@ -414,6 +671,20 @@ func TestCheckSettings_PerformNumberingAnalysis(t *testing.T) {
 		}
 		defectiveArtists = append(defectiveArtists, artist)
 	}
+	multiDiscArtist := func(discs ...int) []*files.Artist {
+		artist := files.NewArtist("my artist", filepath.Join("Music", "my artist"))
+		album := files.NewAlbum("my album", artist, filepath.Join("Music", "my artist",
+			"my album"))
+		for _, disc := range discs {
+			for j := 1; j <= 2; j++ {
+				trackName := fmt.Sprintf("my track %d%d", disc, j)
+				album.AddTrack(files.NewTrack(album, fmt.Sprintf("%d-%02d %s.mp3", disc,
+					j, trackName), trackName, j).WithDisc(disc))
+			}
+		}
+		artist.AddAlbum(album)
+		return []*files.Artist{artist}
+	}
 
 	tests := map[string]struct {
 		cs             *cmd.CheckSettings
@@ -435,6 +706,16 @@ func TestCheckSettings_PerformNumberingAnalysis(t *testing.T) {
 			checkedArtists: cmd.PrepareConcernedArtists(defectiveArtists),
 			want:           true,
 		},
+		"complete multi-disc album": {
+			cs:             cmd.NewCheckSettings().WithNumbering(true),
+			checkedArtists: cmd.PrepareConcernedArtists(multiDiscArtist(1, 2)),
+			want:           false,
+		},
+		"missing disc found": {
+			cs:             cmd.NewCheckSettings().WithNumbering(true),
+			checkedArtists: cmd.PrepareConcernedArtists(multiDiscArtist(1, 3)),
+			want:           true,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -515,7 +796,7 @@ func TestCheckSettings_PerformFileAnalysis(t *testing.T) {
 	defer func() {
 		cmd.ReadMetadata = originalReadMetadata
 	}()
-	cmd.ReadMetadata = func(_ output.Bus, _ []*files.Artist) {}
+	cmd.ReadMetadata = func(_ context.Context, _ output.Bus, _ []*files.Artist) error { return nil }
 	type args struct {
 		checkedArtists []*cmd.ConcernedArtist
 		ss             *cmd.SearchSettings
@@ -566,8 +847,8 @@ func TestCheckSettings_PerformFileAnalysis(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			if got := tt.cs.PerformFileAnalysis(o, tt.args.checkedArtists,
-				tt.args.ss); got != tt.want {
+			if got := tt.cs.PerformFileAnalysis(context.Background(), o,
+				tt.args.checkedArtists, tt.args.ss); got != tt.want {
 				t.Errorf("CheckSettings.PerformFileAnalysis() = %v, want %v", got, tt.want)
 			}
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
@@ -579,11 +860,100 @@ func TestCheckSettings_PerformFileAnalysis(t *testing.T) {
 	}
 }
 
+func TestRecordIntegrityConcerns(t *testing.T) {
+	originalArtists := generateArtists(2, 3, 4)
+	concernedArtists := cmd.PrepareConcernedArtists(originalArtists)
+	track := originalArtists[1].Albums()[2].Tracks()[3]
+	if got := cmd.RecordIntegrityConcerns(concernedArtists, track, nil); got {
+		t.Errorf("RecordIntegrityConcerns() = %v, want false", got)
+	}
+	if got := cmd.RecordIntegrityConcerns(concernedArtists, track,
+		[]string{"the frame at offset 1668 fails its CRC check"}); !got {
+		t.Errorf("RecordIntegrityConcerns() = %v, want true", got)
+	}
+	o := output.NewRecorder()
+	concernedArtists[1].ToConsole(o)
+	if !strings.Contains(o.ConsoleOutput(),
+		"* [integrity] the frame at offset 1668 fails its CRC check") {
+		t.Errorf("RecordIntegrityConcerns() did not record an integrity concern: %q",
+			o.ConsoleOutput())
+	}
+}
+
+func TestCheckSettings_PerformIntegrityAnalysis(t *testing.T) {
+	type args struct {
+		checkedArtists []*cmd.ConcernedArtist
+		ss             *cmd.SearchSettings
+	}
+	tests := map[string]struct {
+		cs *cmd.CheckSettings
+		args
+		want bool
+		output.WantedRecording
+	}{
+		"not permitted to do anything": {
+			cs:              cmd.NewCheckSettings().WithIntegrity(false),
+			args:            args{},
+			want:            false,
+			WantedRecording: output.WantedRecording{},
+		},
+		"allowed, but nothing to check": {
+			cs: cmd.NewCheckSettings().WithIntegrity(true),
+			args: args{
+				checkedArtists: []*cmd.ConcernedArtist{},
+				ss:             cmd.NewSearchSettings(),
+			},
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No music files remain after filtering.\n" +
+					"Why?\n" +
+					"After applying --artistFilter=<nil>, --albumFilter=<nil>, and" +
+					" --trackFilter=<nil>, no files remained.\n" +
+					"What to do:\n" +
+					"Use less restrictive filter settings.\n",
+				Log: "level='error' --albumFilter='<nil>' --artistFilter='<nil>'" +
+					" --trackFilter='<nil>' msg='no files remain after filtering'\n",
+			},
+		},
+		"work to do": {
+			// the generated tracks do not exist, so each one is a concern
+			cs: cmd.NewCheckSettings().WithIntegrity(true),
+			args: args{
+				checkedArtists: cmd.PrepareConcernedArtists(generateArtists(4, 5, 6)),
+				ss: cmd.NewSearchSettings().WithArtistFilter(
+					regexp.MustCompile(".*")).WithAlbumFilter(regexp.MustCompile(
+					".*")).WithTrackFilter(regexp.MustCompile(".*")),
+			},
+			want:            true,
+			WantedRecording: output.WantedRecording{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if got := tt.cs.PerformIntegrityAnalysis(context.Background(), o,
+				tt.args.checkedArtists, tt.args.ss); got != tt.want {
+				t.Errorf("CheckSettings.PerformIntegrityAnalysis() = %v, want %v", got,
+					tt.want)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("CheckSettings.PerformIntegrityAnalysis() %s", difference)
+				}
+			}
+		})
+	}
+}
+
 func TestCheckSettings_MaybeReportCleanResults(t *testing.T) {
 	type args struct {
+		duplicateConcerns bool
 		emptyConcerns     bool
+		nameConcerns      bool
 		numberingConcerns bool
 		fileConcerns      bool
+		integrityConcerns bool
 	}
 	tests := map[string]struct {
 		cs *cmd.CheckSettings
@@ -596,29 +966,38 @@ func TestCheckSettings_MaybeReportCleanResults(t *testing.T) {
 			WantedRecording: output.WantedRecording{},
 		},
 		"all concerns found, everything was checked": {
-			cs: cmd.NewCheckSettings().WithEmpty(true).WithNumbering(true).WithFiles(true),
+			cs: cmd.NewCheckSettings().WithDuplicates(true).WithEmpty(true).WithNames(
+				true).WithNumbering(true).WithFiles(true).WithIntegrity(true),
 			args: args{
+				duplicateConcerns: true,
 				emptyConcerns:     true,
+				nameConcerns:      true,
 				numberingConcerns: true,
-				fileConcerns:      true},
+				fileConcerns:      true,
+				integrityConcerns: true},
 			WantedRecording: output.WantedRecording{},
 		},
 		"no concerns found, everything was checked": {
-			cs:   cmd.NewCheckSettings().WithEmpty(true).WithNumbering(true).WithFiles(true),
-			args: args{emptyConcerns: false, numberingConcerns: false, fileConcerns: false},
+			cs: cmd.NewCheckSettings().WithDuplicates(true).WithEmpty(true).WithNames(
+				true).WithNumbering(true).WithFiles(true).WithIntegrity(true),
+			args: args{},
 			WantedRecording: output.WantedRecording{
 				Console: "" +
+					"Duplicate Album Analysis: no duplicated albums found.\n" +
 					"Empty Folder Analysis: no empty folders found.\n" +
+					"Name Analysis: no unparseable track names found.\n" +
 					"Numbering Analysis: no missing or duplicate tracks found.\n" +
-					"File Analysis: no inconsistencies found.\n",
+					"File Analysis: no inconsistencies found.\n" +
+					"Integrity Analysis: no damaged audio found.\n",
 			},
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			tt.cs.MaybeReportCleanResults(o, tt.args.emptyConcerns, tt.args.numberingConcerns,
-				tt.args.fileConcerns)
+			tt.cs.MaybeReportCleanResults(o, tt.args.duplicateConcerns, tt.args.emptyConcerns,
+				tt.args.nameConcerns, tt.args.numberingConcerns, tt.args.fileConcerns,
+				tt.args.integrityConcerns)
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
 				for _, difference := range differences {
 					t.Errorf("CheckSettings.MaybeReportCleanResults() %s", difference)
@@ -633,8 +1012,13 @@ func TestCheckSettings_PerformChecks(t *testing.T) {
 	defer func() {
 		cmd.ReadMetadata = originalReadMetadata
 	}()
-	cmd.ReadMetadata = func(_ output.Bus, _ []*files.Artist) {}
+	cmd.ReadMetadata = func(ctx context.Context, _ output.Bus, _ []*files.Artist) error {
+		return ctx.Err()
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
 	type args struct {
+		ctx           context.Context
 		artists       []*files.Artist
 		artistsLoaded bool
 		ss            *cmd.SearchSettings
@@ -645,9 +1029,26 @@ func TestCheckSettings_PerformChecks(t *testing.T) {
 		wantStatus *cmd.ExitError
 		output.WantedRecording
 	}{
+		"interrupted": {
+			cs: cmd.NewCheckSettings().WithEmpty(true).WithNumbering(true).WithFiles(true),
+			args: args{
+				ctx:           cancelled,
+				artists:       generateArtists(1, 2, 3),
+				artistsLoaded: true,
+				ss: cmd.NewSearchSettings().WithArtistFilter(
+					regexp.MustCompile(".*")).WithAlbumFilter(
+					regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile(".*")),
+			},
+			wantStatus: cmd.NewExitInterruptedError("check"),
+			WantedRecording: output.WantedRecording{
+				Error: "The checks were interrupted before they were complete, so their" +
+					" results are not reported.\n",
+			},
+		},
 		"no artists loaded": {
 			cs: nil,
 			args: args{
+				ctx:           context.Background(),
 				artists:       generateArtists(1, 1, 1),
 				artistsLoaded: false,
 				ss:            nil,
@@ -656,14 +1057,20 @@ func TestCheckSettings_PerformChecks(t *testing.T) {
 			WantedRecording: output.WantedRecording{},
 		},
 		"no artists": {
-			cs:              nil,
-			args:            args{artists: nil, artistsLoaded: true, ss: nil},
+			cs: nil,
+			args: args{
+				ctx:           context.Background(),
+				artists:       nil,
+				artistsLoaded: true,
+				ss:            nil,
+			},
 			wantStatus:      cmd.NewExitUserError("check"),
 			WantedRecording: output.WantedRecording{},
 		},
 		"artists to check, check everything": {
 			cs: cmd.NewCheckSettings().WithEmpty(true).WithNumbering(true).WithFiles(true),
 			args: args{
+				ctx:           context.Background(),
 				artists:       generateArtists(1, 2, 3),
 				artistsLoaded: true,
 				ss: cmd.NewSearchSettings().WithArtistFilter(
@@ -702,7 +1109,7 @@ func TestCheckSettings_PerformChecks(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			if got := tt.cs.PerformChecks(o, tt.args.artists, tt.args.artistsLoaded,
+			if got := tt.cs.PerformChecks(tt.args.ctx, o, tt.args.artists, tt.args.artistsLoaded,
 				tt.args.ss); !compareExitErrors(got, tt.wantStatus) {
 				t.Errorf("CheckSettings.PerformChecks() got %s want %s", got, tt.wantStatus)
 			}
@@ -716,6 +1123,13 @@ func TestCheckSettings_PerformChecks(t *testing.T) {
 }
 
 func TestCheckSettings_MaybeDoWork(t *testing.T) {
+	originalSince := cmd.Since
+	defer func() {
+		cmd.Since = originalSince
+	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
 	tests := map[string]struct {
 		cs         *cmd.CheckSettings
 		ss         *cmd.SearchSettings
@@ -730,8 +1144,8 @@ func TestCheckSettings_MaybeDoWork(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"The flags --empty, --files, and --numbering are all configured" +
-					" false.\n" +
+					"The flags --duplicates, --empty, --files, --integrity, --names, and" +
+					" --numbering are all configured false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -759,6 +1173,11 @@ func TestCheckSettings_MaybeDoWork(t *testing.T) {
 					"Set --topDir to the path of a directory that contains artist" +
 					" directories.\n",
 				Log: "" +
+					"level='info'" +
+					" --topDir='no dir'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
 					"level='error'" +
 					" directory='no dir'" +
 					" error='open no dir: The system cannot find the file specified.'" +
@@ -772,10 +1191,11 @@ func TestCheckSettings_MaybeDoWork(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			if got := tt.cs.MaybeDoWork(o, tt.ss); !compareExitErrors(got, tt.wantStatus) {
+			if got := tt.cs.MaybeDoWork(context.Background(), o,
+				tt.ss); !compareExitErrors(got, tt.wantStatus) {
 				t.Errorf("CheckSettings.MaybeDoWork() got %s want %s", got, tt.wantStatus)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("CheckSettings.MaybeDoWork() %s", difference)
 				}
@@ -795,6 +1215,10 @@ func TestCheckRun(t *testing.T) {
 	cmd.SearchFlags = safeSearchFlags
 	checkFlags := cmd.NewSectionFlags().WithSectionName(cmd.CheckCommand).WithFlags(
 		map[string]*cmd.FlagDetails{
+			cmd.CheckDuplicates: cmd.NewFlagDetails().WithAbbreviatedName(
+				cmd.CheckDuplicatesAbbr).WithUsage(
+				"report albums found more than once for the same artist").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 			cmd.CheckEmpty: cmd.NewFlagDetails().WithAbbreviatedName(
 				cmd.CheckEmptyAbbr).WithUsage(
 				"report empty album and artist directories").WithExpectedType(
@@ -803,6 +1227,14 @@ func TestCheckRun(t *testing.T) {
 				cmd.CheckFilesAbbr).WithUsage(
 				"report metadata/file inconsistencies").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.CheckIntegrity: cmd.NewFlagDetails().WithAbbreviatedName(
+				cmd.CheckIntegrityAbbr).WithUsage(
+				"report damaged or inconsistent mp3 audio frames").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
+			cmd.CheckNames: cmd.NewFlagDetails().WithAbbreviatedName(
+				cmd.CheckNamesAbbr).WithUsage(
+				"report mp3 files whose names cannot be parsed").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 			cmd.CheckNumbering: cmd.NewFlagDetails().WithAbbreviatedName(
 				cmd.CheckNumberingAbbr).WithUsage(
 				"report missing track numbers and duplicated track" +
@@ -826,8 +1258,8 @@ func TestCheckRun(t *testing.T) {
 				Error: "" +
 					"No checks will be executed.\n" +
 					"Why?\n" +
-					"The flags --empty, --files, and --numbering are all configured" +
-					" false.\n" +
+					"The flags --duplicates, --empty, --files, --integrity, --names, and" +
+					" --numbering are all configured false.\n" +
 					"What to do:\n" +
 					"Either:\n" +
 					"[1] Edit the configuration file so that at least one of these flags" +
@@ -838,15 +1270,37 @@ func TestCheckRun(t *testing.T) {
 					"level='info'" +
 					" --albumFilter='.*'" +
 					" --artistFilter='.*'" +
+					" --bitrateRange=''" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
+					" --duplicates='false'" +
+					" --durationRange=''" +
 					" --empty='false'" +
 					" --extensions='[.mp3]'" +
 					" --files='false'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --integrity='false'" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --names='false'" +
 					" --numbering='false'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" command='check'" +
+					" duplicates-user-set='false'" +
 					" empty-user-set='false'" +
 					" files-user-set='false'" +
+					" integrity-user-set='false'" +
+					" names-user-set='false'" +
 					" numbering-user-set='false'" +
 					" msg='executing command'\n",
 			},
@@ -897,25 +1351,52 @@ func TestCheckHelp(t *testing.T) {
 					"\"check\" runs checks on mp3 files and their containing directories and reports any problems detected\n" +
 					"\n" +
 					"Usage:\n" +
-					"  check [--empty] [--files] [--numbering] [--albumFilter regex] [--artistFilter regex] [--trackFilter regex] [--topDir dir] [--extensions extensions]\n" +
+					"  check [--duplicates] [--empty] [--files] [--integrity] [--names] [--numbering] [--albumFilter regex] [--artistFilter regex] [--trackFilter regex] [--topDir dir] [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
 					"\n" +
 					"Examples:\n" +
+					"check --duplicates\n" +
+					"  reports albums found more than once for the same artist\n" +
 					"check --empty\n" +
 					"  reports empty artist and album directories\n" +
 					"check --files\n" +
 					"  reads each mp3 file's metadata and reports any inconsistencies found\n" +
+					"check --integrity\n" +
+					"  reads each mp3 file's audio frames and reports lost synchronization, truncated\n" +
+					"  final frames, CRC failures, and Xing header frame and byte counts that do not\n" +
+					"  match the audio\n" +
+					"check --names\n" +
+					"  reports mp3 files whose names cannot be parsed into a track number and name\n" +
 					"check --numbering\n" +
 					"  reports errors in the track numbers of mp3 files\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    regular expression specifying which albums to select (default \".*\")\n" +
-					"      --artistFilter string   regular expression specifying which artists to select (default \".*\")\n" +
-					"  -e, --empty                 report empty album and artist directories (default false)\n" +
-					"      --extensions string     comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
-					"  -f, --files                 report metadata/file inconsistencies (default false)\n" +
-					"  -n, --numbering             report missing track numbers and duplicated track numbering (default false)\n" +
-					"      --topDir string         top directory specifying where to find mp3 files (default \".\")\n" +
-					"      --trackFilter string    regular expression specifying which tracks to select (default \".*\")\n",
+					"      --albumFilter string        regular expression specifying which albums to select (default \".*\")\n" +
+					"      --artistFilter string       regular expression specifying which artists to select (default \".*\")\n" +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"  -d, --duplicates                report albums found more than once for the same artist (default false)\n" +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"  -e, --empty                     report empty album and artist directories (default false)\n" +
+					"      --extensions string         comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
+					"  -f, --files                     report metadata/file inconsistencies (default false)\n" +
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"  -i, --integrity                 report damaged or inconsistent mp3 audio frames (default false)\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"  -N, --names                     report mp3 files whose names cannot be parsed (default false)\n" +
+					"  -n, --numbering                 report missing track numbers and duplicated track numbering (default false)\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             top directory, or list of top directories, specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        regular expression specifying which tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
@@ -950,34 +1431,66 @@ func TestCheckUsage(t *testing.T) {
 			WantedRecording: output.WantedRecording{
 				Console: "" +
 					"Usage:\n" +
-					"  check [--empty] [--files] [--numbering] [--albumFilter regex] [--artistFilter regex] [--trackFilter regex] [--topDir dir] [--extensions extensions]\n" +
+					"  check [--duplicates] [--empty] [--files] [--integrity] [--names] [--numbering] [--albumFilter regex] [--artistFilter regex] [--trackFilter regex] [--topDir dir] [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
 					"\n" +
 					"Examples:\n" +
+					"check --duplicates\n" +
+					"  reports albums found more than once for the same artist\n" +
 					"check --empty\n" +
 					"  reports empty artist and album directories\n" +
 					"check --files\n" +
 					"  reads each mp3 file's metadata and reports any inconsistencies" +
 					" found\n" +
+					"check --integrity\n" +
+					"  reads each mp3 file's audio frames and reports lost synchronization," +
+					" truncated\n" +
+					"  final frames, CRC failures, and Xing header frame and byte counts" +
+					" that do not\n" +
+					"  match the audio\n" +
+					"check --names\n" +
+					"  reports mp3 files whose names cannot be parsed into a track number and name\n" +
 					"check --numbering\n" +
 					"  reports errors in the track numbers of mp3 files\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    " +
+					"      --albumFilter string        " +
 					"regular expression specifying which albums to select (default \".*\")\n" +
-					"      --artistFilter string   " +
+					"      --artistFilter string       " +
 					"regular expression specifying which artists to select (default \".*\")\n" +
-					"  -e, --empty                 " +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"  -d, --duplicates                report albums found more than once for the same artist (default false)\n" +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"  -e, --empty                     " +
 					"report empty album and artist directories (default false)\n" +
-					"      --extensions string     " +
+					"      --extensions string         " +
 					"comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
-					"  -f, --files                 " +
+					"  -f, --files                     " +
 					"report metadata/file inconsistencies (default false)\n" +
-					"  -n, --numbering             " +
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             " +
+					"newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"  -i, --integrity                 " +
+					"report damaged or inconsistent mp3 audio frames (default false)\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   " +
+					"comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"  -N, --names                     report mp3 files whose names cannot be parsed (default false)\n" +
+					"  -n, --numbering                 " +
 					"report missing track numbers and duplicated track numbering (default false)\n" +
-					"      --topDir string         " +
-					"top directory specifying where to find mp3 files (default \".\")\n" +
-					"      --trackFilter string    " +
-					"regular expression specifying which tracks to select (default \".*\")\n",
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             " +
+					"top directory, or list of top directories, specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        " +
+					"regular expression specifying which tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
@@ -588,6 +588,13 @@ func TestAddFlags(t *testing.T) {
 			},
 			wantNames: []string{
 				"albumFilter", "artistFilter", "topDir", "trackFilter", "extensions",
+				"metadataPriority", "compilations", "layout", "trackNames",
+				"metadataCache",
+				"concurrency",
+				"ignore",
+				"genreFilter", "tagArtistFilter", "tagAlbumFilter", "tagTitleFilter",
+				"yearRange", "bitrateRange", "durationRange", "id3Versions",
+				"where",
 			},
 		},
 		"empty details without searches": {
@@ -650,6 +657,16 @@ func TestAddFlags(t *testing.T) {
 				"topDir",
 				"trackFilter",
 				"extensions",
+				"metadataPriority",
+				"compilations",
+				"layout",
+				"trackNames",
+				"metadataCache",
+				"concurrency",
+				"ignore",
+				"genreFilter", "tagArtistFilter", "tagAlbumFilter", "tagTitleFilter",
+				"yearRange", "bitrateRange", "durationRange", "id3Versions",
+				"where",
 			},
 		},
 		"good details without searches": {
@@ -729,6 +746,16 @@ func TestAddFlags(t *testing.T) {
 				"topDir",
 				"trackFilter",
 				"extensions",
+				"metadataPriority",
+				"compilations",
+				"layout",
+				"trackNames",
+				"metadataCache",
+				"concurrency",
+				"ignore",
+				"genreFilter", "tagArtistFilter", "tagAlbumFilter", "tagTitleFilter",
+				"yearRange", "bitrateRange", "durationRange", "id3Versions",
+				"where",
 			},
 			WantedRecording: output.WantedRecording{
 				Error: "An internal error occurred: the type of flag \"myBadFlag\"'s value," +
@@ -886,7 +913,7 @@ func TestAddFlags(t *testing.T) {
 				t.Errorf("AddFlags() got %d registered flags, expected %d", got,
 					len(tt.wantNames))
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("GetBool() %s", difference)
 				}
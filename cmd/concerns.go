@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"mp3/internal/files"
 	"slices"
+	"strings"
 
 	"github.com/majohn-r/output"
 )
@@ -16,13 +17,25 @@ const (
 	FilesConcern
 	NumberingConcern
 	ConflictConcern
+	IntegrityConcern
+	MissingTagConcern
+	RedundantTagConcern
+	NormalizationConcern
+	DuplicateConcern
+	NamesConcern
 )
 
 var concernNames = map[ConcernType]string{
-	EmptyConcern:     "empty",
-	FilesConcern:     "files",
-	NumberingConcern: "numbering",
-	ConflictConcern:  "metadata conflict",
+	EmptyConcern:         "empty",
+	FilesConcern:         "files",
+	NumberingConcern:     "numbering",
+	ConflictConcern:      "metadata conflict",
+	IntegrityConcern:     "integrity",
+	MissingTagConcern:    "missing tag",
+	RedundantTagConcern:  "redundant tag",
+	NormalizationConcern: "normalization",
+	DuplicateConcern:     "duplicate",
+	NamesConcern:         "names",
 }
 
 func ConcernName(i ConcernType) string {
@@ -218,7 +231,7 @@ func NewConcernedArtist(artist *files.Artist) *ConcernedArtist {
 func (cAr *ConcernedArtist) AddAlbum(album *files.Album) {
 	if cAl := NewConcernedAlbum(album); cAl != nil {
 		cAr.albums = append(cAr.albums, cAl)
-		cAr.albumMap[cAl.name()] = cAl
+		cAr.albumMap[album.Path()] = cAl
 	}
 }
 
@@ -246,8 +259,11 @@ func (cAr *ConcernedArtist) IsConcerned() bool {
 	return false
 }
 
+// Lookup finds the concerned track for the track; albums are matched by path,
+// as an artist found under several top directories may have two albums with
+// the same name
 func (cAr *ConcernedArtist) Lookup(track *files.Track) *ConcernedTrack {
-	albumKey := track.AlbumName()
+	albumKey := track.AlbumPath()
 	if cAl, ok := cAr.albumMap[albumKey]; ok {
 		return cAl.Lookup(track)
 	}
@@ -262,18 +278,12 @@ func (cAr *ConcernedArtist) ToConsole(o output.Bus) {
 	if cAr.IsConcerned() {
 		o.WriteConsole("Artist %q\n", cAr.name())
 		cAr.Concerns.ToConsole(o, 0)
-		m := map[string]*ConcernedAlbum{}
-		names := make([]string, 0, len(cAr.albums))
-		for _, cT := range cAr.albums {
-			albumName := cT.name()
-			m[albumName] = cT
-			names = append(names, albumName)
-		}
-		slices.Sort(names)
-		for _, name := range names {
-			if cAl := m[name]; cAl != nil {
-				cAl.ToConsole(o)
-			}
+		albums := slices.Clone(cAr.albums)
+		slices.SortStableFunc(albums, func(a, b *ConcernedAlbum) int {
+			return strings.Compare(a.name(), b.name())
+		})
+		for _, cAl := range albums {
+			cAl.ToConsole(o)
 		}
 	}
 }
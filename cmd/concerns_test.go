@@ -16,11 +16,16 @@ func TestConcernName(t *testing.T) {
 		i    cmd.ConcernType
 		want string
 	}{
-		"unspecified": {i: cmd.UnspecifiedConcern, want: "concern 0"},
-		"empty":       {i: cmd.EmptyConcern, want: "empty"},
-		"files":       {i: cmd.FilesConcern, want: "files"},
-		"numbering":   {i: cmd.NumberingConcern, want: "numbering"},
-		"metadata":    {i: cmd.ConflictConcern, want: "metadata conflict"},
+		"unspecified":   {i: cmd.UnspecifiedConcern, want: "concern 0"},
+		"empty":         {i: cmd.EmptyConcern, want: "empty"},
+		"files":         {i: cmd.FilesConcern, want: "files"},
+		"numbering":     {i: cmd.NumberingConcern, want: "numbering"},
+		"metadata":      {i: cmd.ConflictConcern, want: "metadata conflict"},
+		"integrity":     {i: cmd.IntegrityConcern, want: "integrity"},
+		"missing tag":   {i: cmd.MissingTagConcern, want: "missing tag"},
+		"redundant tag": {i: cmd.RedundantTagConcern, want: "redundant tag"},
+		"duplicate":     {i: cmd.DuplicateConcern, want: "duplicate"},
+		"names":         {i: cmd.NamesConcern, want: "names"},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,348 @@
+/*
+Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"mp3/internal/files"
+	"path/filepath"
+	"slices"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dedupeCommandName    = "dedupe"
+	dedupeQuarantine     = "quarantine"
+	dedupeQuarantineFlag = "--" + dedupeQuarantine
+)
+
+var (
+	// DedupeCmd represents the dedupe command
+	DedupeCmd = &cobra.Command{
+		Use: dedupeCommandName + " [" + dedupeQuarantineFlag + " dir] " +
+			searchUsage,
+		DisableFlagsInUseLine: true,
+		Short:                 "Finds track files that contain the same audio",
+		Long: "" +
+			fmt.Sprintf("%q finds track files that contain the same audio, regardless of"+
+				" their metadata\n", dedupeCommandName) +
+			"\n" +
+			"Each track file's audio is hashed without its ID3V1, ID3V2, APEv2, or Lyrics3\n" +
+			"tags (or, for FLAC, MP4, and Ogg files, without their metadata). Track files\n" +
+			"whose hashes match are reported together; the first of them, in artist, album,\n" +
+			"and track order, is kept, and each of the others is reported as either an\n" +
+			"identical copy or a copy that differs only in its tags.\n" +
+			"\n" +
+			"If " + dedupeQuarantineFlag + " is set, the other copies are moved into that" +
+			" directory, under\n" +
+			"their artist and album names. Each copy is copied into the quarantine directory\n" +
+			"before it is deleted, and a file that already exists in the quarantine\n" +
+			"directory is never overwritten.",
+		Example: "" +
+			dedupeCommandName + "\n" +
+			"  reports track files that contain the same audio\n" +
+			dedupeCommandName + " " + dedupeQuarantineFlag + " dir\n" +
+			"  reports track files that contain the same audio, and moves the extra copies\n" +
+			"  into dir",
+		RunE: DedupeRun,
+	}
+	DedupeFlags = NewSectionFlags().WithSectionName(dedupeCommandName).WithFlags(
+		map[string]*FlagDetails{
+			dedupeQuarantine: NewFlagDetails().WithUsage(
+				"directory into which extra copies are moved; if empty, nothing is moved",
+			).WithExpectedType(StringType).WithDefaultValue(""),
+		},
+	)
+)
+
+func DedupeRun(cmd *cobra.Command, _ []string) error {
+	exitError := NewExitProgrammingError(dedupeCommandName)
+	o := getBus()
+	producer := cmd.Flags()
+	values, eSlice := ReadFlags(producer, DedupeFlags)
+	searchSettings, searchFlagsOk := EvaluateSearchFlags(o, producer)
+	if ProcessFlagErrors(o, eSlice) && searchFlagsOk {
+		if ds, ok := ProcessDedupeFlags(o, values); ok {
+			details := map[string]any{dedupeQuarantineFlag: ds.quarantine}
+			for k, v := range searchSettings.Values() {
+				details[k] = v
+			}
+			LogCommandStart(o, dedupeCommandName, details)
+			ctx := commandContext(cmd)
+			allArtists, loaded := searchSettings.Load(ctx, o)
+			exitError = ds.ProcessArtists(ctx, o, allArtists, loaded, searchSettings)
+		}
+	}
+	return ToErrorInterface(exitError)
+}
+
+type DedupeSettings struct {
+	quarantine string
+}
+
+func NewDedupeSettings() *DedupeSettings {
+	return &DedupeSettings{}
+}
+
+func (ds *DedupeSettings) WithQuarantine(s string) *DedupeSettings {
+	ds.quarantine = s
+	return ds
+}
+
+func (ds *DedupeSettings) ProcessArtists(ctx context.Context, o output.Bus,
+	allArtists []*files.Artist, loaded bool, ss *SearchSettings) (e *ExitError) {
+	e = NewExitUserError(dedupeCommandName)
+	if loaded && ds.quarantineIsUsableEverywhere(o, ss.topDirectories) {
+		if filteredArtists, filtered := ss.Filter(o, allArtists); filtered {
+			groups := FindDuplicates(ctx, o, filteredArtists)
+			if ctx.Err() != nil {
+				o.WriteCanonicalError("The search for duplicated audio was interrupted" +
+					" before it was complete, so its results are not reported")
+				return NewExitInterruptedError(dedupeCommandName)
+			}
+			ReportDuplicates(o, groups)
+			e = ds.QuarantineDuplicates(ctx, o, groups)
+		}
+	}
+	return
+}
+
+// quarantineIsUsableEverywhere returns false if the quarantine directory is
+// inside any of the top directories
+func (ds *DedupeSettings) quarantineIsUsableEverywhere(o output.Bus, topDirs []string) bool {
+	usable := true
+	for _, topDir := range topDirs {
+		usable = ds.QuarantineIsUsable(o, topDir) && usable
+	}
+	return usable
+}
+
+// QuarantineIsUsable returns false if the quarantine directory is inside the
+// top directory, where its contents would be found, again, as duplicates
+func (ds *DedupeSettings) QuarantineIsUsable(o output.Bus, topDir string) bool {
+	if ds.quarantine == "" {
+		return true
+	}
+	if !isWithin(ds.quarantine, topDir) {
+		return true
+	}
+	o.WriteCanonicalError("The %s value, %q, cannot be used", dedupeQuarantineFlag,
+		ds.quarantine)
+	o.Log(output.Error, "invalid quarantine directory", map[string]any{
+		dedupeQuarantineFlag: ds.quarantine,
+		SearchTopDirFlag:     topDir,
+	})
+	o.WriteCanonicalError("Why?\nThe directory is inside the %s directory, %q, where"+
+		" the quarantined track files would be found again", SearchTopDirFlag, topDir)
+	o.WriteCanonicalError("What to do:\nSet %s to a directory outside of %q",
+		dedupeQuarantineFlag, topDir)
+	return false
+}
+
+// FindDuplicates returns groups of tracks that contain the same audio, in the
+// order in which the first track in each group was found. A track file that is
+// found more than once, as when the same top directory is specified by two
+// different paths, is only considered the first time, so that it can never be
+// reported as a duplicate of itself. The search stops, between tracks, when the
+// context is cancelled.
+func FindDuplicates(ctx context.Context, o output.Bus,
+	artists []*files.Artist) [][]*files.Track {
+	groups := map[string][]*files.Track{}
+	fingerprints := []string{}
+	seen := map[string]bool{}
+	for _, artist := range artists {
+		for _, album := range artist.Albums() {
+			for _, track := range album.Tracks() {
+				if ctx.Err() != nil {
+					break
+				}
+				if path, err := filepath.Abs(track.Path()); err == nil {
+					if seen[path] {
+						continue
+					}
+					seen[path] = true
+				}
+				fingerprint, err := AudioFingerprint(track.Path())
+				if err != nil {
+					o.WriteCanonicalError("The audio in track file %q cannot be read: %v",
+						track, err)
+					o.Log(output.Error, "cannot read audio", map[string]any{
+						"command": dedupeCommandName,
+						"file":    track.Path(),
+						"error":   err,
+					})
+					continue
+				}
+				group, found := groups[fingerprint]
+				if !found {
+					fingerprints = append(fingerprints, fingerprint)
+				}
+				if slices.ContainsFunc(group, func(other *files.Track) bool {
+					return sameFile(other.Path(), track.Path())
+				}) {
+					continue
+				}
+				groups[fingerprint] = append(group, track)
+			}
+		}
+	}
+	duplicates := [][]*files.Track{}
+	for _, fingerprint := range fingerprints {
+		if group := groups[fingerprint]; len(group) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+	return duplicates
+}
+
+// ReportDuplicates writes each group of duplicates to the console, describing
+// how each extra copy compares to the copy that is kept
+func ReportDuplicates(o output.Bus, groups [][]*files.Track) {
+	if len(groups) == 0 {
+		o.WriteCanonicalConsole("No duplicated audio was found.")
+		return
+	}
+	for _, group := range groups {
+		kept := group[0]
+		o.WriteConsole("%q has the same audio as:\n", kept)
+		for _, t := range group[1:] {
+			description := "differs only in tags"
+			if identical, err := IdenticalFiles(kept.Path(), t.Path()); err != nil {
+				description = fmt.Sprintf("cannot be compared: %v", err)
+			} else if identical {
+				description = "identical"
+			}
+			o.WriteConsole("  %q (%s)\n", t, description)
+		}
+	}
+}
+
+// QuarantineDuplicates moves every track but the first in each group into the
+// quarantine directory, if one was specified; it stops, between tracks, when
+// the context is cancelled
+func (ds *DedupeSettings) QuarantineDuplicates(ctx context.Context, o output.Bus,
+	groups [][]*files.Track) (e *ExitError) {
+	if ds.quarantine == "" {
+		return
+	}
+	moved, skipped := 0, 0
+	for _, group := range groups {
+		for _, t := range group[1:] {
+			if ctx.Err() != nil {
+				skipped++
+				continue
+			}
+			if QuarantineTrack(o, t, group[0], ds.quarantine) {
+				moved++
+			} else {
+				e = NewExitSystemError(dedupeCommandName)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		o.WriteCanonicalError("The dedupe command was interrupted: %d track files were"+
+			" moved to %q, and %d were not attempted", moved, ds.quarantine, skipped)
+		o.Log(output.Info, "dedupe interrupted", map[string]any{
+			"moved":   moved,
+			"skipped": skipped,
+			"command": dedupeCommandName,
+		})
+		return NewExitInterruptedError(dedupeCommandName)
+	}
+	o.WriteCanonicalConsole("Track files moved to %q: %d", ds.quarantine, moved)
+	return
+}
+
+// QuarantineTrack moves a track file into the quarantine directory, under its
+// artist and album names; as with the backups made by the repair command, an
+// existing file is never overwritten, and the track file is deleted only after
+// it has been copied, and then forgotten by the metadata cache. A track file
+// that is the same file as the kept copy is never moved, as that would leave no
+// copy behind.
+func QuarantineTrack(o output.Bus, t, kept *files.Track, quarantine string) (moved bool) {
+	dir := filepath.Join(quarantine, t.RecordingArtist(), t.AlbumName())
+	destination := filepath.Join(dir, t.FileName())
+	isKept := sameFile(t.Path(), kept.Path())
+	var dirErr error
+	if !isKept && !DirExists(dir) {
+		dirErr = MkdirAll(dir, cmd_toolkit.StdDirPermissions)
+	}
+	switch {
+	case isKept:
+		o.WriteCanonicalError("The track file %q is the same file as the copy that is"+
+			" kept, %q", t, kept)
+		o.Log(output.Error, "duplicate is the kept file", map[string]any{
+			"command": dedupeCommandName,
+			"file":    t.Path(),
+			"kept":    kept.Path(),
+		})
+	case dirErr != nil:
+		o.WriteCanonicalError("The directory %q cannot be created: %v", dir, dirErr)
+		o.Log(output.Error, "cannot create directory", map[string]any{
+			"command":   dedupeCommandName,
+			"directory": dir,
+			"error":     dirErr,
+		})
+	case PlainFileExists(destination):
+		o.WriteCanonicalError("The quarantine file for track file %q, %q, already exists",
+			t, destination)
+		o.Log(output.Error, "file already exists", map[string]any{
+			"command": dedupeCommandName,
+			"file":    destination,
+		})
+	default:
+		if err := CopyFile(t.Path(), destination); err != nil {
+			o.WriteCanonicalError(
+				"The track file %q could not be copied to %q due to error %v", t,
+				destination, err)
+			o.Log(output.Error, "error copying file", map[string]any{
+				"command":     dedupeCommandName,
+				"source":      t.Path(),
+				"destination": destination,
+				"error":       err,
+			})
+		} else if err = Remove(t.Path()); err != nil {
+			o.WriteCanonicalError(
+				"The track file %q has been copied to %q, but could not be deleted due"+
+					" to error %v", t, destination, err)
+			o.Log(output.Error, "cannot delete file", map[string]any{
+				"command": dedupeCommandName,
+				"file":    t.Path(),
+				"error":   err,
+			})
+		} else {
+			o.WriteCanonicalConsole("The track file %q has been moved to %q", t,
+				destination)
+			ForgetCachedFile(t.Path())
+			MarkDirty(o)
+			moved = true
+		}
+	}
+	if !moved {
+		o.WriteCanonicalError("The track file %q will not be moved", t)
+	}
+	return
+}
+
+func ProcessDedupeFlags(o output.Bus, values map[string]*FlagValue) (*DedupeSettings, bool) {
+	ds := &DedupeSettings{}
+	ok := true // optimistic
+	var err error
+	if ds.quarantine, _, err = GetString(o, values, dedupeQuarantine); err != nil {
+		ok = false
+	}
+	return ds, ok
+}
+
+func init() {
+	RootCmd.AddCommand(DedupeCmd)
+	addDefaults(DedupeFlags)
+	o := getBus()
+	c := getConfiguration()
+	AddFlags(o, c, DedupeCmd.Flags(), DedupeFlags, SearchFlags)
+}
@@ -0,0 +1,809 @@
+package cmd_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"mp3/cmd"
+	"mp3/internal/files"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+)
+
+func TestProcessDedupeFlags(t *testing.T) {
+	tests := map[string]struct {
+		values map[string]*cmd.FlagValue
+		want   *cmd.DedupeSettings
+		want1  bool
+		output.WantedRecording
+	}{
+		"bad value": {
+			values: map[string]*cmd.FlagValue{},
+			want:   cmd.NewDedupeSettings(),
+			want1:  false,
+			WantedRecording: output.WantedRecording{
+				Error: "An internal error occurred: flag \"quarantine\" is not found.\n",
+				Log: "" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='quarantine'" +
+					" msg='internal error'\n",
+			},
+		},
+		"good value": {
+			values: map[string]*cmd.FlagValue{
+				"quarantine": cmd.NewFlagValue().WithValue("dupes"),
+			},
+			want:  cmd.NewDedupeSettings().WithQuarantine("dupes"),
+			want1: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			got, got1 := cmd.ProcessDedupeFlags(o, tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProcessDedupeFlags() got = %v, want %v", got, tt.want)
+			}
+			if got1 != tt.want1 {
+				t.Errorf("ProcessDedupeFlags() got1 = %v, want %v", got1, tt.want1)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ProcessDedupeFlags() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeSettings_QuarantineIsUsable(t *testing.T) {
+	tests := map[string]struct {
+		ds     *cmd.DedupeSettings
+		topDir string
+		want   bool
+		output.WantedRecording
+	}{
+		"no quarantine": {ds: cmd.NewDedupeSettings(), topDir: "Music", want: true},
+		"outside": {
+			ds:     cmd.NewDedupeSettings().WithQuarantine("dupes"),
+			topDir: "Music",
+			want:   true,
+		},
+		"sibling with a similar name": {
+			ds:     cmd.NewDedupeSettings().WithQuarantine("Music dupes"),
+			topDir: "Music",
+			want:   true,
+		},
+		"inside": {
+			ds:     cmd.NewDedupeSettings().WithQuarantine(filepath.Join("Music", "dupes")),
+			topDir: "Music",
+			want:   false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The --quarantine value, \"Music\\\\dupes\", cannot be used.\n" +
+					"Why?\n" +
+					"The directory is inside the --topDir directory, \"Music\", where the" +
+					" quarantined track files would be found again.\n" +
+					"What to do:\n" +
+					"Set --quarantine to a directory outside of \"Music\".\n",
+				Log: "" +
+					"level='error'" +
+					" --quarantine='Music\\dupes'" +
+					" --topDir='Music'" +
+					" msg='invalid quarantine directory'\n",
+			},
+		},
+		"same": {
+			ds:     cmd.NewDedupeSettings().WithQuarantine("Music"),
+			topDir: "Music",
+			want:   false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The --quarantine value, \"Music\", cannot be used.\n" +
+					"Why?\n" +
+					"The directory is inside the --topDir directory, \"Music\", where the" +
+					" quarantined track files would be found again.\n" +
+					"What to do:\n" +
+					"Set --quarantine to a directory outside of \"Music\".\n",
+				Log: "" +
+					"level='error'" +
+					" --quarantine='Music'" +
+					" --topDir='Music'" +
+					" msg='invalid quarantine directory'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if got := tt.ds.QuarantineIsUsable(o, tt.topDir); got != tt.want {
+				t.Errorf("DedupeSettings.QuarantineIsUsable() = %v, want %v", got, tt.want)
+			}
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
+				for _, difference := range differences {
+					t.Errorf("DedupeSettings.QuarantineIsUsable() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeSettings_ProcessArtists(t *testing.T) {
+	originalAudioFingerprint := cmd.AudioFingerprint
+	defer func() {
+		cmd.AudioFingerprint = originalAudioFingerprint
+	}()
+	cmd.AudioFingerprint = func(path string) (string, error) {
+		return strings.Fields(filepath.Base(path))[0], nil
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ss := cmd.NewSearchSettings().WithArtistFilter(
+		regexp.MustCompile(".*")).WithAlbumFilter(
+		regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile(".*"))
+	tests := map[string]struct {
+		ctx        context.Context
+		artists    []*files.Artist
+		loaded     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"not loaded": {
+			ctx:        context.Background(),
+			wantStatus: cmd.NewExitUserError("dedupe"),
+		},
+		"no duplicates": {
+			ctx:     context.Background(),
+			artists: generateArtists(1, 1, 2),
+			loaded:  true,
+			WantedRecording: output.WantedRecording{
+				Console: "No duplicated audio was found.\n",
+			},
+		},
+		"interrupted": {
+			ctx:        cancelled,
+			artists:    generateArtists(2, 1, 2),
+			loaded:     true,
+			wantStatus: cmd.NewExitInterruptedError("dedupe"),
+			WantedRecording: output.WantedRecording{
+				Error: "The search for duplicated audio was interrupted before it was" +
+					" complete, so its results are not reported.\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if got := cmd.NewDedupeSettings().ProcessArtists(tt.ctx, o, tt.artists, tt.loaded,
+				ss); !compareExitErrors(got, tt.wantStatus) {
+				t.Errorf("DedupeSettings.ProcessArtists() got %s want %s", got, tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("DedupeSettings.ProcessArtists() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	originalAudioFingerprint := cmd.AudioFingerprint
+	defer func() {
+		cmd.AudioFingerprint = originalAudioFingerprint
+	}()
+	// tracks with the same track number have the same audio, except for the
+	// third track of the second artist, which cannot be read
+	cmd.AudioFingerprint = func(path string) (string, error) {
+		name := filepath.Base(path)
+		if name == "3 my track 103.mp3" {
+			return "", fmt.Errorf("file is locked")
+		}
+		return strings.Fields(name)[0], nil
+	}
+	artists := generateArtists(2, 1, 3)
+	// the first artist's album, found again through its absolute path, as when the
+	// same top directory is specified twice; its tracks must not be reported as
+	// duplicates of themselves
+	firstAlbum := artists[0].Albums()[0]
+	absolutePath, _ := filepath.Abs(firstAlbum.Path())
+	again := files.NewArtist(artists[0].Name(), filepath.Dir(absolutePath))
+	againAlbum := files.NewAlbum(firstAlbum.Name(), again, absolutePath)
+	for _, track := range firstAlbum.Tracks() {
+		againAlbum.AddTrack(files.NewTrack(againAlbum, track.FileName(), track.CommonName(),
+			track.Number()))
+	}
+	again.AddAlbum(againAlbum)
+	artists = append(artists, again)
+	o := output.NewRecorder()
+	groups := cmd.FindDuplicates(context.Background(), o, artists)
+	got := [][]string{}
+	for _, group := range groups {
+		names := []string{}
+		for _, track := range group {
+			names = append(names, track.FileName())
+		}
+		got = append(got, names)
+	}
+	want := [][]string{
+		{"1 my track 001.mp3", "1 my track 101.mp3"},
+		{"2 my track 002.mp3", "2 my track 102.mp3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindDuplicates() = %v, want %v", got, want)
+	}
+	wantRecording := output.WantedRecording{
+		Error: "" +
+			"The audio in track file" +
+			" \"Music\\\\my artist\\\\my album 10\\\\3 my track 103.mp3\" cannot be read:" +
+			" file is locked.\n",
+		Log: "" +
+			"level='error'" +
+			" command='dedupe'" +
+			" error='file is locked'" +
+			" file='Music\\my artist\\my album 10\\3 my track 103.mp3'" +
+			" msg='cannot read audio'\n",
+	}
+	if differences, ok := o.Verify(nativeRecording(wantRecording)); !ok {
+		for _, difference := range differences {
+			t.Errorf("FindDuplicates() %s", difference)
+		}
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if groups := cmd.FindDuplicates(cancelled, output.NewNilBus(), artists); len(groups) != 0 {
+		t.Errorf("FindDuplicates() with cancelled context = %v, want none", groups)
+	}
+}
+
+func TestReportDuplicates(t *testing.T) {
+	originalIdenticalFiles := cmd.IdenticalFiles
+	defer func() {
+		cmd.IdenticalFiles = originalIdenticalFiles
+	}()
+	cmd.IdenticalFiles = func(_, path2 string) (bool, error) {
+		switch filepath.Base(path2) {
+		case "2 my track 002.mp3":
+			return true, nil
+		case "3 my track 003.mp3":
+			return false, fmt.Errorf("file is locked")
+		default:
+			return false, nil
+		}
+	}
+	tracks := generateTracks(4)
+	tests := map[string]struct {
+		groups [][]*files.Track
+		output.WantedRecording
+	}{
+		"no duplicates": {
+			groups: nil,
+			WantedRecording: output.WantedRecording{
+				Console: "No duplicated audio was found.\n",
+			},
+		},
+		"duplicates": {
+			groups: [][]*files.Track{tracks},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"\"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" has the" +
+					" same audio as:\n" +
+					"  \"Music\\\\my artist\\\\my album 00\\\\2 my track 002.mp3\"" +
+					" (identical)\n" +
+					"  \"Music\\\\my artist\\\\my album 00\\\\3 my track 003.mp3\"" +
+					" (cannot be compared: file is locked)\n" +
+					"  \"Music\\\\my artist\\\\my album 00\\\\4 my track 004.mp3\"" +
+					" (differs only in tags)\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			cmd.ReportDuplicates(o, tt.groups)
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
+				for _, difference := range differences {
+					t.Errorf("ReportDuplicates() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestQuarantineTrack(t *testing.T) {
+	originalDirExists := cmd.DirExists
+	originalMkdirAll := cmd.MkdirAll
+	originalPlainFileExists := cmd.PlainFileExists
+	originalCopyFile := cmd.CopyFile
+	originalRemove := cmd.Remove
+	originalMarkDirty := cmd.MarkDirty
+	originalForgetCachedFile := cmd.ForgetCachedFile
+	defer func() {
+		cmd.DirExists = originalDirExists
+		cmd.MkdirAll = originalMkdirAll
+		cmd.PlainFileExists = originalPlainFileExists
+		cmd.CopyFile = originalCopyFile
+		cmd.Remove = originalRemove
+		cmd.MarkDirty = originalMarkDirty
+		cmd.ForgetCachedFile = originalForgetCachedFile
+	}()
+	var markedDirty bool
+	cmd.MarkDirty = func(_ output.Bus) {
+		markedDirty = true
+	}
+	var forgotten []string
+	cmd.ForgetCachedFile = func(path string) {
+		forgotten = append(forgotten, path)
+	}
+	tracks := generateTracks(2)
+	track := tracks[0]
+	tests := map[string]struct {
+		kept            *files.Track
+		dirExists       func(string) bool
+		mkdirAll        func(string, fs.FileMode) error
+		plainFileExists func(string) bool
+		copyFile        func(string, string) error
+		remove          func(string) error
+		wantMoved       bool
+		output.WantedRecording
+	}{
+		"same file as the kept copy": {
+			kept:            track,
+			dirExists:       func(_ string) bool { return true },
+			plainFileExists: func(_ string) bool { return false },
+			copyFile:        func(_, _ string) error { return nil },
+			remove:          func(_ string) error { return nil },
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" is the same" +
+					" file as the copy that is kept," +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\".\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" file='Music\\my artist\\my album 00\\1 my track 001.mp3'" +
+					" kept='Music\\my artist\\my album 00\\1 my track 001.mp3'" +
+					" msg='duplicate is the kept file'\n",
+			},
+		},
+		"cannot create directory": {
+			dirExists: func(_ string) bool { return false },
+			mkdirAll:  func(_ string, _ fs.FileMode) error { return fmt.Errorf("disk full") },
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The directory \"dupes\\\\my artist 0\\\\my album 00\" cannot be" +
+					" created: disk full.\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" directory='dupes\\my artist 0\\my album 00'" +
+					" error='disk full'" +
+					" msg='cannot create directory'\n",
+			},
+		},
+		"file already quarantined": {
+			dirExists:       func(_ string) bool { return true },
+			plainFileExists: func(_ string) bool { return true },
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The quarantine file for track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\"," +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\1 my track 001.mp3\"," +
+					" already exists.\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" file='dupes\\my artist 0\\my album 00\\1 my track 001.mp3'" +
+					" msg='file already exists'\n",
+			},
+		},
+		"copy fails": {
+			dirExists:       func(_ string) bool { return false },
+			mkdirAll:        func(_ string, _ fs.FileMode) error { return nil },
+			plainFileExists: func(_ string) bool { return false },
+			copyFile:        func(_, _ string) error { return fmt.Errorf("disk full") },
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" could not" +
+					" be copied to" +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\1 my track 001.mp3\" due to" +
+					" error disk full.\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" destination='dupes\\my artist 0\\my album 00\\1 my track 001.mp3'" +
+					" error='disk full'" +
+					" source='Music\\my artist\\my album 00\\1 my track 001.mp3'" +
+					" msg='error copying file'\n",
+			},
+		},
+		"delete fails": {
+			dirExists:       func(_ string) bool { return true },
+			plainFileExists: func(_ string) bool { return false },
+			copyFile:        func(_, _ string) error { return nil },
+			remove:          func(_ string) error { return fmt.Errorf("file is locked") },
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" has been" +
+					" copied to" +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\1 my track 001.mp3\", but" +
+					" could not be deleted due to error file is locked.\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" error='file is locked'" +
+					" file='Music\\my artist\\my album 00\\1 my track 001.mp3'" +
+					" msg='cannot delete file'\n",
+			},
+		},
+		"success": {
+			dirExists:       func(_ string) bool { return true },
+			plainFileExists: func(_ string) bool { return false },
+			copyFile:        func(_, _ string) error { return nil },
+			remove:          func(_ string) error { return nil },
+			wantMoved:       true,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\" has been" +
+					" moved to" +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\1 my track 001.mp3\".\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd.DirExists = tt.dirExists
+			cmd.MkdirAll = tt.mkdirAll
+			cmd.PlainFileExists = tt.plainFileExists
+			cmd.CopyFile = tt.copyFile
+			cmd.Remove = tt.remove
+			markedDirty = false
+			forgotten = nil
+			o := output.NewRecorder()
+			kept := tt.kept
+			if kept == nil {
+				kept = tracks[1]
+			}
+			if got := cmd.QuarantineTrack(o, track, kept, "dupes"); got != tt.wantMoved {
+				t.Errorf("QuarantineTrack() = %v, want %v", got, tt.wantMoved)
+			}
+			if markedDirty != tt.wantMoved {
+				t.Errorf("QuarantineTrack() marked dirty = %v, want %v", markedDirty,
+					tt.wantMoved)
+			}
+			var wantForgotten []string
+			if tt.wantMoved {
+				wantForgotten = []string{track.Path()}
+			}
+			if !reflect.DeepEqual(forgotten, wantForgotten) {
+				t.Errorf("QuarantineTrack() forgot %v, want %v", forgotten, wantForgotten)
+			}
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
+				for _, difference := range differences {
+					t.Errorf("QuarantineTrack() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeSettings_QuarantineDuplicates(t *testing.T) {
+	originalDirExists := cmd.DirExists
+	originalPlainFileExists := cmd.PlainFileExists
+	originalCopyFile := cmd.CopyFile
+	originalRemove := cmd.Remove
+	originalMarkDirty := cmd.MarkDirty
+	defer func() {
+		cmd.DirExists = originalDirExists
+		cmd.PlainFileExists = originalPlainFileExists
+		cmd.CopyFile = originalCopyFile
+		cmd.Remove = originalRemove
+		cmd.MarkDirty = originalMarkDirty
+	}()
+	cmd.DirExists = func(_ string) bool { return true }
+	cmd.PlainFileExists = func(path string) bool {
+		return filepath.Base(path) == "3 my track 003.mp3"
+	}
+	cmd.CopyFile = func(_, _ string) error { return nil }
+	cmd.Remove = func(_ string) error { return nil }
+	tracks := generateTracks(3)
+	// the first track to be moved is interrupted, but is still moved
+	interrupted, interrupt := context.WithCancel(context.Background())
+	tests := map[string]struct {
+		ctx        context.Context
+		markDirty  func(output.Bus)
+		ds         *cmd.DedupeSettings
+		groups     [][]*files.Track
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"interrupted": {
+			ctx:        interrupted,
+			markDirty:  func(_ output.Bus) { interrupt() },
+			ds:         cmd.NewDedupeSettings().WithQuarantine("dupes"),
+			groups:     [][]*files.Track{tracks},
+			wantStatus: cmd.NewExitInterruptedError("dedupe"),
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\2 my track 002.mp3\" has been" +
+					" moved to" +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\2 my track 002.mp3\".\n",
+				Error: "The dedupe command was interrupted: 1 track files were moved to" +
+					" \"dupes\", and 1 were not attempted.\n",
+				Log: "level='info'" +
+					" command='dedupe'" +
+					" moved='1'" +
+					" skipped='1'" +
+					" msg='dedupe interrupted'\n",
+			},
+		},
+		"no quarantine": {
+			ds:     cmd.NewDedupeSettings(),
+			groups: [][]*files.Track{tracks},
+		},
+		"quarantine": {
+			ds:         cmd.NewDedupeSettings().WithQuarantine("dupes"),
+			groups:     [][]*files.Track{tracks},
+			wantStatus: cmd.NewExitSystemError("dedupe"),
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\2 my track 002.mp3\" has been" +
+					" moved to" +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\2 my track 002.mp3\".\n" +
+					"Track files moved to \"dupes\": 1.\n",
+				Error: "" +
+					"The quarantine file for track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\3 my track 003.mp3\"," +
+					" \"dupes\\\\my artist 0\\\\my album 00\\\\3 my track 003.mp3\"," +
+					" already exists.\n" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\3 my track 003.mp3\" will not" +
+					" be moved.\n",
+				Log: "" +
+					"level='error'" +
+					" command='dedupe'" +
+					" file='dupes\\my artist 0\\my album 00\\3 my track 003.mp3'" +
+					" msg='file already exists'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			cmd.MarkDirty = func(_ output.Bus) {}
+			if tt.markDirty != nil {
+				cmd.MarkDirty = tt.markDirty
+			}
+			o := output.NewRecorder()
+			if got := tt.ds.QuarantineDuplicates(ctx, o, tt.groups); !compareExitErrors(got,
+				tt.wantStatus) {
+				t.Errorf("DedupeSettings.QuarantineDuplicates() got %s want %s", got,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
+				for _, difference := range differences {
+					t.Errorf("DedupeSettings.QuarantineDuplicates() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeRun(t *testing.T) {
+	cmd.InitGlobals()
+	originalBus := cmd.Bus
+	originalSince := cmd.Since
+	originalSearchFlags := cmd.SearchFlags
+	defer func() {
+		cmd.Bus = originalBus
+		cmd.Since = originalSince
+		cmd.SearchFlags = originalSearchFlags
+	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
+	cmd.SearchFlags = safeSearchFlags
+	dedupeFlags := cmd.NewSectionFlags().WithSectionName("dedupe").WithFlags(
+		map[string]*cmd.FlagDetails{
+			"quarantine": cmd.NewFlagDetails().WithUsage(
+				"directory into which extra copies are moved; if empty, nothing is" +
+					" moved").WithExpectedType(cmd.StringType).WithDefaultValue(""),
+		},
+	)
+	command := &cobra.Command{}
+	cmd.AddFlags(output.NewNilBus(), cmd_toolkit.EmptyConfiguration(), command.Flags(),
+		dedupeFlags, cmd.SearchFlags)
+	tests := map[string]struct {
+		cmd *cobra.Command
+		in1 []string
+		output.WantedRecording
+	}{
+		"basic": {
+			cmd: command,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"No music files could be found using the specified parameters.\n" +
+					"Why?\n" +
+					"There were no directories found in \".\" (the --topDir value).\n" +
+					"What to do:\n" +
+					"Set --topDir to the path of a directory that contains artist" +
+					" directories.\n",
+				Log: "" +
+					"level='info'" +
+					" --albumFilter='.*'" +
+					" --artistFilter='.*'" +
+					" --bitrateRange=''" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
+					" --durationRange=''" +
+					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --quarantine=''" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
+					" --topDir='.'" +
+					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
+					" --where=''" +
+					" --yearRange=''" +
+					" command='dedupe'" +
+					" msg='executing command'\n" +
+					"level='info'" +
+					" --topDir='.'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='error'" +
+					" --topDir='.'" +
+					" msg='cannot find any artist directories'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			cmd.Bus = o // cook getBus()
+			cmd.DedupeRun(tt.cmd, tt.in1)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("DedupeRun() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupeHelp(t *testing.T) {
+	originalSearchFlags := cmd.SearchFlags
+	defer func() {
+		cmd.SearchFlags = originalSearchFlags
+	}()
+	cmd.SearchFlags = safeSearchFlags
+	commandUnderTest := cloneCommand(cmd.DedupeCmd)
+	cmd.AddFlags(output.NewNilBus(), cmd_toolkit.EmptyConfiguration(),
+		commandUnderTest.Flags(), cmd.DedupeFlags, cmd.SearchFlags)
+	tests := map[string]struct {
+		output.WantedRecording
+	}{
+		"good": {
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"\"dedupe\" finds track files that contain the same audio, regardless of" +
+					" their metadata\n" +
+					"\n" +
+					"Each track file's audio is hashed without its ID3V1, ID3V2, APEv2, or" +
+					" Lyrics3\n" +
+					"tags (or, for FLAC, MP4, and Ogg files, without their metadata). Track" +
+					" files\n" +
+					"whose hashes match are reported together; the first of them, in artist," +
+					" album,\n" +
+					"and track order, is kept, and each of the others is reported as either" +
+					" an\n" +
+					"identical copy or a copy that differs only in its tags.\n" +
+					"\n" +
+					"If --quarantine is set, the other copies are moved into that directory," +
+					" under\n" +
+					"their artist and album names. Each copy is copied into the quarantine" +
+					" directory\n" +
+					"before it is deleted, and a file that already exists in the quarantine\n" +
+					"directory is never overwritten.\n" +
+					"\n" +
+					"Usage:\n" +
+					"  dedupe [--quarantine dir] [--albumFilter regex] [--artistFilter regex]" +
+					" [--trackFilter regex] [--topDir dir] [--extensions extensions]" +
+					" [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
+					"\n" +
+					"Examples:\n" +
+					"dedupe\n" +
+					"  reports track files that contain the same audio\n" +
+					"dedupe --quarantine dir\n" +
+					"  reports track files that contain the same audio, and moves the extra" +
+					" copies\n" +
+					"  into dir\n" +
+					"\n" +
+					"Flags:\n" +
+					"      --albumFilter string        " +
+					"regular expression specifying which albums to select (default \".*\")\n" +
+					"      --artistFilter string       " +
+					"regular expression specifying which artists to select (default \".*\")\n" +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"      --extensions string         " +
+					"comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   " +
+					"comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"      --quarantine string         " +
+					"directory into which extra copies are moved; if empty, nothing is moved (default \"\")\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             " +
+					"top directory, or list of top directories, specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        " +
+					"regular expression specifying which tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			command := commandUnderTest
+			enableCommandRecording(o, command)
+			command.Help()
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("dedupe Help() %s", difference)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+const (
+	// where the export command writes the configuration file
+	configurationFileDescription = "$APPDATA/mp3/defaults.yaml (if APPDATA is not" +
+		" set, $XDG_CONFIG_HOME or $HOME/.config is used in its place)"
+	xdgConfigHomeVar = "XDG_CONFIG_HOME"
+)
+
+var (
+	defaultTopDir = filepath.Join("$HOME", "Music")
+	// there is no media player service here; the default only needs to be
+	// resolvable, so that reading the flag defaults reports no errors
+	defaultMetadataDir = filepath.Join("$HOME", ".local", "share", "Media Player")
+)
+
+// initPlatformEnvironment fills in the Windows-style environment variables that
+// the application relies on, if they are not already defined: APPDATA (where
+// the configuration file lives) and TMP (where log files are written)
+func initPlatformEnvironment() {
+	if _, ok := LookupEnv(cmd_toolkit.ApplicationDataEnvVarName); !ok {
+		if dir, ok := LookupEnv(xdgConfigHomeVar); ok && dir != "" {
+			_ = Setenv(cmd_toolkit.ApplicationDataEnvVarName, dir)
+		} else if home, err := UserHomeDir(); err == nil {
+			_ = Setenv(cmd_toolkit.ApplicationDataEnvVarName, filepath.Join(home, ".config"))
+		}
+	}
+	_, tmpFound := LookupEnv("TMP")
+	_, tempFound := LookupEnv("TEMP")
+	if !tmpFound && !tempFound {
+		_ = Setenv("TMP", os.TempDir())
+	}
+}
@@ -0,0 +1,108 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+	"github.com/spf13/cobra"
+)
+
+func Test_initPlatformEnvironment(t *testing.T) {
+	originalLookupEnv := LookupEnv
+	originalSetenv := Setenv
+	originalUserHomeDir := UserHomeDir
+	defer func() {
+		LookupEnv = originalLookupEnv
+		Setenv = originalSetenv
+		UserHomeDir = originalUserHomeDir
+	}()
+	tests := map[string]struct {
+		env         map[string]string
+		userHomeDir func() (string, error)
+		want        map[string]string
+	}{
+		"all set": {
+			env: map[string]string{
+				"APPDATA": "/appdata",
+				"TMP":     "/tmp1",
+				"TEMP":    "/tmp2",
+			},
+			want: map[string]string{},
+		},
+		"only TEMP set": {
+			env: map[string]string{
+				"APPDATA": "/appdata",
+				"TEMP":    "/tmp2",
+			},
+			want: map[string]string{},
+		},
+		"XDG_CONFIG_HOME set": {
+			env: map[string]string{
+				"XDG_CONFIG_HOME": "/xdg",
+				"TMP":             "/tmp1",
+			},
+			want: map[string]string{"APPDATA": "/xdg"},
+		},
+		"home directory only": {
+			env:         map[string]string{"XDG_CONFIG_HOME": ""},
+			userHomeDir: func() (string, error) { return "/home/me", nil },
+			want: map[string]string{
+				"APPDATA": filepath.Join("/home/me", ".config"),
+				"TMP":     os.TempDir(),
+			},
+		},
+		"no home directory": {
+			env:         map[string]string{"TMP": "/tmp1"},
+			userHomeDir: func() (string, error) { return "", fmt.Errorf("no home") },
+			want:        map[string]string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := map[string]string{}
+			LookupEnv = func(key string) (string, bool) {
+				value, ok := tt.env[key]
+				return value, ok
+			}
+			Setenv = func(key, value string) error {
+				got[key] = value
+				return nil
+			}
+			UserHomeDir = tt.userHomeDir
+			initPlatformEnvironment()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("initPlatformEnvironment() set %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_flagDefaultsResolve(t *testing.T) {
+	// a Linux session defines HOME, but none of the Windows-only variables
+	t.Setenv("HOME", "/home/me")
+	for _, name := range []string{"USERPROFILE", "HOMEPATH"} {
+		t.Setenv(name, "")
+		_ = os.Unsetenv(name)
+	}
+	sections := []*SectionFlags{
+		CheckFlags, DedupeFlags, ExportFlags, ListFlags, RepairFlags,
+		ResetDatabaseFlags, SearchFlags,
+	}
+	for _, section := range sections {
+		t.Run(section.SectionName(), func(t *testing.T) {
+			o := output.NewRecorder()
+			command := &cobra.Command{}
+			AddFlags(o, cmd_toolkit.EmptyConfiguration(), command.Flags(), section)
+			if got := o.ErrorOutput(); got != "" {
+				t.Errorf("AddFlags() wrote %q to stderr, want nothing", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package cmd
+
+import (
+	"path/filepath"
+)
+
+const (
+	// where the export command writes the configuration file
+	configurationFileDescription = `%APPDATA%\mp3\defaults.yaml`
+)
+
+var (
+	defaultTopDir      = filepath.Join("%HOMEPATH%", "Music")
+	defaultMetadataDir = filepath.Join("%USERPROFILE%", "AppData", "Local", "Microsoft",
+		"Media Player")
+)
+
+// initPlatformEnvironment does nothing on Windows: the environment variables
+// the application relies on (APPDATA, TMP, TEMP) are always defined there
+func initPlatformEnvironment() {}
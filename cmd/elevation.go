@@ -5,7 +5,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"syscall"
 
 	"github.com/majohn-r/output"
 )
@@ -46,11 +45,6 @@ func stdoutState() bool {
 	return redirectedDescriptor(os.Stdout.Fd())
 }
 
-func processIsElevated() bool {
-	t := GetCurrentProcessToken()
-	return IsElevated(t)
-}
-
 type ElevationControl struct {
 	adminPermitted   bool
 	elevated         bool
@@ -62,7 +56,7 @@ type ElevationControl struct {
 func NewElevationControl() *ElevationControl {
 	return &ElevationControl{
 		adminPermitted:   environmentPermits(),
-		elevated:         processIsElevated(),
+		elevated:         ProcessIsElevated(),
 		stderrRedirected: stderrState(),
 		stdinRedirected:  stdinState(),
 		stdoutRedirected: stdoutState(),
@@ -83,19 +77,6 @@ func (ec *ElevationControl) canElevate() bool {
 	return ec.adminPermitted // ok, obey the environment variable then
 }
 
-func (ec *ElevationControl) ConfigureExit() {
-	if ec.elevated {
-		originalExit := Exit
-		Exit = func(code int) {
-			fmt.Printf("Exiting with exit code %d\n", code)
-			var name string
-			fmt.Printf("Press enter to close the window...\n")
-			Scanf("%s", &name)
-			originalExit(code)
-		}
-	}
-}
-
 func mergeArguments(args []string) string {
 	merged := ""
 	if len(args) > 1 {
@@ -104,25 +85,9 @@ func mergeArguments(args []string) string {
 	return merged
 }
 
-// credit: https://gist.github.com/jerblack/d0eb182cc5a1c1d92d92a4c4fcc416c6
-
-func runElevated() {
-	verb := "runas"
-	exe, _ := os.Executable()
-	cwd, _ := os.Getwd()
-	args := mergeArguments(os.Args)
-	verbPtr, _ := syscall.UTF16PtrFromString(verb)
-	exePtr, _ := syscall.UTF16PtrFromString(exe)
-	cwdPtr, _ := syscall.UTF16PtrFromString(cwd)
-	argPtr, _ := syscall.UTF16PtrFromString(args)
-	var showCmd int32 = syscall.SW_NORMAL
-	ShellExecute(0, verbPtr, exePtr, argPtr, cwdPtr, showCmd)
-}
-
 func (ec *ElevationControl) WillRunElevated() bool {
 	if ec.canElevate() {
-		runElevated()
-		return true
+		return runElevated()
 	}
 	return false
 }
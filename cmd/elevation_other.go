@@ -0,0 +1,19 @@
+//go:build !windows
+
+package cmd
+
+// on platforms other than Windows, running elevated means running as root
+func processIsElevated() bool {
+	return Geteuid() == 0
+}
+
+// ConfigureExit does nothing: the process never runs in a window of its own, so
+// there is no window to keep open
+func (ec *ElevationControl) ConfigureExit() {}
+
+// runElevated does nothing and reports that the process was not relaunched:
+// there is no equivalent of the Windows "runas" verb, so the user must use sudo
+// (or an equivalent) explicitly
+func runElevated() bool {
+	return false
+}
@@ -0,0 +1,91 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"testing"
+)
+
+func Test_ProcessIsElevated(t *testing.T) {
+	originalGeteuid := Geteuid
+	defer func() {
+		Geteuid = originalGeteuid
+	}()
+	tests := map[string]struct {
+		euid int
+		want bool
+	}{
+		"user": {euid: 1000, want: false},
+		"root": {euid: 0, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			Geteuid = func() int { return tt.euid }
+			if got := processIsElevated(); got != tt.want {
+				t.Errorf("processIsElevated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElevationControl_ConfigureExit(t *testing.T) {
+	initExamples()
+	originalExit := Exit
+	originalScanf := Scanf
+	defer func() {
+		Exit = originalExit
+		Scanf = originalScanf
+	}()
+	var scanfCalled bool
+	Scanf = func(_ string, _ ...any) (int, error) {
+		scanfCalled = true
+		return 0, nil
+	}
+	var exitCalled bool
+	tests := map[string]struct {
+		ec *ElevationControl
+	}{
+		"not elevated": {ec: ec000},
+		"elevated":     {ec: ec010},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			exitCalled = false
+			scanfCalled = false
+			Exit = func(_ int) {
+				exitCalled = true
+			}
+			tt.ec.ConfigureExit()
+			Exit(0)
+			if !exitCalled {
+				t.Errorf("ElevationControl.ConfigureExit exit not called")
+			}
+			if scanfCalled {
+				t.Errorf("ElevationControl.ConfigureExit scanf called")
+			}
+		})
+	}
+}
+
+func TestElevationControl_WillRunElevated(t *testing.T) {
+	initExamples()
+	tests := map[string]struct {
+		ec *ElevationControl
+	}{
+		"000": {ec: ec000},
+		"001": {ec: ec001},
+		"010": {ec: ec010},
+		"011": {ec: ec011},
+		"100": {ec: ec100},
+		"101": {ec: ec101},
+		"110": {ec: ec110},
+		"111": {ec: ec111},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.ec.WillRunElevated(); got {
+				t.Errorf("ElevationControl.WillRunElevated() = %t, want false", got)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"testing"
 
 	"github.com/majohn-r/output"
-	"golang.org/x/sys/windows"
 )
 
 func Test_EnvironmentPermits(t *testing.T) {
@@ -51,32 +50,6 @@ func Test_EnvironmentPermits(t *testing.T) {
 	}
 }
 
-func Test_ProcessIsElevated(t *testing.T) {
-	originalGetCurrentProcessToken := GetCurrentProcessToken
-	originalIsElevatedFunc := IsElevated
-	defer func() {
-		GetCurrentProcessToken = originalGetCurrentProcessToken
-		IsElevated = originalIsElevatedFunc
-	}()
-	GetCurrentProcessToken = func() (t windows.Token) {
-		return
-	}
-	tests := map[string]struct {
-		want bool
-	}{
-		"no":  {want: false},
-		"yes": {want: true},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			IsElevated = func(_ windows.Token) bool { return tt.want }
-			if got := processIsElevated(); got != tt.want {
-				t.Errorf("processIsElevated() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 var (
 	ec000               *ElevationControl
 	ec001               *ElevationControl
@@ -199,58 +172,6 @@ func TestElevationGoverner_CanElevate(t *testing.T) {
 	}
 }
 
-func TestElevationControl_ConfigureExit(t *testing.T) {
-	initExamples()
-	originalExit := Exit
-	originalScanf := Scanf
-	defer func() {
-		Exit = originalExit
-		Scanf = originalScanf
-	}()
-	var scanfCalled bool
-	Scanf = func(_ string, _ ...any) (int, error) {
-		scanfCalled = true
-		return 0, nil
-	}
-	var exitCalled bool
-	Exit = func(_ int) {
-		exitCalled = true
-	}
-	tests := map[string]struct {
-		ec              *ElevationControl
-		wantExitCalled  bool
-		wantScanfCalled bool
-	}{
-		"not elevated": {
-			ec:              ec000,
-			wantExitCalled:  true,
-			wantScanfCalled: false,
-		},
-		"elevated": {
-			ec:              ec010,
-			wantExitCalled:  true,
-			wantScanfCalled: true,
-		},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			exitCalled = false
-			scanfCalled = false
-			Exit = func(_ int) {
-				exitCalled = true
-			}
-			tt.ec.ConfigureExit()
-			Exit(0)
-			if got := exitCalled; got != tt.wantExitCalled {
-				t.Errorf("ElevationControl.ConfigureExit exit called %t, want %t", got, tt.wantExitCalled)
-			}
-			if got := scanfCalled; got != tt.wantScanfCalled {
-				t.Errorf("ElevationControl.ConfigureExit scanf called %t, want %t", got, tt.wantScanfCalled)
-			}
-		})
-	}
-}
-
 func Test_mergeArguments(t *testing.T) {
 	tests := map[string]struct {
 		args []string
@@ -286,63 +207,6 @@ func Test_mergeArguments(t *testing.T) {
 	}
 }
 
-func Test_RunElevated(t *testing.T) {
-	originalShellExecute := ShellExecute
-	defer func() {
-		ShellExecute = originalShellExecute
-	}()
-	var executed bool
-	ShellExecute = func(_ windows.Handle, _, _, _, _ *uint16, _ int32) error {
-		executed = true
-		return nil
-	}
-	tests := map[string]struct {
-		wantExecuted bool
-	}{
-		"expected": {wantExecuted: true},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			executed = false
-			runElevated()
-			if got := executed; got != tt.wantExecuted {
-				t.Errorf("runElevated() got %t want %t", got, tt.wantExecuted)
-			}
-		})
-	}
-}
-
-func TestElevationControl_WillRunElevated(t *testing.T) {
-	initExamples()
-	originalShellExecute := ShellExecute
-	defer func() {
-		ShellExecute = originalShellExecute
-	}()
-	ShellExecute = func(_ windows.Handle, _, _, _, _ *uint16, _ int32) error {
-		return nil
-	}
-	tests := map[string]struct {
-		ec   *ElevationControl
-		want bool
-	}{
-		"000": {ec: ec000, want: false},
-		"001": {ec: ec001, want: false},
-		"010": {ec: ec010, want: false},
-		"011": {ec: ec011, want: false},
-		"100": {ec: ec100, want: true},
-		"101": {ec: ec101, want: false},
-		"110": {ec: ec110, want: false},
-		"111": {ec: ec111, want: false},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			if got := tt.ec.WillRunElevated(); got != tt.want {
-				t.Errorf("ElevationControl.WillRunElevated() = %t, want %t", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestElevationControl_Status(t *testing.T) {
 	initExamples()
 	tests := map[string]struct {
@@ -0,0 +1,45 @@
+//go:build windows
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func processIsElevated() bool {
+	t := GetCurrentProcessToken()
+	return IsElevated(t)
+}
+
+// ConfigureExit keeps the window opened by an elevated run visible until the
+// user has had a chance to read its output
+func (ec *ElevationControl) ConfigureExit() {
+	if ec.elevated {
+		originalExit := Exit
+		Exit = func(code int) {
+			fmt.Printf("Exiting with exit code %d\n", code)
+			var name string
+			fmt.Printf("Press enter to close the window...\n")
+			Scanf("%s", &name)
+			originalExit(code)
+		}
+	}
+}
+
+// credit: https://gist.github.com/jerblack/d0eb182cc5a1c1d92d92a4c4fcc416c6
+
+func runElevated() bool {
+	verb := "runas"
+	exe, _ := os.Executable()
+	cwd, _ := os.Getwd()
+	args := mergeArguments(os.Args)
+	verbPtr, _ := syscall.UTF16PtrFromString(verb)
+	exePtr, _ := syscall.UTF16PtrFromString(exe)
+	cwdPtr, _ := syscall.UTF16PtrFromString(cwd)
+	argPtr, _ := syscall.UTF16PtrFromString(args)
+	var showCmd int32 = syscall.SW_NORMAL
+	ShellExecute(0, verbPtr, exePtr, argPtr, cwdPtr, showCmd)
+	return true
+}
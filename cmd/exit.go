@@ -12,6 +12,7 @@ const (
 	userError              // user did something silly
 	programError           // program code error
 	systemError            // unexpected errors, like file not found
+	interrupted            // user interrupted the command, as with Ctrl-C
 )
 
 var (
@@ -19,6 +20,7 @@ var (
 		userError:    "user error",
 		programError: "programming error",
 		systemError:  "system call failed",
+		interrupted:  "interrupted by the user",
 	}
 )
 
@@ -39,6 +41,10 @@ func NewExitSystemError(cmd string) *ExitError {
 	return &ExitError{command: cmd, errorCode: systemError}
 }
 
+func NewExitInterruptedError(cmd string) *ExitError {
+	return &ExitError{command: cmd, errorCode: interrupted}
+}
+
 func (e *ExitError) Error() string {
 	return fmt.Sprintf("command %q terminated with an error: %s", e.command, strStatusMap[e.errorCode])
 }
@@ -62,6 +62,25 @@ func TestNewExitProgrammingError(t *testing.T) {
 	}
 }
 
+func TestNewExitInterruptedError(t *testing.T) {
+	tests := map[string]struct {
+		cmd  string
+		want *ExitError
+	}{
+		"typical": {
+			cmd:  "someCommand",
+			want: &ExitError{command: "someCommand", errorCode: interrupted},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := NewExitInterruptedError(tt.cmd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewExitInterruptedError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExitError_Error(t *testing.T) {
 	tests := map[string]struct {
 		e    *ExitError
@@ -79,6 +98,10 @@ func TestExitError_Error(t *testing.T) {
 			e:    NewExitSystemError("command3"),
 			want: `command "command3" terminated with an error: system call failed`,
 		},
+		"interrupted": {
+			e:    NewExitInterruptedError("command4"),
+			want: `command "command4" terminated with an error: interrupted by the user`,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -97,6 +120,7 @@ func TestExitError_status(t *testing.T) {
 		"user error":        {e: NewExitUserError("command1"), want: 1},
 		"programming error": {e: NewExitProgrammingError("command2"), want: 2},
 		"system error":      {e: NewExitSystemError("command3"), want: 3},
+		"interrupted":       {e: NewExitInterruptedError("command4"), want: 4},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
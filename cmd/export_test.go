@@ -423,7 +423,7 @@ func TestExportFlagSettingsExportDefaultConfiguration(t *testing.T) {
 				t.Errorf("ExportFlagSettings.ExportDefaultConfiguration() got %s want %s",
 					got, tt.wantStatus)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ExportFlagSettings.ExportDefaultConfiguration() %s", difference)
 				}
@@ -628,8 +628,8 @@ func TestExportHelp(t *testing.T) {
 		"good": {
 			WantedRecording: output.WantedRecording{
 				Console: "" +
-					"\"export\" exports default program configuration data to" +
-					" %APPDATA%\\mp3\\defaults.yaml\n" +
+					"\"export\" exports default program configuration data to " +
+					configurationFileDescription + "\n" +
 					"\n" +
 					"Usage:\n" +
 					"  mp3 export [--defaults] [--overwrite]\n" +
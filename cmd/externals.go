@@ -6,54 +6,58 @@ import (
 	"fmt"
 	"mp3/internal/files"
 	"os"
+	"os/signal"
 	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
 	"github.com/mattn/go-isatty"
-	"golang.org/x/sys/windows"
-	"golang.org/x/sys/windows/svc/mgr"
 )
 
 var (
-	ApplicationPath        = cmd_toolkit.ApplicationPath
-	AppName                = cmd_toolkit.AppName
-	BuildDependencies      = cmd_toolkit.BuildDependencies
-	CopyFile               = cmd_toolkit.CopyFile
-	DereferenceEnvVar      = cmd_toolkit.DereferenceEnvVar
-	DirExists              = cmd_toolkit.DirExists
-	GenerateAboutContent   = cmd_toolkit.GenerateAboutContent
-	GoVersion              = cmd_toolkit.GoVersion
-	InitApplicationPath    = cmd_toolkit.InitApplicationPath
-	InitBuildData          = cmd_toolkit.InitBuildData
-	InitLogging            = cmd_toolkit.InitLogging
-	InterpretBuildData     = cmd_toolkit.InterpretBuildData
-	LogCommandStart        = cmd_toolkit.LogCommandStart
-	LogPath                = cmd_toolkit.LogPath
-	Mkdir                  = cmd_toolkit.Mkdir
-	PlainFileExists        = cmd_toolkit.PlainFileExists
-	ReadConfigurationFile  = cmd_toolkit.ReadConfigurationFile
-	ReadDirectory          = cmd_toolkit.ReadDirectory
-	SetAppName             = cmd_toolkit.SetAppName
-	SetFirstYear           = cmd_toolkit.SetFirstYear
-	SetFlagIndicator       = cmd_toolkit.SetFlagIndicator
-	ClearDirty             = files.ClearDirty
-	Dirty                  = files.Dirty
-	MarkDirty              = files.MarkDirty
-	ReadMetadata           = files.ReadMetadata
-	Scanf                  = fmt.Scanf
-	IsCygwinTerminal       = isatty.IsCygwinTerminal
-	IsTerminal             = isatty.IsTerminal
-	Connect                = mgr.Connect
-	Exit                   = os.Exit
-	LookupEnv              = os.LookupEnv
-	Rename                 = os.Rename
-	Remove                 = os.Remove
-	RemoveAll              = os.RemoveAll
-	WriteFile              = os.WriteFile
-	NewDefaultBus          = output.NewDefaultBus
-	Since                  = time.Since
-	GetCurrentProcessToken = windows.GetCurrentProcessToken
-	ShellExecute           = windows.ShellExecute
-	IsElevated             = windows.Token.IsElevated
+	ApplicationPath       = cmd_toolkit.ApplicationPath
+	AudioFingerprint      = files.AudioFingerprint
+	AppName               = cmd_toolkit.AppName
+	BuildDependencies     = cmd_toolkit.BuildDependencies
+	CopyFile              = cmd_toolkit.CopyFile
+	DereferenceEnvVar     = cmd_toolkit.DereferenceEnvVar
+	DirExists             = cmd_toolkit.DirExists
+	GenerateAboutContent  = cmd_toolkit.GenerateAboutContent
+	GoVersion             = cmd_toolkit.GoVersion
+	IdenticalFiles        = files.IdenticalFiles
+	LoadArtistMetadata    = files.LoadArtistMetadata
+	InitApplicationPath   = cmd_toolkit.InitApplicationPath
+	InitBuildData         = cmd_toolkit.InitBuildData
+	InitLogging           = cmd_toolkit.InitLogging
+	InterpretBuildData    = cmd_toolkit.InterpretBuildData
+	LogCommandStart       = cmd_toolkit.LogCommandStart
+	LogPath               = cmd_toolkit.LogPath
+	Mkdir                 = cmd_toolkit.Mkdir
+	PlainFileExists       = cmd_toolkit.PlainFileExists
+	ReadConfigurationFile = cmd_toolkit.ReadConfigurationFile
+	ReadDirectory         = cmd_toolkit.ReadDirectory
+	ReadFile              = os.ReadFile
+	SetAppName            = cmd_toolkit.SetAppName
+	SetFirstYear          = cmd_toolkit.SetFirstYear
+	SetFlagIndicator      = cmd_toolkit.SetFlagIndicator
+	ClearDirty            = files.ClearDirty
+	Dirty                 = files.Dirty
+	ForgetCachedFile      = files.ForgetCachedFile
+	MarkDirty             = files.MarkDirty
+	ReadMetadata          = files.ReadMetadata
+	SaveMetadataCache     = files.SaveMetadataCache
+	Scanf                 = fmt.Scanf
+	IsCygwinTerminal      = isatty.IsCygwinTerminal
+	IsTerminal            = isatty.IsTerminal
+	Exit                  = os.Exit
+	LookupEnv             = os.LookupEnv
+	MkdirAll              = os.MkdirAll
+	NotifyContext         = signal.NotifyContext
+	Rename                = os.Rename
+	Remove                = os.Remove
+	RemoveAll             = os.RemoveAll
+	WriteFile             = os.WriteFile
+	NewDefaultBus         = output.NewDefaultBus
+	Since                 = time.Since
+	ProcessIsElevated     = processIsElevated
 )
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package cmd
+
+// this file contains variables used to access external functions on platforms
+// other than Windows, allowing test code to easily override them
+import (
+	"os"
+)
+
+var (
+	Geteuid     = os.Geteuid
+	Setenv      = os.Setenv
+	UserHomeDir = os.UserHomeDir
+)
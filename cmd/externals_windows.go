@@ -0,0 +1,17 @@
+//go:build windows
+
+package cmd
+
+// this file contains variables used to access external functions that only
+// exist on Windows, allowing test code to easily override them
+import (
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var (
+	Connect                = mgr.Connect
+	GetCurrentProcessToken = windows.GetCurrentProcessToken
+	ShellExecute           = windows.ShellExecute
+	IsElevated             = windows.Token.IsElevated
+)
@@ -6,31 +6,37 @@ package cmd
 import (
 	"fmt"
 	"mp3/internal/files"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/majohn-r/output"
 	"github.com/spf13/cobra"
 )
 
 const (
-	ListAlbums           = "albums"
-	ListAlbumsFlag       = "--" + ListAlbums
-	ListAnnotate         = "annotate"
-	ListAnnotateFlag     = "--" + ListAnnotate
-	ListArtists          = "artists"
-	ListArtistsFlag      = "--" + ListArtists
-	ListCommand          = "list"
-	ListDetails          = "details"
-	ListDetailsFlag      = "--" + ListDetails
-	ListDiagnostic       = "diagnostic"
-	ListDiagnosticFlag   = "--" + ListDiagnostic
-	ListSortByNumber     = "byNumber"
-	ListSortByNumberFlag = "--" + ListSortByNumber
-	ListSortByTitle      = "byTitle"
-	ListSortByTitleFlag  = "--" + ListSortByTitle
-	ListTracks           = "tracks"
-	ListTracksFlag       = "--" + ListTracks
+	ListAlbums             = "albums"
+	ListAlbumsFlag         = "--" + ListAlbums
+	ListAnnotate           = "annotate"
+	ListAnnotateFlag       = "--" + ListAnnotate
+	ListArtists            = "artists"
+	ListArtistsFlag        = "--" + ListArtists
+	ListCommand            = "list"
+	ListDetails            = "details"
+	ListDetailsFlag        = "--" + ListDetails
+	ListDiagnostic         = "diagnostic"
+	ListDiagnosticFlag     = "--" + ListDiagnostic
+	ListIgnored            = "ignored"
+	ListIgnoredFlag        = "--" + ListIgnored
+	ListSortByDuration     = "byDuration"
+	ListSortByDurationFlag = "--" + ListSortByDuration
+	ListSortByNumber       = "byNumber"
+	ListSortByNumberFlag   = "--" + ListSortByNumber
+	ListSortByTitle        = "byTitle"
+	ListSortByTitleFlag    = "--" + ListSortByTitle
+	ListTracks             = "tracks"
+	ListTracksFlag         = "--" + ListTracks
 )
 
 var (
@@ -38,8 +44,9 @@ var (
 	ListCmd = &cobra.Command{
 		Use: ListCommand + " [" + ListAlbumsFlag + "] [" + ListArtistsFlag + "] " +
 			"[" + ListTracksFlag + "] [" + ListAnnotateFlag + "] [" + ListDetailsFlag + "] " +
-			"[" + ListDiagnosticFlag + "] [" + ListSortByNumberFlag + " | " +
-			ListSortByTitleFlag + "] " + searchUsage,
+			"[" + ListDiagnosticFlag + "] [" + ListIgnoredFlag + "] [" +
+			ListSortByNumberFlag + " | " + ListSortByTitleFlag + " | " +
+			ListSortByDurationFlag + "] " + searchUsage,
 		DisableFlagsInUseLine: true,
 		Short:                 "Lists mp3 files and containing album and artist directories",
 		Long: fmt.Sprintf(
@@ -49,17 +56,26 @@ var (
 			ListCommand + " " + ListDetailsFlag + "\n" +
 			"  Include detailed information, if available, for each track. This includes" +
 			" composer,\n" +
-			"  conductor, key, lyricist, orchestra/band, and subtitle\n" +
+			"  conductor, key, lyricist, orchestra/band, and subtitle; for mp3 files, it" +
+			" also\n" +
+			"  includes the duration, bitrate, sample rate, channel mode, VBR header, and" +
+			" encoder\n" +
 			ListCommand + " " + ListAlbumsFlag + "\n" +
 			"  Include the album names in the output\n" +
 			ListCommand + " " + ListArtistsFlag + "\n" +
 			"  Include the artist names in the output\n" +
 			ListCommand + " " + ListTracksFlag + "\n" +
 			"  Include the track names in the output\n" +
+			ListCommand + " " + ListIgnoredFlag + "\n" +
+			"  Include the files and directories skipped by ignore rules, and the rules" +
+			" that\n" +
+			"  skipped them\n" +
 			ListCommand + " " + ListSortByTitleFlag + "\n" +
 			"  Sort tracks by name, ignoring track numbers\n" +
 			ListCommand + " " + ListSortByNumberFlag + "\n" +
-			"  Sort tracks by track number",
+			"  Sort tracks by track number\n" +
+			ListCommand + " " + ListSortByDurationFlag + "\n" +
+			"  Sort tracks by duration, shortest first",
 		RunE: ListRun,
 	}
 	ListFlags = NewSectionFlags().WithSectionName(ListCommand).WithFlags(
@@ -79,6 +95,9 @@ var (
 			ListSortByTitle: NewFlagDetails().WithUsage(
 				"sort tracks by track title").WithExpectedType(BoolType).WithDefaultValue(
 				false),
+			ListSortByDuration: NewFlagDetails().WithUsage(
+				"sort tracks by duration").WithExpectedType(BoolType).WithDefaultValue(
+				false),
 			ListAnnotate: NewFlagDetails().WithUsage(
 				"annotate listings with album and artist names").WithExpectedType(
 				BoolType).WithDefaultValue(false),
@@ -88,6 +107,9 @@ var (
 			ListDiagnostic: NewFlagDetails().WithUsage(
 				"include diagnostic information with tracks").WithExpectedType(
 				BoolType).WithDefaultValue(false),
+			ListIgnored: NewFlagDetails().WithUsage(
+				"include files and directories skipped by ignore rules").WithExpectedType(
+				BoolType).WithDefaultValue(false),
 		},
 	)
 )
@@ -101,19 +123,22 @@ func ListRun(cmd *cobra.Command, _ []string) error {
 	if ProcessFlagErrors(o, eSlice) && searchFlagsOk {
 		if ls, ok := ProcessListFlags(o, values); ok {
 			details := map[string]any{
-				ListAlbumsFlag:       ls.albums,
-				"albums-user-set":    ls.albumsUserSet,
-				ListAnnotateFlag:     ls.annotate,
-				ListArtistsFlag:      ls.artists,
-				"artists-user-set":   ls.artistsUserSet,
-				ListSortByNumberFlag: ls.sortByNumber,
-				"byNumber-user-set":  ls.sortByNumberUserSet,
-				ListSortByTitleFlag:  ls.sortByTitle,
-				"byTitle-user-set":   ls.sortByTitleUserSet,
-				ListDetailsFlag:      ls.details,
-				ListDiagnosticFlag:   ls.diagnostic,
-				ListTracksFlag:       ls.tracks,
-				"tracks-user-set":    ls.tracksUserSet,
+				ListAlbumsFlag:         ls.albums,
+				"albums-user-set":      ls.albumsUserSet,
+				ListAnnotateFlag:       ls.annotate,
+				ListArtistsFlag:        ls.artists,
+				"artists-user-set":     ls.artistsUserSet,
+				ListSortByDurationFlag: ls.sortByDuration,
+				"byDuration-user-set":  ls.sortByDurationUserSet,
+				ListSortByNumberFlag:   ls.sortByNumber,
+				"byNumber-user-set":    ls.sortByNumberUserSet,
+				ListSortByTitleFlag:    ls.sortByTitle,
+				"byTitle-user-set":     ls.sortByTitleUserSet,
+				ListDetailsFlag:        ls.details,
+				ListDiagnosticFlag:     ls.diagnostic,
+				ListIgnoredFlag:        ls.ignored,
+				ListTracksFlag:         ls.tracks,
+				"tracks-user-set":      ls.tracksUserSet,
 			}
 			for k, v := range searchSettings.Values() {
 				details[k] = v
@@ -121,7 +146,7 @@ func ListRun(cmd *cobra.Command, _ []string) error {
 			LogCommandStart(o, ListCommand, details)
 			if ls.HasWorkToDo(o) {
 				if ls.TracksSortable(o) {
-					allArtists, loaded := searchSettings.Load(o)
+					allArtists, loaded := searchSettings.Load(commandContext(cmd), o)
 					exitError = ls.ProcessArtists(o, allArtists, loaded, searchSettings)
 				} else {
 					exitError = NewExitUserError(ListCommand)
@@ -135,19 +160,22 @@ func ListRun(cmd *cobra.Command, _ []string) error {
 }
 
 type ListSettings struct {
-	albums              bool
-	albumsUserSet       bool
-	annotate            bool
-	artists             bool
-	artistsUserSet      bool
-	details             bool
-	diagnostic          bool
-	sortByNumber        bool
-	sortByNumberUserSet bool
-	sortByTitle         bool
-	sortByTitleUserSet  bool
-	tracks              bool
-	tracksUserSet       bool
+	albums                bool
+	albumsUserSet         bool
+	annotate              bool
+	artists               bool
+	artistsUserSet        bool
+	details               bool
+	diagnostic            bool
+	ignored               bool
+	sortByDuration        bool
+	sortByDurationUserSet bool
+	sortByNumber          bool
+	sortByNumberUserSet   bool
+	sortByTitle           bool
+	sortByTitleUserSet    bool
+	tracks                bool
+	tracksUserSet         bool
 }
 
 func NewListSettings() *ListSettings {
@@ -189,6 +217,21 @@ func (ls *ListSettings) WithDiagnostic(b bool) *ListSettings {
 	return ls
 }
 
+func (ls *ListSettings) WithIgnored(b bool) *ListSettings {
+	ls.ignored = b
+	return ls
+}
+
+func (ls *ListSettings) WithSortByDuration(b bool) *ListSettings {
+	ls.sortByDuration = b
+	return ls
+}
+
+func (ls *ListSettings) WithSortByDurationUserSet(b bool) *ListSettings {
+	ls.sortByDurationUserSet = b
+	return ls
+}
+
 func (ls *ListSettings) WithSortByNumber(b bool) *ListSettings {
 	ls.sortByNumber = b
 	return ls
@@ -228,9 +271,28 @@ func (ls *ListSettings) ProcessArtists(o output.Bus, allArtists []*files.Artist,
 			err = nil
 		}
 	}
+	if ls.ignored {
+		ls.listIgnored(o, searchSettings.ignored)
+	}
 	return err
 }
 
+// listIgnored lists the files and directories that ignore rules kept out of the
+// search, along with the rule that kept each one out
+func (ls *ListSettings) listIgnored(o output.Bus, ignored []ignoredEntry) {
+	if len(ignored) == 0 {
+		o.WriteCanonicalConsole("No files or directories were ignored")
+		return
+	}
+	for _, entry := range ignored {
+		kind := "file"
+		if entry.isDir {
+			kind = "directory"
+		}
+		o.WriteConsole("Ignored %s: %s\n  by %s\n", kind, entry.path, entry.rule)
+	}
+}
+
 func (ls *ListSettings) ListArtists(o output.Bus, artists []*files.Artist) {
 	if ls.artists {
 		m := map[string]*files.Artist{}
@@ -284,6 +346,7 @@ func (ls *ListSettings) ListAlbums(o output.Bus, albums []*files.Album, tab int)
 		for _, album := range albums {
 			o.WriteConsole("%*sAlbum: %s\n", tab, "", ls.AnnotateAlbumName(album))
 			ls.ListTracks(o, album.Tracks(), tab+2)
+			ls.ListUnparsedTracks(o, []*files.Album{album}, tab+2)
 		}
 	} else {
 		trackCount := 0
@@ -295,6 +358,7 @@ func (ls *ListSettings) ListAlbums(o output.Bus, albums []*files.Album, tab int)
 			tracks = append(tracks, album.Tracks()...)
 		}
 		ls.ListTracks(o, tracks, tab)
+		ls.ListUnparsedTracks(o, albums, tab)
 	}
 }
 
@@ -312,6 +376,10 @@ func (ls *ListSettings) ListTracks(o output.Bus, tracks []*files.Track, tab int)
 	if !ls.tracks {
 		return
 	}
+	if ls.sortByDuration {
+		ls.ListTracksByDuration(o, tracks, tab)
+		return
+	}
 	if ls.sortByNumber {
 		ls.ListTracksByNumber(o, tracks, tab)
 		return
@@ -321,21 +389,25 @@ func (ls *ListSettings) ListTracks(o output.Bus, tracks []*files.Track, tab int)
 	}
 }
 
+// ListTracksByNumber lists the tracks in disc and track number order; the
+// tracks of a multi-disc album are prefixed with their disc numbers
 func (ls *ListSettings) ListTracksByNumber(o output.Bus, tracks []*files.Track, tab int) {
-	m := map[int]*files.Track{}
-	numbers := make([]int, 0, len(tracks))
-	for _, track := range tracks {
-		numbers = append(numbers, track.Number())
-		m[track.Number()] = track
-	}
-	sort.Ints(numbers)
-	for _, n := range numbers {
-		track := m[n]
-		if track != nil {
-			o.WriteConsole("%*s%2d. %s\n", tab, "", n, track.CommonName())
-			ls.ListTrackDetails(o, track, tab+2)
-			ls.ListTrackDiagnostics(o, track, tab+2)
+	sorted := slices.Clone(tracks)
+	slices.SortStableFunc(sorted, func(a, b *files.Track) int {
+		if a.Disc() != b.Disc() {
+			return a.Disc() - b.Disc()
+		}
+		return a.Number() - b.Number()
+	})
+	for _, track := range sorted {
+		if track.Disc() != 0 {
+			o.WriteConsole("%*s%d-%02d. %s\n", tab, "", track.Disc(), track.Number(),
+				track.CommonName())
+		} else {
+			o.WriteConsole("%*s%2d. %s\n", tab, "", track.Number(), track.CommonName())
 		}
+		ls.ListTrackDetails(o, track, tab+2)
+		ls.ListTrackDiagnostics(o, track, tab+2)
 	}
 }
 
@@ -372,6 +444,37 @@ func (ls *ListSettings) ListTracksByName(o output.Bus, tracks []*files.Track, ta
 	}
 }
 
+// ListTracksByDuration lists the tracks from shortest to longest; tracks whose
+// duration cannot be determined follow the rest, and tracks of equal duration
+// are listed by name
+func (ls *ListSettings) ListTracksByDuration(o output.Bus, tracks []*files.Track, tab int) {
+	sort.Sort(TrackSlice(tracks))
+	durations := map[*files.Track]time.Duration{}
+	for _, track := range tracks {
+		if ai, err := track.AudioInfo(); err == nil {
+			durations[track] = ai.Duration()
+		}
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		d1, ok1 := durations[tracks[i]]
+		d2, ok2 := durations[tracks[j]]
+		if ok1 && ok2 {
+			return d1 < d2
+		}
+		return ok1 && !ok2
+	})
+	for _, track := range tracks {
+		if d, ok := durations[track]; ok {
+			o.WriteConsole("%*s%s (%s)\n", tab, "", ls.AnnotateTrackName(track),
+				files.FormatDuration(d))
+		} else {
+			o.WriteConsole("%*s%s\n", tab, "", ls.AnnotateTrackName(track))
+		}
+		ls.ListTrackDetails(o, track, tab+2)
+		ls.ListTrackDiagnostics(o, track, tab+2)
+	}
+}
+
 func quote(s string) string {
 	return fmt.Sprintf("%q", s)
 }
@@ -388,6 +491,33 @@ func (ls *ListSettings) AnnotateTrackName(track *files.Track) string {
 	return strings.Join(trackNameParts, " ")
 }
 
+// ListUnparsedTracks lists the albums' track files whose names could not be
+// parsed, marked as such; lacking names and numbers to sort by, they follow the
+// other tracks
+func (ls *ListSettings) ListUnparsedTracks(o output.Bus, albums []*files.Album, tab int) {
+	if !ls.tracks {
+		return
+	}
+	for _, album := range albums {
+		for _, fileName := range album.UnparsedTracks() {
+			o.WriteConsole("%*s%s (unparsed)\n", tab, "",
+				ls.annotateUnparsedTrackName(album, fileName))
+		}
+	}
+}
+
+func (ls *ListSettings) annotateUnparsedTrackName(album *files.Album,
+	fileName string) string {
+	if !ls.annotate || ls.albums {
+		return fileName
+	}
+	trackNameParts := []string{quote(fileName), "on", quote(album.Name())}
+	if !ls.artists {
+		trackNameParts = append(trackNameParts, "by", quote(album.RecordingArtistName()))
+	}
+	return strings.Join(trackNameParts, " ")
+}
+
 func (ls *ListSettings) ListTrackDetails(o output.Bus, track *files.Track, tab int) {
 	if ls.details {
 		// go get information from track and display it
@@ -423,35 +553,24 @@ func ShowDetails(o output.Bus, track *files.Track, details map[string]string,
 
 func (ls *ListSettings) ListTrackDiagnostics(o output.Bus, track *files.Track, tab int) {
 	if ls.diagnostic {
-		version, encoding, frames, err := track.ID3V2Diagnostics()
-		ShowID3V2Diagnostics(o, track, version, encoding, frames, err, tab)
-		tags, err := track.ID3V1Diagnostics()
-		ShowID3V1Diagnostics(o, track, tags, err, tab)
-	}
-}
-
-// split out for testing!
-func ShowID3V1Diagnostics(o output.Bus, track *files.Track, tags []string, err error,
-	tab int) {
-	if err != nil {
-		track.ReportMetadataReadError(o, files.ID3V1, err.Error())
-	} else {
-		for _, s := range tags {
-			o.WriteConsole("%*sID3V1 %s\n", tab, "", s)
+		for _, src := range track.MetadataSources() {
+			lines, err := src.Diagnostics(track.Path())
+			ShowDiagnostics(o, track, src.Type(), lines, err, tab)
 		}
 	}
 }
 
 // split out for testing!
-func ShowID3V2Diagnostics(o output.Bus, track *files.Track, version byte, encoding string,
-	frames []string, err error, tab int) {
-	if err != nil {
-		track.ReportMetadataReadError(o, files.ID3V2, err.Error())
-	} else {
-		o.WriteConsole("%*sID3V2 Version: %v\n", tab, "", version)
-		o.WriteConsole("%*sID3V2 Encoding: %q\n", tab, "", encoding)
-		for _, frame := range frames {
-			o.WriteConsole("%*sID3V2 %s\n", tab, "", frame)
+func ShowDiagnostics(o output.Bus, track *files.Track, sT files.SourceType, lines []string,
+	err error, tab int) {
+	switch {
+	case files.IsMissingMetadata(sT, err):
+		// optional metadata, such as an APEv2 tag, is usually absent
+	case err != nil:
+		track.ReportMetadataReadError(o, sT, err.Error())
+	default:
+		for _, s := range lines {
+			o.WriteConsole("%*s%s %s\n", tab, "", sT, s)
 		}
 	}
 }
@@ -459,6 +578,28 @@ func ShowID3V2Diagnostics(o output.Bus, track *files.Track, version byte, encodi
 func (ls *ListSettings) TracksSortable(o output.Bus) bool {
 	bothSortingOptionsSet := ls.sortByNumber && ls.sortByTitle
 	neitherSortingOptionSet := !ls.sortByNumber && !ls.sortByTitle
+	if ls.tracks && ls.sortByDuration {
+		otherOptionExplicitlySet := (ls.sortByNumber && ls.sortByNumberUserSet) ||
+			(ls.sortByTitle && ls.sortByTitleUserSet)
+		switch {
+		case otherOptionExplicitlySet && ls.sortByDurationUserSet:
+			o.WriteCanonicalError("Track sorting cannot be done")
+			o.WriteCanonicalError("Why?")
+			o.WriteCanonicalError("You explicitly set %s true, and also %s or %s",
+				ListSortByDurationFlag, ListSortByNumberFlag, ListSortByTitleFlag)
+			o.WriteCanonicalError("What to do:\nSet only one of the sorting flags on the" +
+				" command line")
+			return false
+		case otherOptionExplicitlySet:
+			// the explicit choice overrides the configured sort by duration
+			ls.sortByDuration = false
+		default:
+			// sorting by duration overrides the other sorting options
+			ls.sortByNumber = false
+			ls.sortByTitle = false
+			return true
+		}
+	}
 	if ls.tracks {
 		switch {
 		case bothSortingOptionsSet:
@@ -533,12 +674,13 @@ func (ls *ListSettings) TracksSortable(o output.Bus) bool {
 			})
 		}
 	} else if (ls.sortByNumber && ls.sortByNumberUserSet) ||
-		(ls.sortByTitle && ls.sortByTitleUserSet) {
+		(ls.sortByTitle && ls.sortByTitleUserSet) ||
+		(ls.sortByDuration && ls.sortByDurationUserSet) {
 		o.WriteCanonicalError("Your sorting preferences are not relevant")
 		o.WriteCanonicalError("Why?")
 		o.WriteCanonicalError(
-			"Tracks are not included in the output, but you explicitly set %s or %s true.",
-			ListSortByNumberFlag, ListSortByTitleFlag)
+			"Tracks are not included in the output, but you explicitly set %s, %s, or %s"+
+				" true.", ListSortByNumberFlag, ListSortByTitleFlag, ListSortByDurationFlag)
 		o.WriteCanonicalError("What to do:\nEither set %s true or remove the sorting flags"+
 			" from the command line.", ListTracksFlag)
 		return false
@@ -547,7 +689,7 @@ func (ls *ListSettings) TracksSortable(o output.Bus) bool {
 }
 
 func (ls *ListSettings) HasWorkToDo(o output.Bus) bool {
-	if ls.albums || ls.artists || ls.tracks {
+	if ls.albums || ls.artists || ls.tracks || ls.ignored {
 		return true
 	}
 	userPartiallyAtFault := ls.albumsUserSet || ls.artistsUserSet || ls.tracksUserSet
@@ -610,6 +752,13 @@ func ProcessListFlags(o output.Bus, values map[string]*FlagValue) (*ListSettings
 	if settings.diagnostic, _, err = GetBool(o, values, ListDiagnostic); err != nil {
 		ok = false
 	}
+	if settings.ignored, _, err = GetBool(o, values, ListIgnored); err != nil {
+		ok = false
+	}
+	if settings.sortByDuration, settings.sortByDurationUserSet, err = GetBool(o, values,
+		ListSortByDuration); err != nil {
+		ok = false
+	}
 	if settings.sortByNumber, settings.sortByNumberUserSet, err = GetBool(o, values,
 		ListSortByNumber); err != nil {
 		ok = false
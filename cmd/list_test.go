@@ -4,14 +4,18 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"mp3/cmd"
 	"mp3/internal/files"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
 	"testing"
+	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
@@ -34,6 +38,8 @@ func TestProcessListFlags(t *testing.T) {
 					"An internal error occurred: flag \"artists\" is not found.\n" +
 					"An internal error occurred: flag \"details\" is not found.\n" +
 					"An internal error occurred: flag \"diagnostic\" is not found.\n" +
+					"An internal error occurred: flag \"ignored\" is not found.\n" +
+					"An internal error occurred: flag \"byDuration\" is not found.\n" +
 					"An internal error occurred: flag \"byNumber\" is not found.\n" +
 					"An internal error occurred: flag \"byTitle\" is not found.\n" +
 					"An internal error occurred: flag \"tracks\" is not found.\n",
@@ -59,6 +65,14 @@ func TestProcessListFlags(t *testing.T) {
 					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
+					" flag='ignored'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='byDuration'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
 					" flag='byNumber'" +
 					" msg='internal error'\n" +
 					"level='error'" +
@@ -78,13 +92,16 @@ func TestProcessListFlags(t *testing.T) {
 				"artists":    cmd.NewFlagValue().WithValue(true),
 				"details":    cmd.NewFlagValue().WithValue(true),
 				"diagnostic": cmd.NewFlagValue().WithValue(true),
+				"ignored":    cmd.NewFlagValue().WithValue(true),
+				"byDuration": cmd.NewFlagValue().WithValue(true),
 				"byNumber":   cmd.NewFlagValue().WithValue(true),
 				"byTitle":    cmd.NewFlagValue().WithValue(true),
 				"tracks":     cmd.NewFlagValue().WithValue(true),
 			},
 			want: cmd.NewListSettings().WithAlbums(true).WithAlbumsUserSet(
 				false).WithAnnotate(true).WithArtists(true).WithArtistsUserSet(
-				false).WithDetails(true).WithDiagnostic(true).WithSortByNumber(
+				false).WithDetails(true).WithDiagnostic(true).WithIgnored(true).WithSortByDuration(
+				true).WithSortByDurationUserSet(false).WithSortByNumber(
 				true).WithSortByNumberUserSet(false).WithSortByTitle(
 				true).WithSortByTitleUserSet(false).WithTracks(
 				true).WithTracksUserSet(false),
@@ -97,13 +114,16 @@ func TestProcessListFlags(t *testing.T) {
 				"artists":    cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 				"details":    cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 				"diagnostic": cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
+				"ignored":    cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
+				"byDuration": cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 				"byNumber":   cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 				"byTitle":    cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 				"tracks":     cmd.NewFlagValue().WithValue(false).WithExplicitlySet(true),
 			},
 			want: cmd.NewListSettings().WithAlbums(false).WithAlbumsUserSet(
 				true).WithAnnotate(false).WithArtists(false).WithArtistsUserSet(
-				true).WithDetails(false).WithDiagnostic(false).WithSortByNumber(
+				true).WithDetails(false).WithDiagnostic(false).WithIgnored(false).WithSortByDuration(
+				false).WithSortByDurationUserSet(true).WithSortByNumber(
 				false).WithSortByNumberUserSet(true).WithSortByTitle(
 				false).WithSortByTitleUserSet(true).WithTracks(false).WithTracksUserSet(
 				true),
@@ -494,7 +514,7 @@ func TestListSettingsTracksSortable(t *testing.T) {
 				Error: "Your sorting preferences are not relevant.\n" +
 					"Why?\n" +
 					"Tracks are not included in the output, but you explicitly set" +
-					" --byNumber or --byTitle true.\n" +
+					" --byNumber, --byTitle, or --byDuration true.\n" +
 					"What to do:\n" +
 					"Either set --tracks true or remove the sorting flags from the" +
 					" command line.\n",
@@ -507,7 +527,7 @@ func TestListSettingsTracksSortable(t *testing.T) {
 				Error: "Your sorting preferences are not relevant.\n" +
 					"Why?\n" +
 					"Tracks are not included in the output, but you explicitly set" +
-					" --byNumber or --byTitle true.\nWhat to do:\n" +
+					" --byNumber, --byTitle, or --byDuration true.\nWhat to do:\n" +
 					"Either set --tracks true or remove the sorting flags from the" +
 					" command line.\n",
 			},
@@ -520,7 +540,7 @@ func TestListSettingsTracksSortable(t *testing.T) {
 				Error: "Your sorting preferences are not relevant.\n" +
 					"Why?\n" +
 					"Tracks are not included in the output, but you explicitly set" +
-					" --byNumber or --byTitle true.\n" +
+					" --byNumber, --byTitle, or --byDuration true.\n" +
 					"What to do:\n" +
 					"Either set --tracks true or remove the sorting flags from the" +
 					" command line.\n",
@@ -533,6 +553,48 @@ func TestListSettingsTracksSortable(t *testing.T) {
 			lsFinal: cmd.NewListSettings().WithAlbums(true).WithTracks(
 				true).WithSortByNumber(true),
 		},
+		"tracks listed, sort by duration": {
+			ls: cmd.NewListSettings().WithTracks(true).WithSortByDuration(
+				true).WithSortByNumber(true),
+			want: true,
+			lsFinal: cmd.NewListSettings().WithTracks(true).WithSortByDuration(
+				true),
+		},
+		"tracks listed, configured sort by duration, explicit sort by title": {
+			ls: cmd.NewListSettings().WithTracks(true).WithSortByDuration(
+				true).WithSortByTitle(true).WithSortByTitleUserSet(true),
+			want: true,
+			lsFinal: cmd.NewListSettings().WithTracks(true).WithSortByTitle(
+				true).WithSortByTitleUserSet(true),
+		},
+		"tracks listed, explicit sort by duration and by title": {
+			ls: cmd.NewListSettings().WithTracks(true).WithSortByDuration(
+				true).WithSortByDurationUserSet(true).WithSortByTitle(
+				true).WithSortByTitleUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "Track sorting cannot be done.\n" +
+					"Why?\n" +
+					"You explicitly set --byDuration true, and also --byNumber or" +
+					" --byTitle.\n" +
+					"What to do:\n" +
+					"Set only one of the sorting flags on the command line.\n",
+			},
+		},
+		"tracks not listed, sort by duration explicitly called for": {
+			ls: cmd.NewListSettings().WithSortByDuration(
+				true).WithSortByDurationUserSet(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "Your sorting preferences are not relevant.\n" +
+					"Why?\n" +
+					"Tracks are not included in the output, but you explicitly set" +
+					" --byNumber, --byTitle, or --byDuration true.\n" +
+					"What to do:\n" +
+					"Either set --tracks true or remove the sorting flags from the" +
+					" command line.\n",
+			},
+		},
 		"tracks listed, just sort by title": {
 			ls:      cmd.NewListSettings().WithTracks(true).WithSortByTitle(true),
 			want:    true,
@@ -579,19 +641,81 @@ var (
 				"regular expression specifying which tracks to select").WithExpectedType(
 				cmd.StringType).WithDefaultValue(".*"),
 			cmd.SearchTopDir: cmd.NewFlagDetails().WithUsage(
-				"top directory specifying where to find mp3 files").WithExpectedType(
-				cmd.StringType).WithDefaultValue("."),
+				"top directory, or list of top directories, specifying where to find mp3" +
+					" files").WithExpectedType(cmd.StringType).WithDefaultValue("."),
 			cmd.SearchFileExtensions: cmd.NewFlagDetails().WithUsage(
 				"comma-delimited list of file extensions used by mp3" +
 					" files").WithExpectedType(cmd.StringType).WithDefaultValue(".mp3"),
+			cmd.SearchMetadataPriority: cmd.NewFlagDetails().WithUsage(
+				"comma-delimited list of metadata sources, in order of" +
+					" preference").WithExpectedType(cmd.StringType).WithDefaultValue(
+				"ID3V2,APEV2,ID3V1"),
+			cmd.SearchCompilations: cmd.NewFlagDetails().WithUsage(
+				"comma-delimited list of artist directory names that hold" +
+					" compilations").WithExpectedType(cmd.StringType).WithDefaultValue(
+				"Various Artists"),
+			cmd.SearchLayout: cmd.NewFlagDetails().WithUsage(
+				"template describing the directories below the top" +
+					" directory").WithExpectedType(cmd.StringType).WithDefaultValue(
+				"{artist}/{album}/{track} {title}"),
+			cmd.SearchConcurrency: cmd.NewFlagDetails().WithUsage(
+				"maximum number of track files read at the same time (minimum 1, maximum" +
+					" 100)").WithExpectedType(cmd.IntType).WithDefaultValue(
+				cmd_toolkit.NewIntBounds(1, 20, 100)),
+			cmd.SearchMetadataCache: cmd.NewFlagDetails().WithUsage(
+				"how to use the cache of track metadata: use, bypass," +
+					" rebuild").WithExpectedType(cmd.StringType).WithDefaultValue("use"),
+			cmd.SearchTrackNames: cmd.NewFlagDetails().WithUsage(
+				"newline-delimited list of regular expressions for parsing track file" +
+					" names; empty selects the built-in expressions").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchIgnore: cmd.NewFlagDetails().WithUsage(
+				"newline-delimited list of patterns, written as in .mp3ignore files, naming" +
+					" what to ignore below the top directory").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchGenreFilter: cmd.NewFlagDetails().WithUsage(
+				"regular expression specifying which genres, read from the track metadata," +
+					" to select; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchTagArtistFilter: cmd.NewFlagDetails().WithUsage(
+				"regular expression specifying which artist names, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchTagAlbumFilter: cmd.NewFlagDetails().WithUsage(
+				"regular expression specifying which album names, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchTagTitleFilter: cmd.NewFlagDetails().WithUsage(
+				"regular expression specifying which track titles, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchYearRange: cmd.NewFlagDetails().WithUsage(
+				"range of years, read from the track metadata, to select, such as" +
+					" 1970-1979, 1990-, or -1979; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchBitrateRange: cmd.NewFlagDetails().WithUsage(
+				"range of bitrates, in kbps, to select, such as 192-320; empty selects" +
+					" all").WithExpectedType(cmd.StringType).WithDefaultValue(""),
+			cmd.SearchDurationRange: cmd.NewFlagDetails().WithUsage(
+				"range of track durations to select, such as 2m-10m30s; empty selects" +
+					" all").WithExpectedType(cmd.StringType).WithDefaultValue(""),
+			cmd.SearchID3Versions: cmd.NewFlagDetails().WithUsage(
+				"comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects" +
+					" tracks without an ID3V2 tag; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
+			cmd.SearchWhere: cmd.NewFlagDetails().WithUsage(
+				"boolean expression specifying which tracks to select, such as 'year >= 1990" +
+					" and not hasConcern(numbering)'; empty selects all").WithExpectedType(
+				cmd.StringType).WithDefaultValue(""),
 		},
 	)
 )
 
-func TestShowID3V1Diagnostics(t *testing.T) {
+func TestShowDiagnostics(t *testing.T) {
 	type args struct {
 		track *files.Track
-		tags  []string
+		sT    files.SourceType
+		lines []string
 		err   error
 		tab   int
 	}
@@ -599,9 +723,10 @@ func TestShowID3V1Diagnostics(t *testing.T) {
 		args
 		output.WantedRecording
 	}{
-		"with error": {
+		"ID3V1 error": {
 			args: args{
 				track: sampleTrack,
+				sT:    files.ID3V1,
 				err:   fmt.Errorf("could not read track"),
 				tab:   2,
 			},
@@ -613,10 +738,11 @@ func TestShowID3V1Diagnostics(t *testing.T) {
 					" msg='metadata read error'\n",
 			},
 		},
-		"without error": {
+		"ID3V1 tags": {
 			args: args{
 				track: sampleTrack,
-				tags: []string{
+				sT:    files.ID3V1,
+				lines: []string{
 					"artist=my artist",
 					"album=my album",
 					"track=track 10",
@@ -632,37 +758,10 @@ func TestShowID3V1Diagnostics(t *testing.T) {
 					"  ID3V1 number=10\n",
 			},
 		},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			o := output.NewRecorder()
-			cmd.ShowID3V1Diagnostics(o, tt.args.track, tt.args.tags, tt.args.err,
-				tt.args.tab)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
-				for _, difference := range differences {
-					t.Errorf("ShowID3V1Diagnostics() %s", difference)
-				}
-			}
-		})
-	}
-}
-
-func TestShowID3V2Diagnostics(t *testing.T) {
-	type args struct {
-		track    *files.Track
-		version  byte
-		encoding string
-		frames   []string
-		err      error
-		tab      int
-	}
-	tests := map[string]struct {
-		args
-		output.WantedRecording
-	}{
-		"error": {
+		"ID3V2 error": {
 			args: args{
 				track: sampleTrack,
+				sT:    files.ID3V2,
 				err:   fmt.Errorf("no ID3V2 data found"),
 			},
 			WantedRecording: output.WantedRecording{
@@ -673,44 +772,102 @@ func TestShowID3V2Diagnostics(t *testing.T) {
 					" msg='metadata read error'\n",
 			},
 		},
-		"empty frames": {
+		"ID3V2 frames": {
 			args: args{
-				track:    sampleTrack,
-				version:  1,
-				encoding: "UTF-8",
-				tab:      2,
+				track: sampleTrack,
+				sT:    files.ID3V2,
+				lines: []string{"Version: 1", `Encoding: "UTF-8"`, "FRAME1", "FRAME2"},
+				tab:   2,
 			},
 			WantedRecording: output.WantedRecording{
 				Console: "" +
 					"  ID3V2 Version: 1\n" +
-					"  ID3V2 Encoding: \"UTF-8\"\n",
+					"  ID3V2 Encoding: \"UTF-8\"\n" +
+					"  ID3V2 FRAME1\n" +
+					"  ID3V2 FRAME2\n",
+			},
+		},
+		"no APEV2 tag": {
+			args: args{
+				track: sampleTrack,
+				sT:    files.APEV2,
+				err:   files.ErrNoApeV2Metadata,
+				tab:   2,
+			},
+		},
+		"APEV2 error": {
+			args: args{
+				track: sampleTrack,
+				sT:    files.APEV2,
+				err:   fmt.Errorf("could not read track"),
+				tab:   2,
+			},
+			WantedRecording: output.WantedRecording{
+				Log: "level='error'" +
+					" error='could not read track'" +
+					" metadata='APEV2'" +
+					" track='music\\my artist\\my album\\10 track 10.mp3'" +
+					" msg='metadata read error'\n",
 			},
 		},
-		"with frames": {
+		"APEV2 items": {
 			args: args{
-				track:    sampleTrack,
-				version:  1,
-				encoding: "UTF-8",
-				frames:   []string{"FRAME1", "FRAME2"},
-				tab:      2,
+				track: sampleTrack,
+				sT:    files.APEV2,
+				lines: []string{
+					`Album = "my album"`,
+					`Artist = "my artist"`,
+				},
+				tab: 2,
 			},
 			WantedRecording: output.WantedRecording{
 				Console: "" +
-					"  ID3V2 Version: 1\n" +
-					"  ID3V2 Encoding: \"UTF-8\"\n" +
-					"  ID3V2 FRAME1\n" +
-					"  ID3V2 FRAME2\n",
+					"  APEV2 Album = \"my album\"\n" +
+					"  APEV2 Artist = \"my artist\"\n",
+			},
+		},
+		"FLAC error": {
+			args: args{
+				track: sampleTrack,
+				sT:    files.FLAC,
+				err:   files.ErrNoFlacVorbisComments,
+				tab:   2,
+			},
+			WantedRecording: output.WantedRecording{
+				Log: "level='error'" +
+					" error='no FLAC vorbis comment block found'" +
+					" metadata='FLAC'" +
+					" track='music\\my artist\\my album\\10 track 10.mp3'" +
+					" msg='metadata read error'\n",
+			},
+		},
+		"FLAC blocks": {
+			args: args{
+				track: sampleTrack,
+				sT:    files.FLAC,
+				lines: []string{
+					"Stream: 44100 Hz, 2 channels, 16 bits per sample, 0 samples",
+					"Block: STREAMINFO (34 bytes)",
+					`vendor = "reference libFLAC 1.4.3"`,
+				},
+				tab: 2,
+			},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"  FLAC Stream: 44100 Hz, 2 channels, 16 bits per sample, 0 samples\n" +
+					"  FLAC Block: STREAMINFO (34 bytes)\n" +
+					"  FLAC vendor = \"reference libFLAC 1.4.3\"\n",
 			},
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			cmd.ShowID3V2Diagnostics(o, tt.args.track, tt.args.version, tt.args.encoding,
-				tt.args.frames, tt.args.err, tt.args.tab)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			cmd.ShowDiagnostics(o, tt.args.track, tt.args.sT, tt.args.lines, tt.args.err,
+				tt.args.tab)
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
-					t.Errorf("ShowID3V2Diagnostics() %s", difference)
+					t.Errorf("ShowDiagnostics() %s", difference)
 				}
 			}
 		})
@@ -740,6 +897,12 @@ func TestListSettingsListTrackDiagnostics(t *testing.T) {
 					"level='error'" +
 					" error='open music\\my artist\\my album\\10 track 10.mp3: The system" +
 					" cannot find the path specified.'" +
+					" metadata='APEV2'" +
+					" track='music\\my artist\\my album\\10 track 10.mp3'" +
+					" msg='metadata read error'\n" +
+					"level='error'" +
+					" error='open music\\my artist\\my album\\10 track 10.mp3: The system" +
+					" cannot find the path specified.'" +
 					" metadata='ID3V1'" +
 					" track='music\\my artist\\my album\\10 track 10.mp3'" +
 					" msg='metadata read error'\n",
@@ -753,7 +916,7 @@ func TestListSettingsListTrackDiagnostics(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
 			tt.ls.ListTrackDiagnostics(o, tt.args.track, tt.args.tab)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ListSettings.ListTrackDiagnostics() %s", difference)
 				}
@@ -810,7 +973,7 @@ func TestShowDetails(t *testing.T) {
 			o := output.NewRecorder()
 			cmd.ShowDetails(o, tt.args.track, tt.args.details, tt.args.detailsError,
 				tt.args.tab)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ShowDetails() %s", difference)
 				}
@@ -850,7 +1013,7 @@ func TestListSettingsListTrackDetails(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
 			tt.ls.ListTrackDetails(o, tt.args.track, tt.args.tab)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ListSettings.ListTrackDetails() %s", difference)
 				}
@@ -997,6 +1160,69 @@ func TestListSettingsListTracksByName(t *testing.T) {
 	}
 }
 
+func TestListSettingsListTracksByDuration(t *testing.T) {
+	dir := t.TempDir()
+	// each 128 kbps frame holds 1152 samples, or about 26ms of audio
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x40})
+	newTrack := func(name string, frames int) *files.Track {
+		path := filepath.Join(dir, name+".mp3")
+		if err := os.WriteFile(path, bytes.Repeat(frame, frames), 0o644); err != nil {
+			t.Errorf("ListSettings.ListTracksByDuration() cannot create %q: %v", path, err)
+		}
+		return files.NewEmptyTrack().WithName(name).WithFullPath(path).WithAlbum(
+			files.NewEmptyAlbum().WithTitle("my album").WithArtist(
+				files.NewEmptyArtist().WithFileName("my artist")))
+	}
+	type args struct {
+		tracks []*files.Track
+		tab    int
+	}
+	tests := map[string]struct {
+		ls *cmd.ListSettings
+		args
+		output.WantedRecording
+	}{
+		"no tracks": {
+			ls:   cmd.NewListSettings(),
+			args: args{},
+		},
+		"mixed tracks": {
+			ls: cmd.NewListSettings(),
+			args: args{
+				tracks: []*files.Track{
+					files.NewEmptyTrack().WithName("missing").WithFullPath(
+						filepath.Join(dir, "missing.mp3")).WithAlbum(files.NewEmptyAlbum()),
+					newTrack("long", 7000),
+					newTrack("short b", 100),
+					newTrack("short a", 100),
+					newTrack("medium", 2000),
+				},
+				tab: 2,
+			},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"  short a (0:03)\n" +
+					"  short b (0:03)\n" +
+					"  medium (0:52)\n" +
+					"  long (3:03)\n" +
+					"  missing\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			tt.ls.ListTracksByDuration(o, tt.args.tracks, tt.args.tab)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ListSettings.ListTracksByDuration() %s", difference)
+				}
+			}
+		})
+	}
+}
+
 func TestListSettingsListTracksByNumber(t *testing.T) {
 	type args struct {
 		tracks []*files.Track
@@ -1035,6 +1261,26 @@ func TestListSettingsListTracksByNumber(t *testing.T) {
 					"  17. my track 0017\n",
 			},
 		},
+		"multi-disc album": {
+			ls: cmd.NewListSettings(),
+			args: args{
+				tracks: []*files.Track{
+					files.NewEmptyTrack().WithName("second disc, first track").WithNumber(
+						1).WithDisc(2),
+					files.NewEmptyTrack().WithName("first disc, second track").WithNumber(
+						2).WithDisc(1),
+					files.NewEmptyTrack().WithName("first disc, first track").WithNumber(
+						1).WithDisc(1),
+				},
+				tab: 2,
+			},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"  1-01. first disc, first track\n" +
+					"  1-02. first disc, second track\n" +
+					"  2-01. second disc, first track\n",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -1210,6 +1456,8 @@ func generateAlbums(albumCount, trackCount int) []*files.Album {
 }
 
 func TestListSettingsListAlbums(t *testing.T) {
+	misnamedAlbums := generateAlbums(1, 2)
+	misnamedAlbums[0].AddUnparsedTrack("bonus track.mp3")
 	type args struct {
 		albums []*files.Album
 		tab    int
@@ -1274,6 +1522,33 @@ func TestListSettingsListAlbums(t *testing.T) {
 					"   3. my track 023\n",
 			},
 		},
+		"list albums and tracks with unparsed track files": {
+			ls: cmd.NewListSettings().WithAlbums(true).WithTracks(true).WithSortByNumber(true),
+			args: args{
+				albums: misnamedAlbums,
+				tab:    0,
+			},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"Album: my album 00\n" +
+					"   1. my track 001\n" +
+					"   2. my track 002\n" +
+					"  bonus track.mp3 (unparsed)\n",
+			},
+		},
+		"list tracks only with unparsed track files": {
+			ls: cmd.NewListSettings().WithTracks(true).WithAnnotate(true).WithSortByTitle(true),
+			args: args{
+				albums: misnamedAlbums,
+				tab:    2,
+			},
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"  \"my track 001\" on \"my album 00\" by \"my artist 0\"\n" +
+					"  \"my track 002\" on \"my album 00\" by \"my artist 0\"\n" +
+					"  \"bonus track.mp3\" on \"my album 00\" by \"my artist 0\" (unparsed)\n",
+			},
+		},
 		"https://github.com/majohn-r/mp3/issues/147": {
 			ls: cmd.NewListSettings().WithAlbums(true).WithAnnotate(true),
 			args: args{
@@ -1578,11 +1853,16 @@ func TestListSettingsListArtists(t *testing.T) {
 func Test_ListRun(t *testing.T) {
 	cmd.InitGlobals()
 	originalBus := cmd.Bus
+	originalSince := cmd.Since
 	originalSearchFlags := cmd.SearchFlags
 	defer func() {
 		cmd.Bus = originalBus
+		cmd.Since = originalSince
 		cmd.SearchFlags = originalSearchFlags
 	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
 	cmd.SearchFlags = safeSearchFlags
 
 	testListFlags := cmd.NewSectionFlags().WithSectionName(cmd.ListCommand).WithFlags(
@@ -1602,6 +1882,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListSortByTitle: cmd.NewFlagDetails().WithUsage(
 				"sort tracks by track title").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.ListSortByDuration: cmd.NewFlagDetails().WithUsage(
+				"sort tracks by duration").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 			cmd.ListAnnotate: cmd.NewFlagDetails().WithUsage(
 				"annotate listings with album and artist names").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
@@ -1611,6 +1894,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListDiagnostic: cmd.NewFlagDetails().WithUsage(
 				"include diagnostic information with tracks").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.ListIgnored: cmd.NewFlagDetails().WithUsage(
+				"include files and directories skipped by ignore rules").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 		},
 	)
 	testCmd := &cobra.Command{}
@@ -1634,6 +1920,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListSortByTitle: cmd.NewFlagDetails().WithUsage(
 				"sort tracks by track title").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(true),
+			cmd.ListSortByDuration: cmd.NewFlagDetails().WithUsage(
+				"sort tracks by duration").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 			cmd.ListAnnotate: cmd.NewFlagDetails().WithUsage(
 				"annotate listings with album and artist names").WithExpectedType(cmd.BoolType).WithDefaultValue(false),
 			cmd.ListDetails: cmd.NewFlagDetails().WithUsage(
@@ -1642,6 +1931,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListDiagnostic: cmd.NewFlagDetails().WithUsage(
 				"include diagnostic information with tracks").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.ListIgnored: cmd.NewFlagDetails().WithUsage(
+				"include files and directories skipped by ignore rules").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 		},
 	)
 	testCmd2 := &cobra.Command{}
@@ -1665,6 +1957,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListSortByTitle: cmd.NewFlagDetails().WithUsage(
 				"sort tracks by track title").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.ListSortByDuration: cmd.NewFlagDetails().WithUsage(
+				"sort tracks by duration").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 			cmd.ListAnnotate: cmd.NewFlagDetails().WithUsage(
 				"annotate listings with album and artist names").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
@@ -1674,6 +1969,9 @@ func Test_ListRun(t *testing.T) {
 			cmd.ListDiagnostic: cmd.NewFlagDetails().WithUsage(
 				"include diagnostic information with tracks").WithExpectedType(
 				cmd.BoolType).WithDefaultValue(false),
+			cmd.ListIgnored: cmd.NewFlagDetails().WithUsage(
+				"include files and directories skipped by ignore rules").WithExpectedType(
+				cmd.BoolType).WithDefaultValue(false),
 		},
 	)
 	testCmd3 := &cobra.Command{}
@@ -1703,21 +2001,45 @@ func Test_ListRun(t *testing.T) {
 					" --annotate='false'" +
 					" --artistFilter='.*'" +
 					" --artists='true'" +
+					" --bitrateRange=''" +
+					" --byDuration='false'" +
 					" --byNumber='false'" +
 					" --byTitle='false'" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
 					" --details='false'" +
 					" --diagnostic='false'" +
+					" --durationRange=''" +
 					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --ignored='false'" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
 					" --tracks='false'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" albums-user-set='false'" +
 					" artists-user-set='false'" +
+					" byDuration-user-set='false'" +
 					" byNumber-user-set='false'" +
 					" byTitle-user-set='false'" +
 					" command='list'" +
 					" tracks-user-set='false'" +
 					" msg='executing command'\n" +
+					"level='info'" +
+					" --topDir='.'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
 					"level='error'" +
 					" --topDir='.'" +
 					" msg='cannot find any artist directories'\n",
@@ -1741,16 +2063,35 @@ func Test_ListRun(t *testing.T) {
 					" --annotate='false'" +
 					" --artistFilter='.*'" +
 					" --artists='true'" +
+					" --bitrateRange=''" +
+					" --byDuration='false'" +
 					" --byNumber='true'" +
 					" --byTitle='true'" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
 					" --details='false'" +
 					" --diagnostic='false'" +
+					" --durationRange=''" +
 					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --ignored='false'" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
 					" --tracks='true'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" albums-user-set='false'" +
 					" artists-user-set='false'" +
+					" byDuration-user-set='false'" +
 					" byNumber-user-set='false'" +
 					" byTitle-user-set='false'" +
 					" command='list'" +
@@ -1780,16 +2121,35 @@ func Test_ListRun(t *testing.T) {
 					" --annotate='false'" +
 					" --artistFilter='.*'" +
 					" --artists='false'" +
+					" --bitrateRange=''" +
+					" --byDuration='false'" +
 					" --byNumber='false'" +
 					" --byTitle='false'" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
 					" --details='false'" +
 					" --diagnostic='false'" +
+					" --durationRange=''" +
 					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --ignored='false'" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
 					" --tracks='false'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" albums-user-set='false'" +
 					" artists-user-set='false'" +
+					" byDuration-user-set='false'" +
 					" byNumber-user-set='false'" +
 					" byTitle-user-set='false'" +
 					" command='list'" +
@@ -1823,6 +2183,29 @@ func compareExitErrors(e1, e2 *cmd.ExitError) bool {
 }
 
 func TestListSettingsProcessArtists(t *testing.T) {
+	// a library whose ignore file keeps a bootleg album out of the search
+	topDir := t.TempDir()
+	albumDir := filepath.Join(topDir, "my artist", "my album")
+	bootlegDir := filepath.Join(topDir, "my artist", "bootleg live")
+	for _, dir := range []string{albumDir, bootlegDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Errorf("ListSettings.ProcessArtists() cannot create %q: %v", dir, err)
+		}
+	}
+	ignoreFile := filepath.Join(topDir, files.IgnoreFileName)
+	for path, content := range map[string]string{
+		filepath.Join(albumDir, "01 my track.mp3"):   "",
+		filepath.Join(bootlegDir, "01 my track.mp3"): "",
+		ignoreFile: "bootleg*/",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Errorf("ListSettings.ProcessArtists() cannot create %q: %v", path, err)
+		}
+	}
+	loadedSettings := cmd.NewSearchSettings().WithTopDirectory(topDir).WithFileExtensions(
+		[]string{".mp3"}).WithArtistFilter(regexp.MustCompile(".*")).WithAlbumFilter(
+		regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile(".*"))
+	loadedArtists, _ := loadedSettings.Load(context.Background(), output.NewNilBus())
 	type args struct {
 		allArtists     []*files.Artist
 		loaded         bool
@@ -1876,6 +2259,35 @@ func TestListSettingsProcessArtists(t *testing.T) {
 					"Artist: my artist 2\n",
 			},
 		},
+		"ignored, with none": {
+			ls: cmd.NewListSettings().WithArtists(true).WithIgnored(true),
+			args: args{
+				allArtists: generateArtists(1, 1, 1),
+				loaded:     true,
+				searchSettings: cmd.NewSearchSettings().WithArtistFilter(
+					regexp.MustCompile(".*")).WithAlbumFilter(
+					regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile(".*")),
+			},
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"Artist: my artist 0\n" +
+					"No files or directories were ignored.\n",
+			},
+		},
+		"ignored": {
+			ls: cmd.NewListSettings().WithIgnored(true),
+			args: args{
+				allArtists:     loadedArtists,
+				loaded:         true,
+				searchSettings: loadedSettings,
+			},
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Console: fmt.Sprintf("Ignored directory: %s\n  by \"bootleg*/\" (line 1 of %s)\n",
+					bootlegDir, ignoreFile),
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -1914,9 +2326,10 @@ func TestListHelp(t *testing.T) {
 					"\n" +
 					"Usage:\n" +
 					"  list [--albums] [--artists] [--tracks] [--annotate] [--details]" +
-					" [--diagnostic] [--byNumber | --byTitle] [--albumFilter regex]" +
+					" [--diagnostic] [--ignored] [--byNumber | --byTitle | --byDuration]" +
+					" [--albumFilter regex]" +
 					" [--artistFilter regex] [--trackFilter regex] [--topDir dir]" +
-					" [--extensions extensions]\n" +
+					" [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
 					"\n" +
 					"Examples:\n" +
 					"list --annotate\n" +
@@ -1925,45 +2338,78 @@ func TestListHelp(t *testing.T) {
 					"list --details\n" +
 					"  Include detailed information, if available, for each track. This" +
 					" includes composer,\n" +
-					"  conductor, key, lyricist, orchestra/band, and subtitle\n" +
+					"  conductor, key, lyricist, orchestra/band, and subtitle; for mp3 files," +
+					" it also\n" +
+					"  includes the duration, bitrate, sample rate, channel mode, VBR header," +
+					" and encoder\n" +
 					"list --albums\n" +
 					"  Include the album names in the output\n" +
 					"list --artists\n" +
 					"  Include the artist names in the output\n" +
 					"list --tracks\n" +
 					"  Include the track names in the output\n" +
+					"list --ignored\n" +
+					"  Include the files and directories skipped by ignore rules, and the" +
+					" rules that\n" +
+					"  skipped them\n" +
 					"list --byTitle\n" +
 					"  Sort tracks by name, ignoring track numbers\n" +
 					"list --byNumber\n" +
 					"  Sort tracks by track number\n" +
+					"list --byDuration\n" +
+					"  Sort tracks by duration, shortest first\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    " +
+					"      --albumFilter string        " +
 					"regular expression specifying which albums to select (default \".*\")\n" +
-					"  -l, --albums                " +
+					"  -l, --albums                    " +
 					"include album names in listing (default false)\n" +
-					"      --annotate              " +
+					"      --annotate                  " +
 					"annotate listings with album and artist names (default false)\n" +
-					"      --artistFilter string   " +
+					"      --artistFilter string       " +
 					"regular expression specifying which artists to select (default \".*\")\n" +
-					"  -r, --artists               " +
+					"  -r, --artists                   " +
 					"include artist names in listing (default false)\n" +
-					"      --byNumber              " +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --byDuration                " +
+					"sort tracks by duration (default false)\n" +
+					"      --byNumber                  " +
 					"sort tracks by track number (default false)\n" +
-					"      --byTitle               " +
+					"      --byTitle                   " +
 					"sort tracks by track title (default false)\n" +
-					"      --details               " +
+					"      --compilations string       " +
+					"comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"      --details                   " +
 					"include details with tracks (default false)\n" +
-					"      --diagnostic            " +
+					"      --diagnostic                " +
 					"include diagnostic information with tracks (default false)\n" +
-					"      --extensions string     " +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"      --extensions string         " +
 					"comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
-					"      --topDir string         " +
-					"top directory specifying where to find mp3 files (default \".\")\n" +
-					"      --trackFilter string    " +
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"      --ignored                   " +
+					"include files and directories skipped by ignore rules (default false)\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   " +
+					"comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             " +
+					"top directory, or list of top directories, specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        " +
 					"regular expression specifying which tracks to select (default \".*\")\n" +
-					"  -t, --tracks                " +
-					"include track names in listing (default false)\n",
+					"      --trackNames string         " +
+					"newline-delimited list of regular expressions for parsing track file names;" +
+					" empty selects the built-in expressions (default \"\")\n" +
+					"  -t, --tracks                    " +
+					"include track names in listing (default false)\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
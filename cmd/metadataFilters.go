@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"mp3/internal/files"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+// ValueRange is an inclusive range of values, either end of which may be left
+// open, as in "1970-1979", "1990-", or "-1979"; a single value, as in "1984",
+// is a range that begins and ends with that value
+type ValueRange[T cmp.Ordered] struct {
+	text    string
+	low     T
+	high    T
+	hasLow  bool
+	hasHigh bool
+}
+
+// String returns the range as it was written; a nil range, which selects every
+// value, is written as an empty string
+func (r *ValueRange[T]) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.text
+}
+
+// Contains reports whether the value lies within the range
+func (r *ValueRange[T]) Contains(v T) bool {
+	return (!r.hasLow || v >= r.low) && (!r.hasHigh || v <= r.high)
+}
+
+// ParseNumberRange parses a range of whole numbers, such as years or bitrates
+func ParseNumberRange(text string) (*ValueRange[int], error) {
+	return parseRange(text, func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a whole number", s)
+		}
+		return n, nil
+	})
+}
+
+// ParseDurationRange parses a range of durations, each written as in "3m30s"
+func ParseDurationRange(text string) (*ValueRange[time.Duration], error) {
+	return parseRange(text, time.ParseDuration)
+}
+
+func parseRange[T cmp.Ordered](text string, parse func(string) (T, error)) (*ValueRange[T],
+	error) {
+	lowText, highText, found := strings.Cut(text, "-")
+	if !found {
+		highText = lowText
+	}
+	r := &ValueRange[T]{text: text}
+	var err error
+	if lowText = strings.TrimSpace(lowText); lowText != "" {
+		if r.low, err = parse(lowText); err != nil {
+			return nil, err
+		}
+		r.hasLow = true
+	}
+	if highText = strings.TrimSpace(highText); highText != "" {
+		if r.high, err = parse(highText); err != nil {
+			return nil, err
+		}
+		r.hasHigh = true
+	}
+	switch {
+	case !r.hasLow && !r.hasHigh:
+		return nil, errors.New("neither end of the range is given")
+	case r.hasLow && r.hasHigh && r.high < r.low:
+		return nil, fmt.Errorf("the range ends (%s) before it begins (%s)", highText, lowText)
+	}
+	return r, nil
+}
+
+// EvaluateTagFilter returns the filter applied to one of the values read from
+// the track metadata; an empty value selects every track, which is indicated by
+// returning nil
+func EvaluateTagFilter(o output.Bus, values map[string]*FlagValue, flagName,
+	nameAsFlag string) (filter *regexp.Regexp, ok, regexOk bool) {
+	rawValue, _, err := GetString(o, values, flagName)
+	if err != nil {
+		return nil, false, true
+	}
+	if rawValue == "" {
+		return nil, true, true
+	}
+	return EvaluateFilter(o, values, flagName, nameAsFlag)
+}
+
+// EvaluateYearRange returns the range of years, read from the track metadata, to
+// select; an empty value selects every track, which is indicated by returning
+// nil
+func EvaluateYearRange(o output.Bus, values map[string]*FlagValue) (*ValueRange[int], bool) {
+	return evaluateRange(o, values, SearchYearRange, ParseNumberRange, "1970-1979")
+}
+
+// EvaluateBitrateRange returns the range of bitrates, in kbps, to select; an
+// empty value selects every track, which is indicated by returning nil
+func EvaluateBitrateRange(o output.Bus, values map[string]*FlagValue) (*ValueRange[int], bool) {
+	return evaluateRange(o, values, SearchBitrateRange, ParseNumberRange, "192-320")
+}
+
+// EvaluateDurationRange returns the range of track durations to select; an empty
+// value selects every track, which is indicated by returning nil
+func EvaluateDurationRange(o output.Bus,
+	values map[string]*FlagValue) (*ValueRange[time.Duration], bool) {
+	return evaluateRange(o, values, SearchDurationRange, ParseDurationRange, "2m-10m30s")
+}
+
+func evaluateRange[T cmp.Ordered](o output.Bus, values map[string]*FlagValue, flagName string,
+	parse func(string) (*ValueRange[T], error), example string) (*ValueRange[T], bool) {
+	rawValue, userSet, err := GetString(o, values, flagName)
+	if err != nil {
+		return nil, false
+	}
+	if strings.TrimSpace(rawValue) == "" {
+		return nil, true
+	}
+	r, err := parse(rawValue)
+	if err != nil {
+		nameAsFlag := "--" + flagName
+		o.WriteCanonicalError("The %s value %q cannot be used", nameAsFlag, rawValue)
+		o.WriteCanonicalError("Why?\n%v", err)
+		o.WriteCanonicalError("What to do:\n"+
+			"Use a range such as %q, leaving out either end to leave it open, or an"+
+			" empty value to select every track", example)
+		o.Log(output.Error, "invalid range", map[string]any{
+			"error":    err,
+			nameAsFlag: rawValue,
+			"user-set": userSet,
+		})
+		return nil, false
+	}
+	return r, true
+}
+
+// EvaluateID3Versions returns the ID3V2 versions to select, where 0 stands for
+// tracks that have no ID3V2 tag; an empty value selects every track, which is
+// indicated by returning nil
+func EvaluateID3Versions(o output.Bus, values map[string]*FlagValue) ([]int, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchID3Versions)
+	if err != nil {
+		return nil, false
+	}
+	var versions []int
+	var rejected []string
+	for _, candidate := range strings.Split(rawValue, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "" {
+			continue
+		}
+		version, err := strconv.Atoi(candidate)
+		if err != nil || !slices.Contains([]int{0, 2, 3, 4}, version) {
+			o.WriteCanonicalError("The ID3V2 version %q cannot be used.", candidate)
+			rejected = append(rejected, candidate)
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(rejected) > 0 {
+		o.WriteCanonicalError("Why?\nThe ID3V2 versions are 2, 3, and 4")
+		o.WriteCanonicalError("What to do:\n" +
+			"Provide a comma-delimited list of ID3V2 versions, using 0 for tracks that" +
+			" have no ID3V2 tag")
+		o.Log(output.Error, "invalid ID3V2 versions", map[string]any{
+			"rejected":            rejected,
+			SearchID3VersionsFlag: rawValue,
+			"user-set":            userSet,
+		})
+		return nil, false
+	}
+	return versions, true
+}
+
+// readsTags reports whether any of the filters, including the --where
+// expression, use values read from the track metadata
+func (ss *SearchSettings) readsTags() bool {
+	return ss.genreFilter != nil || ss.tagArtistFilter != nil || ss.tagAlbumFilter != nil ||
+		ss.tagTitleFilter != nil || ss.yearRange != nil || len(ss.id3Versions) > 0 ||
+		ss.where.readsTags()
+}
+
+// appliedFilter is a filter as it would be written on the command line
+type appliedFilter struct {
+	flag  string
+	value string
+}
+
+// activeMetadataFilters returns the metadata filters, and the --where
+// expression, that select fewer than all the tracks
+func (ss *SearchSettings) activeMetadataFilters() []appliedFilter {
+	var active []appliedFilter
+	for _, filter := range []appliedFilter{
+		{flag: SearchGenreFilterFlag, value: optionalFilter(ss.genreFilter)},
+		{flag: SearchTagArtistFilterFlag, value: optionalFilter(ss.tagArtistFilter)},
+		{flag: SearchTagAlbumFilterFlag, value: optionalFilter(ss.tagAlbumFilter)},
+		{flag: SearchTagTitleFilterFlag, value: optionalFilter(ss.tagTitleFilter)},
+		{flag: SearchYearRangeFlag, value: ss.yearRange.String()},
+		{flag: SearchBitrateRangeFlag, value: ss.bitrateRange.String()},
+		{flag: SearchDurationRangeFlag, value: ss.durationRange.String()},
+		{flag: SearchID3VersionsFlag, value: id3VersionList(ss.id3Versions)},
+		{flag: SearchWhereFlag, value: ss.where.String()},
+	} {
+		if filter.value != "" {
+			active = append(active, filter)
+		}
+	}
+	return active
+}
+
+// optionalFilter returns the filter's expression, or an empty string if there is
+// no filter
+func optionalFilter(r *regexp.Regexp) string {
+	if r == nil {
+		return ""
+	}
+	return r.String()
+}
+
+func id3VersionList(versions []int) string {
+	written := make([]string, 0, len(versions))
+	for _, version := range versions {
+		written = append(written, strconv.Itoa(version))
+	}
+	return strings.Join(written, ",")
+}
+
+// metadataMatches reports whether the track passes the metadata filters; a track
+// whose metadata cannot be read passes none of the filters that read tags, and a
+// track whose audio cannot be read, such as a track that is not an MPEG file,
+// passes neither the bitrate nor the duration filter
+func (ss *SearchSettings) metadataMatches(t *files.Track) bool {
+	if ss.readsTags() && !ss.tagsMatch(t) {
+		return false
+	}
+	if ss.bitrateRange == nil && ss.durationRange == nil {
+		return true
+	}
+	info, err := t.AudioInfo()
+	if err != nil {
+		return false
+	}
+	return (ss.bitrateRange == nil || ss.bitrateRange.Contains(info.Bitrate())) &&
+		(ss.durationRange == nil || ss.durationRange.Contains(info.Duration()))
+}
+
+func (ss *SearchSettings) tagsMatch(t *files.Track) bool {
+	_ = t.LoadMetadata()
+	tM := t.GetMetadata()
+	if tM == nil {
+		return false
+	}
+	if len(ss.id3Versions) > 0 && !slices.Contains(ss.id3Versions, tM.ID3V2Version()) {
+		return false
+	}
+	if ss.genreFilter == nil && ss.tagArtistFilter == nil && ss.tagAlbumFilter == nil &&
+		ss.tagTitleFilter == nil && ss.yearRange == nil {
+		return true
+	}
+	if !tM.IsValid() {
+		return false
+	}
+	for _, check := range []struct {
+		filter *regexp.Regexp
+		value  string
+	}{
+		{filter: ss.genreFilter, value: tM.CanonicalGenre()},
+		{filter: ss.tagArtistFilter, value: tM.CanonicalArtist()},
+		{filter: ss.tagAlbumFilter, value: tM.CanonicalAlbum()},
+		{filter: ss.tagTitleFilter, value: tM.CanonicalTitle()},
+	} {
+		if check.filter != nil && !check.filter.MatchString(check.value) {
+			return false
+		}
+	}
+	if ss.yearRange != nil {
+		year, ok := tagYear(tM.CanonicalYear())
+		return ok && ss.yearRange.Contains(year)
+	}
+	return true
+}
+
+// tagYear returns the year with which a metadata year value, such as "1979" or
+// "1979-05-01", begins
+func tagYear(value string) (int, bool) {
+	if len(value) < 4 {
+		return 0, false
+	}
+	year, err := strconv.Atoi(value[:4])
+	return year, err == nil && year >= 0
+}
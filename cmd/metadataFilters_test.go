@@ -0,0 +1,200 @@
+package cmd_test
+
+import (
+	"bytes"
+	"mp3/cmd"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+func TestParseNumberRange(t *testing.T) {
+	tests := map[string]struct {
+		text    string
+		wantErr bool
+		inside  []int
+		outside []int
+	}{
+		"closed":      {text: "1970-1979", inside: []int{1970, 1975, 1979}, outside: []int{1969, 1980}},
+		"open low":    {text: "-1979", inside: []int{0, 1979}, outside: []int{1980}},
+		"open high":   {text: "1990 - ", inside: []int{1990, 2024}, outside: []int{1989}},
+		"single":      {text: "1984", inside: []int{1984}, outside: []int{1983, 1985}},
+		"empty":       {text: "-", wantErr: true},
+		"backwards":   {text: "1979-1970", wantErr: true},
+		"not numbers": {text: "fast-slow", wantErr: true},
+		"two ranges":  {text: "1-2-3", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cmd.ParseNumberRange(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNumberRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.text {
+				t.Errorf("ParseNumberRange() String() = %q, want %q", got.String(), tt.text)
+			}
+			for _, v := range tt.inside {
+				if !got.Contains(v) {
+					t.Errorf("ParseNumberRange() %q does not contain %d", tt.text, v)
+				}
+			}
+			for _, v := range tt.outside {
+				if got.Contains(v) {
+					t.Errorf("ParseNumberRange() %q contains %d", tt.text, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDurationRange(t *testing.T) {
+	tests := map[string]struct {
+		text    string
+		wantErr bool
+		inside  []time.Duration
+		outside []time.Duration
+	}{
+		"closed": {
+			text:    "2m-10m30s",
+			inside:  []time.Duration{2 * time.Minute, 10*time.Minute + 30*time.Second},
+			outside: []time.Duration{time.Minute, 11 * time.Minute},
+		},
+		"open high":    {text: "90s-", inside: []time.Duration{time.Hour}, outside: []time.Duration{time.Minute}},
+		"missing unit": {text: "3-4", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cmd.ParseDurationRange(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDurationRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, v := range tt.inside {
+				if !got.Contains(v) {
+					t.Errorf("ParseDurationRange() %q does not contain %v", tt.text, v)
+				}
+			}
+			for _, v := range tt.outside {
+				if got.Contains(v) {
+					t.Errorf("ParseDurationRange() %q contains %v", tt.text, v)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchSettingsFilterByMetadata(t *testing.T) {
+	dir := t.TempDir()
+	// each 128 kbps frame holds 1152 samples, or about 26ms of audio
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x40})
+	artist := files.NewArtist("artist", dir)
+	album := files.NewAlbum("album", artist, dir)
+	artist.AddAlbum(album)
+	addTrack := func(name string, number, frames int, tM *files.TrackMetadata) {
+		fileName := name + ".mp3"
+		if frames > 0 {
+			path := filepath.Join(dir, fileName)
+			if err := os.WriteFile(path, bytes.Repeat(frame, frames), 0o644); err != nil {
+				t.Errorf("SearchSettings.Filter() cannot create %q: %v", path, err)
+			}
+		}
+		album.AddTrack(files.NewTrack(album, fileName, name, number).WithMetadata(tM))
+	}
+	tagged := func(genre, artistName, albumName, title, year string,
+		version int) *files.TrackMetadata {
+		return files.NewTrackMetadata().WithGenres([]string{"", "", genre}).WithArtistNames(
+			[]string{"", "", artistName}).WithAlbumNames([]string{"", "", albumName}).WithTrackNames(
+			[]string{"", "", title}).WithYears([]string{"", "", year}).WithPrimarySource(
+			files.ID3V2).WithID3V2Version(version)
+	}
+	addTrack("rock", 1, 100, tagged("Rock", "Band", "Live", "Rocking", "1975", 3))
+	addTrack("pop", 2, 5000, tagged("Pop", "Singer", "Studio", "Popping", "1985-06-01", 4))
+	addTrack("untagged", 3, 0, files.NewTrackMetadata().WithErrorCauses(
+		[]string{"", "no ID3V1 tag", "no ID3V2 tag"}))
+	album.AddUnparsedTrack("bonus.mp3")
+	eighties, _ := cmd.ParseNumberRange("1980-1989")
+	highBitrates, _ := cmd.ParseNumberRange("192-")
+	commonBitrates, _ := cmd.ParseNumberRange("128")
+	shortTracks, _ := cmd.ParseDurationRange("-1m")
+	eightiesOrLater, _ := cmd.ParseWhereExpression("year >= 1980 or id3Version == 0")
+	allFilters := func() *cmd.SearchSettings {
+		return cmd.NewSearchSettings().WithArtistFilter(regexp.MustCompile(".*")).WithAlbumFilter(
+			regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile(".*"))
+	}
+	tests := map[string]struct {
+		ss   *cmd.SearchSettings
+		want []string
+		output.WantedRecording
+	}{
+		"no metadata filters": {ss: allFilters(), want: []string{"rock", "pop", "untagged", "bonus.mp3"}},
+		"genre":               {ss: allFilters().WithGenreFilter(regexp.MustCompile("^Rock$")), want: []string{"rock", "bonus.mp3"}},
+		"tag artist":          {ss: allFilters().WithTagArtistFilter(regexp.MustCompile("Singer")), want: []string{"pop", "bonus.mp3"}},
+		"tag album":           {ss: allFilters().WithTagAlbumFilter(regexp.MustCompile("Live")), want: []string{"rock", "bonus.mp3"}},
+		"tag title":           {ss: allFilters().WithTagTitleFilter(regexp.MustCompile("ing$")), want: []string{"rock", "pop", "bonus.mp3"}},
+		"year":                {ss: allFilters().WithYearRange(eighties), want: []string{"pop", "bonus.mp3"}},
+		"ID3V2 version":       {ss: allFilters().WithID3Versions([]int{0, 3}), want: []string{"rock", "untagged", "bonus.mp3"}},
+		"bitrate":             {ss: allFilters().WithBitrateRange(commonBitrates), want: []string{"rock", "pop", "bonus.mp3"}},
+		"duration":            {ss: allFilters().WithDurationRange(shortTracks), want: []string{"rock", "bonus.mp3"}},
+		"where":               {ss: allFilters().WithWhere(eightiesOrLater), want: []string{"pop", "untagged", "bonus.mp3"}},
+		"unparsed track file names matched": {
+			ss: cmd.NewSearchSettings().WithArtistFilter(regexp.MustCompile(".*")).WithAlbumFilter(
+				regexp.MustCompile(".*")).WithTrackFilter(regexp.MustCompile("^[bp]o")),
+			want: []string{"pop", "bonus.mp3"},
+		},
+		"everything filtered": {
+			ss: allFilters().WithGenreFilter(regexp.MustCompile("Jazz")).WithBitrateRange(
+				highBitrates),
+			WantedRecording: output.WantedRecording{
+				Error: "No music files remain after filtering.\n" +
+					"Why?\n" +
+					"After applying --artistFilter=\".*\", --albumFilter=\".*\"," +
+					" --trackFilter=\".*\", --genreFilter=\"Jazz\", and" +
+					" --bitrateRange=\"192-\", no files remained.\n" +
+					"What to do:\n" +
+					"Use less restrictive filter settings.\n",
+				Log: "level='error'" +
+					" --albumFilter='.*'" +
+					" --artistFilter='.*'" +
+					" --bitrateRange='192-'" +
+					" --genreFilter='Jazz'" +
+					" --trackFilter='.*'" +
+					" msg='no files remain after filtering'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			filtered, _ := tt.ss.Filter(o, []*files.Artist{artist})
+			var got []string
+			for _, a := range filtered {
+				for _, al := range a.Albums() {
+					for _, track := range al.Tracks() {
+						got = append(got, track.CommonName())
+					}
+					got = append(got, al.UnparsedTracks()...)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchSettings.Filter() got %v, want %v", got, tt.want)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("SearchSettings.Filter() %s", difference)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+
+package cmd_test
+
+import (
+	"strings"
+
+	"github.com/majohn-r/output"
+)
+
+// translates expectations written in terms of Windows paths and error
+// messages into their equivalents on this platform; quoted separators (as
+// written by %q) must be translated before bare separators, and error
+// messages ending a sentence keep their period, while embedded error messages
+// do not
+var nativeReplacer = strings.NewReplacer(
+	`\\`, "/",
+	`\`, "/",
+	"CreateFile ", "stat ",
+	"The system cannot find the file specified.\n", "no such file or directory.\n",
+	"The system cannot find the path specified.\n", "no such file or directory.\n",
+	"The system cannot find the file specified.", "no such file or directory",
+	"The system cannot find the path specified.", "no such file or directory",
+)
+
+// the description of the configuration file that help text is expected to
+// contain
+const configurationFileDescription = "$APPDATA/mp3/defaults.yaml (if APPDATA is not set," +
+	" $XDG_CONFIG_HOME or $HOME/.config is used in its place)"
+
+// nativeText translates expected text into its equivalent on this platform
+func nativeText(s string) string {
+	return nativeReplacer.Replace(s)
+}
+
+// nativeRecording translates an expected recording into its equivalent on
+// this platform
+func nativeRecording(w output.WantedRecording) output.WantedRecording {
+	return output.WantedRecording{
+		Console: nativeText(w.Console),
+		Error:   nativeText(w.Error),
+		Log:     nativeText(w.Log),
+	}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package cmd_test
+
+import "github.com/majohn-r/output"
+
+// the description of the configuration file that help text is expected to
+// contain
+const configurationFileDescription = `%APPDATA%\mp3\defaults.yaml`
+
+// nativeText returns the expected text unchanged; expectations are written
+// in terms of Windows paths and error messages
+func nativeText(s string) string {
+	return s
+}
+
+// nativeRecording returns the expected recording unchanged; expectations are
+// written in terms of Windows paths and error messages
+func nativeRecording(w output.WantedRecording) output.WantedRecording {
+	return w
+}
@@ -36,7 +36,7 @@ func PostRepairRun(cmd *cobra.Command, _ []string) error {
 	if searchFlagsOk {
 		// do some work here!
 		LogCommandStart(o, postRepairCommandName, ss.Values())
-		allArtists, loaded := ss.Load(o)
+		allArtists, loaded := ss.Load(commandContext(cmd), o)
 		exitError = PostRepairWork(o, ss, allArtists, loaded)
 	}
 	return ToErrorInterface(exitError)
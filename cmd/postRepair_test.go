@@ -9,6 +9,7 @@ import (
 	"mp3/internal/files"
 	"regexp"
 	"testing"
+	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
@@ -202,7 +203,7 @@ func TestPostRepairWork(t *testing.T) {
 			cmd.DirExists = tt.dirExists
 			o := output.NewRecorder()
 			cmd.PostRepairWork(o, tt.args.ss, tt.args.allArtists, tt.args.loaded)
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("PostRepairWork() %s", difference)
 				}
@@ -214,9 +215,14 @@ func TestPostRepairWork(t *testing.T) {
 func TestPostRepairRun(t *testing.T) {
 	cmd.InitGlobals()
 	originalBus := cmd.Bus
+	originalSince := cmd.Since
 	defer func() {
 		cmd.Bus = originalBus
+		cmd.Since = originalSince
 	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
 	command := &cobra.Command{}
 	cmd.AddFlags(output.NewNilBus(), cmd_toolkit.EmptyConfiguration(), command.Flags(),
 		safeSearchFlags)
@@ -242,11 +248,32 @@ func TestPostRepairRun(t *testing.T) {
 					"level='info'" +
 					" --albumFilter='.*'" +
 					" --artistFilter='.*'" +
+					" --bitrateRange=''" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
+					" --durationRange=''" +
 					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --ignore=''" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" command='postRepair'" +
 					" msg='executing command'\n" +
+					"level='info'" +
+					" --topDir='.'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
 					"level='error'" +
 					" --topDir='.'" +
 					" msg='cannot find any artist directories'\n",
@@ -282,19 +309,35 @@ func TestPostRepairHelp(t *testing.T) {
 					"\n" +
 					"Usage:\n" +
 					"  postRepair [--albumFilter regex] [--artistFilter regex]" +
-					" [--trackFilter regex] [--topDir dir] [--extensions extensions]\n" +
+					" [--trackFilter regex] [--topDir dir] [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    regular expression specifying which" +
+					"      --albumFilter string        regular expression specifying which" +
 					" albums to select (default \".*\")\n" +
-					"      --artistFilter string   regular expression specifying which" +
+					"      --artistFilter string       regular expression specifying which" +
 					" artists to select (default \".*\")\n" +
-					"      --extensions string     comma-delimited list of file extensions" +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"      --extensions string         comma-delimited list of file extensions" +
 					" used by mp3 files (default \".mp3\")\n" +
-					"      --topDir string         top directory specifying where to find" +
-					" mp3 files (default \".\")\n" +
-					"      --trackFilter string    regular expression specifying which" +
-					" tracks to select (default \".*\")\n",
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             top directory, or list of top directories," +
+					" specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        regular expression specifying which" +
+					" tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
@@ -325,19 +368,35 @@ func TestPostRepairUsage(t *testing.T) {
 				Console: "" +
 					"Usage:\n" +
 					"  postRepair [--albumFilter regex] [--artistFilter regex]" +
-					" [--trackFilter regex] [--topDir dir] [--extensions extensions]\n" +
+					" [--trackFilter regex] [--topDir dir] [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    regular expression specifying which" +
+					"      --albumFilter string        regular expression specifying which" +
 					" albums to select (default \".*\")\n" +
-					"      --artistFilter string   regular expression specifying which" +
+					"      --artistFilter string       regular expression specifying which" +
 					" artists to select (default \".*\")\n" +
-					"      --extensions string     comma-delimited list of file extensions" +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"      --extensions string         comma-delimited list of file extensions" +
 					" used by mp3 files (default \".mp3\")\n" +
-					"      --topDir string         top directory specifying where to find" +
-					" mp3 files (default \".\")\n" +
-					"      --trackFilter string    regular expression specifying which" +
-					" tracks to select (default \".*\")\n",
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             top directory, or list of top directories," +
+					" specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        regular expression specifying which" +
+					" tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
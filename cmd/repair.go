@@ -4,27 +4,40 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"mp3/internal/files"
 	"path/filepath"
 	"slices"
 	"strings"
 
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
 	"github.com/spf13/cobra"
 )
 
 const (
-	repairCommandName = "repair"
-	repairDryRun      = "dryRun"
-	repairDryRunFlag  = "--" + repairDryRun
+	repairCommandName    = "repair"
+	repairCreateTags     = "createTags"
+	repairCreateTagsFlag = "--" + repairCreateTags
+	repairDryRun         = "dryRun"
+	repairDryRunFlag     = "--" + repairDryRun
+	repairEncoding       = "id3v2Encoding"
+	repairEncodingFlag   = "--" + repairEncoding
+	repairNormalize      = "normalize"
+	repairNormalizeFlag  = "--" + repairNormalize
+	repairStrip          = "strip"
+	repairStripFlag      = "--" + repairStrip
+	repairVersion        = "id3v2Version"
+	repairVersionFlag    = "--" + repairVersion
 )
 
 var (
 	// RepairCmd represents the repair command
 	RepairCmd = &cobra.Command{
-		Use: repairCommandName + " [" + repairDryRunFlag + "] " +
-			searchUsage,
+		Use: repairCommandName + " [" + repairDryRunFlag + "] [" + repairCreateTagsFlag +
+			" | " + repairStripFlag + "] [" + repairNormalizeFlag + " [" + repairVersionFlag +
+			" 3|4] [" + repairEncodingFlag + " encoding]] " + searchUsage,
 		DisableFlagsInUseLine: true,
 		Short: "Repairs problems found by running '" + CheckCommand + " " +
 			CheckFilesFlag + "'",
@@ -40,14 +53,56 @@ var (
 			" original mp3\n" +
 			"file into that backup directory. Use the " + postRepairCommandName +
 			" command to automatically delete\n" +
-			"the backup folders.",
+			"the backup folders.\n" +
+			"\n" +
+			"If " + repairCreateTagsFlag + " is set, the command also adds an ID3V1 tag or" +
+			" an ID3V2 tag to each\n" +
+			"mp3 file that lacks one, filling it in from the file structure and from the" +
+			" genre\n" +
+			"and year of the other tracks in the album.\n" +
+			"\n" +
+			"If " + repairStripFlag + " is set, the command also removes the ID3V1, APEv2," +
+			" and Lyrics3 tags\n" +
+			"from each mp3 file whose ID3V2 tag can be read.\n" +
+			"\n" +
+			"If " + repairNormalizeFlag + " is set, the command also rewrites each ID3V2 tag" +
+			" in the version set by\n" +
+			repairVersionFlag + ", with all of its text in the encoding set by " +
+			repairEncodingFlag + ". Frames\n" +
+			"that cannot be represented in that version or encoding are removed; the" +
+			" TYER, TDAT,\n" +
+			"and TIME frames of ID3V2.3 become the TDRC frame of ID3V2.4, and vice versa.",
+		Example: "" +
+			repairCommandName + " " + repairDryRunFlag + " " + repairStripFlag + "\n" +
+			"  lists the tags that would be removed from each mp3 file\n" +
+			repairCommandName + " " + repairNormalizeFlag + " " + repairVersionFlag + " 3 " +
+			repairEncodingFlag + " UTF-16\n" +
+			"  rewrites each ID3V2 tag as version 2.3, with its text encoded in UTF-16",
 		RunE: RepairRun,
 	}
 	RepairFlags = NewSectionFlags().WithSectionName("repair").WithFlags(
 		map[string]*FlagDetails{
+			repairCreateTags: NewFlagDetails().WithUsage(
+				"create missing ID3V1 and ID3V2 tags",
+			).WithExpectedType(BoolType).WithDefaultValue(false),
 			"dryRun": NewFlagDetails().WithUsage(
 				"output what would have been repaired, but make no repairs",
 			).WithExpectedType(BoolType).WithDefaultValue(false),
+			repairEncoding: NewFlagDetails().WithUsage(
+				"text encoding of normalized ID3V2 tags: one of " +
+					strings.Join(files.Id3v2EncodingNames(), ", "),
+			).WithExpectedType(StringType).WithDefaultValue("UTF-8"),
+			repairNormalize: NewFlagDetails().WithUsage(
+				"rewrite ID3V2 tags in one version and text encoding",
+			).WithExpectedType(BoolType).WithDefaultValue(false),
+			repairStrip: NewFlagDetails().WithUsage(
+				"remove ID3V1, APEv2, and Lyrics3 tags from mp3 files with a readable" +
+					" ID3V2 tag",
+			).WithExpectedType(BoolType).WithDefaultValue(false),
+			repairVersion: NewFlagDetails().WithUsage(
+				"ID3V2 version (3 or 4) of normalized ID3V2 tags",
+			).WithExpectedType(IntType).WithDefaultValue(
+				cmd_toolkit.NewIntBounds(3, 4, 4)),
 		},
 	)
 )
@@ -60,53 +115,141 @@ func RepairRun(cmd *cobra.Command, _ []string) error {
 	searchSettings, searchFlagsOk := EvaluateSearchFlags(o, producer)
 	if ProcessFlagErrors(o, eSlice) && searchFlagsOk {
 		if rs, ok := ProcessRepairFlags(o, values); ok {
-			details := map[string]any{repairDryRunFlag: rs.dryRun}
+			details := map[string]any{
+				repairCreateTagsFlag: rs.createTags,
+				repairDryRunFlag:     rs.dryRun,
+				repairEncodingFlag:   rs.encoding,
+				repairNormalizeFlag:  rs.normalize,
+				repairStripFlag:      rs.strip,
+				repairVersionFlag:    rs.version,
+			}
 			for k, v := range searchSettings.Values() {
 				details[k] = v
 			}
 			LogCommandStart(o, repairCommandName, details)
-			allArtists, loaded := searchSettings.Load(o)
-			exitError = rs.ProcessArtists(o, allArtists, loaded, searchSettings)
+			if rs.FlagsCompatible(o) {
+				ctx := commandContext(cmd)
+				allArtists, loaded := searchSettings.Load(ctx, o)
+				exitError = rs.ProcessArtists(ctx, o, allArtists, loaded, searchSettings)
+			} else {
+				exitError = NewExitUserError(repairCommandName)
+			}
 		}
 	}
 	return ToErrorInterface(exitError)
 }
 
 type RepairSettings struct {
-	dryRun bool
+	createTags bool
+	dryRun     bool
+	encoding   string
+	normalize  bool
+	strip      bool
+	target     *files.Id3v2Target
+	version    int
 }
 
 func NewRepairSettings() *RepairSettings {
 	return &RepairSettings{}
 }
 
+func (rs *RepairSettings) WithCreateTags(b bool) *RepairSettings {
+	rs.createTags = b
+	return rs
+}
+
 func (rs *RepairSettings) WithDryRun(b bool) *RepairSettings {
 	rs.dryRun = b
 	return rs
 }
 
-func (rs *RepairSettings) ProcessArtists(o output.Bus, allArtists []*files.Artist,
-	loaded bool, ss *SearchSettings) (e *ExitError) {
+func (rs *RepairSettings) WithEncoding(s string) *RepairSettings {
+	rs.encoding = s
+	return rs
+}
+
+func (rs *RepairSettings) WithNormalize(b bool) *RepairSettings {
+	rs.normalize = b
+	return rs
+}
+
+func (rs *RepairSettings) WithStrip(b bool) *RepairSettings {
+	rs.strip = b
+	return rs
+}
+
+func (rs *RepairSettings) WithVersion(i int) *RepairSettings {
+	rs.version = i
+	return rs
+}
+
+// FlagsCompatible returns false if the flags ask for tags to be both created
+// and removed, or for tags to be normalized to a version and encoding that
+// cannot be written together
+func (rs *RepairSettings) FlagsCompatible(o output.Bus) bool {
+	if rs.createTags && rs.strip {
+		o.WriteCanonicalError("Repairs cannot be made")
+		o.WriteCanonicalError("Why?\nBoth %s and %s are set, but %s would remove the"+
+			" ID3V1 tags that %s creates", repairCreateTagsFlag, repairStripFlag,
+			repairStripFlag, repairCreateTagsFlag)
+		o.WriteCanonicalError("What to do:\nSet only one of %s and %s",
+			repairCreateTagsFlag, repairStripFlag)
+		return false
+	}
+	if rs.normalize {
+		target, err := files.NewId3v2Target(rs.version, rs.encoding)
+		if err != nil {
+			o.WriteCanonicalError("ID3V2 tags cannot be normalized")
+			o.Log(output.Error, "invalid normalization target", map[string]any{
+				repairVersionFlag:  rs.version,
+				repairEncodingFlag: rs.encoding,
+				"error":            err,
+			})
+			o.WriteCanonicalError("Why?\n%v", err)
+			o.WriteCanonicalError("What to do:\nSet %s to 3 or 4, and set %s to one of %s;"+
+				" ID3V2.3 tags can only use ISO-8859-1 or UTF-16", repairVersionFlag,
+				repairEncodingFlag, strings.Join(files.Id3v2EncodingNames(), ", "))
+			return false
+		}
+		rs.target = target
+	}
+	return true
+}
+
+func (rs *RepairSettings) ProcessArtists(ctx context.Context, o output.Bus,
+	allArtists []*files.Artist, loaded bool, ss *SearchSettings) (e *ExitError) {
 	e = NewExitUserError(repairCommandName)
 	if loaded {
 		if filteredArtists, filtered := ss.Filter(o, allArtists); filtered {
-			e = rs.RepairArtists(o, filteredArtists)
+			e = rs.RepairArtists(ctx, o, filteredArtists)
 		}
 	}
 	return
 }
 
-func (rs *RepairSettings) RepairArtists(o output.Bus, artists []*files.Artist) (e *ExitError) {
-	ReadMetadata(o, artists) // read all track metadata
+func (rs *RepairSettings) RepairArtists(ctx context.Context, o output.Bus,
+	artists []*files.Artist) (e *ExitError) {
+	if ReadMetadata(ctx, o, artists) != nil { // read all track metadata
+		return NewExitInterruptedError(repairCommandName)
+	}
 	concernedArtists := PrepareConcernedArtists(artists)
 	count := FindConflictedTracks(concernedArtists)
+	if rs.createTags {
+		count = FindMissingTags(concernedArtists)
+	}
+	if rs.normalize {
+		count = FindID3V2Normalizations(concernedArtists, rs.target)
+	}
+	if rs.strip {
+		count = FindRedundantTags(concernedArtists)
+	}
 	if rs.dryRun {
 		ReportRepairsNeeded(o, concernedArtists)
 	} else {
 		if count == 0 {
 			nothingToDo(o)
 		} else {
-			e = BackupAndFix(o, concernedArtists)
+			e = BackupAndFix(ctx, o, concernedArtists, rs.target)
 		}
 	}
 	return
@@ -123,6 +266,16 @@ func FindConflictedTracks(concernedArtists []*ConcernedArtist) int {
 						"the artist name field does not match the name of the artist"+
 							" directory")
 				}
+				if state.HasAlbumArtistConflict() {
+					cT.AddConcern(ConflictConcern,
+						"the album artist field does not match the name of the artist"+
+							" directory")
+				}
+				if state.HasCompilationConflict() {
+					cT.AddConcern(ConflictConcern,
+						"the compilation field is not set, but the artist directory"+
+							" holds compilations")
+				}
 				if state.HasAlbumNameConflict() {
 					cT.AddConcern(ConflictConcern,
 						"the album name field does not match the name of the album"+
@@ -145,6 +298,10 @@ func FindConflictedTracks(concernedArtists []*ConcernedArtist) int {
 					cT.AddConcern(ConflictConcern,
 						"the track name field does not match the track's file name")
 				}
+				if state.HasDiscConflict() {
+					cT.AddConcern(ConflictConcern,
+						"the disc number field does not match the track's disc")
+				}
 				if state.HasYearConflict() {
 					cT.AddConcern(ConflictConcern,
 						"the year field does not match the other tracks in the album")
@@ -158,6 +315,68 @@ func FindConflictedTracks(concernedArtists []*ConcernedArtist) int {
 	return count
 }
 
+// FindMissingTags adds a concern for each tag that a track file lacks and that
+// can be created; it returns the number of tracks with concerns of any kind
+func FindMissingTags(concernedArtists []*ConcernedArtist) int {
+	count := 0
+	for _, cAr := range concernedArtists {
+		for _, cAl := range cAr.Albums() {
+			for _, cT := range cAl.Tracks() {
+				for _, src := range cT.backing.MissingTags() {
+					cT.AddConcern(MissingTagConcern,
+						fmt.Sprintf("the %s tag is missing", src.Name()))
+				}
+				if cT.IsConcerned() {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// FindRedundantTags adds a concern for each tag that duplicates a track file's
+// ID3V2 tag; it returns the number of tracks with concerns of any kind
+func FindRedundantTags(concernedArtists []*ConcernedArtist) int {
+	count := 0
+	for _, cAr := range concernedArtists {
+		for _, cAl := range cAr.Albums() {
+			for _, cT := range cAl.Tracks() {
+				for _, tag := range cT.backing.RedundantTags() {
+					cT.AddConcern(RedundantTagConcern,
+						fmt.Sprintf("the %s tag will be removed", tag))
+				}
+				if cT.IsConcerned() {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// FindID3V2Normalizations adds a concern for each change that normalizing a
+// track file's ID3V2 tag would make; it returns the number of tracks with
+// concerns of any kind
+func FindID3V2Normalizations(concernedArtists []*ConcernedArtist,
+	target *files.Id3v2Target) int {
+	count := 0
+	for _, cAr := range concernedArtists {
+		for _, cAl := range cAr.Albums() {
+			for _, cT := range cAl.Tracks() {
+				changes, _ := cT.backing.ID3V2Normalization(target)
+				for _, change := range changes {
+					cT.AddConcern(NormalizationConcern, change)
+				}
+				if cT.IsConcerned() {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
 func ReportRepairsNeeded(o output.Bus, concernedArtists []*ConcernedArtist) {
 	artistNames := make([]string, 0, len(concernedArtists))
 	artistMap := map[string]*ConcernedArtist{}
@@ -188,22 +407,40 @@ func nothingToDo(o output.Bus) {
 	o.WriteCanonicalConsole("No repairable track defects were found.")
 }
 
-func BackupAndFix(o output.Bus, concernedArtists []*ConcernedArtist) (e *ExitError) {
+// BackupAndFix backs up and repairs each concerned track. When the context is
+// cancelled, the track being repaired is finished, so that no track is left
+// half-rewritten, and the remaining tracks are left alone; a summary of what was
+// done is then written.
+func BackupAndFix(ctx context.Context, o output.Bus, concernedArtists []*ConcernedArtist,
+	target *files.Id3v2Target) (e *ExitError) {
+	repaired, failed, skipped := 0, 0, 0
 	for _, cAr := range concernedArtists {
 		if cAr.IsConcerned() {
 			for _, cAl := range cAr.albums {
 				if cAl.IsConcerned() {
+					if ctx.Err() != nil {
+						skipped += countConcernedTracks(cAl)
+						continue
+					}
 					if path, exists := EnsureBackupDirectoryExists(o, cAl); exists {
 						for _, cT := range cAl.tracks {
 							if cT.IsConcerned() {
+								if ctx.Err() != nil {
+									skipped++
+									continue
+								}
 								t := cT.backing
 								if AttemptCopy(o, t, path) {
-									err := t.UpdateMetadata()
+									err := RepairTrack(cT, target)
 									if e2 := ProcessUpdateResult(o, t, err); e2 != nil {
 										e = e2
+										failed++
+									} else {
+										repaired++
 									}
 								} else {
 									e = NewExitSystemError(repairCommandName)
+									failed++
 								}
 							}
 						}
@@ -214,6 +451,56 @@ func BackupAndFix(o output.Bus, concernedArtists []*ConcernedArtist) (e *ExitErr
 			}
 		}
 	}
+	if ctx.Err() != nil {
+		o.WriteCanonicalError("The repair was interrupted: %d tracks were repaired, %d could"+
+			" not be repaired, and %d were not attempted", repaired, failed, skipped)
+		o.Log(output.Info, "repair interrupted", map[string]any{
+			"repaired": repaired,
+			"failed":   failed,
+			"skipped":  skipped,
+			"command":  repairCommandName,
+		})
+		e = NewExitInterruptedError(repairCommandName)
+	}
+	return
+}
+
+func countConcernedTracks(cAl *ConcernedAlbum) int {
+	count := 0
+	for _, cT := range cAl.tracks {
+		if cT.IsConcerned() {
+			count++
+		}
+	}
+	return count
+}
+
+// RepairTrack edits the track's conflicting metadata, creates its missing tags,
+// normalizes its ID3V2 tag, and removes its redundant tags; the ID3V2 tag is
+// normalized after it has been edited or created, and the redundant tags are
+// removed last, as editing the metadata may edit them, too
+func RepairTrack(cT *ConcernedTrack, target *files.Id3v2Target) (e []error) {
+	t := cT.backing
+	missingTags := len(cT.concerns[MissingTagConcern]) != 0
+	redundantTags := len(cT.concerns[RedundantTagConcern]) != 0
+	normalization := len(cT.concerns[NormalizationConcern]) != 0
+	if len(cT.concerns[ConflictConcern]) != 0 ||
+		(!missingTags && !redundantTags && !normalization) {
+		e = append(e, t.UpdateMetadata()...)
+	}
+	if missingTags {
+		e = append(e, t.CreateMissingTags()...)
+	}
+	if normalization {
+		if err := t.NormalizeID3V2(target); err != nil {
+			e = append(e, err)
+		}
+	}
+	if redundantTags {
+		if err := t.StripRedundantTags(); err != nil {
+			e = append(e, err)
+		}
+	}
 	return
 }
 
@@ -239,7 +526,12 @@ func ProcessUpdateResult(o output.Bus, t *files.Track, err []error) (e *ExitErro
 }
 
 func AttemptCopy(o output.Bus, t *files.Track, path string) (backedUp bool) {
-	backupFile := filepath.Join(path, fmt.Sprintf("%d.mp3", t.Number()))
+	backupName := fmt.Sprintf("%d%s", t.Number(), filepath.Ext(t.Path()))
+	if t.Disc() != 0 {
+		// each disc of a multi-disc album has its own track 1
+		backupName = fmt.Sprintf("%d-%s", t.Disc(), backupName)
+	}
+	backupFile := filepath.Join(path, backupName)
 	if PlainFileExists(backupFile) {
 		o.WriteCanonicalError("The backup file for track file %q, %q, already exists", t,
 			backupFile)
@@ -293,9 +585,24 @@ func ProcessRepairFlags(o output.Bus, values map[string]*FlagValue) (*RepairSett
 	rs := &RepairSettings{}
 	ok := true // optimistic
 	var err error
+	if rs.createTags, _, err = GetBool(o, values, repairCreateTags); err != nil {
+		ok = false
+	}
 	if rs.dryRun, _, err = GetBool(o, values, repairDryRun); err != nil {
 		ok = false
 	}
+	if rs.encoding, _, err = GetString(o, values, repairEncoding); err != nil {
+		ok = false
+	}
+	if rs.normalize, _, err = GetBool(o, values, repairNormalize); err != nil {
+		ok = false
+	}
+	if rs.strip, _, err = GetBool(o, values, repairStrip); err != nil {
+		ok = false
+	}
+	if rs.version, _, err = GetInt(o, values, repairVersion); err != nil {
+		ok = false
+	}
 	return rs, ok
 }
 
@@ -4,12 +4,16 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd_test
 
 import (
+	"context"
 	"fmt"
 	"mp3/cmd"
 	"mp3/internal/files"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
@@ -28,18 +32,52 @@ func TestProcessRepairFlags(t *testing.T) {
 			want:   cmd.NewRepairSettings(),
 			want1:  false,
 			WantedRecording: output.WantedRecording{
-				Error: "An internal error occurred: flag \"dryRun\" is not found.\n",
+				Error: "" +
+					"An internal error occurred: flag \"createTags\" is not found.\n" +
+					"An internal error occurred: flag \"dryRun\" is not found.\n" +
+					"An internal error occurred: flag \"id3v2Encoding\" is not found.\n" +
+					"An internal error occurred: flag \"normalize\" is not found.\n" +
+					"An internal error occurred: flag \"strip\" is not found.\n" +
+					"An internal error occurred: flag \"id3v2Version\" is not found.\n",
 				Log: "" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='createTags'" +
+					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
 					" flag='dryRun'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='id3v2Encoding'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='normalize'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='strip'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='id3v2Version'" +
 					" msg='internal error'\n",
 			},
 		},
 		"good value": {
-			values: map[string]*cmd.FlagValue{"dryRun": cmd.NewFlagValue().WithValue(true)},
-			want:   cmd.NewRepairSettings().WithDryRun(true),
-			want1:  true,
+			values: map[string]*cmd.FlagValue{
+				"createTags":    cmd.NewFlagValue().WithValue(true),
+				"dryRun":        cmd.NewFlagValue().WithValue(true),
+				"id3v2Encoding": cmd.NewFlagValue().WithValue("UTF-16"),
+				"normalize":     cmd.NewFlagValue().WithValue(true),
+				"strip":         cmd.NewFlagValue().WithValue(false),
+				"id3v2Version":  cmd.NewFlagValue().WithValue(3),
+			},
+			want: cmd.NewRepairSettings().WithCreateTags(true).WithDryRun(true).WithEncoding(
+				"UTF-16").WithNormalize(true).WithVersion(3),
+			want1: true,
 		},
 	}
 	for name, tt := range tests {
@@ -129,7 +167,7 @@ func TestEnsureBackupDirectoryExists(t *testing.T) {
 				t.Errorf("EnsureBackupDirectoryExists() gotExists = %v, want %v",
 					gotExists, tt.wantExists)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("EnsureBackupDirectoryExists() %s", difference)
 				}
@@ -213,6 +251,17 @@ func TestAttemptCopy(t *testing.T) {
 					" has been backed up to \"backupDir\\\\1.mp3\".\n",
 			},
 		},
+		"successful backup of a track on a disc": {
+			plainFileExists: func(_ string) bool { return false },
+			copyFile:        func(_, _ string) error { return nil },
+			args:            args{t: track.Copy(track.Album()).WithDisc(2), path: "backupDir"},
+			wantBackedUp:    true,
+			WantedRecording: output.WantedRecording{
+				Console: "The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\"" +
+					" has been backed up to \"backupDir\\\\2-1.mp3\".\n",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -223,7 +272,7 @@ func TestAttemptCopy(t *testing.T) {
 				tt.wantBackedUp {
 				t.Errorf("AttemptCopy() = %v, want %v", gotBackedUp, tt.wantBackedUp)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("AttemptCopy() %s", difference)
 				}
@@ -310,7 +359,7 @@ func TestProcessUpdateResult(t *testing.T) {
 			if got := markedDirty; got != tt.wantDirty {
 				t.Errorf("ProcessUpdateResult() got %t want %t", got, tt.wantDirty)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ProcessUpdateResult() %s", difference)
 				}
@@ -337,7 +386,10 @@ func TestBackupAndFix(t *testing.T) {
 		cmd.PlainFileExists = originalPlainFileExists
 		cmd.CopyFile = originalCopyFile
 	}()
+	// the first track to be backed up is interrupted, but is still repaired
+	interrupted, interrupt := context.WithCancel(context.Background())
 	tests := map[string]struct {
+		ctx              context.Context
 		dirExists        func(string) bool
 		plainFileExists  func(string) bool
 		copyFile         func(string, string) error
@@ -345,7 +397,44 @@ func TestBackupAndFix(t *testing.T) {
 		wantStatus       *cmd.ExitError
 		output.WantedRecording
 	}{
+		"interrupted": {
+			ctx:             interrupted,
+			dirExists:       func(_ string) bool { return true },
+			plainFileExists: func(_ string) bool { return false },
+			copyFile: func(_, _ string) error {
+				interrupt()
+				return nil
+			},
+			concernedArtists: concernedArtists,
+			wantStatus:       cmd.NewExitInterruptedError("repair"),
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The track file" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\"" +
+					" has been backed up to" +
+					" \"Music\\\\my artist\\\\my album 00\\\\pre-repair-backup\\\\1.mp3\".\n",
+				Error: "" +
+					"An error occurred repairing track" +
+					" \"Music\\\\my artist\\\\my album 00\\\\1 my track 001.mp3\".\n" +
+					"The repair was interrupted: 0 tracks were repaired, 1 could not be" +
+					" repaired, and 23 were not attempted.\n",
+				Log: "" +
+					"level='error'" +
+					" command='repair'" +
+					" directory='Music\\my artist\\my album 00'" +
+					" error='[\"no edit required\"]'" +
+					" fileName='1 my track 001.mp3'" +
+					" msg='cannot edit track'\n" +
+					"level='info'" +
+					" command='repair'" +
+					" failed='1'" +
+					" repaired='0'" +
+					" skipped='23'" +
+					" msg='repair interrupted'\n",
+			},
+		},
 		"basic test": {
+			ctx:              context.Background(),
 			dirExists:        func(_ string) bool { return true },
 			plainFileExists:  func(_ string) bool { return false },
 			copyFile:         func(_, _ string) error { return nil },
@@ -646,6 +735,7 @@ func TestBackupAndFix(t *testing.T) {
 			},
 		},
 		"basic test2": {
+			ctx:              context.Background(),
 			dirExists:        func(_ string) bool { return false },
 			plainFileExists:  func(_ string) bool { return false },
 			copyFile:         func(_, _ string) error { return nil },
@@ -734,6 +824,7 @@ func TestBackupAndFix(t *testing.T) {
 			},
 		},
 		"basic test3": {
+			ctx:              context.Background(),
 			dirExists:        func(_ string) bool { return true },
 			plainFileExists:  func(_ string) bool { return false },
 			copyFile:         func(_, _ string) error { return fmt.Errorf("oops") },
@@ -1039,10 +1130,11 @@ func TestBackupAndFix(t *testing.T) {
 			cmd.PlainFileExists = tt.plainFileExists
 			cmd.CopyFile = tt.copyFile
 			o := output.NewRecorder()
-			if got := cmd.BackupAndFix(o, tt.concernedArtists); !compareExitErrors(got, tt.wantStatus) {
+			if got := cmd.BackupAndFix(tt.ctx, o, tt.concernedArtists,
+				nil); !compareExitErrors(got, tt.wantStatus) {
 				t.Errorf("BackupAndFix() got %s want %s", got, tt.wantStatus)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("BackupAndFix() %s", difference)
 				}
@@ -1182,6 +1274,298 @@ func TestFindConflictedTracks(t *testing.T) {
 	}
 }
 
+// generateTaggableArtist creates an artist with an album of track files in
+// dir: the first lacks both ID3V1 and ID3V2 tags, the second has both, though
+// its ID3V2 tag cannot be read, and the third has both, with a readable ID3V2
+// tag
+func generateTaggableArtist(t *testing.T, dir string) *files.Artist {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x40})
+	id3v1Tag := append([]byte("TAG"), make([]byte, 125)...)
+	tagged := append([]byte("ID3"), make([]byte, 7)...)
+	tagged = append(tagged, frame...)
+	tagged = append(tagged, id3v1Tag...)
+	// an ID3V2.3.0 tag holding a single TRCK frame
+	readable := []byte("ID3\x03\x00\x00\x00\x00\x00\x0cTRCK\x00\x00\x00\x02\x00\x00\x001")
+	readable = append(readable, frame...)
+	readable = append(readable, id3v1Tag...)
+	contents := map[string][]byte{
+		"1 untagged.mp3":  frame,
+		"2 tagged.mp3":    tagged,
+		"3 redundant.mp3": readable,
+	}
+	for name, content := range contents {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Errorf("cannot create %q: %v", name, err)
+		}
+	}
+	artist := files.NewArtist("my artist", filepath.Dir(dir))
+	album := files.NewAlbum("my album", artist, dir).WithCanonicalGenre(
+		"Rock").WithCanonicalYear("1999")
+	album.AddTrack(files.NewTrack(album, "1 untagged.mp3", "untagged", 1))
+	album.AddTrack(files.NewTrack(album, "2 tagged.mp3", "tagged", 2))
+	album.AddTrack(files.NewTrack(album, "3 redundant.mp3", "redundant", 3))
+	artist.AddAlbum(album)
+	return artist
+}
+
+func TestFindMissingTags(t *testing.T) {
+	clean := cmd.PrepareConcernedArtists(generateArtists(2, 3, 4))
+	untagged := cmd.PrepareConcernedArtists(
+		[]*files.Artist{generateTaggableArtist(t, t.TempDir())})
+	tests := map[string]struct {
+		concernedArtists []*cmd.ConcernedArtist
+		want             int
+		output.WantedRecording
+	}{
+		"clean": {
+			concernedArtists: clean,
+			want:             0,
+			WantedRecording: output.WantedRecording{
+				Console: "No repairable track defects were found.\n",
+			},
+		},
+		"untagged": {
+			concernedArtists: untagged,
+			want:             1,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The following concerns can be repaired:\n" +
+					"Artist \"my artist\"\n" +
+					"  Album \"my album\"\n" +
+					"    Track \"untagged\"\n" +
+					"    * [missing tag] the ID3V1 tag is missing\n" +
+					"    * [missing tag] the ID3V2 tag is missing\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmd.FindMissingTags(tt.concernedArtists); got != tt.want {
+				t.Errorf("FindMissingTags() = %v, want %v", got, tt.want)
+			}
+			o := output.NewRecorder()
+			cmd.ReportRepairsNeeded(o, tt.concernedArtists)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("FindMissingTags() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestFindRedundantTags(t *testing.T) {
+	clean := cmd.PrepareConcernedArtists(generateArtists(2, 3, 4))
+	redundant := cmd.PrepareConcernedArtists(
+		[]*files.Artist{generateTaggableArtist(t, t.TempDir())})
+	tests := map[string]struct {
+		concernedArtists []*cmd.ConcernedArtist
+		want             int
+		output.WantedRecording
+	}{
+		"clean": {
+			concernedArtists: clean,
+			want:             0,
+			WantedRecording: output.WantedRecording{
+				Console: "No repairable track defects were found.\n",
+			},
+		},
+		"redundant": {
+			concernedArtists: redundant,
+			want:             1,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The following concerns can be repaired:\n" +
+					"Artist \"my artist\"\n" +
+					"  Album \"my album\"\n" +
+					"    Track \"redundant\"\n" +
+					"    * [redundant tag] the ID3V1 tag will be removed\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmd.FindRedundantTags(tt.concernedArtists); got != tt.want {
+				t.Errorf("FindRedundantTags() = %v, want %v", got, tt.want)
+			}
+			o := output.NewRecorder()
+			cmd.ReportRepairsNeeded(o, tt.concernedArtists)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("FindRedundantTags() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestFindID3V2Normalizations(t *testing.T) {
+	clean := cmd.PrepareConcernedArtists(generateArtists(2, 3, 4))
+	unnormalized := cmd.PrepareConcernedArtists(
+		[]*files.Artist{generateTaggableArtist(t, t.TempDir())})
+	target, _ := files.NewId3v2Target(4, "UTF-8")
+	tests := map[string]struct {
+		concernedArtists []*cmd.ConcernedArtist
+		want             int
+		output.WantedRecording
+	}{
+		"clean": {
+			concernedArtists: clean,
+			want:             0,
+			WantedRecording: output.WantedRecording{
+				Console: "No repairable track defects were found.\n",
+			},
+		},
+		"unnormalized": {
+			concernedArtists: unnormalized,
+			want:             1,
+			WantedRecording: output.WantedRecording{
+				Console: "" +
+					"The following concerns can be repaired:\n" +
+					"Artist \"my artist\"\n" +
+					"  Album \"my album\"\n" +
+					"    Track \"redundant\"\n" +
+					"    * [normalization] the ID3V2 tag will be converted from version 2.3 to" +
+					" version 2.4\n" +
+					"    * [normalization] the TRCK frame will be re-encoded from ISO-8859-1 to" +
+					" UTF-8\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmd.FindID3V2Normalizations(tt.concernedArtists, target); got != tt.want {
+				t.Errorf("FindID3V2Normalizations() = %v, want %v", got, tt.want)
+			}
+			o := output.NewRecorder()
+			cmd.ReportRepairsNeeded(o, tt.concernedArtists)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("FindID3V2Normalizations() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestRepairSettings_FlagsCompatible(t *testing.T) {
+	tests := map[string]struct {
+		rs   *cmd.RepairSettings
+		want bool
+		output.WantedRecording
+	}{
+		"neither": {rs: cmd.NewRepairSettings(), want: true},
+		"create":  {rs: cmd.NewRepairSettings().WithCreateTags(true), want: true},
+		"strip":   {rs: cmd.NewRepairSettings().WithStrip(true), want: true},
+		"normalize": {
+			rs: cmd.NewRepairSettings().WithNormalize(true).WithVersion(3).WithEncoding(
+				"UTF-16"),
+			want: true,
+		},
+		"normalize with unusable encoding": {
+			rs: cmd.NewRepairSettings().WithNormalize(true).WithVersion(3).WithEncoding(
+				"UTF-8"),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"ID3V2 tags cannot be normalized.\n" +
+					"Why?\n" +
+					"ID3V2.3 tags cannot use the UTF-8 encoding.\n" +
+					"What to do:\n" +
+					"Set --id3v2Version to 3 or 4, and set --id3v2Encoding to one of" +
+					" ISO-8859-1, UTF-16, UTF-16BE, UTF-8; ID3V2.3 tags can only use" +
+					" ISO-8859-1 or UTF-16.\n",
+				Log: "" +
+					"level='error'" +
+					" --id3v2Encoding='UTF-8'" +
+					" --id3v2Version='3'" +
+					" error='ID3V2.3 tags cannot use the UTF-8 encoding'" +
+					" msg='invalid normalization target'\n",
+			},
+		},
+		"both": {
+			rs:   cmd.NewRepairSettings().WithCreateTags(true).WithStrip(true),
+			want: false,
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"Repairs cannot be made.\n" +
+					"Why?\n" +
+					"Both --createTags and --strip are set, but --strip would remove the" +
+					" ID3V1 tags that --createTags creates.\n" +
+					"What to do:\n" +
+					"Set only one of --createTags and --strip.\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if got := tt.rs.FlagsCompatible(o); got != tt.want {
+				t.Errorf("RepairSettings.FlagsCompatible() = %v, want %v", got, tt.want)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("RepairSettings.FlagsCompatible() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestRepairTrack(t *testing.T) {
+	artist := generateTaggableArtist(t, t.TempDir())
+	concernedArtists := cmd.PrepareConcernedArtists([]*files.Artist{artist})
+	cmd.FindMissingTags(concernedArtists)
+	cmd.FindRedundantTags(concernedArtists)
+	cTs := concernedArtists[0].Albums()[0].Tracks()
+	target, _ := files.NewId3v2Target(4, "UTF-8")
+	normalizable := cmd.PrepareConcernedArtists(
+		[]*files.Artist{generateTaggableArtist(t, t.TempDir())})
+	cmd.FindID3V2Normalizations(normalizable, target)
+	// creating an ID3V1 tag makes it redundant, and removing a redundant ID3V1
+	// tag leaves it missing
+	tests := map[string]struct {
+		cT                *cmd.ConcernedTrack
+		target            *files.Id3v2Target
+		wantErrs          []error
+		wantMissingTags   int
+		wantRedundantTags int
+	}{
+		"missing tags": {cT: cTs[0], wantRedundantTags: 1},
+		"nothing to do": {
+			cT:       cTs[1],
+			wantErrs: []error{files.ErrNoEditNeeded},
+		},
+		"redundant tags": {cT: cTs[2], wantMissingTags: 1},
+		"normalization": {
+			cT:                normalizable[0].Albums()[0].Tracks()[2],
+			target:            target,
+			wantRedundantTags: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := cmd.RepairTrack(tt.cT, tt.target); !reflect.DeepEqual(got, tt.wantErrs) {
+				t.Errorf("RepairTrack() = %v, want %v", got, tt.wantErrs)
+			}
+			if got := tt.cT.Track().MissingTags(); len(got) != tt.wantMissingTags {
+				t.Errorf("RepairTrack() left %d tags missing, want %d", len(got),
+					tt.wantMissingTags)
+			}
+			if got := tt.cT.Track().RedundantTags(); len(got) != tt.wantRedundantTags {
+				t.Errorf("RepairTrack() left %d redundant tags, want %d", len(got),
+					tt.wantRedundantTags)
+			}
+			if tt.target != nil {
+				if got, _ := tt.cT.Track().ID3V2Normalization(tt.target); len(got) != 0 {
+					t.Errorf("RepairTrack() left normalizations %v", got)
+				}
+			}
+		})
+	}
+}
+
 func TestRepairSettings_RepairArtists(t *testing.T) {
 	originalReadMetadata := cmd.ReadMetadata
 	originalDirExists := cmd.DirExists
@@ -1195,7 +1579,7 @@ func TestRepairSettings_RepairArtists(t *testing.T) {
 		cmd.CopyFile = originalCopyFile
 		cmd.MarkDirty = originalMarkDirty
 	}()
-	cmd.ReadMetadata = func(_ output.Bus, _ []*files.Artist) {}
+	cmd.ReadMetadata = func(_ context.Context, _ output.Bus, _ []*files.Artist) error { return nil }
 	cmd.DirExists = func(_ string) bool { return true }
 	cmd.PlainFileExists = func(_ string) bool { return false }
 	cmd.CopyFile = func(_, _ string) error { return nil }
@@ -1886,10 +2270,11 @@ func TestRepairSettings_RepairArtists(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			if got := tt.rs.RepairArtists(o, tt.artists); !compareExitErrors(got, tt.wantStatus) {
+			if got := tt.rs.RepairArtists(context.Background(), o,
+				tt.artists); !compareExitErrors(got, tt.wantStatus) {
 				t.Errorf("RepairSettings.RepairArtists() got %s want %s", got, tt.wantStatus)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("RepairSettings.RepairArtists() %s", difference)
 				}
@@ -1903,7 +2288,7 @@ func TestRepairSettings_ProcessArtists(t *testing.T) {
 	defer func() {
 		cmd.ReadMetadata = originalReadMetadata
 	}()
-	cmd.ReadMetadata = func(_ output.Bus, _ []*files.Artist) {}
+	cmd.ReadMetadata = func(_ context.Context, _ output.Bus, _ []*files.Artist) error { return nil }
 	type args struct {
 		allArtists []*files.Artist
 		loaded     bool
@@ -1940,7 +2325,8 @@ func TestRepairSettings_ProcessArtists(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
 			if got := tt.rs.ProcessArtists(
-				o, tt.args.allArtists, tt.args.loaded, tt.args.ss); !compareExitErrors(got, tt.wantStatus) {
+				context.Background(), o, tt.args.allArtists, tt.args.loaded,
+				tt.args.ss); !compareExitErrors(got, tt.wantStatus) {
 				t.Errorf("RepairSettings.ProcessArtists() got %s want %s", got, tt.wantStatus)
 			}
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
@@ -1955,17 +2341,38 @@ func TestRepairSettings_ProcessArtists(t *testing.T) {
 func TestRepairRun(t *testing.T) {
 	cmd.InitGlobals()
 	originalBus := cmd.Bus
+	originalSince := cmd.Since
 	originalSearchFlags := cmd.SearchFlags
 	defer func() {
 		cmd.Bus = originalBus
+		cmd.Since = originalSince
 		cmd.SearchFlags = originalSearchFlags
 	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
 	cmd.SearchFlags = safeSearchFlags
 	repairFlags := cmd.NewSectionFlags().WithSectionName("repair").WithFlags(
 		map[string]*cmd.FlagDetails{
+			"createTags": cmd.NewFlagDetails().WithUsage(
+				"create missing ID3V1 and ID3V2 tags",
+			).WithExpectedType(cmd.BoolType).WithDefaultValue(false),
 			"dryRun": cmd.NewFlagDetails().WithUsage(
 				"output what would have been repaired, but make no" +
 					" repairs").WithExpectedType(cmd.BoolType).WithDefaultValue(false),
+			"id3v2Encoding": cmd.NewFlagDetails().WithUsage(
+				"text encoding of normalized ID3V2 tags",
+			).WithExpectedType(cmd.StringType).WithDefaultValue("UTF-8"),
+			"id3v2Version": cmd.NewFlagDetails().WithUsage(
+				"ID3V2 version (3 or 4) of normalized ID3V2 tags",
+			).WithExpectedType(cmd.IntType).WithDefaultValue(
+				cmd_toolkit.NewIntBounds(3, 4, 4)),
+			"normalize": cmd.NewFlagDetails().WithUsage(
+				"rewrite ID3V2 tags in one version and text encoding",
+			).WithExpectedType(cmd.BoolType).WithDefaultValue(false),
+			"strip": cmd.NewFlagDetails().WithUsage(
+				"remove ID3V1, APEv2, and Lyrics3 tags from mp3 files with a readable" +
+					" ID3V2 tag").WithExpectedType(cmd.BoolType).WithDefaultValue(false),
 		},
 	)
 	command := &cobra.Command{}
@@ -1990,12 +2397,38 @@ func TestRepairRun(t *testing.T) {
 					"level='info'" +
 					" --albumFilter='.*'" +
 					" --artistFilter='.*'" +
+					" --bitrateRange=''" +
+					" --compilations='[Various Artists]'" +
+					" --concurrency='20'" +
+					" --createTags='false'" +
 					" --dryRun='false'" +
+					" --durationRange=''" +
 					" --extensions='[.mp3]'" +
+					" --genreFilter=''" +
+					" --id3Versions='[]'" +
+					" --id3v2Encoding='UTF-8'" +
+					" --id3v2Version='4'" +
+					" --ignore=''" +
+					" --layout='{artist}/{album}/{track} {title}'" +
+					" --metadataCache='use'" +
+					" --metadataPriority='[ID3V2 APEV2 ID3V1]'" +
+					" --normalize='false'" +
+					" --strip='false'" +
+					" --tagAlbumFilter=''" +
+					" --tagArtistFilter=''" +
+					" --tagTitleFilter=''" +
 					" --topDir='.'" +
 					" --trackFilter='.*'" +
+					" --trackNames='[]'" +
+					" --where=''" +
+					" --yearRange=''" +
 					" command='repair'" +
 					" msg='executing command'\n" +
+					"level='info'" +
+					" --topDir='.'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
 					"level='error'" +
 					" --topDir='.'" +
 					" msg='cannot find any artist directories'\n",
@@ -2043,23 +2476,76 @@ func TestRepairHelp(t *testing.T) {
 					" automatically delete\n" +
 					"the backup folders.\n" +
 					"\n" +
+					"If --createTags is set, the command also adds an ID3V1 tag or an ID3V2" +
+					" tag to each\n" +
+					"mp3 file that lacks one, filling it in from the file structure and from" +
+					" the genre\n" +
+					"and year of the other tracks in the album.\n" +
+					"\n" +
+					"If --strip is set, the command also removes the ID3V1, APEv2, and" +
+					" Lyrics3 tags\n" +
+					"from each mp3 file whose ID3V2 tag can be read.\n" +
+					"\n" +
+					"If --normalize is set, the command also rewrites each ID3V2 tag in the" +
+					" version set by\n" +
+					"--id3v2Version, with all of its text in the encoding set by" +
+					" --id3v2Encoding. Frames\n" +
+					"that cannot be represented in that version or encoding are removed; the" +
+					" TYER, TDAT,\n" +
+					"and TIME frames of ID3V2.3 become the TDRC frame of ID3V2.4, and vice" +
+					" versa.\n" +
+					"\n" +
 					"Usage:\n" +
-					"  repair [--dryRun] [--albumFilter regex] [--artistFilter regex]" +
-					" [--trackFilter regex] [--topDir dir] [--extensions extensions]\n" +
+					"  repair [--dryRun] [--createTags | --strip] [--normalize [--id3v2Version 3|4]" +
+					" [--id3v2Encoding encoding]] [--albumFilter regex] [--artistFilter regex]" +
+					" [--trackFilter regex] [--topDir dir] [--extensions extensions] [--metadataPriority sources] [--compilations names] [--layout template] [--trackNames patterns] [--metadataCache mode] [--concurrency count] [--ignore patterns] [--genreFilter regex] [--tagArtistFilter regex] [--tagAlbumFilter regex] [--tagTitleFilter regex] [--yearRange range] [--bitrateRange range] [--durationRange range] [--id3Versions versions] [--where expression]\n" +
+					"\n" +
+					"Examples:\n" +
+					"repair --dryRun --strip\n" +
+					"  lists the tags that would be removed from each mp3 file\n" +
+					"repair --normalize --id3v2Version 3 --id3v2Encoding UTF-16\n" +
+					"  rewrites each ID3V2 tag as version 2.3, with its text encoded in UTF-16\n" +
 					"\n" +
 					"Flags:\n" +
-					"      --albumFilter string    " +
+					"      --albumFilter string        " +
 					"regular expression specifying which albums to select (default \".*\")\n" +
-					"      --artistFilter string   " +
+					"      --artistFilter string       " +
 					"regular expression specifying which artists to select (default \".*\")\n" +
-					"      --dryRun                " +
+					"      --bitrateRange string       range of bitrates, in kbps, to select, such as 192-320; empty selects all (default \"\")\n" +
+					"      --compilations string       comma-delimited list of artist directory names that hold compilations (default \"Various Artists\")\n" +
+					"      --concurrency int           maximum number of track files read at the same time (minimum 1, maximum 100) (default 20)\n" +
+					"      --createTags                " +
+					"create missing ID3V1 and ID3V2 tags (default false)\n" +
+					"      --dryRun                    " +
 					"output what would have been repaired, but make no repairs (default false)\n" +
-					"      --extensions string     " +
+					"      --durationRange string      range of track durations to select, such as 2m-10m30s; empty selects all (default \"\")\n" +
+					"      --extensions string         " +
 					"comma-delimited list of file extensions used by mp3 files (default \".mp3\")\n" +
-					"      --topDir string         " +
-					"top directory specifying where to find mp3 files (default \".\")\n" +
-					"      --trackFilter string    " +
-					"regular expression specifying which tracks to select (default \".*\")\n",
+					"      --genreFilter string        regular expression specifying which genres, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --id3Versions string        comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects tracks without an ID3V2 tag; empty selects all (default \"\")\n" +
+					"      --id3v2Encoding string      " +
+					"text encoding of normalized ID3V2 tags: one of ISO-8859-1, UTF-16, UTF-16BE, UTF-8 (default \"UTF-8\")\n" +
+					"      --id3v2Version int          " +
+					"ID3V2 version (3 or 4) of normalized ID3V2 tags (default 4)\n" +
+					"      --ignore string             newline-delimited list of patterns, written as in .mp3ignore files, naming what to ignore below the top directory (default \"\")\n" +
+					"      --layout string             template describing the directories below the top directory (default \"{artist}/{album}/{track} {title}\")\n" +
+					"      --metadataCache string      how to use the cache of track metadata: use, bypass, rebuild (default \"use\")\n" +
+					"      --metadataPriority string   " +
+					"comma-delimited list of metadata sources, in order of preference (default \"ID3V2,APEV2,ID3V1\")\n" +
+					"      --normalize                 " +
+					"rewrite ID3V2 tags in one version and text encoding (default false)\n" +
+					"      --strip                     " +
+					"remove ID3V1, APEv2, and Lyrics3 tags from mp3 files with a readable ID3V2 tag (default false)\n" +
+					"      --tagAlbumFilter string     regular expression specifying which album names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagArtistFilter string    regular expression specifying which artist names, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --tagTitleFilter string     regular expression specifying which track titles, read from the track metadata, to select; empty selects all (default \"\")\n" +
+					"      --topDir string             " +
+					"top directory, or list of top directories, specifying where to find mp3 files (default \".\")\n" +
+					"      --trackFilter string        " +
+					"regular expression specifying which tracks to select (default \".*\")\n" +
+					"      --trackNames string         newline-delimited list of regular expressions for parsing track file names; empty selects the built-in expressions (default \"\")\n" +
+					"      --where string              boolean expression specifying which tracks to select, such as 'year >= 1990 and not hasConcern(numbering)'; empty selects all (default \"\")\n" +
+					"      --yearRange string          range of years, read from the track metadata, to select, such as 1970-1979, 1990-, or -1979; empty selects all (default \"\")\n",
 			},
 		},
 	}
@@ -7,16 +7,11 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
-	"reflect"
-	"slices"
 	"strings"
-	"time"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
 	"github.com/spf13/cobra"
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
 )
 
 const (
@@ -81,8 +76,7 @@ changes, unless the ` + resetDBForceFlag + ` flag is set.`,
 				StringType).WithDefaultValue("WMPNetworkSVC"),
 			resetDBMetadataDir: NewFlagDetails().WithUsage(
 				"directory where the media player service metadata files are stored").WithExpectedType(
-				StringType).WithDefaultValue(
-				filepath.Join("%USERPROFILE%", "AppData", "Local", "Microsoft", "Media Player")),
+				StringType).WithDefaultValue(defaultMetadataDir),
 			resetDBExtension: NewFlagDetails().WithUsage(
 				"extension for metadata files").WithExpectedType(
 				StringType).WithDefaultValue(".wmdb"),
@@ -96,15 +90,6 @@ changes, unless the ` + resetDBForceFlag + ` flag is set.`,
 					" metadata files").WithExpectedType(BoolType).WithDefaultValue(false),
 		},
 	)
-	stateToStatus = map[svc.State]string{
-		svc.Stopped:         "stopped",
-		svc.StartPending:    "start pending",
-		svc.StopPending:     "stop pending",
-		svc.Running:         "running",
-		svc.ContinuePending: "continue pending",
-		svc.PausePending:    "pause pending",
-		svc.Paused:          "paused",
-	}
 )
 
 func ResetDBExec(cmd *cobra.Command, _ []string) error {
@@ -205,218 +190,6 @@ func MaybeClearDirty(o output.Bus, e *ExitError) {
 	}
 }
 
-type ServiceManager interface {
-	Disconnect() error
-	OpenService(name string) (*mgr.Service, error)
-	ListServices() ([]string, error)
-}
-
-type ServiceRep interface {
-	Close() error
-	Control(c svc.Cmd) (svc.Status, error)
-	Query() (svc.Status, error)
-}
-
-func openService(manager ServiceManager, serviceName string) (rep ServiceRep, err error) {
-	if manager == nil || reflect.ValueOf(manager).IsNil() {
-		err = fmt.Errorf("nil manager")
-	} else {
-		rep, err = manager.OpenService(serviceName)
-	}
-	return
-}
-
-func (rdbs *ResetDBSettings) StopService(o output.Bus) (ok bool, e *ExitError) {
-	var manager ServiceManager
-	var err error
-	if manager, err = Connect(); err != nil {
-		e = NewExitSystemError(resetDBCommandName)
-		o.WriteCanonicalError("An attempt to connect with the service manager failed; error"+
-			" is %v", err)
-		o.WriteCanonicalError("Why?\nThis often fails due to lack of permissions")
-		o.WriteCanonicalError("What to do:\n" +
-			"If you can, try running this command as an administrator.")
-		o.Log(output.Error, "service manager connect failed", map[string]any{"error": err})
-	} else {
-		ok, e = rdbs.HandleService(o, manager)
-	}
-	return
-}
-
-func listServices(manager ServiceManager) ([]string, error) {
-	if manager == nil || reflect.ValueOf(manager).IsNil() {
-		return nil, fmt.Errorf("nil manager")
-	}
-	return manager.ListServices()
-}
-
-func (rdbs *ResetDBSettings) HandleService(o output.Bus, manager ServiceManager) (ok bool,
-	e *ExitError) {
-	if service, serviceError := openService(manager, rdbs.service); serviceError != nil {
-		e = NewExitSystemError(resetDBCommandName)
-		o.WriteCanonicalError("The service %q cannot be opened: %v", rdbs.service,
-			serviceError)
-		o.Log(output.Error, "service problem", map[string]any{
-			"service": rdbs.service,
-			"trigger": "OpenService",
-			"error":   serviceError,
-		})
-		if serviceList, listError := listServices(manager); listError != nil {
-			o.Log(output.Error, "service problem", map[string]any{
-				"trigger": "ListServices",
-				"error":   listError,
-			})
-		} else {
-			ListServices(o, manager, serviceList)
-		}
-		disconnectManager(manager)
-	} else {
-		ok, e = rdbs.StopFoundService(o, manager, service)
-	}
-	return
-}
-
-func disconnectManager(manager ServiceManager) {
-	if !reflect.ValueOf(manager).IsNil() && manager != nil {
-		_ = manager.Disconnect()
-	}
-}
-
-func ListServices(o output.Bus, manager ServiceManager, services []string) {
-	o.WriteError("The following services are available:\n")
-	if len(services) == 0 {
-		o.WriteError("  - none -\n")
-	} else {
-		slices.Sort(services)
-		m := map[string][]string{}
-		for _, serviceName := range services {
-			if s, err := openService(manager, serviceName); err == nil {
-				AddServiceState(m, s, serviceName)
-				closeService(s)
-			} else {
-				e := err.Error()
-				m[e] = append(m[e], serviceName)
-			}
-		}
-		states := make([]string, 0, len(m))
-		for k := range m {
-			states = append(states, k)
-		}
-		slices.Sort(states)
-		for _, state := range states {
-			o.WriteError("  State %q:\n", state)
-			for _, svc := range m[state] {
-				o.WriteError("    %q\n", svc)
-			}
-		}
-	}
-}
-
-func AddServiceState(m map[string][]string, s ServiceRep, serviceName string) {
-	if status, err := runQuery(s); err == nil {
-		key := stateToStatus[status.State]
-		m[key] = append(m[key], serviceName)
-	} else {
-		e := err.Error()
-		m[e] = append(m[e], serviceName)
-	}
-}
-
-func runQuery(s ServiceRep) (status svc.Status, err error) {
-	if reflect.ValueOf(s).IsNil() {
-		status, err = svc.Status{}, fmt.Errorf("no service")
-	} else {
-		status, err = s.Query()
-	}
-	return
-}
-
-func closeService(s ServiceRep) {
-	if !reflect.ValueOf(s).IsNil() {
-		_ = s.Close()
-	}
-}
-
-func (rdbs *ResetDBSettings) StopFoundService(o output.Bus, manager ServiceManager,
-	service ServiceRep) (ok bool, e *ExitError) {
-	defer func() {
-		_ = manager.Disconnect()
-		closeService(service)
-	}()
-	if status, err := runQuery(service); err != nil {
-		e = NewExitSystemError(resetDBCommandName)
-		o.WriteCanonicalError("An error occurred while trying to stop service %q: %v",
-			rdbs.service, err)
-		rdbs.ReportServiceQueryError(o, err)
-	} else {
-		if status.State == svc.Stopped {
-			rdbs.ReportServiceStopped(o)
-			ok = true
-		} else if status, err = service.Control(svc.Stop); err == nil {
-			if status.State == svc.Stopped {
-				rdbs.ReportServiceStopped(o)
-				ok = true
-			} else {
-				timeout := time.Now().Add(time.Duration(rdbs.timeout) * time.Second)
-				ok, e = rdbs.WaitForStop(o, service, timeout, 100*time.Millisecond)
-			}
-		} else {
-			e = NewExitSystemError(resetDBCommandName)
-			o.WriteCanonicalError("The service %q cannot be stopped: %v", rdbs.service, err)
-			o.Log(output.Error, "service problem", map[string]any{
-				"service": rdbs.service,
-				"trigger": "Stop",
-				"error":   err,
-			})
-		}
-	}
-	return
-}
-
-func (rdbs *ResetDBSettings) ReportServiceQueryError(o output.Bus, err error) {
-	o.Log(output.Error, "service query error", map[string]any{
-		"service": rdbs.service,
-		"error":   err,
-	})
-}
-
-func (rdbs *ResetDBSettings) ReportServiceStopped(o output.Bus) {
-	o.Log(output.Info, "service stopped", map[string]any{"service": rdbs.service})
-}
-
-func (rdbs *ResetDBSettings) WaitForStop(o output.Bus, s ServiceRep, expiration time.Time,
-	checkInterval time.Duration) (ok bool, e *ExitError) {
-	for {
-		if expiration.Before(time.Now()) {
-			e = NewExitSystemError(resetDBCommandName)
-			o.WriteCanonicalError(
-				"The service %q could not be stopped within the %d second timeout",
-				rdbs.service, rdbs.timeout)
-			o.Log(output.Error, "service problem", map[string]any{
-				"service": rdbs.service,
-				"trigger": "Stop",
-				"error":   "timed out",
-				"timeout": rdbs.timeout,
-			})
-			break
-		}
-		time.Sleep(checkInterval)
-		if status, err := runQuery(s); err != nil {
-			e = NewExitSystemError(resetDBCommandName)
-			o.WriteCanonicalError(
-				"An error occurred while attempting to stop the service %q: %v",
-				rdbs.service, err)
-			rdbs.ReportServiceQueryError(o, err)
-			break
-		} else if status.State == svc.Stopped {
-			rdbs.ReportServiceStopped(o)
-			ok = true
-			break
-		}
-	}
-	return
-}
-
 func (rdbs *ResetDBSettings) DeleteMetadataFiles(o output.Bus, stopped bool) (e *ExitError) {
 	if !stopped {
 		if !rdbs.ignoreServiceErrors {
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/majohn-r/output"
+)
+
+// StopService cannot stop anything: the Windows service manager does not exist
+// on this platform. Deleting the metadata files is still possible if the user
+// explicitly asks to ignore service errors.
+func (rdbs *ResetDBSettings) StopService(o output.Bus) (ok bool, e *ExitError) {
+	e = NewExitUserError(resetDBCommandName)
+	o.WriteCanonicalError("The service %q cannot be stopped on this platform", rdbs.service)
+	o.WriteCanonicalError("Why?\nStopping the media player service is only supported on"+
+		" Windows, and this platform is %s", runtime.GOOS)
+	o.WriteCanonicalError("What to do:\n"+
+		"If you want the metadata files in %q deleted anyway, rerun this command with"+
+		" %q set to true", rdbs.metadataDir, resetDBIgnoreServiceErrorsFlag)
+	o.Log(output.Error, "service manager not supported", map[string]any{
+		"service":  rdbs.service,
+		"platform": runtime.GOOS,
+	})
+	return
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package cmd_test
+
+import (
+	"mp3/cmd"
+	"runtime"
+	"testing"
+
+	"github.com/majohn-r/output"
+)
+
+func TestResetDBSettings_StopService(t *testing.T) {
+	tests := map[string]struct {
+		rdbs       *cmd.ResetDBSettings
+		wantOk     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"unsupported": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithMetadataDir(
+				"metadata"),
+			wantStatus: cmd.NewExitUserError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The service \"my service\" cannot be stopped on this platform.\n" +
+					"Why?\n" +
+					"Stopping the media player service is only supported on Windows," +
+					" and this platform is " + runtime.GOOS + ".\n" +
+					"What to do:\n" +
+					"If you want the metadata files in \"metadata\" deleted anyway, rerun" +
+					" this command with \"--ignoreServiceErrors\" set to true.\n",
+				Log: "" +
+					"level='error'" +
+					" platform='" + runtime.GOOS + "'" +
+					" service='my service'" +
+					" msg='service manager not supported'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			gotOk, gotStatus := tt.rdbs.StopService(o)
+			if gotOk != tt.wantOk {
+				t.Errorf("ResetDBSettings.StopService() = %v, want %v", gotOk, tt.wantOk)
+			}
+			if !compareExitErrors(gotStatus, tt.wantStatus) {
+				t.Errorf("ResetDBSettings.StopService() = %v, want %v", gotStatus,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.StopService() %s", difference)
+				}
+			}
+		})
+	}
+}
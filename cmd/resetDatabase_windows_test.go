@@ -0,0 +1,622 @@
+//go:build windows
+
+/*
+Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
+*/
+package cmd_test
+
+import (
+	"fmt"
+	"mp3/cmd"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/majohn-r/output"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+type testService struct {
+	queries  int
+	statuses []svc.Status
+}
+
+func (ts *testService) Close() error {
+	return nil
+}
+
+func (ts *testService) Query() (svc.Status, error) {
+	if ts.queries >= len(ts.statuses) {
+		return svc.Status{}, fmt.Errorf("no results from query")
+	}
+	status := ts.statuses[ts.queries]
+	ts.queries++
+	return status, nil
+}
+
+func (ts *testService) Control(c svc.Cmd) (svc.Status, error) {
+	return ts.Query()
+}
+
+func newTestService(values ...svc.Status) *testService {
+	ts := &testService{
+		queries:  0,
+		statuses: values,
+	}
+	return ts
+}
+
+func TestResetDBSettings_WaitForStop(t *testing.T) {
+	type args struct {
+		s             cmd.ServiceRep
+		expiration    time.Time
+		checkInterval time.Duration
+	}
+	tests := map[string]struct {
+		rdbs *cmd.ResetDBSettings
+		args
+		wantOk     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"already timed out": {
+			rdbs: cmd.NewResetDBSettings().WithService(
+				"my service").WithTimeout(10),
+			args:       args{expiration: time.Now().Add(time.Duration(-1) * time.Second)},
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "The service \"my service\" could not be stopped within the 10" +
+					" second timeout.\n",
+				Log: "" +
+					"level='error'" +
+					" error='timed out'" +
+					" service='my service'" +
+					" timeout='10'" +
+					" trigger='Stop'" +
+					" msg='service problem'\n",
+			},
+		},
+		"query error": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				s:             newTestService(),
+				expiration:    time.Now().Add(time.Duration(1) * time.Second),
+				checkInterval: 1 * time.Millisecond,
+			},
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "An error occurred while attempting to stop the service " +
+					"\"my service\": no results from query.\n",
+				Log: "" +
+					"level='error'" +
+					" error='no results from query'" +
+					" service='my service'" +
+					" msg='service query error'\n",
+			},
+		},
+		"stops correctly": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				s: newTestService(
+					svc.Status{State: svc.Running},
+					svc.Status{State: svc.Running},
+					svc.Status{State: svc.Stopped}),
+				expiration:    time.Now().Add(time.Duration(1) * time.Second),
+				checkInterval: 1 * time.Millisecond,
+			},
+			wantOk:     true,
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info'" +
+					" service='my service'" +
+					" msg='service stopped'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			gotOk, gotStatus := tt.rdbs.WaitForStop(o, tt.args.s, tt.args.expiration,
+				tt.args.checkInterval)
+			if gotOk != tt.wantOk {
+				t.Errorf("ResetDBSettings.WaitForStop() = %t, want %t", gotOk, tt.wantOk)
+			}
+			if !compareExitErrors(gotStatus, tt.wantStatus) {
+				t.Errorf("ResetDBSettings.WaitForStop() = %s, want %s", gotStatus,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.WaitForStop() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+type testManager struct {
+	serviceMap  map[string]*mgr.Service
+	serviceList []string
+}
+
+func (tm *testManager) Disconnect() error {
+	return nil
+}
+
+func (tm *testManager) OpenService(name string) (*mgr.Service, error) {
+	if service, ok := tm.serviceMap[name]; ok {
+		return service, nil
+	}
+	return nil, fmt.Errorf("no such service")
+}
+
+func (tm *testManager) ListServices() ([]string, error) {
+	if len(tm.serviceList) == 0 {
+		return nil, fmt.Errorf("no services")
+	}
+	return tm.serviceList, nil
+}
+
+func newTestManager(m map[string]*mgr.Service, list []string) *testManager {
+	return &testManager{
+		serviceMap:  m,
+		serviceList: list,
+	}
+}
+
+func TestResetDBSettings_StopFoundService(t *testing.T) {
+	type args struct {
+		manager cmd.ServiceManager
+		service cmd.ServiceRep
+	}
+	tests := map[string]struct {
+		rdbs *cmd.ResetDBSettings
+		args
+		wantOk     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"defective service": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				manager: newTestManager(nil, nil),
+				service: newTestService(),
+			},
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "An error occurred while trying to stop service \"my service\":" +
+					" no results from query.\n",
+				Log: "" +
+					"level='error' " +
+					"error='no results from query' " +
+					"service='my service' " +
+					"msg='service query error'\n",
+			},
+		},
+		"already stopped": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				manager: newTestManager(nil, nil),
+				service: newTestService(svc.Status{State: svc.Stopped}),
+			},
+			wantOk:     true,
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info'" +
+					" service='my service'" +
+					" msg='service stopped'\n",
+			},
+		},
+		"stopped easily": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				manager: newTestManager(nil, nil),
+				service: newTestService(
+					svc.Status{State: svc.Paused},
+					svc.Status{State: svc.Stopped}),
+			},
+			wantOk:     true,
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info'" +
+					" service='my service'" +
+					" msg='service stopped'\n",
+			},
+		},
+		"stopped with a little more difficulty": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				manager: newTestManager(nil, nil),
+				service: newTestService(
+					svc.Status{State: svc.Paused},
+					svc.Status{State: svc.Paused},
+					svc.Status{State: svc.Stopped}),
+			},
+			wantOk:     true,
+			wantStatus: nil,
+			WantedRecording: output.WantedRecording{
+				Log: "" +
+					"level='info'" +
+					" service='my service'" +
+					" msg='service stopped'\n",
+			},
+		},
+		"cannot be stopped": {
+			rdbs: cmd.NewResetDBSettings().WithService("my service").WithTimeout(10),
+			args: args{
+				manager: newTestManager(nil, nil),
+				service: newTestService(svc.Status{State: svc.Paused}),
+			},
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "The service \"my service\" cannot be stopped:" +
+					" no results from query.\n",
+				Log: "" +
+					"level='error'" +
+					" error='no results from query'" +
+					" service='my service'" +
+					" trigger='Stop'" +
+					" msg='service problem'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			gotOk, gotStatus := tt.rdbs.StopFoundService(o, tt.args.manager,
+				tt.args.service)
+			if gotOk != tt.wantOk {
+				t.Errorf("ResetDBSettings.StopFoundService() = %v, want %v", gotOk,
+					tt.wantOk)
+			}
+			if !compareExitErrors(gotStatus, tt.wantStatus) {
+				t.Errorf("ResetDBSettings.StopFoundService() = %v, want %v", gotStatus,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.WaitForStop() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestAddServiceState(t *testing.T) {
+	tests := map[string]struct {
+		m           map[string][]string
+		s           cmd.ServiceRep
+		serviceName string
+		want        map[string][]string
+	}{
+		"error": {
+			m: map[string][]string{"no results from query": {
+				"some other bad service",
+			}},
+			s:           newTestService(),
+			serviceName: "bad service",
+			want: map[string][]string{
+				"no results from query": {"some other bad service", "bad service"},
+			},
+		},
+		"success": {
+			m:           map[string][]string{"stopped": {"some other service"}},
+			s:           newTestService(svc.Status{State: svc.Stopped}),
+			serviceName: "happy service",
+			want: map[string][]string{
+				"stopped": {"some other service", "happy service"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd.AddServiceState(tt.m, tt.s, tt.serviceName)
+			if !reflect.DeepEqual(tt.m, tt.want) {
+				t.Errorf("AddServiceState() = %v, want %v", tt.m, tt.want)
+			}
+		})
+	}
+}
+
+func Test_listServices(t *testing.T) {
+	type args struct {
+		manager  cmd.ServiceManager
+		services []string
+	}
+	tests := map[string]struct {
+		args
+		output.WantedRecording
+	}{
+		"no services": {
+			args: args{},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The following services are available:\n" +
+					"  - none -\n",
+			},
+		},
+		"some services": {
+			args: args{
+				manager: newTestManager(map[string]*mgr.Service{
+					"service1": nil,
+					"service2": nil,
+				}, nil),
+				services: []string{"service2", "service1", "service4"},
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The following services are available:\n" +
+					"  State \"no service\":\n" +
+					"    \"service1\"\n" +
+					"    \"service2\"\n" +
+					"  State \"no such service\":\n" +
+					"    \"service4\"\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			cmd.ListServices(o, tt.args.manager, tt.args.services)
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ListServices() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestResetDBSettings_HandleService(t *testing.T) {
+	tests := map[string]struct {
+		rdbs       *cmd.ResetDBSettings
+		manager    cmd.ServiceManager
+		wantOk     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"defective manager #1": {
+			rdbs:       cmd.NewResetDBSettings().WithService("my service").WithTimeout(1),
+			manager:    newTestManager(nil, []string{"my service"}),
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The service \"my service\" cannot be opened: no such service.\n" +
+					"The following services are available:\n" +
+					"  State \"no such service\":\n" +
+					"    \"my service\"\n",
+				Log: "" +
+					"level='error'" +
+					" error='no such service'" +
+					" service='my service'" +
+					" trigger='OpenService'" +
+					" msg='service problem'\n",
+			},
+		},
+		"defective manager #2": {
+			rdbs:       cmd.NewResetDBSettings().WithService("my service").WithTimeout(1),
+			manager:    newTestManager(nil, nil),
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "The service \"my service\" cannot be opened: no such service.\n",
+				Log: "" +
+					"level='error'" +
+					" error='no such service'" +
+					" service='my service'" +
+					" trigger='OpenService'" +
+					" msg='service problem'\n" +
+					"level='error'" +
+					" error='no services'" +
+					" trigger='ListServices'" +
+					" msg='service problem'\n",
+			},
+		},
+		"defective manager #3": {
+			rdbs:       cmd.NewResetDBSettings().WithService("my service").WithTimeout(1),
+			manager:    newTestManager(map[string]*mgr.Service{"my service": nil}, nil),
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "An error occurred while trying to stop service \"my service\":" +
+					" no service.\n",
+				Log: "" +
+					"level='error'" +
+					" error='no service'" +
+					" service='my service'" +
+					" msg='service query error'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			gotOk, gotStatus := tt.rdbs.HandleService(o, tt.manager)
+			if gotOk != tt.wantOk {
+				t.Errorf("ResetDBSettings.HandleService() = %v, want %v", gotOk, tt.wantOk)
+			}
+			if !compareExitErrors(gotStatus, tt.wantStatus) {
+				t.Errorf("ResetDBSettings.HandleService() = %v, want %v", gotStatus,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.HandleService() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestResetDBSettings_StopService(t *testing.T) {
+	originalConnect := cmd.Connect
+	defer func() {
+		cmd.Connect = originalConnect
+	}()
+	tests := map[string]struct {
+		connect    func() (*mgr.Mgr, error)
+		rdbs       *cmd.ResetDBSettings
+		wantOk     bool
+		wantStatus *cmd.ExitError
+		output.WantedRecording
+	}{
+		"connect fails": {
+			connect: func() (*mgr.Mgr, error) {
+				return nil, fmt.Errorf("no manager available")
+			},
+			rdbs:       cmd.NewResetDBSettings().WithService("my service").WithTimeout(1),
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An attempt to connect with the service manager failed;" +
+					" error is no manager available.\n" +
+					"Why?\n" +
+					"This often fails due to lack of permissions.\n" +
+					"What to do:\n" +
+					"If you can, try running this command as an administrator.\n",
+				Log: "" +
+					"level='error'" +
+					" error='no manager available'" +
+					" msg='service manager connect failed'\n",
+			},
+		},
+		"connect sort of works": {
+			connect: func() (*mgr.Mgr, error) {
+				return nil, nil
+			},
+			rdbs:       cmd.NewResetDBSettings().WithService("my service").WithTimeout(1),
+			wantStatus: cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "The service \"my service\" cannot be opened: nil manager.\n",
+				Log: "" +
+					"level='error'" +
+					" error='nil manager'" +
+					" service='my service'" +
+					" trigger='OpenService'" +
+					" msg='service problem'\n" +
+					"level='error'" +
+					" error='nil manager'" +
+					" trigger='ListServices'" +
+					" msg='service problem'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd.Connect = tt.connect
+			o := output.NewRecorder()
+			gotOk, gotStatus := tt.rdbs.StopService(o)
+			if gotOk != tt.wantOk {
+				t.Errorf("ResetDBSettings.StopService() = %v, want %v", gotOk, tt.wantOk)
+			}
+			if !compareExitErrors(gotStatus, tt.wantStatus) {
+				t.Errorf("ResetDBSettings.StopService() = %v, want %v", gotStatus,
+					tt.wantStatus)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.StopService() %s", difference)
+				}
+			}
+		})
+	}
+}
+
+func TestResetDBSettings_ResetService(t *testing.T) {
+	originalDirty := cmd.Dirty
+	originalClearDirty := cmd.ClearDirty
+	originalConnect := cmd.Connect
+	defer func() {
+		cmd.Dirty = originalDirty
+		cmd.ClearDirty = originalClearDirty
+		cmd.Connect = originalConnect
+	}()
+	cmd.ClearDirty = func(_ output.Bus) {}
+	cmd.Connect = func() (*mgr.Mgr, error) { return nil, fmt.Errorf("access denied") }
+	tests := map[string]struct {
+		dirty func() bool
+		rdbs  *cmd.ResetDBSettings
+		want  *cmd.ExitError
+		output.WantedRecording
+	}{
+		"not dirty, no force": {
+			dirty: func() bool { return false },
+			rdbs:  cmd.NewResetDBSettings().WithForce(false),
+			want:  cmd.NewExitUserError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The \"resetDatabase\" command has no work to perform.\n" +
+					"Why?\n" +
+					"The \"mp3\" program has not made any changes to any mp3 files\n" +
+					"since the last successful database reset.\n" +
+					"What to do:\n" +
+					"If you believe the Windows database needs to be reset, run this command\n" +
+					"again and use the \"--force\" flag.\n",
+			},
+		},
+		"not dirty, force": {
+			dirty: func() bool { return false },
+			rdbs:  cmd.NewResetDBSettings().WithForce(true),
+			want:  cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An attempt to connect with the service manager failed;" +
+					" error is access denied.\n" +
+					"Why?\n" +
+					"This often fails due to lack of permissions.\n" +
+					"What to do:\n" +
+					"If you can, try running this command as an administrator.\n" +
+					"Metadata files will not be deleted.\n" +
+					"Why?\n" +
+					"The music service \"\" could not be stopped, and" +
+					" \"--ignoreServiceErrors\" is false.\n" +
+					"What to do:\n" +
+					"Rerun this command with \"--ignoreServiceErrors\" set to true.\n",
+				Log: "" +
+					"level='error'" +
+					" error='access denied'" +
+					" msg='service manager connect failed'\n",
+			},
+		},
+		"dirty, not force": {
+			dirty: func() bool { return true },
+			rdbs:  cmd.NewResetDBSettings(),
+			want:  cmd.NewExitSystemError("resetDatabase"),
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"An attempt to connect with the service manager failed;" +
+					" error is access denied.\n" +
+					"Why?\n" +
+					"This often fails due to lack of permissions.\n" +
+					"What to do:\n" +
+					"If you can, try running this command as an administrator.\n" +
+					"Metadata files will not be deleted.\n" +
+					"Why?\n" +
+					"The music service \"\" could not be stopped, and" +
+					" \"--ignoreServiceErrors\" is false.\n" +
+					"What to do:\n" +
+					"Rerun this command with \"--ignoreServiceErrors\" set to true.\n",
+				Log: "" +
+					"level='error'" +
+					" error='access denied'" +
+					" msg='service manager connect failed'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd.Dirty = tt.dirty
+			o := output.NewRecorder()
+			if got := tt.rdbs.ResetService(o); !compareExitErrors(got, tt.want) {
+				t.Errorf("ResetDBSettings.ResetService() got %s want %s", got, tt.want)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("ResetDBSettings.ResetService() %s", difference)
+				}
+			}
+		})
+	}
+}
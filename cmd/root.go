@@ -4,6 +4,7 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -81,6 +82,7 @@ func InitGlobals() {
 	defer initLock.Unlock()
 	if !Initialized {
 		ok := false
+		initPlatformEnvironment()
 		Bus = NewDefaultBus(cmd_toolkit.ProductionLogger)
 		if _, err := AppName(); err != nil {
 			SetAppName(appName)
@@ -116,7 +118,7 @@ func CookCommandLineArguments(o output.Bus, inputArgs []string) []string {
 
 type CommandExecutor interface {
 	SetArgs(a []string)
-	Execute() error
+	ExecuteContext(ctx context.Context) error
 }
 
 // Execute adds all child commands to the root command and sets flags
@@ -145,9 +147,21 @@ func RunMain(o output.Bus, cmd CommandExecutor, start time.Time) int {
 		"args":         cookedArgs,
 	})
 	NewElevationControl().Log(o, output.Info)
+	// the first interrupt cancels the context, giving the command the chance to
+	// stop cleanly; restoring the default handling then lets a second interrupt
+	// end the program at once
+	ctx, stop := NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	context.AfterFunc(ctx, stop)
 	cmd.SetArgs(cookedArgs)
-	err := cmd.Execute()
+	err := cmd.ExecuteContext(ctx)
+	SaveMetadataCache(o)
 	exitCode := ObtainExitCode(err)
+	if ctx.Err() != nil {
+		exitCode = int(interrupted)
+		o.WriteCanonicalError("The command was interrupted before it could finish")
+		o.Log(output.Info, "execution interrupted", map[string]any{"error": ctx.Err()})
+	}
 	o.Log(output.Info, "execution ends", map[string]any{
 		"duration": Since(start),
 		"exitCode": exitCode,
@@ -159,6 +173,15 @@ func RunMain(o output.Bus, cmd CommandExecutor, start time.Time) int {
 	return exitCode
 }
 
+// commandContext returns the context with which the command was executed; a
+// command run on its own, rather than through the root command, has none
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
 func ObtainExitCode(err error) int {
 	switch {
 	case err == nil:
@@ -4,6 +4,7 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd_test
 
 import (
+	"context"
 	"fmt"
 	"mp3/cmd"
 	"os"
@@ -13,7 +14,6 @@ import (
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
-	"golang.org/x/sys/windows"
 )
 
 func TestExecute(t *testing.T) {
@@ -48,39 +48,41 @@ func TestExecute(t *testing.T) {
 
 type happyCommand struct{}
 
-func (h happyCommand) SetArgs(_ []string) {}
-func (h happyCommand) Execute() error     { return nil }
+func (h happyCommand) SetArgs(_ []string)                     {}
+func (h happyCommand) ExecuteContext(_ context.Context) error { return nil }
 
 type sadCommand struct{}
 
-func (s sadCommand) SetArgs(_ []string) {}
-func (s sadCommand) Execute() error     { return fmt.Errorf("sad") }
+func (s sadCommand) SetArgs(_ []string)                     {}
+func (s sadCommand) ExecuteContext(_ context.Context) error { return fmt.Errorf("sad") }
 
 type panickyCommand struct{}
 
-func (p panickyCommand) SetArgs(_ []string) {}
-func (p panickyCommand) Execute() error     { panic("oh dear") }
+func (p panickyCommand) SetArgs(_ []string)                     {}
+func (p panickyCommand) ExecuteContext(_ context.Context) error { panic("oh dear") }
 
 func TestRunMain(t *testing.T) {
 	originalArgs := os.Args
 	originalSince := cmd.Since
 	originalVersion := cmd.Version
 	originalCreation := cmd.Creation
-	originalIsElevatedFunc := cmd.IsElevated
+	originalProcessIsElevated := cmd.ProcessIsElevated
 	originalIsTerminal := cmd.IsTerminal
 	originalIsCygwinTerminal := cmd.IsCygwinTerminal
 	originalLookupEnv := cmd.LookupEnv
+	originalNotifyContext := cmd.NotifyContext
 	defer func() {
+		cmd.NotifyContext = originalNotifyContext
 		cmd.Since = originalSince
 		os.Args = originalArgs
 		cmd.Version = originalVersion
 		cmd.Creation = originalCreation
-		cmd.IsElevated = originalIsElevatedFunc
+		cmd.ProcessIsElevated = originalProcessIsElevated
 		cmd.IsTerminal = originalIsTerminal
 		cmd.IsCygwinTerminal = originalIsCygwinTerminal
 		cmd.LookupEnv = originalLookupEnv
 	}()
-	cmd.IsElevated = func(_ windows.Token) bool { return true }
+	cmd.ProcessIsElevated = func() bool { return true }
 	cmd.IsTerminal = func(_ uintptr) bool { return true }
 	cmd.IsCygwinTerminal = func(_ uintptr) bool { return true }
 	cmd.LookupEnv = func(_ string) (string, bool) { return "", false }
@@ -90,6 +92,7 @@ func TestRunMain(t *testing.T) {
 	}
 	tests := map[string]struct {
 		args
+		interrupted  bool
 		cmdline      []string
 		appVersion   string
 		timestamp    string
@@ -155,6 +158,41 @@ func TestRunMain(t *testing.T) {
 					" msg='execution ends'\n",
 			},
 		},
+		"interrupted": {
+			args:         args{cmd: happyCommand{}, start: time.Now()},
+			interrupted:  true,
+			cmdline:      []string{"happyApp", "arg1", "arg2"},
+			appVersion:   "0.1.2",
+			timestamp:    "2021-11-28T12:01:02Z05:00",
+			goVersion:    "1.22.x",
+			dependencies: []string{"foo v1.1.1", "bar v1.2.2"},
+			WantedRecording: output.WantedRecording{
+				Error: "" +
+					"The command was interrupted before it could finish.\n" +
+					"\"mp3\" version 0.1.2, created at 2021-11-28T12:01:02Z05:00, failed.\n",
+				Log: "level='info'" +
+					" args='[arg1 arg2]'" +
+					" dependencies='[foo v1.1.1 bar v1.2.2]'" +
+					" goVersion='1.22.x'" +
+					" timeStamp='2021-11-28T12:01:02Z05:00'" +
+					" version='0.1.2'" +
+					" msg='execution starts'\n" +
+					"level='info'" +
+					" admin_permission='true'" +
+					" elevated='true'" +
+					" stderr_redirected='false'" +
+					" stdin_redirected='false'" +
+					" stdout_redirected='false'" +
+					" msg='elevation state'\n" +
+					"level='info'" +
+					" error='context canceled'" +
+					" msg='execution interrupted'\n" +
+					"level='info'" +
+					" duration='0s'" +
+					" exitCode='4'" +
+					" msg='execution ends'\n",
+			},
+		},
 		"panicky": {
 			args:         args{cmd: panickyCommand{}, start: time.Now()},
 			appVersion:   "0.2.3",
@@ -199,6 +237,14 @@ func TestRunMain(t *testing.T) {
 			cmd.BuildDependencies = func() []string {
 				return tt.dependencies
 			}
+			cmd.NotifyContext = func(parent context.Context,
+				signals ...os.Signal) (context.Context, context.CancelFunc) {
+				ctx, cancel := originalNotifyContext(parent, signals...)
+				if tt.interrupted {
+					cancel()
+				}
+				return ctx, cancel
+			}
 			o := output.NewRecorder()
 			cmd.RunMain(o, tt.args.cmd, tt.args.start)
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
@@ -4,30 +4,75 @@ Copyright © 2021 Marc Johnson (marc.johnson27591@gmail.com)
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
 	"mp3/internal/files"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
 )
 
 const (
-	SearchAlbumFilter        = "albumFilter"
-	SearchAlbumFilterFlag    = "--" + SearchAlbumFilter
-	SearchArtistFilter       = "artistFilter"
-	SearchArtistFilterFlag   = "--" + SearchArtistFilter
-	SearchFileExtensions     = "extensions"
-	SearchFileExtensionsFlag = "--" + SearchFileExtensions
-	SearchTopDir             = "topDir"
-	SearchTopDirFlag         = "--" + SearchTopDir
-	SearchTrackFilter        = "trackFilter"
-	SearchTrackFilterFlag    = "--" + SearchTrackFilter
-	searchUsage              = "[" + SearchAlbumFilterFlag + " regex] [" +
+	SearchAlbumFilter          = "albumFilter"
+	SearchAlbumFilterFlag      = "--" + SearchAlbumFilter
+	SearchArtistFilter         = "artistFilter"
+	SearchArtistFilterFlag     = "--" + SearchArtistFilter
+	SearchBitrateRange         = "bitrateRange"
+	SearchBitrateRangeFlag     = "--" + SearchBitrateRange
+	SearchCompilations         = "compilations"
+	SearchCompilationsFlag     = "--" + SearchCompilations
+	SearchConcurrency          = "concurrency"
+	SearchConcurrencyFlag      = "--" + SearchConcurrency
+	SearchDurationRange        = "durationRange"
+	SearchDurationRangeFlag    = "--" + SearchDurationRange
+	SearchFileExtensions       = "extensions"
+	SearchFileExtensionsFlag   = "--" + SearchFileExtensions
+	SearchGenreFilter          = "genreFilter"
+	SearchGenreFilterFlag      = "--" + SearchGenreFilter
+	SearchID3Versions          = "id3Versions"
+	SearchID3VersionsFlag      = "--" + SearchID3Versions
+	SearchIgnore               = "ignore"
+	SearchIgnoreFlag           = "--" + SearchIgnore
+	SearchLayout               = "layout"
+	SearchLayoutFlag           = "--" + SearchLayout
+	SearchMetadataCache        = "metadataCache"
+	SearchMetadataCacheFlag    = "--" + SearchMetadataCache
+	SearchMetadataPriority     = "metadataPriority"
+	SearchMetadataPriorityFlag = "--" + SearchMetadataPriority
+	SearchTagAlbumFilter       = "tagAlbumFilter"
+	SearchTagAlbumFilterFlag   = "--" + SearchTagAlbumFilter
+	SearchTagArtistFilter      = "tagArtistFilter"
+	SearchTagArtistFilterFlag  = "--" + SearchTagArtistFilter
+	SearchTagTitleFilter       = "tagTitleFilter"
+	SearchTagTitleFilterFlag   = "--" + SearchTagTitleFilter
+	SearchTopDir               = "topDir"
+	SearchTopDirFlag           = "--" + SearchTopDir
+	SearchTrackFilter          = "trackFilter"
+	SearchTrackFilterFlag      = "--" + SearchTrackFilter
+	SearchTrackNames           = "trackNames"
+	SearchTrackNamesFlag       = "--" + SearchTrackNames
+	SearchWhere                = "where"
+	SearchWhereFlag            = "--" + SearchWhere
+	SearchYearRange            = "yearRange"
+	SearchYearRangeFlag        = "--" + SearchYearRange
+	searchUsage                = "[" + SearchAlbumFilterFlag + " regex] [" +
 		SearchArtistFilterFlag + " regex] [" + SearchTrackFilterFlag + " regex] [" +
-		SearchTopDirFlag + " dir] [" + SearchFileExtensionsFlag + " extensions]"
+		SearchTopDirFlag + " dir] [" + SearchFileExtensionsFlag + " extensions] [" +
+		SearchMetadataPriorityFlag + " sources] [" + SearchCompilationsFlag + " names] [" +
+		SearchLayoutFlag + " template] [" + SearchTrackNamesFlag + " patterns] [" +
+		SearchMetadataCacheFlag + " mode] [" + SearchConcurrencyFlag + " count] [" +
+		SearchIgnoreFlag + " patterns] [" + SearchGenreFilterFlag + " regex] [" +
+		SearchTagArtistFilterFlag + " regex] [" + SearchTagAlbumFilterFlag + " regex] [" +
+		SearchTagTitleFilterFlag + " regex] [" + SearchYearRangeFlag + " range] [" +
+		SearchBitrateRangeFlag + " range] [" + SearchDurationRangeFlag + " range] [" +
+		SearchID3VersionsFlag + " versions] [" + SearchWhereFlag + " expression]"
 	searchRegexInstructions = "" +
 		`Here are some common errors in filter expressions and what to do:
 Character class problems
@@ -61,21 +106,106 @@ var (
 				"regular expression specifying which tracks to select").WithExpectedType(
 				StringType).WithDefaultValue(".*"),
 			SearchTopDir: NewFlagDetails().WithUsage(
-				"top directory specifying where to find mp3 files").WithExpectedType(
-				StringType).WithDefaultValue(filepath.Join("%HOMEPATH%", "Music")),
+				"top directory, or list of top directories, specifying where to find mp3" +
+					" files").WithExpectedType(
+				StringType).WithDefaultValue(defaultTopDir),
 			SearchFileExtensions: NewFlagDetails().WithUsage(
 				"comma-delimited list of file extensions used by mp3 files").WithExpectedType(
 				StringType).WithDefaultValue(".mp3"),
+			SearchMetadataPriority: NewFlagDetails().WithUsage(
+				"comma-delimited list of metadata sources, in order of preference").WithExpectedType(
+				StringType).WithDefaultValue(defaultMetadataPriority()),
+			SearchCompilations: NewFlagDetails().WithUsage(
+				"comma-delimited list of artist directory names that hold compilations").WithExpectedType(
+				StringType).WithDefaultValue(strings.Join(files.DefaultCompilationArtists(), ",")),
+			SearchLayout: NewFlagDetails().WithUsage(
+				"template describing the directories below the top directory").WithExpectedType(
+				StringType).WithDefaultValue(files.DefaultLayout),
+			SearchTrackNames: NewFlagDetails().WithUsage(
+				"newline-delimited list of regular expressions for parsing track file names;" +
+					" empty selects the built-in expressions").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchMetadataCache: NewFlagDetails().WithUsage(
+				"how to use the cache of track metadata: " + strings.Join(files.CacheModes(),
+					", ")).WithExpectedType(StringType).WithDefaultValue(files.CacheUse),
+			SearchConcurrency: NewFlagDetails().WithUsage(fmt.Sprintf(
+				"maximum number of track files read at the same time (minimum %d, maximum"+
+					" %d)", files.MinMetadataReaders, files.MaxMetadataReaders)).WithExpectedType(
+				IntType).WithDefaultValue(cmd_toolkit.NewIntBounds(files.MinMetadataReaders,
+				files.DefaultMetadataReaders, files.MaxMetadataReaders)),
+			SearchIgnore: NewFlagDetails().WithUsage(
+				"newline-delimited list of patterns, written as in " + files.IgnoreFileName +
+					" files, naming what to ignore below the top directory").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchGenreFilter: NewFlagDetails().WithUsage(
+				"regular expression specifying which genres, read from the track metadata, to" +
+					" select; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchTagArtistFilter: NewFlagDetails().WithUsage(
+				"regular expression specifying which artist names, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchTagAlbumFilter: NewFlagDetails().WithUsage(
+				"regular expression specifying which album names, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchTagTitleFilter: NewFlagDetails().WithUsage(
+				"regular expression specifying which track titles, read from the track" +
+					" metadata, to select; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchYearRange: NewFlagDetails().WithUsage(
+				"range of years, read from the track metadata, to select, such as 1970-1979," +
+					" 1990-, or -1979; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchBitrateRange: NewFlagDetails().WithUsage(
+				"range of bitrates, in kbps, to select, such as 192-320; empty selects" +
+					" all").WithExpectedType(StringType).WithDefaultValue(""),
+			SearchDurationRange: NewFlagDetails().WithUsage(
+				"range of track durations to select, such as 2m-10m30s; empty selects" +
+					" all").WithExpectedType(StringType).WithDefaultValue(""),
+			SearchID3Versions: NewFlagDetails().WithUsage(
+				"comma-delimited list of ID3V2 versions to select, such as 3,4; 0 selects" +
+					" tracks without an ID3V2 tag; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
+			SearchWhere: NewFlagDetails().WithUsage(
+				"boolean expression specifying which tracks to select, such as 'year >= 1990" +
+					" and not hasConcern(numbering)'; empty selects all").WithExpectedType(
+				StringType).WithDefaultValue(""),
 		},
 	)
 )
 
+func defaultMetadataPriority() string {
+	names := []string{}
+	for _, sT := range files.NewReadSettings().PrimarySourcePriority() {
+		names = append(names, sT.Name())
+	}
+	return strings.Join(names, ",")
+}
+
 type SearchSettings struct {
-	albumFilter    *regexp.Regexp
-	artistFilter   *regexp.Regexp
-	fileExtensions []string
-	topDirectory   string
-	trackFilter    *regexp.Regexp
+	albumFilter      *regexp.Regexp
+	artistFilter     *regexp.Regexp
+	bitrateRange     *ValueRange[int]
+	compilations     []string
+	concurrency      int
+	durationRange    *ValueRange[time.Duration]
+	fileExtensions   []string
+	genreFilter      *regexp.Regexp
+	id3Versions      []int
+	ignore           string
+	ignored          []ignoredEntry
+	layout           *files.Layout
+	metadataCache    string
+	metadataPriority []files.SourceType
+	tagAlbumFilter   *regexp.Regexp
+	tagArtistFilter  *regexp.Regexp
+	tagTitleFilter   *regexp.Regexp
+	topDirectories   []string
+	trackFilter      *regexp.Regexp
+	trackNames       []*regexp.Regexp
+	where            *WhereExpression
+	yearRange        *ValueRange[int]
 }
 
 func NewSearchSettings() *SearchSettings {
@@ -84,11 +214,27 @@ func NewSearchSettings() *SearchSettings {
 
 func (ss *SearchSettings) Values() map[string]any {
 	return map[string]any{
-		SearchAlbumFilterFlag:    ss.albumFilter,
-		SearchArtistFilterFlag:   ss.artistFilter,
-		SearchTrackFilterFlag:    ss.trackFilter,
-		SearchTopDirFlag:         ss.topDirectory,
-		SearchFileExtensionsFlag: ss.fileExtensions,
+		SearchAlbumFilterFlag:      ss.albumFilter,
+		SearchArtistFilterFlag:     ss.artistFilter,
+		SearchTrackFilterFlag:      ss.trackFilter,
+		SearchTopDirFlag:           ss.topDirectoryList(),
+		SearchFileExtensionsFlag:   ss.fileExtensions,
+		SearchIgnoreFlag:           ss.ignore,
+		SearchMetadataPriorityFlag: ss.metadataPriority,
+		SearchCompilationsFlag:     ss.compilations,
+		SearchConcurrencyFlag:      ss.concurrency,
+		SearchLayoutFlag:           ss.layout,
+		SearchTrackNamesFlag:       ss.trackNames,
+		SearchMetadataCacheFlag:    ss.metadataCache,
+		SearchGenreFilterFlag:      optionalFilter(ss.genreFilter),
+		SearchTagArtistFilterFlag:  optionalFilter(ss.tagArtistFilter),
+		SearchTagAlbumFilterFlag:   optionalFilter(ss.tagAlbumFilter),
+		SearchTagTitleFilterFlag:   optionalFilter(ss.tagTitleFilter),
+		SearchYearRangeFlag:        ss.yearRange,
+		SearchBitrateRangeFlag:     ss.bitrateRange,
+		SearchDurationRangeFlag:    ss.durationRange,
+		SearchID3VersionsFlag:      ss.id3Versions,
+		SearchWhereFlag:            ss.where,
 	}
 }
 
@@ -102,21 +248,122 @@ func (ss *SearchSettings) WithArtistFilter(r *regexp.Regexp) *SearchSettings {
 	return ss
 }
 
+func (ss *SearchSettings) WithBitrateRange(r *ValueRange[int]) *SearchSettings {
+	ss.bitrateRange = r
+	return ss
+}
+
+func (ss *SearchSettings) WithCompilations(s []string) *SearchSettings {
+	ss.compilations = s
+	return ss
+}
+
+func (ss *SearchSettings) WithConcurrency(i int) *SearchSettings {
+	ss.concurrency = i
+	return ss
+}
+
+func (ss *SearchSettings) WithDurationRange(r *ValueRange[time.Duration]) *SearchSettings {
+	ss.durationRange = r
+	return ss
+}
+
 func (ss *SearchSettings) WithFileExtensions(s []string) *SearchSettings {
 	ss.fileExtensions = s
 	return ss
 }
 
+func (ss *SearchSettings) WithGenreFilter(r *regexp.Regexp) *SearchSettings {
+	ss.genreFilter = r
+	return ss
+}
+
+func (ss *SearchSettings) WithID3Versions(v []int) *SearchSettings {
+	ss.id3Versions = v
+	return ss
+}
+
+func (ss *SearchSettings) WithIgnore(s string) *SearchSettings {
+	ss.ignore = s
+	return ss
+}
+
+// IgnoredPaths returns the paths of the files and directories that ignore rules
+// kept out of the most recent Load
+func (ss *SearchSettings) IgnoredPaths() []string {
+	var paths []string
+	for _, entry := range ss.ignored {
+		paths = append(paths, entry.path)
+	}
+	return paths
+}
+
+func (ss *SearchSettings) WithLayout(l *files.Layout) *SearchSettings {
+	ss.layout = l
+	return ss
+}
+
+func (ss *SearchSettings) WithMetadataCache(s string) *SearchSettings {
+	ss.metadataCache = s
+	return ss
+}
+
+func (ss *SearchSettings) WithMetadataPriority(s []files.SourceType) *SearchSettings {
+	ss.metadataPriority = s
+	return ss
+}
+
+func (ss *SearchSettings) WithTagAlbumFilter(r *regexp.Regexp) *SearchSettings {
+	ss.tagAlbumFilter = r
+	return ss
+}
+
+func (ss *SearchSettings) WithTagArtistFilter(r *regexp.Regexp) *SearchSettings {
+	ss.tagArtistFilter = r
+	return ss
+}
+
+func (ss *SearchSettings) WithTagTitleFilter(r *regexp.Regexp) *SearchSettings {
+	ss.tagTitleFilter = r
+	return ss
+}
+
 func (ss *SearchSettings) WithTopDirectory(s string) *SearchSettings {
-	ss.topDirectory = s
+	ss.topDirectories = []string{s}
 	return ss
 }
 
+func (ss *SearchSettings) WithTopDirectories(s []string) *SearchSettings {
+	ss.topDirectories = s
+	return ss
+}
+
+// topDirectoryList returns the top directories as they would be written in the
+// --topDir value
+func (ss *SearchSettings) topDirectoryList() string {
+	return strings.Join(ss.topDirectories, string(os.PathListSeparator))
+}
+
 func (ss *SearchSettings) WithTrackFilter(r *regexp.Regexp) *SearchSettings {
 	ss.trackFilter = r
 	return ss
 }
 
+func (ss *SearchSettings) WithTrackNames(r []*regexp.Regexp) *SearchSettings {
+	ss.trackNames = r
+	return ss
+}
+
+func (ss *SearchSettings) WithWhere(w *WhereExpression) *SearchSettings {
+	ss.where = w
+	return ss
+}
+
+func (ss *SearchSettings) WithYearRange(r *ValueRange[int]) *SearchSettings {
+	ss.yearRange = r
+	return ss
+}
+
 func EvaluateSearchFlags(o output.Bus, producer FlagProducer) (*SearchSettings, bool) {
 	values, eSlice := ReadFlags(producer, SearchFlags)
 	if ProcessFlagErrors(o, eSlice) {
@@ -158,12 +405,31 @@ func ProcessSearchFlags(o output.Bus, values map[string]*FlagValue) (settings *S
 		}
 		ok = false
 	}
+	for _, tagFilter := range []struct {
+		name   string
+		filter **regexp.Regexp
+	}{
+		{name: SearchGenreFilter, filter: &settings.genreFilter},
+		{name: SearchTagArtistFilter, filter: &settings.tagArtistFilter},
+		{name: SearchTagAlbumFilter, filter: &settings.tagAlbumFilter},
+		{name: SearchTagTitleFilter, filter: &settings.tagTitleFilter},
+	} {
+		if filter, _ok, _regexOk := EvaluateTagFilter(o, values, tagFilter.name,
+			"--"+tagFilter.name); _ok {
+			*tagFilter.filter = filter
+		} else {
+			if !_regexOk {
+				regexOk = false
+			}
+			ok = false
+		}
+	}
 	if !regexOk {
 		// user has attempted to use filters that don't compile
 		o.WriteCanonicalError(searchRegexInstructions)
 	}
-	if topDir, _ok := EvaluateTopDir(o, values); _ok {
-		settings.topDirectory = topDir
+	if topDirs, _ok := EvaluateTopDir(o, values); _ok {
+		settings.topDirectories = topDirs
 	} else {
 		ok = false
 	}
@@ -172,9 +438,102 @@ func ProcessSearchFlags(o output.Bus, values map[string]*FlagValue) (settings *S
 	} else {
 		ok = false
 	}
+	if priority, _ok := EvaluateMetadataPriority(o, values); _ok {
+		settings.metadataPriority = priority
+	} else {
+		ok = false
+	}
+	if compilations, _ok := EvaluateCompilations(o, values); _ok {
+		settings.compilations = compilations
+	} else {
+		ok = false
+	}
+	if layout, _ok := EvaluateLayout(o, values); _ok {
+		settings.layout = layout
+	} else {
+		ok = false
+	}
+	if trackNames, _ok := EvaluateTrackNames(o, values); _ok {
+		settings.trackNames = trackNames
+	} else {
+		ok = false
+	}
+	if mode, _ok := EvaluateMetadataCache(o, values); _ok {
+		settings.metadataCache = mode
+	} else {
+		ok = false
+	}
+	if concurrency, _ok := EvaluateConcurrency(o, values); _ok {
+		settings.concurrency = concurrency
+	} else {
+		ok = false
+	}
+	if ignore, _ok := EvaluateIgnore(o, values); _ok {
+		settings.ignore = ignore
+	} else {
+		ok = false
+	}
+	if years, _ok := EvaluateYearRange(o, values); _ok {
+		settings.yearRange = years
+	} else {
+		ok = false
+	}
+	if bitrates, _ok := EvaluateBitrateRange(o, values); _ok {
+		settings.bitrateRange = bitrates
+	} else {
+		ok = false
+	}
+	if durations, _ok := EvaluateDurationRange(o, values); _ok {
+		settings.durationRange = durations
+	} else {
+		ok = false
+	}
+	if versions, _ok := EvaluateID3Versions(o, values); _ok {
+		settings.id3Versions = versions
+	} else {
+		ok = false
+	}
+	if where, _ok := EvaluateWhere(o, values); _ok {
+		settings.where = where
+	} else {
+		ok = false
+	}
 	return
 }
 
+// EvaluateIgnore returns the global ignore list, whose patterns, one per line,
+// are written as in an ignore file and apply below each top directory; the
+// value is returned as written, as the patterns are relative to each top
+// directory in turn
+func EvaluateIgnore(o output.Bus, values map[string]*FlagValue) (string, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchIgnore)
+	if err != nil {
+		return "", false
+	}
+	_, errs := files.ParseIgnoreRules(rawValue, "", SearchIgnoreFlag)
+	for _, err := range errs {
+		o.WriteCanonicalError("The %s value contains a pattern that cannot be used",
+			SearchIgnoreFlag)
+		o.WriteCanonicalError("Why?\n%v", err)
+		if userSet {
+			o.WriteCanonicalError("What to do:\nCorrect the pattern, or remove it")
+		} else {
+			o.WriteCanonicalError("What to do:\n" +
+				"Either edit the defaults.yaml file containing the settings, or explicitly" +
+				" set " + SearchIgnoreFlag + " to a better value.")
+		}
+		o.Log(output.Error, "invalid ignore pattern", map[string]any{
+			"error":          err,
+			SearchIgnoreFlag: rawValue,
+			"user-set":       userSet,
+		})
+	}
+	if len(errs) > 0 {
+		return "", false
+	}
+	return rawValue, true
+}
+
 func EvaluateFileExtensions(o output.Bus, values map[string]*FlagValue) ([]string, bool) {
 	extensions := []string{}
 	ok := false
@@ -205,52 +564,309 @@ func EvaluateFileExtensions(o output.Bus, values map[string]*FlagValue) ([]strin
 	return extensions, ok
 }
 
-func EvaluateTopDir(o output.Bus, values map[string]*FlagValue) (dir string, ok bool) {
-	if rawValue, userSet, err := GetString(o, values, SearchTopDir); err == nil {
-		if file, err := os.Stat(rawValue); err != nil {
+// EvaluateConcurrency returns the number of track files that may be read at the
+// same time
+func EvaluateConcurrency(o output.Bus, values map[string]*FlagValue) (int, bool) {
+	value, userSet, err := GetInt(o, values, SearchConcurrency)
+	if err != nil {
+		return 0, false
+	}
+	if value < files.MinMetadataReaders || value > files.MaxMetadataReaders {
+		o.WriteCanonicalError("The %s value %d cannot be used", SearchConcurrencyFlag, value)
+		o.WriteCanonicalError("Why?\nThe value must be at least %d and at most %d",
+			files.MinMetadataReaders, files.MaxMetadataReaders)
+		o.WriteCanonicalError("What to do:\n"+
+			"Use a larger value to read track files on slow drives or network shares"+
+			" faster, or a smaller value to use fewer open files; %d suits most"+
+			" libraries", files.DefaultMetadataReaders)
+		o.Log(output.Error, "invalid concurrency", map[string]any{
+			SearchConcurrencyFlag: value,
+			"user-set":            userSet,
+		})
+		return 0, false
+	}
+	return value, true
+}
+
+// EvaluateMetadataCache returns how the metadata cache is to be used
+func EvaluateMetadataCache(o output.Bus, values map[string]*FlagValue) (string, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchMetadataCache)
+	if err != nil {
+		return "", false
+	}
+	mode := strings.ToLower(strings.TrimSpace(rawValue))
+	if !slices.Contains(files.CacheModes(), mode) {
+		o.WriteCanonicalError("The %s value %q cannot be used", SearchMetadataCacheFlag,
+			rawValue)
+		o.WriteCanonicalError("Why?\nThe value must be one of %s",
+			strings.Join(files.CacheModes(), ", "))
+		o.WriteCanonicalError("What to do:\n"+
+			"Use %q to reuse the metadata of unchanged track files, %q to read every"+
+			" track file without the cache, or %q to read every track file and replace"+
+			" the cache", files.CacheUse, files.CacheBypass, files.CacheRebuild)
+		o.Log(output.Error, "invalid metadata cache mode", map[string]any{
+			SearchMetadataCacheFlag: rawValue,
+			"user-set":              userSet,
+		})
+		return "", false
+	}
+	return mode, true
+}
+
+func EvaluateMetadataPriority(o output.Bus,
+	values map[string]*FlagValue) ([]files.SourceType, bool) {
+	priority := []files.SourceType{}
+	ok := false
+	if rawValue, _, err := GetString(o, values, SearchMetadataPriority); err == nil {
+		failedCandidates := []string{}
+		ok = true
+		for _, candidate := range strings.Split(rawValue, ",") {
+			if sT, found := files.ParseSourceType(candidate); found {
+				priority = append(priority, sT)
+			} else {
+				o.WriteCanonicalError("The metadata source %q cannot be used.", candidate)
+				failedCandidates = append(failedCandidates, candidate)
+				ok = false
+			}
+		}
+		if !ok {
+			names := []string{}
+			for _, src := range files.MetadataSources() {
+				names = append(names, src.Name())
+			}
+			o.WriteCanonicalError("Why?")
+			o.WriteCanonicalError("The supported metadata sources are %s",
+				strings.Join(names, ", "))
+			o.WriteCanonicalError("What to do:\nProvide appropriate metadata sources.")
+			o.Log(output.Error, "invalid metadata priority", map[string]any{
+				"rejected":                 failedCandidates,
+				SearchMetadataPriorityFlag: rawValue,
+			})
+		}
+	}
+	return priority, ok
+}
+
+// EvaluateCompilations returns the artist directory names that hold
+// compilations; surrounding spaces are ignored, and an empty value means that no
+// artist directory holds compilations
+func EvaluateCompilations(o output.Bus, values map[string]*FlagValue) ([]string, bool) {
+	rawValue, _, err := GetString(o, values, SearchCompilations)
+	if err != nil {
+		return nil, false
+	}
+	names := []string{}
+	for _, name := range strings.Split(rawValue, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, true
+}
+
+func EvaluateLayout(o output.Bus, values map[string]*FlagValue) (*files.Layout, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchLayout)
+	if err != nil {
+		return nil, false
+	}
+	layout, err := files.ParseLayout(rawValue)
+	if err != nil {
+		o.WriteCanonicalError("The %s value %q cannot be used", SearchLayoutFlag, rawValue)
+		o.WriteCanonicalError("Why?\n%v", err)
+		o.WriteCanonicalError("What to do:\n"+
+			"Use a template such as %q, with one path component for each directory"+
+			" level below %s, followed by one for the track file names, without their"+
+			" extensions", files.DefaultLayout, SearchTopDirFlag)
+		o.Log(output.Error, "invalid layout", map[string]any{
+			"error":          err,
+			SearchLayoutFlag: rawValue,
+			"user-set":       userSet,
+		})
+		return nil, false
+	}
+	return layout, true
+}
+
+// EvaluateTrackNames returns the patterns used to parse track file names, one
+// per line of the value; blank lines are ignored, and an empty value selects the
+// built-in patterns, which is indicated by returning nil
+func EvaluateTrackNames(o output.Bus, values map[string]*FlagValue) ([]*regexp.Regexp, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchTrackNames)
+	if err != nil {
+		return nil, false
+	}
+	var patterns []*regexp.Regexp
+	ok := true
+	for _, line := range strings.Split(rawValue, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		pattern, err := files.ParseTrackNamePattern(line)
+		if err != nil {
+			o.WriteCanonicalError("The %s value %q cannot be used", SearchTrackNamesFlag, line)
+			o.WriteCanonicalError("Why?\n%v", err)
+			o.WriteCanonicalError("What to do:\n"+
+				"Use a regular expression that matches track file names without their"+
+				" extensions, and that names the track number and title with the groups"+
+				" (?P<%s>...) and (?P<%s>...)", files.TrackNameTrack, files.TrackNameTitle)
+			o.Log(output.Error, "invalid track name pattern", map[string]any{
+				"error":              err,
+				SearchTrackNamesFlag: line,
+				"user-set":           userSet,
+			})
+			ok = false
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	if !ok {
+		return nil, false
+	}
+	return patterns, true
+}
+
+// EvaluateTopDir returns the top directories named by the --topDir value, which
+// may list several directories, separated as in the PATH environment variable;
+// a directory listed more than once, even by different paths, is used once, and
+// a directory inside another one cannot be used, as its tracks would be found
+// twice
+func EvaluateTopDir(o output.Bus, values map[string]*FlagValue) (dirs []string, ok bool) {
+	rawValue, userSet, err := GetString(o, values, SearchTopDir)
+	if err != nil {
+		return
+	}
+	candidates := filepath.SplitList(rawValue)
+	if len(candidates) == 0 {
+		// an empty value names no directory at all; let the check explain why
+		candidates = []string{rawValue}
+	}
+	ok = true
+	for _, candidate := range candidates {
+		if !evaluateTopDir(o, candidate, userSet) {
+			ok = false
+			continue
+		}
+		if slices.ContainsFunc(dirs, func(dir string) bool {
+			return sameFile(dir, candidate)
+		}) {
+			continue
+		}
+		if index := slices.IndexFunc(dirs, func(dir string) bool {
+			return isWithin(candidate, dir) || isWithin(dir, candidate)
+		}); index != -1 {
+			reportNestedTopDirs(o, dirs[index], candidate, userSet)
+			ok = false
+			continue
+		}
+		dirs = append(dirs, candidate)
+	}
+	if !ok {
+		dirs = nil
+	}
+	return
+}
+
+func reportNestedTopDirs(o output.Bus, dir1, dir2 string, userSet bool) {
+	o.WriteCanonicalError("The %s value, %q, cannot be used", SearchTopDirFlag, dir2)
+	o.Log(output.Error, "nested top directories", map[string]any{
+		SearchTopDirFlag: dir2,
+		"other":          dir1,
+		"user-set":       userSet,
+	})
+	o.WriteCanonicalError("Why?\nOne of the directories %q and %q is inside the other, so"+
+		" the track files of the inner one would be found twice", dir1, dir2)
+	if userSet {
+		o.WriteCanonicalError("What to do:\nSpecify only one of the two directories.")
+	} else {
+		o.WriteCanonicalError("What to do:\n"+
+			"Edit the configuration file or specify %s so that it names only one of the"+
+			" two directories.", SearchTopDirFlag)
+	}
+}
+
+// sameFile returns true if the paths name the same file: either they are the
+// same path once made absolute, or they lead to the same file, as through a
+// symbolic link
+func sameFile(path1, path2 string) bool {
+	abs1, err1 := filepath.Abs(path1)
+	abs2, err2 := filepath.Abs(path2)
+	if err1 == nil && err2 == nil && abs1 == abs2 {
+		return true
+	}
+	info1, err1 := os.Stat(path1)
+	info2, err2 := os.Stat(path2)
+	return err1 == nil && err2 == nil && os.SameFile(info1, info2)
+}
+
+// isWithin returns true if the directory is the parent directory, or lies
+// somewhere inside it, once both are made absolute and their symbolic links are
+// resolved
+func isWithin(dir, parent string) bool {
+	absDir, err1 := resolvedPath(dir)
+	absParent, err2 := resolvedPath(parent)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absParent, absDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvedPath returns the absolute path, with its symbolic links resolved if it
+// exists
+func resolvedPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+func evaluateTopDir(o output.Bus, rawValue string, userSet bool) (ok bool) {
+	if file, err := os.Stat(rawValue); err != nil {
+		o.WriteCanonicalError("The %s value, %q, cannot be used", SearchTopDirFlag,
+			rawValue)
+		o.Log(output.Error, "invalid directory", map[string]any{
+			"error":          err,
+			SearchTopDirFlag: rawValue,
+			"user-set":       userSet,
+		})
+		o.WriteCanonicalError("Why?")
+		if userSet {
+			o.WriteCanonicalError("The value you specified is not a readable file.")
+			o.WriteCanonicalError(
+				"What to do:\nSpecify a value that is a readable file.")
+		} else {
+			o.WriteCanonicalError(
+				"The currently configured value is not a readable file.")
+			o.WriteCanonicalError("What to do:\n"+
+				"Edit the configuration file or specify %s with a value that is a"+
+				" readable file.", SearchTopDirFlag)
+		}
+	} else {
+		if file.IsDir() {
+			ok = true
+		} else {
 			o.WriteCanonicalError("The %s value, %q, cannot be used", SearchTopDirFlag,
 				rawValue)
-			o.Log(output.Error, "invalid directory", map[string]any{
-				"error":          err,
+			o.Log(output.Error, "the file is not a directory", map[string]any{
 				SearchTopDirFlag: rawValue,
 				"user-set":       userSet,
 			})
 			o.WriteCanonicalError("Why?")
 			if userSet {
-				o.WriteCanonicalError("The value you specified is not a readable file.")
 				o.WriteCanonicalError(
-					"What to do:\nSpecify a value that is a readable file.")
+					"The value you specified is not the name of a directory.")
+				o.WriteCanonicalError("What to do:\n" +
+					"Specify a value that is the name of a directory.")
 			} else {
 				o.WriteCanonicalError(
-					"The currently configured value is not a readable file.")
+					"The currently configured value is not the name of a directory.")
 				o.WriteCanonicalError("What to do:\n"+
-					"Edit the configuration file or specify %s with a value that is a"+
-					" readable file.", SearchTopDirFlag)
-			}
-		} else {
-			if file.IsDir() {
-				dir = rawValue
-				ok = true
-			} else {
-				o.WriteCanonicalError("The %s value, %q, cannot be used", SearchTopDirFlag,
-					rawValue)
-				o.Log(output.Error, "the file is not a directory", map[string]any{
-					SearchTopDirFlag: rawValue,
-					"user-set":       userSet,
-				})
-				o.WriteCanonicalError("Why?")
-				if userSet {
-					o.WriteCanonicalError(
-						"The value you specified is not the name of a directory.")
-					o.WriteCanonicalError("What to do:\n" +
-						"Specify a value that is the name of a directory.")
-				} else {
-					o.WriteCanonicalError(
-						"The currently configured value is not the name of a directory.")
-					o.WriteCanonicalError("What to do:\n"+
-						"Edit the configuration file or specify %s with a value that is the"+
-						" name of a directory.", SearchTopDirFlag)
-				}
+					"Edit the configuration file or specify %s with a value that is the"+
+					" name of a directory.", SearchTopDirFlag)
 			}
 		}
 	}
@@ -301,15 +917,18 @@ func (ss *SearchSettings) Filter(o output.Bus,
 			filteredArtist := originalArtist.Copy()
 			for _, originalAlbum := range originalArtist.Albums() {
 				if ss.albumFilter.MatchString(originalAlbum.Name()) &&
-					originalAlbum.HasTracks() {
+					(originalAlbum.HasTracks() || len(originalAlbum.UnparsedTracks()) > 0) {
 					filteredAlbum := originalAlbum.Copy(filteredArtist, false)
 					for _, originalTrack := range originalAlbum.Tracks() {
-						if ss.trackFilter.MatchString(originalTrack.CommonName()) {
+						if ss.trackFilter.MatchString(originalTrack.CommonName()) &&
+							ss.metadataMatches(originalTrack) &&
+							(ss.where == nil || ss.where.Matches(originalTrack)) {
 							filteredTrack := originalTrack.Copy(filteredAlbum)
 							filteredAlbum.AddTrack(filteredTrack)
 						}
 					}
-					if filteredAlbum.HasTracks() {
+					ss.filterUnparsedTracks(originalAlbum, filteredAlbum)
+					if filteredAlbum.HasTracks() || len(filteredAlbum.UnparsedTracks()) > 0 {
 						filteredArtist.AddAlbum(filteredAlbum)
 					}
 				}
@@ -323,73 +942,349 @@ func (ss *SearchSettings) Filter(o output.Bus,
 	if !ok {
 		o.WriteCanonicalError("No music files remain after filtering.")
 		o.WriteCanonicalError("Why?")
-		o.WriteCanonicalError("After applying %s=%q, %s=%q, and %s=%q, no files remained",
-			SearchArtistFilterFlag, ss.artistFilter, SearchAlbumFilterFlag, ss.albumFilter,
-			SearchTrackFilterFlag, ss.trackFilter)
-		o.WriteCanonicalError("What to do:\nUse less restrictive filter settings.")
-		o.Log(output.Error, "no files remain after filtering", map[string]any{
+		applied := []string{
+			fmt.Sprintf("%s=%q", SearchArtistFilterFlag, ss.artistFilter),
+			fmt.Sprintf("%s=%q", SearchAlbumFilterFlag, ss.albumFilter),
+			fmt.Sprintf("%s=%q", SearchTrackFilterFlag, ss.trackFilter),
+		}
+		fields := map[string]any{
 			SearchArtistFilterFlag: ss.artistFilter,
 			SearchAlbumFilterFlag:  ss.albumFilter,
 			SearchTrackFilterFlag:  ss.trackFilter,
-		})
+		}
+		for _, filter := range ss.activeMetadataFilters() {
+			applied = append(applied, fmt.Sprintf("%s=%q", filter.flag, filter.value))
+			fields[filter.flag] = filter.value
+		}
+		o.WriteCanonicalError("After applying %s, and %s, no files remained",
+			strings.Join(applied[:len(applied)-1], ", "), applied[len(applied)-1])
+		o.WriteCanonicalError("What to do:\nUse less restrictive filter settings.")
+		o.Log(output.Error, "no files remain after filtering", fields)
 	}
 	return filteredArtists, ok
 }
 
-func (ss *SearchSettings) Load(o output.Bus) ([]*files.Artist, bool) {
-	artistFiles, dirRead := ReadDirectory(o, ss.topDirectory)
-	artists := make([]*files.Artist, 0, len(artistFiles))
-	if dirRead {
-		for _, artistFile := range artistFiles {
-			if artistFile.IsDir() {
-				artist := files.NewArtistFromFile(artistFile, ss.topDirectory)
-				ss.addAlbums(o, artist)
-				artists = append(artists, artist)
-			}
+// filterUnparsedTracks copies the album's unparsed track files that pass the
+// track filter, which is matched against the file name, as there is no track
+// name. Their metadata is never read, so the metadata filters and the --where
+// expression do not apply to them; while any of those is active, though, they
+// are only kept in an album that has tracks that pass it, as they cannot select
+// an album by themselves.
+func (ss *SearchSettings) filterUnparsedTracks(originalAlbum, filteredAlbum *files.Album) {
+	if !filteredAlbum.HasTracks() && len(ss.activeMetadataFilters()) > 0 {
+		return
+	}
+	for _, fileName := range originalAlbum.UnparsedTracks() {
+		if ss.trackFilter.MatchString(filepath.Base(fileName)) {
+			filteredAlbum.AddUnparsedTrack(fileName)
 		}
 	}
+}
+
+// Load finds the artists, albums, and tracks under the top directories, and
+// reads the tracks' metadata if any of the metadata filters, or the --where
+// expression, need it. When the context is cancelled while the directories or
+// the metadata are being read, nothing is loaded.
+func (ss *SearchSettings) Load(ctx context.Context, o output.Bus) ([]*files.Artist, bool) {
+	defaultLayout := ss.layout == nil || ss.layout.IsDefault()
+	descend := descendDefault
+	if !defaultLayout {
+		descend = ss.descendLayout
+	}
+	var found [][]*files.Artist
+	read := 0
+	ss.ignored = nil
+	for _, topDir := range ss.topDirectories {
+		// the global patterns were checked when the flags were read
+		rules, _ := files.ParseIgnoreRules(ss.ignore, topDir, SearchIgnoreFlag)
+		dirs := walkDirectories(ctx, o, topDir, rules, descend)
+		read += dirs.count()
+		if ctx.Err() != nil {
+			o.WriteCanonicalError("The search for music files was interrupted after %d"+
+				" directories were read", read)
+			o.Log(output.Info, "search interrupted", map[string]any{
+				"directories":    read,
+				SearchTopDirFlag: ss.topDirectoryList(),
+			})
+			return nil, false
+		}
+		ss.ignored = append(ss.ignored, dirs.ignored()...)
+		if defaultLayout {
+			found = append(found, ss.loadArtists(o, dirs, topDir))
+		} else {
+			found = append(found, ss.walkLayout(o, dirs, topDir, 0, files.LayoutValues{},
+				map[string]*files.Artist{}, nil, nil))
+		}
+	}
+	if len(ss.ignored) > 0 {
+		o.Log(output.Info, "files and directories ignored", map[string]any{
+			"count":          len(ss.ignored),
+			SearchTopDirFlag: ss.topDirectoryList(),
+		})
+	}
+	artists := mergeArtists(found)
+	if ss.readsTags() && LoadArtistMetadata(ctx, o, artists) != nil {
+		return nil, false
+	}
+	if ss.where.checksFiles() {
+		// file concerns compare each track with the values chosen for its album
+		// and artist; any ambiguity in choosing them is reported by the commands
+		// that read the metadata for themselves
+		files.ProcessAlbumMetadata(output.NewNilBus(), artists)
+		files.ProcessArtistMetadata(output.NewNilBus(), artists)
+	}
 	ok := len(artists) > 0
 	if !ok {
 		o.WriteCanonicalError(
 			"No music files could be found using the specified parameters.")
 		o.WriteCanonicalError("Why?")
 		o.WriteCanonicalError("There were no directories found in %q (the %s value)",
-			ss.topDirectory, SearchTopDirFlag)
+			ss.topDirectoryList(), SearchTopDirFlag)
 		o.WriteCanonicalError("What to do:\n"+
 			"Set %s to the path of a directory that contains artist directories",
 			SearchTopDirFlag)
 		o.Log(output.Error, "cannot find any artist directories", map[string]any{
-			SearchTopDirFlag: ss.topDirectory,
+			SearchTopDirFlag: ss.topDirectoryList(),
 		})
 	}
 	return artists, ok
 }
 
-func (ss *SearchSettings) addAlbums(o output.Bus, artist *files.Artist) {
-	if albumFiles, artistDirRead := ReadDirectory(o, artist.Path()); artistDirRead {
+// readSettings returns the settings with which the loaded artists' tracks are
+// read; settings that were not specified keep their defaults
+func (ss *SearchSettings) readSettings() *files.ReadSettings {
+	settings := files.NewReadSettings()
+	if len(ss.metadataPriority) > 0 {
+		settings.WithPrimarySourcePriority(ss.metadataPriority)
+	}
+	if ss.metadataCache != "" {
+		settings.WithMetadataCacheMode(ss.metadataCache)
+	}
+	if ss.concurrency > 0 {
+		settings.WithMetadataReaders(ss.concurrency)
+	}
+	return settings
+}
+
+// isCompilation returns true if the artist directory name is one of the
+// compilation artist names, without regard to case
+func (ss *SearchSettings) isCompilation(name string) bool {
+	names := ss.compilations
+	if names == nil {
+		names = files.DefaultCompilationArtists()
+	}
+	return slices.ContainsFunc(names, func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+// trackNamePatterns returns the patterns used to parse track file names
+func (ss *SearchSettings) trackNamePatterns() []*regexp.Regexp {
+	if ss.trackNames == nil {
+		return files.DefaultTrackNamePatterns()
+	}
+	return ss.trackNames
+}
+
+// configureArtist marks a newly found artist as holding compilations, if it
+// does, and attaches the settings with which its tracks are read
+func (ss *SearchSettings) configureArtist(artist *files.Artist) *files.Artist {
+	return artist.WithCompilation(ss.isCompilation(artist.Name())).WithReadSettings(
+		ss.readSettings())
+}
+
+// mergeArtists combines the artists found under each top directory into one
+// list; the first artist found with a name takes the albums of every later
+// artist of the same name, whether found under the same top directory or
+// another one
+func mergeArtists(found [][]*files.Artist) []*files.Artist {
+	merged := []*files.Artist{}
+	earlier := map[string]*files.Artist{}
+	for _, artists := range found {
+		for _, artist := range artists {
+			if match, exists := earlier[artist.Name()]; exists {
+				match.MergeAlbums(artist)
+			} else {
+				earlier[artist.Name()] = artist
+				merged = append(merged, artist)
+			}
+		}
+	}
+	return merged
+}
+
+// descendDefault selects the directories to read below the top directory in the
+// default layout: artists, their albums, and the albums' disc subdirectories
+func descendDefault(depth int, entry fs.DirEntry) bool {
+	if depth < 2 {
+		return true
+	}
+	_, isDisc := files.DiscDirectoryNumber(entry.Name())
+	return depth == 2 && isDisc
+}
+
+// descendLayout selects the directories to read below the top directory in a
+// layout: those whose names match the layout at their level
+func (ss *SearchSettings) descendLayout(depth int, entry fs.DirEntry) bool {
+	if depth >= ss.layout.TrackLevel() {
+		return false
+	}
+	_, isMatch := ss.layout.Match(depth, entry.Name(), nil)
+	return isMatch
+}
+
+func (ss *SearchSettings) loadArtists(o output.Bus, dirs *directoryListings,
+	topDir string) []*files.Artist {
+	artistFiles, dirRead := dirs.read(o, topDir)
+	artists := make([]*files.Artist, 0, len(artistFiles))
+	if dirRead {
+		for _, artistFile := range artistFiles {
+			if artistFile.IsDir() {
+				artist := ss.configureArtist(files.NewArtistFromFile(artistFile, topDir))
+				ss.addAlbums(o, dirs, artist)
+				artists = append(artists, artist)
+			}
+		}
+	}
+	return artists
+}
+
+// walkLayout walks the directory at the layout's level, matching the names of
+// its entries against the layout and descending into the directories that
+// match; artists are created at the layout's artist level, albums at its album
+// level, and tracks at its track level, from the values matched along the way,
+// so that an album's genre and year may come from its path. An artist whose name
+// is matched in more than one directory, as "Rock/Beatles" and "Pop/Beatles"
+// are by "{genre}/{artist}", is created once, from the first such directory, and
+// holds the albums found in all of them; known maps the names of the artists
+// created so far to those artists, and only newly created artists are returned
+func (ss *SearchSettings) walkLayout(o output.Bus, dirs *directoryListings, dir string,
+	level int, values files.LayoutValues, known map[string]*files.Artist,
+	artist *files.Artist, album *files.Album) []*files.Artist {
+	entries, dirRead := dirs.read(o, dir)
+	if !dirRead {
+		return nil
+	}
+	var artists []*files.Artist
+	for _, entry := range entries {
+		if level == ss.layout.TrackLevel() {
+			ss.addLayoutTrack(o, album, dir, entry, values)
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+		matched, isMatch := ss.layout.Match(level, entry.Name(), values)
+		if !isMatch {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		switch level {
+		case ss.layout.ArtistLevel():
+			name := matched[files.LayoutArtist]
+			matchedArtist, found := known[name]
+			if !found {
+				matchedArtist = ss.configureArtist(files.NewArtist(name, path))
+				known[name] = matchedArtist
+				artists = append(artists, matchedArtist)
+			}
+			ss.walkLayout(o, dirs, path, level+1, matched, known, matchedArtist, nil)
+		case ss.layout.AlbumLevel():
+			newAlbum := files.NewAlbum(matched[files.LayoutAlbum], artist,
+				path).WithPathGenre(matched[files.LayoutGenre]).WithPathYear(
+				matched[files.LayoutYear])
+			ss.walkLayout(o, dirs, path, level+1, matched, known, artist, newAlbum)
+			artist.AddAlbum(newAlbum)
+		default:
+			artists = append(artists, ss.walkLayout(o, dirs, path, level+1, matched,
+				known, artist, album)...)
+		}
+	}
+	return artists
+}
+
+// addLayoutTrack adds the track file to the album, if it is a valid track file
+// whose name, without its extension, matches the layout
+func (ss *SearchSettings) addLayoutTrack(o output.Bus, album *files.Album, dir string,
+	trackFile fs.DirEntry, values files.LayoutValues) {
+	extension, isTrack := ss.isValidTrackFile(trackFile)
+	if !isTrack {
+		return
+	}
+	name := trackFile.Name()
+	fileName, _ := filepath.Rel(album.Path(), filepath.Join(dir, name))
+	matched, isMatch := ss.layout.Match(ss.layout.TrackLevel(),
+		strings.TrimSuffix(name, extension), values)
+	if !isMatch {
+		o.Log(output.Error, "the track name does not match the layout", map[string]any{
+			"trackName":      name,
+			"albumName":      album.Name(),
+			"artistName":     album.RecordingArtistName(),
+			SearchLayoutFlag: ss.layout,
+		})
+		album.AddUnparsedTrack(fileName)
+		return
+	}
+	album.AddTrack(files.NewTrack(album, fileName, matched[files.LayoutTitle],
+		matched.Number(files.LayoutTrack)).WithDisc(matched.Number(files.LayoutDisc)))
+}
+
+func (ss *SearchSettings) addAlbums(o output.Bus, dirs *directoryListings,
+	artist *files.Artist) {
+	if albumFiles, artistDirRead := dirs.read(o, artist.Path()); artistDirRead {
 		for _, albumFile := range albumFiles {
 			if albumFile.IsDir() {
 				album := files.NewAlbumFromFile(albumFile, artist)
-				ss.addTracks(o, album)
+				ss.addTracks(o, dirs, album)
 				artist.AddAlbum(album)
 			}
 		}
 	}
 }
 
-func (ss *SearchSettings) addTracks(o output.Bus, album *files.Album) {
-	if trackFiles, ok := ReadDirectory(o, album.Path()); ok {
+// addTracks adds the album's track files to the album, including those in disc
+// subdirectories, such as "CD1" and "CD2", which hold the discs of a multi-disc
+// album
+func (ss *SearchSettings) addTracks(o output.Bus, dirs *directoryListings,
+	album *files.Album) {
+	if trackFiles, ok := dirs.read(o, album.Path()); ok {
 		for _, trackFile := range trackFiles {
-			if extension, isTrack := ss.isValidTrackFile(trackFile); isTrack {
-				if simpleName, trackNumber, valid := files.ParseTrackName(o,
-					trackFile.Name(), album, extension); valid {
-					album.AddTrack(files.NewTrack(album, trackFile.Name(), simpleName,
-						trackNumber))
+			if trackFile.IsDir() {
+				if disc, isDisc := files.DiscDirectoryNumber(trackFile.Name()); isDisc {
+					ss.addDiscTracks(o, dirs, album, trackFile.Name(), disc)
 				}
+				continue
 			}
+			ss.addTrack(o, album, trackFile, "", 0)
 		}
 	}
+}
 
+func (ss *SearchSettings) addDiscTracks(o output.Bus, dirs *directoryListings,
+	album *files.Album, discDir string, disc int) {
+	if trackFiles, ok := dirs.read(o, filepath.Join(album.Path(), discDir)); ok {
+		for _, trackFile := range trackFiles {
+			ss.addTrack(o, album, trackFile, discDir, disc)
+		}
+	}
+}
+
+// addTrack adds the track file to the album, if it is a valid track file; a
+// disc subdirectory's disc number takes precedence over one in the file name
+func (ss *SearchSettings) addTrack(o output.Bus, album *files.Album, trackFile fs.DirEntry,
+	discDir string, disc int) {
+	if extension, isTrack := ss.isValidTrackFile(trackFile); isTrack {
+		if simpleName, performer, nameDisc, trackNumber, valid := files.ParseTrackName(o,
+			trackFile.Name(), album, extension, ss.trackNamePatterns()); valid {
+			if disc == 0 {
+				disc = nameDisc
+			}
+			album.AddTrack(files.NewTrack(album, filepath.Join(discDir, trackFile.Name()),
+				simpleName, trackNumber).WithDisc(disc).WithPerformer(performer))
+		} else {
+			album.AddUnparsedTrack(filepath.Join(discDir, trackFile.Name()))
+		}
+	}
 }
 
 func (ss *SearchSettings) isValidTrackFile(file fs.DirEntry) (string, bool) {
@@ -1,13 +1,18 @@
 package cmd_test
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
 	"mp3/cmd"
 	"mp3/internal/files"
+	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/majohn-r/output"
 )
@@ -130,10 +135,16 @@ func TestEvaluateFilter(t *testing.T) {
 }
 
 func TestEvaluateTopDir(t *testing.T) {
+	list := func(dirs ...string) string {
+		return strings.Join(dirs, string(os.PathListSeparator))
+	}
+	internalDir := filepath.Join("..", "internal")
+	filesDir := filepath.Join(internalDir, "files")
+	absoluteDir, _ := filepath.Abs(".")
 	tests := map[string]struct {
-		values  map[string]*cmd.FlagValue
-		wantDir string
-		wantOk  bool
+		values   map[string]*cmd.FlagValue
+		wantDirs []string
+		wantOk   bool
 		output.WantedRecording
 	}{
 		"missing flag": {
@@ -225,21 +236,95 @@ func TestEvaluateTopDir(t *testing.T) {
 				"topDir": cmd.NewFlagValue().WithExplicitlySet(false).WithValueType(
 					cmd.StringType).WithValue("."),
 			},
-			wantDir: ".",
-			wantOk:  true,
+			wantDirs: []string{"."},
+			wantOk:   true,
+		},
+		"several directories": {
+			values: map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(true).WithValueType(
+					cmd.StringType).WithValue(list(".", internalDir, "./")),
+			},
+			wantDirs: []string{".", internalDir},
+			wantOk:   true,
+		},
+		"same directory, relative and absolute": {
+			values: map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(true).WithValueType(
+					cmd.StringType).WithValue(list(".", absoluteDir)),
+			},
+			wantDirs: []string{"."},
+			wantOk:   true,
+		},
+		"nested directories, user set": {
+			values: map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(true).WithValueType(
+					cmd.StringType).WithValue(list(internalDir, filesDir)),
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "The --topDir value, \"..\\\\internal\\\\files\", cannot be used.\n" +
+					"Why?\n" +
+					"One of the directories \"..\\\\internal\" and" +
+					" \"..\\\\internal\\\\files\" is inside the other, so the track files of" +
+					" the inner one would be found twice.\n" +
+					"What to do:\n" +
+					"Specify only one of the two directories.\n",
+				Log: "level='error'" +
+					" --topDir='..\\internal\\files'" +
+					" other='..\\internal'" +
+					" user-set='true'" +
+					" msg='nested top directories'\n",
+			},
+		},
+		"nested directories, as configured": {
+			values: map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(false).WithValueType(
+					cmd.StringType).WithValue(list(filesDir, internalDir)),
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "The --topDir value, \"..\\\\internal\", cannot be used.\n" +
+					"Why?\n" +
+					"One of the directories \"..\\\\internal\\\\files\" and" +
+					" \"..\\\\internal\" is inside the other, so the track files of" +
+					" the inner one would be found twice.\n" +
+					"What to do:\n" +
+					"Edit the configuration file or specify --topDir so that it names only" +
+					" one of the two directories.\n",
+				Log: "level='error'" +
+					" --topDir='..\\internal'" +
+					" other='..\\internal\\files'" +
+					" user-set='false'" +
+					" msg='nested top directories'\n",
+			},
+		},
+		"several directories, one of them a file": {
+			values: map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(true).WithValueType(
+					cmd.StringType).WithValue(list(".", "./commonFlags_test.go", internalDir)),
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "The --topDir value, \"./commonFlags_test.go\", cannot be used.\n" +
+					"Why?\n" +
+					"The value you specified is not the name of a directory.\n" +
+					"What to do:\n" +
+					"Specify a value that is the name of a directory.\n",
+				Log: "level='error'" +
+					" --topDir='./commonFlags_test.go'" +
+					" user-set='true'" +
+					" msg='the file is not a directory'\n",
+			},
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			gotDir, gotOk := cmd.EvaluateTopDir(o, tt.values)
-			if gotDir != tt.wantDir {
-				t.Errorf("EvaluateTopDir() gotDir = %v, want %v", gotDir, tt.wantDir)
+			gotDirs, gotOk := cmd.EvaluateTopDir(o, tt.values)
+			if !reflect.DeepEqual(gotDirs, tt.wantDirs) {
+				t.Errorf("EvaluateTopDir() gotDirs = %v, want %v", gotDirs, tt.wantDirs)
 			}
 			if gotOk != tt.wantOk {
 				t.Errorf("EvaluateTopDir() gotOk = %v, want %v", gotOk, tt.wantOk)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("EvaluateTopDir() %s", difference)
 				}
@@ -248,7 +333,59 @@ func TestEvaluateTopDir(t *testing.T) {
 	}
 }
 
+func TestEvaluateTopDirSymbolicLink(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "Music")
+	link := filepath.Join(dir, "link")
+	if err := os.Mkdir(music, 0o755); err != nil {
+		t.Fatalf("EvaluateTopDir() cannot create %q: %v", music, err)
+	}
+	if err := os.Symlink(music, link); err != nil {
+		t.Skipf("EvaluateTopDir() cannot create symbolic link %q: %v", link, err)
+	}
+	tests := map[string]struct {
+		value    string
+		wantDirs []string
+		wantOk   bool
+	}{
+		"same directory through a link": {
+			value:    strings.Join([]string{music, link}, string(os.PathListSeparator)),
+			wantDirs: []string{music},
+			wantOk:   true,
+		},
+		"same directory through a link and a roundabout path": {
+			value: strings.Join([]string{link, filepath.Join(music, "..", "Music")},
+				string(os.PathListSeparator)),
+			wantDirs: []string{link},
+			wantOk:   true,
+		},
+		"link inside another top directory": {
+			value: strings.Join([]string{dir, link}, string(os.PathListSeparator)),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			values := map[string]*cmd.FlagValue{
+				"topDir": cmd.NewFlagValue().WithExplicitlySet(true).WithValueType(
+					cmd.StringType).WithValue(tt.value),
+			}
+			gotDirs, gotOk := cmd.EvaluateTopDir(output.NewNilBus(), values)
+			if !reflect.DeepEqual(gotDirs, tt.wantDirs) {
+				t.Errorf("EvaluateTopDir() gotDirs = %v, want %v", gotDirs, tt.wantDirs)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("EvaluateTopDir() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
 func TestProcessSearchFlags(t *testing.T) {
+	years, _ := cmd.ParseNumberRange("-1979")
+	bitrates, _ := cmd.ParseNumberRange("192-320")
+	durations, _ := cmd.ParseDurationRange("2m-")
+	where, _ := cmd.ParseWhereExpression("not hasConcern(numbering)")
+	genreLayout, _ := files.ParseLayout("{genre}/{artist}/{year} - {album}/{track:02} {title}")
 	tests := map[string]struct {
 		values       map[string]*cmd.FlagValue
 		wantSettings *cmd.SearchSettings
@@ -262,8 +399,24 @@ func TestProcessSearchFlags(t *testing.T) {
 				Error: "An internal error occurred: flag \"albumFilter\" is not found.\n" +
 					"An internal error occurred: flag \"artistFilter\" is not found.\n" +
 					"An internal error occurred: flag \"trackFilter\" is not found.\n" +
+					"An internal error occurred: flag \"genreFilter\" is not found.\n" +
+					"An internal error occurred: flag \"tagArtistFilter\" is not found.\n" +
+					"An internal error occurred: flag \"tagAlbumFilter\" is not found.\n" +
+					"An internal error occurred: flag \"tagTitleFilter\" is not found.\n" +
 					"An internal error occurred: flag \"topDir\" is not found.\n" +
-					"An internal error occurred: flag \"extensions\" is not found.\n",
+					"An internal error occurred: flag \"extensions\" is not found.\n" +
+					"An internal error occurred: flag \"metadataPriority\" is not found.\n" +
+					"An internal error occurred: flag \"compilations\" is not found.\n" +
+					"An internal error occurred: flag \"layout\" is not found.\n" +
+					"An internal error occurred: flag \"trackNames\" is not found.\n" +
+					"An internal error occurred: flag \"metadataCache\" is not found.\n" +
+					"An internal error occurred: flag \"concurrency\" is not found.\n" +
+					"An internal error occurred: flag \"ignore\" is not found.\n" +
+					"An internal error occurred: flag \"yearRange\" is not found.\n" +
+					"An internal error occurred: flag \"bitrateRange\" is not found.\n" +
+					"An internal error occurred: flag \"durationRange\" is not found.\n" +
+					"An internal error occurred: flag \"id3Versions\" is not found.\n" +
+					"An internal error occurred: flag \"where\" is not found.\n",
 				Log: "level='error'" +
 					" error='flag not found'" +
 					" flag='albumFilter'" +
@@ -278,11 +431,75 @@ func TestProcessSearchFlags(t *testing.T) {
 					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
+					" flag='genreFilter'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='tagArtistFilter'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='tagAlbumFilter'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='tagTitleFilter'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
 					" flag='topDir'" +
 					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag not found'" +
 					" flag='extensions'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='metadataPriority'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='compilations'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='layout'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='trackNames'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='metadataCache'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='concurrency'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='ignore'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='yearRange'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='bitrateRange'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='durationRange'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='id3Versions'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag not found'" +
+					" flag='where'" +
 					" msg='internal error'\n",
 			},
 		},
@@ -298,8 +515,40 @@ func TestProcessSearchFlags(t *testing.T) {
 					"no such dir"),
 				"extensions": cmd.NewFlagValue().WithValueType(cmd.StringType).WithValue(
 					"foo,bar"),
+				"metadataPriority": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("ID3V3,id3v1"),
+				"compilations": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"layout": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("{artist}/{album}"),
+				"trackNames": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("(?P<track>[0-9]+) (?P<name>.+)\n(?P<track>"),
+				"metadataCache": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("sometimes"),
+				"concurrency": cmd.NewFlagValue().WithValueType(
+					cmd.IntType).WithValue(0),
+				"ignore": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("live/\n[bootleg"),
+				"genreFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("[Rock"),
+				"tagArtistFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"tagAlbumFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"tagTitleFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"yearRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("1979-1970"),
+				"bitrateRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("fast-"),
+				"durationRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("-"),
+				"id3Versions": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("3,5,x"),
+				"where": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("year >= 1990 or disc = 2"),
 			},
-			wantSettings: cmd.NewSearchSettings(),
+			wantSettings: cmd.NewSearchSettings().WithCompilations([]string{}),
 			WantedRecording: output.WantedRecording{
 				Error: "The --albumFilter value \"[2\" cannot be used.\n" +
 					"Why?\n" +
@@ -321,6 +570,13 @@ func TestProcessSearchFlags(t *testing.T) {
 					"What to do:\n" +
 					"Either edit the defaults.yaml file containing the settings, or" +
 					" explicitly set --trackFilter to a better value.\n" +
+					"The --genreFilter value \"[Rock\" cannot be used.\n" +
+					"Why?\n" +
+					"The configured default value of --genreFilter is not a valid regular" +
+					" expression: error parsing regexp: missing closing ]: `[Rock`.\n" +
+					"What to do:\n" +
+					"Either edit the defaults.yaml file containing the settings, or" +
+					" explicitly set --genreFilter to a better value.\n" +
 					"Here are some common errors in filter expressions and what to do:\n" +
 					"Character class problems\n" +
 					"Character classes are sets of 1 or more characters, enclosed in square" +
@@ -357,7 +613,88 @@ func TestProcessSearchFlags(t *testing.T) {
 					"Why?\n" +
 					"Extensions must be at least two characters long and begin with '.'.\n" +
 					"What to do:\n" +
-					"Provide appropriate extensions.\n",
+					"Provide appropriate extensions.\n" +
+					"The metadata source \"ID3V3\" cannot be used.\n" +
+					"Why?\n" +
+					"The supported metadata sources are ID3V1, ID3V2, APEV2, FLAC, MP4, OGG.\n" +
+					"What to do:\n" +
+					"Provide appropriate metadata sources.\n" +
+					"The --layout value \"{artist}/{album}\" cannot be used.\n" +
+					"Why?\n" +
+					"the layout \"{artist}/{album}\" does not include the {track} field.\n" +
+					"What to do:\n" +
+					"Use a template such as \"{artist}/{album}/{track} {title}\", with one" +
+					" path component for each directory level below --topDir, followed by" +
+					" one for the track file names, without their extensions.\n" +
+					"The --trackNames value \"(?P<track>[0-9]+) (?P<name>.+)\" cannot be used.\n" +
+					"Why?\n" +
+					"the track name pattern \"(?P<track>[0-9]+) (?P<name>.+)\" names the" +
+					" group \"name\", which is not one of \"artist\", \"disc\", \"side\"," +
+					" \"title\", or \"track\".\n" +
+					"What to do:\n" +
+					"Use a regular expression that matches track file names without their" +
+					" extensions, and that names the track number and title with the groups" +
+					" (?P<track>...) and (?P<title>...).\n" +
+					"The --trackNames value \"(?P<track>\" cannot be used.\n" +
+					"Why?\n" +
+					"error parsing regexp: missing closing ): `(?P<track>`.\n" +
+					"What to do:\n" +
+					"Use a regular expression that matches track file names without their" +
+					" extensions, and that names the track number and title with the groups" +
+					" (?P<track>...) and (?P<title>...).\n" +
+					"The --metadataCache value \"sometimes\" cannot be used.\n" +
+					"Why?\n" +
+					"The value must be one of use, bypass, rebuild.\n" +
+					"What to do:\n" +
+					"Use \"use\" to reuse the metadata of unchanged track files, \"bypass\" to" +
+					" read every track file without the cache, or \"rebuild\" to read every" +
+					" track file and replace the cache.\n" +
+					"The --concurrency value 0 cannot be used.\n" +
+					"Why?\n" +
+					"The value must be at least 1 and at most 100.\n" +
+					"What to do:\n" +
+					"Use a larger value to read track files on slow drives or network shares" +
+					" faster, or a smaller value to use fewer open files; 20 suits most" +
+					" libraries.\n" +
+					"The --ignore value contains a pattern that cannot be used.\n" +
+					"Why?\n" +
+					"line 2 of --ignore: the pattern \"[bootleg\" has a '[' without a" +
+					" matching ']'.\n" +
+					"What to do:\n" +
+					"Either edit the defaults.yaml file containing the settings, or" +
+					" explicitly set --ignore to a better value.\n" +
+					"The --yearRange value \"1979-1970\" cannot be used.\n" +
+					"Why?\n" +
+					"the range ends (1970) before it begins (1979).\n" +
+					"What to do:\n" +
+					"Use a range such as \"1970-1979\", leaving out either end to leave it" +
+					" open, or an empty value to select every track.\n" +
+					"The --bitrateRange value \"fast-\" cannot be used.\n" +
+					"Why?\n" +
+					"\"fast\" is not a whole number.\n" +
+					"What to do:\n" +
+					"Use a range such as \"192-320\", leaving out either end to leave it" +
+					" open, or an empty value to select every track.\n" +
+					"The --durationRange value \"-\" cannot be used.\n" +
+					"Why?\n" +
+					"neither end of the range is given.\n" +
+					"What to do:\n" +
+					"Use a range such as \"2m-10m30s\", leaving out either end to leave it" +
+					" open, or an empty value to select every track.\n" +
+					"The ID3V2 version \"5\" cannot be used.\n" +
+					"The ID3V2 version \"x\" cannot be used.\n" +
+					"Why?\n" +
+					"The ID3V2 versions are 2, 3, and 4.\n" +
+					"What to do:\n" +
+					"Provide a comma-delimited list of ID3V2 versions, using 0 for tracks" +
+					" that have no ID3V2 tag.\n" +
+					"The --where value \"year >= 1990 or disc = 2\" cannot be used.\n" +
+					"Why?\n" +
+					"The expression cannot be read at column 22, \"=\": this is not an" +
+					" operator; the operators are ==, !=, !~, <=, >=, ~, <, >.\n" +
+					"What to do:\n" +
+					"Either edit the defaults.yaml file containing the settings, or" +
+					" explicitly set --where to a better value.\n",
 				Log: "level='error'" +
 					" --albumFilter='[2'" +
 					" error='error parsing regexp: missing closing ]: `[2`'" +
@@ -375,6 +712,11 @@ func TestProcessSearchFlags(t *testing.T) {
 					" user-set='false'" +
 					" msg='the filter cannot be parsed as a regular expression'\n" +
 					"level='error'" +
+					" --genreFilter='[Rock'" +
+					" error='error parsing regexp: missing closing ]: `[Rock`'" +
+					" user-set='false'" +
+					" msg='the filter cannot be parsed as a regular expression'\n" +
+					"level='error'" +
 					" --topDir='no such dir'" +
 					" error='CreateFile no such dir: The system cannot find the file" +
 					" specified.'" +
@@ -383,7 +725,69 @@ func TestProcessSearchFlags(t *testing.T) {
 					"level='error'" +
 					" --extensions='foo,bar'" +
 					" rejected='[foo bar]'" +
-					" msg='invalid file extensions'\n",
+					" msg='invalid file extensions'\n" +
+					"level='error'" +
+					" --metadataPriority='ID3V3,id3v1'" +
+					" rejected='[ID3V3]'" +
+					" msg='invalid metadata priority'\n" +
+					"level='error'" +
+					" --layout='{artist}/{album}'" +
+					" error='the layout \"{artist}/{album}\" does not include the {track}" +
+					" field'" +
+					" user-set='false'" +
+					" msg='invalid layout'\n" +
+					"level='error'" +
+					" --trackNames='(?P<track>[0-9]+) (?P<name>.+)'" +
+					" error='the track name pattern \"(?P<track>[0-9]+) (?P<name>.+)\" names" +
+					" the group \"name\", which is not one of \"artist\", \"disc\", \"side\"," +
+					" \"title\", or \"track\"'" +
+					" user-set='false'" +
+					" msg='invalid track name pattern'\n" +
+					"level='error'" +
+					" --trackNames='(?P<track>'" +
+					" error='error parsing regexp: missing closing ): `(?P<track>`'" +
+					" user-set='false'" +
+					" msg='invalid track name pattern'\n" +
+					"level='error'" +
+					" --metadataCache='sometimes'" +
+					" user-set='false'" +
+					" msg='invalid metadata cache mode'\n" +
+					"level='error'" +
+					" --concurrency='0'" +
+					" user-set='false'" +
+					" msg='invalid concurrency'\n" +
+					"level='error'" +
+					" --ignore='live/\n[bootleg'" +
+					" error='line 2 of --ignore: the pattern \"[bootleg\" has a '[' without" +
+					" a matching ']''" +
+					" user-set='false'" +
+					" msg='invalid ignore pattern'\n" +
+					"level='error'" +
+					" --yearRange='1979-1970'" +
+					" error='the range ends (1970) before it begins (1979)'" +
+					" user-set='false'" +
+					" msg='invalid range'\n" +
+					"level='error'" +
+					" --bitrateRange='fast-'" +
+					" error='\"fast\" is not a whole number'" +
+					" user-set='false'" +
+					" msg='invalid range'\n" +
+					"level='error'" +
+					" --durationRange='-'" +
+					" error='neither end of the range is given'" +
+					" user-set='false'" +
+					" msg='invalid range'\n" +
+					"level='error'" +
+					" --id3Versions='3,5,x'" +
+					" rejected='[5 x]'" +
+					" user-set='false'" +
+					" msg='invalid ID3V2 versions'\n" +
+					"level='error'" +
+					" --where='year >= 1990 or disc = 2'" +
+					" error='at column 22, \"=\": this is not an operator; the operators" +
+					" are ==, !=, !~, <=, >=, ~, <, >'" +
+					" user-set='false'" +
+					" msg='invalid where expression'\n",
 			},
 		},
 		"good data": {
@@ -398,12 +802,53 @@ func TestProcessSearchFlags(t *testing.T) {
 					cmd.StringType).WithValue("."),
 				"extensions": cmd.NewFlagValue().WithValueType(
 					cmd.StringType).WithValue(".mp3"),
+				"metadataPriority": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("id3v1,ID3V2"),
+				"compilations": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(" Various Artists, ,Soundtracks "),
+				"layout": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("{genre}/{artist}/{year} - {album}/{track:02} {title}"),
+				"trackNames": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("^(?P<track>[0-9]+)\\. (?P<title>.+)$\n\n"),
+				"metadataCache": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(" Rebuild "),
+				"concurrency": cmd.NewFlagValue().WithValueType(
+					cmd.IntType).WithValue(5),
+				"ignore": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("# bootlegs\nbootleg*/"),
+				"genreFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("^Rock$"),
+				"tagArtistFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"tagAlbumFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("Live"),
+				"tagTitleFilter": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(""),
+				"yearRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("-1979"),
+				"bitrateRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("192-320"),
+				"durationRange": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("2m-"),
+				"id3Versions": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue(" 3, 4"),
+				"where": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("not hasConcern(numbering)"),
 			},
 			wantSettings: cmd.NewSearchSettings().WithAlbumFilter(
 				regexp.MustCompile("[23]")).WithArtistFilter(
 				regexp.MustCompile("[0-7]")).WithTrackFilter(
 				regexp.MustCompile("0+")).WithTopDirectory(".").WithFileExtensions(
-				[]string{".mp3"}),
+				[]string{".mp3"}).WithMetadataPriority(
+				[]files.SourceType{files.ID3V1, files.ID3V2}).WithCompilations(
+				[]string{"Various Artists", "Soundtracks"}).WithLayout(
+				genreLayout).WithTrackNames([]*regexp.Regexp{
+				regexp.MustCompile(`^(?P<track>[0-9]+)\. (?P<title>.+)$`)}).WithMetadataCache(
+				"rebuild").WithConcurrency(5).WithIgnore("# bootlegs\nbootleg*/").WithGenreFilter(
+				regexp.MustCompile("^Rock$")).WithTagAlbumFilter(
+				regexp.MustCompile("Live")).WithYearRange(years).WithBitrateRange(
+				bitrates).WithDurationRange(durations).WithID3Versions([]int{3, 4}).WithWhere(
+				where),
 			wantOk: true,
 		},
 	}
@@ -418,7 +863,7 @@ func TestProcessSearchFlags(t *testing.T) {
 			if gotOk != tt.wantOk {
 				t.Errorf("ProcessSearchFlags() gotOk = %v, want %v", gotOk, tt.wantOk)
 			}
-			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+			if differences, ok := o.Verify(nativeRecording(tt.WantedRecording)); !ok {
 				for _, difference := range differences {
 					t.Errorf("ProcessSearchFlags() %s", difference)
 				}
@@ -428,6 +873,7 @@ func TestProcessSearchFlags(t *testing.T) {
 }
 
 func TestEvaluateSearchFlags(t *testing.T) {
+	defaultLayout, _ := files.ParseLayout(files.DefaultLayout)
 	tests := map[string]struct {
 		producer     cmd.FlagProducer
 		wantSettings *cmd.SearchSettings
@@ -440,9 +886,25 @@ func TestEvaluateSearchFlags(t *testing.T) {
 			WantedRecording: output.WantedRecording{
 				Error: "An internal error occurred: flag \"albumFilter\" does not exist.\n" +
 					"An internal error occurred: flag \"artistFilter\" does not exist.\n" +
+					"An internal error occurred: flag \"bitrateRange\" does not exist.\n" +
+					"An internal error occurred: flag \"compilations\" does not exist.\n" +
+					"An internal error occurred: flag \"concurrency\" does not exist.\n" +
+					"An internal error occurred: flag \"durationRange\" does not exist.\n" +
 					"An internal error occurred: flag \"extensions\" does not exist.\n" +
+					"An internal error occurred: flag \"genreFilter\" does not exist.\n" +
+					"An internal error occurred: flag \"id3Versions\" does not exist.\n" +
+					"An internal error occurred: flag \"ignore\" does not exist.\n" +
+					"An internal error occurred: flag \"layout\" does not exist.\n" +
+					"An internal error occurred: flag \"metadataCache\" does not exist.\n" +
+					"An internal error occurred: flag \"metadataPriority\" does not exist.\n" +
+					"An internal error occurred: flag \"tagAlbumFilter\" does not exist.\n" +
+					"An internal error occurred: flag \"tagArtistFilter\" does not exist.\n" +
+					"An internal error occurred: flag \"tagTitleFilter\" does not exist.\n" +
 					"An internal error occurred: flag \"topDir\" does not exist.\n" +
-					"An internal error occurred: flag \"trackFilter\" does not exist.\n",
+					"An internal error occurred: flag \"trackFilter\" does not exist.\n" +
+					"An internal error occurred: flag \"trackNames\" does not exist.\n" +
+					"An internal error occurred: flag \"where\" does not exist.\n" +
+					"An internal error occurred: flag \"yearRange\" does not exist.\n",
 				Log: "level='error'" +
 					" error='flag \"albumFilter\" does not exist'" +
 					" msg='internal error'\n" +
@@ -450,13 +912,61 @@ func TestEvaluateSearchFlags(t *testing.T) {
 					" error='flag \"artistFilter\" does not exist'" +
 					" msg='internal error'\n" +
 					"level='error'" +
+					" error='flag \"bitrateRange\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"compilations\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"concurrency\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"durationRange\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
 					" error='flag \"extensions\" does not exist'" +
 					" msg='internal error'\n" +
 					"level='error'" +
+					" error='flag \"genreFilter\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"id3Versions\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"ignore\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"layout\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"metadataCache\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"metadataPriority\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"tagAlbumFilter\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"tagArtistFilter\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"tagTitleFilter\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
 					" error='flag \"topDir\" does not exist'" +
 					" msg='internal error'\n" +
 					"level='error'" +
 					" error='flag \"trackFilter\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"trackNames\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"where\" does not exist'" +
+					" msg='internal error'\n" +
+					"level='error'" +
+					" error='flag \"yearRange\" does not exist'" +
 					" msg='internal error'\n",
 			},
 		},
@@ -468,13 +978,38 @@ func TestEvaluateSearchFlags(t *testing.T) {
 					"trackFilter":  {value: "Sadie", valueKind: cmd.StringType},
 					"topDir":       {value: ".", valueKind: cmd.StringType},
 					"extensions":   {value: ".mp3", valueKind: cmd.StringType},
+					"metadataPriority": {
+						value:     "ID3V2,APEV2,ID3V1",
+						valueKind: cmd.StringType,
+					},
+					"compilations": {value: "Various Artists", valueKind: cmd.StringType},
+					"layout": {
+						value:     "{artist}/{album}/{track} {title}",
+						valueKind: cmd.StringType,
+					},
+					"trackNames":      {value: "", valueKind: cmd.StringType},
+					"metadataCache":   {value: "use", valueKind: cmd.StringType},
+					"concurrency":     {value: 20, valueKind: cmd.IntType},
+					"ignore":          {value: "", valueKind: cmd.StringType},
+					"genreFilter":     {value: "", valueKind: cmd.StringType},
+					"tagArtistFilter": {value: "", valueKind: cmd.StringType},
+					"tagAlbumFilter":  {value: "", valueKind: cmd.StringType},
+					"tagTitleFilter":  {value: "", valueKind: cmd.StringType},
+					"yearRange":       {value: "", valueKind: cmd.StringType},
+					"bitrateRange":    {value: "", valueKind: cmd.StringType},
+					"durationRange":   {value: "", valueKind: cmd.StringType},
+					"id3Versions":     {value: "", valueKind: cmd.StringType},
+					"where":           {value: "", valueKind: cmd.StringType},
 				},
 			},
 			wantSettings: cmd.NewSearchSettings().WithAlbumFilter(
 				regexp.MustCompile(`\d+`)).WithArtistFilter(
 				regexp.MustCompile("Beatles")).WithTrackFilter(
 				regexp.MustCompile("Sadie")).WithTopDirectory(".").WithFileExtensions(
-				[]string{".mp3"}),
+				[]string{".mp3"}).WithMetadataPriority(
+				[]files.SourceType{files.ID3V2, files.APEV2, files.ID3V1}).WithCompilations(
+				[]string{"Various Artists"}).WithLayout(defaultLayout).WithMetadataCache(
+				"use").WithConcurrency(20),
 			wantOk: true,
 		},
 	}
@@ -498,6 +1033,81 @@ func TestEvaluateSearchFlags(t *testing.T) {
 	}
 }
 
+func TestEvaluateMetadataPriority(t *testing.T) {
+	tests := map[string]struct {
+		values map[string]*cmd.FlagValue
+		want   []files.SourceType
+		want1  bool
+		output.WantedRecording
+	}{
+		"no data": {
+			values: map[string]*cmd.FlagValue{},
+			want:   []files.SourceType{},
+			want1:  false,
+			WantedRecording: output.WantedRecording{
+				Error: "An internal error occurred: flag \"metadataPriority\" is not found.\n",
+				Log: "level='error'" +
+					" error='flag not found'" +
+					" flag='metadataPriority'" +
+					" msg='internal error'\n",
+			},
+		},
+		"one source": {
+			values: map[string]*cmd.FlagValue{
+				"metadataPriority": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("apev2"),
+			},
+			want:  []files.SourceType{files.APEV2},
+			want1: true,
+		},
+		"all sources": {
+			values: map[string]*cmd.FlagValue{
+				"metadataPriority": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("ID3V1, ID3V2, APEV2"),
+			},
+			want:  []files.SourceType{files.ID3V1, files.ID3V2, files.APEV2},
+			want1: true,
+		},
+		"bad sources": {
+			values: map[string]*cmd.FlagValue{
+				"metadataPriority": cmd.NewFlagValue().WithValueType(
+					cmd.StringType).WithValue("ID3V2,,vorbis"),
+			},
+			want:  []files.SourceType{files.ID3V2},
+			want1: false,
+			WantedRecording: output.WantedRecording{
+				Error: "The metadata source \"\" cannot be used.\n" +
+					"The metadata source \"vorbis\" cannot be used.\n" +
+					"Why?\n" +
+					"The supported metadata sources are ID3V1, ID3V2, APEV2, FLAC, MP4, OGG.\n" +
+					"What to do:\n" +
+					"Provide appropriate metadata sources.\n",
+				Log: "level='error'" +
+					" --metadataPriority='ID3V2,,vorbis'" +
+					" rejected='[ vorbis]'" +
+					" msg='invalid metadata priority'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			got, got1 := cmd.EvaluateMetadataPriority(o, tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvaluateMetadataPriority() got = %v, want %v", got, tt.want)
+			}
+			if got1 != tt.want1 {
+				t.Errorf("EvaluateMetadataPriority() got1 = %v, want %v", got1, tt.want1)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("EvaluateMetadataPriority() %s", difference)
+				}
+			}
+		})
+	}
+}
+
 func TestEvaluateFileExtensions(t *testing.T) {
 	tests := map[string]struct {
 		values map[string]*cmd.FlagValue
@@ -602,18 +1212,42 @@ func newTestFile(name string, content []*testFile) *testFile {
 	return &testFile{name: name, files: content}
 }
 
+// readWith returns a copy of the artist, with copies of its albums and tracks,
+// whose tracks are read with the specified settings
+func readWith(artist *files.Artist, settings *files.ReadSettings) *files.Artist {
+	copied := artist.Copy().WithReadSettings(settings)
+	for _, album := range artist.Albums() {
+		copied.AddAlbum(album.Copy(copied, true))
+	}
+	return copied
+}
+
 func TestSearchSettingsLoad(t *testing.T) {
 	originalReadDirectory := cmd.ReadDirectory
+	originalReadFile := cmd.ReadFile
+	originalLoadArtistMetadata := cmd.LoadArtistMetadata
+	originalSince := cmd.Since
 	defer func() {
 		cmd.ReadDirectory = originalReadDirectory
+		cmd.ReadFile = originalReadFile
+		cmd.LoadArtistMetadata = originalLoadArtistMetadata
+		cmd.Since = originalSince
 	}()
+	cmd.Since = func(_ time.Time) time.Duration {
+		return 0
+	}
 	album1Content1 := newTestFile("subfolder", []*testFile{newTestFile("foo", nil)})
 	album1Content2 := newTestFile("cover.jpg", nil)
 	album1Content3 := newTestFile("1 lovely music.mp3", nil)
 	album1 := newTestFile("album", []*testFile{album1Content1, album1Content2,
 		album1Content3})
 	album2 := newTestFile("not an album", nil)
-	artist1 := newTestFile("artist", []*testFile{album1, album2})
+	disc1Content := newTestFile("1 first song.mp3", nil)
+	disc1 := newTestFile("CD1", []*testFile{disc1Content})
+	disc2Content := newTestFile("1 second song.mp3", nil)
+	disc2 := newTestFile("CD2", []*testFile{disc2Content})
+	album3 := newTestFile("double album", []*testFile{disc1, disc2})
+	artist1 := newTestFile("artist", []*testFile{album1, album2, album3})
 	artist2 := newTestFile("not an artist", nil)
 	topDir := newTestFile("music", []*testFile{artist1, artist2})
 	testFiles := map[string]*testFile{
@@ -628,13 +1262,188 @@ func TestSearchSettingsLoad(t *testing.T) {
 			album1Content2.name): album1Content2,
 		filepath.Join(topDir.name, artist1.name, album1.name,
 			album1Content3.name): album1Content3,
+		filepath.Join(topDir.name, artist1.name, album3.name):             album3,
+		filepath.Join(topDir.name, artist1.name, album3.name, disc1.name): disc1,
+		filepath.Join(topDir.name, artist1.name, album3.name, disc2.name): disc2,
 	}
-	testArtist := files.NewArtistFromFile(artist1, topDir.name)
+	layoutTrack := newTestFile("01 song.mp3", nil)
+	layoutStray := newTestFile("song without a number.mp3", nil)
+	layoutAlbum := newTestFile("1969 - album", []*testFile{layoutTrack, layoutStray})
+	layoutNotAlbum := newTestFile("album without a year", nil)
+	layoutArtist := newTestFile("artist", []*testFile{layoutAlbum, layoutNotAlbum})
+	layoutGenre := newTestFile("rock", []*testFile{layoutArtist,
+		newTestFile("loose.mp3", nil)})
+	// the same artist, found under another genre
+	layoutOtherTrack := newTestFile("01 other song.mp3", nil)
+	layoutOtherAlbum := newTestFile("1970 - other album", []*testFile{layoutOtherTrack})
+	layoutOtherArtist := newTestFile("artist", []*testFile{layoutOtherAlbum})
+	layoutOtherGenre := newTestFile("pop", []*testFile{layoutOtherArtist})
+	layoutTopDir := newTestFile("genres", []*testFile{layoutGenre, layoutOtherGenre})
+	testFiles[layoutTopDir.name] = layoutTopDir
+	testFiles[filepath.Join(layoutTopDir.name, layoutGenre.name)] = layoutGenre
+	testFiles[filepath.Join(layoutTopDir.name, layoutGenre.name,
+		layoutArtist.name)] = layoutArtist
+	testFiles[filepath.Join(layoutTopDir.name, layoutGenre.name, layoutArtist.name,
+		layoutAlbum.name)] = layoutAlbum
+	testFiles[filepath.Join(layoutTopDir.name, layoutOtherGenre.name)] = layoutOtherGenre
+	testFiles[filepath.Join(layoutTopDir.name, layoutOtherGenre.name,
+		layoutOtherArtist.name)] = layoutOtherArtist
+	testFiles[filepath.Join(layoutTopDir.name, layoutOtherGenre.name, layoutOtherArtist.name,
+		layoutOtherAlbum.name)] = layoutOtherAlbum
+	genreLayout, _ := files.ParseLayout("{genre}/{artist}/{year} - {album}/{track} {title}")
+	// the artists are loaded with the settings with which their tracks are read
+	defaultSettings := files.NewReadSettings()
+	testLayoutArtist := files.NewArtist("artist", filepath.Join(layoutTopDir.name,
+		layoutGenre.name, layoutArtist.name)).WithReadSettings(defaultSettings)
+	testLayoutAlbum := files.NewAlbum("album", testLayoutArtist,
+		filepath.Join(testLayoutArtist.Path(), layoutAlbum.name)).WithPathGenre(
+		"rock").WithPathYear("1969")
+	testLayoutArtist.AddAlbum(testLayoutAlbum)
+	testLayoutAlbum.AddTrack(files.NewTrack(testLayoutAlbum, layoutTrack.name, "song", 1))
+	testLayoutAlbum.AddUnparsedTrack("song without a number.mp3")
+	testLayoutOtherAlbum := files.NewAlbum("other album", testLayoutArtist,
+		filepath.Join(layoutTopDir.name, layoutOtherGenre.name, layoutOtherArtist.name,
+			layoutOtherAlbum.name)).WithPathGenre("pop").WithPathYear("1970")
+	testLayoutArtist.AddAlbum(testLayoutOtherAlbum)
+	testLayoutOtherAlbum.AddTrack(files.NewTrack(testLayoutOtherAlbum, layoutOtherTrack.name,
+		"other song", 1))
+	testArtist := files.NewArtistFromFile(artist1,
+		topDir.name).WithReadSettings(defaultSettings)
 	testAlbum := files.NewAlbumFromFile(album1, testArtist)
 	testArtist.AddAlbum(testAlbum)
 	testTrack := files.NewTrack(testAlbum, album1Content3.name, "lovely music", 1)
 	testAlbum.AddTrack(testTrack)
-	cmd.ReadDirectory = func(_ output.Bus, dir string) ([]fs.DirEntry, bool) {
+	testDoubleAlbum := files.NewAlbumFromFile(album3, testArtist)
+	testArtist.AddAlbum(testDoubleAlbum)
+	testDoubleAlbum.AddTrack(files.NewTrack(testDoubleAlbum, filepath.Join(disc1.name,
+		disc1Content.name), "first song", 1).WithDisc(1))
+	testDoubleAlbum.AddTrack(files.NewTrack(testDoubleAlbum, filepath.Join(disc2.name,
+		disc2Content.name), "second song", 1).WithDisc(2))
+	// a library of several artists, some of whose albums cannot be read; the
+	// errors must be reported in order, although the albums are read concurrently
+	unreadable := map[string]bool{}
+	libraryTopDir := newTestFile("library", nil)
+	testFiles[libraryTopDir.name] = libraryTopDir
+	var testLibraryArtists []*files.Artist
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		libraryTrack := newTestFile("1 song.mp3", nil)
+		libraryAlbum := newTestFile("album "+name, []*testFile{libraryTrack})
+		libraryArtist := newTestFile("artist "+name, []*testFile{libraryAlbum})
+		libraryTopDir.files = append(libraryTopDir.files, libraryArtist)
+		artistPath := filepath.Join(libraryTopDir.name, libraryArtist.name)
+		albumPath := filepath.Join(artistPath, libraryAlbum.name)
+		testFiles[artistPath] = libraryArtist
+		testFiles[albumPath] = libraryAlbum
+		testLibraryArtist := files.NewArtistFromFile(libraryArtist,
+			libraryTopDir.name).WithReadSettings(defaultSettings)
+		testLibraryAlbum := files.NewAlbumFromFile(libraryAlbum, testLibraryArtist)
+		testLibraryArtist.AddAlbum(testLibraryAlbum)
+		if name == "c" || name == "h" {
+			unreadable[albumPath] = true
+		} else {
+			testLibraryAlbum.AddTrack(files.NewTrack(testLibraryAlbum, libraryTrack.name,
+				"song", 1))
+		}
+		testLibraryArtists = append(testLibraryArtists, testLibraryArtist)
+	}
+	// a second top directory, holding more albums by the same artist, one of them
+	// a copy of an album in the first, and an artist found only there
+	overflowTrack := newTestFile("2 more music.mp3", nil)
+	overflowAlbum := newTestFile("album", []*testFile{overflowTrack})
+	overflowArtist := newTestFile("artist", []*testFile{overflowAlbum})
+	otherTrack := newTestFile("1 other song.mp3", nil)
+	otherAlbum := newTestFile("other album", []*testFile{otherTrack})
+	otherArtist := newTestFile("other artist", []*testFile{otherAlbum})
+	overflowTopDir := newTestFile("overflow", []*testFile{overflowArtist, otherArtist})
+	testFiles[overflowTopDir.name] = overflowTopDir
+	testFiles[filepath.Join(overflowTopDir.name, overflowArtist.name)] = overflowArtist
+	testFiles[filepath.Join(overflowTopDir.name, overflowArtist.name,
+		overflowAlbum.name)] = overflowAlbum
+	testFiles[filepath.Join(overflowTopDir.name, otherArtist.name)] = otherArtist
+	testFiles[filepath.Join(overflowTopDir.name, otherArtist.name,
+		otherAlbum.name)] = otherAlbum
+	testMergedArtist := files.NewArtistFromFile(artist1,
+		topDir.name).WithReadSettings(defaultSettings)
+	testMergedAlbum := files.NewAlbumFromFile(album1, testMergedArtist)
+	testMergedArtist.AddAlbum(testMergedAlbum)
+	testMergedAlbum.AddTrack(files.NewTrack(testMergedAlbum, album1Content3.name,
+		"lovely music", 1))
+	testMergedDoubleAlbum := files.NewAlbumFromFile(album3, testMergedArtist)
+	testMergedArtist.AddAlbum(testMergedDoubleAlbum)
+	testMergedDoubleAlbum.AddTrack(files.NewTrack(testMergedDoubleAlbum,
+		filepath.Join(disc1.name, disc1Content.name), "first song", 1).WithDisc(1))
+	testMergedDoubleAlbum.AddTrack(files.NewTrack(testMergedDoubleAlbum,
+		filepath.Join(disc2.name, disc2Content.name), "second song", 1).WithDisc(2))
+	testOverflowAlbum := files.NewAlbum(overflowAlbum.name, testMergedArtist,
+		filepath.Join(overflowTopDir.name, overflowArtist.name, overflowAlbum.name))
+	testMergedArtist.AddAlbum(testOverflowAlbum)
+	testOverflowAlbum.AddTrack(files.NewTrack(testOverflowAlbum, overflowTrack.name,
+		"more music", 2))
+	testOtherArtist := files.NewArtistFromFile(otherArtist,
+		overflowTopDir.name).WithReadSettings(defaultSettings)
+	testOtherAlbum := files.NewAlbumFromFile(otherAlbum, testOtherArtist)
+	testOtherArtist.AddAlbum(testOtherAlbum)
+	testOtherAlbum.AddTrack(files.NewTrack(testOtherAlbum, otherTrack.name, "other song", 1))
+	// a top directory holding a track file whose name has no track number
+	misnamedTrack := newTestFile("song.mp3", nil)
+	misnamedAlbum := newTestFile("album", []*testFile{newTestFile("1 song.mp3", nil),
+		misnamedTrack})
+	misnamedArtist := newTestFile("artist", []*testFile{misnamedAlbum})
+	misnamedTopDir := newTestFile("misnamed", []*testFile{misnamedArtist})
+	testFiles[misnamedTopDir.name] = misnamedTopDir
+	testFiles[filepath.Join(misnamedTopDir.name, misnamedArtist.name)] = misnamedArtist
+	testFiles[filepath.Join(misnamedTopDir.name, misnamedArtist.name,
+		misnamedAlbum.name)] = misnamedAlbum
+	testMisnamedArtist := files.NewArtistFromFile(misnamedArtist,
+		misnamedTopDir.name).WithReadSettings(defaultSettings)
+	testMisnamedAlbum := files.NewAlbumFromFile(misnamedAlbum, testMisnamedArtist)
+	testMisnamedArtist.AddAlbum(testMisnamedAlbum)
+	testMisnamedAlbum.AddTrack(files.NewTrack(testMisnamedAlbum, "1 song.mp3", "song", 1))
+	testMisnamedAlbum.AddUnparsedTrack(misnamedTrack.name)
+	// a top directory whose ignore files, along with the global ignore list, keep
+	// some of its artists, albums, and tracks out of the search
+	ignoredTrack := newTestFile("02 skipped.mp3", nil)
+	keptTrack := newTestFile("01 kept.mp3", nil)
+	brokenIgnoreFile := newTestFile(files.IgnoreFileName, nil)
+	ignoringAlbum := newTestFile("album", []*testFile{keptTrack, ignoredTrack,
+		brokenIgnoreFile})
+	bootlegAlbum := newTestFile("bootleg live", []*testFile{newTestFile("1 live.mp3", nil)})
+	ignoringArtist := newTestFile("artist", []*testFile{newTestFile(files.IgnoreFileName,
+		nil), ignoringAlbum, bootlegAlbum})
+	bootlegArtist := newTestFile("bootleg artist", []*testFile{newTestFile("album", nil)})
+	scratch := newTestFile("scratch", []*testFile{newTestFile("album", nil)})
+	ignoringTopDir := newTestFile("ignoring", []*testFile{
+		newTestFile(files.IgnoreFileName, nil), ignoringArtist, bootlegArtist, scratch})
+	ignoringArtistPath := filepath.Join(ignoringTopDir.name, ignoringArtist.name)
+	ignoringAlbumPath := filepath.Join(ignoringArtistPath, ignoringAlbum.name)
+	testFiles[ignoringTopDir.name] = ignoringTopDir
+	testFiles[ignoringArtistPath] = ignoringArtist
+	testFiles[ignoringAlbumPath] = ignoringAlbum
+	testFiles[filepath.Join(ignoringArtistPath, bootlegAlbum.name)] = bootlegAlbum
+	testFiles[filepath.Join(ignoringTopDir.name, bootlegArtist.name)] = bootlegArtist
+	testFiles[filepath.Join(ignoringTopDir.name, scratch.name)] = scratch
+	ignoreFiles := map[string]string{
+		filepath.Join(ignoringTopDir.name, files.IgnoreFileName): "# bootlegs\nbootleg*/\n[bad",
+		filepath.Join(ignoringArtistPath, files.IgnoreFileName):  "02 *.mp3",
+	}
+	cmd.ReadFile = func(name string) ([]byte, error) {
+		if content, ok := ignoreFiles[name]; ok {
+			return []byte(content), nil
+		}
+		return nil, fmt.Errorf("open %s: access is denied", name)
+	}
+	testIgnoringArtist := files.NewArtistFromFile(ignoringArtist,
+		ignoringTopDir.name).WithReadSettings(defaultSettings)
+	testIgnoringAlbum := files.NewAlbumFromFile(ignoringAlbum, testIgnoringArtist)
+	testIgnoringArtist.AddAlbum(testIgnoringAlbum)
+	testIgnoringAlbum.AddTrack(files.NewTrack(testIgnoringAlbum, keptTrack.name, "kept", 1))
+	topIgnoreFile := filepath.Join(ignoringTopDir.name, files.IgnoreFileName)
+	albumIgnoreFile := filepath.Join(ignoringAlbumPath, files.IgnoreFileName)
+	cmd.ReadDirectory = func(o output.Bus, dir string) ([]fs.DirEntry, bool) {
+		if unreadable[dir] {
+			o.WriteCanonicalError("The directory %q cannot be read", dir)
+			return nil, false
+		}
 		if tf, ok := testFiles[dir]; ok {
 			entries := []fs.DirEntry{}
 			for _, f := range tf.files {
@@ -644,12 +1453,38 @@ func TestSearchSettingsLoad(t *testing.T) {
 		}
 		return []fs.DirEntry{}, false
 	}
+	seventies, _ := cmd.ParseNumberRange("1970-1979")
+	highBitrates, _ := cmd.ParseNumberRange("256-")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
 	tests := map[string]struct {
-		ss    *cmd.SearchSettings
-		want  []*files.Artist
-		want1 bool
+		ss          *cmd.SearchSettings
+		interrupted bool
+		metadataErr error
+		want        []*files.Artist
+		want1       bool
+		wantIgnored []string
 		output.WantedRecording
 	}{
+		"interrupted": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}),
+			interrupted: true,
+			want1:       false,
+			WantedRecording: output.WantedRecording{
+				Error: "The search for music files was interrupted after 0 directories" +
+					" were read.\n",
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='0'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='info'" +
+					" --topDir='music'" +
+					" directories='0'" +
+					" msg='search interrupted'\n",
+			},
+		},
 		"topDir read error": {
 			ss:    cmd.NewSearchSettings().WithTopDirectory("td"),
 			want:  []*files.Artist{},
@@ -661,28 +1496,250 @@ func TestSearchSettingsLoad(t *testing.T) {
 					"What to do:\n" +
 					"Set --topDir to the path of a directory that contains artist" +
 					" directories.\n",
-				Log: "level='error'" +
+				Log: "level='info'" +
+					" --topDir='td'" +
+					" directories='1'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='error'" +
 					" --topDir='td'" +
 					" msg='cannot find any artist directories'\n",
 			},
 		},
+		"good read from several top directories": {
+			ss: cmd.NewSearchSettings().WithTopDirectories([]string{"music",
+				"overflow"}).WithFileExtensions([]string{".mp3"}),
+			want:  []*files.Artist{testMergedArtist, testOtherArtist},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='info'" +
+					" --topDir='overflow'" +
+					" directories='5'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
 		"good read": {
 			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
 				[]string{".mp3"}),
 			want:  []*files.Artist{testArtist},
 			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"good read with an unparsed track name": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("misnamed").WithFileExtensions(
+				[]string{".mp3"}),
+			want:  []*files.Artist{testMisnamedArtist},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='misnamed'" +
+					" directories='3'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='error'" +
+					" albumName='album'" +
+					" artistName='artist'" +
+					" trackName='song.mp3'" +
+					" msg='the track name cannot be parsed'\n",
+			},
+		},
+		"good read with metadata filters": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}).WithGenreFilter(regexp.MustCompile("Rock")),
+			want:  []*files.Artist{testArtist},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Error: "Reading the metadata of 3 tracks.\n",
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"metadata reading interrupted": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}).WithYearRange(seventies),
+			metadataErr: context.Canceled,
+			want1:       false,
+			WantedRecording: output.WantedRecording{
+				Error: "Reading the metadata of 3 tracks.\n",
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"no metadata read for audio filters": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}).WithBitrateRange(highBitrates),
+			want:  []*files.Artist{testArtist},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"good read with metadata priority": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}).WithMetadataPriority([]files.SourceType{files.ID3V1}),
+			want: []*files.Artist{readWith(testArtist,
+				files.NewReadSettings().WithPrimarySourcePriority(
+					[]files.SourceType{files.ID3V1}))},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"good read with compilations and read settings": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("music").WithFileExtensions(
+				[]string{".mp3"}).WithCompilations([]string{"ARTIST"}).WithMetadataCache(
+				files.CacheUse).WithConcurrency(5),
+			want: []*files.Artist{readWith(testArtist,
+				files.NewReadSettings().WithMetadataCacheMode(files.CacheUse).WithMetadataReaders(
+					5)).WithCompilation(true)},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='music'" +
+					" directories='6'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"good read with unreadable albums": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("library").WithFileExtensions(
+				[]string{".mp3"}),
+			want:  testLibraryArtists,
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Error: fmt.Sprintf("The directory %q cannot be read.\n"+
+					"The directory %q cannot be read.\n",
+					filepath.Join("library", "artist c", "album c"),
+					filepath.Join("library", "artist h", "album h")),
+				Log: "level='info'" +
+					" --topDir='library'" +
+					" directories='21'" +
+					" duration='0s'" +
+					" msg='directories read'\n",
+			},
+		},
+		"good read with ignore rules": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("ignoring").WithFileExtensions(
+				[]string{".mp3"}).WithIgnore("/scratch/"),
+			want:  []*files.Artist{testIgnoringArtist},
+			want1: true,
+			wantIgnored: []string{
+				filepath.Join(ignoringAlbumPath, ignoredTrack.name),
+				filepath.Join(ignoringArtistPath, bootlegAlbum.name),
+				filepath.Join(ignoringTopDir.name, bootlegArtist.name),
+				filepath.Join(ignoringTopDir.name, scratch.name),
+			},
+			WantedRecording: output.WantedRecording{
+				Error: fmt.Sprintf("The ignore file %q contains a pattern that cannot be"+
+					" used.\n"+
+					"Why?\n"+
+					"line 3 of %s: the pattern \"[bad\" has a '[' without a matching ']'.\n"+
+					"What to do:\n"+
+					"Correct the pattern, or remove it; the file's other patterns are still"+
+					" used.\n"+
+					"The ignore file %q cannot be read.\n"+
+					"Why?\n"+
+					"open %s: access is denied.\n"+
+					"What to do:\n"+
+					"Make the file readable, or remove it; until then, nothing in the"+
+					" directory is ignored because of it.\n",
+					topIgnoreFile, topIgnoreFile, albumIgnoreFile, albumIgnoreFile),
+				Log: "level='info'" +
+					" --topDir='ignoring'" +
+					" directories='3'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='error'" +
+					" error='line 3 of " + topIgnoreFile + ": the pattern \"[bad\" has a" +
+					" '[' without a matching ']''" +
+					" fileName='" + topIgnoreFile + "'" +
+					" msg='invalid ignore pattern'\n" +
+					"level='error'" +
+					" error='open " + albumIgnoreFile + ": access is denied'" +
+					" fileName='" + albumIgnoreFile + "'" +
+					" msg='cannot read ignore file'\n" +
+					"level='info'" +
+					" --topDir='ignoring'" +
+					" count='4'" +
+					" msg='files and directories ignored'\n",
+			},
+		},
+		"good read with layout": {
+			ss: cmd.NewSearchSettings().WithTopDirectory("genres").WithFileExtensions(
+				[]string{".mp3"}).WithLayout(genreLayout),
+			want:  []*files.Artist{testLayoutArtist},
+			want1: true,
+			WantedRecording: output.WantedRecording{
+				Log: "level='info'" +
+					" --topDir='genres'" +
+					" directories='7'" +
+					" duration='0s'" +
+					" msg='directories read'\n" +
+					"level='error'" +
+					" --layout='{genre}/{artist}/{year} - {album}/{track} {title}'" +
+					" albumName='album'" +
+					" artistName='artist'" +
+					" trackName='song without a number.mp3'" +
+					" msg='the track name does not match the layout'\n",
+			},
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.interrupted {
+				ctx = cancelled
+			}
+			cmd.LoadArtistMetadata = func(_ context.Context, o output.Bus,
+				artists []*files.Artist) error {
+				tracks := 0
+				for _, artist := range artists {
+					for _, album := range artist.Albums() {
+						tracks += len(album.Tracks())
+					}
+				}
+				o.WriteCanonicalError("Reading the metadata of %d tracks", tracks)
+				return tt.metadataErr
+			}
 			o := output.NewRecorder()
-			got, got1 := tt.ss.Load(o)
+			got, got1 := tt.ss.Load(ctx, o)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("SearchSettings.Load() got = %v, want %v", got, tt.want)
 			}
 			if got1 != tt.want1 {
 				t.Errorf("SearchSettings.Load() got1 = %v, want %v", got1, tt.want1)
 			}
+			if gotIgnored := tt.ss.IgnoredPaths(); !reflect.DeepEqual(gotIgnored,
+				tt.wantIgnored) {
+				t.Errorf("SearchSettings.Load() ignored = %v, want %v", gotIgnored,
+					tt.wantIgnored)
+			}
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
 				for _, difference := range differences {
 					t.Errorf("SearchSettings.Load() %s", difference)
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"mp3/internal/files"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majohn-r/output"
+)
+
+// directoryReaders is the number of directories that may be read at the same
+// time; listing a directory on a network share is slow, but not so slow that
+// a large number of readers pays off
+const directoryReaders = 8
+
+// directoryListing is the result of reading one directory, less the entries
+// that ignore rules kept out, along with whatever reading it wrote to the output
+// bus
+type directoryListing struct {
+	entries []fs.DirEntry
+	ignored []ignoredEntry
+	ok      bool
+	output  *deferredBus
+}
+
+// ignoredEntry is a file or directory kept out of a walk by an ignore rule
+type ignoredEntry struct {
+	path  string
+	isDir bool
+	rule  *files.IgnoreRule
+}
+
+// directoryListings holds the directories read by a walk; the listings are
+// read concurrently, but used in the same order as they would have been read
+// serially, so that the artists, albums, and tracks built from them, and any
+// errors reading them, come out in the same order
+type directoryListings struct {
+	listings map[string]*directoryListing
+}
+
+// walkJob is a directory to be read, its depth below the top directory, and the
+// ignore rules that apply to it, which include those of the ignore files in the
+// directories above it
+type walkJob struct {
+	dir   string
+	depth int
+	rules files.IgnoreRules
+}
+
+// directoryWalker reads a directory tree with a pool of readers; descend
+// decides which entries of a directory at a given depth are directories to be
+// read in turn. Once ctx is cancelled, the directories not yet started are
+// dropped.
+type directoryWalker struct {
+	ctx      context.Context
+	lock     *sync.Mutex
+	ready    *sync.Cond
+	pending  []walkJob
+	active   int
+	listings map[string]*directoryListing
+	descend  func(depth int, entry fs.DirEntry) bool
+}
+
+// walkDirectories reads the top directory and those below it that descend
+// selects, leaving out what the ignore rules match, and logs how long that took
+func walkDirectories(ctx context.Context, o output.Bus, topDir string, rules files.IgnoreRules,
+	descend func(depth int, entry fs.DirEntry) bool) *directoryListings {
+	start := time.Now()
+	lock := &sync.Mutex{}
+	w := &directoryWalker{
+		ctx:      ctx,
+		lock:     lock,
+		ready:    sync.NewCond(lock),
+		pending:  []walkJob{{dir: topDir, rules: rules}},
+		listings: map[string]*directoryListing{},
+		descend:  descend,
+	}
+	wg := &sync.WaitGroup{}
+	for range directoryReaders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+	o.Log(output.Info, "directories read", map[string]any{
+		"directories":    len(w.listings),
+		"duration":       Since(start),
+		SearchTopDirFlag: topDir,
+	})
+	return &directoryListings{listings: w.listings}
+}
+
+// work reads directories until there are none left to read and no reader is
+// busy, as a busy reader may yet find more directories to read
+func (w *directoryWalker) work() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for {
+		if w.ctx.Err() != nil {
+			w.pending = nil
+		}
+		for len(w.pending) == 0 && w.active > 0 {
+			w.ready.Wait()
+		}
+		if len(w.pending) == 0 {
+			w.ready.Broadcast()
+			return
+		}
+		job := w.pending[0]
+		w.pending = w.pending[1:]
+		w.active++
+		w.lock.Unlock()
+		listing, subdirectories := w.read(job)
+		w.lock.Lock()
+		w.active--
+		w.listings[job.dir] = listing
+		w.pending = append(w.pending, subdirectories...)
+		w.ready.Broadcast()
+	}
+}
+
+func (w *directoryWalker) read(job walkJob) (*directoryListing, []walkJob) {
+	listing := &directoryListing{output: &deferredBus{}}
+	entries, ok := ReadDirectory(listing.output, job.dir)
+	listing.ok = ok
+	rules := job.rules.With(readIgnoreFile(listing.output, job.dir, entries))
+	var subdirectories []walkJob
+	for _, entry := range entries {
+		path := filepath.Join(job.dir, entry.Name())
+		if rule := rules.Match(path, entry.IsDir()); rule != nil {
+			listing.ignored = append(listing.ignored, ignoredEntry{
+				path:  path,
+				isDir: entry.IsDir(),
+				rule:  rule,
+			})
+			continue
+		}
+		listing.entries = append(listing.entries, entry)
+		if entry.IsDir() && w.descend(job.depth, entry) {
+			subdirectories = append(subdirectories, walkJob{
+				dir:   path,
+				depth: job.depth + 1,
+				rules: rules,
+			})
+		}
+	}
+	return listing, subdirectories
+}
+
+// readIgnoreFile returns the rules of the directory's ignore file, if it has
+// one; a file that cannot be read, or a pattern that cannot be parsed, is
+// reported and otherwise skipped
+func readIgnoreFile(o output.Bus, dir string, entries []fs.DirEntry) files.IgnoreRules {
+	if !slices.ContainsFunc(entries, func(entry fs.DirEntry) bool {
+		return !entry.IsDir() && entry.Name() == files.IgnoreFileName
+	}) {
+		return nil
+	}
+	path := filepath.Join(dir, files.IgnoreFileName)
+	content, err := ReadFile(path)
+	if err != nil {
+		o.WriteCanonicalError("The ignore file %q cannot be read", path)
+		o.WriteCanonicalError("Why?\n%v", err)
+		o.WriteCanonicalError("What to do:\n" +
+			"Make the file readable, or remove it; until then, nothing in the directory" +
+			" is ignored because of it")
+		o.Log(output.Error, "cannot read ignore file", map[string]any{
+			"error":    err,
+			"fileName": path,
+		})
+		return nil
+	}
+	rules, errs := files.ParseIgnoreRules(string(content), dir, path)
+	for _, err := range errs {
+		o.WriteCanonicalError("The ignore file %q contains a pattern that cannot be used", path)
+		o.WriteCanonicalError("Why?\n%v", err)
+		o.WriteCanonicalError("What to do:\n" +
+			"Correct the pattern, or remove it; the file's other patterns are still used")
+		o.Log(output.Error, "invalid ignore pattern", map[string]any{
+			"error":    err,
+			"fileName": path,
+		})
+	}
+	return rules
+}
+
+// count returns the number of directories read by the walk
+func (dl *directoryListings) count() int {
+	return len(dl.listings)
+}
+
+// ignored returns the files and directories that ignore rules kept out of the
+// walk, sorted by path
+func (dl *directoryListings) ignored() []ignoredEntry {
+	var ignored []ignoredEntry
+	for _, listing := range dl.listings {
+		ignored = append(ignored, listing.ignored...)
+	}
+	slices.SortFunc(ignored, func(a, b ignoredEntry) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return ignored
+}
+
+// read returns the listing of a directory, first replaying whatever reading it
+// wrote to the output bus; a directory the walk did not read is read now
+func (dl *directoryListings) read(o output.Bus, dir string) ([]fs.DirEntry, bool) {
+	listing, found := dl.listings[dir]
+	if !found {
+		return ReadDirectory(o, dir)
+	}
+	listing.output.replay(o)
+	return listing.entries, listing.ok
+}
+
+// deferredBus records what is written to it, so that it can be replayed to
+// another bus later
+type deferredBus struct {
+	calls []func(output.Bus)
+}
+
+func (db *deferredBus) record(call func(output.Bus)) {
+	db.calls = append(db.calls, call)
+}
+
+func (db *deferredBus) replay(o output.Bus) {
+	for _, call := range db.calls {
+		call(o)
+	}
+}
+
+func (db *deferredBus) Log(l output.Level, msg string, fields map[string]any) {
+	db.record(func(o output.Bus) { o.Log(l, msg, fields) })
+}
+
+func (db *deferredBus) WriteCanonicalConsole(format string, a ...any) {
+	db.record(func(o output.Bus) { o.WriteCanonicalConsole(format, a...) })
+}
+
+func (db *deferredBus) WriteConsole(format string, a ...any) {
+	db.record(func(o output.Bus) { o.WriteConsole(format, a...) })
+}
+
+func (db *deferredBus) WriteCanonicalError(format string, a ...any) {
+	db.record(func(o output.Bus) { o.WriteCanonicalError(format, a...) })
+}
+
+func (db *deferredBus) WriteError(format string, a ...any) {
+	db.record(func(o output.Bus) { o.WriteError(format, a...) })
+}
+
+func (db *deferredBus) ConsoleWriter() io.Writer {
+	return deferredWriter{bus: db, writer: output.Bus.ConsoleWriter}
+}
+
+func (db *deferredBus) ErrorWriter() io.Writer {
+	return deferredWriter{bus: db, writer: output.Bus.ErrorWriter}
+}
+
+func (db *deferredBus) IsConsoleTTY() bool {
+	return false
+}
+
+func (db *deferredBus) IsErrorTTY() bool {
+	return false
+}
+
+// deferredWriter records what is written to one of a deferredBus's writers
+type deferredWriter struct {
+	bus    *deferredBus
+	writer func(output.Bus) io.Writer
+}
+
+func (dw deferredWriter) Write(p []byte) (int, error) {
+	content := bytes.Clone(p)
+	dw.bus.record(func(o output.Bus) { _, _ = dw.writer(o).Write(content) })
+	return len(p), nil
+}
@@ -0,0 +1,767 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"mp3/internal/files"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/majohn-r/output"
+)
+
+// WhereExpression is a parsed --where value: a boolean expression that selects
+// tracks by their names, their metadata, their audio, and the concerns that the
+// check command raises about them, as in
+//
+//	artist ~ "^The" and (year >= 1990 or genre == "Jazz") and not hasConcern(numbering)
+type WhereExpression struct {
+	text string
+	root whereNode
+	// numbered records, for each album evaluated so far, whether its tracks
+	// have numbering concerns, which need only be found once per album
+	numbered map[*files.Album]bool
+}
+
+// ParseWhereExpression parses an expression; an expression that cannot be used
+// is rejected with an error naming the column at which it fails
+func ParseWhereExpression(text string) (*WhereExpression, error) {
+	tokens, err := scanWhereTokens(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if extra := p.next(); extra.kind != whereEnd {
+		return nil, extra.errorf("a condition has already ended here; join conditions with" +
+			" and or or")
+	}
+	return &WhereExpression{text: text, root: root}, nil
+}
+
+// String returns the expression as it was written; a nil expression, which
+// selects every track, is written as an empty string
+func (w *WhereExpression) String() string {
+	if w == nil {
+		return ""
+	}
+	return w.text
+}
+
+// Matches reports whether the track satisfies the expression
+func (w *WhereExpression) Matches(t *files.Track) bool {
+	if w.numbered == nil {
+		w.numbered = map[*files.Album]bool{}
+	}
+	return w.root.evaluate(&whereTrack{track: t, numbered: w.numbered})
+}
+
+// readsTags reports whether the expression uses values read from the track
+// metadata
+func (w *WhereExpression) readsTags() bool {
+	return w.uses(func(node whereNode) bool {
+		switch n := node.(type) {
+		case *comparisonNode:
+			return n.left.readsTags() || n.right.readsTags()
+		case *concernNode:
+			return n.concern == FilesConcern
+		}
+		return false
+	})
+}
+
+// checksFiles reports whether the expression looks for file concerns, which
+// compare each track's metadata with the values chosen for its album and artist
+func (w *WhereExpression) checksFiles() bool {
+	return w.uses(func(node whereNode) bool {
+		n, ok := node.(*concernNode)
+		return ok && n.concern == FilesConcern
+	})
+}
+
+func (w *WhereExpression) uses(test func(whereNode) bool) bool {
+	if w == nil {
+		return false
+	}
+	pending := []whereNode{w.root}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if test(node) {
+			return true
+		}
+		pending = append(pending, node.operands()...)
+	}
+	return false
+}
+
+// EvaluateWhere returns the expression selecting which tracks to process; an
+// empty value selects every track, which is indicated by returning nil
+func EvaluateWhere(o output.Bus, values map[string]*FlagValue) (*WhereExpression, bool) {
+	rawValue, userSet, err := GetString(o, values, SearchWhere)
+	if err != nil {
+		return nil, false
+	}
+	if strings.TrimSpace(rawValue) == "" {
+		return nil, true
+	}
+	expression, err := ParseWhereExpression(rawValue)
+	if err != nil {
+		o.WriteCanonicalError("The %s value %q cannot be used", SearchWhereFlag, rawValue)
+		o.WriteCanonicalError("Why?\nThe expression cannot be read %v", err)
+		if userSet {
+			o.WriteCanonicalError("What to do:\n"+
+				"Correct that part of the expression. A condition compares a field with a"+
+				" value or another field, as in year >= 1990; matches a field with a quoted"+
+				" regular expression, as in artist ~ \"^The\"; or looks for a concern, as in"+
+				" hasConcern(numbering). Conditions are joined with and, or, and not, and"+
+				" grouped with parentheses. The fields are %s", whereFieldList())
+		} else {
+			o.WriteCanonicalError("What to do:\n"+
+				"Either edit the defaults.yaml file containing the settings, or explicitly"+
+				" set %s to a better value.", SearchWhereFlag)
+		}
+		o.Log(output.Error, "invalid where expression", map[string]any{
+			"error":         err,
+			SearchWhereFlag: rawValue,
+			"user-set":      userSet,
+		})
+		return nil, false
+	}
+	return expression, true
+}
+
+type whereTokenKind int
+
+const (
+	whereEnd whereTokenKind = iota
+	whereWord
+	whereQuotedText
+	whereWholeNumber
+	whereDurationValue
+	whereOperator
+	whereOpenParenthesis
+	whereCloseParenthesis
+)
+
+// whereOperators are the comparison operators, longest first, so that "<=" is
+// not read as "<" followed by "="
+var whereOperators = []string{"==", "!=", "!~", "<=", ">=", "~", "<", ">"}
+
+// whereToken is one word, value, operator, or parenthesis of an expression;
+// column counts characters, starting at 1
+type whereToken struct {
+	kind   whereTokenKind
+	text   string
+	column int
+	value  whereValue
+}
+
+// errorf returns an error naming the token and where it was found
+func (t whereToken) errorf(format string, a ...any) error {
+	place := "the end of the expression"
+	if t.text != "" {
+		place = strconv.Quote(t.text)
+	}
+	return fmt.Errorf("at column %d, %s: %s", t.column, place, fmt.Sprintf(format, a...))
+}
+
+func (t whereToken) isKeyword() bool {
+	return t.kind == whereWord && slices.Contains([]string{"and", "or", "not"}, t.text)
+}
+
+func scanWhereTokens(text string) ([]whereToken, error) {
+	var tokens []whereToken
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		token := whereToken{column: i + 1}
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			token.kind = whereOpenParenthesis
+			i++
+		case r == ')':
+			token.kind = whereCloseParenthesis
+			i++
+		case r == '"':
+			// a backslash escapes a quote or another backslash; any other
+			// backslash is kept, so that regular expressions such as "\d" are
+			// written as they would be anywhere else
+			var b strings.Builder
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' ||
+					runes[i+1] == '\\') {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				token.text = string(runes[start:])
+				return nil, token.errorf("the quoted text has no closing quote")
+			}
+			i++
+			token.kind = whereQuotedText
+			token.value = whereValue{text: b.String()}
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				runes[i] == '_') {
+				i++
+			}
+			token.kind = whereWord
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				runes[i] == '.') {
+				i++
+			}
+			token.text = string(runes[start:i])
+			if n, err := strconv.ParseInt(token.text, 10, 64); err == nil {
+				token.kind = whereWholeNumber
+				token.value = whereValue{number: n}
+			} else if d, err := time.ParseDuration(token.text); err == nil {
+				token.kind = whereDurationValue
+				token.value = whereValue{number: int64(d)}
+			} else {
+				return nil, token.errorf("this is neither a whole number nor a duration," +
+					" such as 3m30s")
+			}
+		default:
+			for _, operator := range whereOperators {
+				if strings.HasPrefix(string(runes[i:]), operator) {
+					token.kind = whereOperator
+					i += len([]rune(operator))
+					break
+				}
+			}
+			if token.kind != whereOperator {
+				token.text = string(r)
+				if r == '=' || r == '!' {
+					return nil, token.errorf("this is not an operator; the operators are %s",
+						strings.Join(whereOperators, ", "))
+				}
+				return nil, token.errorf("this character cannot be used outside of quoted text")
+			}
+		}
+		token.text = string(runes[start:i])
+		tokens = append(tokens, token)
+	}
+	return append(tokens, whereToken{kind: whereEnd, column: len(runes) + 1}), nil
+}
+
+// whereParser builds the tree of an expression from its tokens; "or" binds less
+// tightly than "and", which binds less tightly than "not"
+type whereParser struct {
+	tokens   []whereToken
+	position int
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.tokens[p.position]
+}
+
+func (p *whereParser) next() whereToken {
+	t := p.tokens[p.position]
+	if t.kind != whereEnd {
+		p.position++
+	}
+	return t
+}
+
+func (p *whereParser) nextIs(keyword string) bool {
+	t := p.peek()
+	return t.kind == whereWord && t.text == keyword
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.nextIs("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.nextIs("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseNot() (whereNode, error) {
+	if p.nextIs("not") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *whereParser) parseCondition() (whereNode, error) {
+	t := p.next()
+	switch {
+	case t.kind == whereOpenParenthesis:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != whereCloseParenthesis {
+			return nil, closing.errorf("a ) is needed to close the ( at column %d", t.column)
+		}
+		return node, nil
+	case t.kind == whereWord && !t.isKeyword():
+		if p.peek().kind == whereOpenParenthesis {
+			return p.parseConcern(t)
+		}
+		return p.parseComparison(t)
+	}
+	return nil, t.errorf("a condition is needed here, such as genre == \"Jazz\" or" +
+		" hasConcern(numbering)")
+}
+
+func (p *whereParser) parseConcern(function whereToken) (whereNode, error) {
+	if function.text != "hasConcern" {
+		return nil, function.errorf("there is no such function; the only function is" +
+			" hasConcern")
+	}
+	p.next()
+	name := p.next()
+	concern, found := whereConcerns[name.text]
+	if name.kind != whereWord || !found {
+		return nil, name.errorf("hasConcern needs the name of a concern: %s",
+			strings.Join(whereConcernList(), ", "))
+	}
+	if closing := p.next(); closing.kind != whereCloseParenthesis {
+		return nil, closing.errorf("a ) is needed to close hasConcern(")
+	}
+	return &concernNode{concern: concern}, nil
+}
+
+func (p *whereParser) parseComparison(fieldName whereToken) (whereNode, error) {
+	field, found := whereFields[fieldName.text]
+	if !found {
+		return nil, fieldName.errorf("there is no such field; the fields are %s",
+			whereFieldList())
+	}
+	node := &comparisonNode{left: whereOperand{name: fieldName.text, field: field}}
+	operator := p.next()
+	if operator.kind != whereOperator {
+		return nil, operator.errorf("%s must be compared with something, as in %s",
+			fieldName.text, field.example)
+	}
+	node.operator = operator.text
+	value := p.next()
+	switch {
+	case value.kind == whereWord && !value.isKeyword():
+		other, found := whereFields[value.text]
+		if !found {
+			return nil, value.errorf("there is no such field; quote text, as in \"%s\", and"+
+				" see the fields: %s", value.text, whereFieldList())
+		}
+		node.right = whereOperand{name: value.text, field: other}
+	case value.kind == whereQuotedText:
+		node.right = whereOperand{name: value.text, kind: whereText, value: value.value}
+	case value.kind == whereWholeNumber:
+		node.right = whereOperand{name: value.text, kind: whereNumber, value: value.value}
+	case value.kind == whereDurationValue:
+		node.right = whereOperand{name: value.text, kind: whereDuration, value: value.value}
+	default:
+		return nil, value.errorf("a value is needed after %s", operator.text)
+	}
+	if node.operator == "~" || node.operator == "!~" {
+		if field.kind != whereText {
+			return nil, operator.errorf("%s is %s, and only text can be matched with a"+
+				" regular expression", fieldName.text, field.kind)
+		}
+		if value.kind != whereQuotedText {
+			return nil, value.errorf("%s needs a quoted regular expression", operator.text)
+		}
+		regex, err := regexp.Compile(value.value.text)
+		if err != nil {
+			return nil, value.errorf("the regular expression cannot be used: %v", err)
+		}
+		node.regex = regex
+		return node, nil
+	}
+	if rightKind := node.right.valueKind(); rightKind != field.kind {
+		return nil, value.errorf("%s is %s, but this is %s", fieldName.text, field.kind,
+			rightKind)
+	}
+	return node, nil
+}
+
+type whereKind int
+
+const (
+	whereText whereKind = iota
+	whereNumber
+	whereDuration
+)
+
+func (k whereKind) String() string {
+	switch k {
+	case whereNumber:
+		return "a whole number"
+	case whereDuration:
+		return "a duration"
+	default:
+		return "text"
+	}
+}
+
+// whereValue holds text, or a whole number, or a duration in nanoseconds
+type whereValue struct {
+	text   string
+	number int64
+}
+
+// whereField is a value of a track that an expression can use; a track whose
+// metadata or audio cannot be read has empty text and zero numbers for the
+// fields read from them
+type whereField struct {
+	kind    whereKind
+	tags    bool
+	example string
+	value   func(wt *whereTrack) whereValue
+}
+
+var whereFields = map[string]*whereField{
+	"artist": {
+		example: "artist == \"Prince\"",
+		value: func(wt *whereTrack) whereValue {
+			return whereValue{text: wt.track.RecordingArtist()}
+		},
+	},
+	"album": {
+		example: "album ~ \"Live\"",
+		value: func(wt *whereTrack) whereValue {
+			return whereValue{text: wt.track.AlbumName()}
+		},
+	},
+	"title": {
+		example: "title ~ \"(?i)remix\"",
+		value: func(wt *whereTrack) whereValue {
+			return whereValue{text: wt.track.CommonName()}
+		},
+	},
+	"number": {
+		kind:    whereNumber,
+		example: "number > 12",
+		value: func(wt *whereTrack) whereValue {
+			return whereValue{number: int64(wt.track.Number())}
+		},
+	},
+	"disc": {
+		kind:    whereNumber,
+		example: "disc == 2",
+		value: func(wt *whereTrack) whereValue {
+			return whereValue{number: int64(wt.track.Disc())}
+		},
+	},
+	"genre": {
+		tags:    true,
+		example: "genre == \"Jazz\"",
+		value: func(wt *whereTrack) whereValue {
+			return wt.tag((*files.TrackMetadata).CanonicalGenre)
+		},
+	},
+	"tagArtist": {
+		tags:    true,
+		example: "tagArtist != artist",
+		value: func(wt *whereTrack) whereValue {
+			return wt.tag((*files.TrackMetadata).CanonicalArtist)
+		},
+	},
+	"tagAlbum": {
+		tags:    true,
+		example: "tagAlbum != album",
+		value: func(wt *whereTrack) whereValue {
+			return wt.tag((*files.TrackMetadata).CanonicalAlbum)
+		},
+	},
+	"tagTitle": {
+		tags:    true,
+		example: "tagTitle != title",
+		value: func(wt *whereTrack) whereValue {
+			return wt.tag((*files.TrackMetadata).CanonicalTitle)
+		},
+	},
+	"year": {
+		kind:    whereNumber,
+		tags:    true,
+		example: "year >= 1990",
+		value: func(wt *whereTrack) whereValue {
+			year, _ := tagYear(wt.tag((*files.TrackMetadata).CanonicalYear).text)
+			return whereValue{number: int64(year)}
+		},
+	},
+	"id3Version": {
+		kind:    whereNumber,
+		tags:    true,
+		example: "id3Version == 3",
+		value: func(wt *whereTrack) whereValue {
+			if tM := wt.metadata(); tM != nil {
+				return whereValue{number: int64(tM.ID3V2Version())}
+			}
+			return whereValue{}
+		},
+	},
+	"bitrate": {
+		kind:    whereNumber,
+		example: "bitrate < 192",
+		value: func(wt *whereTrack) whereValue {
+			if info := wt.audio(); info != nil {
+				return whereValue{number: int64(info.Bitrate())}
+			}
+			return whereValue{}
+		},
+	},
+	"duration": {
+		kind:    whereDuration,
+		example: "duration > 10m",
+		value: func(wt *whereTrack) whereValue {
+			if info := wt.audio(); info != nil {
+				return whereValue{number: int64(info.Duration())}
+			}
+			return whereValue{}
+		},
+	},
+}
+
+func whereFieldList() string {
+	names := make([]string, 0, len(whereFields))
+	for name := range whereFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// whereConcerns are the concerns that hasConcern can look for; they are those
+// the check command raises about individual tracks and their numbering
+var whereConcerns = map[string]ConcernType{
+	ConcernName(FilesConcern):     FilesConcern,
+	ConcernName(IntegrityConcern): IntegrityConcern,
+	ConcernName(NumberingConcern): NumberingConcern,
+}
+
+func whereConcernList() []string {
+	names := make([]string, 0, len(whereConcerns))
+	for name := range whereConcerns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// whereOperand is one side of a comparison: a field, or a value written in the
+// expression
+type whereOperand struct {
+	name  string
+	field *whereField
+	kind  whereKind
+	value whereValue
+}
+
+func (op whereOperand) valueKind() whereKind {
+	if op.field != nil {
+		return op.field.kind
+	}
+	return op.kind
+}
+
+func (op whereOperand) readsTags() bool {
+	return op.field != nil && op.field.tags
+}
+
+func (op whereOperand) evaluate(wt *whereTrack) whereValue {
+	if op.field != nil {
+		return op.field.value(wt)
+	}
+	return op.value
+}
+
+// whereTrack is a track being evaluated; its metadata and audio are looked up
+// at most once, however many conditions use them, and its album's numbering is
+// checked at most once, however many of the album's tracks are evaluated
+type whereTrack struct {
+	track     *files.Track
+	tM        *files.TrackMetadata
+	tagsRead  bool
+	info      *files.AudioInfo
+	audioRead bool
+	numbered  map[*files.Album]bool
+}
+
+func (wt *whereTrack) metadata() *files.TrackMetadata {
+	if !wt.tagsRead {
+		wt.tagsRead = true
+		_ = wt.track.LoadMetadata()
+		wt.tM = wt.track.GetMetadata()
+	}
+	return wt.tM
+}
+
+func (wt *whereTrack) tag(value func(*files.TrackMetadata) string) whereValue {
+	if tM := wt.metadata(); tM != nil && tM.IsValid() {
+		return whereValue{text: value(tM)}
+	}
+	return whereValue{}
+}
+
+func (wt *whereTrack) hasNumberingConcerns() bool {
+	album := wt.track.Album()
+	if album == nil {
+		return false
+	}
+	concerned, found := wt.numbered[album]
+	if !found {
+		concerned = len(NumberingConcerns(album.Tracks())) > 0
+		wt.numbered[album] = concerned
+	}
+	return concerned
+}
+
+func (wt *whereTrack) audio() *files.AudioInfo {
+	if !wt.audioRead {
+		wt.audioRead = true
+		wt.info, _ = wt.track.AudioInfo()
+	}
+	return wt.info
+}
+
+type whereNode interface {
+	evaluate(wt *whereTrack) bool
+	operands() []whereNode
+}
+
+type orNode struct {
+	left  whereNode
+	right whereNode
+}
+
+func (n *orNode) evaluate(wt *whereTrack) bool {
+	return n.left.evaluate(wt) || n.right.evaluate(wt)
+}
+
+func (n *orNode) operands() []whereNode {
+	return []whereNode{n.left, n.right}
+}
+
+type andNode struct {
+	left  whereNode
+	right whereNode
+}
+
+func (n *andNode) evaluate(wt *whereTrack) bool {
+	return n.left.evaluate(wt) && n.right.evaluate(wt)
+}
+
+func (n *andNode) operands() []whereNode {
+	return []whereNode{n.left, n.right}
+}
+
+type notNode struct {
+	operand whereNode
+}
+
+func (n *notNode) evaluate(wt *whereTrack) bool {
+	return !n.operand.evaluate(wt)
+}
+
+func (n *notNode) operands() []whereNode {
+	return []whereNode{n.operand}
+}
+
+// comparisonNode compares a field with a value or another field of the same
+// kind; text is compared as written, so "Jazz" is not "jazz", and matching a
+// regular expression such as "(?i)jazz" is the way to ignore case
+type comparisonNode struct {
+	left     whereOperand
+	operator string
+	right    whereOperand
+	regex    *regexp.Regexp
+}
+
+func (n *comparisonNode) evaluate(wt *whereTrack) bool {
+	left := n.left.evaluate(wt)
+	if n.regex != nil {
+		return n.regex.MatchString(left.text) == (n.operator == "~")
+	}
+	right := n.right.evaluate(wt)
+	order := cmp.Compare(left.number, right.number)
+	if n.left.valueKind() == whereText {
+		order = strings.Compare(left.text, right.text)
+	}
+	switch n.operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+func (n *comparisonNode) operands() []whereNode {
+	return nil
+}
+
+// concernNode looks for a concern that the check command would raise: numbering
+// concerns belong to the track's album, while file and integrity concerns belong
+// to the track itself
+type concernNode struct {
+	concern ConcernType
+}
+
+func (n *concernNode) evaluate(wt *whereTrack) bool {
+	album := wt.track.Album()
+	switch n.concern {
+	case NumberingConcern:
+		return wt.hasNumberingConcerns()
+	case FilesConcern:
+		wt.metadata()
+		return album != nil && len(wt.track.ReportMetadataProblems()) > 0
+	default:
+		return len(wt.track.ReportAudioProblems()) > 0
+	}
+}
+
+func (n *concernNode) operands() []whereNode {
+	return nil
+}
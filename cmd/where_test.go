@@ -0,0 +1,268 @@
+package cmd_test
+
+import (
+	"bytes"
+	"mp3/cmd"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/majohn-r/output"
+)
+
+func TestParseWhereExpression(t *testing.T) {
+	tests := map[string]struct {
+		text    string
+		wantErr string
+	}{
+		"comparison": {text: `genre == "Jazz"`},
+		"everything": {
+			text: `artist ~ "^The" and (year >= 1990 or genre == "Jazz") and not hasConcern(numbering)`,
+		},
+		"fields compared":    {text: "tagAlbum != album"},
+		"duration":           {text: "duration > 10m30s or bitrate <= 128"},
+		"escaped quote":      {text: `title == "say \"hello\""`},
+		"regex with escapes": {text: `title ~ "\d+ \\ \(live\)"`},
+		"unknown field": {
+			text: `genere == "Jazz"`,
+			wantErr: `at column 1, "genere": there is no such field; the fields are album, artist,` +
+				" bitrate, disc, duration, genre, id3Version, number, tagAlbum, tagArtist," +
+				" tagTitle, title, year",
+		},
+		"single equals": {
+			text: `genre = "Jazz"`,
+			wantErr: `at column 7, "=": this is not an operator; the operators are ==, !=, !~,` +
+				" <=, >=, ~, <, >",
+		},
+		"mismatched kinds": {
+			text:    `year >= "1990"`,
+			wantErr: `at column 9, "\"1990\"": year is a whole number, but this is text`,
+		},
+		"number for duration": {
+			text:    "duration > 600",
+			wantErr: `at column 12, "600": duration is a duration, but this is a whole number`,
+		},
+		"regex on number": {
+			text: `year ~ "19"`,
+			wantErr: `at column 6, "~": year is a whole number, and only text can be matched` +
+				" with a regular expression",
+		},
+		"regex not quoted": {
+			text:    "artist ~ album",
+			wantErr: `at column 10, "album": ~ needs a quoted regular expression`,
+		},
+		"bad regex": {
+			text: `artist ~ "[The"`,
+			wantErr: `at column 10, "\"[The\"": the regular expression cannot be used:` +
+				" error parsing regexp: missing closing ]: `[The`",
+		},
+		"unclosed quote": {
+			text:    `artist == "The`,
+			wantErr: `at column 11, "\"The": the quoted text has no closing quote`,
+		},
+		"unclosed parenthesis": {
+			text:    "(year > 1990 or disc == 2",
+			wantErr: "at column 26, the end of the expression: a ) is needed to close the ( at column 1",
+		},
+		"missing value": {
+			text:    "year >",
+			wantErr: `at column 7, the end of the expression: a value is needed after >`,
+		},
+		"missing operator": {
+			text:    "year",
+			wantErr: "at column 5, the end of the expression: year must be compared with something, as in year >= 1990",
+		},
+		"dangling and": {
+			text: "year > 1990 and",
+			wantErr: "at column 16, the end of the expression: a condition is needed here," +
+				` such as genre == "Jazz" or hasConcern(numbering)`,
+		},
+		"missing and": {
+			text:    "year > 1990 disc == 2",
+			wantErr: `at column 13, "disc": a condition has already ended here; join conditions with and or or`,
+		},
+		"unknown concern": {
+			text: "hasConcern(empty)",
+			wantErr: `at column 12, "empty": hasConcern needs the name of a concern: files,` +
+				" integrity, numbering",
+		},
+		"unknown function": {
+			text:    "isLive(album)",
+			wantErr: `at column 1, "isLive": there is no such function; the only function is hasConcern`,
+		},
+		"bad number": {
+			text:    "year > 19x0",
+			wantErr: `at column 8, "19x0": this is neither a whole number nor a duration, such as 3m30s`,
+		},
+		"stray character": {
+			text:    "year > 1990 & disc == 2",
+			wantErr: `at column 13, "&": this character cannot be used outside of quoted text`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cmd.ParseWhereExpression(tt.text)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("ParseWhereExpression() error = %q, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("ParseWhereExpression() error = nil, want %q", tt.wantErr)
+			}
+			if got.String() != tt.text {
+				t.Errorf("ParseWhereExpression() String() = %q, want %q", got.String(), tt.text)
+			}
+		})
+	}
+}
+
+func TestWhereExpressionMatches(t *testing.T) {
+	dir := t.TempDir()
+	// each 128 kbps frame holds about 26ms of audio
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x40})
+	artist := files.NewArtist("The Band", dir)
+	complete := files.NewAlbum("Live", artist, dir)
+	gapped := files.NewAlbum("Studio", artist, dir)
+	artist.AddAlbum(complete)
+	artist.AddAlbum(gapped)
+	tagged := func(genre, album, title, year string) *files.TrackMetadata {
+		return files.NewTrackMetadata().WithGenres([]string{"", "", genre}).WithArtistNames(
+			[]string{"", "", "The Band"}).WithAlbumNames([]string{"", "", album}).WithTrackNames(
+			[]string{"", "", title}).WithYears([]string{"", "", year}).WithPrimarySource(
+			files.ID3V2).WithID3V2Version(3)
+	}
+	addTrack := func(album *files.Album, name string, number, frames int, tM *files.TrackMetadata) {
+		fileName := name + ".mp3"
+		if frames > 0 {
+			path := filepath.Join(dir, fileName)
+			if err := os.WriteFile(path, bytes.Repeat(frame, frames), 0o644); err != nil {
+				t.Errorf("WhereExpression.Matches() cannot create %q: %v", path, err)
+			}
+		}
+		album.AddTrack(files.NewTrack(album, fileName, name, number).WithMetadata(tM))
+	}
+	addTrack(complete, "opener", 1, 100, tagged("Rock", "Live", "opener", "1975"))
+	addTrack(complete, "jam", 2, 25000, tagged("Jazz", "Live at the Fillmore", "jam", "1995-06-01"))
+	addTrack(gapped, "first", 1, 0, tagged("Rock", "Studio", "first", "1985"))
+	addTrack(gapped, "third", 3, 0, files.NewTrackMetadata().WithErrorCauses(
+		[]string{"", "no ID3V1 tag", "no ID3V2 tag"}))
+	tests := map[string]struct {
+		text string
+		want []string
+	}{
+		"name regex":       {text: `artist ~ "^The"`, want: []string{"opener", "jam", "first", "third"}},
+		"text comparison":  {text: `genre == "Jazz"`, want: []string{"jam"}},
+		"case matters":     {text: `genre == "jazz"`},
+		"case ignored":     {text: `genre ~ "(?i)^jazz$"`, want: []string{"jam"}},
+		"year":             {text: "year >= 1980 and year < 1990", want: []string{"first"}},
+		"or binds loosely": {text: `genre == "Jazz" or year < 1980 and number == 1`, want: []string{"opener", "jam"}},
+		"parentheses": {
+			text: `(genre == "Jazz" or year < 1980) and number == 2`,
+			want: []string{"jam"},
+		},
+		"not":           {text: `not genre == "Rock"`, want: []string{"jam", "third"}},
+		"fields differ": {text: "tagAlbum != album", want: []string{"jam", "third"}},
+		"no match":      {text: `artist !~ "Band"`},
+		"numbering":     {text: "hasConcern(numbering)", want: []string{"first", "third"}},
+		"no numbering":  {text: "not hasConcern(numbering)", want: []string{"opener", "jam"}},
+		"duration":      {text: "duration > 10m", want: []string{"jam"}},
+		"bitrate":       {text: "bitrate == 128", want: []string{"opener", "jam"}},
+		"no ID3V2 tag":  {text: "id3Version == 0", want: []string{"third"}},
+		"everything": {
+			text: `artist ~ "^The" and (year >= 1990 or genre == "Jazz") and not hasConcern(numbering)`,
+			want: []string{"jam"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w, err := cmd.ParseWhereExpression(tt.text)
+			if err != nil {
+				t.Fatalf("WhereExpression.Matches() cannot parse %q: %v", tt.text, err)
+			}
+			var got []string
+			for _, album := range artist.Albums() {
+				for _, track := range album.Tracks() {
+					if w.Matches(track) {
+						got = append(got, track.CommonName())
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WhereExpression.Matches() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateWhere(t *testing.T) {
+	tests := map[string]struct {
+		values map[string]*cmd.FlagValue
+		want   string
+		wantOk bool
+		output.WantedRecording
+	}{
+		"empty": {
+			values: map[string]*cmd.FlagValue{
+				"where": cmd.NewFlagValue().WithValueType(cmd.StringType).WithValue(" "),
+			},
+			wantOk: true,
+		},
+		"good": {
+			values: map[string]*cmd.FlagValue{
+				"where": cmd.NewFlagValue().WithValueType(cmd.StringType).WithValue(
+					"hasConcern(files)"),
+			},
+			want:   "hasConcern(files)",
+			wantOk: true,
+		},
+		"user set bad expression": {
+			values: map[string]*cmd.FlagValue{
+				"where": cmd.NewFlagValue().WithValueType(cmd.StringType).WithValue(
+					"year >= 1990 and").WithExplicitlySet(true),
+			},
+			WantedRecording: output.WantedRecording{
+				Error: "The --where value \"year >= 1990 and\" cannot be used.\n" +
+					"Why?\n" +
+					"The expression cannot be read at column 17, the end of the expression:" +
+					" a condition is needed here, such as genre == \"Jazz\" or" +
+					" hasConcern(numbering).\n" +
+					"What to do:\n" +
+					"Correct that part of the expression. A condition compares a field with a" +
+					" value or another field, as in year >= 1990; matches a field with a quoted" +
+					" regular expression, as in artist ~ \"^The\"; or looks for a concern, as in" +
+					" hasConcern(numbering). Conditions are joined with and, or, and not, and" +
+					" grouped with parentheses. The fields are album, artist, bitrate, disc," +
+					" duration, genre, id3Version, number, tagAlbum, tagArtist, tagTitle," +
+					" title, year.\n",
+				Log: "level='error'" +
+					" --where='year >= 1990 and'" +
+					" error='at column 17, the end of the expression: a condition is needed" +
+					" here, such as genre == \"Jazz\" or hasConcern(numbering)'" +
+					" user-set='true'" +
+					" msg='invalid where expression'\n",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			got, gotOk := cmd.EvaluateWhere(o, tt.values)
+			if got.String() != tt.want {
+				t.Errorf("EvaluateWhere() got %q, want %q", got, tt.want)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("EvaluateWhere() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("EvaluateWhere() %s", difference)
+				}
+			}
+		})
+	}
+}
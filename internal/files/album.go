@@ -3,6 +3,7 @@ package files
 import (
 	"io/fs"
 	"path/filepath"
+	"slices"
 
 	"github.com/bogem/id3v2/v2"
 )
@@ -18,6 +19,13 @@ type Album struct {
 	canonicalTitle    string
 	canonicalYear     string
 	musicCDIdentifier id3v2.UnknownFrame
+	// the genre and year found in the album's path by a layout; when set, they
+	// take precedence over the values recorded in the tracks' metadata
+	pathGenre string
+	pathYear  string
+	// the track files, relative to the album's path, whose names could not be
+	// parsed into a track number and name
+	unparsedTracks []string
 }
 
 func NewEmptyAlbum() *Album {
@@ -58,6 +66,22 @@ func (a *Album) WithCanonicalYear(s string) *Album {
 	return a
 }
 
+// WithPathGenre sets the genre found in the album's path, which also becomes
+// its canonical genre
+func (a *Album) WithPathGenre(s string) *Album {
+	a.pathGenre = s
+	a.canonicalGenre = s
+	return a
+}
+
+// WithPathYear sets the year found in the album's path, which also becomes its
+// canonical year
+func (a *Album) WithPathYear(s string) *Album {
+	a.pathYear = s
+	a.canonicalYear = s
+	return a
+}
+
 func (a *Album) WithMusicCDIdentifier(b []byte) *Album {
 	a.musicCDIdentifier = id3v2.UnknownFrame{Body: b}
 	return a
@@ -74,11 +98,14 @@ func (a *Album) Copy(ar *Artist, includeTracks bool) *Album {
 		for _, t := range a.tracks {
 			a2.AddTrack(t.Copy(a2))
 		}
+		a2.unparsedTracks = slices.Clone(a.unparsedTracks)
 	}
 	a2.canonicalGenre = a.canonicalGenre
 	a2.canonicalYear = a.canonicalYear
 	a2.canonicalTitle = a.canonicalTitle
 	a2.musicCDIdentifier = a.musicCDIdentifier
+	a2.pathGenre = a.pathGenre
+	a2.pathYear = a.pathYear
 	return a2
 }
 
@@ -124,6 +151,18 @@ func (a *Album) Tracks() []*Track {
 	return a.tracks
 }
 
+// AddUnparsedTrack records a track file, given by its path relative to the
+// album's path, whose name could not be parsed into a track number and name
+func (a *Album) AddUnparsedTrack(fileName string) {
+	a.unparsedTracks = append(a.unparsedTracks, fileName)
+}
+
+// UnparsedTracks returns the track files whose names could not be parsed, in
+// the order in which they were found
+func (a *Album) UnparsedTracks() []string {
+	return a.unparsedTracks
+}
+
 func (a *Album) subDirectory(s string) string {
 	return filepath.Join(a.path, s)
 }
@@ -40,6 +40,7 @@ func TestAlbum_Copy(t *testing.T) {
 			fmt.Sprintf("track %d.mp3", k), k)
 		complexAlbum.AddTrack(track)
 	}
+	complexAlbum.AddUnparsedTrack("bonus track.mp3")
 	complexAlbum2 := files.NewAlbum("my album", files.NewArtist("my artist",
 		"Music/my artist"), "Music/my artist/my album").WithCanonicalGenre(
 		"rap").WithCanonicalTitle("my special album").WithCanonicalYear(
@@ -49,6 +50,7 @@ func TestAlbum_Copy(t *testing.T) {
 			fmt.Sprintf("track %d.mp3", k), k)
 		complexAlbum2.AddTrack(track)
 	}
+	complexAlbum2.AddUnparsedTrack("bonus track.mp3")
 	type args struct {
 		ar            *files.Artist
 		includeTracks bool
@@ -94,7 +96,7 @@ func TestAlbum_BackupDirectory(t *testing.T) {
 	}{
 		"simple": {
 			a:    files.NewAlbum("album", nil, "artist/album"),
-			want: "artist\\album\\pre-repair-backup",
+			want: filepath.Join("artist", "album", "pre-repair-backup"),
 		},
 	}
 	for name, tt := range tests {
@@ -0,0 +1,454 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// values per https://wiki.hydrogenaud.io/index.php?title=APEv2_specification
+const (
+	// the header and footer both begin with this preamble
+	apeV2Preamble = "APETAGEX"
+	// the header and footer are the same size
+	apeV2FooterLength = 32
+	// offsets of the fields within the header or footer
+	apeV2VersionOffset   = 8
+	apeV2SizeOffset      = 12
+	apeV2CountOffset     = 16
+	apeV2FlagsOffset     = 20
+	apeV2Version         = 2000
+	apeV2HasHeaderFlag   = uint32(1) << 31
+	apeV2IsHeaderFlag    = uint32(1) << 29
+	apeV2ItemTypeMask    = uint32(0x6)
+	apeV2ItemHeaderSize  = 8
+	apeV2MinimumKeySize  = 2
+	apeV2MaximumKeySize  = 255
+	apeV2TextValueMarker = uint32(0)
+
+	apeV2AlbumKey  = "Album"
+	apeV2ArtistKey = "Artist"
+	apeV2GenreKey  = "Genre"
+	apeV2TitleKey  = "Title"
+	apeV2TrackKey  = "Track"
+	apeV2YearKey   = "Year"
+)
+
+// ErrNoApeV2Metadata is the error returned when a file has no APEv2 tag; unlike
+// ID3V1 and ID3V2 tags, APEv2 tags are rare, and their absence is not a problem
+var ErrNoApeV2Metadata = fmt.Errorf("no APEv2 metadata found")
+
+// ApeV2Item is a single item in an APEv2 tag: a key and its value, which is
+// usually UTF-8 text, but may be binary data
+type ApeV2Item struct {
+	flags uint32
+	key   string
+	value []byte
+}
+
+func (ai *ApeV2Item) WithFlags(f uint32) *ApeV2Item {
+	ai.flags = f
+	return ai
+}
+
+func (ai *ApeV2Item) WithKey(s string) *ApeV2Item {
+	ai.key = s
+	return ai
+}
+
+func (ai *ApeV2Item) WithValue(b []byte) *ApeV2Item {
+	ai.value = b
+	return ai
+}
+
+func NewApeV2Item() *ApeV2Item {
+	return &ApeV2Item{}
+}
+
+// IsText returns true if the item's value is UTF-8 text
+func (ai *ApeV2Item) IsText() bool {
+	return ai.flags&apeV2ItemTypeMask == apeV2TextValueMarker
+}
+
+// Text returns the item's value as text; a text value may hold several values,
+// separated by null bytes, and only the first such value is returned
+func (ai *ApeV2Item) Text() string {
+	s, _, _ := strings.Cut(string(ai.value), "\u0000")
+	return s
+}
+
+// String returns the contents of an ApeV2Item formatted in the form
+// "key = \"value\"".
+func (ai *ApeV2Item) String() string {
+	if !ai.IsText() {
+		return fmt.Sprintf("%s = <<%d bytes of binary data>>", ai.key, len(ai.value))
+	}
+	return fmt.Sprintf("%s = %q", ai.key, ai.Text())
+}
+
+// ApeV2Tag holds the items of an APEv2 tag, and the location of the tag within
+// its file
+type ApeV2Tag struct {
+	items []*ApeV2Item
+	// offsets of the first byte of the tag (including its header, if any) and
+	// of the first byte following the tag's footer
+	start int64
+	end   int64
+}
+
+func (at *ApeV2Tag) WithItems(items []*ApeV2Item) *ApeV2Tag {
+	at.items = items
+	return at
+}
+
+func NewApeV2Tag() *ApeV2Tag {
+	return &ApeV2Tag{}
+}
+
+// Items returns the tag's items
+func (at *ApeV2Tag) Items() []*ApeV2Item {
+	return at.items
+}
+
+func (at *ApeV2Tag) find(key string) *ApeV2Item {
+	// keys are case-insensitive
+	for _, item := range at.items {
+		if strings.EqualFold(item.key, key) {
+			return item
+		}
+	}
+	return nil
+}
+
+// Value returns the text value for the specified key, or an empty string if
+// there is no such text value
+func (at *ApeV2Tag) Value(key string) string {
+	if item := at.find(key); item != nil && item.IsText() {
+		return item.Text()
+	}
+	return ""
+}
+
+// SetValue replaces the value for the specified key with the specified text,
+// adding a new item if the tag has no item for that key
+func (at *ApeV2Tag) SetValue(key, value string) {
+	if item := at.find(key); item != nil {
+		item.flags &^= apeV2ItemTypeMask
+		item.value = []byte(value)
+		return
+	}
+	at.items = append(at.items, &ApeV2Item{key: key, value: []byte(value)})
+}
+
+// Bytes returns the tag as it should be written to a file: a header, the items,
+// and a footer
+func (at *ApeV2Tag) Bytes() []byte {
+	var items []byte
+	for _, item := range at.items {
+		items = binary.LittleEndian.AppendUint32(items, uint32(len(item.value)))
+		items = binary.LittleEndian.AppendUint32(items, item.flags)
+		items = append(items, item.key...)
+		items = append(items, 0)
+		items = append(items, item.value...)
+	}
+	size := uint32(len(items) + apeV2FooterLength)
+	content := make([]byte, 0, len(items)+2*apeV2FooterLength)
+	content = append(content,
+		createApeV2Footer(size, len(at.items), apeV2HasHeaderFlag|apeV2IsHeaderFlag)...)
+	content = append(content, items...)
+	content = append(content, createApeV2Footer(size, len(at.items), apeV2HasHeaderFlag)...)
+	return content
+}
+
+func createApeV2Footer(size uint32, count int, flags uint32) []byte {
+	footer := make([]byte, apeV2FooterLength)
+	copy(footer, apeV2Preamble)
+	binary.LittleEndian.PutUint32(footer[apeV2VersionOffset:], apeV2Version)
+	binary.LittleEndian.PutUint32(footer[apeV2SizeOffset:], size)
+	binary.LittleEndian.PutUint32(footer[apeV2CountOffset:], uint32(count))
+	binary.LittleEndian.PutUint32(footer[apeV2FlagsOffset:], flags)
+	return footer
+}
+
+func readApeV2Tag(path string) (*ApeV2Tag, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// the tag is at the end of the file, but precedes any ID3V1 tag
+	end := stat.Size()
+	if end >= id3v1Length {
+		v1 := NewID3v1Metadata()
+		if _, err = file.ReadAt(v1.data, end-id3v1Length); err != nil {
+			return nil, err
+		}
+		if v1.IsValid() {
+			end -= id3v1Length
+		}
+	}
+	if end < apeV2FooterLength {
+		return nil, ErrNoApeV2Metadata
+	}
+	footer := make([]byte, apeV2FooterLength)
+	if _, err = file.ReadAt(footer, end-apeV2FooterLength); err != nil {
+		return nil, err
+	}
+	if string(footer[:apeV2VersionOffset]) != apeV2Preamble {
+		return nil, ErrNoApeV2Metadata
+	}
+	size := int64(binary.LittleEndian.Uint32(footer[apeV2SizeOffset:]))
+	count := int(binary.LittleEndian.Uint32(footer[apeV2CountOffset:]))
+	flags := binary.LittleEndian.Uint32(footer[apeV2FlagsOffset:])
+	itemsStart := end - size
+	start := itemsStart
+	if flags&apeV2HasHeaderFlag != 0 {
+		start -= apeV2FooterLength
+	}
+	if size < apeV2FooterLength || start < 0 {
+		return nil, fmt.Errorf("APEv2 metadata in file %q has an invalid size, %d", path,
+			size)
+	}
+	data := make([]byte, size-apeV2FooterLength)
+	if _, err = file.ReadAt(data, itemsStart); err != nil {
+		return nil, err
+	}
+	items, err := parseApeV2Items(data, count)
+	if err != nil {
+		return nil, fmt.Errorf("APEv2 metadata in file %q is corrupt: %w", path, err)
+	}
+	return &ApeV2Tag{items: items, start: start, end: end}, nil
+}
+
+func parseApeV2Items(data []byte, count int) ([]*ApeV2Item, error) {
+	// the count comes from the file, and cannot be trusted to size the slice
+	items := make([]*ApeV2Item, 0, min(count, len(data)/apeV2ItemHeaderSize))
+	for k := 0; k < count; k++ {
+		if len(data) < apeV2ItemHeaderSize {
+			return nil, fmt.Errorf("item %d is truncated", k)
+		}
+		valueSize := int(binary.LittleEndian.Uint32(data))
+		flags := binary.LittleEndian.Uint32(data[4:])
+		data = data[apeV2ItemHeaderSize:]
+		keySize := bytes.IndexByte(data, 0)
+		if keySize < apeV2MinimumKeySize || keySize > apeV2MaximumKeySize {
+			return nil, fmt.Errorf("item %d has an invalid key", k)
+		}
+		key := string(data[:keySize])
+		data = data[keySize+1:]
+		if valueSize < 0 || valueSize > len(data) {
+			return nil, fmt.Errorf("item %d (%q) has an invalid size, %d", k, key, valueSize)
+		}
+		items = append(items, &ApeV2Item{flags: flags, key: key, value: data[:valueSize]})
+		data = data[valueSize:]
+	}
+	return items, nil
+}
+
+// write replaces the tag's original content in the file with the tag's current
+// content
+func (at *ApeV2Tag) write(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if at.end > int64(len(content)) || at.start > at.end {
+		return fmt.Errorf("APEv2 metadata in file %q has moved", path)
+	}
+	tag := at.Bytes()
+	newContent := make([]byte, 0, int64(len(content))-(at.end-at.start)+int64(len(tag)))
+	newContent = append(newContent, content[:at.start]...)
+	newContent = append(newContent, tag...)
+	newContent = append(newContent, content[at.end:]...)
+	return rewriteFile(path, newContent)
+}
+
+type ApeV2Metadata struct {
+	albumName   string
+	artistName  string
+	err         error
+	genre       string
+	trackName   string
+	trackNumber int
+	year        string
+}
+
+func (am *ApeV2Metadata) HasError() bool {
+	return am.err != nil
+}
+
+func (am *ApeV2Metadata) WithAlbumName(s string) *ApeV2Metadata {
+	am.albumName = s
+	return am
+}
+
+func (am *ApeV2Metadata) WithArtistName(s string) *ApeV2Metadata {
+	am.artistName = s
+	return am
+}
+
+func (am *ApeV2Metadata) WithTrackName(s string) *ApeV2Metadata {
+	am.trackName = s
+	return am
+}
+
+func (am *ApeV2Metadata) WithGenre(s string) *ApeV2Metadata {
+	am.genre = s
+	return am
+}
+
+func (am *ApeV2Metadata) WithYear(s string) *ApeV2Metadata {
+	am.year = s
+	return am
+}
+
+func (am *ApeV2Metadata) WithTrackNumber(i int) *ApeV2Metadata {
+	am.trackNumber = i
+	return am
+}
+
+func (am *ApeV2Metadata) WithErr(e error) *ApeV2Metadata {
+	am.err = e
+	return am
+}
+
+func NewApeV2Metadata() *ApeV2Metadata {
+	return &ApeV2Metadata{}
+}
+
+func RawReadApeV2Metadata(path string) (d *ApeV2Metadata) {
+	d = &ApeV2Metadata{}
+	if tag, err := readApeV2Tag(path); err != nil {
+		d.err = err
+	} else {
+		if trackNumber, err := ToTrackNumber(tag.Value(apeV2TrackKey)); err != nil {
+			d.err = err
+		} else {
+			d.albumName = tag.Value(apeV2AlbumKey)
+			d.artistName = tag.Value(apeV2ArtistKey)
+			d.genre = tag.Value(apeV2GenreKey)
+			d.trackName = tag.Value(apeV2TitleKey)
+			d.trackNumber = trackNumber
+			d.year = tag.Value(apeV2YearKey)
+		}
+	}
+	return
+}
+
+// ReadApeV2Metadata returns the items of the file's APEv2 tag, sorted by key
+func ReadApeV2Metadata(path string) ([]string, error) {
+	tag, err := readApeV2Tag(path)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*ApeV2Item, len(tag.items))
+	copy(items, tag.items)
+	sort.SliceStable(items, func(i, j int) bool {
+		return strings.ToLower(items[i].key) < strings.ToLower(items[j].key)
+	})
+	output := make([]string, 0, len(items))
+	for _, item := range items {
+		output = append(output, item.String())
+	}
+	return output, nil
+}
+
+func updateApeV2Metadata(tM *TrackMetadata, path string, sT SourceType) (e error) {
+	if tM.requiresEdit[sT] {
+		if tag, err := readApeV2Tag(path); err != nil {
+			e = err
+		} else {
+			album := tM.correctedAlbumName[sT]
+			if album != "" {
+				tag.SetValue(apeV2AlbumKey, album)
+			}
+			artist := tM.correctedArtistName[sT]
+			if artist != "" {
+				tag.SetValue(apeV2ArtistKey, artist)
+			}
+			title := tM.correctedTrackName[sT]
+			if title != "" {
+				tag.SetValue(apeV2TitleKey, title)
+			}
+			track := tM.correctedTrackNumber[sT]
+			if track != 0 {
+				tag.SetValue(apeV2TrackKey, fmt.Sprintf("%d", track))
+			}
+			genre := tM.correctedGenre[sT]
+			if genre != "" {
+				tag.SetValue(apeV2GenreKey, genre)
+			}
+			year := tM.correctedYear[sT]
+			if year != "" {
+				tag.SetValue(apeV2YearKey, year)
+			}
+			e = tag.write(path)
+		}
+	}
+	return
+}
+
+// APEv2 text values, like ID3V2 text frames, are not limited in length or
+// character set, so they are compared the same way
+func ApeV2NameDiffers(cS *ComparableStrings) bool {
+	return Id3v2NameDiffers(cS)
+}
+
+func ApeV2GenreDiffers(cS *ComparableStrings) bool {
+	return Id3v2GenreDiffers(cS)
+}
+
+// apeV2Source is the MetadataSource for APEv2 tags
+type apeV2Source struct{}
+
+func init() {
+	registerMetadataSource(apeV2Source{})
+}
+
+func (apeV2Source) Type() SourceType {
+	return APEV2
+}
+
+func (apeV2Source) Name() string {
+	return "APEV2"
+}
+
+func (apeV2Source) Extensions() []string {
+	return nil
+}
+
+func (apeV2Source) Read(path string, tM *TrackMetadata) error {
+	ape := RawReadApeV2Metadata(path)
+	if ape.err == nil {
+		tM.SetApeV2Values(ape)
+	}
+	return ape.err
+}
+
+func (apeV2Source) NameDiffers(cS *ComparableStrings) bool {
+	return ApeV2NameDiffers(cS)
+}
+
+func (apeV2Source) GenreDiffers(cS *ComparableStrings) bool {
+	return ApeV2GenreDiffers(cS)
+}
+
+func (apeV2Source) Write(tM *TrackMetadata, path string) error {
+	return updateApeV2Metadata(tM, path, APEV2)
+}
+
+func (apeV2Source) Diagnostics(path string) ([]string, error) {
+	return ReadApeV2Metadata(path)
+}
+
+// most track files have no APEv2 tag
+func (apeV2Source) MissingError() error {
+	return ErrNoApeV2Metadata
+}
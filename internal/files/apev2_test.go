@@ -0,0 +1,269 @@
+package files_test
+
+import (
+	"encoding/binary"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+// createApeV2TaggedData creates an APEv2 tag containing the specified text
+// items, sorted by key; if withHeader is false, the tag has a footer only, as
+// APEv1 tags do
+func createApeV2TaggedData(items map[string]string, withHeader bool) []byte {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var body []byte
+	for _, k := range keys {
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(items[k])))
+		body = binary.LittleEndian.AppendUint32(body, 0)
+		body = append(body, k...)
+		body = append(body, 0)
+		body = append(body, items[k]...)
+	}
+	footer := func(flags uint32) []byte {
+		f := []byte("APETAGEX")
+		f = binary.LittleEndian.AppendUint32(f, 2000)
+		f = binary.LittleEndian.AppendUint32(f, uint32(len(body)+32))
+		f = binary.LittleEndian.AppendUint32(f, uint32(len(items)))
+		f = binary.LittleEndian.AppendUint32(f, flags)
+		return append(f, 0, 0, 0, 0, 0, 0, 0, 0)
+	}
+	var content []byte
+	var flags uint32
+	if withHeader {
+		flags = 1 << 31
+		content = append(content, footer(flags|1<<29)...)
+	}
+	content = append(content, body...)
+	return append(content, footer(flags)...)
+}
+
+func TestRawReadApeV2Metadata(t *testing.T) {
+	const fnName = "RawReadApeV2Metadata()"
+	testDir := "rawReadApeV2"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	items := map[string]string{
+		"ALBUM":  "unknown album",
+		"Artist": "unknown artist",
+		"Genre":  "dance music",
+		"Title":  "unknown track",
+		"Track":  "2/12",
+		"Year":   "2022",
+	}
+	audio := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	fileContents := map[string][]byte{
+		"tagless.mp3": audio,
+		"header.mp3": append(append([]byte{}, audio...),
+			createApeV2TaggedData(items, true)...),
+		"footerOnly.mp3": append(append([]byte{}, audio...),
+			createApeV2TaggedData(items, false)...),
+		"withID3V1.mp3": append(append(append([]byte{}, audio...),
+			createApeV2TaggedData(items, true)...), id3v1DataSet1...),
+		"badTrack.mp3":    createApeV2TaggedData(map[string]string{"Track": "two"}, true),
+		"truncated.mp3":   createApeV2TaggedData(items, true)[40:],
+		"corruptItem.mp3": createApeV2TaggedData(map[string]string{"T": "short key"}, true),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	wantData := files.NewApeV2Metadata().WithAlbumName("unknown album").WithArtistName(
+		"unknown artist").WithGenre("dance music").WithTrackName(
+		"unknown track").WithTrackNumber(2).WithYear("2022")
+	tests := map[string]struct {
+		path      string
+		want      *files.ApeV2Metadata
+		wantError bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantError: true},
+		"no tag": {
+			path: filepath.Join(testDir, "tagless.mp3"),
+			want: files.NewApeV2Metadata().WithErr(files.ErrNoApeV2Metadata),
+		},
+		"header":       {path: filepath.Join(testDir, "header.mp3"), want: wantData},
+		"footer only":  {path: filepath.Join(testDir, "footerOnly.mp3"), want: wantData},
+		"with ID3V1":   {path: filepath.Join(testDir, "withID3V1.mp3"), want: wantData},
+		"bad track":    {path: filepath.Join(testDir, "badTrack.mp3"), wantError: true},
+		"truncated":    {path: filepath.Join(testDir, "truncated.mp3"), wantError: true},
+		"corrupt item": {path: filepath.Join(testDir, "corruptItem.mp3"), wantError: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := files.RawReadApeV2Metadata(tt.path)
+			if tt.wantError {
+				if !got.HasError() {
+					t.Errorf("%s = %#v, want error", fnName, got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadApeV2Metadata(t *testing.T) {
+	const fnName = "ReadApeV2Metadata()"
+	testDir := "readApeV2"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	tag := files.NewApeV2Tag().WithItems([]*files.ApeV2Item{
+		files.NewApeV2Item().WithKey("Title").WithValue([]byte("my track")),
+		files.NewApeV2Item().WithKey("Cover Art (Front)").WithFlags(2).WithValue(
+			[]byte{1, 2, 3, 4}),
+		files.NewApeV2Item().WithKey("artist").WithValue(
+			[]byte("my artist\u0000my other artist")),
+	})
+	if err := createFileWithContent(testDir, "tagged.mp3", tag.Bytes()); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.mp3", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.mp3"),
+			want: []string{
+				`artist = "my artist"`,
+				"Cover Art (Front) = <<4 bytes of binary data>>",
+				`Title = "my track"`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadApeV2Metadata(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApeV2Tag_SetValue(t *testing.T) {
+	const fnName = "ApeV2Tag.SetValue()"
+	tests := map[string]struct {
+		tag       *files.ApeV2Tag
+		key       string
+		value     string
+		wantCount int
+	}{
+		"new key": {
+			tag:       files.NewApeV2Tag(),
+			key:       "Album",
+			value:     "new album",
+			wantCount: 1,
+		},
+		"existing key, different case": {
+			tag: files.NewApeV2Tag().WithItems([]*files.ApeV2Item{
+				files.NewApeV2Item().WithKey("ALBUM").WithValue([]byte("old album")),
+			}),
+			key:       "Album",
+			value:     "new album",
+			wantCount: 1,
+		},
+		"existing binary item": {
+			tag: files.NewApeV2Tag().WithItems([]*files.ApeV2Item{
+				files.NewApeV2Item().WithKey("album").WithFlags(2).WithValue([]byte{0}),
+			}),
+			key:       "Album",
+			value:     "new album",
+			wantCount: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.tag.SetValue(tt.key, tt.value)
+			if got := tt.tag.Value(tt.key); got != tt.value {
+				t.Errorf("%s got %q, want %q", fnName, got, tt.value)
+			}
+			if got := len(tt.tag.Items()); got != tt.wantCount {
+				t.Errorf("%s got %d items, want %d", fnName, got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestApeV2NameDiffers(t *testing.T) {
+	const fnName = "ApeV2NameDiffers()"
+	tests := map[string]struct {
+		cS   *files.ComparableStrings
+		want bool
+	}{
+		"identical": {
+			cS: files.NewComparableStrings().WithExternal("simple name").WithMetadata(
+				"simple name"),
+			want: false,
+		},
+		"illegal file name character": {
+			cS: files.NewComparableStrings().WithExternal("simple_name").WithMetadata(
+				"simple:name"),
+			want: false,
+		},
+		"different": {
+			cS: files.NewComparableStrings().WithExternal("simple name").WithMetadata(
+				"other name"),
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := files.ApeV2NameDiffers(tt.cS); got != tt.want {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApeV2Tag_Bytes(t *testing.T) {
+	const fnName = "ApeV2Tag.Bytes()"
+	items := map[string]string{"Album": "my album", "Title": "my title"}
+	tag := files.NewApeV2Tag().WithItems([]*files.ApeV2Item{
+		files.NewApeV2Item().WithKey("Album").WithValue([]byte("my album")),
+		files.NewApeV2Item().WithKey("Title").WithValue([]byte("my title")),
+	})
+	if got, want := tag.Bytes(), createApeV2TaggedData(items, true); !reflect.DeepEqual(
+		got, want) {
+		t.Errorf("%s = %v, want %v", fnName, got, want)
+	}
+	// verify the output can be read back
+	path := "apeV2Bytes.mp3"
+	if err := createFileWithContent(".", path, tag.Bytes()); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, path, err)
+	}
+	defer func() {
+		if err := os.Remove(path); err != nil {
+			t.Errorf("%s error removing %q: %v", fnName, path, err)
+		}
+	}()
+	got := files.RawReadApeV2Metadata(path)
+	// no track number, so the tag is unusable as a source of metadata
+	want := files.NewApeV2Metadata().WithErr(files.ErrMissingTrackNumber)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s read back %#v, want %#v", fnName, got, want)
+	}
+}
@@ -5,6 +5,13 @@ import (
 	"path/filepath"
 )
 
+// DefaultCompilationArtists returns the names of the artist directories, such as
+// "Various Artists", whose albums are, by default, compilations of tracks by
+// many artists
+func DefaultCompilationArtists() []string {
+	return []string{"Various Artists"}
+}
+
 // Artist encapsulates information about a recording artist (a solo performer, a
 // duo, a band, etc.)
 type Artist struct {
@@ -13,6 +20,10 @@ type Artist struct {
 	path     string
 	// artist name as recorded in the metadata for each track in each album
 	canonicalName string
+	// true if the artist's directory holds compilations
+	compilation bool
+	// the settings with which the artist's tracks are read
+	settings *ReadSettings
 }
 
 func (a *Artist) WithAlbums(albums []*Album) *Artist {
@@ -30,6 +41,18 @@ func (a *Artist) WithCanonicalName(s string) *Artist {
 	return a
 }
 
+// WithCompilation marks the artist's directory as one that holds compilations
+func (a *Artist) WithCompilation(b bool) *Artist {
+	a.compilation = b
+	return a
+}
+
+// WithReadSettings sets the settings with which the artist's tracks are read
+func (a *Artist) WithReadSettings(settings *ReadSettings) *Artist {
+	a.settings = settings
+	return a
+}
+
 func NewEmptyArtist() *Artist {
 	return &Artist{}
 }
@@ -42,6 +65,8 @@ func NewArtistFromFile(f fs.DirEntry, dir string) *Artist {
 func (a *Artist) Copy() *Artist {
 	a2 := NewArtist(a.fileName, a.Path())
 	a2.canonicalName = a.canonicalName
+	a2.compilation = a.compilation
+	a2.settings = a.settings
 	return a2
 }
 
@@ -63,6 +88,16 @@ func (a *Artist) subDirectory(s string) string {
 	return filepath.Join(a.path, s)
 }
 
+// MergeAlbums moves the other artist's albums to this artist, as when the same
+// artist is found under more than one top directory
+func (a *Artist) MergeAlbums(other *Artist) {
+	for _, album := range other.albums {
+		album.artist = a
+		a.albums = append(a.albums, album)
+	}
+	other.albums = nil
+}
+
 // AddAlbum adds an album to the artist's slice of albums
 func (a *Artist) AddAlbum(album *Album) {
 	a.albums = append(a.albums, album)
@@ -73,6 +108,19 @@ func (a *Artist) Albums() []*Album {
 	return a.albums
 }
 
+// IsCompilation returns true if the artist's directory holds compilations
+func (a *Artist) IsCompilation() bool {
+	return a.compilation
+}
+
+// ReadSettings returns the settings with which the artist's tracks are read
+func (a *Artist) ReadSettings() *ReadSettings {
+	if a.settings == nil {
+		return defaultReadSettings
+	}
+	return a.settings
+}
+
 // HasAlbums returns true if there any albums associated with the artist
 func (a *Artist) HasAlbums() bool {
 	return len(a.albums) != 0
@@ -13,6 +13,11 @@ func TestArtist_Copy(t *testing.T) {
 		"artist's name", "Music/artist's name").WithCanonicalName("Actually, Fred")
 	complexArtist2 := files.NewArtist(
 		"artist's name", "Music/artist's name").WithCanonicalName("Actually, Fred")
+	settings := files.NewReadSettings().WithMetadataReaders(3)
+	compilation := files.NewArtist("Various Artists", "Music/Various Artists").WithCompilation(
+		true).WithReadSettings(settings)
+	compilation2 := files.NewArtist("Various Artists", "Music/Various Artists").WithCompilation(
+		true).WithReadSettings(settings)
 	tests := map[string]struct {
 		a    *files.Artist
 		want *files.Artist
@@ -25,6 +30,10 @@ func TestArtist_Copy(t *testing.T) {
 			a:    complexArtist,
 			want: complexArtist2,
 		},
+		"compilation with settings": {
+			a:    compilation,
+			want: compilation2,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -81,3 +90,75 @@ func TestArtist_HasAlbums(t *testing.T) {
 		})
 	}
 }
+
+func TestArtist_MergeAlbums(t *testing.T) {
+	artist := files.NewArtist("artist", filepath.Join("music", "artist"))
+	artist.AddAlbum(files.NewAlbum("album 1", artist, filepath.Join("music", "artist",
+		"album 1")))
+	other := files.NewArtist("artist", filepath.Join("overflow", "artist"))
+	other.AddAlbum(files.NewAlbum("album 2", other, filepath.Join("overflow", "artist",
+		"album 2")))
+	artist.MergeAlbums(other)
+	if other.HasAlbums() {
+		t.Errorf("Artist.MergeAlbums() left albums behind: %v", other.Albums())
+	}
+	var names []string
+	for _, album := range artist.Albums() {
+		names = append(names, album.Name())
+		if album.GetArtist() != artist {
+			t.Errorf("Artist.MergeAlbums() album %q has artist %v", album.Name(),
+				album.GetArtist())
+		}
+	}
+	if want := []string{"album 1", "album 2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Artist.MergeAlbums() albums = %v, want %v", names, want)
+	}
+}
+
+func TestArtist_IsCompilation(t *testing.T) {
+	tests := map[string]struct {
+		a    *files.Artist
+		want bool
+	}{
+		"ordinary artist": {a: files.NewArtist("The Beatles", "Music/The Beatles")},
+		"unmarked compilation name": {
+			a: files.NewArtist("Various Artists", "Music/Various Artists"),
+		},
+		"compilation": {
+			a: files.NewArtist("Various Artists", "Music/Various Artists").WithCompilation(
+				true),
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.a.IsCompilation(); got != tt.want {
+				t.Errorf("Artist.IsCompilation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtist_ReadSettings(t *testing.T) {
+	settings := files.NewReadSettings().WithMetadataReaders(3)
+	tests := map[string]struct {
+		a    *files.Artist
+		want *files.ReadSettings
+	}{
+		"default": {
+			a:    files.NewArtist("The Beatles", "Music/The Beatles"),
+			want: files.NewReadSettings(),
+		},
+		"specified": {
+			a:    files.NewArtist("The Beatles", "Music/The Beatles").WithReadSettings(settings),
+			want: settings,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.a.ReadSettings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Artist.ReadSettings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
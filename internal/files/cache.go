@@ -0,0 +1,409 @@
+package files
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+const (
+	MetadataCacheFileName = "metadata.cache"
+	// the ways in which the metadata cache may be used
+	CacheUse     = "use"     // reuse entries for unchanged files, and save new ones
+	CacheBypass  = "bypass"  // neither read nor write the cache
+	CacheRebuild = "rebuild" // discard the cache, and save a new one
+	// incremented whenever the content of a cache entry changes, so that a cache
+	// written by an older version is discarded
+	metadataCacheVersion = 2
+)
+
+// CacheModes returns the ways in which the metadata cache may be used
+func CacheModes() []string {
+	return []string{CacheUse, CacheBypass, CacheRebuild}
+}
+
+var (
+	cacheLock = &sync.Mutex{}
+	// the mode in which the cache was loaded; empty until the cache is first used
+	cacheMode  = ""
+	cacheDirty = false
+	cache      = map[string]*cacheEntry{}
+	// fileIdentity is set by the platform-specific code; tests may override it
+	fileIdentity = platformFileIdentity
+)
+
+// metadataCacheFile is the content of the metadata cache file
+type metadataCacheFile struct {
+	Version int
+	Entries map[string]*cacheEntry
+}
+
+// fileStamp identifies a particular version of a file; when any part changes,
+// the file is read again
+type fileStamp struct {
+	Size    int64
+	ModTime int64
+	ID      string
+}
+
+// cacheEntry holds what was learned from reading a track file; the parts are
+// filled in as they are needed, as not every command needs every part
+type cacheEntry struct {
+	Stamp        fileStamp
+	Metadata     *cachedMetadata
+	Audio        *cachedAudioInfo
+	AudioError   string
+	Details      map[string]string
+	DetailsError string
+	HasDetails   bool
+	Problems     []string
+	HasProblems  bool
+}
+
+// cachedMetadata holds the values read from a track file's metadata sources
+type cachedMetadata struct {
+	AlbumArtistName   string
+	AlbumName         []string
+	ArtistName        []string
+	Compilation       bool
+	DiscNumber        int
+	ErrorCause        []string
+	Genre             []string
+	ID3V2Version      int
+	MusicCDIdentifier []byte
+	TrackName         []string
+	TrackNumber       []int
+	Year              []string
+}
+
+// cachedAudioInfo holds a description of a track file's MPEG audio stream
+type cachedAudioInfo struct {
+	Bitrate     int
+	BitrateMode string
+	ChannelMode string
+	Duration    time.Duration
+	Encoder     string
+	Format      string
+	Frames      int
+	SampleRate  int
+	VbrHeader   string
+	HeaderBytes int
+}
+
+func metadataCachePath() string {
+	return filepath.Join(cmd_toolkit.ApplicationPath(), MetadataCacheFileName)
+}
+
+// loadMetadataCache prepares the cache the first time it is used in a mode: in
+// the "use" mode, the cache file is read, and a missing, unreadable, or outdated
+// cache file simply leaves the cache empty; in the "rebuild" mode, the cache
+// starts empty. The caller must hold cacheLock.
+func loadMetadataCache(mode string) {
+	if cacheMode == mode {
+		return
+	}
+	cacheMode = mode
+	cacheDirty = mode == CacheRebuild
+	cache = map[string]*cacheEntry{}
+	if mode != CacheUse {
+		return
+	}
+	f, err := os.Open(metadataCachePath())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	content := &metadataCacheFile{}
+	if err = gob.NewDecoder(f).Decode(content); err == nil &&
+		content.Version == metadataCacheVersion && content.Entries != nil {
+		cache = content.Entries
+	}
+}
+
+// SaveMetadataCache writes the metadata cache file, if anything in the cache has
+// changed since it was read; entries for files that no longer exist, as when
+// they have been moved or deleted, are dropped first
+func SaveMetadataCache(o output.Bus) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if !cacheDirty {
+		return
+	}
+	dropMissingFiles()
+	path := metadataCachePath()
+	if err := writeMetadataCache(path); err != nil {
+		o.Log(output.Error, "cannot write metadata cache", map[string]any{
+			"fileName": path,
+			"error":    err,
+		})
+		o.WriteCanonicalError("The metadata cache %q could not be written: %v", path, err)
+		return
+	}
+	cacheDirty = false
+	o.Log(output.Info, "metadata cache written", map[string]any{
+		"fileName": path,
+		"entries":  len(cache),
+	})
+}
+
+// dropMissingFiles removes the entries for files that no longer exist; a file
+// that cannot be examined for any other reason, such as an unreachable network
+// share, keeps its entry. The caller must hold cacheLock.
+func dropMissingFiles() {
+	for path := range cache {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			delete(cache, path)
+		}
+	}
+}
+
+// writeMetadataCache writes the cache file; an existing cache file is replaced
+// through a temporary file, so that a failed write leaves it as it was
+func writeMetadataCache(path string) error {
+	var content bytes.Buffer
+	if err := gob.NewEncoder(&content).Encode(&metadataCacheFile{
+		Version: metadataCacheVersion,
+		Entries: cache,
+	}); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(path, content.Bytes(), cmd_toolkit.StdFilePermissions)
+	}
+	return rewriteFile(path, content.Bytes())
+}
+
+// ForgetCachedFile removes what the cache knows about a file, as when the file
+// has been rewritten or moved
+func ForgetCachedFile(path string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if _, found := cache[path]; found {
+		delete(cache, path)
+		cacheDirty = true
+	}
+}
+
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	id, err := fileIdentity(path, info)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{Size: info.Size(), ModTime: info.ModTime().UnixNano(), ID: id}, true
+}
+
+// cachedEntry returns the cache entry for a file, if the settings use the cache
+// and the file has not changed since the entry was made, along with the file's
+// current stamp; cacheable is false if the cache is not in use or the file
+// cannot be stamped
+func cachedEntry(path string, settings *ReadSettings) (entry *cacheEntry, stamp fileStamp,
+	cacheable bool) {
+	if !metadataCacheInUse(settings.MetadataCacheMode()) {
+		return
+	}
+	if stamp, cacheable = stampFile(path); !cacheable {
+		return
+	}
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if found, ok := cache[path]; ok {
+		if found.Stamp == stamp {
+			entry = found
+		} else {
+			delete(cache, path)
+			cacheDirty = true
+		}
+	}
+	return
+}
+
+func metadataCacheInUse(mode string) bool {
+	if mode == CacheBypass {
+		return false
+	}
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	loadMetadataCache(mode)
+	return true
+}
+
+// updateCachedEntry records new information about a file, which was stamped
+// before it was read
+func updateCachedEntry(path string, stamp fileStamp, update func(*cacheEntry)) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	entry, found := cache[path]
+	if !found || entry.Stamp != stamp {
+		entry = &cacheEntry{Stamp: stamp}
+		cache[path] = entry
+	}
+	update(entry)
+	cacheDirty = true
+}
+
+// readCachedMetadata returns the track file's metadata, read from the cache if
+// possible, and otherwise from the file
+func readCachedMetadata(path string, settings *ReadSettings) *TrackMetadata {
+	entry, stamp, cacheable := cachedEntry(path, settings)
+	if entry != nil && entry.Metadata != nil {
+		return entry.Metadata.restore(settings)
+	}
+	tM := ReadRawMetadata(path, settings)
+	if cacheable {
+		cached := newCachedMetadata(tM)
+		updateCachedEntry(path, stamp, func(entry *cacheEntry) {
+			entry.Metadata = cached
+		})
+	}
+	return tM
+}
+
+// readCachedAudioInfo returns a description of the track file's MPEG audio
+// stream, read from the cache if possible, and otherwise from the file
+func readCachedAudioInfo(path string, settings *ReadSettings) (*AudioInfo, error) {
+	entry, stamp, cacheable := cachedEntry(path, settings)
+	if entry != nil {
+		switch {
+		case entry.Audio != nil:
+			return entry.Audio.restore(), nil
+		case entry.AudioError != "":
+			return nil, errors.New(entry.AudioError)
+		}
+	}
+	ai, err := ReadAudioInfo(path)
+	if cacheable {
+		updateCachedEntry(path, stamp, func(entry *cacheEntry) {
+			if err != nil {
+				entry.AudioError = err.Error()
+			} else {
+				entry.Audio = newCachedAudioInfo(ai)
+			}
+		})
+	}
+	return ai, err
+}
+
+// readCachedDetails returns the track file's details, read from the cache if
+// possible, and otherwise obtained by calling read
+func readCachedDetails(path string, settings *ReadSettings,
+	read func() (map[string]string, error)) (map[string]string, error) {
+	entry, stamp, cacheable := cachedEntry(path, settings)
+	if entry != nil && entry.HasDetails {
+		if entry.DetailsError != "" {
+			return nil, errors.New(entry.DetailsError)
+		}
+		return maps.Clone(entry.Details), nil
+	}
+	details, err := read()
+	if cacheable {
+		updateCachedEntry(path, stamp, func(entry *cacheEntry) {
+			entry.HasDetails = true
+			if err != nil {
+				entry.DetailsError = err.Error()
+			} else {
+				entry.Details = maps.Clone(details)
+			}
+		})
+	}
+	return details, err
+}
+
+// readCachedAudioProblems returns the problems found in the track file's MPEG
+// audio frames, read from the cache if possible, and otherwise obtained by
+// calling check
+func readCachedAudioProblems(path string, settings *ReadSettings,
+	check func() []string) []string {
+	entry, stamp, cacheable := cachedEntry(path, settings)
+	if entry != nil && entry.HasProblems {
+		return slices.Clone(entry.Problems)
+	}
+	problems := check()
+	if cacheable {
+		updateCachedEntry(path, stamp, func(entry *cacheEntry) {
+			entry.HasProblems = true
+			entry.Problems = slices.Clone(problems)
+		})
+	}
+	return problems
+}
+
+func newCachedMetadata(tM *TrackMetadata) *cachedMetadata {
+	return &cachedMetadata{
+		AlbumArtistName:   tM.albumArtistName,
+		AlbumName:         slices.Clone(tM.albumName),
+		ArtistName:        slices.Clone(tM.artistName),
+		Compilation:       tM.compilation,
+		DiscNumber:        tM.discNumber,
+		ErrorCause:        slices.Clone(tM.errorCause),
+		Genre:             slices.Clone(tM.genre),
+		ID3V2Version:      tM.id3v2Version,
+		MusicCDIdentifier: slices.Clone(tM.musicCDIdentifier.Body),
+		TrackName:         slices.Clone(tM.trackName),
+		TrackNumber:       slices.Clone(tM.trackNumber),
+		Year:              slices.Clone(tM.year),
+	}
+}
+
+// restore recreates the metadata that was cached; the primary source is chosen
+// anew, as the metadata source priority may have changed
+func (cm *cachedMetadata) restore(settings *ReadSettings) *TrackMetadata {
+	tM := NewTrackMetadata()
+	tM.albumArtistName = cm.AlbumArtistName
+	copy(tM.albumName, cm.AlbumName)
+	copy(tM.artistName, cm.ArtistName)
+	tM.compilation = cm.Compilation
+	tM.discNumber = cm.DiscNumber
+	copy(tM.errorCause, cm.ErrorCause)
+	copy(tM.genre, cm.Genre)
+	tM.id3v2Version = cm.ID3V2Version
+	tM.musicCDIdentifier.Body = slices.Clone(cm.MusicCDIdentifier)
+	copy(tM.trackName, cm.TrackName)
+	copy(tM.trackNumber, cm.TrackNumber)
+	copy(tM.year, cm.Year)
+	tM.choosePrimarySource(settings)
+	return tM
+}
+
+func newCachedAudioInfo(ai *AudioInfo) *cachedAudioInfo {
+	return &cachedAudioInfo{
+		Bitrate:     ai.bitrate,
+		BitrateMode: ai.bitrateMode,
+		ChannelMode: ai.channelMode,
+		Duration:    ai.duration,
+		Encoder:     ai.encoder,
+		Format:      ai.format,
+		Frames:      ai.frames,
+		SampleRate:  ai.sampleRate,
+		VbrHeader:   ai.vbrHeader,
+		HeaderBytes: ai.headerBytes,
+	}
+}
+
+func (ca *cachedAudioInfo) restore() *AudioInfo {
+	return &AudioInfo{
+		bitrate:     ca.Bitrate,
+		bitrateMode: ca.BitrateMode,
+		channelMode: ca.ChannelMode,
+		duration:    ca.Duration,
+		encoder:     ca.Encoder,
+		format:      ca.Format,
+		frames:      ca.Frames,
+		sampleRate:  ca.SampleRate,
+		vbrHeader:   ca.VbrHeader,
+		headerBytes: ca.HeaderBytes,
+	}
+}
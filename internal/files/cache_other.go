@@ -0,0 +1,18 @@
+//go:build !windows
+
+package files
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// platformFileIdentity identifies a file by its device and inode numbers
+func platformFileIdentity(_ string, info os.FileInfo) (string, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("the file %q has no inode", info.Name())
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino), nil
+}
@@ -0,0 +1,227 @@
+package files_test
+
+import (
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+	"github.com/majohn-r/output"
+)
+
+// cachedTrack creates a track whose artist reads it with the specified metadata
+// cache mode
+func cachedTrack(path, mode string) *files.Track {
+	return files.NewEmptyTrack().WithFullPath(path).WithAlbum(
+		files.NewEmptyAlbum().WithArtist(files.NewEmptyArtist().WithReadSettings(
+			files.NewReadSettings().WithMetadataCacheMode(mode))))
+}
+
+// loadTrackMetadata reads a track file's metadata
+func loadTrackMetadata(path, mode string) *files.TrackMetadata {
+	track := cachedTrack(path, mode)
+	_ = track.LoadMetadata()
+	return track.GetMetadata()
+}
+
+func TestMetadataCache(t *testing.T) {
+	const fnName = "metadata cache"
+	testDir := "metadataCache"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	originalPath := cmd_toolkit.ApplicationPath()
+	defer func() {
+		cmd_toolkit.SetApplicationPath(originalPath)
+		destroyDirectory(fnName, testDir)
+	}()
+	cmd_toolkit.SetApplicationPath(testDir)
+	trackPath := filepath.Join(testDir, "01 track.mp3")
+	cachePath := filepath.Join(testDir, files.MetadataCacheFileName)
+	audio := make([]byte, 256)
+	tagged := func(album string) []byte {
+		return createConsistentlyTaggedData(audio, map[string]any{
+			"artist": "artist",
+			"album":  album,
+			"title":  "track",
+			"genre":  "Rock",
+			"year":   "1999",
+			"track":  1,
+		})
+	}
+	// rewrite the track file with a different album name of the same length; when
+	// its modification time is kept, only the cache can tell the versions apart
+	var modTime time.Time
+	rewrite := func(album string, keepModTime bool) {
+		if err := os.WriteFile(trackPath, tagged(album), cmd_toolkit.StdFilePermissions); err != nil {
+			t.Fatalf("%s error writing %q: %v", fnName, trackPath, err)
+		}
+		if keepModTime {
+			_ = os.Chtimes(trackPath, modTime, modTime)
+		}
+		info, _ := os.Stat(trackPath)
+		modTime = info.ModTime()
+	}
+	rewrite("album A", false)
+	savedLog := "level='info'" +
+		" entries='1'" +
+		" fileName='" + cachePath + "'" +
+		" msg='metadata cache written'\n"
+	steps := []struct {
+		name      string
+		mode      string
+		album     string
+		newTime   bool
+		forget    bool
+		wantAlbum string
+		wantLog   string
+	}{
+		{name: "bypass", mode: files.CacheBypass, wantAlbum: "album A"},
+		{name: "first use", mode: files.CacheUse, wantAlbum: "album A", wantLog: savedLog},
+		{name: "unchanged stamp", mode: files.CacheUse, album: "album B", wantAlbum: "album A"},
+		{
+			name:      "forgotten",
+			mode:      files.CacheUse,
+			forget:    true,
+			wantAlbum: "album B",
+			wantLog:   savedLog,
+		},
+		{
+			name:      "rebuild",
+			mode:      files.CacheRebuild,
+			album:     "album C",
+			wantAlbum: "album C",
+			wantLog:   savedLog,
+		},
+		{
+			name:      "changed stamp",
+			mode:      files.CacheUse,
+			album:     "album D",
+			newTime:   true,
+			wantAlbum: "album D",
+			wantLog:   savedLog,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.album != "" {
+				if step.newTime {
+					modTime = modTime.Add(time.Second)
+				}
+				rewrite(step.album, true)
+			}
+			if step.forget {
+				loadTrackMetadata(trackPath, step.mode)
+				files.ForgetCachedFile(trackPath)
+			}
+			if got := loadTrackMetadata(trackPath, step.mode).CanonicalAlbum(); got !=
+				step.wantAlbum {
+				t.Errorf("%s album = %q, want %q", fnName, got, step.wantAlbum)
+			}
+			o := output.NewRecorder()
+			files.SaveMetadataCache(o)
+			if got := o.LogOutput(); got != step.wantLog {
+				t.Errorf("%s log = %q, want %q", fnName, got, step.wantLog)
+			}
+		})
+	}
+}
+
+func TestMetadataCacheAudio(t *testing.T) {
+	const fnName = "metadata cache"
+	testDir := "metadataCacheAudio"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	originalPath := cmd_toolkit.ApplicationPath()
+	defer func() {
+		cmd_toolkit.SetApplicationPath(originalPath)
+		destroyDirectory(fnName, testDir)
+	}()
+	cmd_toolkit.SetApplicationPath(testDir)
+	name := "01 track.mp3"
+	if err := createFileWithContent(testDir, name, createMpegFrame(128, nil)); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, name, err)
+	}
+	path := filepath.Join(testDir, name)
+	want, wantErr := cachedTrack(path, files.CacheRebuild).AudioInfo()
+	wantProblems := cachedTrack(path, files.CacheRebuild).ReportAudioProblems()
+	o := output.NewRecorder()
+	files.SaveMetadataCache(o)
+	// replace the audio with something unreadable, keeping the stamp, so that the
+	// results can only have come from the cache
+	info, _ := os.Stat(path)
+	_ = os.WriteFile(path, make([]byte, info.Size()), cmd_toolkit.StdFilePermissions)
+	_ = os.Chtimes(path, info.ModTime(), info.ModTime())
+	// changing the mode reads the cache file again
+	got, gotErr := cachedTrack(path, files.CacheUse).AudioInfo()
+	if (gotErr != nil) != (wantErr != nil) {
+		t.Fatalf("%s AudioInfo() error = %v, want %v", fnName, gotErr, wantErr)
+	}
+	if gotErr == nil && (got.Bitrate() != want.Bitrate() || got.Frames() != want.Frames() ||
+		got.Duration() != want.Duration()) {
+		t.Errorf("%s AudioInfo() = %v, want %v", fnName, got, want)
+	}
+	gotProblems := cachedTrack(path, files.CacheUse).ReportAudioProblems()
+	if len(gotProblems) != len(wantProblems) {
+		t.Errorf("%s ReportAudioProblems() = %v, want %v", fnName, gotProblems,
+			wantProblems)
+	}
+	if _, err := cachedTrack(path, files.CacheBypass).AudioInfo(); err == nil {
+		t.Errorf("%s AudioInfo() without the cache should fail", fnName)
+	}
+}
+
+func TestSaveMetadataCache(t *testing.T) {
+	const fnName = "SaveMetadataCache()"
+	testDir := "saveMetadataCache"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	originalPath := cmd_toolkit.ApplicationPath()
+	defer func() {
+		cmd_toolkit.SetApplicationPath(originalPath)
+		destroyDirectory(fnName, testDir)
+	}()
+	cmd_toolkit.SetApplicationPath(testDir)
+	cachePath := filepath.Join(testDir, files.MetadataCacheFileName)
+	kept := filepath.Join(testDir, "01 kept.mp3")
+	moved := filepath.Join(testDir, "02 moved.mp3")
+	for _, path := range []string{kept, moved} {
+		if err := os.WriteFile(path, createMpegFrame(128, nil),
+			cmd_toolkit.StdFilePermissions); err != nil {
+			t.Fatalf("%s error writing %q: %v", fnName, path, err)
+		}
+		loadTrackMetadata(path, files.CacheRebuild)
+	}
+	// a track file that has gone away is dropped from the cache
+	_ = os.Remove(moved)
+	o := output.NewRecorder()
+	files.SaveMetadataCache(o)
+	wantLog := "level='info'" +
+		" entries='1'" +
+		" fileName='" + cachePath + "'" +
+		" msg='metadata cache written'\n"
+	if got := o.LogOutput(); got != wantLog {
+		t.Errorf("%s log = %q, want %q", fnName, got, wantLog)
+	}
+	saved, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("%s error reading %q: %v", fnName, cachePath, err)
+	}
+	// a failed write leaves the existing cache file as it was
+	if err = cmd_toolkit.Mkdir(cachePath + "-rewrite"); err != nil {
+		t.Fatalf("%s error creating %q: %v", fnName, cachePath+"-rewrite", err)
+	}
+	files.ForgetCachedFile(kept)
+	o = output.NewRecorder()
+	files.SaveMetadataCache(o)
+	if got := o.ErrorOutput(); got == "" {
+		t.Errorf("%s reported no error", fnName)
+	}
+	if got, _ := os.ReadFile(cachePath); string(got) != string(saved) {
+		t.Errorf("%s changed the cache file after failing to write it", fnName)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package files
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// platformFileIdentity identifies a file by its volume serial number and file
+// index, which can only be obtained from an open file
+func platformFileIdentity(path string, _ os.FileInfo) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var data windows.ByHandleFileInformation
+	if err = windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &data); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x:%x:%x", data.VolumeSerialNumber, data.FileIndexHigh,
+		data.FileIndexLow), nil
+}
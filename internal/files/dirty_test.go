@@ -4,6 +4,7 @@ import (
 	"mp3/internal/files"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
@@ -42,7 +43,7 @@ func TestMarkDirty(t *testing.T) {
 			WantedRecording: output.WantedRecording{
 				Log: "" +
 					"level='info'" +
-					" fileName='empty\\metadata.dirty'" +
+					" fileName='" + filepath.Join("empty", files.DirtyFileName) + "'" +
 					" msg='metadata dirty file written'\n",
 			},
 		},
@@ -138,14 +139,20 @@ func TestClearDirty(t *testing.T) {
 			WantedRecording: output.WantedRecording{
 				Log: "" +
 					"level='info'" +
-					" fileName='clearDirty\\metadata.dirty'" +
+					" fileName='" + filepath.Join("clearDirty", files.DirtyFileName) + "'" +
 					" msg='metadata dirty file deleted'\n",
 			},
 		},
 		"nothing to remove": {
 			initialDirtyFolder: ".",
 		},
-		"unremovable file": {
+	}
+	if runtime.GOOS == "windows" {
+		// only Windows refuses to delete a file that is open for reading
+		tests["unremovable file"] = struct {
+			initialDirtyFolder string
+			output.WantedRecording
+		}{
 			initialDirtyFolder: uncleanable,
 			WantedRecording: output.WantedRecording{
 				Error: "The file \"clearDirty2\\\\metadata.dirty\" cannot be deleted:" +
@@ -158,7 +165,7 @@ func TestClearDirty(t *testing.T) {
 					" fileName='clearDirty2\\metadata.dirty'" +
 					" msg='cannot delete file'\n",
 			},
-		},
+		}
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
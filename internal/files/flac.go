@@ -0,0 +1,297 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// values per https://xiph.org/flac/format.html
+const (
+	flacMarker             = "fLaC"
+	flacBlockHeaderSize    = 4
+	flacLastBlockFlag      = byte(0x80)
+	flacBlockTypeMask      = byte(0x7f)
+	flacStreamInfoBlock    = byte(0)
+	flacPaddingBlock       = byte(1)
+	flacVorbisCommentBlock = byte(4)
+	flacMaximumBlockSize   = 1<<24 - 1
+	flacStreamInfoSize     = 34
+)
+
+var (
+	// ErrNoFlacVorbisComments is the error returned when a FLAC file has no
+	// Vorbis comment block
+	ErrNoFlacVorbisComments = fmt.Errorf("no FLAC vorbis comment block found")
+	flacBlockNames          = map[byte]string{
+		0: "STREAMINFO",
+		1: "PADDING",
+		2: "APPLICATION",
+		3: "SEEKTABLE",
+		4: "VORBIS_COMMENT",
+		5: "CUESHEET",
+		6: "PICTURE",
+	}
+)
+
+type flacBlock struct {
+	blockType byte
+	data      []byte
+}
+
+// flacFile holds the metadata blocks of a FLAC file, and the offset of the
+// audio frames that follow them
+type flacFile struct {
+	blocks     []*flacBlock
+	audioStart int64
+}
+
+func readFlacFile(path string) (*flacFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	marker := make([]byte, len(flacMarker))
+	if _, err = io.ReadFull(file, marker); err != nil || string(marker) != flacMarker {
+		return nil, fmt.Errorf("file %q is not a FLAC file", path)
+	}
+	ff := &flacFile{audioStart: int64(len(flacMarker))}
+	for {
+		header := make([]byte, flacBlockHeaderSize)
+		if _, err = io.ReadFull(file, header); err != nil {
+			return nil, fmt.Errorf("FLAC metadata in file %q is truncated", path)
+		}
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		block := &flacBlock{blockType: header[0] & flacBlockTypeMask, data: make([]byte, size)}
+		if _, err = io.ReadFull(file, block.data); err != nil {
+			return nil, fmt.Errorf("FLAC metadata in file %q is truncated", path)
+		}
+		ff.blocks = append(ff.blocks, block)
+		ff.audioStart += int64(flacBlockHeaderSize + size)
+		if header[0]&flacLastBlockFlag != 0 {
+			return ff, nil
+		}
+	}
+}
+
+func (ff *flacFile) vorbisComments() (*VorbisComments, error) {
+	for _, block := range ff.blocks {
+		if block.blockType == flacVorbisCommentBlock {
+			return parseVorbisComments(block.data)
+		}
+	}
+	return nil, ErrNoFlacVorbisComments
+}
+
+// setVorbisComments replaces the Vorbis comment block; when possible, the
+// padding block grows or shrinks to compensate, so that the audio frames do not
+// move
+func (ff *flacFile) setVorbisComments(vc *VorbisComments) error {
+	data := vc.Bytes()
+	if len(data) > flacMaximumBlockSize {
+		return fmt.Errorf("vorbis comments are too large for a FLAC file: %d bytes", len(data))
+	}
+	delta := len(data)
+	found := false
+	for _, block := range ff.blocks {
+		if block.blockType == flacVorbisCommentBlock {
+			delta -= len(block.data)
+			block.data = data
+			found = true
+			break
+		}
+	}
+	if !found {
+		delta += flacBlockHeaderSize
+		// the STREAMINFO block must remain first
+		ff.blocks = append(ff.blocks[:1], append([]*flacBlock{
+			{blockType: flacVorbisCommentBlock, data: data}}, ff.blocks[1:]...)...)
+	}
+	for _, block := range ff.blocks {
+		if block.blockType == flacPaddingBlock && len(block.data) >= delta {
+			block.data = make([]byte, len(block.data)-delta)
+			break
+		}
+	}
+	return nil
+}
+
+// Bytes returns the marker and the metadata blocks
+func (ff *flacFile) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(flacMarker)
+	for k, block := range ff.blocks {
+		header := block.blockType
+		if k == len(ff.blocks)-1 {
+			header |= flacLastBlockFlag
+		}
+		size := len(block.data)
+		b.Write([]byte{header, byte(size >> 16), byte(size >> 8), byte(size)})
+		b.Write(block.data)
+	}
+	return b.Bytes()
+}
+
+func (ff *flacFile) write(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if ff.audioStart > int64(len(content)) {
+		return fmt.Errorf("FLAC metadata in file %q has changed", path)
+	}
+	return rewriteFile(path, append(ff.Bytes(), content[ff.audioStart:]...))
+}
+
+// streamInfo describes the STREAMINFO block, whose layout is fixed
+func (ff *flacFile) streamInfo() string {
+	if len(ff.blocks) == 0 || ff.blocks[0].blockType != flacStreamInfoBlock ||
+		len(ff.blocks[0].data) < flacStreamInfoSize {
+		return "missing"
+	}
+	b := ff.blocks[0].data
+	sampleRate := int(b[10])<<12 | int(b[11])<<4 | int(b[12])>>4
+	channels := int(b[12]>>1&0x7) + 1
+	bitsPerSample := int(b[12]&0x1)<<4 | int(b[13])>>4 + 1
+	samples := int64(b[13]&0xf)<<32 | int64(b[14])<<24 | int64(b[15])<<16 |
+		int64(b[16])<<8 | int64(b[17])
+	return fmt.Sprintf("%d Hz, %d channels, %d bits per sample, %d samples", sampleRate,
+		channels, bitsPerSample, samples)
+}
+
+func RawReadFlacMetadata(path string) *VorbisMetadata {
+	ff, err := readFlacFile(path)
+	if err != nil {
+		return &VorbisMetadata{err: err}
+	}
+	vc, err := ff.vorbisComments()
+	if err != nil {
+		return &VorbisMetadata{err: err}
+	}
+	return newVorbisMetadata(vc)
+}
+
+// ReadFlacMetadata returns a description of the file's stream, its metadata
+// blocks, and its Vorbis comments
+func ReadFlacMetadata(path string) ([]string, error) {
+	ff, err := readFlacFile(path)
+	if err != nil {
+		return nil, err
+	}
+	output := []string{fmt.Sprintf("Stream: %s", ff.streamInfo())}
+	for _, block := range ff.blocks {
+		name, ok := flacBlockNames[block.blockType]
+		if !ok {
+			name = fmt.Sprintf("type %d", block.blockType)
+		}
+		output = append(output, fmt.Sprintf("Block: %s (%d bytes)", name, len(block.data)))
+	}
+	if vc, err := ff.vorbisComments(); err == nil {
+		output = append(output, vc.Diagnostics()...)
+	}
+	return output, nil
+}
+
+// ReadFlacDetails returns the known details found in the file's Vorbis comments
+func ReadFlacDetails(path string) (map[string]string, error) {
+	ff, err := readFlacFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vc, err := ff.vorbisComments()
+	if err != nil {
+		return nil, err
+	}
+	return vc.details(), nil
+}
+
+func updateFlacMetadata(tM *TrackMetadata, path string, sT SourceType) error {
+	if !tM.requiresEdit[sT] {
+		return nil
+	}
+	ff, err := readFlacFile(path)
+	if err != nil {
+		return err
+	}
+	vc, err := ff.vorbisComments()
+	if err != nil {
+		return err
+	}
+	vc.applyCorrections(tM, sT)
+	if err = ff.setVorbisComments(vc); err != nil {
+		return err
+	}
+	return ff.write(path)
+}
+
+// flacSource is the MetadataSource for the Vorbis comments in FLAC files
+type flacSource struct{}
+
+func init() {
+	registerMetadataSource(flacSource{})
+}
+
+func (flacSource) Type() SourceType {
+	return FLAC
+}
+
+func (flacSource) Name() string {
+	return "FLAC"
+}
+
+func (flacSource) Extensions() []string {
+	return []string{".flac"}
+}
+
+func (flacSource) Read(path string, tM *TrackMetadata) error {
+	vm := RawReadFlacMetadata(path)
+	if vm.err == nil {
+		tM.SetFlacValues(vm)
+	}
+	return vm.err
+}
+
+func (flacSource) NameDiffers(cS *ComparableStrings) bool {
+	return VorbisNameDiffers(cS)
+}
+
+func (flacSource) GenreDiffers(cS *ComparableStrings) bool {
+	return VorbisGenreDiffers(cS)
+}
+
+func (flacSource) Write(tM *TrackMetadata, path string) error {
+	return updateFlacMetadata(tM, path, FLAC)
+}
+
+func (flacSource) Diagnostics(path string) ([]string, error) {
+	return ReadFlacMetadata(path)
+}
+
+func (flacSource) Details(path string) (map[string]string, error) {
+	return ReadFlacDetails(path)
+}
+
+// WriteAudio writes the audio frames that follow the metadata blocks
+func (flacSource) WriteAudio(path string, w io.Writer) error {
+	ff, err := readFlacFile(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.Seek(ff.audioStart, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// a FLAC file is expected to have Vorbis comments
+func (flacSource) MissingError() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package files_test
+
+import (
+	"encoding/binary"
+	"mp3/internal/files"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+// createFlacData creates the content of a FLAC file: a STREAMINFO block
+// describing 16-bit stereo at 44100 Hz, a VORBIS_COMMENT block containing the
+// specified comments (omitted if comments is nil), a PADDING block of the
+// specified size, and the audio
+func createFlacData(comments []string, padding int, audio []byte) []byte {
+	block := func(blockType byte, data []byte) []byte {
+		size := len(data)
+		return append([]byte{blockType, byte(size >> 16), byte(size >> 8), byte(size)},
+			data...)
+	}
+	streamInfo := make([]byte, 34)
+	copy(streamInfo[10:], []byte{0x0a, 0xc4, 0x42, 0xf0})
+	content := append([]byte("fLaC"), block(0, streamInfo)...)
+	if comments != nil {
+		vendor := "reference libFLAC 1.4.3"
+		data := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+		data = append(data, vendor...)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(comments)))
+		for _, comment := range comments {
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(comment)))
+			data = append(data, comment...)
+		}
+		content = append(content, block(4, data)...)
+	}
+	content = append(content, block(0x80|1, make([]byte, padding))...)
+	return append(content, audio...)
+}
+
+func TestRawReadFlacMetadata(t *testing.T) {
+	const fnName = "RawReadFlacMetadata()"
+	testDir := "rawReadFlac"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	comments := []string{
+		"ALBUM=unknown album",
+		"artist=unknown artist",
+		"Genre=dance music",
+		"TITLE=unknown track",
+		"TRACKNUMBER=2/12",
+		"DATE=2022",
+	}
+	audio := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	good := createFlacData(comments, 16, audio)
+	fileContents := map[string][]byte{
+		"good.flac":       good,
+		"notFlac.flac":    audio,
+		"truncated.flac":  good[:50],
+		"noComments.flac": createFlacData(nil, 16, audio),
+		"badTrack.flac":   createFlacData([]string{"TRACKNUMBER=two"}, 16, audio),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		path      string
+		want      *files.VorbisMetadata
+		wantError bool
+	}{
+		"no file":   {path: filepath.Join(testDir, "no such file"), wantError: true},
+		"not FLAC":  {path: filepath.Join(testDir, "notFlac.flac"), wantError: true},
+		"truncated": {path: filepath.Join(testDir, "truncated.flac"), wantError: true},
+		"no comments": {
+			path: filepath.Join(testDir, "noComments.flac"),
+			want: files.NewVorbisMetadata().WithErr(files.ErrNoFlacVorbisComments),
+		},
+		"bad track": {path: filepath.Join(testDir, "badTrack.flac"), wantError: true},
+		"good": {
+			path: filepath.Join(testDir, "good.flac"),
+			want: files.NewVorbisMetadata().WithAlbumName("unknown album").WithArtistName(
+				"unknown artist").WithGenre("dance music").WithTrackName(
+				"unknown track").WithTrackNumber(2).WithYear("2022"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := files.RawReadFlacMetadata(tt.path)
+			if tt.wantError {
+				if !got.HasError() {
+					t.Errorf("%s = %#v, want error", fnName, got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFlacMetadata(t *testing.T) {
+	const fnName = "ReadFlacMetadata()"
+	testDir := "readFlac"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	content := createFlacData([]string{"TITLE=my track", "ARTIST=my artist"}, 8, []byte{0})
+	if err := createFileWithContent(testDir, "tagged.flac", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.flac", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.flac"),
+			want: []string{
+				"Stream: 44100 Hz, 2 channels, 16 bits per sample, 0 samples",
+				"Block: STREAMINFO (34 bytes)",
+				"Block: VORBIS_COMMENT (69 bytes)",
+				"Block: PADDING (8 bytes)",
+				`vendor = "reference libFLAC 1.4.3"`,
+				`TITLE = "my track"`,
+				`ARTIST = "my artist"`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadFlacMetadata(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFlacDetails(t *testing.T) {
+	const fnName = "ReadFlacDetails()"
+	testDir := "readFlacDetails"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	content := createFlacData([]string{
+		"TITLE=my track",
+		"composer=a couple of idiots",
+		"CONDUCTOR=Someone with a stick",
+	}, 8, []byte{0})
+	if err := createFileWithContent(testDir, "tagged.flac", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.flac", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    map[string]string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.flac"),
+			want: map[string]string{
+				"Composer":  "a couple of idiots",
+				"Conductor": "Someone with a stick",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadFlacDetails(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
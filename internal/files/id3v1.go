@@ -2,6 +2,7 @@ package files
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -41,6 +42,8 @@ const (
 	genreLength = 1
 	// total length of the ID3V1 block
 	id3v1Length = genreOffset + genreLength
+	// genre index that does not identify any genre
+	noGenre = 255
 )
 
 type id3v1Field struct {
@@ -50,6 +53,7 @@ type id3v1Field struct {
 }
 
 var (
+	errNoID3v1Metadata = errors.New("no id3v1 metadata found")
 	// per https://en.wikipedia.org/wiki/List_of_ID3v1_Genres as of August 16 2022
 	GenreMap = map[int]string{
 		0: "Blues",
@@ -681,7 +685,7 @@ func InternalReadID3V1Metadata(path string,
 	if v1.IsValid() {
 		return v1, nil
 	}
-	return nil, fmt.Errorf("no id3v1 metadata found in file %q", path)
+	return nil, fmt.Errorf("%w in file %q", errNoID3v1Metadata, path)
 }
 
 func (im *Id3v1Metadata) Write(path string) error {
@@ -726,6 +730,29 @@ func updateID3V1Metadata(tM *TrackMetadata, path string, sT SourceType) (err err
 	return
 }
 
+// createID3V1Metadata appends a new ID3V1 tag, holding the corrected values
+// recorded in tM, to the file
+func createID3V1Metadata(tM *TrackMetadata, path string, sT SourceType) (err error) {
+	v1 := NewID3v1Metadata()
+	v1.WriteString("TAG", TagField)
+	v1.SetTitle(tM.correctedTrackName[sT])
+	v1.SetArtist(tM.correctedArtistName[sT])
+	v1.SetAlbum(tM.correctedAlbumName[sT])
+	v1.SetYear(tM.correctedYear[sT])
+	_ = v1.SetTrack(tM.correctedTrackNumber[sT])
+	if genre := tM.correctedGenre[sT]; genre != "" {
+		v1.SetGenre(genre)
+	} else {
+		v1.writeInt(noGenre, genreField)
+	}
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0); err == nil {
+		defer f.Close()
+		_, err = WriteToFile(f, v1.data)
+	}
+	return
+}
+
 func (im *Id3v1Metadata) InternalWrite(path string,
 	writeFunc func(f *os.File, b []byte) (int, error)) (err error) {
 	var src *os.File
@@ -814,3 +841,61 @@ func Id3v1GenreDiffers(cS *ComparableStrings) bool {
 	// external name is a known id3v1 genre, or metadata name is not "Other"
 	return cS.External() != cS.Metadata()
 }
+
+// id3v1Source is the MetadataSource for ID3V1 tags
+type id3v1Source struct{}
+
+func init() {
+	registerMetadataSource(id3v1Source{})
+}
+
+func (id3v1Source) Type() SourceType {
+	return ID3V1
+}
+
+func (id3v1Source) Name() string {
+	return "ID3V1"
+}
+
+func (id3v1Source) Extensions() []string {
+	return nil
+}
+
+func (id3v1Source) Read(path string, tM *TrackMetadata) error {
+	v1, err := InternalReadID3V1Metadata(path, FileReader)
+	if err == nil {
+		tM.SetID3v1Values(v1)
+	}
+	return err
+}
+
+func (id3v1Source) NameDiffers(cS *ComparableStrings) bool {
+	return Id3v1NameDiffers(cS)
+}
+
+func (id3v1Source) GenreDiffers(cS *ComparableStrings) bool {
+	return Id3v1GenreDiffers(cS)
+}
+
+func (id3v1Source) Write(tM *TrackMetadata, path string) error {
+	return updateID3V1Metadata(tM, path, ID3V1)
+}
+
+// Absent returns true if the file does not end with an ID3V1 tag
+func (id3v1Source) Absent(path string) bool {
+	_, err := InternalReadID3V1Metadata(path, FileReader)
+	return errors.Is(err, errNoID3v1Metadata)
+}
+
+func (id3v1Source) Create(tM *TrackMetadata, path string) error {
+	return createID3V1Metadata(tM, path, ID3V1)
+}
+
+func (id3v1Source) Diagnostics(path string) ([]string, error) {
+	return ReadID3v1Metadata(path)
+}
+
+// an ID3V1 tag is expected to be present
+func (id3v1Source) MissingError() error {
+	return nil
+}
@@ -2,6 +2,8 @@ package files
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 
@@ -9,13 +11,17 @@ import (
 )
 
 type Id3v2Metadata struct {
+	albumArtistName   string
 	albumName         string
 	artistName        string
+	compilation       bool
+	discNumber        int
 	err               error
 	genre             string
 	musicCDIdentifier id3v2.UnknownFrame
 	trackName         string
 	trackNumber       int
+	version           byte
 	year              string
 }
 
@@ -23,6 +29,11 @@ func (im *Id3v2Metadata) HasError() bool {
 	return im.err != nil
 }
 
+func (im *Id3v2Metadata) WithAlbumArtistName(s string) *Id3v2Metadata {
+	im.albumArtistName = s
+	return im
+}
+
 func (im *Id3v2Metadata) WithAlbumName(s string) *Id3v2Metadata {
 	im.albumName = s
 	return im
@@ -53,11 +64,26 @@ func (im *Id3v2Metadata) WithTrackNumber(i int) *Id3v2Metadata {
 	return im
 }
 
+func (im *Id3v2Metadata) WithCompilation(b bool) *Id3v2Metadata {
+	im.compilation = b
+	return im
+}
+
+func (im *Id3v2Metadata) WithDiscNumber(i int) *Id3v2Metadata {
+	im.discNumber = i
+	return im
+}
+
 func (im *Id3v2Metadata) WithMusicCDIdentifier(b []byte) *Id3v2Metadata {
 	im.musicCDIdentifier = id3v2.UnknownFrame{Body: b}
 	return im
 }
 
+func (im *Id3v2Metadata) WithVersion(b byte) *Id3v2Metadata {
+	im.version = b
+	return im
+}
+
 func (im *Id3v2Metadata) WithErr(e error) *Id3v2Metadata {
 	im.err = e
 	return im
@@ -77,6 +103,7 @@ func RawReadID3V2Metadata(path string) (d *Id3v2Metadata) {
 		d.err = err
 	} else {
 		defer tag.Close()
+		d.version = tag.Version()
 		if trackNumber, err := ToTrackNumber(
 			tag.GetTextFrame(trackFrame).Text); err != nil {
 			d.err = err
@@ -87,6 +114,15 @@ func RawReadID3V2Metadata(path string) (d *Id3v2Metadata) {
 			d.trackName = RemoveLeadingBOMs(tag.Title())
 			d.trackNumber = trackNumber
 			d.year = RemoveLeadingBOMs(tag.Year())
+			// like TRCK, TPOS may be "1/2", meaning the first disc of two; unlike
+			// TRCK, it is usually absent
+			if disc, err := ToTrackNumber(tag.GetTextFrame(discFrame).Text); err == nil {
+				d.discNumber = disc
+			}
+			d.albumArtistName = RemoveLeadingBOMs(tag.GetTextFrame(albumArtistFrame).Text)
+			// TCMP is an iTunes extension, set to "1" for a track that is part of
+			// a compilation
+			d.compilation = tag.GetTextFrame(compilationFrame).Text == "1"
 			mcdiFramers := tag.AllFrames()[mcdiFrame]
 			d.musicCDIdentifier = SelectUnknownFrame(mcdiFramers)
 		}
@@ -214,6 +250,15 @@ func updateID3V2Metadata(tM *TrackMetadata, path string, sT SourceType) (e error
 			if year != "" {
 				tag.SetYear(year)
 			}
+			if disc := tM.correctedDiscNumber; disc != 0 {
+				tag.AddTextFrame(discFrame, tag.DefaultEncoding(), fmt.Sprintf("%d", disc))
+			}
+			if albumArtist := tM.correctedAlbumArtistName; albumArtist != "" {
+				tag.AddTextFrame(albumArtistFrame, tag.DefaultEncoding(), albumArtist)
+			}
+			if tM.correctedCompilation {
+				tag.AddTextFrame(compilationFrame, tag.DefaultEncoding(), "1")
+			}
 			mcdi := tM.correctedMusicCDIdentifier
 			if len(mcdi.Body) != 0 {
 				tag.DeleteFrames(mcdiFrame)
@@ -331,3 +376,92 @@ func Id3v2GenreDiffers(cS *ComparableStrings) bool {
 	// differs unless exact match. Period.
 	return cS.External() != cS.Metadata()
 }
+
+// id3v2Source is the MetadataSource for ID3V2 tags
+type id3v2Source struct{}
+
+func init() {
+	registerMetadataSource(id3v2Source{})
+}
+
+func (id3v2Source) Type() SourceType {
+	return ID3V2
+}
+
+func (id3v2Source) Name() string {
+	return "ID3V2"
+}
+
+func (id3v2Source) Extensions() []string {
+	return nil
+}
+
+func (id3v2Source) Read(path string, tM *TrackMetadata) error {
+	d := RawReadID3V2Metadata(path)
+	if d.err == nil {
+		tM.SetID3v2Values(d)
+	}
+	return d.err
+}
+
+func (id3v2Source) NameDiffers(cS *ComparableStrings) bool {
+	return Id3v2NameDiffers(cS)
+}
+
+func (id3v2Source) GenreDiffers(cS *ComparableStrings) bool {
+	return Id3v2GenreDiffers(cS)
+}
+
+func (id3v2Source) Write(tM *TrackMetadata, path string) error {
+	return updateID3V2Metadata(tM, path, ID3V2)
+}
+
+// Absent returns true if the file does not begin with an ID3V2 tag
+func (id3v2Source) Absent(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	identifier := make([]byte, 3)
+	_, err = io.ReadFull(f, identifier)
+	return err == nil && string(identifier) != "ID3"
+}
+
+// Create relies on the id3v2 library, which reads a file without a tag as
+// though it had an empty one, and prepends the tag when saving it
+func (id3v2Source) Create(tM *TrackMetadata, path string) error {
+	return updateID3V2Metadata(tM, path, ID3V2)
+}
+
+// Diagnostics returns the tag's version and encoding, followed by its frames
+func (id3v2Source) Diagnostics(path string) ([]string, error) {
+	version, encoding, frames, _, err := ReadID3V2Metadata(path)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := make([]string, 0, len(frames)+2)
+	diagnostics = append(diagnostics, fmt.Sprintf("Version: %v", version),
+		fmt.Sprintf("Encoding: %q", encoding))
+	return append(diagnostics, frames...), nil
+}
+
+// Details returns the values of the frames described in frameDescriptions
+func (id3v2Source) Details(path string) (map[string]string, error) {
+	_, _, _, frames, err := ReadID3V2Metadata(path)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{}
+	for _, frame := range frames {
+		if value, ok := frameDescriptions[frame.name]; ok {
+			m[value] = frame.value
+		}
+	}
+	return m, nil
+}
+
+// an ID3V2 tag is expected to be present
+func (id3v2Source) MissingError() error {
+	return nil
+}
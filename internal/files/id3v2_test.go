@@ -323,7 +323,7 @@ func Test_readID3V2Metadata(t *testing.T) {
 	}{
 		"error case": {args: args{path: "./no such file"}, wantErr: true},
 		"good case": {
-			args:        args{path: "./goodfile.mp3"},
+			args:        args{path: "./goodFile.mp3"},
 			wantEnc:     "ISO-8859-1",
 			wantVersion: 3,
 			wantF: []string{
@@ -0,0 +1,219 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// IgnoreFileName is the name of the files that list what is to be ignored in
+// the directory holding them, and in the directories below it
+const IgnoreFileName = ".mp3ignore"
+
+// IgnoreRule is one pattern from an ignore file or from the global ignore list.
+// The patterns follow the rules of .gitignore files: a pattern is matched
+// against the path relative to the directory holding the ignore file if it
+// contains a slash, other than a trailing one, and against the name of the file
+// or directory otherwise; a trailing slash matches only directories; a leading
+// '!' restores what an earlier pattern ignored; '*' matches any text other than
+// a slash, '?' any one character other than a slash, '**' any text including
+// slashes, and '[...]' any one of the enclosed characters.
+type IgnoreRule struct {
+	base     string
+	source   string
+	line     int
+	pattern  string
+	negated  bool
+	dirOnly  bool
+	anchored bool
+	regex    *regexp.Regexp
+}
+
+// Pattern returns the pattern as it was written
+func (r *IgnoreRule) Pattern() string {
+	return r.pattern
+}
+
+// Source returns the ignore file, or the setting, that the pattern came from
+func (r *IgnoreRule) Source() string {
+	return r.source
+}
+
+// Line returns the number of the line the pattern was written on
+func (r *IgnoreRule) Line() int {
+	return r.line
+}
+
+func (r *IgnoreRule) String() string {
+	return fmt.Sprintf("%q (line %d of %s)", r.pattern, r.line, r.source)
+}
+
+// IgnoreRules is an ordered list of ignore rules; when more than one rule
+// matches a path, the last one decides whether the path is ignored
+type IgnoreRules []*IgnoreRule
+
+// ParseIgnoreRules parses the content of an ignore file, or of the global ignore
+// list; base is the directory the patterns are relative to, and source names
+// where the content came from. Blank lines and lines beginning with '#' are
+// skipped. The patterns that can be parsed are returned, along with an error
+// for each one that cannot.
+func ParseIgnoreRules(content, base, source string) (IgnoreRules, []error) {
+	var rules IgnoreRules
+	var errs []error
+	for index, line := range strings.Split(content, "\n") {
+		line = trimIgnorePattern(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseIgnoreRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d of %s: %w", index+1, source, err))
+			continue
+		}
+		rule.base = base
+		rule.source = source
+		rule.line = index + 1
+		rules = append(rules, rule)
+	}
+	return rules, errs
+}
+
+// trimIgnorePattern removes trailing white space from a line, other than a space
+// escaped with a backslash
+func trimIgnorePattern(line string) string {
+	trimmed := strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(trimmed, `\`) && len(trimmed) < len(line) && line[len(trimmed)] == ' ' {
+		trimmed += " "
+	}
+	return trimmed
+}
+
+func parseIgnoreRule(pattern string) (*IgnoreRule, error) {
+	rule := &IgnoreRule{pattern: pattern}
+	body := pattern
+	if strings.HasPrefix(body, "!") {
+		rule.negated = true
+		body = body[1:]
+	}
+	if strings.HasSuffix(body, "/") {
+		rule.dirOnly = true
+		body = strings.TrimRight(body, "/")
+	}
+	if strings.Contains(body, "/") {
+		rule.anchored = true
+		body = strings.TrimPrefix(body, "/")
+	}
+	if body == "" {
+		return nil, fmt.Errorf("the pattern %q does not name anything", pattern)
+	}
+	expression, err := ignorePatternExpression(body)
+	if err != nil {
+		return nil, fmt.Errorf("the pattern %q %w", pattern, err)
+	}
+	if rule.regex, err = regexp.Compile(expression); err != nil {
+		return nil, fmt.Errorf("the pattern %q cannot be used: %v", pattern, err)
+	}
+	return rule, nil
+}
+
+// ignorePatternExpression translates the wildcards of an ignore pattern into a
+// regular expression that matches the whole of a slash-separated path
+func ignorePatternExpression(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '\\':
+			if i+1 == len(runes) {
+				return "", errors.New("ends with an unfinished escape")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' && (i == 0 || runes[i-1] == '/') {
+				switch {
+				case i+2 == len(runes):
+					b.WriteString(".*")
+					i++
+					continue
+				case runes[i+2] == '/':
+					b.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return "", errors.New("has a '[' without a matching ']'")
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, "[", `\[`) + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// matches reports whether the rule matches the path, which is a file, or, if
+// isDir is set, a directory
+func (r *IgnoreRule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	// a rule applies only below the directory holding its ignore file
+	relative, err := filepath.Rel(r.base, path)
+	if err != nil {
+		return false
+	}
+	relative = filepath.ToSlash(relative)
+	if relative == "." || relative == ".." || strings.HasPrefix(relative, "../") {
+		return false
+	}
+	if !r.anchored {
+		return r.regex.MatchString(filepath.Base(path))
+	}
+	return r.regex.MatchString(relative)
+}
+
+// With returns the rules followed by more rules, which therefore take
+// precedence; neither list is changed
+func (ir IgnoreRules) With(more IgnoreRules) IgnoreRules {
+	return slices.Concat(ir, more)
+}
+
+// Match returns the rule that causes the path to be ignored, or nil if the path
+// is not ignored
+func (ir IgnoreRules) Match(path string, isDir bool) *IgnoreRule {
+	for i := len(ir) - 1; i >= 0; i-- {
+		if rule := ir[i]; rule.matches(path, isDir) {
+			if rule.negated {
+				return nil
+			}
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package files_test
+
+import (
+	"mp3/internal/files"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	const fnName = "ParseIgnoreRules()"
+	tests := map[string]struct {
+		content   string
+		wantRules []string
+		wantLines []int
+		wantErrs  []string
+	}{
+		"empty": {},
+		"comments and blank lines": {
+			content:   "# bootlegs\n\n  \nlive*\r\n",
+			wantRules: []string{"live*"},
+			wantLines: []int{4},
+		},
+		"escaped trailing space": {
+			content:   "odd name\\ \nplain   ",
+			wantRules: []string{"odd name\\ ", "plain"},
+			wantLines: []int{1, 2},
+		},
+		"bad patterns": {
+			content:   "good\n[abc\n/\nends\\\n!",
+			wantRules: []string{"good"},
+			wantLines: []int{1},
+			wantErrs: []string{
+				`line 2 of test: the pattern "[abc" has a '[' without a matching ']'`,
+				`line 3 of test: the pattern "/" does not name anything`,
+				`line 4 of test: the pattern "ends\\" ends with an unfinished escape`,
+				`line 5 of test: the pattern "!" does not name anything`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules, errs := files.ParseIgnoreRules(tt.content, "music", "test")
+			if len(rules) != len(tt.wantRules) {
+				t.Fatalf("%s got %d rules, want %d", fnName, len(rules), len(tt.wantRules))
+			}
+			for i, rule := range rules {
+				if rule.Pattern() != tt.wantRules[i] || rule.Line() != tt.wantLines[i] ||
+					rule.Source() != "test" {
+					t.Errorf("%s rule %d = %s, want %q on line %d", fnName, i, rule,
+						tt.wantRules[i], tt.wantLines[i])
+				}
+			}
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("%s got errors %v, want %v", fnName, errs, tt.wantErrs)
+			}
+			for i, err := range errs {
+				if err.Error() != tt.wantErrs[i] {
+					t.Errorf("%s error %d = %q, want %q", fnName, i, err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoreRules_Match(t *testing.T) {
+	const fnName = "IgnoreRules.Match()"
+	base := filepath.Join("music", "top")
+	path := func(parts ...string) string {
+		return filepath.Join(append([]string{base}, parts...)...)
+	}
+	tests := map[string]struct {
+		content string
+		path    string
+		isDir   bool
+		want    string
+	}{
+		"name at any depth":          {content: "bootleg*", path: path("a", "b", "bootleg 1"), isDir: true, want: "bootleg*"},
+		"name does not match":        {content: "bootleg*", path: path("a", "live"), isDir: true},
+		"question mark":              {content: "0?.mp3", path: path("a", "b", "01.mp3"), want: "0?.mp3"},
+		"wildcard stops at slash":    {content: "a/*", path: path("a", "b", "c")},
+		"anchored":                   {content: "a/b", path: path("a", "b"), isDir: true, want: "a/b"},
+		"anchored elsewhere":         {content: "a/b", path: path("x", "a", "b"), isDir: true},
+		"leading slash":              {content: "/b", path: path("b"), isDir: true, want: "/b"},
+		"leading slash, deeper":      {content: "/b", path: path("a", "b"), isDir: true},
+		"outside the base":           {content: "/b", path: filepath.Join("music", "b"), isDir: true},
+		"directories only":           {content: "live/", path: path("a", "live"), isDir: true, want: "live/"},
+		"directories only, file":     {content: "live/", path: path("a", "live")},
+		"double star prefix":         {content: "**/live", path: path("a", "b", "live"), want: "**/live"},
+		"double star prefix, at top": {content: "**/live", path: path("live"), want: "**/live"},
+		"double star suffix":         {content: "a/**", path: path("a", "b", "c"), want: "a/**"},
+		"double star middle":         {content: "a/**/c", path: path("a", "x", "y", "c"), want: "a/**/c"},
+		"character class":            {content: "[0-9]*", path: path("a", "7 track"), want: "[0-9]*"},
+		"negated character class":    {content: "[!0-9]*", path: path("a", "7 track")},
+		"escaped wildcard":           {content: `what\?`, path: path("what?"), want: `what\?`},
+		"escaped wildcard, literal":  {content: `what\?`, path: path("whatx")},
+		"regex characters literal":   {content: "a+b (live)", path: path("a+b (live)"), want: "a+b (live)"},
+		"negation restores":          {content: "*.mp3\n!keep.mp3", path: path("keep.mp3")},
+		"negation then ignore":       {content: "!keep.mp3\n*.mp3", path: path("keep.mp3"), want: "*.mp3"},
+		"last rule decides":          {content: "*.mp3\n!keep.mp3\nkeep*", path: path("keep.mp3"), want: "keep*"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules, errs := files.ParseIgnoreRules(tt.content, base, "test")
+			if len(errs) != 0 {
+				t.Fatalf("%s unexpected errors %v", fnName, errs)
+			}
+			got := rules.Match(tt.path, tt.isDir)
+			switch {
+			case got == nil && tt.want != "":
+				t.Errorf("%s %q not ignored, want ignored by %q", fnName, tt.path, tt.want)
+			case got != nil && got.Pattern() != tt.want:
+				t.Errorf("%s %q ignored by %s, want %q", fnName, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreRules_With(t *testing.T) {
+	const fnName = "IgnoreRules.With()"
+	outer, _ := files.ParseIgnoreRules("*.wav", "music", "outer")
+	inner, _ := files.ParseIgnoreRules("!keep.wav", filepath.Join("music", "a"), "inner")
+	combined := outer.With(inner)
+	if len(outer) != 1 || len(inner) != 1 || len(combined) != 2 {
+		t.Fatalf("%s lengths %d, %d, %d", fnName, len(outer), len(inner), len(combined))
+	}
+	if got := combined.Match(filepath.Join("music", "a", "keep.wav"), false); got != nil {
+		t.Errorf("%s inner rule did not take precedence: %s", fnName, got)
+	}
+	if got := combined.Match(filepath.Join("music", "b", "keep.wav"), false); got == nil {
+		t.Errorf("%s outer rule was not applied", fnName)
+	}
+}
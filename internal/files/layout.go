@@ -0,0 +1,230 @@
+package files
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the fields that a layout template may use; each is written in the template
+// in braces, e.g., "{artist}"
+const (
+	LayoutAlbum  = "album"
+	LayoutArtist = "artist"
+	LayoutDisc   = "disc"
+	LayoutGenre  = "genre"
+	LayoutTitle  = "title"
+	LayoutTrack  = "track"
+	LayoutYear   = "year"
+)
+
+// DefaultLayout is the layout of artist directories holding album directories
+// holding track files whose names begin with the track number
+const DefaultLayout = "{" + LayoutArtist + "}/{" + LayoutAlbum + "}/{" + LayoutTrack + "} {" +
+	LayoutTitle + "}"
+
+var (
+	// the regular expression each field matches; fields not listed here match
+	// any text
+	layoutFieldPatterns = map[string]string{
+		LayoutDisc:  `\d+`,
+		LayoutTrack: `\d+`,
+		LayoutYear:  `\d{4}`,
+	}
+	layoutFieldRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+)
+
+// Layout describes how the directories below the top directory are arranged:
+// a template such as "{genre}/{artist}/{year} - {album}/{track:02} {title}" has
+// one component for each directory level, followed by one for the track file
+// names, which omits the file extension
+type Layout struct {
+	text        string
+	levels      []*layoutLevel
+	artistLevel int
+	albumLevel  int
+}
+
+// layoutLevel is one component of a layout template
+type layoutLevel struct {
+	pattern *regexp.Regexp
+	fields  []string
+}
+
+// LayoutValues holds the field values matched by a layout
+type LayoutValues map[string]string
+
+// Number returns the value of a numeric field, such as the track number; a
+// missing field is 0
+func (lv LayoutValues) Number(field string) int {
+	i, _ := strconv.Atoi(lv[field])
+	return i
+}
+
+// ParseLayout parses a layout template. The template must include the {artist}
+// and {album} fields, each in its own directory component and in that order,
+// and the {track} and {title} fields in the track file name component. The
+// {genre} and {year} fields describe the album, and so must appear at or above
+// the album's directory component; the {disc} field must appear below it. The
+// numeric fields, {track}, {disc}, and {year}, may specify a minimum number of
+// digits, e.g., {track:02}.
+func ParseLayout(template string) (*Layout, error) {
+	components := strings.Split(template, "/")
+	l := &Layout{text: template}
+	found := map[string]int{}
+	for index, component := range components {
+		if component == "" {
+			return nil, fmt.Errorf("the layout %q has an empty path component", template)
+		}
+		level, err := parseLayoutLevel(component)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range level.fields {
+			if _, duplicate := found[field]; duplicate {
+				return nil, fmt.Errorf("the layout %q uses the {%s} field more than once",
+					template, field)
+			}
+			found[field] = index
+		}
+		l.levels = append(l.levels, level)
+	}
+	trackLevel := len(components) - 1
+	for _, field := range []string{LayoutArtist, LayoutAlbum, LayoutTrack, LayoutTitle} {
+		if _, ok := found[field]; !ok {
+			return nil, fmt.Errorf("the layout %q does not include the {%s} field",
+				template, field)
+		}
+	}
+	l.artistLevel = found[LayoutArtist]
+	l.albumLevel = found[LayoutAlbum]
+	switch {
+	case l.albumLevel == trackLevel:
+		return nil, fmt.Errorf("the {%s} field of the layout %q must be part of a"+
+			" directory name", LayoutAlbum, template)
+	case l.artistLevel >= l.albumLevel:
+		return nil, fmt.Errorf("the {%s} field of the layout %q must be part of a"+
+			" directory above the {%s} field", LayoutArtist, template, LayoutAlbum)
+	}
+	for _, field := range []string{LayoutTrack, LayoutTitle} {
+		if found[field] != trackLevel {
+			return nil, fmt.Errorf("the {%s} field of the layout %q must be part of the"+
+				" track file name", field, template)
+		}
+	}
+	for _, field := range []string{LayoutGenre, LayoutYear} {
+		if index, ok := found[field]; ok && index > l.albumLevel {
+			return nil, fmt.Errorf("the {%s} field of the layout %q must not be below"+
+				" the {%s} field", field, template, LayoutAlbum)
+		}
+	}
+	if index, ok := found[LayoutDisc]; ok && index <= l.albumLevel {
+		return nil, fmt.Errorf("the {%s} field of the layout %q must be below the {%s}"+
+			" field", LayoutDisc, template, LayoutAlbum)
+	}
+	return l, nil
+}
+
+func parseLayoutLevel(component string) (*layoutLevel, error) {
+	level := &layoutLevel{}
+	pattern := &strings.Builder{}
+	pattern.WriteString("^")
+	previous := 0
+	for _, match := range layoutFieldRegex.FindAllStringSubmatchIndex(component, -1) {
+		literal := component[previous:match[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, fmt.Errorf("the layout component %q has an unmatched brace",
+				component)
+		}
+		pattern.WriteString(regexp.QuoteMeta(literal))
+		field, format, _ := strings.Cut(component[match[2]:match[3]], ":")
+		fieldPattern, err := layoutFieldPattern(field, format)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(pattern, "(?P<%s>%s)", field, fieldPattern)
+		level.fields = append(level.fields, field)
+		previous = match[1]
+	}
+	literal := component[previous:]
+	if strings.ContainsAny(literal, "{}") {
+		return nil, fmt.Errorf("the layout component %q has an unmatched brace", component)
+	}
+	pattern.WriteString(regexp.QuoteMeta(literal))
+	pattern.WriteString("$")
+	level.pattern = regexp.MustCompile(pattern.String())
+	return level, nil
+}
+
+func layoutFieldPattern(field, format string) (string, error) {
+	switch field {
+	case LayoutAlbum, LayoutArtist, LayoutGenre, LayoutTitle:
+		if format != "" {
+			return "", fmt.Errorf("the layout field {%s:%s} cannot have a format", field,
+				format)
+		}
+		return ".+?", nil
+	case LayoutDisc, LayoutTrack, LayoutYear:
+		if format == "" {
+			return layoutFieldPatterns[field], nil
+		}
+		if width, err := strconv.Atoi(format); err == nil && width > 0 {
+			return fmt.Sprintf(`\d{%d,}`, width), nil
+		}
+		return "", fmt.Errorf("the layout field {%s:%s} has a format that is not a"+
+			" number of digits", field, format)
+	default:
+		return "", fmt.Errorf("the layout field {%s} is not recognized; the recognized"+
+			" fields are {%s}, {%s}, {%s}, {%s}, {%s}, {%s}, and {%s}", field, LayoutAlbum,
+			LayoutArtist, LayoutDisc, LayoutGenre, LayoutTitle, LayoutTrack, LayoutYear)
+	}
+}
+
+// String returns the layout's template
+func (l *Layout) String() string {
+	return l.text
+}
+
+// IsDefault returns true if the layout is the default layout
+func (l *Layout) IsDefault() bool {
+	return l.text == DefaultLayout
+}
+
+// ArtistLevel returns the index of the directory level holding artists
+func (l *Layout) ArtistLevel() int {
+	return l.artistLevel
+}
+
+// AlbumLevel returns the index of the directory level holding albums
+func (l *Layout) AlbumLevel() int {
+	return l.albumLevel
+}
+
+// TrackLevel returns the index of the level holding track files, which is below
+// all the directory levels
+func (l *Layout) TrackLevel() int {
+	return len(l.levels) - 1
+}
+
+// Match matches a directory or file name, stripped of its extension, against
+// the layout's component for the level; the values matched are added to a copy
+// of the values matched at the levels above it
+func (l *Layout) Match(level int, name string, values LayoutValues) (LayoutValues, bool) {
+	if level < 0 || level >= len(l.levels) {
+		return nil, false
+	}
+	lL := l.levels[level]
+	matches := lL.pattern.FindStringSubmatch(name)
+	if matches == nil {
+		return nil, false
+	}
+	matched := LayoutValues{}
+	for field, value := range values {
+		matched[field] = value
+	}
+	for _, field := range lL.fields {
+		matched[field] = matches[lL.pattern.SubexpIndex(field)]
+	}
+	return matched, true
+}
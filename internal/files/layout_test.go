@@ -0,0 +1,189 @@
+package files_test
+
+import (
+	"mp3/internal/files"
+	"reflect"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	const fnName = "ParseLayout()"
+	tests := map[string]struct {
+		template    string
+		wantArtist  int
+		wantAlbum   int
+		wantTrack   int
+		wantDefault bool
+		wantErr     string
+	}{
+		"default": {
+			template:    files.DefaultLayout,
+			wantArtist:  0,
+			wantAlbum:   1,
+			wantTrack:   2,
+			wantDefault: true,
+		},
+		"genre and year": {
+			template:   "{genre}/{artist}/{year} - {album}/{track:02} {title}",
+			wantArtist: 1,
+			wantAlbum:  2,
+			wantTrack:  3,
+		},
+		"disc subdirectory": {
+			template:   "{artist}/{album}/Disc {disc}/{track} {title}",
+			wantArtist: 0,
+			wantAlbum:  1,
+			wantTrack:  3,
+		},
+		"empty component": {
+			template: "{artist}//{album}/{track} {title}",
+			wantErr:  `the layout "{artist}//{album}/{track} {title}" has an empty path component`,
+		},
+		"duplicate field": {
+			template: "{artist}/{artist} - {album}/{track} {title}",
+			wantErr: `the layout "{artist}/{artist} - {album}/{track} {title}" uses the` +
+				" {artist} field more than once",
+		},
+		"missing title": {
+			template: "{artist}/{album}/{track}",
+			wantErr:  `the layout "{artist}/{album}/{track}" does not include the {title} field`,
+		},
+		"album in file name": {
+			template: "{artist}/{track} {album} {title}",
+			wantErr: `the {album} field of the layout "{artist}/{track} {album} {title}"` +
+				" must be part of a directory name",
+		},
+		"artist below album": {
+			template: "{album}/{artist}/{track} {title}",
+			wantErr: `the {artist} field of the layout "{album}/{artist}/{track} {title}"` +
+				" must be part of a directory above the {album} field",
+		},
+		"track in directory": {
+			template: "{artist}/{album}/{track}/{title}",
+			wantErr: `the {track} field of the layout "{artist}/{album}/{track}/{title}"` +
+				" must be part of the track file name",
+		},
+		"year below album": {
+			template: "{artist}/{album}/{year} {track} {title}",
+			wantErr: `the {year} field of the layout "{artist}/{album}/{year} {track}` +
+				` {title}" must not be below the {album} field`,
+		},
+		"disc above album": {
+			template: "{artist}/{disc}/{album}/{track} {title}",
+			wantErr: `the {disc} field of the layout "{artist}/{disc}/{album}/{track}` +
+				` {title}" must be below the {album} field`,
+		},
+		"unmatched brace": {
+			template: "{artist}/{album}}/{track} {title}",
+			wantErr:  `the layout component "{album}}" has an unmatched brace`,
+		},
+		"format on text field": {
+			template: "{artist}/{album:02}/{track} {title}",
+			wantErr:  "the layout field {album:02} cannot have a format",
+		},
+		"bad format": {
+			template: "{artist}/{album}/{track:xx} {title}",
+			wantErr:  "the layout field {track:xx} has a format that is not a number of digits",
+		},
+		"unknown field": {
+			template: "{artist}/{album}/{track} {title} {composer}",
+			wantErr: "the layout field {composer} is not recognized; the recognized fields" +
+				" are {album}, {artist}, {disc}, {genre}, {title}, {track}, and {year}",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ParseLayout(tt.template)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("%s error = %q, want %q", fnName, err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("%s error = nil, want %q", fnName, tt.wantErr)
+				return
+			}
+			if got.String() != tt.template {
+				t.Errorf("%s String() = %q, want %q", fnName, got.String(), tt.template)
+			}
+			if got.ArtistLevel() != tt.wantArtist || got.AlbumLevel() != tt.wantAlbum ||
+				got.TrackLevel() != tt.wantTrack {
+				t.Errorf("%s levels = %d %d %d, want %d %d %d", fnName, got.ArtistLevel(),
+					got.AlbumLevel(), got.TrackLevel(), tt.wantArtist, tt.wantAlbum,
+					tt.wantTrack)
+			}
+			if got.IsDefault() != tt.wantDefault {
+				t.Errorf("%s IsDefault() = %t, want %t", fnName, got.IsDefault(),
+					tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestLayoutMatch(t *testing.T) {
+	const fnName = "Layout.Match()"
+	layout, _ := files.ParseLayout("{genre}/{artist}/{year} - {album}/{track:02} {title}")
+	tests := map[string]struct {
+		level     int
+		name      string
+		values    files.LayoutValues
+		want      files.LayoutValues
+		wantMatch bool
+	}{
+		"genre": {
+			level:     0,
+			name:      "rock",
+			values:    files.LayoutValues{},
+			want:      files.LayoutValues{files.LayoutGenre: "rock"},
+			wantMatch: true,
+		},
+		"album": {
+			level:  2,
+			name:   "1969 - Abbey Road - Remastered",
+			values: files.LayoutValues{files.LayoutArtist: "The Beatles"},
+			want: files.LayoutValues{
+				files.LayoutArtist: "The Beatles",
+				files.LayoutYear:   "1969",
+				files.LayoutAlbum:  "Abbey Road - Remastered",
+			},
+			wantMatch: true,
+		},
+		"album without year": {
+			level:  2,
+			name:   "Abbey Road",
+			values: files.LayoutValues{},
+		},
+		"track": {
+			level:  3,
+			name:   "07 Here Comes the Sun",
+			values: files.LayoutValues{},
+			want: files.LayoutValues{
+				files.LayoutTrack: "07",
+				files.LayoutTitle: "Here Comes the Sun",
+			},
+			wantMatch: true,
+		},
+		"track number too short": {
+			level:  3,
+			name:   "7 Here Comes the Sun",
+			values: files.LayoutValues{},
+		},
+		"no such level": {
+			level:  4,
+			name:   "anything",
+			values: files.LayoutValues{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotMatch := layout.Match(tt.level, tt.name, tt.values)
+			if gotMatch != tt.wantMatch {
+				t.Errorf("%s match = %t, want %t", fnName, gotMatch, tt.wantMatch)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
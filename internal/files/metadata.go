@@ -13,52 +13,49 @@ const (
 	UndefinedSource SourceType = iota
 	ID3V1
 	ID3V2
+	APEV2
+	FLAC
+	MP4
+	OGG
 	TotalSources
 )
 
-var (
-	nameComparators = map[SourceType]func(*ComparableStrings) bool{
-		ID3V1: Id3v1NameDiffers,
-		ID3V2: Id3v2NameDiffers,
-	}
-	genreComparators = map[SourceType]func(*ComparableStrings) bool{
-		ID3V1: Id3v1GenreDiffers,
-		ID3V2: Id3v2GenreDiffers,
-	}
-	metadataUpdaters = map[SourceType]func(tM *TrackMetadata, path string,
-		src SourceType) error{
-		ID3V1: updateID3V1Metadata,
-		ID3V2: updateID3V2Metadata,
-	}
-	sourceTypes = []SourceType{ID3V1, ID3V2}
-)
-
+// Name returns the name of the registered metadata source for the SourceType
 func (sT SourceType) Name() string {
-	switch sT {
-	case ID3V1:
-		return "ID3V1"
-	case ID3V2:
-		return "ID3V2"
-	case TotalSources:
+	if src, ok := lookupMetadataSource(sT); ok {
+		return src.Name()
+	}
+	if sT == TotalSources {
 		return "total"
-	default:
-		return "undefined"
 	}
+	return "undefined"
+}
+
+// String returns the SourceType's name (implementation of Stringer interface)
+func (sT SourceType) String() string {
+	return sT.Name()
 }
 
 type TrackMetadata struct {
+	albumArtistName   string
 	albumName         []string
 	artistName        []string
+	compilation       bool
+	discNumber        int
 	primarySource     SourceType
 	errorCause        []string
 	genre             []string
+	id3v2Version      int
 	musicCDIdentifier id3v2.UnknownFrame
 	trackName         []string
 	trackNumber       []int
 	year              []string
 	// these fields are set by the various xDiffers methods
+	correctedAlbumArtistName   string
 	correctedAlbumName         []string
 	correctedArtistName        []string
+	correctedCompilation       bool
+	correctedDiscNumber        int
 	correctedGenre             []string
 	correctedMusicCDIdentifier id3v2.UnknownFrame
 	correctedTrackName         []string
@@ -95,6 +92,11 @@ func (tm *TrackMetadata) SetYear(src SourceType, s string) {
 	tm.year[src] = s
 }
 
+func (tm *TrackMetadata) WithAlbumArtistName(s string) *TrackMetadata {
+	tm.albumArtistName = s
+	return tm
+}
+
 func (tm *TrackMetadata) WithAlbumNames(s []string) *TrackMetadata {
 	for i := range min(len(s), int(TotalSources)) {
 		tm.albumName[i] = s[i]
@@ -109,6 +111,16 @@ func (tm *TrackMetadata) WithArtistNames(s []string) *TrackMetadata {
 	return tm
 }
 
+func (tm *TrackMetadata) WithCompilation(b bool) *TrackMetadata {
+	tm.compilation = b
+	return tm
+}
+
+func (tm *TrackMetadata) WithDiscNumber(i int) *TrackMetadata {
+	tm.discNumber = i
+	return tm
+}
+
 func (tm *TrackMetadata) WithPrimarySource(t SourceType) *TrackMetadata {
 	tm.primarySource = t
 	return tm
@@ -128,6 +140,11 @@ func (tm *TrackMetadata) WithGenres(s []string) *TrackMetadata {
 	return tm
 }
 
+func (tm *TrackMetadata) WithID3V2Version(i int) *TrackMetadata {
+	tm.id3v2Version = i
+	return tm
+}
+
 func (tm *TrackMetadata) WithMusicCDIdentifier(b []byte) *TrackMetadata {
 	tm.musicCDIdentifier = id3v2.UnknownFrame{Body: b}
 	return tm
@@ -154,6 +171,11 @@ func (tm *TrackMetadata) WithYears(s []string) *TrackMetadata {
 	return tm
 }
 
+func (tm *TrackMetadata) WithCorrectedAlbumArtistName(s string) *TrackMetadata {
+	tm.correctedAlbumArtistName = s
+	return tm
+}
+
 func (tm *TrackMetadata) WithCorrectedAlbumNames(s []string) *TrackMetadata {
 	for i := range min(len(s), int(TotalSources)) {
 		tm.correctedAlbumName[i] = s[i]
@@ -168,6 +190,16 @@ func (tm *TrackMetadata) WithCorrectedArtistNames(s []string) *TrackMetadata {
 	return tm
 }
 
+func (tm *TrackMetadata) WithCorrectedCompilation(b bool) *TrackMetadata {
+	tm.correctedCompilation = b
+	return tm
+}
+
+func (tm *TrackMetadata) WithCorrectedDiscNumber(i int) *TrackMetadata {
+	tm.correctedDiscNumber = i
+	return tm
+}
+
 func (tm *TrackMetadata) WithCorrectedGenres(s []string) *TrackMetadata {
 	for i := range min(len(s), int(TotalSources)) {
 		tm.correctedGenre[i] = s[i]
@@ -208,8 +240,13 @@ func (tm *TrackMetadata) WithRequiresEdits(b []bool) *TrackMetadata {
 	return tm
 }
 
+// NewTrackMetadata creates an empty TrackMetadata instance; metadata that is
+// normally absent from track files, such as APEv2 tags, is marked as missing,
+// and metadata that only some kinds of files contain, such as the Vorbis
+// comments in FLAC files, is marked as not applicable, until the metadata is
+// read from a file
 func NewTrackMetadata() *TrackMetadata {
-	return &TrackMetadata{
+	tM := &TrackMetadata{
 		albumName:            make([]string, TotalSources),
 		artistName:           make([]string, TotalSources),
 		trackName:            make([]string, TotalSources),
@@ -225,32 +262,44 @@ func NewTrackMetadata() *TrackMetadata {
 		correctedTrackNumber: make([]int, TotalSources),
 		requiresEdit:         make([]bool, TotalSources),
 	}
+	for _, src := range metadataSources {
+		switch missing := src.MissingError(); {
+		case len(src.Extensions()) != 0:
+			tM.errorCause[src.Type()] = errNotApplicable.Error()
+		case missing != nil:
+			tM.errorCause[src.Type()] = missing.Error()
+		}
+	}
+	return tM
 }
 
-func ReadRawMetadata(path string) *TrackMetadata {
-	v1, id3v1Err := InternalReadID3V1Metadata(path, FileReader)
-	d := RawReadID3V2Metadata(path)
+// ReadRawMetadata reads the metadata from each registered source that applies
+// to the file and selects the primary source from those that could be read,
+// following the settings' priority
+func ReadRawMetadata(path string, settings *ReadSettings) *TrackMetadata {
 	tM := NewTrackMetadata()
-	switch {
-	case id3v1Err != nil && d.err != nil:
-		tM.errorCause[ID3V1] = id3v1Err.Error()
-		tM.errorCause[ID3V2] = d.err.Error()
-	case id3v1Err != nil:
-		tM.errorCause[ID3V1] = id3v1Err.Error()
-		tM.SetID3v2Values(d)
-		tM.primarySource = ID3V2
-	case d.err != nil:
-		tM.errorCause[ID3V2] = d.err.Error()
-		tM.SetID3v1Values(v1)
-		tM.primarySource = ID3V1
-	default:
-		tM.SetID3v2Values(d)
-		tM.SetID3v1Values(v1)
-		tM.primarySource = ID3V2
+	for _, src := range metadataSources {
+		if appliesTo(src, path) {
+			readSource(src, path, tM)
+		} else {
+			tM.errorCause[src.Type()] = errNotApplicable.Error()
+		}
 	}
+	tM.choosePrimarySource(settings)
 	return tM
 }
 
+// choosePrimarySource selects the most preferred of the metadata sources that
+// were read without error
+func (tM *TrackMetadata) choosePrimarySource(settings *ReadSettings) {
+	for _, sT := range settings.PrimarySourcePriority() {
+		if tM.errorCause[sT] == "" {
+			tM.primarySource = sT
+			break
+		}
+	}
+}
+
 func (tM *TrackMetadata) SetID3v2Values(d *Id3v2Metadata) {
 	i := ID3V2
 	tM.albumName[i] = d.albumName
@@ -260,6 +309,10 @@ func (tM *TrackMetadata) SetID3v2Values(d *Id3v2Metadata) {
 	tM.year[i] = d.year
 	tM.trackNumber[i] = d.trackNumber
 	tM.musicCDIdentifier = d.musicCDIdentifier
+	tM.discNumber = d.discNumber
+	tM.albumArtistName = d.albumArtistName
+	tM.compilation = d.compilation
+	tM.id3v2Version = int(d.version)
 }
 
 func (tM *TrackMetadata) SetID3v1Values(v1 *Id3v1Metadata) {
@@ -276,8 +329,47 @@ func (tM *TrackMetadata) SetID3v1Values(v1 *Id3v1Metadata) {
 	}
 }
 
+func (tM *TrackMetadata) SetApeV2Values(ape *ApeV2Metadata) {
+	i := APEV2
+	tM.albumName[i] = ape.albumName
+	tM.artistName[i] = ape.artistName
+	tM.trackName[i] = ape.trackName
+	tM.genre[i] = ape.genre
+	tM.year[i] = ape.year
+	tM.trackNumber[i] = ape.trackNumber
+}
+
+func (tM *TrackMetadata) SetFlacValues(vm *VorbisMetadata) {
+	tM.setVorbisValues(FLAC, vm)
+}
+
+func (tM *TrackMetadata) SetOggValues(vm *VorbisMetadata) {
+	tM.setVorbisValues(OGG, vm)
+}
+
+func (tM *TrackMetadata) setVorbisValues(i SourceType, vm *VorbisMetadata) {
+	tM.albumName[i] = vm.albumName
+	tM.artistName[i] = vm.artistName
+	tM.trackName[i] = vm.trackName
+	tM.genre[i] = vm.genre
+	tM.year[i] = vm.year
+	tM.trackNumber[i] = vm.trackNumber
+}
+
+func (tM *TrackMetadata) SetMp4Values(mm *Mp4Metadata) {
+	i := MP4
+	tM.albumName[i] = mm.albumName
+	tM.artistName[i] = mm.artistName
+	tM.trackName[i] = mm.trackName
+	tM.genre[i] = mm.genre
+	tM.year[i] = mm.year
+	tM.trackNumber[i] = mm.trackNumber
+}
+
+// IsValid returns true if the primary source is a registered metadata source
 func (tM *TrackMetadata) IsValid() bool {
-	return tM.primarySource == ID3V1 || tM.primarySource == ID3V2
+	_, ok := lookupMetadataSource(tM.primarySource)
+	return ok
 }
 
 func (tM *TrackMetadata) CanonicalArtist() string {
@@ -288,6 +380,10 @@ func (tM *TrackMetadata) CanonicalAlbum() string {
 	return tM.albumName[tM.primarySource]
 }
 
+func (tM *TrackMetadata) CanonicalTitle() string {
+	return tM.trackName[tM.primarySource]
+}
+
 func (tM *TrackMetadata) CanonicalGenre() string {
 	return tM.genre[tM.primarySource]
 }
@@ -300,10 +396,18 @@ func (tM *TrackMetadata) CanonicalMusicCDIdentifier() id3v2.UnknownFrame {
 	return tM.musicCDIdentifier
 }
 
+// ID3V2Version returns the major version of the track's ID3V2 tag, such as 3
+// for ID3V2.3; it is 0 if the track has no ID3V2 tag that could be read
+func (tM *TrackMetadata) ID3V2Version() int {
+	return tM.id3v2Version
+}
+
+// ErrorCauses returns the errors encountered reading the metadata; missing
+// optional metadata, such as an APEv2 tag, is not considered to be an error
 func (tM *TrackMetadata) ErrorCauses() []string {
 	errCauses := make([]string, 0, len(tM.errorCause))
-	for _, e := range tM.errorCause {
-		if e != "" {
+	for _, src := range metadataSources {
+		if e := tM.errorCause[src.Type()]; e != "" && !isMissing(src, e) {
 			errCauses = append(errCauses, e)
 		}
 	}
@@ -338,7 +442,8 @@ func NewComparableStrings() *ComparableStrings {
 }
 
 func (tM *TrackMetadata) TrackDiffers(track int) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		if tM.errorCause[sT] == "" && tM.trackNumber[sT] != track {
 			differs = true
 			tM.requiresEdit[sT] = true
@@ -349,9 +454,10 @@ func (tM *TrackMetadata) TrackDiffers(track int) (differs bool) {
 }
 
 func (tM *TrackMetadata) TrackTitleDiffers(title string) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		comparison := &ComparableStrings{external: title, metadata: tM.trackName[sT]}
-		if tM.errorCause[sT] == "" && nameComparators[sT](comparison) {
+		if tM.errorCause[sT] == "" && src.NameDiffers(comparison) {
 			differs = true
 			tM.requiresEdit[sT] = true
 			tM.correctedTrackName[sT] = title
@@ -361,9 +467,10 @@ func (tM *TrackMetadata) TrackTitleDiffers(title string) (differs bool) {
 }
 
 func (tM *TrackMetadata) AlbumTitleDiffers(albumTitle string) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		comparison := &ComparableStrings{external: albumTitle, metadata: tM.albumName[sT]}
-		if tM.errorCause[sT] == "" && nameComparators[sT](comparison) {
+		if tM.errorCause[sT] == "" && src.NameDiffers(comparison) {
 			differs = true
 			tM.requiresEdit[sT] = true
 			tM.correctedAlbumName[sT] = albumTitle
@@ -373,9 +480,10 @@ func (tM *TrackMetadata) AlbumTitleDiffers(albumTitle string) (differs bool) {
 }
 
 func (tM *TrackMetadata) ArtistNameDiffers(artistName string) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		comparison := &ComparableStrings{external: artistName, metadata: tM.artistName[sT]}
-		if tM.errorCause[sT] == "" && nameComparators[sT](comparison) {
+		if tM.errorCause[sT] == "" && src.NameDiffers(comparison) {
 			differs = true
 			tM.requiresEdit[sT] = true
 			tM.correctedArtistName[sT] = artistName
@@ -385,9 +493,10 @@ func (tM *TrackMetadata) ArtistNameDiffers(artistName string) (differs bool) {
 }
 
 func (tM *TrackMetadata) GenreDiffers(genre string) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		comparison := &ComparableStrings{external: genre, metadata: tM.genre[sT]}
-		if tM.errorCause[sT] == "" && genreComparators[sT](comparison) {
+		if tM.errorCause[sT] == "" && src.GenreDiffers(comparison) {
 			differs = true
 			tM.requiresEdit[sT] = true
 			tM.correctedGenre[sT] = genre
@@ -397,7 +506,8 @@ func (tM *TrackMetadata) GenreDiffers(genre string) (differs bool) {
 }
 
 func (tM *TrackMetadata) YearDiffers(year string) (differs bool) {
-	for _, sT := range sourceTypes {
+	for _, src := range metadataSources {
+		sT := src.Type()
 		if tM.errorCause[sT] == "" && tM.year[sT] != year {
 			differs = true
 			tM.requiresEdit[sT] = true
@@ -416,19 +526,66 @@ func (tM *TrackMetadata) MCDIDiffers(f id3v2.UnknownFrame) (differs bool) {
 	return
 }
 
+// DiscDiffers compares the disc number with the ID3V2 TPOS frame, the only
+// metadata that records it; a disc number of 0 means that the track's album is
+// not split into discs, and so it does not differ
+func (tM *TrackMetadata) DiscDiffers(disc int) (differs bool) {
+	if disc != 0 && tM.errorCause[ID3V2] == "" && tM.discNumber != disc {
+		differs = true
+		tM.requiresEdit[ID3V2] = true
+		tM.correctedDiscNumber = disc
+	}
+	return
+}
+
+// AlbumArtistDiffers compares the album artist with the ID3V2 TPE2 frame, the
+// only metadata that records it. Most tracks by a single artist have no TPE2
+// frame, and so a missing frame differs only on a compilation, whose album
+// artist cannot be inferred from the artist name metadata.
+func (tM *TrackMetadata) AlbumArtistDiffers(albumArtist string,
+	compilation bool) (differs bool) {
+	if tM.errorCause[ID3V2] != "" || (tM.albumArtistName == "" && !compilation) {
+		return
+	}
+	comparison := &ComparableStrings{external: albumArtist, metadata: tM.albumArtistName}
+	if Id3v2NameDiffers(comparison) {
+		differs = true
+		tM.requiresEdit[ID3V2] = true
+		tM.correctedAlbumArtistName = albumArtist
+	}
+	return
+}
+
+// CompilationDiffers compares the compilation flag with the ID3V2 TCMP frame;
+// only tracks of a compilation that are not flagged as such differ, as a track
+// flagged as part of a compilation may be filed under one of its artists
+func (tM *TrackMetadata) CompilationDiffers(compilation bool) (differs bool) {
+	if compilation && tM.errorCause[ID3V2] == "" && !tM.compilation {
+		differs = true
+		tM.requiresEdit[ID3V2] = true
+		tM.correctedCompilation = true
+	}
+	return
+}
+
 func (tM *TrackMetadata) CanonicalAlbumTitleMatches(albumTitle string) bool {
 	comparison := &ComparableStrings{external: albumTitle, metadata: tM.CanonicalAlbum()}
-	return !nameComparators[tM.primarySource](comparison)
+	return !tM.primaryNameDiffers(comparison)
 }
 
 func (tM *TrackMetadata) CanonicalArtistNameMatches(artistName string) bool {
 	comparison := &ComparableStrings{external: artistName, metadata: tM.CanonicalArtist()}
-	return !nameComparators[tM.primarySource](comparison)
+	return !tM.primaryNameDiffers(comparison)
+}
+
+func (tM *TrackMetadata) primaryNameDiffers(cS *ComparableStrings) bool {
+	src, ok := lookupMetadataSource(tM.primarySource)
+	return !ok || src.NameDiffers(cS)
 }
 
 func updateMetadata(tM *TrackMetadata, path string) (e []error) {
-	for _, source := range sourceTypes {
-		if err := metadataUpdaters[source](tM, path, source); err != nil {
+	for _, src := range metadataSources {
+		if err := src.Write(tM, path); err != nil {
 			e = append(e, err)
 		}
 	}
@@ -1,6 +1,7 @@
 package files_test
 
 import (
+	"fmt"
 	"mp3/internal/files"
 	"path/filepath"
 	"reflect"
@@ -10,14 +11,15 @@ import (
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 )
 
-const (
-	cannotOpenFile = "open readMetadata\\no such file.mp3:" +
-		" The system cannot find the file specified."
-	negativeSeek = "seek readMetadata\\01 tagless.mp3:" +
-		" An attempt was made to move the file pointer before the beginning of the file."
-	noID3V1Metadata = "no id3v1 metadata found in file" +
-		" \"readMetadata\\\\03 id3v2.mp3\""
-	zeroBytes = "zero length"
+const zeroBytes = "zero length"
+
+var (
+	cannotOpenFile = "open " + filepath.Join("readMetadata", "no such file.mp3") + ": " +
+		fileNotFound
+	negativeSeek = "seek " + filepath.Join("readMetadata", "01 tagless.mp3") + ": " +
+		seekBeforeStart
+	noID3V1Metadata = fmt.Sprintf("no id3v1 metadata found in file %q",
+		filepath.Join("readMetadata", "03 id3v2.mp3"))
 )
 
 func Test_trackMetadata_setId3v1Values(t *testing.T) {
@@ -66,7 +68,7 @@ func Test_trackMetadata_setId3v2Values(t *testing.T) {
 			args: args{
 				d: files.NewId3v2Metadata().WithAlbumName("Great album").WithArtistName(
 					"Great artist").WithTrackName("Great track").WithGenre("Pop").WithYear(
-					"2022").WithTrackNumber(1).WithMusicCDIdentifier([]byte{0, 2, 4}),
+					"2022").WithTrackNumber(1).WithMusicCDIdentifier([]byte{0, 2, 4}).WithVersion(4),
 			},
 			wantTM: files.NewTrackMetadata().WithAlbumNames(
 				[]string{"", "", "Great album"}).WithArtistNames(
@@ -74,7 +76,7 @@ func Test_trackMetadata_setId3v2Values(t *testing.T) {
 				[]string{"", "", "Great track"}).WithGenres(
 				[]string{"", "", "Pop"}).WithYears([]string{
 				"", "", "2022"}).WithTrackNumbers([]int{
-				0, 0, 1}).WithMusicCDIdentifier([]byte{0, 2, 4}),
+				0, 0, 1}).WithMusicCDIdentifier([]byte{0, 2, 4}).WithID3V2Version(4),
 		},
 	}
 	for name, tt := range tests {
@@ -131,7 +133,8 @@ func Test_readMetadata(t *testing.T) {
 		destroyDirectory(fnName, testDir)
 	}()
 	type args struct {
-		path string
+		path     string
+		priority []files.SourceType
 	}
 	tests := map[string]struct {
 		args
@@ -140,7 +143,7 @@ func Test_readMetadata(t *testing.T) {
 		"missing file": {
 			args: args{path: filepath.Join(testDir, "no such file.mp3")},
 			want: files.NewTrackMetadata().WithErrorCauses([]string{
-				"", cannotOpenFile, cannotOpenFile}),
+				"", cannotOpenFile, cannotOpenFile, cannotOpenFile}),
 		},
 		"no metadata": {
 			args: args{path: filepath.Join(testDir, taglessFile)},
@@ -168,7 +171,7 @@ func Test_readMetadata(t *testing.T) {
 				[]string{"", "", "2022"}).WithTrackNumbers(
 				[]int{0, 0, 2}).WithMusicCDIdentifier(
 				[]byte{0}).WithPrimarySource(files.ID3V2).WithErrorCauses(
-				[]string{"", noID3V1Metadata, ""}),
+				[]string{"", noID3V1Metadata, ""}).WithID3V2Version(3),
 		},
 		"all metadata": {
 			args: args{path: filepath.Join(testDir, completeFile)},
@@ -179,12 +182,31 @@ func Test_readMetadata(t *testing.T) {
 				[]string{"", "Other", "dance music"}).WithYears(
 				[]string{"", "2013", "2022"}).WithTrackNumbers(
 				[]int{0, 29, 2}).WithMusicCDIdentifier([]byte{0}).WithPrimarySource(
-				files.ID3V2),
+				files.ID3V2).WithID3V2Version(3),
+		},
+		"all metadata, ID3V1 preferred": {
+			args: args{
+				path:     filepath.Join(testDir, completeFile),
+				priority: []files.SourceType{files.ID3V1},
+			},
+			want: files.NewTrackMetadata().WithAlbumNames(
+				[]string{"", "On Air: Live At The BBC, Volum", "unknown album"}).WithArtistNames(
+				[]string{"", "The Beatles", "unknown artist"}).WithTrackNames(
+				[]string{"", "Ringo - Pop Profile [Interview", "unknown track"}).WithGenres(
+				[]string{"", "Other", "dance music"}).WithYears(
+				[]string{"", "2013", "2022"}).WithTrackNumbers(
+				[]int{0, 29, 2}).WithMusicCDIdentifier([]byte{0}).WithPrimarySource(
+				files.ID3V1).WithID3V2Version(3),
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			if got := files.ReadRawMetadata(tt.args.path); !reflect.DeepEqual(got, tt.want) {
+			settings := files.NewReadSettings()
+			if tt.args.priority != nil {
+				settings.WithPrimarySourcePriority(tt.args.priority)
+			}
+			if got := files.ReadRawMetadata(tt.args.path, settings); !reflect.DeepEqual(got,
+				tt.want) {
 				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
 			}
 		})
@@ -1295,6 +1317,159 @@ func Test_trackMetadata_MCDIDiffers(t *testing.T) {
 	}
 }
 
+func Test_trackMetadata_DiscDiffers(t *testing.T) {
+	const fnName = "trackMetadata.DiscDiffers()"
+	tests := map[string]struct {
+		tM          *files.TrackMetadata
+		disc        int
+		wantDiffers bool
+		wantTM      *files.TrackMetadata
+	}{
+		"single disc album": {
+			tM:     files.NewTrackMetadata().WithDiscNumber(1),
+			disc:   0,
+			wantTM: files.NewTrackMetadata().WithDiscNumber(1),
+		},
+		"after id3v2 read failure": {
+			tM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+			disc: 2,
+			wantTM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+		},
+		"matching disc": {
+			tM:     files.NewTrackMetadata().WithDiscNumber(2),
+			disc:   2,
+			wantTM: files.NewTrackMetadata().WithDiscNumber(2),
+		},
+		"missing disc": {
+			tM:          files.NewTrackMetadata(),
+			disc:        2,
+			wantDiffers: true,
+			wantTM: files.NewTrackMetadata().WithCorrectedDiscNumber(2).WithRequiresEdits(
+				[]bool{false, false, true}),
+		},
+		"wrong disc": {
+			tM:          files.NewTrackMetadata().WithDiscNumber(1),
+			disc:        2,
+			wantDiffers: true,
+			wantTM: files.NewTrackMetadata().WithDiscNumber(1).WithCorrectedDiscNumber(
+				2).WithRequiresEdits([]bool{false, false, true}),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if gotDiffers := tt.tM.DiscDiffers(tt.disc); gotDiffers != tt.wantDiffers {
+				t.Errorf("%s = %v, want %v", fnName, gotDiffers, tt.wantDiffers)
+			}
+			if !reflect.DeepEqual(tt.tM, tt.wantTM) {
+				t.Errorf("%s got TM %v, want TM %v", fnName, tt.tM, tt.wantTM)
+			}
+		})
+	}
+}
+
+func Test_trackMetadata_AlbumArtistDiffers(t *testing.T) {
+	const fnName = "trackMetadata.AlbumArtistDiffers()"
+	tests := map[string]struct {
+		tM          *files.TrackMetadata
+		albumArtist string
+		compilation bool
+		wantDiffers bool
+		wantTM      *files.TrackMetadata
+	}{
+		"after id3v2 read failure": {
+			tM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+			albumArtist: "Various Artists",
+			compilation: true,
+			wantTM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+		},
+		"no album artist, ordinary artist": {
+			tM:          files.NewTrackMetadata(),
+			albumArtist: "The Beatles",
+			wantTM:      files.NewTrackMetadata(),
+		},
+		"no album artist, compilation": {
+			tM:          files.NewTrackMetadata(),
+			albumArtist: "Various Artists",
+			compilation: true,
+			wantDiffers: true,
+			wantTM: files.NewTrackMetadata().WithCorrectedAlbumArtistName(
+				"Various Artists").WithRequiresEdits([]bool{false, false, true}),
+		},
+		"matching album artist": {
+			tM:          files.NewTrackMetadata().WithAlbumArtistName("the beatles"),
+			albumArtist: "The Beatles",
+			wantTM:      files.NewTrackMetadata().WithAlbumArtistName("the beatles"),
+		},
+		"wrong album artist": {
+			tM:          files.NewTrackMetadata().WithAlbumArtistName("Paul McCartney"),
+			albumArtist: "The Beatles",
+			wantDiffers: true,
+			wantTM: files.NewTrackMetadata().WithAlbumArtistName(
+				"Paul McCartney").WithCorrectedAlbumArtistName("The Beatles").WithRequiresEdits(
+				[]bool{false, false, true}),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if gotDiffers := tt.tM.AlbumArtistDiffers(tt.albumArtist,
+				tt.compilation); gotDiffers != tt.wantDiffers {
+				t.Errorf("%s = %v, want %v", fnName, gotDiffers, tt.wantDiffers)
+			}
+			if !reflect.DeepEqual(tt.tM, tt.wantTM) {
+				t.Errorf("%s got TM %v, want TM %v", fnName, tt.tM, tt.wantTM)
+			}
+		})
+	}
+}
+
+func Test_trackMetadata_CompilationDiffers(t *testing.T) {
+	const fnName = "trackMetadata.CompilationDiffers()"
+	tests := map[string]struct {
+		tM          *files.TrackMetadata
+		compilation bool
+		wantDiffers bool
+		wantTM      *files.TrackMetadata
+	}{
+		"ordinary artist": {
+			tM:     files.NewTrackMetadata().WithCompilation(true),
+			wantTM: files.NewTrackMetadata().WithCompilation(true),
+		},
+		"after id3v2 read failure": {
+			tM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+			compilation: true,
+			wantTM: files.NewTrackMetadata().WithErrorCauses(
+				[]string{"", "", cannotOpenFile}),
+		},
+		"flagged compilation": {
+			tM:          files.NewTrackMetadata().WithCompilation(true),
+			compilation: true,
+			wantTM:      files.NewTrackMetadata().WithCompilation(true),
+		},
+		"unflagged compilation": {
+			tM:          files.NewTrackMetadata(),
+			compilation: true,
+			wantDiffers: true,
+			wantTM: files.NewTrackMetadata().WithCorrectedCompilation(
+				true).WithRequiresEdits([]bool{false, false, true}),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if gotDiffers := tt.tM.CompilationDiffers(tt.compilation); gotDiffers != tt.wantDiffers {
+				t.Errorf("%s = %v, want %v", fnName, gotDiffers, tt.wantDiffers)
+			}
+			if !reflect.DeepEqual(tt.tM, tt.wantTM) {
+				t.Errorf("%s got TM %v, want TM %v", fnName, tt.tM, tt.wantTM)
+			}
+		})
+	}
+}
+
 func Test_trackMetadata_CanonicalAlbumTitleMatches(t *testing.T) {
 	const fnName = "trackMetadata.CanonicalAlbumTitleMatches()"
 	type args struct {
@@ -1492,6 +1667,10 @@ func TestSourceType_name(t *testing.T) {
 		"undefined": {sT: files.UndefinedSource, want: "undefined"},
 		"ID3V1":     {sT: files.ID3V1, want: "ID3V1"},
 		"ID3V2":     {sT: files.ID3V2, want: "ID3V2"},
+		"APEV2":     {sT: files.APEV2, want: "APEV2"},
+		"FLAC":      {sT: files.FLAC, want: "FLAC"},
+		"MP4":       {sT: files.MP4, want: "MP4"},
+		"OGG":       {sT: files.OGG, want: "OGG"},
 		"total":     {sT: files.TotalSources, want: "total"},
 	}
 	for name, tt := range tests {
@@ -0,0 +1,709 @@
+package files
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// values per ISO/IEC 14496-12 and
+// https://developer.apple.com/documentation/quicktime-file-format
+const (
+	mp4HeaderSize      = 8
+	mp4LargeHeaderSize = 16
+	mp4AlbumKey        = "\xa9alb"
+	mp4AlbumArtistKey  = "aART"
+	mp4ArtistKey       = "\xa9ART"
+	mp4GenreKey        = "\xa9gen"
+	mp4GenreIndexKey   = "gnre"
+	mp4TitleKey        = "\xa9nam"
+	mp4TrackKey        = "trkn"
+	mp4YearKey         = "\xa9day"
+	mp4FreeformKey     = "----"
+	// the data atom's well-known types
+	mp4ImplicitType = 0
+	mp4UTF8Type     = 1
+)
+
+var (
+	// ErrNoMp4Metadata is the error returned when an MP4 file has no iTunes
+	// metadata item list
+	ErrNoMp4Metadata = fmt.Errorf("no MP4 metadata found")
+	// atoms whose content is a sequence of atoms
+	mp4Containers = map[string]bool{
+		"dinf": true,
+		"edts": true,
+		"ilst": true,
+		"mdia": true,
+		"meta": true,
+		"minf": true,
+		"moov": true,
+		"stbl": true,
+		"trak": true,
+		"udta": true,
+	}
+	// maps item list keys to the descriptions used by Track.Details(); aART
+	// corresponds to the ID3V2 TPE2 frame
+	mp4Descriptions = map[string]string{
+		"\xa9wrt":         "Composer",
+		mp4AlbumArtistKey: "Orchestra/Band",
+	}
+)
+
+// mp4Atom is an atom (a box, in ISO terms) in an MP4 file's moov atom; the
+// atoms that lead to the metadata item list and the chunk offset tables are
+// parsed into children, and all others keep their content as raw data
+type mp4Atom struct {
+	kind     string
+	prefix   []byte // the version and flags of an ISO meta atom
+	data     []byte
+	children []*mp4Atom
+}
+
+// mp4Extent records the location of a top-level atom in an MP4 file
+type mp4Extent struct {
+	kind       string
+	offset     int64
+	size       int64
+	toFileEnd  bool
+	headerSize int
+}
+
+// mp4File holds the layout of an MP4 file and the content of its moov atom
+type mp4File struct {
+	brand     string
+	extents   []mp4Extent
+	moov      *mp4Atom
+	moovIndex int
+}
+
+// readMp4Header reads an atom header; available is the number of bytes from
+// the start of the header to the end of the enclosing atom or file
+func readMp4Header(header []byte, available int64) (e mp4Extent, err error) {
+	if len(header) < mp4HeaderSize {
+		err = fmt.Errorf("atom header is truncated")
+		return
+	}
+	e.size = int64(binary.BigEndian.Uint32(header))
+	e.kind = string(header[4:mp4HeaderSize])
+	e.headerSize = mp4HeaderSize
+	switch e.size {
+	case 0:
+		e.size = available
+		e.toFileEnd = true
+	case 1:
+		if len(header) < mp4LargeHeaderSize {
+			err = fmt.Errorf("atom %q header is truncated", mp4AtomName(e.kind))
+			return
+		}
+		e.size = int64(binary.BigEndian.Uint64(header[mp4HeaderSize:]))
+		e.headerSize = mp4LargeHeaderSize
+	}
+	if e.size < int64(e.headerSize) || e.size > available {
+		err = fmt.Errorf("atom %q has an invalid size, %d", mp4AtomName(e.kind), e.size)
+	}
+	return
+}
+
+func parseMp4Atoms(data []byte, inItemList bool) ([]*mp4Atom, error) {
+	var atoms []*mp4Atom
+	for len(data) > 0 {
+		e, err := readMp4Header(data, int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		payload := data[e.headerSize:e.size]
+		atom := &mp4Atom{kind: e.kind}
+		// each item in the item list holds data atoms
+		if inItemList || mp4Containers[e.kind] {
+			// an ISO meta atom has a version and flags before its children; a
+			// QuickTime meta atom does not
+			if e.kind == "meta" && !(len(payload) >= mp4HeaderSize &&
+				string(payload[4:mp4HeaderSize]) == "hdlr") {
+				if len(payload) < 4 {
+					return nil, fmt.Errorf("atom \"meta\" is truncated")
+				}
+				atom.prefix = payload[:4]
+				payload = payload[4:]
+			}
+			if atom.children, err = parseMp4Atoms(payload, e.kind == "ilst"); err != nil {
+				return nil, err
+			}
+		} else {
+			atom.data = payload
+		}
+		atoms = append(atoms, atom)
+		data = data[e.size:]
+	}
+	return atoms, nil
+}
+
+func readMp4File(path string) (*mp4File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mf := &mp4File{moovIndex: -1}
+	for offset := int64(0); offset < stat.Size(); {
+		header := make([]byte, mp4LargeHeaderSize)
+		n, _ := file.ReadAt(header, offset)
+		e, err := readMp4Header(header[:n], stat.Size()-offset)
+		if err != nil {
+			if offset == 0 {
+				return nil, fmt.Errorf("file %q is not an MP4 file", path)
+			}
+			return nil, fmt.Errorf("MP4 file %q is corrupt: %v", path, err)
+		}
+		e.offset = offset
+		payload := func() ([]byte, error) {
+			b := make([]byte, e.size-int64(e.headerSize))
+			_, err := file.ReadAt(b, offset+int64(e.headerSize))
+			return b, err
+		}
+		switch {
+		case offset == 0:
+			if e.kind != "ftyp" {
+				return nil, fmt.Errorf("file %q is not an MP4 file", path)
+			}
+			b, err := payload()
+			if err != nil || len(b) < 4 {
+				return nil, fmt.Errorf("file %q is not an MP4 file", path)
+			}
+			mf.brand = string(b[:4])
+		case e.kind == "moov" && mf.moov == nil:
+			b, err := payload()
+			if err != nil {
+				return nil, err
+			}
+			children, err := parseMp4Atoms(b, false)
+			if err != nil {
+				return nil, fmt.Errorf("MP4 file %q is corrupt: %v", path, err)
+			}
+			mf.moov = &mp4Atom{kind: e.kind, children: children}
+			mf.moovIndex = len(mf.extents)
+		}
+		mf.extents = append(mf.extents, e)
+		offset += e.size
+	}
+	if mf.moov == nil {
+		return nil, fmt.Errorf("MP4 file %q has no moov atom", path)
+	}
+	return mf, nil
+}
+
+func (a *mp4Atom) child(kind string) *mp4Atom {
+	for _, c := range a.children {
+		if c.kind == kind {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *mp4Atom) find(kinds ...string) *mp4Atom {
+	atom := a
+	for _, kind := range kinds {
+		if atom = atom.child(kind); atom == nil {
+			return nil
+		}
+	}
+	return atom
+}
+
+func (a *mp4Atom) removeChild(kind string) {
+	children := a.children[:0]
+	for _, c := range a.children {
+		if c.kind != kind {
+			children = append(children, c)
+		}
+	}
+	a.children = children
+}
+
+// Bytes returns the atom's binary representation
+func (a *mp4Atom) Bytes() []byte {
+	body := append(append([]byte{}, a.prefix...), a.data...)
+	for _, c := range a.children {
+		body = append(body, c.Bytes()...)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(mp4HeaderSize+len(body)))
+	b = append(b, a.kind...)
+	return append(b, body...)
+}
+
+func (mf *mp4File) itemList() (*mp4Atom, error) {
+	if ilst := mf.moov.find("udta", "meta", "ilst"); ilst != nil {
+		return ilst, nil
+	}
+	return nil, ErrNoMp4Metadata
+}
+
+// itemData returns the type and content of the item's first data atom
+func (a *mp4Atom) itemData() (dataType uint32, value []byte, ok bool) {
+	if d := a.child("data"); d != nil && len(d.data) >= 8 {
+		return binary.BigEndian.Uint32(d.data) & 0xffffff, d.data[8:], true
+	}
+	return
+}
+
+func (a *mp4Atom) text(key string) string {
+	if item := a.child(key); item != nil {
+		if dataType, value, ok := item.itemData(); ok && dataType == mp4UTF8Type {
+			return string(value)
+		}
+	}
+	return ""
+}
+
+// setItem replaces the item's data atoms with a single data atom holding the
+// specified value; the item is added to the list if necessary
+func (a *mp4Atom) setItem(key string, dataType uint32, value []byte) {
+	data := binary.BigEndian.AppendUint32(nil, dataType)
+	data = binary.BigEndian.AppendUint32(data, 0) // locale
+	data = append(data, value...)
+	item := a.child(key)
+	if item == nil {
+		item = &mp4Atom{kind: key}
+		a.children = append(a.children, item)
+	}
+	item.children = []*mp4Atom{{kind: "data", data: data}}
+}
+
+// diagnostic describes an item in the metadata item list
+func (a *mp4Atom) diagnostic() string {
+	name := mp4AtomName(a.kind)
+	if a.kind == mp4FreeformKey {
+		// freeform items identify themselves with mean and name atoms
+		parts := []string{name}
+		for _, kind := range []string{"mean", "name"} {
+			if c := a.child(kind); c != nil && len(c.data) >= 4 {
+				parts = append(parts, string(c.data[4:]))
+			}
+		}
+		name = strings.Join(parts, ":")
+	}
+	dataType, value, ok := a.itemData()
+	switch {
+	case !ok:
+		return fmt.Sprintf("%s = <<no data>>", name)
+	case (a.kind == mp4TrackKey || a.kind == "disk") && len(value) >= 6:
+		return fmt.Sprintf("%s = %d/%d", name, binary.BigEndian.Uint16(value[2:]),
+			binary.BigEndian.Uint16(value[4:]))
+	case a.kind == mp4GenreIndexKey && len(value) >= 2:
+		return fmt.Sprintf("%s = %d", name, binary.BigEndian.Uint16(value))
+	case dataType == mp4UTF8Type:
+		return fmt.Sprintf("%s = %q", name, value)
+	default:
+		return fmt.Sprintf("%s = <<%d bytes of binary data>>", name, len(value))
+	}
+}
+
+// mp4AtomName converts an atom type to a printable string; the bytes are Mac
+// OS Roman characters, which agree with Latin-1 for the copyright sign used by
+// iTunes
+func mp4AtomName(kind string) string {
+	runes := make([]rune, 0, len(kind))
+	for _, b := range []byte(kind) {
+		runes = append(runes, rune(b))
+	}
+	return string(runes)
+}
+
+// absorb grows or shrinks padding in the moov atom to make up for a change in
+// size of delta bytes; it returns the part of delta that could not be absorbed
+func (a *mp4Atom) absorb(delta int64) int64 {
+	for _, path := range [][]string{{"udta", "meta", "free"}, {"udta", "free"}, {"free"}} {
+		free := a.find(path...)
+		if free == nil || int64(len(free.data)) < delta {
+			continue
+		}
+		free.data = make([]byte, int64(len(free.data))-delta)
+		return 0
+	}
+	return delta
+}
+
+// adjustChunkOffsets adds delta to each chunk offset at or beyond the specified
+// file offset, as found in the stco and co64 atoms
+func (a *mp4Atom) adjustChunkOffsets(from, delta int64) error {
+	for _, c := range a.children {
+		var entrySize int
+		switch c.kind {
+		case "stco":
+			entrySize = 4
+		case "co64":
+			entrySize = 8
+		default:
+			if err := c.adjustChunkOffsets(from, delta); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(c.data) < 8 {
+			return fmt.Errorf("atom %q is truncated", c.kind)
+		}
+		count := int(binary.BigEndian.Uint32(c.data[4:]))
+		entries := c.data[8:]
+		if count > len(entries)/entrySize {
+			return fmt.Errorf("atom %q is truncated", c.kind)
+		}
+		for k := 0; k < count; k++ {
+			entry := entries[k*entrySize:]
+			if entrySize == 4 {
+				offset := int64(binary.BigEndian.Uint32(entry))
+				if offset >= from {
+					if offset+delta < 0 || offset+delta > math.MaxUint32 {
+						return fmt.Errorf("chunk offset %d cannot be moved by %d bytes", offset,
+							delta)
+					}
+					binary.BigEndian.PutUint32(entry, uint32(offset+delta))
+				}
+			} else if offset := int64(binary.BigEndian.Uint64(entry)); offset >= from {
+				binary.BigEndian.PutUint64(entry, uint64(offset+delta))
+			}
+		}
+	}
+	return nil
+}
+
+// write replaces the file's moov atom with its current content. When the moov
+// atom precedes the media data, a change in its size moves the media data;
+// padding in the moov atom absorbs the change if possible, and otherwise the
+// chunk offsets are adjusted. If the offsets cannot be adjusted, the moov atom
+// is relocated to the end of the file, and its old location becomes padding.
+func (mf *mp4File) write(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	moovExtent := mf.extents[mf.moovIndex]
+	moovEnd := moovExtent.offset + moovExtent.size
+	if moovEnd > int64(len(content)) {
+		return fmt.Errorf("MP4 metadata in file %q has changed", path)
+	}
+	delta := mf.moov.absorb(int64(len(mf.moov.Bytes())) - moovExtent.size)
+	moov := mf.moov.Bytes()
+	if delta == 0 || !mf.mediaDataFollows(moovExtent.offset) {
+		return rewriteFile(path, concatenate(content[:moovExtent.offset], moov,
+			content[moovEnd:]))
+	}
+	if err = mf.moov.adjustChunkOffsets(moovEnd, delta); err == nil {
+		return rewriteFile(path, concatenate(content[:moovExtent.offset], mf.moov.Bytes(),
+			content[moovEnd:]))
+	}
+	if mf.extents[len(mf.extents)-1].toFileEnd {
+		return fmt.Errorf("MP4 file %q cannot be rewritten: %v", path, err)
+	}
+	return rewriteFile(path, concatenate(content[:moovExtent.offset],
+		mp4Padding(moovExtent.size), content[moovEnd:], moov))
+}
+
+// mp4Padding returns a free atom of the specified size
+func mp4Padding(size int64) []byte {
+	var b []byte
+	if size > math.MaxUint32 {
+		b = binary.BigEndian.AppendUint32(b, 1)
+		b = append(b, "free"...)
+		b = binary.BigEndian.AppendUint64(b, uint64(size))
+	} else {
+		b = binary.BigEndian.AppendUint32(b, uint32(size))
+		b = append(b, "free"...)
+	}
+	return append(b, make([]byte, size-int64(len(b)))...)
+}
+
+func (mf *mp4File) mediaDataFollows(offset int64) bool {
+	for _, e := range mf.extents {
+		if e.kind == "mdat" && e.offset > offset {
+			return true
+		}
+	}
+	return false
+}
+
+func concatenate(parts ...[]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += len(part)
+	}
+	b := make([]byte, 0, size)
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// Mp4Metadata holds the metadata values read from an MP4 metadata item list
+type Mp4Metadata struct {
+	albumName   string
+	artistName  string
+	err         error
+	genre       string
+	trackName   string
+	trackNumber int
+	year        string
+}
+
+func (mm *Mp4Metadata) HasError() bool {
+	return mm.err != nil
+}
+
+func (mm *Mp4Metadata) WithAlbumName(s string) *Mp4Metadata {
+	mm.albumName = s
+	return mm
+}
+
+func (mm *Mp4Metadata) WithArtistName(s string) *Mp4Metadata {
+	mm.artistName = s
+	return mm
+}
+
+func (mm *Mp4Metadata) WithErr(e error) *Mp4Metadata {
+	mm.err = e
+	return mm
+}
+
+func (mm *Mp4Metadata) WithGenre(s string) *Mp4Metadata {
+	mm.genre = s
+	return mm
+}
+
+func (mm *Mp4Metadata) WithTrackName(s string) *Mp4Metadata {
+	mm.trackName = s
+	return mm
+}
+
+func (mm *Mp4Metadata) WithTrackNumber(i int) *Mp4Metadata {
+	mm.trackNumber = i
+	return mm
+}
+
+func (mm *Mp4Metadata) WithYear(s string) *Mp4Metadata {
+	mm.year = s
+	return mm
+}
+
+func NewMp4Metadata() *Mp4Metadata {
+	return &Mp4Metadata{}
+}
+
+func newMp4Metadata(ilst *mp4Atom) (mm *Mp4Metadata) {
+	mm = &Mp4Metadata{}
+	if item := ilst.child(mp4TrackKey); item != nil {
+		if _, value, ok := item.itemData(); ok && len(value) >= 4 {
+			mm.trackNumber = int(binary.BigEndian.Uint16(value[2:]))
+		}
+	}
+	if mm.trackNumber == 0 {
+		mm.err = ErrMissingTrackNumber
+		return
+	}
+	mm.albumName = ilst.text(mp4AlbumKey)
+	mm.artistName = ilst.text(mp4ArtistKey)
+	mm.trackName = ilst.text(mp4TitleKey)
+	mm.year = ilst.text(mp4YearKey)
+	mm.genre = ilst.text(mp4GenreKey)
+	if mm.genre == "" {
+		// older files hold the ID3V1 genre index, plus one, instead
+		if item := ilst.child(mp4GenreIndexKey); item != nil {
+			if _, value, ok := item.itemData(); ok && len(value) >= 2 {
+				mm.genre = GenreMap[int(binary.BigEndian.Uint16(value))-1]
+			}
+		}
+	}
+	return
+}
+
+// applyCorrections sets the items for the corrected values recorded in tM
+func (a *mp4Atom) applyCorrections(tM *TrackMetadata, sT SourceType) {
+	if album := tM.correctedAlbumName[sT]; album != "" {
+		a.setItem(mp4AlbumKey, mp4UTF8Type, []byte(album))
+	}
+	if artist := tM.correctedArtistName[sT]; artist != "" {
+		a.setItem(mp4ArtistKey, mp4UTF8Type, []byte(artist))
+	}
+	if title := tM.correctedTrackName[sT]; title != "" {
+		a.setItem(mp4TitleKey, mp4UTF8Type, []byte(title))
+	}
+	if track := tM.correctedTrackNumber[sT]; track != 0 {
+		// preserve the track count
+		value := make([]byte, 8)
+		if item := a.child(mp4TrackKey); item != nil {
+			if _, old, ok := item.itemData(); ok {
+				copy(value, old)
+			}
+		}
+		binary.BigEndian.PutUint16(value[2:], uint16(track))
+		a.setItem(mp4TrackKey, mp4ImplicitType, value)
+	}
+	if genre := tM.correctedGenre[sT]; genre != "" {
+		a.setItem(mp4GenreKey, mp4UTF8Type, []byte(genre))
+		a.removeChild(mp4GenreIndexKey)
+	}
+	if year := tM.correctedYear[sT]; year != "" {
+		a.setItem(mp4YearKey, mp4UTF8Type, []byte(year))
+	}
+}
+
+// MP4 metadata is UTF-8, so names in it are held to the ID3V2 standard
+func Mp4NameDiffers(cS *ComparableStrings) bool {
+	return Id3v2NameDiffers(cS)
+}
+
+func Mp4GenreDiffers(cS *ComparableStrings) bool {
+	return Id3v2GenreDiffers(cS)
+}
+
+func RawReadMp4Metadata(path string) *Mp4Metadata {
+	mf, err := readMp4File(path)
+	if err != nil {
+		return &Mp4Metadata{err: err}
+	}
+	ilst, err := mf.itemList()
+	if err != nil {
+		return &Mp4Metadata{err: err}
+	}
+	return newMp4Metadata(ilst)
+}
+
+// ReadMp4Metadata returns a description of the file's brand, its top-level
+// atoms, and the items in its metadata item list
+func ReadMp4Metadata(path string) ([]string, error) {
+	mf, err := readMp4File(path)
+	if err != nil {
+		return nil, err
+	}
+	output := []string{fmt.Sprintf("Brand: %q", mf.brand)}
+	for _, e := range mf.extents {
+		output = append(output, fmt.Sprintf("Atom: %s (%d bytes)", mp4AtomName(e.kind),
+			e.size))
+	}
+	if ilst, err := mf.itemList(); err == nil {
+		for _, item := range ilst.children {
+			output = append(output, item.diagnostic())
+		}
+	}
+	return output, nil
+}
+
+// ReadMp4Details returns the known details found in the file's metadata item
+// list
+func ReadMp4Details(path string) (map[string]string, error) {
+	mf, err := readMp4File(path)
+	if err != nil {
+		return nil, err
+	}
+	ilst, err := mf.itemList()
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{}
+	for key, description := range mp4Descriptions {
+		if value := ilst.text(key); value != "" {
+			m[description] = value
+		}
+	}
+	return m, nil
+}
+
+func updateMp4Metadata(tM *TrackMetadata, path string, sT SourceType) error {
+	if !tM.requiresEdit[sT] {
+		return nil
+	}
+	mf, err := readMp4File(path)
+	if err != nil {
+		return err
+	}
+	ilst, err := mf.itemList()
+	if err != nil {
+		return err
+	}
+	ilst.applyCorrections(tM, sT)
+	return mf.write(path)
+}
+
+// mp4Source is the MetadataSource for the iTunes metadata item list in MP4
+// files
+type mp4Source struct{}
+
+func init() {
+	registerMetadataSource(mp4Source{})
+}
+
+func (mp4Source) Type() SourceType {
+	return MP4
+}
+
+func (mp4Source) Name() string {
+	return "MP4"
+}
+
+func (mp4Source) Extensions() []string {
+	return []string{".m4a", ".mp4"}
+}
+
+func (mp4Source) Read(path string, tM *TrackMetadata) error {
+	mm := RawReadMp4Metadata(path)
+	if mm.err == nil {
+		tM.SetMp4Values(mm)
+	}
+	return mm.err
+}
+
+func (mp4Source) NameDiffers(cS *ComparableStrings) bool {
+	return Mp4NameDiffers(cS)
+}
+
+func (mp4Source) GenreDiffers(cS *ComparableStrings) bool {
+	return Mp4GenreDiffers(cS)
+}
+
+func (mp4Source) Write(tM *TrackMetadata, path string) error {
+	return updateMp4Metadata(tM, path, MP4)
+}
+
+func (mp4Source) Diagnostics(path string) ([]string, error) {
+	return ReadMp4Metadata(path)
+}
+
+func (mp4Source) Details(path string) (map[string]string, error) {
+	return ReadMp4Details(path)
+}
+
+// WriteAudio writes the content of the media data (mdat) atoms; rewriting the
+// metadata may move those atoms, but does not change their content
+func (mp4Source) WriteAudio(path string, w io.Writer) error {
+	mf, err := readMp4File(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, e := range mf.extents {
+		if e.kind != "mdat" {
+			continue
+		}
+		start := e.offset + int64(e.headerSize)
+		r := io.NewSectionReader(file, start, e.size-int64(e.headerSize))
+		if _, err = io.Copy(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files bought from iTunes always have a metadata item list
+func (mp4Source) MissingError() error {
+	return nil
+}
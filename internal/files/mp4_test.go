@@ -0,0 +1,350 @@
+package files_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+// mp4TestItem is an item in the metadata item list of a test MP4 file
+type mp4TestItem struct {
+	key      string
+	dataType uint32
+	value    []byte
+}
+
+func createMp4Atom(kind string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	atom := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(atom, kind...), body...)
+}
+
+// createMp4Data creates the content of an MP4 file whose metadata item list
+// holds the specified items (the item list is omitted if items is nil),
+// followed by padding of the specified size; if fastStart is true, the moov
+// atom precedes the media data. The file has a single chunk, the audio.
+func createMp4Data(items []mp4TestItem, padding int, fastStart bool, audio []byte) []byte {
+	ftyp := createMp4Atom("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	mdat := createMp4Atom("mdat", audio)
+	moov := func(chunkOffset int) []byte {
+		stco := createMp4Atom("stco", []byte{0, 0, 0, 0, 0, 0, 0, 1},
+			binary.BigEndian.AppendUint32(nil, uint32(chunkOffset)))
+		trak := createMp4Atom("trak", createMp4Atom("mdia", createMp4Atom("minf",
+			createMp4Atom("stbl", stco))))
+		var udta []byte
+		if items != nil {
+			var ilst [][]byte
+			for _, item := range items {
+				data := binary.BigEndian.AppendUint32(nil, item.dataType)
+				data = binary.BigEndian.AppendUint32(data, 0)
+				ilst = append(ilst, createMp4Atom(item.key, createMp4Atom("data", data,
+					item.value)))
+			}
+			hdlr := createMp4Atom("hdlr", make([]byte, 8), []byte("mdirappl"),
+				make([]byte, 9))
+			var free []byte
+			if padding > 0 {
+				free = createMp4Atom("free", make([]byte, padding))
+			}
+			udta = createMp4Atom("udta", createMp4Atom("meta", []byte{0, 0, 0, 0}, hdlr,
+				createMp4Atom("ilst", ilst...), free))
+		}
+		return createMp4Atom("moov", createMp4Atom("mvhd", make([]byte, 100)), trak, udta)
+	}
+	if fastStart {
+		return bytes.Join([][]byte{
+			ftyp, moov(len(ftyp) + len(moov(0)) + 8), mdat,
+		}, nil)
+	}
+	return bytes.Join([][]byte{ftyp, mdat, moov(len(ftyp) + 8)}, nil)
+}
+
+// mp4ChunkOffset returns the location of the first chunk offset table entry,
+// and the entry's value
+func mp4ChunkOffset(content []byte) (int, uint32) {
+	location := bytes.Index(content, []byte("stco")) + 12
+	return location, binary.BigEndian.Uint32(content[location:])
+}
+
+var mp4TestItems = []mp4TestItem{
+	{key: "\xa9alb", dataType: 1, value: []byte("unknown album")},
+	{key: "\xa9ART", dataType: 1, value: []byte("unknown artist")},
+	{key: "gnre", value: []byte{0, 14}},
+	{key: "\xa9nam", dataType: 1, value: []byte("unknown title")},
+	{key: "trkn", value: []byte{0, 0, 0, 2, 0, 12, 0, 0}},
+	{key: "\xa9day", dataType: 1, value: []byte("1900")},
+}
+
+func TestRawReadMp4Metadata(t *testing.T) {
+	const fnName = "RawReadMp4Metadata()"
+	testDir := "rawReadMp4"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	withGenre := append([]mp4TestItem{
+		{key: "\xa9gen", dataType: 1, value: []byte("dance music")},
+	}, mp4TestItems...)
+	fileContents := map[string][]byte{
+		"fastStart.m4a": createMp4Data(mp4TestItems, 0, true, audio),
+		"moovLast.m4a":  createMp4Data(mp4TestItems, 0, false, audio),
+		"genre.m4a":     createMp4Data(withGenre, 0, false, audio),
+		"notMp4.m4a":    audio,
+		"truncated.m4a": createMp4Data(mp4TestItems, 0, false, audio)[:60],
+		"noItems.m4a":   createMp4Data(nil, 0, true, audio),
+		"noTrack.m4a":   createMp4Data(mp4TestItems[:4], 0, true, audio),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	wantData := files.NewMp4Metadata().WithAlbumName("unknown album").WithArtistName(
+		"unknown artist").WithGenre("Pop").WithTrackName(
+		"unknown title").WithTrackNumber(2).WithYear("1900")
+	tests := map[string]struct {
+		path      string
+		want      *files.Mp4Metadata
+		wantError bool
+	}{
+		"no file":        {path: filepath.Join(testDir, "no such file"), wantError: true},
+		"not MP4":        {path: filepath.Join(testDir, "notMp4.m4a"), wantError: true},
+		"truncated":      {path: filepath.Join(testDir, "truncated.m4a"), wantError: true},
+		"fast start":     {path: filepath.Join(testDir, "fastStart.m4a"), want: wantData},
+		"moov atom last": {path: filepath.Join(testDir, "moovLast.m4a"), want: wantData},
+		"preferred genre": {
+			path: filepath.Join(testDir, "genre.m4a"),
+			want: files.NewMp4Metadata().WithAlbumName("unknown album").WithArtistName(
+				"unknown artist").WithGenre("dance music").WithTrackName(
+				"unknown title").WithTrackNumber(2).WithYear("1900"),
+		},
+		"no item list": {
+			path: filepath.Join(testDir, "noItems.m4a"),
+			want: files.NewMp4Metadata().WithErr(files.ErrNoMp4Metadata),
+		},
+		"no track number": {
+			path: filepath.Join(testDir, "noTrack.m4a"),
+			want: files.NewMp4Metadata().WithErr(files.ErrMissingTrackNumber),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := files.RawReadMp4Metadata(tt.path)
+			if tt.wantError {
+				if !got.HasError() {
+					t.Errorf("%s = %#v, want error", fnName, got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMp4Metadata(t *testing.T) {
+	const fnName = "ReadMp4Metadata()"
+	testDir := "readMp4"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	items := append(append([]mp4TestItem{}, mp4TestItems...), mp4TestItem{
+		key: "covr", dataType: 13, value: []byte{1, 2, 3, 4},
+	})
+	content := createMp4Data(items, 16, true, []byte{0, 1, 2, 3})
+	if err := createFileWithContent(testDir, "tagged.m4a", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.m4a", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.m4a"),
+			want: []string{
+				`Brand: "M4A "`,
+				"Atom: ftyp (28 bytes)",
+				"Atom: moov (479 bytes)",
+				"Atom: mdat (12 bytes)",
+				`©alb = "unknown album"`,
+				`©ART = "unknown artist"`,
+				"gnre = 14",
+				`©nam = "unknown title"`,
+				"trkn = 2/12",
+				`©day = "1900"`,
+				"covr = <<4 bytes of binary data>>",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadMp4Metadata(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMp4Details(t *testing.T) {
+	const fnName = "ReadMp4Details()"
+	testDir := "readMp4Details"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	items := append(append([]mp4TestItem{}, mp4TestItems...),
+		mp4TestItem{key: "\xa9wrt", dataType: 1, value: []byte("a couple of idiots")},
+		mp4TestItem{key: "aART", dataType: 1, value: []byte("Various Artists")},
+	)
+	content := createMp4Data(items, 0, true, []byte{0, 1, 2, 3})
+	if err := createFileWithContent(testDir, "tagged.m4a", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.m4a", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    map[string]string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.m4a"),
+			want: map[string]string{
+				"Composer":       "a couple of idiots",
+				"Orchestra/Band": "Various Artists",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadMp4Details(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrack_UpdateMetadataMp4(t *testing.T) {
+	const fnName = "Track.UpdateMetadata()"
+	testDir := "updateMp4"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := []byte("the audio, which must not be lost")
+	unmovable := createMp4Data(mp4TestItems, 0, true, audio)
+	location, _ := mp4ChunkOffset(unmovable)
+	// an offset that cannot be moved without overflowing
+	binary.BigEndian.PutUint32(unmovable[location:], 0xfffffffe)
+	fileContents := map[string][]byte{
+		"padded.m4a":    createMp4Data(mp4TestItems, 64, true, audio),
+		"unpadded.m4a":  createMp4Data(mp4TestItems, 0, true, audio),
+		"moovLast.m4a":  createMp4Data(mp4TestItems, 0, false, audio),
+		"unmovable.m4a": unmovable,
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	newTrack := func(name string) *files.Track {
+		path := filepath.Join(testDir, name)
+		return files.NewEmptyTrack().WithFullPath(path).WithName(
+			"a much longer track title").WithNumber(3).WithAlbum(
+			files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+				"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+				"fine album").WithArtist(files.NewEmptyArtist().WithFileName(
+				"fine artist").WithCanonicalName("fine artist"))).WithMetadata(
+			files.ReadRawMetadata(path, files.NewReadSettings()))
+	}
+	tests := map[string]struct {
+		name            string
+		wantChunkOffset uint32
+		wantAtoms       []string
+	}{
+		"padding absorbs the change": {
+			name:            "padded.m4a",
+			wantChunkOffset: uint32(bytes.Index(fileContents["padded.m4a"], audio)),
+			wantAtoms:       []string{"ftyp", "moov", "mdat"},
+		},
+		"chunk offsets adjusted": {
+			name:      "unpadded.m4a",
+			wantAtoms: []string{"ftyp", "moov", "mdat"},
+		},
+		"moov atom follows the media data": {
+			name:            "moovLast.m4a",
+			wantChunkOffset: uint32(bytes.Index(fileContents["moovLast.m4a"], audio)),
+			wantAtoms:       []string{"ftyp", "mdat", "moov"},
+		},
+		"moov atom relocated": {
+			name:            "unmovable.m4a",
+			wantChunkOffset: 0xfffffffe,
+			wantAtoms:       []string{"ftyp", "free", "mdat", "moov"},
+		},
+	}
+	wantData := files.NewMp4Metadata().WithAlbumName("fine album").WithArtistName(
+		"fine artist").WithGenre("Classic Rock").WithTrackName(
+		"a much longer track title").WithTrackNumber(3).WithYear("2022")
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			track := newTrack(tt.name)
+			if gotE := track.UpdateMetadata(); len(gotE) != 0 {
+				t.Errorf("%s = %v, want no errors", fnName, gotE)
+				return
+			}
+			if got := files.RawReadMp4Metadata(track.Path()); !reflect.DeepEqual(got,
+				wantData) {
+				t.Errorf("%s read %#v, want %#v", fnName, got, wantData)
+			}
+			content, _ := os.ReadFile(track.Path())
+			_, gotChunkOffset := mp4ChunkOffset(content)
+			wantChunkOffset := tt.wantChunkOffset
+			if wantChunkOffset == 0 {
+				wantChunkOffset = uint32(bytes.Index(content, audio))
+			}
+			if gotChunkOffset != wantChunkOffset {
+				t.Errorf("%s chunk offset = %d, want %d", fnName, gotChunkOffset,
+					wantChunkOffset)
+			}
+			lines, _ := files.ReadMp4Metadata(track.Path())
+			var gotAtoms []string
+			for _, line := range lines {
+				if kind, found := bytes.CutPrefix([]byte(line), []byte("Atom: ")); found {
+					gotAtoms = append(gotAtoms, string(kind[:4]))
+				}
+			}
+			if !reflect.DeepEqual(gotAtoms, tt.wantAtoms) {
+				t.Errorf("%s atoms = %v, want %v", fnName, gotAtoms, tt.wantAtoms)
+			}
+			if !bytes.Contains(content, []byte("\x00\x00\x00\x03\x00\x0c")) {
+				t.Errorf("%s did not preserve the track count", fnName)
+			}
+		})
+	}
+}
@@ -0,0 +1,735 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// values per http://www.mp3-tech.org/programmer/frame_header.html,
+// https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header, and
+// http://gabriel.mp3-tech.org/mp3infotag.html
+const (
+	mpegFrameHeaderSize = 4
+	mpegCRCSize         = 2
+	mpegVersion25       = 0
+	mpegVersion2        = 2
+	mpegVersion1        = 3
+	mpegLayer3          = 1
+	mpegLayer2          = 2
+	mpegLayer1          = 3
+	mpegMonoChannelMode = 3
+	id3v2HeaderSize     = 10
+	id3v2FooterFlag     = byte(0x10)
+	lyrics3v2Marker     = "LYRICS200"
+	lyrics3v2SizeLength = 6
+	// the size field and marker that end a Lyrics3 v2 tag are not included in
+	// the size that the tag records
+	lyrics3v2Overhead = lyrics3v2SizeLength + len(lyrics3v2Marker)
+	lyrics3v1Begin    = "LYRICSBEGIN"
+	lyrics3v1End      = "LYRICSEND"
+	// a Lyrics3 v1 tag holds at most 5100 bytes of lyrics between its markers
+	lyrics3v1MaxSize  = len(lyrics3v1Begin) + 5100 + len(lyrics3v1End)
+	xingFramesFlag    = uint32(0x1)
+	xingBytesFlag     = uint32(0x2)
+	xingTOCFlag       = uint32(0x4)
+	xingQualityFlag   = uint32(0x8)
+	xingTOCSize       = 100
+	lameEncoderLength = 9
+	// the VBRI header is always 32 bytes past the frame header
+	vbriOffset = mpegFrameHeaderSize + 32
+	// the number of bytes read at a time from an MPEG audio file
+	mpegWindowSize = 64 * 1024
+)
+
+var (
+	// bitrates, in kbps, indexed by [version is MPEG-1][layer][bitrate index]
+	mpegBitrates = [2][4][16]int{
+		{
+			{},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		},
+		{
+			{},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		},
+	}
+	// sample rates, in Hz, indexed by [version][sample rate index]
+	mpegSampleRates = [4][3]int{
+		mpegVersion25: {11025, 12000, 8000},
+		mpegVersion2:  {22050, 24000, 16000},
+		mpegVersion1:  {44100, 48000, 32000},
+	}
+	mpegVersionNames = map[int]string{
+		mpegVersion25: "MPEG-2.5",
+		mpegVersion2:  "MPEG-2",
+		mpegVersion1:  "MPEG-1",
+	}
+	mpegLayerNames = map[int]string{
+		mpegLayer3: "Layer III",
+		mpegLayer2: "Layer II",
+		mpegLayer1: "Layer I",
+	}
+	mpegChannelModes = []string{"Stereo", "Joint Stereo", "Dual Channel", "Mono"}
+	// maps the VBR method recorded in a LAME tag to the bitrate mode
+	lameBitrateModes = map[byte]string{
+		1: "CBR",
+		2: "ABR",
+		3: "VBR",
+		4: "VBR",
+		5: "VBR",
+		6: "VBR",
+		8: "CBR",
+		9: "ABR",
+	}
+)
+
+// mpegFrame describes an MPEG audio frame, as read from its header
+type mpegFrame struct {
+	version     int
+	layer       int
+	protected   bool
+	bitrate     int
+	sampleRate  int
+	padding     bool
+	channelMode int
+}
+
+// parseMpegFrameHeader parses the 4-byte frame header at the start of b; it
+// returns false if b does not begin with a usable frame header. Free-format
+// bitrates are not supported.
+func parseMpegFrameHeader(b []byte) (*mpegFrame, bool) {
+	if len(b) < mpegFrameHeaderSize || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return nil, false
+	}
+	f := &mpegFrame{
+		version:     int(b[1]>>3) & 0x3,
+		layer:       int(b[1]>>1) & 0x3,
+		protected:   b[1]&0x1 == 0,
+		padding:     b[2]&0x2 != 0,
+		channelMode: int(b[3] >> 6),
+	}
+	bitrateIndex := int(b[2] >> 4)
+	sampleRateIndex := int(b[2]>>2) & 0x3
+	if f.version == 1 || f.layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 ||
+		sampleRateIndex == 3 {
+		return nil, false
+	}
+	mpeg1 := 0
+	if f.version == mpegVersion1 {
+		mpeg1 = 1
+	}
+	f.bitrate = mpegBitrates[mpeg1][f.layer][bitrateIndex]
+	f.sampleRate = mpegSampleRates[f.version][sampleRateIndex]
+	return f, true
+}
+
+// samples returns the number of samples the frame holds
+func (f *mpegFrame) samples() int {
+	switch {
+	case f.layer == mpegLayer1:
+		return 384
+	case f.layer == mpegLayer3 && f.version != mpegVersion1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// size returns the size of the frame in bytes, including its header
+func (f *mpegFrame) size() int {
+	padding := 0
+	if f.padding {
+		padding = 1
+	}
+	if f.layer == mpegLayer1 {
+		return (12*f.bitrate*1000/f.sampleRate + padding) * 4
+	}
+	return f.samples()/8*f.bitrate*1000/f.sampleRate + padding
+}
+
+// sideInfoSize returns the size of the layer III side information that
+// follows the frame header (and CRC, if any); the Xing header follows it
+func (f *mpegFrame) sideInfoSize() int {
+	mono := f.channelMode == mpegMonoChannelMode
+	switch {
+	case f.version == mpegVersion1 && mono:
+		return 17
+	case f.version == mpegVersion1:
+		return 32
+	case mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// matches returns true if the frames could belong to the same stream
+func (f *mpegFrame) matches(other *mpegFrame) bool {
+	return f.version == other.version && f.layer == other.layer &&
+		f.sampleRate == other.sampleRate
+}
+
+func (f *mpegFrame) format() string {
+	return mpegVersionNames[f.version] + " " + mpegLayerNames[f.layer]
+}
+
+// mpegAudio reads the content of an MPEG audio file, and records the bounds of
+// its audio frames, which exclude any ID3V2, APEv2, Lyrics3, and ID3V1 tags;
+// the names of the tags that follow the audio are recorded in file order. The
+// content is read through a window that slides forward as the frames are
+// scanned, so that the file is never read into memory all at once
+type mpegAudio struct {
+	r            io.ReaderAt
+	start        int
+	end          int
+	trailingTags []string
+	window       []byte
+	windowStart  int
+	// the first error encountered reading the content, if any
+	err error
+}
+
+// openMpegAudio opens an MPEG audio file and reads the bounds of its audio;
+// the caller is responsible for closing the file
+func openMpegAudio(path string) (*os.File, *mpegAudio, error) {
+	if isClaimed(path) {
+		return nil, nil, fmt.Errorf("file %q is not an MPEG audio file", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	ma, err := readMpegAudio(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, ma, nil
+}
+
+func readMpegAudio(r io.ReaderAt, size int64) (*mpegAudio, error) {
+	ma := &mpegAudio{r: r, end: int(size)}
+	if h := ma.bytesAt(0, id3v2HeaderSize); len(h) == id3v2HeaderSize && string(h[:3]) == "ID3" {
+		size := int(h[6])<<21 | int(h[7])<<14 | int(h[8])<<7 | int(h[9])
+		ma.start = min(id3v2HeaderSize+size, ma.end)
+		if h[5]&id3v2FooterFlag != 0 {
+			ma.start = min(ma.start+id3v2HeaderSize, ma.end)
+		}
+	}
+	if tag := ma.suffix(id3v1Length); tag != nil && NewID3v1Metadata().WithData(tag).IsValid() {
+		ma.end -= id3v1Length
+		ma.trailingTags = append(ma.trailingTags, "ID3V1")
+	}
+	// a Lyrics3 tag precedes the ID3V1 tag; a v2 tag records its size, while a
+	// v1 tag, which is only found with an ID3V1 tag, must be searched for
+	switch {
+	case bytes.Equal(ma.suffix(len(lyrics3v2Marker)), []byte(lyrics3v2Marker)):
+		if sizeField := ma.suffix(lyrics3v2Overhead); sizeField != nil {
+			if size, err := strconv.Atoi(string(sizeField[:lyrics3v2SizeLength])); err == nil &&
+				size+lyrics3v2Overhead <= ma.end-ma.start {
+				ma.end -= size + lyrics3v2Overhead
+				ma.trailingTags = append([]string{"Lyrics3"}, ma.trailingTags...)
+			}
+		}
+	case bytes.Equal(ma.suffix(len(lyrics3v1End)), []byte(lyrics3v1End)) &&
+		len(ma.trailingTags) > 0:
+		window := ma.suffix(min(lyrics3v1MaxSize, ma.end-ma.start))
+		if begin := bytes.LastIndex(window, []byte(lyrics3v1Begin)); begin != -1 {
+			ma.end -= len(window) - begin
+			ma.trailingTags = append([]string{"Lyrics3"}, ma.trailingTags...)
+		}
+	}
+	// an APEv2 tag precedes both
+	if footer := ma.suffix(apeV2FooterLength); footer != nil &&
+		string(footer[:apeV2VersionOffset]) == apeV2Preamble {
+		size := int(binary.LittleEndian.Uint32(footer[apeV2SizeOffset:]))
+		if binary.LittleEndian.Uint32(footer[apeV2FlagsOffset:])&apeV2HasHeaderFlag != 0 {
+			size += apeV2FooterLength
+		}
+		if size <= ma.end-ma.start {
+			ma.end -= size
+			ma.trailingTags = append([]string{"APEV2"}, ma.trailingTags...)
+		}
+	}
+	return ma, ma.err
+}
+
+// bytesAt returns up to n bytes of the content, starting at the specified
+// offset and stopping at the end of the audio; if the bytes are not in the
+// current window, the window is moved to start at the offset. The window is
+// replaced, not overwritten, so that earlier results remain valid
+func (ma *mpegAudio) bytesAt(offset, n int) []byte {
+	n = min(n, ma.end-offset)
+	if n <= 0 || offset < 0 {
+		return nil
+	}
+	if offset < ma.windowStart || offset+n > ma.windowStart+len(ma.window) {
+		window := make([]byte, min(max(n, mpegWindowSize), ma.end-offset))
+		read, err := ma.r.ReadAt(window, int64(offset))
+		if err != nil && err != io.EOF && ma.err == nil {
+			ma.err = err
+		}
+		ma.window, ma.windowStart = window[:read], offset
+		n = min(n, read)
+	}
+	return ma.window[offset-ma.windowStart : offset-ma.windowStart+n]
+}
+
+// suffix returns the last n bytes of the content between the start and the end
+// of the audio, or nil if there are fewer than n bytes
+func (ma *mpegAudio) suffix(n int) []byte {
+	if ma.end-ma.start < n {
+		return nil
+	}
+	if b := ma.bytesAt(ma.end-n, n); len(b) == n {
+		return b
+	}
+	return nil
+}
+
+// frameAt returns the frame that starts at the specified offset, provided that
+// it ends within the audio
+func (ma *mpegAudio) frameAt(offset int) (*mpegFrame, bool) {
+	f, ok := parseMpegFrameHeader(ma.bytesAt(offset, mpegFrameHeaderSize))
+	if !ok || offset+f.size() > ma.end {
+		return nil, false
+	}
+	return f, true
+}
+
+// firstFrame returns the first frame of the audio and its offset
+func (ma *mpegAudio) firstFrame() (int, *mpegFrame, bool) {
+	return ma.syncFrom(ma.start, nil)
+}
+
+// syncFrom returns the first frame found at or after the specified offset, and
+// its offset; if like is not nil, the frame must belong to the same stream. To
+// avoid being fooled by data that happens to look like a frame header, a frame
+// that does not end the audio must be followed by a frame from the same stream
+func (ma *mpegAudio) syncFrom(start int, like *mpegFrame) (int, *mpegFrame, bool) {
+	for offset := start; offset+mpegFrameHeaderSize <= ma.end; offset++ {
+		f, ok := ma.frameAt(offset)
+		if !ok || (like != nil && !f.matches(like)) {
+			continue
+		}
+		next := offset + f.size()
+		if next == ma.end {
+			return offset, f, true
+		}
+		if nextFrame, ok := ma.frameAt(next); ok && f.matches(nextFrame) {
+			return offset, f, true
+		}
+	}
+	return 0, nil, false
+}
+
+// AudioInfo describes the MPEG audio stream in a track file
+type AudioInfo struct {
+	bitrate     int
+	bitrateMode string
+	channelMode string
+	duration    time.Duration
+	encoder     string
+	format      string
+	frames      int
+	sampleRate  int
+	vbrHeader   string
+	// the number of bytes recorded in a Xing or Info header, if any
+	headerBytes int
+}
+
+// Bitrate returns the stream's bitrate, in kbps; for a variable bitrate
+// stream, it is the average bitrate
+func (ai *AudioInfo) Bitrate() int {
+	return ai.bitrate
+}
+
+// BitrateMode returns "CBR", "VBR", or "ABR"
+func (ai *AudioInfo) BitrateMode() string {
+	return ai.bitrateMode
+}
+
+func (ai *AudioInfo) ChannelMode() string {
+	return ai.channelMode
+}
+
+func (ai *AudioInfo) Duration() time.Duration {
+	return ai.duration
+}
+
+// Encoder returns the encoder recorded in the stream's LAME tag, if any
+func (ai *AudioInfo) Encoder() string {
+	return ai.encoder
+}
+
+// Format returns the MPEG version and layer, e.g., "MPEG-1 Layer III"
+func (ai *AudioInfo) Format() string {
+	return ai.format
+}
+
+func (ai *AudioInfo) Frames() int {
+	return ai.frames
+}
+
+// SampleRate returns the stream's sample rate, in Hz
+func (ai *AudioInfo) SampleRate() int {
+	return ai.sampleRate
+}
+
+// VbrHeader returns the kind of header ("Xing", "Info", or "VBRI") found in the
+// stream's first frame, if any
+func (ai *AudioInfo) VbrHeader() string {
+	return ai.vbrHeader
+}
+
+// FormatDuration formats a duration as minutes and seconds, or as hours,
+// minutes, and seconds if it is an hour or longer
+func FormatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+func (ai *AudioInfo) details() map[string]string {
+	m := map[string]string{
+		"Audio Format": ai.format,
+		"Bitrate":      fmt.Sprintf("%d kbps", ai.bitrate),
+		"Bitrate Mode": ai.bitrateMode,
+		"Channel Mode": ai.channelMode,
+		"Duration":     FormatDuration(ai.duration),
+		"Sample Rate":  fmt.Sprintf("%d Hz", ai.sampleRate),
+	}
+	if ai.vbrHeader != "" {
+		m["VBR Header"] = ai.vbrHeader
+	}
+	if ai.encoder != "" {
+		m["Encoder"] = ai.encoder
+	}
+	return m
+}
+
+// setTotals sets the duration and bitrate from the number of frames and the
+// number of bytes they occupy
+func (ai *AudioInfo) setTotals(frames, size, samplesPerFrame int) {
+	ai.frames = frames
+	samples := int64(frames) * int64(samplesPerFrame)
+	if samples == 0 {
+		return
+	}
+	ai.duration = time.Duration(samples) * time.Second / time.Duration(ai.sampleRate)
+	ai.bitrate = int((int64(size)*8*int64(ai.sampleRate) + samples*500) / (samples * 1000))
+}
+
+// readVbrHeader reads the Xing (or Info) header, and the LAME tag that may
+// follow it, or the VBRI header, from the first frame; it returns false if the
+// frame has neither header, or if the header does not record the number of
+// frames
+func (ai *AudioInfo) readVbrHeader(frame []byte, f *mpegFrame) bool {
+	offset := mpegFrameHeaderSize + f.sideInfoSize()
+	if f.protected {
+		offset += mpegCRCSize
+	}
+	if len(frame) >= offset+8 {
+		if kind := string(frame[offset : offset+4]); kind == "Xing" || kind == "Info" {
+			ai.vbrHeader = kind
+			return ai.readXingHeader(frame[offset+4:], f)
+		}
+	}
+	if len(frame) >= vbriOffset+18 && string(frame[vbriOffset:vbriOffset+4]) == "VBRI" {
+		ai.vbrHeader = "VBRI"
+		ai.bitrateMode = "VBR"
+		b := frame[vbriOffset:]
+		ai.setTotals(int(binary.BigEndian.Uint32(b[14:])),
+			int(binary.BigEndian.Uint32(b[10:])), f.samples())
+		return ai.frames != 0
+	}
+	return false
+}
+
+func (ai *AudioInfo) readXingHeader(b []byte, f *mpegFrame) bool {
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	frames, size := 0, 0
+	for _, field := range []struct {
+		flag  uint32
+		size  int
+		value *int
+	}{
+		{flag: xingFramesFlag, size: 4, value: &frames},
+		{flag: xingBytesFlag, size: 4, value: &size},
+		{flag: xingTOCFlag, size: xingTOCSize},
+		{flag: xingQualityFlag, size: 4},
+	} {
+		if flags&field.flag == 0 {
+			continue
+		}
+		if len(b) < field.size {
+			return false
+		}
+		if field.value != nil {
+			*field.value = int(binary.BigEndian.Uint32(b))
+		}
+		b = b[field.size:]
+	}
+	ai.bitrateMode = "VBR"
+	if ai.vbrHeader == "Info" {
+		ai.bitrateMode = "CBR"
+	}
+	if len(b) > lameEncoderLength {
+		if encoder := lameEncoder(b[:lameEncoderLength]); encoder != "" {
+			ai.encoder = encoder
+			if mode, ok := lameBitrateModes[b[lameEncoderLength]&0xf]; ok {
+				ai.bitrateMode = mode
+			}
+		}
+	}
+	ai.headerBytes = size
+	if frames == 0 {
+		return false
+	}
+	if size == 0 {
+		// without a byte count, assume every frame is the size of the first
+		size = frames * f.size()
+	}
+	ai.setTotals(frames, size, f.samples())
+	if ai.bitrateMode == "CBR" {
+		ai.bitrate = f.bitrate
+	}
+	return true
+}
+
+// lameEncoder returns the encoder name at the start of a LAME tag, or "" if the
+// bytes are not printable
+func lameEncoder(b []byte) string {
+	for _, c := range b {
+		if c != 0 && (c < ' ' || c > '~') {
+			return ""
+		}
+	}
+	return strings.TrimSpace(string(bytes.TrimRight(b, "\x00")))
+}
+
+// walk reads every frame from the specified offset until the end of the audio
+// or a loss of synchronization, to determine the stream's totals
+func (ai *AudioInfo) walk(ma *mpegAudio, offset int, first *mpegFrame) {
+	frames, size := 0, 0
+	bitrates := map[int]bool{}
+	for offset < ma.end {
+		f, ok := ma.frameAt(offset)
+		if !ok || !f.matches(first) {
+			break
+		}
+		frames++
+		size += f.size()
+		bitrates[f.bitrate] = true
+		offset += f.size()
+	}
+	ai.bitrateMode = "CBR"
+	if len(bitrates) > 1 {
+		ai.bitrateMode = "VBR"
+	}
+	ai.setTotals(frames, size, first.samples())
+}
+
+// ReadAudioInfo reads the MPEG audio frames in a track file; a Xing, Info, or
+// VBRI header in the first frame is trusted to describe the stream, and in its
+// absence, every frame is read
+func ReadAudioInfo(path string) (*AudioInfo, error) {
+	file, ma, err := openMpegAudio(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	offset, first, ok := ma.firstFrame()
+	if !ok {
+		if ma.err != nil {
+			return nil, ma.err
+		}
+		return nil, fmt.Errorf("no MPEG audio frames found in file %q", path)
+	}
+	ai := &AudioInfo{
+		channelMode: mpegChannelModes[first.channelMode],
+		format:      first.format(),
+		sampleRate:  first.sampleRate,
+	}
+	frame := ma.bytesAt(offset, first.size())
+	if first.layer != mpegLayer3 || !ai.readVbrHeader(frame, first) {
+		ai.walk(ma, offset, first)
+	}
+	if ma.err != nil {
+		return nil, ma.err
+	}
+	return ai, nil
+}
+
+// crcMatches returns true if the frame at the specified offset is unprotected,
+// or if its CRC matches the CRC computed over the last two bytes of its header
+// and its side information; only Layer III frames are checked, as the extent of
+// the protected data in Layer I and Layer II frames depends on their bit
+// allocation
+func (ma *mpegAudio) crcMatches(offset int, f *mpegFrame) bool {
+	if !f.protected || f.layer != mpegLayer3 {
+		return true
+	}
+	sideInfo := offset + mpegFrameHeaderSize + mpegCRCSize
+	if sideInfo+f.sideInfoSize() > offset+f.size() {
+		return false
+	}
+	stored := binary.BigEndian.Uint16(ma.bytesAt(offset+mpegFrameHeaderSize, mpegCRCSize))
+	return stored == mpegCRC(ma.bytesAt(offset+2, mpegFrameHeaderSize-2),
+		ma.bytesAt(sideInfo, f.sideInfoSize()))
+}
+
+// mpegCRC computes the CRC-16 (polynomial 0x8005, initial value 0xffff) that
+// protects MPEG audio frames
+func mpegCRC(data ...[]byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		for _, c := range b {
+			crc ^= uint16(c) << 8
+			for range 8 {
+				if crc&0x8000 != 0 {
+					crc = crc<<1 ^ 0x8005
+				} else {
+					crc <<= 1
+				}
+			}
+		}
+	}
+	return crc
+}
+
+// CheckAudioIntegrity walks every MPEG audio frame in a track file and returns
+// a description of each problem found: a loss of synchronization, a truncated
+// final frame, a failed CRC check, or a frame or byte count that disagrees with
+// the counts recorded in the stream's Xing (or Info) header
+func CheckAudioIntegrity(path string) ([]string, error) {
+	file, ma, err := openMpegAudio(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	offset, first, ok := ma.firstFrame()
+	if !ok {
+		if ma.err != nil {
+			return nil, ma.err
+		}
+		return []string{"no MPEG audio frames found"}, nil
+	}
+	var problems []string
+	header := &AudioInfo{sampleRate: first.sampleRate}
+	hasXing := first.layer == mpegLayer3 &&
+		header.readVbrHeader(ma.bytesAt(offset, first.size()), first) &&
+		header.vbrHeader != "VBRI"
+	frames, size := 0, 0
+	for offset < ma.end {
+		f, ok := parseMpegFrameHeader(ma.bytesAt(offset, mpegFrameHeaderSize))
+		if ok && f.matches(first) {
+			if offset+f.size() > ma.end {
+				problems = append(problems, fmt.Sprintf(
+					"the final frame, at offset %d, is truncated: %d of %d bytes are present",
+					offset, ma.end-offset, f.size()))
+				break
+			}
+			if !ma.crcMatches(offset, f) {
+				problems = append(problems, fmt.Sprintf(
+					"the frame at offset %d fails its CRC check", offset))
+			}
+			frames++
+			size += f.size()
+			offset += f.size()
+			continue
+		}
+		next, _, found := ma.syncFrom(offset+1, first)
+		if !found {
+			problems = append(problems, fmt.Sprintf(
+				"lost sync at offset %d; no further frames found in the remaining %d bytes",
+				offset, ma.end-offset))
+			break
+		}
+		problems = append(problems, fmt.Sprintf(
+			"lost sync at offset %d; regained sync after skipping %d bytes", offset, next-offset))
+		offset = next
+	}
+	if hasXing {
+		// the frame count recorded in a Xing header excludes the header's own
+		// frame
+		if header.frames != frames-1 {
+			problems = append(problems, fmt.Sprintf(
+				"the %s header records %d frames, but %d were found",
+				header.vbrHeader, header.frames, frames-1))
+		}
+		if header.headerBytes != 0 && header.headerBytes != size {
+			problems = append(problems, fmt.Sprintf(
+				"the %s header records %d bytes, but the frames occupy %d bytes",
+				header.vbrHeader, header.headerBytes, size))
+		}
+	}
+	if ma.err != nil {
+		return nil, ma.err
+	}
+	return problems, nil
+}
+
+// writeMpegAudio writes the audio frames, starting with the first frame and
+// excluding any tags; if no frame can be found, everything between the tags is
+// written
+func writeMpegAudio(path string, w io.Writer) error {
+	file, ma, err := openMpegAudio(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	start := ma.start
+	if offset, _, ok := ma.firstFrame(); ok {
+		start = offset
+	}
+	if ma.err != nil {
+		return ma.err
+	}
+	_, err = io.Copy(w, io.NewSectionReader(file, int64(start), int64(ma.end-start)))
+	return err
+}
+
+// TrailingTags returns the names of the tags, such as an ID3V1 tag, that follow
+// the audio in an MPEG audio file
+func TrailingTags(path string) ([]string, error) {
+	file, ma, err := openMpegAudio(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ma.trailingTags, nil
+}
+
+// StripTrailingTags removes the tags that follow the audio in an MPEG audio
+// file
+func StripTrailingTags(path string) error {
+	file, ma, err := openMpegAudio(path)
+	if err != nil {
+		return err
+	}
+	// the file is closed before it is truncated
+	file.Close()
+	if len(ma.trailingTags) == 0 {
+		return nil
+	}
+	return os.Truncate(path, int64(ma.end))
+}
@@ -0,0 +1,426 @@
+package files_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+// createMpegFrame creates an unpadded, unprotected MPEG-1 Layer III joint
+// stereo frame at 44100 Hz, beginning with the specified payload
+func createMpegFrame(bitrate int, payload []byte) []byte {
+	bitrateIndexes := map[int]byte{128: 9, 192: 11, 320: 14}
+	frame := make([]byte, 144*bitrate*1000/44100)
+	copy(frame, []byte{0xff, 0xfb, bitrateIndexes[bitrate] << 4, 0x40})
+	copy(frame[4:], payload)
+	return frame
+}
+
+// createXingFrame creates a frame holding a Xing (or Info) header that records
+// the number of frames and bytes, followed by a LAME tag
+func createXingFrame(kind string, frames, size uint32, method byte) []byte {
+	payload := make([]byte, 32, 200)
+	payload = append(payload, kind...)
+	payload = binary.BigEndian.AppendUint32(payload, 0xf)
+	payload = binary.BigEndian.AppendUint32(payload, frames)
+	payload = binary.BigEndian.AppendUint32(payload, size)
+	payload = append(payload, make([]byte, 104)...)
+	payload = append(payload, "LAME3.100"...)
+	payload = append(payload, 0x10|method)
+	return createMpegFrame(128, payload)
+}
+
+func TestReadAudioInfo(t *testing.T) {
+	const fnName = "ReadAudioInfo()"
+	testDir := "readAudioInfo"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	var cbr, vbr, long []byte
+	// spans several of the windows through which the file is read
+	for k := 0; k < 1000; k++ {
+		long = append(long, createMpegFrame(128, nil)...)
+	}
+	for k := 0; k < 100; k++ {
+		cbr = append(cbr, createMpegFrame(128, nil)...)
+		if k%2 == 0 {
+			vbr = append(vbr, createMpegFrame(320, nil)...)
+		} else {
+			vbr = append(vbr, createMpegFrame(192, nil)...)
+		}
+	}
+	vbri := make([]byte, 32, 64)
+	vbri = append(vbri, "VBRI"...)
+	vbri = append(vbri, 0, 1, 0, 0, 0, 50)
+	vbri = binary.BigEndian.AppendUint32(vbri, 1_000_000)
+	vbri = binary.BigEndian.AppendUint32(vbri, 38_281)
+	fileContents := map[string][]byte{
+		"cbr.mp3":  cbr,
+		"long.mp3": append(long, id3v1DataSet1...),
+		// leading garbage, an ID3V2 tag, and an ID3V1 tag
+		"tagged.mp3": append(createID3v2TaggedData(append([]byte{0xff, 0xfb, 0, 0}, cbr...),
+			map[string]string{"TIT2": "my track"}), id3v1DataSet1...),
+		"vbr.mp3":  vbr,
+		"xing.mp3": append(createXingFrame("Xing", 38_281, 10_000_000, 4), vbr...),
+		"info.mp3": append(createXingFrame("Info", 100, 41_700, 1), cbr...),
+		"vbri.mp3": append(createMpegFrame(128, vbri), vbr...),
+		"noise.mp3": {
+			0xff, 0xfb, 0x90, 0x40, 0, 0, 0, 0, 0xff, 0xfb, 0x90, 0x40, 1, 2, 3, 4, 5,
+		},
+		"cbr.flac": cbr,
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	type info struct {
+		bitrate     int
+		bitrateMode string
+		channelMode string
+		duration    time.Duration
+		encoder     string
+		format      string
+		frames      int
+		sampleRate  int
+		vbrHeader   string
+	}
+	cbrInfo := info{
+		bitrate:     128,
+		bitrateMode: "CBR",
+		channelMode: "Joint Stereo",
+		duration:    100 * 1152 * time.Second / 44100,
+		format:      "MPEG-1 Layer III",
+		frames:      100,
+		sampleRate:  44100,
+	}
+	tests := map[string]struct {
+		name    string
+		want    info
+		wantErr bool
+	}{
+		"no file":        {name: "no such file", wantErr: true},
+		"not MPEG audio": {name: "noise.mp3", wantErr: true},
+		"claimed":        {name: "cbr.flac", wantErr: true},
+		"CBR":            {name: "cbr.mp3", want: cbrInfo},
+		"tagged":         {name: "tagged.mp3", want: cbrInfo},
+		"long": {
+			name: "long.mp3",
+			want: info{
+				bitrate:     128,
+				bitrateMode: "CBR",
+				channelMode: "Joint Stereo",
+				duration:    1000 * 1152 * time.Second / 44100,
+				format:      "MPEG-1 Layer III",
+				frames:      1000,
+				sampleRate:  44100,
+			},
+		},
+		"VBR": {
+			name: "vbr.mp3",
+			want: info{
+				bitrate:     256,
+				bitrateMode: "VBR",
+				channelMode: "Joint Stereo",
+				duration:    100 * 1152 * time.Second / 44100,
+				format:      "MPEG-1 Layer III",
+				frames:      100,
+				sampleRate:  44100,
+			},
+		},
+		"Xing": {
+			name: "xing.mp3",
+			want: info{
+				bitrate:     80,
+				bitrateMode: "VBR",
+				channelMode: "Joint Stereo",
+				duration:    38_281 * 1152 * time.Second / 44100,
+				encoder:     "LAME3.100",
+				format:      "MPEG-1 Layer III",
+				frames:      38_281,
+				sampleRate:  44100,
+				vbrHeader:   "Xing",
+			},
+		},
+		"Info": {
+			name: "info.mp3",
+			want: info{
+				bitrate:     128,
+				bitrateMode: "CBR",
+				channelMode: "Joint Stereo",
+				duration:    100 * 1152 * time.Second / 44100,
+				encoder:     "LAME3.100",
+				format:      "MPEG-1 Layer III",
+				frames:      100,
+				sampleRate:  44100,
+				vbrHeader:   "Info",
+			},
+		},
+		"VBRI": {
+			name: "vbri.mp3",
+			want: info{
+				bitrate:     8,
+				bitrateMode: "VBR",
+				channelMode: "Joint Stereo",
+				duration:    38_281 * 1152 * time.Second / 44100,
+				format:      "MPEG-1 Layer III",
+				frames:      38_281,
+				sampleRate:  44100,
+				vbrHeader:   "VBRI",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ai, err := files.ReadAudioInfo(filepath.Join(testDir, tt.name))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			got := info{
+				bitrate:     ai.Bitrate(),
+				bitrateMode: ai.BitrateMode(),
+				channelMode: ai.ChannelMode(),
+				duration:    ai.Duration(),
+				encoder:     ai.Encoder(),
+				format:      ai.Format(),
+				frames:      ai.Frames(),
+				sampleRate:  ai.SampleRate(),
+				vbrHeader:   ai.VbrHeader(),
+			}
+			if got != tt.want {
+				t.Errorf("%s = %+v, want %+v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	const fnName = "FormatDuration()"
+	tests := map[string]struct {
+		d    time.Duration
+		want string
+	}{
+		"zero":     {d: 0, want: "0:00"},
+		"rounded":  {d: 3*time.Minute + 4500*time.Millisecond, want: "3:05"},
+		"long":     {d: 59*time.Minute + 59*time.Second, want: "59:59"},
+		"an hour":  {d: time.Hour, want: "1:00:00"},
+		"a CD set": {d: 2*time.Hour + 3*time.Minute + 7*time.Second, want: "2:03:07"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := files.FormatDuration(tt.d); got != tt.want {
+				t.Errorf("%s = %q, want %q", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+// mpegTestCRC computes the CRC-16 that protects MPEG audio frames
+func mpegTestCRC(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, c := range data {
+		for bit := 7; bit >= 0; bit-- {
+			feedback := crc>>15 ^ uint16(c>>bit)&1
+			crc <<= 1
+			if feedback != 0 {
+				crc ^= 0x8005
+			}
+		}
+	}
+	return crc
+}
+
+// createProtectedMpegFrame creates a CRC-protected variant of the frame created
+// by createMpegFrame; if corrupt is true, the recorded CRC is wrong
+func createProtectedMpegFrame(bitrate int, corrupt bool) []byte {
+	frame := createMpegFrame(bitrate, nil)
+	frame[1] = 0xfa
+	crc := mpegTestCRC(append([]byte{frame[2], frame[3]}, frame[6:6+32]...))
+	if corrupt {
+		crc++
+	}
+	binary.BigEndian.PutUint16(frame[4:], crc)
+	return frame
+}
+
+func TestCheckAudioIntegrity(t *testing.T) {
+	const fnName = "CheckAudioIntegrity()"
+	testDir := "checkAudioIntegrity"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	var cbr, vbr, protected []byte
+	for k := 0; k < 10; k++ {
+		cbr = append(cbr, createMpegFrame(128, nil)...)
+		protected = append(protected, createProtectedMpegFrame(128, k == 4)...)
+	}
+	for k := 0; k < 100; k++ {
+		if k%2 == 0 {
+			vbr = append(vbr, createMpegFrame(320, nil)...)
+		} else {
+			vbr = append(vbr, createMpegFrame(192, nil)...)
+		}
+	}
+	fileContents := map[string][]byte{
+		"cbr.mp3":       cbr,
+		"gap.mp3":       append(append(append([]byte{}, cbr...), make([]byte, 100)...), cbr...),
+		"junk.mp3":      append(append([]byte{}, cbr...), make([]byte, 50)...),
+		"truncated.mp3": append(append([]byte{}, cbr...), cbr[:200]...),
+		"protected.mp3": protected,
+		"xing.mp3":      append(createXingFrame("Xing", 38_281, 10_000_000, 4), vbr...),
+		"info.mp3":      append(createXingFrame("Info", 10, 11*417, 1), cbr...),
+		"noise.mp3":     {0xff, 0xfb, 0x90, 0x40, 0, 0, 0, 0, 1, 2, 3, 4, 5},
+		"cbr.flac":      cbr,
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		"no file":   {name: "no such file", wantErr: true},
+		"claimed":   {name: "cbr.flac", wantErr: true},
+		"clean":     {name: "cbr.mp3"},
+		"no frames": {name: "noise.mp3", want: []string{"no MPEG audio frames found"}},
+		"gap": {
+			name: "gap.mp3",
+			want: []string{"lost sync at offset 4170; regained sync after skipping 100 bytes"},
+		},
+		"trailing junk": {
+			name: "junk.mp3",
+			want: []string{
+				"lost sync at offset 4170; no further frames found in the remaining 50 bytes",
+			},
+		},
+		"truncated": {
+			name: "truncated.mp3",
+			want: []string{
+				"the final frame, at offset 4170, is truncated: 200 of 417 bytes are present",
+			},
+		},
+		"bad CRC": {
+			name: "protected.mp3",
+			want: []string{"the frame at offset 1668 fails its CRC check"},
+		},
+		"Xing mismatch": {
+			name: "xing.mp3",
+			want: []string{
+				"the Xing header records 38281 frames, but 100 were found",
+				"the Xing header records 10000000 bytes, but the frames occupy 83917 bytes",
+			},
+		},
+		"Info match": {name: "info.mp3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.CheckAudioIntegrity(filepath.Join(testDir, tt.name))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripTrailingTags(t *testing.T) {
+	const fnName = "StripTrailingTags()"
+	testDir := "stripTrailingTags"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := createID3v2TaggedData(createMpegFrame(128, nil),
+		map[string]string{"TIT2": "my track"})
+	lyrics := []byte("LYRICSBEGINLYR00005hello")
+	lyrics = append(lyrics, fmt.Sprintf("%06d", len(lyrics))...)
+	lyrics = append(lyrics, "LYRICS200"...)
+	lyricsV1 := []byte("LYRICSBEGINhello\r\nLYRICSEND")
+	ape := createApeV2TaggedData(map[string]string{"Title": "my track"}, true)
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	fileContents := map[string][]byte{
+		"clean.mp3":   audio,
+		"id3v1.mp3":   join(audio, id3v1DataSet1),
+		"lyrics.mp3":  join(audio, lyrics, id3v1DataSet1),
+		"all.mp3":     join(audio, ape, lyrics, id3v1DataSet1),
+		"lyrics1.mp3": join(audio, lyricsV1, id3v1DataSet1),
+		"all1.mp3":    join(audio, ape, lyricsV1, id3v1DataSet1),
+		"ape.mp3":     join(audio, ape),
+		"audio.flac":  join(audio, id3v1DataSet1),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name     string
+		wantTags []string
+		wantErr  bool
+	}{
+		"no file":        {name: "no such file", wantErr: true},
+		"not MPEG audio": {name: "audio.flac", wantErr: true},
+		"no tags":        {name: "clean.mp3"},
+		"ID3V1":          {name: "id3v1.mp3", wantTags: []string{"ID3V1"}},
+		"Lyrics3":        {name: "lyrics.mp3", wantTags: []string{"Lyrics3", "ID3V1"}},
+		"Lyrics3 v1":     {name: "lyrics1.mp3", wantTags: []string{"Lyrics3", "ID3V1"}},
+		"APEV2":          {name: "ape.mp3", wantTags: []string{"APEV2"}},
+		"all": {
+			name:     "all.mp3",
+			wantTags: []string{"APEV2", "Lyrics3", "ID3V1"},
+		},
+		"all, Lyrics3 v1": {
+			name:     "all1.mp3",
+			wantTags: []string{"APEV2", "Lyrics3", "ID3V1"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(testDir, tt.name)
+			gotTags, err := files.TrailingTags(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TrailingTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotTags, tt.wantTags) {
+				t.Errorf("TrailingTags() = %v, want %v", gotTags, tt.wantTags)
+			}
+			if err = files.StripTrailingTags(path); (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, audio) {
+				t.Errorf("%s left %d bytes, want %d", fnName, len(got), len(audio))
+			}
+		})
+	}
+}
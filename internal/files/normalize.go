@@ -0,0 +1,570 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+const (
+	id3v2UnsynchronisationFlag = byte(0x80)
+	id3v22CompressionFlag      = byte(0x40)
+	id3v22FrameHeaderSize      = 6
+)
+
+// id3v2EncodingNames pairs the names by which the text encodings are
+// configured with the encodings themselves
+var id3v2EncodingNames = []struct {
+	name     string
+	encoding id3v2.Encoding
+}{
+	{name: "ISO-8859-1", encoding: id3v2.EncodingISO},
+	{name: "UTF-16", encoding: id3v2.EncodingUTF16},
+	{name: "UTF-16BE", encoding: id3v2.EncodingUTF16BE},
+	{name: "UTF-8", encoding: id3v2.EncodingUTF8},
+}
+
+// Id3v2EncodingNames returns the names of the text encodings that can be used
+// in an ID3V2 tag
+func Id3v2EncodingNames() []string {
+	names := make([]string, 0, len(id3v2EncodingNames))
+	for _, e := range id3v2EncodingNames {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+func encodingName(e id3v2.Encoding) string {
+	for _, candidate := range id3v2EncodingNames {
+		if candidate.encoding.Equals(e) {
+			return candidate.name
+		}
+	}
+	return e.Name
+}
+
+// Id3v2Target is the ID3V2 version and text encoding to which ID3V2 tags are
+// normalized
+type Id3v2Target struct {
+	version  byte
+	encoding id3v2.Encoding
+}
+
+// NewId3v2Target returns a target for ID3V2 version 2.3 or 2.4; ID3V2.3 only
+// supports the ISO-8859-1 and UTF-16 encodings
+func NewId3v2Target(version int, encoding string) (*Id3v2Target, error) {
+	if version != 3 && version != 4 {
+		return nil, fmt.Errorf("ID3V2 tags cannot be written as version 2.%d", version)
+	}
+	for _, e := range id3v2EncodingNames {
+		if strings.EqualFold(e.name, encoding) {
+			if version == 3 && e.encoding.Key > id3v2.EncodingUTF16.Key {
+				return nil, fmt.Errorf("ID3V2.3 tags cannot use the %s encoding", e.name)
+			}
+			return &Id3v2Target{version: byte(version), encoding: e.encoding}, nil
+		}
+	}
+	return nil, fmt.Errorf("ID3V2 tags cannot use the %q encoding", encoding)
+}
+
+func (target *Id3v2Target) Version() int {
+	return int(target.version)
+}
+
+func (target *Id3v2Target) Encoding() string {
+	return encodingName(target.encoding)
+}
+
+// id3v2Frame is a frame and the ID under which it is written
+type id3v2Frame struct {
+	id     string
+	framer id3v2.Framer
+}
+
+// id3v2Normalization is a file's content, the frames with which its ID3V2 tag
+// is to be rewritten, and a description of each change that rewriting the tag
+// makes
+type id3v2Normalization struct {
+	content []byte
+	tagEnd  int
+	frames  []id3v2Frame
+	changes []string
+}
+
+func (n *id3v2Normalization) addChange(format string, a ...any) {
+	change := fmt.Sprintf(format, a...)
+	if !slices.Contains(n.changes, change) {
+		n.changes = append(n.changes, change)
+	}
+}
+
+// frames that only exist in one of the two versions; TYER, TDAT, TIME, and TORY
+// are converted to TDRC and TDOR, and back, before these are removed
+var id3v2VersionOnlyFrames = map[byte][]string{
+	3: {"EQUA", "IPLS", "RVAD", "TDAT", "TIME", "TORY", "TRDA", "TSIZ", "TYER"},
+	4: {
+		"ASPI", "EQU2", "RVA2", "SEEK", "SIGN", "TDEN", "TDOR", "TDRC", "TDRL", "TDTG",
+		"TIPL", "TMCL", "TMOO", "TPRO", "TSOA", "TSOP", "TSOT", "TSST",
+	},
+}
+
+// frames whose first byte is a text encoding, but which the id3v2 library does
+// not parse
+var id3v2EncodedFrames = []string{"COMR", "GEOB", "OWNE", "SYLT", "USER", "WXXX"}
+
+// planNormalization reads the file's ID3V2 tag and determines how its frames
+// must change to be written in the target's version and encoding; a file
+// without an ID3V2 tag needs no changes
+func (target *Id3v2Target) planNormalization(path string) (*id3v2Normalization, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	n := &id3v2Normalization{content: content}
+	if len(content) < id3v2HeaderSize || string(content[:3]) != "ID3" {
+		return n, nil
+	}
+	h := content[:id3v2HeaderSize]
+	size := int(h[6])<<21 | int(h[7])<<14 | int(h[8])<<7 | int(h[9])
+	framesEnd := min(id3v2HeaderSize+size, len(content))
+	n.tagEnd = framesEnd
+	if h[5]&id3v2FooterFlag != 0 {
+		n.tagEnd = min(n.tagEnd+id3v2HeaderSize, len(content))
+	}
+	version := h[3]
+	var frames []id3v2Frame
+	switch version {
+	case 2:
+		if frames, err = readID3V22Frames(h[5], content[id3v2HeaderSize:framesEnd]); err != nil {
+			return nil, err
+		}
+	case 3, 4:
+		tag, err := id3v2.ParseReader(bytes.NewReader(content[:n.tagEnd]),
+			id3v2.Options{Parse: true})
+		if err != nil {
+			return nil, err
+		}
+		frames = sortedFrames(tag.AllFrames())
+	default:
+		return nil, fmt.Errorf("ID3V2 version 2.%d is not supported", version)
+	}
+	if version != target.version {
+		n.addChange("the ID3V2 tag will be converted from version 2.%d to version 2.%d",
+			version, target.version)
+	}
+	target.convertDates(n, frames)
+	n.frames = slices.DeleteFunc(n.frames, func(f id3v2Frame) bool {
+		return !target.represents(n, f)
+	})
+	for k, f := range n.frames {
+		n.frames[k].framer = target.reencode(n, f)
+	}
+	n.frames = slices.DeleteFunc(n.frames, func(f id3v2Frame) bool {
+		return f.framer == nil
+	})
+	slices.SortStableFunc(n.frames, func(a, b id3v2Frame) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return n, nil
+}
+
+func sortedFrames(m map[string][]id3v2.Framer) []id3v2Frame {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var frames []id3v2Frame
+	for _, id := range ids {
+		for _, framer := range m[id] {
+			frames = append(frames, id3v2Frame{id: id, framer: framer})
+		}
+	}
+	return frames
+}
+
+func frameText(frames []id3v2Frame, id string) (string, bool) {
+	for _, f := range frames {
+		if f.id == id {
+			if tf, ok := f.framer.(id3v2.TextFrame); ok {
+				return RemoveLeadingBOMs(tf.Text), true
+			}
+		}
+	}
+	return "", false
+}
+
+// convertDates converts the ID3V2.3 TYER, TDAT, TIME, and TORY frames to the
+// ID3V2.4 TDRC and TDOR frames, or the reverse, depending on the target version
+func (target *Id3v2Target) convertDates(n *id3v2Normalization, frames []id3v2Frame) {
+	replaced := map[string]bool{}
+	var added []id3v2Frame
+	addText := func(id, text string) {
+		if text != "" {
+			added = append(added, id3v2Frame{
+				id:     id,
+				framer: id3v2.TextFrame{Encoding: target.encoding, Text: text},
+			})
+		}
+	}
+	switch target.version {
+	case 4:
+		if year, found := frameText(frames, "TYER"); found {
+			date, _ := frameText(frames, "TDAT")
+			time, _ := frameText(frames, "TIME")
+			if _, exists := frameText(frames, "TDRC"); !exists {
+				addText("TDRC", id3v24Timestamp(year, date, time))
+			}
+			for _, id := range []string{"TYER", "TDAT", "TIME"} {
+				if _, found := frameText(frames, id); found {
+					replaced[id] = true
+					n.addChange("the %s frame will be replaced by the TDRC frame", id)
+				}
+			}
+		}
+		if year, found := frameText(frames, "TORY"); found {
+			if _, exists := frameText(frames, "TDOR"); !exists {
+				addText("TDOR", year)
+			}
+			replaced["TORY"] = true
+			n.addChange("the TORY frame will be replaced by the TDOR frame")
+		}
+	case 3:
+		if timestamp, found := frameText(frames, "TDRC"); found {
+			year, date, time := id3v23Date(timestamp)
+			addText("TYER", year)
+			addText("TDAT", date)
+			addText("TIME", time)
+			ids := []string{}
+			for _, f := range added {
+				ids = append(ids, f.id)
+			}
+			if len(ids) != 0 {
+				replaced["TDRC"] = true
+				n.addChange("the TDRC frame will be replaced by the %s", describeFrames(ids))
+			}
+		}
+		if timestamp, found := frameText(frames, "TDOR"); found && timestamp != "" {
+			year, _, _ := id3v23Date(timestamp)
+			addText("TORY", year)
+			replaced["TDOR"] = true
+			n.addChange("the TDOR frame will be replaced by the TORY frame")
+		}
+	}
+	for _, f := range frames {
+		if !replaced[f.id] {
+			n.frames = append(n.frames, f)
+		}
+	}
+	n.frames = append(n.frames, added...)
+}
+
+// id3v24Timestamp converts the ID3V2.3 year (yyyy), date (DDMM) and time
+// (HHMM) values to an ID3V2.4 timestamp (yyyy-MM-ddTHH:mm)
+func id3v24Timestamp(year, date, time string) string {
+	timestamp := year
+	if len(date) == 4 && isDigits(date) {
+		timestamp += "-" + date[2:] + "-" + date[:2]
+		if len(time) == 4 && isDigits(time) {
+			timestamp += "T" + time[:2] + ":" + time[2:]
+		}
+	}
+	return timestamp
+}
+
+// id3v23Date converts an ID3V2.4 timestamp, which may be as precise as
+// yyyy-MM-ddTHH:mm:ss, to ID3V2.3 year, date, and time values; the date and time
+// are empty if the timestamp is not precise enough to fill them
+func id3v23Date(timestamp string) (year, date, time string) {
+	year = timestamp
+	if len(year) > 4 {
+		year = year[:4]
+	}
+	if len(timestamp) >= 10 && timestamp[4] == '-' && timestamp[7] == '-' {
+		date = timestamp[8:10] + timestamp[5:7]
+		if len(timestamp) >= 16 && timestamp[10] == 'T' && timestamp[13] == ':' {
+			time = timestamp[11:13] + timestamp[14:16]
+		}
+	}
+	return
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// describeFrames returns "the X frame", "the X and Y frames", or "the X, Y,
+// and Z frames"
+func describeFrames(ids []string) string {
+	switch len(ids) {
+	case 1:
+		return ids[0] + " frame"
+	case 2:
+		return ids[0] + " and " + ids[1] + " frames"
+	default:
+		return strings.Join(ids[:len(ids)-1], ", ") + ", and " + ids[len(ids)-1] + " frames"
+	}
+}
+
+// represents returns false, and notes the frame's removal, if the frame cannot
+// be written in the target version
+func (target *Id3v2Target) represents(n *id3v2Normalization, f id3v2Frame) bool {
+	representable := len(f.id) == 4
+	for version, ids := range id3v2VersionOnlyFrames {
+		if version != target.version && slices.Contains(ids, f.id) {
+			representable = false
+		}
+	}
+	if uf, ok := f.framer.(id3v2.UnknownFrame); ok && target.version == 3 &&
+		slices.Contains(id3v2EncodedFrames, f.id) && len(uf.Body) != 0 &&
+		uf.Body[0] > id3v2.EncodingUTF16.Key {
+		representable = false
+	}
+	if !representable {
+		n.addChange("the %s frame cannot be represented in ID3V2.%d and will be removed",
+			f.id, target.version)
+	}
+	return representable
+}
+
+// reencode returns the frame, its text re-encoded in the target encoding; it
+// returns nil, and notes the frame's removal, if the text cannot be represented
+// in the target encoding. Frames that the id3v2 library does not parse are
+// returned unchanged
+func (target *Id3v2Target) reencode(n *id3v2Normalization, f id3v2Frame) id3v2.Framer {
+	var from id3v2.Encoding
+	var texts []string
+	var framer id3v2.Framer
+	switch frame := f.framer.(type) {
+	case id3v2.TextFrame:
+		from = frame.Encoding
+		frame.Encoding = target.encoding
+		frame.Text = RemoveLeadingBOMs(frame.Text)
+		texts = []string{frame.Text}
+		framer = frame
+	case id3v2.CommentFrame:
+		from = frame.Encoding
+		frame.Encoding = target.encoding
+		texts = []string{frame.Description, frame.Text}
+		framer = frame
+	case id3v2.UserDefinedTextFrame:
+		from = frame.Encoding
+		frame.Encoding = target.encoding
+		texts = []string{frame.Description, frame.Value}
+		framer = frame
+	case id3v2.UnsynchronisedLyricsFrame:
+		from = frame.Encoding
+		frame.Encoding = target.encoding
+		texts = []string{frame.ContentDescriptor, frame.Lyrics}
+		framer = frame
+	case id3v2.PictureFrame:
+		from = frame.Encoding
+		frame.Encoding = target.encoding
+		texts = []string{frame.Description}
+		framer = frame
+	default:
+		return f.framer
+	}
+	if target.encoding.Equals(id3v2.EncodingISO) {
+		for _, text := range texts {
+			if strings.ContainsFunc(text, func(r rune) bool { return r > 0xFF }) {
+				n.addChange("the %s frame cannot be represented in ISO-8859-1 and will be"+
+					" removed", f.id)
+				return nil
+			}
+		}
+	}
+	if !from.Equals(target.encoding) {
+		n.addChange("the %s frame will be re-encoded from %s to %s", f.id,
+			encodingName(from), encodingName(target.encoding))
+	}
+	return framer
+}
+
+// tag returns the ID3V2 tag that replaces the original one
+func (n *id3v2Normalization) tag(target *Id3v2Target) ([]byte, error) {
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(target.version)
+	tag.SetDefaultEncoding(target.encoding)
+	for _, f := range n.frames {
+		tag.AddFrame(f.id, f.framer)
+	}
+	buffer := &bytes.Buffer{}
+	_, err := tag.WriteTo(buffer)
+	return buffer.Bytes(), err
+}
+
+// readID3V22Frames converts the frames in an ID3V2.2 tag's body to their ID3V2.3
+// equivalents; frames that have no equivalent keep their three character IDs
+func readID3V22Frames(flags byte, body []byte) ([]id3v2Frame, error) {
+	if flags&id3v22CompressionFlag != 0 {
+		return nil, fmt.Errorf("compressed ID3V2.2 tags are not supported")
+	}
+	if flags&id3v2UnsynchronisationFlag != 0 {
+		body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	var frames []id3v2Frame
+	for len(body) >= id3v22FrameHeaderSize && body[0] != 0 {
+		id := string(body[:3])
+		size := int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		if id3v22FrameHeaderSize+size > len(body) {
+			return nil, fmt.Errorf("the ID3V2.2 %s frame extends past the end of the tag",
+				id)
+		}
+		frames = append(frames,
+			convertID3V22Frame(id, body[id3v22FrameHeaderSize:id3v22FrameHeaderSize+size]))
+		body = body[id3v22FrameHeaderSize+size:]
+	}
+	slices.SortStableFunc(frames, func(a, b id3v2Frame) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return frames, nil
+}
+
+// the ID3V2.3 IDs of the ID3V2.2 frames; CRM and LNK have no equivalent
+var id3v22FrameIDs = map[string]string{
+	"BUF": "RBUF", "CNT": "PCNT", "COM": "COMM", "CRA": "AENC", "ETC": "ETCO",
+	"EQU": "EQUA", "GEO": "GEOB", "IPL": "IPLS", "MCI": "MCDI", "MLL": "MLLT",
+	"PIC": "APIC", "POP": "POPM", "REV": "RVRB", "RVA": "RVAD", "SLT": "SYLT",
+	"STC": "SYTC", "TAL": "TALB", "TBP": "TBPM", "TCM": "TCOM", "TCO": "TCON",
+	"TCR": "TCOP", "TDA": "TDAT", "TDY": "TDLY", "TEN": "TENC", "TFT": "TFLT",
+	"TIM": "TIME", "TKE": "TKEY", "TLA": "TLAN", "TLE": "TLEN", "TMT": "TMED",
+	"TOA": "TOPE", "TOF": "TOFN", "TOL": "TOLY", "TOR": "TORY", "TOT": "TOAL",
+	"TP1": "TPE1", "TP2": "TPE2", "TP3": "TPE3", "TP4": "TPE4", "TPA": "TPOS",
+	"TPB": "TPUB", "TRC": "TSRC", "TRD": "TRDA", "TRK": "TRCK", "TSI": "TSIZ",
+	"TSS": "TSSE", "TT1": "TIT1", "TT2": "TIT2", "TT3": "TIT3", "TXT": "TEXT",
+	"TXX": "TXXX", "TYE": "TYER", "UFI": "UFID", "ULT": "USLT", "WAF": "WOAF",
+	"WAR": "WOAR", "WAS": "WOAS", "WCM": "WCOM", "WCP": "WCOP", "WPB": "WPUB",
+	"WXX": "WXXX",
+}
+
+// convertID3V22Frame parses the text, comment, lyrics, and picture frames as
+// the id3v2 library parses their ID3V2.3 equivalents; the other frames are
+// returned as unknown frames, as their bodies are unchanged in ID3V2.3
+func convertID3V22Frame(id string, data []byte) id3v2Frame {
+	newID, found := id3v22FrameIDs[id]
+	if !found {
+		return id3v2Frame{id: id, framer: id3v2.UnknownFrame{Body: data}}
+	}
+	frame := id3v2Frame{id: newID, framer: id3v2.UnknownFrame{Body: data}}
+	if len(data) == 0 {
+		return frame
+	}
+	encoding := id3v2.EncodingISO
+	if data[0] == id3v2.EncodingUTF16.Key {
+		encoding = id3v2.EncodingUTF16
+	}
+	switch {
+	case newID == "TXXX":
+		description, value := splitID3V2Text(encoding, data[1:])
+		frame.framer = id3v2.UserDefinedTextFrame{
+			Encoding:    encoding,
+			Description: decodeID3V2Text(encoding, description),
+			Value:       decodeID3V2Text(encoding, value),
+		}
+	case strings.HasPrefix(newID, "T"):
+		frame.framer = id3v2.TextFrame{
+			Encoding: encoding,
+			Text:     decodeID3V2Text(encoding, data[1:]),
+		}
+	case (newID == "COMM" || newID == "USLT") && len(data) >= 4:
+		description, text := splitID3V2Text(encoding, data[4:])
+		if newID == "COMM" {
+			frame.framer = id3v2.CommentFrame{
+				Encoding:    encoding,
+				Language:    string(data[1:4]),
+				Description: decodeID3V2Text(encoding, description),
+				Text:        decodeID3V2Text(encoding, text),
+			}
+		} else {
+			frame.framer = id3v2.UnsynchronisedLyricsFrame{
+				Encoding:          encoding,
+				Language:          string(data[1:4]),
+				ContentDescriptor: decodeID3V2Text(encoding, description),
+				Lyrics:            decodeID3V2Text(encoding, text),
+			}
+		}
+	case newID == "APIC" && len(data) >= 5:
+		description, picture := splitID3V2Text(encoding, data[5:])
+		frame.framer = id3v2.PictureFrame{
+			Encoding:    encoding,
+			MimeType:    "image/" + strings.ToLower(string(data[1:4])),
+			PictureType: data[4],
+			Description: decodeID3V2Text(encoding, description),
+			Picture:     picture,
+		}
+	}
+	return frame
+}
+
+// splitID3V2Text splits data after its first null terminator, which is two bytes
+// long in UTF-16
+func splitID3V2Text(encoding id3v2.Encoding, data []byte) (text, rest []byte) {
+	step := len(encoding.TerminationBytes)
+	for k := 0; k+step <= len(data); k += step {
+		if bytes.Equal(data[k:k+step], encoding.TerminationBytes) {
+			return data[:k], data[k+step:]
+		}
+	}
+	return data, nil
+}
+
+// decodeID3V2Text decodes ISO-8859-1 or UTF-16 text, which is big-endian unless
+// it begins with a little-endian byte order mark
+func decodeID3V2Text(encoding id3v2.Encoding, data []byte) string {
+	text, _ := splitID3V2Text(encoding, data)
+	if !encoding.Equals(id3v2.EncodingUTF16) {
+		runes := make([]rune, 0, len(text))
+		for _, b := range text {
+			runes = append(runes, rune(b))
+		}
+		return string(runes)
+	}
+	littleEndian := len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE
+	if len(text) >= 2 && (littleEndian || (text[0] == 0xFE && text[1] == 0xFF)) {
+		text = text[2:]
+	}
+	units := make([]uint16, 0, len(text)/2)
+	for k := 0; k+1 < len(text); k += 2 {
+		if littleEndian {
+			units = append(units, uint16(text[k+1])<<8|uint16(text[k]))
+		} else {
+			units = append(units, uint16(text[k])<<8|uint16(text[k+1]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// ID3V2Normalization returns the changes that normalizing the file's ID3V2 tag
+// would make
+func ID3V2Normalization(path string, target *Id3v2Target) ([]string, error) {
+	n, err := target.planNormalization(path)
+	if err != nil {
+		return nil, err
+	}
+	return n.changes, nil
+}
+
+// NormalizeID3V2 rewrites the file's ID3V2 tag in the target's version and
+// encoding; the file is left alone if no changes are needed
+func NormalizeID3V2(path string, target *Id3v2Target) error {
+	n, err := target.planNormalization(path)
+	if err != nil || len(n.changes) == 0 {
+		return err
+	}
+	tag, err := n.tag(target)
+	if err != nil {
+		return err
+	}
+	return rewriteFile(path, append(tag, n.content[n.tagEnd:]...))
+}
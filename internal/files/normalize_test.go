@@ -0,0 +1,222 @@
+package files_test
+
+import (
+	"bytes"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+// createID3v2TagWithFrames creates an ID3V2.3 or ID3V2.4 tag containing the
+// frames, followed by the audio
+func createID3v2TagWithFrames(version byte, frames map[string]id3v2.Framer,
+	audio []byte) []byte {
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(version)
+	for id, frame := range frames {
+		tag.AddFrame(id, frame)
+	}
+	buffer := &bytes.Buffer{}
+	_, _ = tag.WriteTo(buffer)
+	return append(buffer.Bytes(), audio...)
+}
+
+// createID3v22Tag creates an ID3V2.2 tag from frames, which are in the form
+// id, followed by body, followed by the audio
+func createID3v22Tag(frames [][2][]byte, audio []byte) []byte {
+	body := []byte{}
+	for _, frame := range frames {
+		size := len(frame[1])
+		body = append(body, frame[0]...)
+		body = append(body, byte(size>>16), byte(size>>8), byte(size))
+		body = append(body, frame[1]...)
+	}
+	size := len(body)
+	content := []byte{'I', 'D', '3', 2, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F),
+		byte(size & 0x7F)}
+	content = append(content, body...)
+	return append(content, audio...)
+}
+
+func TestNewId3v2Target(t *testing.T) {
+	const fnName = "NewId3v2Target()"
+	tests := map[string]struct {
+		version      int
+		encoding     string
+		wantVersion  int
+		wantEncoding string
+		wantErr      bool
+	}{
+		"v2.4 UTF-8":     {version: 4, encoding: "UTF-8", wantVersion: 4, wantEncoding: "UTF-8"},
+		"v2.4 utf-16be":  {version: 4, encoding: "utf-16be", wantVersion: 4, wantEncoding: "UTF-16BE"},
+		"v2.3 UTF-16":    {version: 3, encoding: "UTF-16", wantVersion: 3, wantEncoding: "UTF-16"},
+		"v2.3 Latin-1":   {version: 3, encoding: "ISO-8859-1", wantVersion: 3, wantEncoding: "ISO-8859-1"},
+		"v2.3 UTF-8":     {version: 3, encoding: "UTF-8", wantErr: true},
+		"v2.2":           {version: 2, encoding: "UTF-16", wantErr: true},
+		"unknown encode": {version: 4, encoding: "EBCDIC", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.NewId3v2Target(tt.version, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				if got.Version() != tt.wantVersion || got.Encoding() != tt.wantEncoding {
+					t.Errorf("%s = %d %q, want %d %q", fnName, got.Version(), got.Encoding(),
+						tt.wantVersion, tt.wantEncoding)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeID3V2(t *testing.T) {
+	const fnName = "NormalizeID3V2()"
+	testDir := "normalizeID3V2"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := createMpegFrame(128, nil)
+	utf16 := []byte{1, 0xFF, 0xFE, 'm', 0, 'y', 0, ' ', 0, 't', 0, 'r', 0, 'a', 0, 'c', 0, 'k', 0}
+	fileContents := map[string][]byte{
+		"untagged.mp3": audio,
+		"v22.mp3": createID3v22Tag([][2][]byte{
+			{[]byte("TT2"), utf16},
+			{[]byte("TYE"), []byte("\x001999")},
+			{[]byte("COM"), []byte("\x00engdesc\x00nice")},
+			{[]byte("CRM"), []byte("encrypted")},
+		}, audio),
+		"v23.mp3": createID3v2TagWithFrames(3, map[string]id3v2.Framer{
+			"TIT2": id3v2.TextFrame{Encoding: id3v2.EncodingISO, Text: "my track"},
+			"TYER": id3v2.TextFrame{Encoding: id3v2.EncodingISO, Text: "1999"},
+			"TDAT": id3v2.TextFrame{Encoding: id3v2.EncodingISO, Text: "1203"},
+			"TIME": id3v2.TextFrame{Encoding: id3v2.EncodingISO, Text: "1030"},
+			"RVAD": id3v2.UnknownFrame{Body: []byte{0, 16, 0, 0, 0, 0}},
+		}, audio),
+		"v24.mp3": createID3v2TagWithFrames(4, map[string]id3v2.Framer{
+			"TIT2": id3v2.TextFrame{Encoding: id3v2.EncodingUTF8, Text: "my track"},
+			"TDRC": id3v2.TextFrame{Encoding: id3v2.EncodingUTF8, Text: "1999-03-12"},
+			"TPE1": id3v2.TextFrame{Encoding: id3v2.EncodingUTF8, Text: "Иван"},
+		}, audio),
+		"bad.mp3": append([]byte{'I', 'D', '3', 5, 0, 0, 0, 0, 0, 0}, audio...),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	v23Latin1, _ := files.NewId3v2Target(3, "ISO-8859-1")
+	v24UTF8, _ := files.NewId3v2Target(4, "UTF-8")
+	tests := map[string]struct {
+		name        string
+		target      *files.Id3v2Target
+		wantChanges []string
+		wantVersion byte
+		wantFrames  []string
+		wantErr     bool
+	}{
+		"no file": {name: "no such file", target: v24UTF8, wantErr: true},
+		"no tag":  {name: "untagged.mp3", target: v24UTF8},
+		"unsupported version": {
+			name:    "bad.mp3",
+			target:  v24UTF8,
+			wantErr: true,
+		},
+		"v2.2 to v2.4": {
+			name:   "v22.mp3",
+			target: v24UTF8,
+			wantChanges: []string{
+				"the ID3V2 tag will be converted from version 2.2 to version 2.4",
+				"the TYER frame will be replaced by the TDRC frame",
+				"the CRM frame cannot be represented in ID3V2.4 and will be removed",
+				"the COMM frame will be re-encoded from ISO-8859-1 to UTF-8",
+				"the TIT2 frame will be re-encoded from UTF-16 to UTF-8",
+			},
+			wantVersion: 4,
+			wantFrames: []string{
+				`COMM = "<<id3v2.CommentFrame{Encoding:id3v2.Encoding{Name:\"UTF-8 encoded Unicode\", Key:0x3, TerminationBytes:[]uint8{0x0}}, Language:\"eng\", Description:\"desc\", Text:\"nice\"}>>"`,
+				`TDRC = "1999"`,
+				`TIT2 = "my track"`,
+			},
+		},
+		"v2.3 to v2.4": {
+			name:   "v23.mp3",
+			target: v24UTF8,
+			wantChanges: []string{
+				"the ID3V2 tag will be converted from version 2.3 to version 2.4",
+				"the TYER frame will be replaced by the TDRC frame",
+				"the TDAT frame will be replaced by the TDRC frame",
+				"the TIME frame will be replaced by the TDRC frame",
+				"the RVAD frame cannot be represented in ID3V2.4 and will be removed",
+				"the TIT2 frame will be re-encoded from ISO-8859-1 to UTF-8",
+			},
+			wantVersion: 4,
+			wantFrames:  []string{`TDRC = "1999-03-12T10:30"`, `TIT2 = "my track"`},
+		},
+		"v2.4 to v2.3": {
+			name:   "v24.mp3",
+			target: v23Latin1,
+			wantChanges: []string{
+				"the ID3V2 tag will be converted from version 2.4 to version 2.3",
+				"the TDRC frame will be replaced by the TYER and TDAT frames",
+				"the TIT2 frame will be re-encoded from UTF-8 to ISO-8859-1",
+				"the TPE1 frame cannot be represented in ISO-8859-1 and will be removed",
+			},
+			wantVersion: 3,
+			wantFrames:  []string{`TDAT = "1203"`, `TIT2 = "my track"`, `TYER = "1999"`},
+		},
+		"already normalized": {
+			name:        "v24.mp3",
+			target:      v23Latin1,
+			wantVersion: 3,
+			wantFrames:  []string{`TDAT = "1203"`, `TIT2 = "my track"`, `TYER = "1999"`},
+		},
+	}
+	for _, name := range []string{
+		"no file", "no tag", "unsupported version", "v2.2 to v2.4", "v2.3 to v2.4",
+		"v2.4 to v2.3", "already normalized",
+	} {
+		tt := tests[name]
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(testDir, tt.name)
+			gotChanges, err := files.ID3V2Normalization(path, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ID3V2Normalization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotChanges, tt.wantChanges) {
+				t.Errorf("ID3V2Normalization() = %v, want %v", gotChanges, tt.wantChanges)
+			}
+			if err = files.NormalizeID3V2(path, tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			content, _ := os.ReadFile(path)
+			if !bytes.HasSuffix(content, audio) {
+				t.Errorf("%s lost the audio", fnName)
+			}
+			if tt.wantVersion == 0 {
+				return
+			}
+			version, _, frames, _, _ := files.ReadID3V2Metadata(path)
+			if version != tt.wantVersion {
+				t.Errorf("%s version = %d, want %d", fnName, version, tt.wantVersion)
+			}
+			if !reflect.DeepEqual(frames, tt.wantFrames) {
+				t.Errorf("%s frames = %v, want %v", fnName, frames, tt.wantFrames)
+			}
+		})
+	}
+}
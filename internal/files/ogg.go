@@ -0,0 +1,443 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// values per https://www.xiph.org/ogg/doc/framing.html,
+// https://www.xiph.org/vorbis/doc/Vorbis_I_spec.html, and
+// https://www.rfc-editor.org/rfc/rfc7845
+const (
+	oggCapturePattern    = "OggS"
+	oggPageHeaderSize    = 27
+	oggMaximumSegments   = 255
+	oggMaximumSegment    = 255
+	oggContinuedPacket   = byte(0x01)
+	oggBeginningOfStream = byte(0x02)
+	// the granule position of a page on which no packet ends
+	oggNoGranulePosition = ^uint64(0)
+	vorbisIdentification = "\x01vorbis"
+	vorbisCommentHeader  = "\x03vorbis"
+	opusIdentification   = "OpusHead"
+	opusCommentHeader    = "OpusTags"
+)
+
+var (
+	oggCRCTable = func() (table [256]uint32) {
+		for k := range table {
+			r := uint32(k) << 24
+			for range 8 {
+				if r&0x80000000 != 0 {
+					r = r<<1 ^ 0x04c11db7
+				} else {
+					r <<= 1
+				}
+			}
+			table[k] = r
+		}
+		return
+	}()
+	// the codecs whose comments can be read, keyed by the prefix of their
+	// identification header
+	oggCodecs = map[string]*oggCodec{
+		vorbisIdentification: {
+			name:          "Vorbis",
+			commentPrefix: vorbisCommentHeader,
+			headerPackets: 3,
+		},
+		opusIdentification: {
+			name:          "Opus",
+			commentPrefix: opusCommentHeader,
+			headerPackets: 2,
+		},
+	}
+)
+
+type oggCodec struct {
+	name          string
+	commentPrefix string
+	// the number of packets, starting with the identification header, that
+	// precede the audio
+	headerPackets int
+}
+
+type oggPage struct {
+	headerType byte
+	granule    uint64
+	serial     uint32
+	sequence   uint32
+	segments   []byte
+	data       []byte
+}
+
+// oggFile holds the header packets of the first logical stream in an Ogg file
+type oggFile struct {
+	codec   *oggCodec
+	serial  uint32
+	packets [][]byte
+	// the number of pages holding the header packets, and the size of the first
+	// page, which holds only the identification header
+	headerPages   int
+	firstPageSize int64
+	// the offset of the first page following the header pages
+	headerEnd int64
+}
+
+func oggCRC(b []byte) (crc uint32) {
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return
+}
+
+func readOggPage(r io.Reader) (*oggPage, int64, error) {
+	header := make([]byte, oggPageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	if string(header[:4]) != oggCapturePattern || header[4] != 0 {
+		return nil, 0, fmt.Errorf("Ogg page header is invalid")
+	}
+	page := &oggPage{
+		headerType: header[5],
+		granule:    binary.LittleEndian.Uint64(header[6:]),
+		serial:     binary.LittleEndian.Uint32(header[14:]),
+		sequence:   binary.LittleEndian.Uint32(header[18:]),
+		segments:   make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, page.segments); err != nil {
+		return nil, 0, err
+	}
+	size := 0
+	for _, s := range page.segments {
+		size += int(s)
+	}
+	page.data = make([]byte, size)
+	if _, err := io.ReadFull(r, page.data); err != nil {
+		return nil, 0, err
+	}
+	return page, int64(oggPageHeaderSize + len(page.segments) + size), nil
+}
+
+// Bytes returns the page's binary representation, with its CRC
+func (p *oggPage) Bytes() []byte {
+	b := make([]byte, oggPageHeaderSize, oggPageHeaderSize+len(p.segments)+len(p.data))
+	copy(b, oggCapturePattern)
+	b[5] = p.headerType
+	binary.LittleEndian.PutUint64(b[6:], p.granule)
+	binary.LittleEndian.PutUint32(b[14:], p.serial)
+	binary.LittleEndian.PutUint32(b[18:], p.sequence)
+	b[26] = byte(len(p.segments))
+	b = append(append(b, p.segments...), p.data...)
+	binary.LittleEndian.PutUint32(b[22:], oggCRC(b))
+	return b
+}
+
+func readOggFile(path string) (*oggFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	of := &oggFile{}
+	var packet []byte
+	for of.codec == nil || len(of.packets) < of.codec.headerPackets {
+		page, size, err := readOggPage(r)
+		switch {
+		case of.headerPages == 0 && (err != nil || page.headerType&oggBeginningOfStream == 0):
+			return nil, fmt.Errorf("file %q is not an Ogg file", path)
+		case err != nil:
+			return nil, fmt.Errorf("Ogg headers in file %q are truncated", path)
+		case of.headerPages == 0:
+			of.serial = page.serial
+			of.firstPageSize = size
+		case page.serial != of.serial:
+			return nil, fmt.Errorf("Ogg file %q has more than one logical stream", path)
+		}
+		of.headerPages++
+		of.headerEnd += size
+		data := page.data
+		for k, s := range page.segments {
+			if of.codec != nil && len(of.packets) == of.codec.headerPackets {
+				return nil, fmt.Errorf("Ogg file %q has audio in its header pages", path)
+			}
+			packet = append(packet, data[:s]...)
+			data = data[s:]
+			if s == oggMaximumSegment {
+				continue
+			}
+			of.packets = append(of.packets, packet)
+			packet = nil
+			if len(of.packets) == 1 {
+				if of.codec = lookupOggCodec(of.packets[0]); of.codec == nil {
+					return nil, fmt.Errorf("Ogg file %q does not contain Vorbis or Opus audio",
+						path)
+				}
+				// the identification header must be alone on the first page
+				if k != len(page.segments)-1 || of.headerPages != 1 {
+					return nil, fmt.Errorf("Ogg file %q has an invalid first page", path)
+				}
+			}
+		}
+	}
+	return of, nil
+}
+
+func lookupOggCodec(identification []byte) *oggCodec {
+	for prefix, codec := range oggCodecs {
+		if bytes.HasPrefix(identification, []byte(prefix)) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// vorbisComments parses the comment header; it also returns whatever follows
+// the comments in the header, such as Vorbis's framing bit
+func (of *oggFile) vorbisComments() (*VorbisComments, []byte, error) {
+	header := of.packets[1]
+	if !bytes.HasPrefix(header, []byte(of.codec.commentPrefix)) {
+		return nil, nil, fmt.Errorf("%s comment header is missing", of.codec.name)
+	}
+	vc, err := parseVorbisComments(header[len(of.codec.commentPrefix):])
+	if err != nil {
+		return nil, nil, err
+	}
+	return vc, header[len(of.codec.commentPrefix)+len(vc.Bytes()):], nil
+}
+
+func (of *oggFile) setVorbisComments(vc *VorbisComments, trailer []byte) {
+	header := append([]byte(of.codec.commentPrefix), vc.Bytes()...)
+	of.packets[1] = append(header, trailer...)
+}
+
+// paginate divides the packets into pages, starting with the specified
+// sequence number; the last packet ends the last page, as the codecs require
+// of their header packets
+func (of *oggFile) paginate(packets [][]byte, sequence uint32) (pages []*oggPage) {
+	var page *oggPage
+	continued := false
+	for _, packet := range packets {
+		for ended := false; !ended; {
+			if page == nil || len(page.segments) == oggMaximumSegments {
+				if page != nil {
+					pages = append(pages, page)
+				}
+				page = &oggPage{
+					serial:   of.serial,
+					sequence: sequence + uint32(len(pages)),
+					granule:  oggNoGranulePosition,
+				}
+				if continued {
+					page.headerType = oggContinuedPacket
+				}
+			}
+			n := min(len(packet), oggMaximumSegment)
+			page.segments = append(page.segments, byte(n))
+			page.data = append(page.data, packet[:n]...)
+			packet = packet[n:]
+			// a packet ends with a segment shorter than the maximum
+			continued = n == oggMaximumSegment
+			if ended = !continued; ended {
+				// header packets have a granule position of 0
+				page.granule = 0
+			}
+		}
+	}
+	if page != nil {
+		pages = append(pages, page)
+	}
+	return
+}
+
+// write replaces the pages holding the comment header and the packets that
+// follow it in the header pages; the pages that follow are renumbered, and
+// their CRCs recomputed, if the number of header pages changes
+func (of *oggFile) write(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if of.headerEnd > int64(len(content)) {
+		return fmt.Errorf("Ogg metadata in file %q has changed", path)
+	}
+	var b bytes.Buffer
+	b.Write(content[:of.firstPageSize])
+	pages := of.paginate(of.packets[1:], 1)
+	for _, page := range pages {
+		b.Write(page.Bytes())
+	}
+	delta := uint32(len(pages) + 1 - of.headerPages)
+	rest := content[of.headerEnd:]
+	if delta == 0 {
+		b.Write(rest)
+		return rewriteFile(path, b.Bytes())
+	}
+	r := bytes.NewReader(rest)
+	for r.Len() > 0 {
+		offset := len(rest) - r.Len()
+		page, size, err := readOggPage(r)
+		if err != nil {
+			return fmt.Errorf("Ogg file %q is corrupt after offset %d", path,
+				of.headerEnd+int64(offset))
+		}
+		if page.serial != of.serial {
+			b.Write(rest[offset : offset+int(size)])
+			continue
+		}
+		page.sequence += delta
+		b.Write(page.Bytes())
+	}
+	return rewriteFile(path, b.Bytes())
+}
+
+func RawReadOggMetadata(path string) *VorbisMetadata {
+	of, err := readOggFile(path)
+	if err != nil {
+		return &VorbisMetadata{err: err}
+	}
+	vc, _, err := of.vorbisComments()
+	if err != nil {
+		return &VorbisMetadata{err: err}
+	}
+	return newVorbisMetadata(vc)
+}
+
+// ReadOggMetadata returns a description of the file's codec and header pages,
+// and its Vorbis comments
+func ReadOggMetadata(path string) ([]string, error) {
+	of, err := readOggFile(path)
+	if err != nil {
+		return nil, err
+	}
+	output := []string{
+		fmt.Sprintf("Codec: %s", of.codec.name),
+		fmt.Sprintf("Header pages: %d", of.headerPages),
+	}
+	if vc, _, err := of.vorbisComments(); err == nil {
+		output = append(output, vc.Diagnostics()...)
+	}
+	return output, nil
+}
+
+// ReadOggDetails returns the known details found in the file's Vorbis comments
+func ReadOggDetails(path string) (map[string]string, error) {
+	of, err := readOggFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vc, _, err := of.vorbisComments()
+	if err != nil {
+		return nil, err
+	}
+	return vc.details(), nil
+}
+
+func updateOggMetadata(tM *TrackMetadata, path string, sT SourceType) error {
+	if !tM.requiresEdit[sT] {
+		return nil
+	}
+	of, err := readOggFile(path)
+	if err != nil {
+		return err
+	}
+	vc, trailer, err := of.vorbisComments()
+	if err != nil {
+		return err
+	}
+	vc.applyCorrections(tM, sT)
+	of.setVorbisComments(vc, trailer)
+	return of.write(path)
+}
+
+// oggSource is the MetadataSource for the Vorbis comments in Ogg Vorbis and
+// Ogg Opus files
+type oggSource struct{}
+
+func init() {
+	registerMetadataSource(oggSource{})
+}
+
+func (oggSource) Type() SourceType {
+	return OGG
+}
+
+func (oggSource) Name() string {
+	return "OGG"
+}
+
+func (oggSource) Extensions() []string {
+	return []string{".oga", ".ogg", ".opus"}
+}
+
+func (oggSource) Read(path string, tM *TrackMetadata) error {
+	vm := RawReadOggMetadata(path)
+	if vm.err == nil {
+		tM.SetOggValues(vm)
+	}
+	return vm.err
+}
+
+func (oggSource) NameDiffers(cS *ComparableStrings) bool {
+	return VorbisNameDiffers(cS)
+}
+
+func (oggSource) GenreDiffers(cS *ComparableStrings) bool {
+	return VorbisGenreDiffers(cS)
+}
+
+func (oggSource) Write(tM *TrackMetadata, path string) error {
+	return updateOggMetadata(tM, path, OGG)
+}
+
+func (oggSource) Diagnostics(path string) ([]string, error) {
+	return ReadOggMetadata(path)
+}
+
+func (oggSource) Details(path string) (map[string]string, error) {
+	return ReadOggDetails(path)
+}
+
+// WriteAudio writes the content of the pages that follow the header pages;
+// their page headers are skipped, as rewriting the comment header may renumber
+// the pages
+func (oggSource) WriteAudio(path string, w io.Writer) error {
+	of, err := readOggFile(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = file.Seek(of.headerEnd, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(file)
+	for {
+		page, _, err := readOggPage(r)
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return fmt.Errorf("Ogg audio in file %q is corrupt: %v", path, err)
+		case page.serial != of.serial:
+			continue
+		}
+		if _, err = w.Write(page.data); err != nil {
+			return err
+		}
+	}
+}
+
+// the comment header is mandatory
+func (oggSource) MissingError() error {
+	return nil
+}
@@ -0,0 +1,381 @@
+package files_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mp3/internal/files"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+const oggTestSerial = 0x1234
+
+// oggTestCRC computes an Ogg page checksum independently of the package's own
+// implementation
+func oggTestCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc ^= uint32(c) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// createOggPage creates a page containing the specified packets, each of which
+// ends on the page
+func createOggPage(headerType byte, granule uint64, sequence uint32, packets ...[]byte) []byte {
+	var segments, data []byte
+	for _, packet := range packets {
+		for n := len(packet); ; n -= 255 {
+			if n < 255 {
+				segments = append(segments, byte(n))
+				break
+			}
+			segments = append(segments, 255)
+		}
+		data = append(data, packet...)
+	}
+	page := []byte("OggS\x00")
+	page = append(page, headerType)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, oggTestSerial)
+	page = binary.LittleEndian.AppendUint32(page, sequence)
+	page = binary.LittleEndian.AppendUint32(page, 0)
+	page = append(page, byte(len(segments)))
+	page = append(append(page, segments...), data...)
+	binary.LittleEndian.PutUint32(page[22:], oggTestCRC(page))
+	return page
+}
+
+// createOggCommentPacket creates a comment header containing the specified
+// comments; Vorbis headers end with a framing bit, Opus headers do not
+func createOggCommentPacket(opus bool, comments []string) []byte {
+	prefix, vendor, trailer := "\x03vorbis", "Xiph.Org libVorbis I 20200704", []byte{1}
+	if opus {
+		prefix, vendor, trailer = "OpusTags", "libopus 1.4", nil
+	}
+	packet := binary.LittleEndian.AppendUint32([]byte(prefix), uint32(len(vendor)))
+	packet = append(packet, vendor...)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(len(comments)))
+	for _, comment := range comments {
+		packet = binary.LittleEndian.AppendUint32(packet, uint32(len(comment)))
+		packet = append(packet, comment...)
+	}
+	return append(packet, trailer...)
+}
+
+// createOggData creates the content of an Ogg Vorbis (or Ogg Opus) file: the
+// identification header on the first page, the remaining header packets on the
+// second page, and two pages of audio
+func createOggData(opus bool, comments []string, audio []byte) []byte {
+	identification := append([]byte("\x01vorbis"), make([]byte, 23)...)
+	headers := [][]byte{createOggCommentPacket(opus, comments), []byte("\x05vorbis setup")}
+	if opus {
+		identification = append([]byte("OpusHead"), make([]byte, 11)...)
+		headers = headers[:1]
+	}
+	content := createOggPage(0x02, 0, 0, identification)
+	content = append(content, createOggPage(0, 0, 1, headers...)...)
+	content = append(content, createOggPage(0, 1024, 2, audio)...)
+	return append(content, createOggPage(0x04, 2048, 3, audio)...)
+}
+
+type oggTestPage struct {
+	headerType byte
+	sequence   uint32
+	segments   int
+}
+
+// readOggTestPages reads the pages of an Ogg file, verifying their checksums
+func readOggTestPages(t *testing.T, content []byte) []oggTestPage {
+	t.Helper()
+	var pages []oggTestPage
+	for len(content) > 0 {
+		if len(content) < 27 || string(content[:4]) != "OggS" {
+			t.Errorf("page %d is invalid", len(pages))
+			return pages
+		}
+		segments := content[27 : 27+int(content[26])]
+		size := 27 + len(segments)
+		for _, s := range segments {
+			size += int(s)
+		}
+		page := bytes.Clone(content[:size])
+		wantCRC := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if gotCRC := oggTestCRC(page); gotCRC != wantCRC {
+			t.Errorf("page %d CRC = %#x, want %#x", len(pages), gotCRC, wantCRC)
+		}
+		pages = append(pages, oggTestPage{
+			headerType: page[5],
+			sequence:   binary.LittleEndian.Uint32(page[18:]),
+			segments:   len(segments),
+		})
+		content = content[size:]
+	}
+	return pages
+}
+
+func TestRawReadOggMetadata(t *testing.T) {
+	const fnName = "RawReadOggMetadata()"
+	testDir := "rawReadOgg"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	comments := []string{
+		"ALBUM=unknown album",
+		"artist=unknown artist",
+		"Genre=dance music",
+		"TITLE=unknown track",
+		"TRACKNUMBER=2",
+		"DATE=2022",
+	}
+	audio := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	good := createOggData(false, comments, audio)
+	secondStream := bytes.Clone(good)
+	// give the second page a different serial number
+	secondStream[len(createOggPage(0x02, 0, 0, make([]byte, 30)))+14] = 0x99
+	fileContents := map[string][]byte{
+		"vorbis.ogg":       good,
+		"opus.opus":        createOggData(true, comments, audio),
+		"notOgg.ogg":       audio,
+		"truncated.ogg":    good[:100],
+		"twoStreams.ogg":   secondStream,
+		"badTrack.ogg":     createOggData(false, []string{"TRACKNUMBER=two"}, audio),
+		"unknownCodec.ogg": createOggPage(0x02, 0, 0, []byte("\x80theora")),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	want := files.NewVorbisMetadata().WithAlbumName("unknown album").WithArtistName(
+		"unknown artist").WithGenre("dance music").WithTrackName(
+		"unknown track").WithTrackNumber(2).WithYear("2022")
+	tests := map[string]struct {
+		path      string
+		want      *files.VorbisMetadata
+		wantError bool
+	}{
+		"no file":       {path: filepath.Join(testDir, "no such file"), wantError: true},
+		"not Ogg":       {path: filepath.Join(testDir, "notOgg.ogg"), wantError: true},
+		"truncated":     {path: filepath.Join(testDir, "truncated.ogg"), wantError: true},
+		"two streams":   {path: filepath.Join(testDir, "twoStreams.ogg"), wantError: true},
+		"bad track":     {path: filepath.Join(testDir, "badTrack.ogg"), wantError: true},
+		"unknown codec": {path: filepath.Join(testDir, "unknownCodec.ogg"), wantError: true},
+		"vorbis":        {path: filepath.Join(testDir, "vorbis.ogg"), want: want},
+		"opus":          {path: filepath.Join(testDir, "opus.opus"), want: want},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := files.RawReadOggMetadata(tt.path)
+			if tt.wantError {
+				if !got.HasError() {
+					t.Errorf("%s = %#v, want error", fnName, got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, want %#v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOggMetadata(t *testing.T) {
+	const fnName = "ReadOggMetadata()"
+	testDir := "readOgg"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	content := createOggData(true, []string{"TITLE=my track", "ARTIST=my artist"}, []byte{0})
+	if err := createFileWithContent(testDir, "tagged.opus", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.opus", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.opus"),
+			want: []string{
+				"Codec: Opus",
+				"Header pages: 2",
+				`vendor = "libopus 1.4"`,
+				`TITLE = "my track"`,
+				`ARTIST = "my artist"`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadOggMetadata(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOggDetails(t *testing.T) {
+	const fnName = "ReadOggDetails()"
+	testDir := "readOggDetails"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	content := createOggData(false, []string{
+		"TITLE=my track",
+		"lyricist=a couple of idiots",
+		"KEY=C#m",
+	}, []byte{0})
+	if err := createFileWithContent(testDir, "tagged.ogg", content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, "tagged.ogg", err)
+	}
+	tests := map[string]struct {
+		path    string
+		want    map[string]string
+		wantErr bool
+	}{
+		"no file": {path: filepath.Join(testDir, "no such file"), wantErr: true},
+		"tagged": {
+			path: filepath.Join(testDir, "tagged.ogg"),
+			want: map[string]string{
+				"Key":      "C#m",
+				"Lyricist": "a couple of idiots",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ReadOggDetails(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrack_UpdateMetadataOgg(t *testing.T) {
+	const fnName = "Track.UpdateMetadata()"
+	testDir := "updateOgg"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	comments := []string{
+		"ALBUM=unknown album",
+		"ARTIST=unknown artist",
+		"GENRE=dance music",
+		"TITLE=unknown track",
+		"TRACKNUMBER=2",
+		"DATE=2022",
+	}
+	// pads the comment header to the specified size with a description; the
+	// corrections add 7 bytes to the comments
+	padded := func(opus bool, size int) []string {
+		padded := append(slices.Clone(comments), "DESCRIPTION=")
+		filler := size - len(createOggCommentPacket(opus, padded))
+		padded[len(padded)-1] += strings.Repeat("x", filler)
+		return padded
+	}
+	audio := []byte("the audio, which must not be lost")
+	fileContents := map[string][]byte{
+		"roomy.ogg": createOggData(false, comments, audio),
+		// the comment header grows from 254 segments to 255, pushing the setup
+		// header onto a new page
+		"full.ogg": createOggData(false, padded(false, 254*255-3), audio),
+		// the comment header grows beyond the 255 segments a page can hold
+		"full.opus": createOggData(true, padded(true, 255*255-3), audio),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name         string
+		wantSegments []int
+	}{
+		"header pages unchanged": {name: "roomy.ogg", wantSegments: []int{1, 2, 1, 1}},
+		"setup header moves to a new page": {
+			name:         "full.ogg",
+			wantSegments: []int{1, 255, 1, 1, 1},
+		},
+		"comment header spans pages": {
+			name:         "full.opus",
+			wantSegments: []int{1, 255, 1, 1, 1},
+		},
+	}
+	wantData := files.NewVorbisMetadata().WithAlbumName("fine album").WithArtistName(
+		"fine artist").WithGenre("Classic Rock").WithTrackName(
+		"a much longer track title").WithTrackNumber(3).WithYear("2022")
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(testDir, tt.name)
+			track := files.NewEmptyTrack().WithFullPath(path).WithName(
+				"a much longer track title").WithNumber(3).WithAlbum(
+				files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+					"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+					"fine album").WithArtist(files.NewEmptyArtist().WithFileName(
+					"fine artist").WithCanonicalName("fine artist"))).WithMetadata(
+				files.ReadRawMetadata(path, files.NewReadSettings()))
+			if gotE := track.UpdateMetadata(); len(gotE) != 0 {
+				t.Errorf("%s = %v, want no errors", fnName, gotE)
+				return
+			}
+			if got := files.RawReadOggMetadata(path); !reflect.DeepEqual(got, wantData) {
+				t.Errorf("%s read %#v, want %#v", fnName, got, wantData)
+			}
+			content, _ := os.ReadFile(path)
+			if got := bytes.Count(content, audio); got != 2 {
+				t.Errorf("%s audio found %d times, want 2", fnName, got)
+			}
+			pages := readOggTestPages(t, content)
+			var gotSegments []int
+			for k, page := range pages {
+				gotSegments = append(gotSegments, page.segments)
+				if page.sequence != uint32(k) {
+					t.Errorf("%s page %d sequence = %d", fnName, k, page.sequence)
+				}
+			}
+			if !reflect.DeepEqual(gotSegments, tt.wantSegments) {
+				t.Errorf("%s segments = %v, want %v", fnName, gotSegments, tt.wantSegments)
+			}
+			if last := pages[len(pages)-1]; last.headerType != 0x04 {
+				t.Errorf("%s last page header type = %#x, want 0x04", fnName,
+					last.headerType)
+			}
+		})
+	}
+}
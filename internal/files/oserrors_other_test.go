@@ -0,0 +1,9 @@
+//go:build !windows
+
+package files_test
+
+// operating system error messages, as reported on non-Windows platforms
+const (
+	fileNotFound    = "no such file or directory"
+	seekBeforeStart = "invalid argument"
+)
@@ -0,0 +1,9 @@
+//go:build windows
+
+package files_test
+
+// operating system error messages, as reported on Windows
+const (
+	fileNotFound    = "The system cannot find the file specified."
+	seekBeforeStart = "An attempt was made to move the file pointer before the beginning of the file."
+)
@@ -0,0 +1,148 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// the bounds on the number of track files whose metadata may be read at the same
+// time; the default is a typical limit for open files
+const (
+	MinMetadataReaders     = 1
+	DefaultMetadataReaders = 20
+	MaxMetadataReaders     = 100
+)
+
+// ReadSettings holds the settings that govern how track files are read: the
+// order in which metadata sources are considered when selecting a track's
+// primary source of metadata, the number of track files whose metadata may be
+// read at the same time, and how the metadata cache is used. An artist carries
+// the settings with which its tracks are read.
+type ReadSettings struct {
+	primarySourcePriority []SourceType
+	metadataReaders       int
+	metadataCacheMode     string
+}
+
+// defaultReadSettings are used to read a track that does not belong to an
+// artist
+var defaultReadSettings = NewReadSettings()
+
+// NewReadSettings creates a new instance of ReadSettings with the default
+// settings
+func NewReadSettings() *ReadSettings {
+	return &ReadSettings{
+		primarySourcePriority: defaultPrimarySourcePriority,
+		metadataReaders:       DefaultMetadataReaders,
+		metadataCacheMode:     CacheBypass,
+	}
+}
+
+// WithPrimarySourcePriority sets the order in which metadata sources are
+// considered when selecting a track's primary source of metadata; unregistered
+// and duplicate entries are ignored, and registered sources that are not
+// specified are considered last
+func (rs *ReadSettings) WithPrimarySourcePriority(priority []SourceType) *ReadSettings {
+	rs.primarySourcePriority = slices.Clone(priority)
+	return rs
+}
+
+// WithMetadataReaders sets the number of track files whose metadata may be read
+// at the same time; values outside the bounds are moved to the nearest bound
+func (rs *ReadSettings) WithMetadataReaders(n int) *ReadSettings {
+	rs.metadataReaders = max(MinMetadataReaders, min(n, MaxMetadataReaders))
+	return rs
+}
+
+// WithMetadataCacheMode sets how the metadata cache is to be used
+func (rs *ReadSettings) WithMetadataCacheMode(mode string) *ReadSettings {
+	rs.metadataCacheMode = mode
+	return rs
+}
+
+// PrimarySourcePriority returns the order in which metadata sources are
+// considered when selecting a track's primary source of metadata
+func (rs *ReadSettings) PrimarySourcePriority() []SourceType {
+	priority := make([]SourceType, 0, len(metadataSources))
+	for _, sT := range rs.primarySourcePriority {
+		if _, ok := lookupMetadataSource(sT); ok && !slices.Contains(priority, sT) {
+			priority = append(priority, sT)
+		}
+	}
+	for _, src := range metadataSources {
+		if !slices.Contains(priority, src.Type()) {
+			priority = append(priority, src.Type())
+		}
+	}
+	return priority
+}
+
+// MetadataReaders returns the number of track files whose metadata may be read
+// at the same time
+func (rs *ReadSettings) MetadataReaders() int {
+	return rs.metadataReaders
+}
+
+// MetadataCacheMode returns how the metadata cache is to be used
+func (rs *ReadSettings) MetadataCacheMode() string {
+	return rs.metadataCacheMode
+}
+
+// LoadTrackMetadata reads the metadata of those tracks that need it, with a
+// pool of the specified number of metadata readers, calling done after each
+// track is read; each track is read with its artist's settings. It returns
+// only after every reader has stopped, so that the tracks' metadata may be used
+// without further synchronization. When the context is cancelled, no more
+// tracks are started, and the context's error is returned along with any errors
+// reading the tracks, which are returned in track order.
+func LoadTrackMetadata(ctx context.Context, tracks []*Track, readers int, done func()) error {
+	errs := make([]error, len(tracks))
+	indices := make(chan int)
+	wg := &sync.WaitGroup{}
+	for range min(max(readers, MinMetadataReaders), len(tracks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				errs[index] = tracks[index].loadMetadata()
+				done()
+			}
+		}()
+	}
+	var cancelled error
+feed:
+	for index, t := range tracks {
+		if !t.NeedsMetadata() {
+			continue
+		}
+		// check first, as select chooses at random between ready cases
+		if cancelled = ctx.Err(); cancelled != nil {
+			break
+		}
+		select {
+		case indices <- index:
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+	return errors.Join(append([]error{cancelled}, errs...)...)
+}
+
+// loadMetadata reads the track's metadata; a panic while reading, as might be
+// caused by a badly damaged file, is returned as an error instead of ending the
+// program, and leaves the track without metadata
+func (t *Track) loadMetadata() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reading the metadata of %q failed: %v", t.fullPath, r)
+		}
+	}()
+	t.SetMetadata(readCachedMetadata(t.fullPath, t.readSettings()))
+	return nil
+}
@@ -0,0 +1,105 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mp3/internal/files"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+func TestReadSettings_WithMetadataReaders(t *testing.T) {
+	const fnName = "ReadSettings.WithMetadataReaders()"
+	tests := map[string]struct {
+		n    int
+		want int
+	}{
+		"too few":  {n: 0, want: files.MinMetadataReaders},
+		"typical":  {n: 5, want: 5},
+		"too many": {n: 1000, want: files.MaxMetadataReaders},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := files.NewReadSettings().WithMetadataReaders(tt.n).MetadataReaders(); got !=
+				tt.want {
+				t.Errorf("%s got %d want %d", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTrackMetadata(t *testing.T) {
+	const fnName = "LoadTrackMetadata()"
+	testDir := "loadTrackMetadata"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	const trackCount = 25
+	var paths []string
+	for n := 1; n <= trackCount; n++ {
+		fileName := fmt.Sprintf("%02d track %d.mp3", n, n)
+		content := createConsistentlyTaggedData([]byte{0, 1, 2, byte(n)}, map[string]any{
+			"artist": "artist",
+			"album":  "album",
+			"title":  fmt.Sprintf("track %d", n),
+			"genre":  "Rock",
+			"year":   "2024",
+			"track":  n,
+		})
+		if err := createFileWithContent(testDir, fileName, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, fileName, err)
+		}
+		paths = append(paths, filepath.Join(testDir, fileName))
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := map[string]struct {
+		ctx      context.Context
+		readers  int
+		wantRead bool
+		wantErr  error
+	}{
+		"one reader":     {ctx: context.Background(), readers: 1, wantRead: true},
+		"many readers":   {ctx: context.Background(), readers: 7, wantRead: true},
+		"more than work": {ctx: context.Background(), readers: 100, wantRead: true},
+		"cancelled":      {ctx: cancelled, readers: 3, wantErr: context.Canceled},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tracks []*files.Track
+			for _, path := range paths {
+				tracks = append(tracks, files.NewEmptyTrack().WithFullPath(path))
+			}
+			// a track whose metadata has been read already is not read again
+			tracks = append(tracks, files.NewEmptyTrack().WithMetadata(
+				files.NewTrackMetadata()))
+			var done atomic.Int32
+			err := files.LoadTrackMetadata(tt.ctx, tracks, tt.readers, func() { done.Add(1) })
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("%s error = %v, want %v", fnName, err, tt.wantErr)
+			}
+			if tt.wantRead {
+				if got := done.Load(); got != trackCount {
+					t.Errorf("%s read %d tracks, want %d", fnName, got, trackCount)
+				}
+				for n, track := range tracks[:trackCount] {
+					want := fmt.Sprintf("track %d", n+1)
+					if track.NeedsMetadata() {
+						t.Errorf("%s track %q was not read", fnName, track.Path())
+					} else if track.GetMetadata().TrackTitleDiffers(want) {
+						t.Errorf("%s track %q title is not %q", fnName, track.Path(), want)
+					}
+				}
+			} else if got := done.Load(); got != 0 {
+				t.Errorf("%s read %d tracks, want none", fnName, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// MetadataSource is implemented by each form of metadata (such as an ID3V2
+// tag) that can be read from a track file, compared against the file's name and
+// location, written back to the file, and displayed for diagnostic purposes.
+// Implementations register themselves by calling registerMetadataSource from an
+// init function; TrackMetadata then handles them without any further changes.
+type MetadataSource interface {
+	// Type returns the SourceType that indexes the source's metadata in
+	// TrackMetadata
+	Type() SourceType
+	// Name returns the name used to identify the source to the user
+	Name() string
+	// Extensions returns the extensions of the only files to which the source
+	// applies; if it returns none, the source applies to any file whose
+	// extension is not claimed by another source
+	Extensions() []string
+	// Read reads the metadata from the file and, if successful, records the
+	// values in tM
+	Read(path string, tM *TrackMetadata) error
+	// NameDiffers returns true if the metadata value cannot be reconciled with
+	// the (file or directory) name
+	NameDiffers(cS *ComparableStrings) bool
+	// GenreDiffers returns true if the metadata genre cannot be reconciled with
+	// the album's genre
+	GenreDiffers(cS *ComparableStrings) bool
+	// Write writes the corrected values recorded in tM to the file, if an edit
+	// is required
+	Write(tM *TrackMetadata, path string) error
+	// Diagnostics returns a description of the metadata found in the file
+	Diagnostics(path string) ([]string, error)
+	// MissingError returns the error Read returns when the file does not contain
+	// this kind of metadata, if that is normal; if the metadata is expected to
+	// be present, MissingError returns nil
+	MissingError() error
+}
+
+// DetailsSource is implemented by metadata sources that can provide details
+// about a track, such as its composer, beyond the metadata that TrackMetadata
+// holds
+type DetailsSource interface {
+	Details(path string) (map[string]string, error)
+}
+
+// AudioSource is implemented by metadata sources for formats that keep their
+// metadata apart from their audio; WriteAudio writes the audio, and nothing
+// else, so that copies of the same recording can be recognized regardless of
+// their tags
+type AudioSource interface {
+	WriteAudio(path string, w io.Writer) error
+}
+
+// CreatableSource is implemented by metadata sources whose metadata can be
+// added to a file that does not contain it
+type CreatableSource interface {
+	// Absent returns true if the file does not contain the source's metadata
+	Absent(path string) bool
+	// Create adds the source's metadata, holding the corrected values recorded
+	// in tM, to the file
+	Create(tM *TrackMetadata, path string) error
+}
+
+var (
+	// recorded as the error cause for a metadata source that does not apply to
+	// the file; like missing optional metadata, it is not an error
+	errNotApplicable = fmt.Errorf("metadata source does not apply to this file")
+	// registered metadata sources, in SourceType order
+	metadataSources []MetadataSource
+	// when more than one source of metadata is available, the first available
+	// source in this list is the primary source, unless the read settings
+	// specify otherwise; registered sources that are not in the list follow it,
+	// in SourceType order
+	defaultPrimarySourcePriority = []SourceType{ID3V2, APEV2, ID3V1}
+)
+
+func registerMetadataSource(src MetadataSource) {
+	metadataSources = append(metadataSources, src)
+	slices.SortFunc(metadataSources, func(a, b MetadataSource) int {
+		return int(a.Type() - b.Type())
+	})
+}
+
+// MetadataSources returns the registered metadata sources, in SourceType order
+func MetadataSources() []MetadataSource {
+	return slices.Clone(metadataSources)
+}
+
+func lookupMetadataSource(sT SourceType) (MetadataSource, bool) {
+	for _, src := range metadataSources {
+		if src.Type() == sT {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+// ParseSourceType returns the SourceType of the registered metadata source with
+// the specified name, ignoring case
+func ParseSourceType(name string) (SourceType, bool) {
+	for _, src := range metadataSources {
+		if strings.EqualFold(src.Name(), strings.TrimSpace(name)) {
+			return src.Type(), true
+		}
+	}
+	return UndefinedSource, false
+}
+
+// claims returns true if the metadata source claims the file's extension
+func claims(src MetadataSource, path string) bool {
+	extension := filepath.Ext(path)
+	return slices.ContainsFunc(src.Extensions(), func(e string) bool {
+		return strings.EqualFold(e, extension)
+	})
+}
+
+// isClaimed returns true if a format-specific metadata source, such as FLAC,
+// claims the file's extension; files whose extensions are not claimed are
+// presumed to be MPEG audio files
+func isClaimed(path string) bool {
+	return slices.ContainsFunc(metadataSources, func(src MetadataSource) bool {
+		return claims(src, path)
+	})
+}
+
+// appliesTo returns true if the metadata source applies to the file
+func appliesTo(src MetadataSource, path string) bool {
+	if len(src.Extensions()) != 0 {
+		return claims(src, path)
+	}
+	return !isClaimed(path)
+}
+
+// AudioFingerprint returns a SHA-256 hash, in hexadecimal, of a track file's
+// audio, excluding its metadata; files that no metadata source claims are
+// presumed to be MPEG audio files, whose ID3V2, APEv2, Lyrics3, and ID3V1 tags
+// are excluded
+func AudioFingerprint(path string) (string, error) {
+	write := writeMpegAudio
+	for _, src := range metadataSources {
+		if !claims(src, path) {
+			continue
+		}
+		audioSrc, ok := src.(AudioSource)
+		if !ok {
+			return "", fmt.Errorf("the audio in file %q cannot be separated from its %s metadata",
+				path, src.Name())
+		}
+		write = audioSrc.WriteAudio
+		break
+	}
+	h := sha256.New()
+	if err := write(path, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isMissing returns true if the error cause records nothing more than the
+// absence of optional metadata, or that the source does not apply to the file
+func isMissing(src MetadataSource, cause string) bool {
+	if cause == errNotApplicable.Error() {
+		return true
+	}
+	missing := src.MissingError()
+	return missing != nil && cause == missing.Error()
+}
+
+// IsMissingMetadata returns true if the error returned by reading the specified
+// source of metadata means only that the file does not contain that kind of
+// metadata, and that such metadata is optional
+func IsMissingMetadata(sT SourceType, err error) bool {
+	src, ok := lookupMetadataSource(sT)
+	if !ok || err == nil {
+		return false
+	}
+	missing := src.MissingError()
+	return missing != nil && errors.Is(err, missing)
+}
+
+func readSource(src MetadataSource, path string, tM *TrackMetadata) {
+	sT := src.Type()
+	switch err := src.Read(path, tM); {
+	case err == nil:
+		tM.errorCause[sT] = ""
+	case src.MissingError() != nil && errors.Is(err, src.MissingError()):
+		tM.errorCause[sT] = src.MissingError().Error()
+	default:
+		tM.errorCause[sT] = err.Error()
+	}
+}
@@ -0,0 +1,231 @@
+package files_test
+
+import (
+	"mp3/internal/files"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
+)
+
+func TestMetadataSources(t *testing.T) {
+	const fnName = "MetadataSources()"
+	got := files.MetadataSources()
+	gotTypes := make([]files.SourceType, 0, len(got))
+	for _, src := range got {
+		gotTypes = append(gotTypes, src.Type())
+		if src.Name() != src.Type().Name() {
+			t.Errorf("%s source name %q, type name %q", fnName, src.Name(),
+				src.Type().Name())
+		}
+	}
+	if want := []files.SourceType{
+		files.ID3V1, files.ID3V2, files.APEV2, files.FLAC, files.MP4, files.OGG,
+	}; !reflect.DeepEqual(gotTypes, want) {
+		t.Errorf("%s = %v, want %v", fnName, gotTypes, want)
+	}
+}
+
+func TestParseSourceType(t *testing.T) {
+	const fnName = "ParseSourceType()"
+	tests := map[string]struct {
+		name   string
+		want   files.SourceType
+		wantOk bool
+	}{
+		"exact":      {name: "ID3V2", want: files.ID3V2, wantOk: true},
+		"lower case": {name: "apev2", want: files.APEV2, wantOk: true},
+		"padded":     {name: " id3v1 ", want: files.ID3V1, wantOk: true},
+		"unknown":    {name: "vorbis", want: files.UndefinedSource},
+		"total":      {name: "total", want: files.UndefinedSource},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotOk := files.ParseSourceType(tt.name)
+			if got != tt.want {
+				t.Errorf("%s got = %v, want %v", fnName, got, tt.want)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("%s gotOk = %v, want %v", fnName, gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestReadSettings_PrimarySourcePriority(t *testing.T) {
+	const fnName = "ReadSettings.PrimarySourcePriority()"
+	tests := map[string]struct {
+		settings *files.ReadSettings
+		want     []files.SourceType
+	}{
+		"default": {
+			settings: files.NewReadSettings(),
+			want: []files.SourceType{
+				files.ID3V2, files.APEV2, files.ID3V1, files.FLAC, files.MP4, files.OGG,
+			},
+		},
+		"complete": {
+			settings: files.NewReadSettings().WithPrimarySourcePriority([]files.SourceType{
+				files.OGG, files.MP4, files.FLAC, files.ID3V1, files.APEV2, files.ID3V2,
+			}),
+			want: []files.SourceType{
+				files.OGG, files.MP4, files.FLAC, files.ID3V1, files.APEV2, files.ID3V2,
+			},
+		},
+		"partial": {
+			settings: files.NewReadSettings().WithPrimarySourcePriority(
+				[]files.SourceType{files.APEV2}),
+			want: []files.SourceType{
+				files.APEV2, files.ID3V1, files.ID3V2, files.FLAC, files.MP4, files.OGG,
+			},
+		},
+		"duplicates and unregistered types": {
+			settings: files.NewReadSettings().WithPrimarySourcePriority([]files.SourceType{
+				files.TotalSources, files.ID3V1, files.UndefinedSource, files.ID3V1,
+			}),
+			want: []files.SourceType{
+				files.ID3V1, files.ID3V2, files.APEV2, files.FLAC, files.MP4, files.OGG,
+			},
+		},
+		"empty": {
+			settings: files.NewReadSettings().WithPrimarySourcePriority(nil),
+			want: []files.SourceType{
+				files.ID3V1, files.ID3V2, files.APEV2, files.FLAC, files.MP4, files.OGG,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.settings.PrimarySourcePriority(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataSource_Diagnostics(t *testing.T) {
+	const fnName = "MetadataSource.Diagnostics()"
+	testDir := "sourceDiagnostics"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	fileName := "01 tagged.mp3"
+	content := createID3v2TaggedData([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		map[string]string{"TIT2": "tagged", "TRCK": "1"})
+	content = append(content, createApeV2TaggedData(
+		map[string]string{"Title": "tagged", "Track": "1"}, true)...)
+	content = append(content, id3v1DataSet1...)
+	if err := createFileWithContent(testDir, fileName, content); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, fileName, err)
+	}
+	path := filepath.Join(testDir, fileName)
+	want := map[files.SourceType][]string{
+		files.ID3V1: {
+			`Artist: "The Beatles"`,
+			`Album: "On Air: Live At The BBC, Volum"`,
+			`Title: "Ringo - Pop Profile [Interview"`,
+			"Track: 29",
+			`Year: "2013"`,
+			`Genre: "Other"`,
+		},
+		files.ID3V2: {
+			"Version: 3",
+			`Encoding: "ISO-8859-1"`,
+			`TIT2 = "tagged"`,
+			`TRCK = "1"`,
+		},
+		files.APEV2: {`Title = "tagged"`, `Track = "1"`},
+	}
+	track := files.NewEmptyTrack().WithFullPath(path)
+	for _, src := range track.MetadataSources() {
+		t.Run(src.Name(), func(t *testing.T) {
+			got, err := src.Diagnostics(path)
+			if err != nil {
+				t.Errorf("%s error = %v", fnName, err)
+			} else if !reflect.DeepEqual(got, want[src.Type()]) {
+				t.Errorf("%s = %v, want %v", fnName, got, want[src.Type()])
+			}
+		})
+	}
+}
+
+func TestAudioFingerprint(t *testing.T) {
+	const fnName = "AudioFingerprint()"
+	testDir := "audioFingerprint"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	var audio, otherAudio []byte
+	for k := 0; k < 10; k++ {
+		audio = append(audio, createMpegFrame(128, nil)...)
+		otherAudio = append(otherAudio, createMpegFrame(192, nil)...)
+	}
+	fileContents := map[string][]byte{
+		"plain.mp3": audio,
+		"tagged.mp3": append(append(createID3v2TaggedData(audio,
+			map[string]string{"TIT2": "my track"}), createApeV2TaggedData(
+			map[string]string{"Title": "my track"}, true)...), id3v1DataSet1...),
+		"other.mp3": otherAudio,
+		"a.flac":    createFlacData([]string{"TITLE=a"}, 0, audio),
+		"b.flac":    createFlacData([]string{"TITLE=b", "ARTIST=c"}, 100, audio),
+		"c.flac":    createFlacData([]string{"TITLE=a"}, 0, otherAudio),
+		"a.ogg":     createOggData(false, []string{"TITLE=a"}, audio[:1000]),
+		"b.ogg":     createOggData(false, []string{"TITLE=b", "ARTIST=c"}, audio[:1000]),
+		"c.ogg":     createOggData(false, []string{"TITLE=a"}, otherAudio[:1000]),
+		"a.m4a": createMp4Data([]mp4TestItem{{key: "\xa9nam", dataType: 1,
+			value: []byte("a")}}, 0, false, audio),
+		"b.m4a":      createMp4Data(nil, 0, true, audio),
+		"c.m4a":      createMp4Data(nil, 0, true, otherAudio),
+		"noise.flac": {1, 2, 3, 4},
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name     string
+		other    string
+		wantSame bool
+		wantErr  bool
+	}{
+		"no file":             {name: "no such file.mp3", wantErr: true},
+		"not really FLAC":     {name: "noise.flac", wantErr: true},
+		"MPEG, tags differ":   {name: "plain.mp3", other: "tagged.mp3", wantSame: true},
+		"MPEG, audio differs": {name: "plain.mp3", other: "other.mp3"},
+		"FLAC, tags differ":   {name: "a.flac", other: "b.flac", wantSame: true},
+		"FLAC, audio differs": {name: "a.flac", other: "c.flac"},
+		"Ogg, tags differ":    {name: "a.ogg", other: "b.ogg", wantSame: true},
+		"Ogg, audio differs":  {name: "a.ogg", other: "c.ogg"},
+		"MP4, tags differ":    {name: "a.m4a", other: "b.m4a", wantSame: true},
+		"MP4, audio differs":  {name: "a.m4a", other: "c.m4a"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.AudioFingerprint(filepath.Join(testDir, tt.name))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			other, err := files.AudioFingerprint(filepath.Join(testDir, tt.other))
+			if err != nil {
+				t.Errorf("%s error = %v reading %q", fnName, err, tt.other)
+				return
+			}
+			if (got == other) != tt.wantSame {
+				t.Errorf("%s %q = %s, %q = %s, want same %v", fnName, tt.name, got,
+					tt.other, other, tt.wantSame)
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package files
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"maps"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
-	"time"
+	"sync/atomic"
 
 	"github.com/bogem/id3v2/v2"
 	"github.com/cheggaaa/pb/v3"
@@ -16,16 +20,17 @@ import (
 )
 
 const (
-	rawExtension            = "mp3"
-	defaultFileExtension    = "." + rawExtension
-	defaultTrackNamePattern = "^\\d+[\\s-].+\\." + rawExtension + "$"
-
-	mcdiFrame  = "MCDI"
-	trackFrame = "TRCK"
+	rawExtension         = "mp3"
+	defaultFileExtension = "." + rawExtension
+
+	albumArtistFrame = "TPE2"
+	compilationFrame = "TCMP"
+	discFrame        = "TPOS"
+	mcdiFrame        = "MCDI"
+	trackFrame       = "TRCK"
 )
 
 var (
-	openFiles         = make(chan empty, 20) // 20 is a typical limit for open files
 	frameDescriptions = map[string]string{
 		"TCOM": "Composer",
 		"TEXT": "Lyricist",
@@ -35,7 +40,8 @@ var (
 		"TPE3": "Conductor",
 	}
 	ErrNoEditNeeded = fmt.Errorf("no edit required")
-	trackNameRegex  = regexp.MustCompile(defaultTrackNamePattern)
+	// a subdirectory holding one disc of an album, e.g., "CD1" or "Disc 2"
+	discDirectoryRegex = regexp.MustCompile(`(?i)^(?:cd|dis[ck])[\s_-]*(\d+)$`)
 )
 
 // Track encapsulates data about a track in an album.
@@ -45,10 +51,17 @@ type Track struct {
 	fullPath string
 	// read from the file only when needed; file i/o is expensive
 	metadata *TrackMetadata
+	// like metadata, read only when needed
+	audioInfo      *AudioInfo
+	audioInfoError error
 	// name of the track, without the track number or file extension, e.g., "First Track"
 	name string
 	// number of the track
 	number int
+	// number of the disc holding the track; 0 if the album is not split into discs
+	disc int
+	// performing artist, as named by the track file name; "" if it names none
+	performer string
 }
 
 func (t *Track) GetMetadata() *TrackMetadata {
@@ -64,6 +77,11 @@ func (t *Track) WithNumber(i int) *Track {
 	return t
 }
 
+func (t *Track) WithDisc(i int) *Track {
+	t.disc = i
+	return t
+}
+
 func (t *Track) WithAlbum(a *Album) *Track {
 	t.album = a
 	return t
@@ -79,6 +97,11 @@ func (t *Track) WithMetadata(tm *TrackMetadata) *Track {
 	return t
 }
 
+func (t *Track) WithPerformer(s string) *Track {
+	t.performer = s
+	return t
+}
+
 func (t *Track) WithName(s string) *Track {
 	t.name = s
 	return t
@@ -120,13 +143,40 @@ func (t *Track) Number() int {
 	return t.number
 }
 
+// Disc returns the number of the disc holding the track, as defined by its
+// filename or by the disc subdirectory containing it; 0 if neither defines it.
+func (t *Track) Disc() int {
+	return t.disc
+}
+
+// Performer returns the performing artist named by the track's file name; it is
+// "" if the file name names none.
+func (t *Track) Performer() string {
+	return t.performer
+}
+
+// artistName returns the name that the track's artist name metadata should
+// hold: that of its artist directory, unless the directory holds compilations,
+// whose tracks are by many artists; there, it is the performer named by the
+// file name, and "" if the file name names none.
+func (t *Track) artistName() string {
+	if t.album.artist.IsCompilation() {
+		return t.performer
+	}
+	return t.album.artist.canonicalName
+}
+
 func (t *Track) Copy(a *Album) *Track {
 	return &Track{
-		fullPath: t.fullPath,
-		name:     t.name,
-		number:   t.number,
-		metadata: t.metadata,
-		album:    a, // do not use source track's album!
+		fullPath:       t.fullPath,
+		name:           t.name,
+		number:         t.number,
+		disc:           t.disc,
+		performer:      t.performer,
+		metadata:       t.metadata,
+		album:          a, // do not use source track's album!
+		audioInfo:      t.audioInfo,
+		audioInfoError: t.audioInfoError,
 	}
 }
 
@@ -152,7 +202,7 @@ func (ts Tracks) Len() int {
 // artist. If the tracks are from the same artist, then it returns true if the
 // first track's album comes before the second track's album. If the tracks come
 // from the same artist and album, then it returns true if the first track's
-// track number comes before the second track's track number.
+// disc and track number come before the second track's disc and track number.
 func (ts Tracks) Less(i, j int) bool {
 	track1 := ts[i]
 	track2 := ts[j]
@@ -164,7 +214,10 @@ func (ts Tracks) Less(i, j int) bool {
 	if artist1 == artist2 {
 		// artist names are the same ... try the album name next
 		if album1.Name() == album2.Name() {
-			// and album names are the same ... go by track number
+			// and album names are the same ... go by disc and track number
+			if track1.disc != track2.disc {
+				return track1.disc < track2.disc
+			}
 			return track1.number < track2.number
 		}
 		return album1.Name() < album2.Name()
@@ -191,15 +244,18 @@ func (t *Track) SetMetadata(tM *TrackMetadata) {
 
 // MetadataState contains information about metadata problems
 type MetadataState struct {
-	hasError           bool
-	noMetadata         bool
-	numberingConflict  bool
-	trackNameConflict  bool
-	albumNameConflict  bool
-	artistNameConflict bool
-	genreConflict      bool
-	yearConflict       bool
-	mcdiConflict       bool
+	hasError            bool
+	noMetadata          bool
+	numberingConflict   bool
+	trackNameConflict   bool
+	albumNameConflict   bool
+	artistNameConflict  bool
+	genreConflict       bool
+	yearConflict        bool
+	mcdiConflict        bool
+	discConflict        bool
+	albumArtistConflict bool
+	compilationConflict bool
 }
 
 // HasNumberingConflict returns true if there is a conflict between the track
@@ -238,7 +294,10 @@ func (m MetadataState) HasConflicts() bool {
 		m.artistNameConflict ||
 		m.genreConflict ||
 		m.yearConflict ||
-		m.mcdiConflict
+		m.mcdiConflict ||
+		m.discConflict ||
+		m.albumArtistConflict ||
+		m.compilationConflict
 }
 
 // HasMCDIConflict returns true if there is conflict between the track's album's
@@ -247,6 +306,26 @@ func (m MetadataState) HasMCDIConflict() bool {
 	return m.mcdiConflict
 }
 
+// HasDiscConflict returns true if there is a conflict between the track's disc
+// number (as derived from its file name or disc subdirectory) and the value of
+// the track's ID3V2 TPOS frame.
+func (m MetadataState) HasDiscConflict() bool {
+	return m.discConflict
+}
+
+// HasAlbumArtistConflict returns true if there is a conflict between the name
+// of the track's artist directory and the value of the track's ID3V2 TPE2 frame.
+func (m MetadataState) HasAlbumArtistConflict() bool {
+	return m.albumArtistConflict
+}
+
+// HasCompilationConflict returns true if the track's artist directory holds
+// compilations, but the track's ID3V2 TCMP frame does not mark it as part of
+// one.
+func (m MetadataState) HasCompilationConflict() bool {
+	return m.compilationConflict
+}
+
 // HasGenreConflict returns true if there is conflict between the track's
 // album's genre and the value of any of the track's genre metadata.
 func (m MetadataState) HasGenreConflict() bool {
@@ -260,7 +339,10 @@ func (m MetadataState) HasYearConflict() bool {
 }
 
 // ReconcileMetadata determines whether there are problems with the track's
-// metadata.
+// metadata. The tracks of a compilation are by many artists, so their artist
+// name metadata is not compared with the name of the artist directory, but
+// only with the performer named by the track file name, if any; their album
+// artist metadata is compared with the name of the artist directory.
 func (t *Track) ReconcileMetadata() MetadataState {
 	if t.metadata == nil {
 		return MetadataState{noMetadata: true}
@@ -268,14 +350,20 @@ func (t *Track) ReconcileMetadata() MetadataState {
 	if !t.metadata.IsValid() {
 		return MetadataState{hasError: true}
 	}
+	artist := t.album.artist
+	compilation := artist.IsCompilation()
+	artistName := t.artistName()
 	return MetadataState{
-		numberingConflict:  t.metadata.TrackDiffers(t.number),
-		trackNameConflict:  t.metadata.TrackTitleDiffers(t.name),
-		albumNameConflict:  t.metadata.AlbumTitleDiffers(t.album.canonicalTitle),
-		artistNameConflict: t.metadata.ArtistNameDiffers(t.album.artist.canonicalName),
-		genreConflict:      t.metadata.GenreDiffers(t.album.canonicalGenre),
-		yearConflict:       t.metadata.YearDiffers(t.album.canonicalYear),
-		mcdiConflict:       t.metadata.MCDIDiffers(t.album.musicCDIdentifier),
+		numberingConflict:   t.metadata.TrackDiffers(t.number),
+		trackNameConflict:   t.metadata.TrackTitleDiffers(t.name),
+		albumNameConflict:   t.metadata.AlbumTitleDiffers(t.album.canonicalTitle),
+		artistNameConflict:  artistName != "" && t.metadata.ArtistNameDiffers(artistName),
+		genreConflict:       t.metadata.GenreDiffers(t.album.canonicalGenre),
+		yearConflict:        t.metadata.YearDiffers(t.album.canonicalYear),
+		mcdiConflict:        t.metadata.MCDIDiffers(t.album.musicCDIdentifier),
+		discConflict:        t.metadata.DiscDiffers(t.disc),
+		albumArtistConflict: t.metadata.AlbumArtistDiffers(artist.canonicalName, compilation),
+		compilationConflict: t.metadata.CompilationDiffers(compilation),
 	}
 }
 
@@ -293,7 +381,7 @@ func (t *Track) ReportMetadataProblems() []string {
 	if !s.HasConflicts() {
 		return nil
 	}
-	// 7: 1 each for
+	// 10: 1 each for
 	// - track numbering conflict
 	// - track name conflict
 	// - album name conflict
@@ -301,7 +389,10 @@ func (t *Track) ReportMetadataProblems() []string {
 	// - album year conflict
 	// - album genre conflict
 	// - MCDI conflict
-	diffs := make([]string, 0, 7)
+	// - disc number conflict
+	// - album artist conflict
+	// - compilation conflict
+	diffs := make([]string, 0, 10)
 	if s.HasNumberingConflict() {
 		diffs = append(diffs,
 			fmt.Sprintf("metadata does not agree with track number %d", t.number))
@@ -317,8 +408,7 @@ func (t *Track) ReportMetadataProblems() []string {
 	}
 	if s.HasArtistNameConflict() {
 		diffs = append(diffs,
-			fmt.Sprintf("metadata does not agree with artist name %q",
-				t.album.artist.canonicalName))
+			fmt.Sprintf("metadata does not agree with artist name %q", t.artistName()))
 	}
 	if s.HasGenreConflict() {
 		diffs = append(diffs,
@@ -335,6 +425,18 @@ func (t *Track) ReportMetadataProblems() []string {
 			fmt.Sprintf("metadata does not agree with the MCDI frame %q",
 				string(t.album.musicCDIdentifier.Body)))
 	}
+	if s.HasDiscConflict() {
+		diffs = append(diffs,
+			fmt.Sprintf("metadata does not agree with disc number %d", t.disc))
+	}
+	if s.HasAlbumArtistConflict() {
+		diffs = append(diffs,
+			fmt.Sprintf("metadata does not agree with album artist %q",
+				t.album.artist.canonicalName))
+	}
+	if s.HasCompilationConflict() {
+		diffs = append(diffs, "metadata does not identify the album as a compilation")
+	}
 	sort.Strings(diffs)
 	return diffs
 }
@@ -346,37 +448,116 @@ func (t *Track) UpdateMetadata() (e []error) {
 		e = append(e, ErrNoEditNeeded)
 	} else {
 		e = append(e, updateMetadata(t.metadata, t.fullPath)...)
+		ForgetCachedFile(t.fullPath)
 	}
 	return
 }
 
-// use of semaphores nicely documented here:
-// https://gist.github.com/repejota/ed9070d57c23102d50c94e1a126b2f5b
+// MissingTags returns the metadata sources, such as ID3V1, that apply to the
+// track file and could be created, but that are absent from it
+func (t *Track) MissingTags() []MetadataSource {
+	var missing []MetadataSource
+	for _, src := range metadataSources {
+		if cS, ok := src.(CreatableSource); ok && appliesTo(src, t.fullPath) &&
+			cS.Absent(t.fullPath) {
+			missing = append(missing, src)
+		}
+	}
+	return missing
+}
 
-type empty struct{}
+// CreateMissingTags creates the tags returned by MissingTags, filling them with
+// the track's number and name, the names of its album and artist (for a
+// compilation's track, the performer named by its file name, if any), and its
+// album's genre and year
+func (t *Track) CreateMissingTags() (e []error) {
+	defer ForgetCachedFile(t.fullPath)
+	if t.metadata == nil {
+		t.metadata = NewTrackMetadata()
+	}
+	for _, src := range t.MissingTags() {
+		sT := src.Type()
+		t.metadata.requiresEdit[sT] = true
+		t.metadata.correctedTrackNumber[sT] = t.number
+		t.metadata.correctedTrackName[sT] = t.name
+		t.metadata.correctedAlbumName[sT] = t.album.canonicalTitle
+		if artistName := t.artistName(); artistName != "" {
+			t.metadata.correctedArtistName[sT] = artistName
+		} else {
+			t.metadata.correctedArtistName[sT] = t.album.artist.canonicalName
+		}
+		t.metadata.correctedGenre[sT] = t.album.canonicalGenre
+		t.metadata.correctedYear[sT] = t.album.canonicalYear
+		t.metadata.correctedDiscNumber = t.disc
+		if t.album.artist.IsCompilation() {
+			t.metadata.correctedAlbumArtistName = t.album.artist.canonicalName
+			t.metadata.correctedCompilation = true
+		}
+		if err := src.(CreatableSource).Create(t.metadata, t.fullPath); err != nil {
+			e = append(e, err)
+		}
+	}
+	return
+}
 
-func (t *Track) LoadMetadata(bar *pb.ProgressBar) {
-	if t.NeedsMetadata() {
-		openFiles <- empty{} // block while full
-		go func() {
-			defer func() {
-				bar.Increment()
-				<-openFiles // read to release a slot
-			}()
-			t.SetMetadata(ReadRawMetadata(t.fullPath))
-		}()
+// LoadMetadata reads the track's metadata, if it has not already been read
+func (t *Track) LoadMetadata() error {
+	if !t.NeedsMetadata() {
+		return nil
 	}
+	return t.loadMetadata()
 }
 
-// ReadMetadata reads the metadata for all the artists' tracks.
-func ReadMetadata(o output.Bus, artists []*Artist) {
-	// count the tracks
-	count := 0
+// ReadMetadata reads the metadata for all the artists' tracks. When the context
+// is cancelled, the tracks being read are finished, the rest are left unread, and
+// the context's error is returned; the albums and artists are not processed, as
+// their tracks' metadata is incomplete.
+func ReadMetadata(ctx context.Context, o output.Bus, artists []*Artist) error {
+	err := readTrackMetadata(ctx, o, artistTracks(artists), metadataReaders(artists))
+	if err != nil {
+		return err
+	}
+	ProcessAlbumMetadata(o, artists)
+	ProcessArtistMetadata(o, artists)
+	reportAllTrackErrors(o, artists)
+	return nil
+}
+
+// LoadArtistMetadata reads the metadata of those of the artists' tracks that
+// have not been read yet, without processing the albums and artists, so that
+// the metadata can be used to select tracks; it does nothing if every track has
+// been read, and returns the context's error if it is cancelled.
+func LoadArtistMetadata(ctx context.Context, o output.Bus, artists []*Artist) error {
+	tracks := artistTracks(artists)
+	if !slices.ContainsFunc(tracks, (*Track).NeedsMetadata) {
+		return nil
+	}
+	return readTrackMetadata(ctx, o, tracks, metadataReaders(artists))
+}
+
+// metadataReaders returns the number of track files whose metadata may be read
+// at the same time, as specified by the settings of the first artist; the
+// artists are expected to have been loaded with the same settings
+func metadataReaders(artists []*Artist) int {
+	if len(artists) == 0 {
+		return DefaultMetadataReaders
+	}
+	return artists[0].ReadSettings().MetadataReaders()
+}
+
+func artistTracks(artists []*Artist) []*Track {
+	var tracks []*Track
 	for _, artist := range artists {
 		for _, album := range artist.Albums() {
-			count += len(album.Tracks())
+			tracks = append(tracks, album.Tracks()...)
 		}
 	}
+	return tracks
+}
+
+// readTrackMetadata reads the metadata of those tracks that need it, showing its
+// progress; tracks read earlier count as already done
+func readTrackMetadata(ctx context.Context, o output.Bus, tracks []*Track, readers int) error {
 	o.WriteCanonicalError("Reading track metadata")
 	// derived from the Default ProgressBarTemplate used by the progress bar,
 	// following guidance in the ElementSpeed definition to change the output to
@@ -384,19 +565,35 @@ func ReadMetadata(o output.Bus, artists []*Artist) {
 	t := `{{with string . "prefix"}}{{.}} {{end}}{{counters . }} {{bar . }}` +
 		` {{percent . }} {{speed . "%s tracks per second"}}{{with string . "suffix"}}` +
 		` {{.}}{{end}}`
-	bar := pb.New(count).SetWriter(GetBestWriter(o)).SetTemplateString(t).Start()
-	for _, artist := range artists {
-		for _, album := range artist.Albums() {
-			for _, track := range album.Tracks() {
-				track.LoadMetadata(bar)
-			}
+	bar := pb.New(len(tracks)).SetWriter(GetBestWriter(o)).SetTemplateString(t)
+	for _, track := range tracks {
+		if !track.NeedsMetadata() {
+			bar.Increment()
 		}
 	}
-	WaitForFilesClosed()
+	bar.Start()
+	var read atomic.Int64
+	err := LoadTrackMetadata(ctx, tracks, readers, func() {
+		read.Add(1)
+		bar.Increment()
+	})
 	bar.Finish()
-	ProcessAlbumMetadata(o, artists)
-	ProcessArtistMetadata(o, artists)
-	reportAllTrackErrors(o, artists)
+	if ctx.Err() != nil {
+		o.WriteCanonicalError("Reading track metadata was interrupted after %d of %d tracks",
+			read.Load(), len(tracks))
+		o.Log(output.Info, "track metadata reading interrupted", map[string]any{
+			"read":   read.Load(),
+			"tracks": len(tracks),
+		})
+		return ctx.Err()
+	}
+	if err != nil {
+		o.WriteCanonicalError("Not all track metadata could be read: %v", err)
+		o.Log(output.Error, "cannot read track metadata", map[string]any{
+			"error": err,
+		})
+	}
+	return nil
 }
 
 func GetBestWriter(o output.Bus) io.Writer {
@@ -472,7 +669,9 @@ func ProcessAlbumMetadata(o output.Bus, artists []*Artist) {
 				recordedMCDIs[mcdiKey]++
 				recordedMCDIFrames[mcdiKey] = t.metadata.CanonicalMusicCDIdentifier()
 			}
-			if canonicalGenre, ok := CanonicalChoice(recordedGenres); !ok {
+			if al.pathGenre != "" {
+				al.canonicalGenre = al.pathGenre
+			} else if canonicalGenre, ok := CanonicalChoice(recordedGenres); !ok {
 				reportAmbiguousChoices(o, "genre",
 					fmt.Sprintf("%s by %s", al.Name(), ar.Name()), recordedGenres)
 				logAmbiguousValue(o, map[string]any{
@@ -484,7 +683,9 @@ func ProcessAlbumMetadata(o output.Bus, artists []*Artist) {
 			} else {
 				al.canonicalGenre = canonicalGenre
 			}
-			if canonicalYear, ok := CanonicalChoice(recordedYears); !ok {
+			if al.pathYear != "" {
+				al.canonicalYear = al.pathYear
+			} else if canonicalYear, ok := CanonicalChoice(recordedYears); !ok {
 				reportAmbiguousChoices(o, "year",
 					fmt.Sprintf("%s by %s", al.Name(), ar.Name()), recordedYears)
 				logAmbiguousValue(o, map[string]any{
@@ -587,51 +788,55 @@ func reportAllTrackErrors(o output.Bus, artists []*Artist) {
 
 func (t *Track) ReportMetadataErrors(o output.Bus) {
 	if t.HasMetadataError() {
-		for _, sT := range []SourceType{ID3V1, ID3V2} {
+		for _, src := range metadataSources {
 			if metadata := t.metadata; metadata != nil {
-				if e := metadata.errorCause[sT]; e != "" {
-					t.ReportMetadataReadError(o, sT, e)
+				if e := metadata.errorCause[src.Type()]; e != "" && !isMissing(src, e) {
+					t.ReportMetadataReadError(o, src.Type(), e)
 				}
 			}
 		}
 	}
 }
 
-func WaitForFilesClosed() {
-	for len(openFiles) != 0 {
-		time.Sleep(1 * time.Microsecond)
+// ParseTrackName parses a track file name into the track's name and number,
+// using the first of the track name patterns that matches the file name without
+// its extension; the pattern may also yield the disc number, as it does for a
+// file name such as "2-01 Intro.mp3", and the performing artist
+func ParseTrackName(o output.Bus, name string, album *Album, ext string,
+	patterns []*regexp.Regexp) (commonName, performer string, disc, trackNumber int,
+	valid bool) {
+	if strings.HasSuffix(name, ext) {
+		commonName, performer, disc, trackNumber, valid = matchTrackName(
+			strings.TrimSuffix(name, ext), patterns)
 	}
-}
-
-func ParseTrackName(o output.Bus, name string, album *Album,
-	ext string) (commonName string, trackNumber int, valid bool) {
-	if !trackNameRegex.MatchString(name) {
+	if !valid {
 		o.Log(output.Error, "the track name cannot be parsed", map[string]any{
 			"trackName":  name,
 			"albumName":  album.title,
 			"artistName": album.RecordingArtistName(),
 		})
-		o.WriteCanonicalError("The track %q on album %q by artist %q cannot be parsed",
-			name, album.title, album.RecordingArtistName())
-		return
 	}
-	wantDigit := true
-	runes := []rune(name)
-	for i, r := range runes {
-		if wantDigit {
-			if r >= '0' && r <= '9' {
-				trackNumber *= 10
-				trackNumber += int(r - '0')
-			} else {
-				wantDigit = false
-			}
-		} else {
-			commonName = strings.TrimSuffix(string(runes[i:]), ext)
-			break
+	return
+}
+
+// DiscDirectoryNumber returns the disc number of an album subdirectory, such as
+// "CD1" or "Disc 2", that holds one disc of a multi-disc album
+func DiscDirectoryNumber(name string) (int, bool) {
+	if matches := discDirectoryRegex.FindStringSubmatch(name); matches != nil {
+		if disc, err := strconv.Atoi(matches[1]); err == nil {
+			return disc, true
 		}
 	}
-	valid = true
-	return
+	return 0, false
+}
+
+// readSettings returns the settings with which the track is read: those of its
+// artist, if it has one
+func (t *Track) readSettings() *ReadSettings {
+	if t.album == nil || t.album.artist == nil {
+		return defaultReadSettings
+	}
+	return t.album.artist.ReadSettings()
 }
 
 // AlbumPath returns the path of the track's album.
@@ -664,32 +869,98 @@ func (t *Track) CopyFile(destination string) error {
 	return cmd_toolkit.CopyFile(t.fullPath, destination)
 }
 
-// ID3V1Diagnostics returns the ID3V1 tag contents, if any; a missing ID3V1 tag
-// (e.g., the input file is too short to have an ID3V1 tag), or an invalid ID3V1
-// tag (IsValid() is false), returns a non-nil error
-func (t *Track) ID3V1Diagnostics() ([]string, error) {
-	return ReadID3v1Metadata(t.fullPath)
-}
-
-// ID3V2Diagnostics returns ID3V2 tag data - the ID3V2 version, its encoding,
-// and a slice of all the frames in the tag.
-func (t *Track) ID3V2Diagnostics() (version byte, encoding string, frames []string, e error) {
-	version, encoding, frames, _, e = ReadID3V2Metadata(t.fullPath)
-	return
+// MetadataSources returns the metadata sources that apply to the track, in the
+// order in which they are considered when selecting its primary source
+func (t *Track) MetadataSources() []MetadataSource {
+	var sources []MetadataSource
+	for _, sT := range t.readSettings().PrimarySourcePriority() {
+		if src, ok := lookupMetadataSource(sT); ok && appliesTo(src, t.fullPath) {
+			sources = append(sources, src)
+		}
+	}
+	return sources
 }
 
-// Details returns relevant details about the track
+// Details returns relevant details about the track, as provided by the first of
+// its metadata sources that can provide them, and, if the track's MPEG audio
+// stream can be read, a description of that stream
 func (t *Track) Details() (map[string]string, error) {
-	if _, _, _, frames, err := ReadID3V2Metadata(t.fullPath); err != nil {
-		return nil, err
-	} else {
+	return readCachedDetails(t.fullPath, t.readSettings(), func() (map[string]string, error) {
 		m := map[string]string{}
-		// only include known frames
-		for _, frame := range frames {
-			if value, ok := frameDescriptions[frame.name]; ok {
-				m[value] = frame.value
+		for _, src := range t.MetadataSources() {
+			if dS, ok := src.(DetailsSource); ok {
+				var err error
+				if m, err = dS.Details(t.fullPath); err != nil {
+					return nil, err
+				}
+				break
 			}
 		}
+		if ai, err := t.AudioInfo(); err == nil {
+			maps.Copy(m, ai.details())
+		}
 		return m, nil
+	})
+}
+
+// AudioInfo returns a description of the track's MPEG audio stream; the file
+// is read the first time the description is requested
+func (t *Track) AudioInfo() (*AudioInfo, error) {
+	if t.audioInfo == nil && t.audioInfoError == nil {
+		t.audioInfo, t.audioInfoError = readCachedAudioInfo(t.fullPath, t.readSettings())
 	}
+	return t.audioInfo, t.audioInfoError
+}
+
+// ReportAudioProblems returns a slice of strings describing the problems found
+// by walking the frames of the track's MPEG audio stream; tracks whose files
+// belong to another format, such as FLAC, are not checked.
+func (t *Track) ReportAudioProblems() []string {
+	if isClaimed(t.fullPath) {
+		return nil
+	}
+	return readCachedAudioProblems(t.fullPath, t.readSettings(), func() []string {
+		problems, err := CheckAudioIntegrity(t.fullPath)
+		if err != nil {
+			return []string{fmt.Sprintf("audio integrity cannot be determined: %v", err)}
+		}
+		return problems
+	})
+}
+
+// RedundantTags returns the names of the tags that follow the audio in the
+// track file, provided that the file's ID3V2 tag can be read: they hold nothing
+// that the ID3V2 tag does not, and an ID3V1 tag holds it less faithfully
+func (t *Track) RedundantTags() []string {
+	if RawReadID3V2Metadata(t.fullPath).HasError() {
+		return nil
+	}
+	tags, _ := TrailingTags(t.fullPath)
+	return tags
+}
+
+// ID3V2Normalization returns the changes that normalizing the track file's ID3V2
+// tag would make; only mp3 files have ID3V2 tags to normalize
+func (t *Track) ID3V2Normalization(target *Id3v2Target) ([]string, error) {
+	if !appliesTo(id3v2Source{}, t.fullPath) {
+		return nil, nil
+	}
+	return ID3V2Normalization(t.fullPath, target)
+}
+
+// NormalizeID3V2 rewrites the track file's ID3V2 tag in the target's version and
+// encoding
+func (t *Track) NormalizeID3V2(target *Id3v2Target) error {
+	if !appliesTo(id3v2Source{}, t.fullPath) {
+		return nil
+	}
+	defer ForgetCachedFile(t.fullPath)
+	return NormalizeID3V2(t.fullPath, target)
+}
+
+// StripRedundantTags removes the tags returned by RedundantTags from the track
+// file
+func (t *Track) StripRedundantTags() error {
+	defer ForgetCachedFile(t.fullPath)
+	return StripTrailingTags(t.fullPath)
 }
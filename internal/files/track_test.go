@@ -1,6 +1,7 @@
 package files_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,7 +13,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/cheggaaa/pb/v3"
 	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 	"github.com/majohn-r/output"
 )
@@ -26,7 +26,10 @@ func Test_parseTrackName(t *testing.T) {
 	}
 	tests := map[string]struct {
 		args
+		patterns        []string
 		wantCommonName  string
+		wantPerformer   string
+		wantDisc        int
 		wantTrackNumber int
 		wantValid       bool
 		output.WantedRecording
@@ -53,6 +56,119 @@ func Test_parseTrackName(t *testing.T) {
 			wantTrackNumber: 60,
 			wantValid:       true,
 		},
+		"disc and track number": {
+			args: args{
+				name: "2-01 track name.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			wantCommonName:  "track name",
+			wantDisc:        2,
+			wantTrackNumber: 1,
+			wantValid:       true,
+		},
+		"two-digit number, hyphen, title beginning with digits": {
+			args: args{
+				name: "10-20 Years.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			wantCommonName:  "20 Years",
+			wantTrackNumber: 10,
+			wantValid:       true,
+		},
+		"zero-padded number, hyphen, title beginning with digits": {
+			args: args{
+				name: "01-99 Luftballons.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			wantCommonName:  "99 Luftballons",
+			wantTrackNumber: 1,
+			wantValid:       true,
+		},
+		"hyphen separator, name begins with digits": {
+			args: args{
+				name: "3-1999 was a very good year.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			wantCommonName:  "1999 was a very good year",
+			wantTrackNumber: 3,
+			wantValid:       true,
+		},
+		"other extension": {
+			args: args{
+				name: "07 track name.flac",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".flac",
+			},
+			wantCommonName:  "track name",
+			wantTrackNumber: 7,
+			wantValid:       true,
+		},
+		"configured pattern, period separator": {
+			args: args{
+				name: "01. track name.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			patterns:        []string{`^(?P<track>\d+)\. (?P<title>.+)$`},
+			wantCommonName:  "track name",
+			wantTrackNumber: 1,
+			wantValid:       true,
+		},
+		"configured pattern, vinyl side": {
+			args: args{
+				name: "B2 - track name.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			patterns:        []string{`^(?P<side>[A-Za-z])(?P<track>\d+) - (?P<title>.+)$`},
+			wantCommonName:  "track name",
+			wantDisc:        2,
+			wantTrackNumber: 2,
+			wantValid:       true,
+		},
+		"configured patterns, first match wins": {
+			args: args{
+				name: "some artist - 03 - track name.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			patterns: []string{
+				`^(?P<artist>.+?) - (?P<track>\d+) - (?P<title>.+)$`,
+				`^(?P<title>.+?) - (?P<track>\d+) - .+$`,
+			},
+			wantCommonName:  "track name",
+			wantPerformer:   "some artist",
+			wantTrackNumber: 3,
+			wantValid:       true,
+		},
+		"configured pattern, no match": {
+			args: args{
+				name: "59 track name.mp3",
+				album: files.NewEmptyAlbum().WithTitle("some album").WithArtist(
+					files.NewEmptyArtist().WithFileName("some artist")),
+				ext: ".mp3",
+			},
+			patterns: []string{`^(?P<track>\d+)\. (?P<title>.+)$`},
+			WantedRecording: output.WantedRecording{
+				Log: "level='error'" +
+					" albumName='some album'" +
+					" artistName='some artist'" +
+					" trackName='59 track name.mp3'" +
+					" msg='the track name cannot be parsed'\n",
+			},
+		},
 		"wrong extension": {
 			args: args{
 				name: "59 track name.mp4",
@@ -63,8 +179,6 @@ func Test_parseTrackName(t *testing.T) {
 			wantCommonName:  "track name.mp4",
 			wantTrackNumber: 59,
 			WantedRecording: output.WantedRecording{
-				Error: "The track \"59 track name.mp4\" on album \"some album\" by" +
-					" artist \"some artist\" cannot be parsed.\n",
 				Log: "level='error'" +
 					" albumName='some album'" +
 					" artistName='some artist'" +
@@ -81,8 +195,6 @@ func Test_parseTrackName(t *testing.T) {
 			},
 			wantCommonName: "name",
 			WantedRecording: output.WantedRecording{
-				Error: "The track \"track name.mp3\" on album \"some album\" by artist" +
-					" \"some artist\" cannot be parsed.\n",
 				Log: "level='error'" +
 					" albumName='some album'" +
 					" artistName='some artist'" +
@@ -98,8 +210,6 @@ func Test_parseTrackName(t *testing.T) {
 				ext: ".mp3",
 			},
 			WantedRecording: output.WantedRecording{
-				Error: "The track \"trackName.mp3\" on album \"some album\" by artist" +
-					" \"some artist\" cannot be parsed.\n",
 				Log: "level='error'" +
 					" albumName='some album'" +
 					" artistName='some artist'" +
@@ -110,14 +220,32 @@ func Test_parseTrackName(t *testing.T) {
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
+			patterns := files.DefaultTrackNamePatterns()
+			if tt.patterns != nil {
+				patterns = nil
+				for _, pattern := range tt.patterns {
+					re, err := files.ParseTrackNamePattern(pattern)
+					if err != nil {
+						t.Fatalf("%s invalid pattern %q: %v", fnName, pattern, err)
+					}
+					patterns = append(patterns, re)
+				}
+			}
 			o := output.NewRecorder()
-			gotCommonName, gotTrackNumber, gotValid := files.ParseTrackName(o,
-				tt.args.name, tt.args.album, tt.args.ext)
+			gotCommonName, gotPerformer, gotDisc, gotTrackNumber, gotValid :=
+				files.ParseTrackName(o, tt.args.name, tt.args.album, tt.args.ext, patterns)
 			if tt.wantValid {
 				if gotCommonName != tt.wantCommonName {
 					t.Errorf("%s gotCommonName = %q, want %q", fnName, gotCommonName,
 						tt.wantCommonName)
 				}
+				if gotPerformer != tt.wantPerformer {
+					t.Errorf("%s gotPerformer = %q, want %q", fnName, gotPerformer,
+						tt.wantPerformer)
+				}
+				if gotDisc != tt.wantDisc {
+					t.Errorf("%s gotDisc = %d, want %d", fnName, gotDisc, tt.wantDisc)
+				}
 				if gotTrackNumber != tt.wantTrackNumber {
 					t.Errorf("%s gotTrackNumber = %d, want %d", fnName, gotTrackNumber,
 						tt.wantTrackNumber)
@@ -135,6 +263,50 @@ func Test_parseTrackName(t *testing.T) {
 	}
 }
 
+func TestParseTrackNamePattern(t *testing.T) {
+	const fnName = "ParseTrackNamePattern()"
+	tests := map[string]struct {
+		pattern string
+		wantErr string
+	}{
+		"good":          {pattern: `^(?P<disc>\d)(?P<track>\d\d) (?P<title>.+)$`},
+		"unnamed group": {pattern: `^(\d+)-(?P<track>\d+) (?P<title>.+)$`},
+		"bad regex": {
+			pattern: `^(?P<track>\d+`,
+			wantErr: "error parsing regexp: missing closing ): `^(?P<track>\\d+`",
+		},
+		"unknown group": {
+			pattern: `^(?P<track>\d+) (?P<song>.+)$`,
+			wantErr: `the track name pattern "^(?P<track>\\d+) (?P<song>.+)$" names the` +
+				` group "song", which is not one of "artist", "disc", "side", "title", or` +
+				` "track"`,
+		},
+		"no title": {
+			pattern: `^(?P<track>\d+)`,
+			wantErr: `the track name pattern "^(?P<track>\\d+)" does not name the group` +
+				` "title"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.ParseTrackNamePattern(tt.pattern)
+			if err != nil {
+				if err.Error() != tt.wantErr {
+					t.Errorf("%s error = %q, want %q", fnName, err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("%s error = nil, want %q", fnName, tt.wantErr)
+				return
+			}
+			if got.String() != tt.pattern {
+				t.Errorf("%s = %q, want %q", fnName, got, tt.pattern)
+			}
+		})
+	}
+}
+
 func Test_sortTracks(t *testing.T) {
 	const fnName = "sortTracks()"
 	tests := map[string]struct {
@@ -157,6 +329,18 @@ func Test_sortTracks(t *testing.T) {
 					files.NewAlbum("album5", files.NewArtist("artist2", ""), "")),
 			},
 		},
+		"multi-disc album": {
+			tracks: []*files.Track{
+				files.NewEmptyTrack().WithDisc(2).WithNumber(1).WithAlbum(
+					files.NewAlbum("album1", files.NewArtist("artist1", ""), "")),
+				files.NewEmptyTrack().WithDisc(1).WithNumber(2).WithAlbum(
+					files.NewAlbum("album1", files.NewArtist("artist1", ""), "")),
+				files.NewEmptyTrack().WithDisc(2).WithNumber(2).WithAlbum(
+					files.NewAlbum("album1", files.NewArtist("artist1", ""), "")),
+				files.NewEmptyTrack().WithDisc(1).WithNumber(1).WithAlbum(
+					files.NewAlbum("album1", files.NewArtist("artist1", ""), "")),
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -180,7 +364,12 @@ func Test_sortTracks(t *testing.T) {
 							" track[%d] album name %q", fnName, i-1, album1.Name(), i,
 							album2.Name())
 					} else if album1.Name() == album2.Name() {
-						if track1.Number() > track2.Number() {
+						if track1.Disc() > track2.Disc() {
+							t.Errorf("%s track[%d] disc %d comes after"+
+								" track[%d] disc %d", fnName, i-1, track1.Disc(), i,
+								track2.Disc())
+						} else if track1.Disc() == track2.Disc() &&
+							track1.Number() > track2.Number() {
 							t.Errorf("%s track[%d] track %d comes after"+
 								" track[%d] track %d", fnName, i-1, track1.Number(), i,
 								track2.Number())
@@ -192,6 +381,30 @@ func Test_sortTracks(t *testing.T) {
 	}
 }
 
+func TestDiscDirectoryNumber(t *testing.T) {
+	const fnName = "DiscDirectoryNumber()"
+	tests := map[string]struct {
+		name   string
+		want   int
+		wantOk bool
+	}{
+		"CD":          {name: "CD1", want: 1, wantOk: true},
+		"disc":        {name: "Disc 2", want: 2, wantOk: true},
+		"disk":        {name: "disk_03", want: 3, wantOk: true},
+		"hyphenated":  {name: "cd-4", want: 4, wantOk: true},
+		"album":       {name: "Abbey Road", want: 0, wantOk: false},
+		"disc prefix": {name: "Discovery", want: 0, wantOk: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, gotOk := files.DiscDirectoryNumber(tt.name)
+			if got != tt.want || gotOk != tt.wantOk {
+				t.Errorf("%s = %d, %t, want %d, %t", fnName, got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
 func TestTrack_AlbumPath(t *testing.T) {
 	const fnName = "Track.AlbumPath()"
 	tests := map[string]struct {
@@ -377,7 +590,7 @@ func TestTrack_Directory(t *testing.T) {
 		"typical": {
 			t: files.NewEmptyTrack().WithFullPath(
 				"Music/my artist/my album/03 track.mp3"),
-			want: "Music\\my artist\\my album",
+			want: filepath.Join("Music", "my artist", "my album"),
 		},
 	}
 	for name, tt := range tests {
@@ -455,172 +668,6 @@ func Test_pickKey(t *testing.T) {
 	}
 }
 
-func TestTrack_ID3V2Diagnostics(t *testing.T) {
-	const fnName = "Track.ID3V2Diagnostics()"
-	audio := make([]byte, 0)
-	for k := 0; k < 256; k++ {
-		audio = append(audio, byte(k))
-	}
-	frames := map[string]string{
-		"TYER": "2022",
-		"TALB": "unknown album",
-		"TRCK": "2",
-		"TCON": "dance music",
-		"TCOM": "a couple of idiots",
-		"TIT2": "unknown track",
-		"TPE1": "unknown artist",
-		"TLEN": "1000",
-		"T???": "who knows?",
-		"Fake": "ummm",
-	}
-	content := createID3v2TaggedData(audio, frames)
-	if err := createFileWithContent(".", "goodFile.mp3", content); err != nil {
-		t.Errorf("%s failed to create ./goodFile.mp3: %v", fnName, err)
-	}
-	defer func() {
-		if err := os.Remove("./goodFile.mp3"); err != nil {
-			t.Errorf("%s failed to delete ./goodFile.mp3: %v", fnName, err)
-		}
-	}()
-	tests := map[string]struct {
-		t           *files.Track
-		wantEnc     string
-		wantVersion byte
-		wantF       []string
-		wantErr     bool
-	}{
-		"error case": {
-			t:       files.NewEmptyTrack().WithFullPath("./no such file"),
-			wantErr: true,
-		},
-		"good case": {
-			t:           files.NewEmptyTrack().WithFullPath("./goodfile.mp3"),
-			wantEnc:     "ISO-8859-1",
-			wantVersion: 3,
-			wantF: []string{
-				"Fake = \"<<[]byte{0x0, 0x75, 0x6d, 0x6d, 0x6d}>>\"",
-				"T??? = \"who knows?\"",
-				"TALB = \"unknown album\"",
-				"TCOM = \"a couple of idiots\"",
-				"TCON = \"dance music\"",
-				"TIT2 = \"unknown track\"",
-				"TLEN = \"1000\"",
-				"TPE1 = \"unknown artist\"",
-				"TRCK = \"2\"",
-				"TYER = \"2022\"",
-			},
-		},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			gotVersion, gotEnc, gotF, err := tt.t.ID3V2Diagnostics()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
-				return
-			}
-			if gotEnc != tt.wantEnc {
-				t.Errorf("%s gotEnc = %q, want %q", fnName, gotEnc, tt.wantEnc)
-			}
-			if gotVersion != tt.wantVersion {
-				t.Errorf("%s gotVersion = %d, want %d", fnName, gotVersion, tt.wantVersion)
-			}
-			if !reflect.DeepEqual(gotF, tt.wantF) {
-				t.Errorf("%s gotF = %v, want %v", fnName, gotF, tt.wantF)
-			}
-		})
-	}
-}
-
-func TestTrack_ID3V1Diagnostics(t *testing.T) {
-	const fnName = "Track.ID3V1Diagnostics()"
-	testDir := "id3v1Diagnostics"
-	if err := cmd_toolkit.Mkdir(testDir); err != nil {
-		t.Errorf("%s cannot create %q: %v", fnName, testDir, err)
-	}
-	// three files: one good, one too small, one with an invalid tag
-	smallFile := "01 small.mp3"
-	if err := createFileWithContent(testDir, smallFile, []byte{0, 1, 2}); err != nil {
-		t.Errorf("%s cannot create %q: %v", fnName, smallFile, err)
-	}
-	invalidFile := "02 invalid.mp3"
-	if err := createFileWithContent(testDir, invalidFile, []byte{
-		'd', 'A', 'G', // 'd' for defective!
-		'R', 'i', 'n', 'g', 'o', ' ', '-', ' ', 'P', 'o', 'p', ' ', 'P', 'r', 'o', 'f',
-		'i', 'l', 'e', ' ', '[', 'I', 'n', 't', 'e', 'r', 'v', 'i', 'e', 'w',
-		'T', 'h', 'e', ' ', 'B', 'e', 'a', 't', 'l', 'e', 's', 0, 0, 0, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-		'O', 'n', ' ', 'A', 'i', 'r', ':', ' ', 'L', 'i', 'v', 'e', ' ', 'A', 't', ' ',
-		'T', 'h', 'e', ' ', 'B', 'B', 'C', ',', ' ', 'V', 'o', 'l', 'u', 'm',
-		'2', '0', '1', '3',
-		' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ',
-		' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ',
-		0,
-		29,
-		12,
-	}); err != nil {
-		t.Errorf("%s cannot create %q: %v", fnName, invalidFile, err)
-	}
-	goodFile := "02 good.mp3"
-	if err := createFileWithContent(testDir, goodFile, []byte{
-		'T', 'A', 'G',
-		'R', 'i', 'n', 'g', 'o', ' ', '-', ' ', 'P', 'o', 'p', ' ', 'P', 'r', 'o', 'f',
-		'i', 'l', 'e', ' ', '[', 'I', 'n', 't', 'e', 'r', 'v', 'i', 'e', 'w',
-		'T', 'h', 'e', ' ', 'B', 'e', 'a', 't', 'l', 'e', 's', 0, 0, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
-		'O', 'n', ' ', 'A', 'i', 'r', ':', ' ', 'L', 'i', 'v', 'e', ' ', 'A', 't', ' ',
-		'T', 'h', 'e', ' ', 'B', 'B', 'C', ',', ' ', 'V', 'o', 'l', 'u', 'm',
-		'2', '0', '1', '3',
-		's', 'i', 'l', 'l', 'y', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ',
-		' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ', ' ',
-		0,
-		29,
-		12,
-	}); err != nil {
-		t.Errorf("%s cannot create %q: %v", fnName, goodFile, err)
-	}
-	defer func() {
-		destroyDirectory(fnName, testDir)
-	}()
-	tests := map[string]struct {
-		t       *files.Track
-		want    []string
-		wantErr bool
-	}{
-		"small file": {
-			t:       files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, smallFile)),
-			wantErr: true,
-		},
-		"invalid file": {
-			t:       files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, invalidFile)),
-			wantErr: true,
-		},
-		"good file": {
-			t: files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, goodFile)),
-			want: []string{
-				"Artist: \"The Beatles\"",
-				"Album: \"On Air: Live At The BBC, Volum\"",
-				"Title: \"Ringo - Pop Profile [Interview\"",
-				"Track: 29",
-				"Year: \"2013\"",
-				"Genre: \"Other\"",
-				"Comment: \"silly\"",
-			},
-		},
-	}
-	for name, tt := range tests {
-		t.Run(name, func(t *testing.T) {
-			got, err := tt.t.ID3V1Diagnostics()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
-			}
-		})
-	}
-}
-
 var nameToID3V2TagName = map[string]string{
 	"artist": "TPE1",
 	"album":  "TALB",
@@ -715,17 +762,14 @@ func TestTrack_loadMetadata(t *testing.T) {
 				"", trackName, trackName}).WithGenres([]string{
 				"", genre, genre}).WithYears([]string{"", year, year}).WithTrackNumbers(
 				[]int{0, track, track}).WithMusicCDIdentifier(
-				[]byte{0}).WithPrimarySource(files.ID3V2),
+				[]byte{0}).WithPrimarySource(files.ID3V2).WithID3V2Version(3),
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			bar := pb.New(1)
-			bar.SetWriter(output.NewNilBus().ErrorWriter())
-			bar.Start()
-			tt.t.LoadMetadata(bar)
-			files.WaitForFilesClosed()
-			bar.Finish()
+			if err := tt.t.LoadMetadata(); err != nil {
+				t.Errorf("%s error = %v", fnName, err)
+			}
 			if !reflect.DeepEqual(tt.t.GetMetadata(), tt.want) {
 				t.Errorf("%s got %#v want %#v", fnName, tt.t.GetMetadata(), tt.want)
 			}
@@ -783,27 +827,48 @@ func TestReadMetadata(t *testing.T) {
 	defer func() {
 		destroyDirectory(fnName, testDir)
 	}()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
 	type args struct {
+		ctx     context.Context
 		artists []*files.Artist
 	}
 	tests := map[string]struct {
 		args
+		wantErr error
 		output.WantedRecording
 	}{
+		"interrupted": {
+			args:    args{ctx: cancelled, artists: artists},
+			wantErr: context.Canceled,
+			WantedRecording: output.WantedRecording{
+				Error: "Reading track metadata.\n" +
+					"Reading track metadata was interrupted after 0 of 5000 tracks.\n",
+				Log: "level='info'" +
+					" read='0'" +
+					" tracks='5000'" +
+					" msg='track metadata reading interrupted'\n",
+			},
+		},
 		"thorough test": {
-			args:            args{artists: artists},
+			args:            args{ctx: context.Background(), artists: artists},
 			WantedRecording: output.WantedRecording{Error: "Reading track metadata.\n"},
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			o := output.NewRecorder()
-			files.ReadMetadata(o, tt.args.artists)
+			if err := files.ReadMetadata(tt.args.ctx, o, tt.args.artists); err != tt.wantErr {
+				t.Errorf("%s error = %v, want %v", fnName, err, tt.wantErr)
+			}
 			if differences, ok := o.Verify(tt.WantedRecording); !ok {
 				for _, difference := range differences {
 					t.Errorf("%s %s", fnName, difference)
 				}
 			}
+			if tt.wantErr != nil {
+				return
+			}
 			for _, artist := range tt.args.artists {
 				for _, album := range artist.Albums() {
 					for _, track := range album.Tracks() {
@@ -820,6 +885,85 @@ func TestReadMetadata(t *testing.T) {
 	}
 }
 
+func TestLoadArtistMetadata(t *testing.T) {
+	const fnName = "LoadArtistMetadata()"
+	testDir := "loadArtistMetadata"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer destroyDirectory(fnName, testDir)
+	artist := files.NewArtist("artist", testDir)
+	album := files.NewAlbum("album", artist, testDir)
+	artist.AddAlbum(album)
+	for n := 1; n <= 3; n++ {
+		fileName := fmt.Sprintf("%02d track %d.mp3", n, n)
+		content := createConsistentlyTaggedData([]byte{0, 1, 2, byte(n)}, map[string]any{
+			"artist": "artist",
+			"album":  "album",
+			"title":  fmt.Sprintf("track %d", n),
+			"genre":  "Rock",
+			"year":   "2024",
+			"track":  n,
+		})
+		if err := createFileWithContent(testDir, fileName, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, fileName, err)
+		}
+		album.AddTrack(files.NewTrack(album, fileName, fmt.Sprintf("track %d", n), n))
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := map[string]struct {
+		ctx     context.Context
+		wantErr error
+		output.WantedRecording
+	}{
+		"interrupted": {
+			ctx:     cancelled,
+			wantErr: context.Canceled,
+			WantedRecording: output.WantedRecording{
+				Error: "Reading track metadata.\n" +
+					"Reading track metadata was interrupted after 0 of 3 tracks.\n",
+				Log: "level='info'" +
+					" read='0'" +
+					" tracks='3'" +
+					" msg='track metadata reading interrupted'\n",
+			},
+		},
+		"read": {
+			ctx:             context.Background(),
+			WantedRecording: output.WantedRecording{Error: "Reading track metadata.\n"},
+		},
+		// the metadata has been read, and is not read again
+		"read already": {ctx: cancelled},
+	}
+	for _, name := range []string{"interrupted", "read", "read already"} {
+		tt := tests[name]
+		t.Run(name, func(t *testing.T) {
+			o := output.NewRecorder()
+			if err := files.LoadArtistMetadata(tt.ctx, o, []*files.Artist{artist}); !errors.Is(err,
+				tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("%s error = %v, want %v", fnName, err, tt.wantErr)
+			}
+			if differences, ok := o.Verify(tt.WantedRecording); !ok {
+				for _, difference := range differences {
+					t.Errorf("%s %s", fnName, difference)
+				}
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			for _, track := range album.Tracks() {
+				if track.NeedsMetadata() {
+					t.Errorf("%s track %q has no metadata", fnName, track.Path())
+				} else if track.GetMetadata().CanonicalGenre() != "Rock" {
+					t.Errorf("%s track %q genre is %q", fnName, track.Path(),
+						track.GetMetadata().CanonicalGenre())
+				}
+			}
+		})
+	}
+}
+
 func TestTrack_ReportMetadataProblems(t *testing.T) {
 	const fnName = "Track.ReportMetadataProblems()"
 	problematicArtist := files.NewArtist("problematic:artist", "")
@@ -855,6 +999,22 @@ func TestTrack_ReportMetadataProblems(t *testing.T) {
 		goodAlbum, "03 good track.mp3", "good track", 3).WithMetadata(metadata2)
 	goodAlbum.AddTrack(goodTrack)
 	goodArtist.AddAlbum(goodAlbum)
+	compilationArtist := files.NewArtist("Various Artists", "").WithCompilation(true)
+	compilationAlbum := files.NewAlbum("hits", compilationArtist, "").WithCanonicalGenre(
+		"Pop").WithCanonicalYear("1999")
+	metadata3 := files.NewTrackMetadata().WithPrimarySource(src).WithAlbumArtistName(
+		"some band")
+	metadata3.SetAlbumName(src, "hits")
+	metadata3.SetArtistName(src, "some band")
+	metadata3.SetErrorCause(files.ID3V1, "no id3v1 metadata")
+	metadata3.SetGenre(src, "Pop")
+	metadata3.SetTrackName(src, "hit song")
+	metadata3.SetTrackNumber(src, 1)
+	metadata3.SetYear(src, "1999")
+	compilationTrack := files.NewTrack(
+		compilationAlbum, "01 hit song.mp3", "hit song", 1).WithMetadata(metadata3)
+	compilationAlbum.AddTrack(compilationTrack)
+	compilationArtist.AddAlbum(compilationAlbum)
 	tests := map[string]struct {
 		t    *files.Track
 		want []string
@@ -883,6 +1043,28 @@ func TestTrack_ReportMetadataProblems(t *testing.T) {
 			},
 		},
 		"track with no metadata differences": {t: goodTrack, want: nil},
+		"compilation track": {
+			t: compilationTrack,
+			want: []string{
+				"metadata does not agree with album artist \"Various Artists\"",
+				"metadata does not identify the album as a compilation",
+			},
+		},
+		"compilation track naming its performer": {
+			t: compilationTrack.Copy(compilationAlbum).WithPerformer("some band"),
+			want: []string{
+				"metadata does not agree with album artist \"Various Artists\"",
+				"metadata does not identify the album as a compilation",
+			},
+		},
+		"compilation track naming another performer": {
+			t: compilationTrack.Copy(compilationAlbum).WithPerformer("another band"),
+			want: []string{
+				"metadata does not agree with album artist \"Various Artists\"",
+				"metadata does not agree with artist name \"another band\"",
+				"metadata does not identify the album as a compilation",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -926,6 +1108,111 @@ func TestTrack_UpdateMetadata(t *testing.T) {
 			"", "unknown", "unknown"}).WithYears(
 			[]string{"", "1900", "1900"}).WithTrackNumbers(
 			[]int{0, 1, 1}).WithPrimarySource(files.ID3V2))
+	apeTrackName := "edit this ape track.mp3"
+	apeTrackContents := createConsistentlyTaggedData([]byte(apeTrackName), map[string]any{
+		"artist": "unknown artist",
+		"album":  "unknown album",
+		"title":  "unknown title",
+		"genre":  "unknown",
+		"year":   "1900",
+		"track":  1,
+	})
+	id3v1Start := len(apeTrackContents) - 128
+	apeTrackContents = append(append(append([]byte{}, apeTrackContents[:id3v1Start]...),
+		createApeV2TaggedData(map[string]string{
+			"Album":  "unknown album",
+			"Artist": "unknown artist",
+			"Genre":  "unknown",
+			"Title":  "unknown title",
+			"Track":  "1",
+			"Year":   "1900",
+		}, true)...), apeTrackContents[id3v1Start:]...)
+	if err := createFileWithContent(testDir, apeTrackName, apeTrackContents); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, apeTrackName, err)
+	}
+	apeTrack := files.NewEmptyTrack().WithFullPath(
+		filepath.Join(testDir, apeTrackName)).WithName(
+		strings.TrimSuffix(apeTrackName, ".mp3")).WithNumber(2).WithAlbum(
+		files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+			"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+			"fine album").WithMusicCDIdentifier([]byte("fine album")).WithArtist(
+			files.NewEmptyArtist().WithFileName("fine artist").WithCanonicalName(
+				"fine artist"))).WithMetadata(
+		files.NewTrackMetadata().WithAlbumNames([]string{
+			"", "unknown album", "unknown album", "unknown album"}).WithArtistNames(
+			[]string{"", "unknown artist", "unknown artist", "unknown artist"}).WithTrackNames(
+			[]string{"", "unknown title", "unknown title", "unknown title"}).WithGenres(
+			[]string{"", "unknown", "unknown", "unknown"}).WithYears(
+			[]string{"", "1900", "1900", "1900"}).WithTrackNumbers(
+			[]int{0, 1, 1, 1}).WithErrorCauses([]string{"", "", "", ""}).WithPrimarySource(
+			files.ID3V2))
+	flacTrackName := "edit this flac track.flac"
+	flacTrackContents := createFlacData([]string{
+		"ALBUM=unknown album",
+		"ARTIST=unknown artist",
+		"GENRE=unknown",
+		"TITLE=unknown title",
+		"TRACKNUMBER=1",
+		"DATE=1900",
+		"COMPOSER=unknown composer",
+	}, 64, []byte(flacTrackName))
+	if err := createFileWithContent(testDir, flacTrackName, flacTrackContents); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, flacTrackName, err)
+	}
+	flacTrack := files.NewEmptyTrack().WithFullPath(
+		filepath.Join(testDir, flacTrackName)).WithName(
+		strings.TrimSuffix(flacTrackName, ".flac")).WithNumber(2).WithAlbum(
+		files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+			"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+			"fine album").WithMusicCDIdentifier([]byte("fine album")).WithArtist(
+			files.NewEmptyArtist().WithFileName("fine artist").WithCanonicalName(
+				"fine artist"))).WithMetadata(
+		files.ReadRawMetadata(filepath.Join(testDir, flacTrackName), files.NewReadSettings()))
+	discTrackName := "edit this disc track.mp3"
+	discTrackContents := createConsistentlyTaggedData([]byte(discTrackName), map[string]any{
+		"artist": "fine artist",
+		"album":  "fine album",
+		"title":  "edit this disc track",
+		"genre":  "Classic Rock",
+		"year":   "2022",
+		"track":  2,
+	})
+	if err := createFileWithContent(testDir, discTrackName, discTrackContents); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, discTrackName, err)
+	}
+	discTrack := files.NewEmptyTrack().WithFullPath(
+		filepath.Join(testDir, discTrackName)).WithName(
+		strings.TrimSuffix(discTrackName, ".mp3")).WithNumber(2).WithDisc(2).WithAlbum(
+		files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+			"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+			"fine album").WithMusicCDIdentifier([]byte("fine album")).WithArtist(
+			files.NewEmptyArtist().WithFileName("fine artist").WithCanonicalName(
+				"fine artist"))).WithMetadata(
+		files.ReadRawMetadata(filepath.Join(testDir, discTrackName), files.NewReadSettings()))
+	compilationTrackName := "edit this compilation track.mp3"
+	compilationTrackContents := createConsistentlyTaggedData([]byte(compilationTrackName),
+		map[string]any{
+			"artist": "some band",
+			"album":  "fine album",
+			"title":  "edit this compilation track",
+			"genre":  "Classic Rock",
+			"year":   "2022",
+			"track":  2,
+		})
+	if err := createFileWithContent(testDir, compilationTrackName,
+		compilationTrackContents); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, compilationTrackName, err)
+	}
+	compilationTrack := files.NewEmptyTrack().WithFullPath(
+		filepath.Join(testDir, compilationTrackName)).WithName(
+		strings.TrimSuffix(compilationTrackName, ".mp3")).WithNumber(2).WithAlbum(
+		files.NewEmptyAlbum().WithTitle("fine album").WithCanonicalGenre(
+			"Classic Rock").WithCanonicalYear("2022").WithCanonicalTitle(
+			"fine album").WithMusicCDIdentifier([]byte("fine album")).WithArtist(
+			files.NewEmptyArtist().WithFileName("Various Artists").WithCanonicalName(
+				"Various Artists").WithCompilation(true))).WithMetadata(
+		files.ReadRawMetadata(filepath.Join(testDir, compilationTrackName),
+			files.NewReadSettings()))
 	deletedTrack := files.NewEmptyTrack().WithFullPath(
 		filepath.Join(testDir, "no such file")).WithName(
 		strings.TrimSuffix(trackName, ".mp3")).WithNumber(2).WithAlbum(
@@ -947,7 +1234,40 @@ func TestTrack_UpdateMetadata(t *testing.T) {
 		"", "edit this track", "edit this track"}).WithGenres([]string{
 		"", "Classic Rock", "Classic Rock"}).WithYears([]string{
 		"", "2022", "2022"}).WithTrackNumbers([]int{0, 2, 2}).WithMusicCDIdentifier(
-		[]byte("fine album")).WithPrimarySource(files.ID3V2)
+		[]byte("fine album")).WithPrimarySource(files.ID3V2).WithID3V2Version(3)
+	editedApeTm := files.NewTrackMetadata().WithAlbumNames([]string{
+		"", "fine album", "fine album", "fine album"}).WithArtistNames([]string{
+		"", "fine artist", "fine artist", "fine artist"}).WithTrackNames([]string{
+		"", "edit this ape track", "edit this ape track", "edit this ape track"}).WithGenres(
+		[]string{"", "Classic Rock", "Classic Rock", "Classic Rock"}).WithYears([]string{
+		"", "2022", "2022", "2022"}).WithTrackNumbers([]int{0, 2, 2, 2}).WithErrorCauses(
+		[]string{"", "", "", ""}).WithMusicCDIdentifier(
+		[]byte("fine album")).WithPrimarySource(files.ID3V2).WithID3V2Version(3)
+	editedDiscTm := files.NewTrackMetadata().WithAlbumNames([]string{
+		"", "fine album", "fine album"}).WithArtistNames([]string{
+		"", "fine artist", "fine artist"}).WithTrackNames([]string{
+		"", "edit this disc track", "edit this disc track"}).WithGenres([]string{
+		"", "Classic Rock", "Classic Rock"}).WithYears([]string{
+		"", "2022", "2022"}).WithTrackNumbers([]int{0, 2, 2}).WithDiscNumber(
+		2).WithMusicCDIdentifier([]byte("fine album")).WithPrimarySource(
+		files.ID3V2).WithID3V2Version(3)
+	editedCompilationTm := files.NewTrackMetadata().WithAlbumNames([]string{
+		"", "fine album", "fine album"}).WithArtistNames([]string{
+		"", "some band", "some band"}).WithTrackNames([]string{
+		"", "edit this compilation track", "edit this compilation track"}).WithGenres(
+		[]string{"", "Classic Rock", "Classic Rock"}).WithYears([]string{
+		"", "2022", "2022"}).WithTrackNumbers([]int{0, 2, 2}).WithAlbumArtistName(
+		"Various Artists").WithCompilation(true).WithMusicCDIdentifier(
+		[]byte("fine album")).WithPrimarySource(files.ID3V2).WithID3V2Version(3)
+	notApplicable := "metadata source does not apply to this file"
+	editedFlacTm := files.NewTrackMetadata().WithAlbumNames([]string{
+		"", "", "", "", "fine album"}).WithArtistNames([]string{
+		"", "", "", "", "fine artist"}).WithTrackNames([]string{
+		"", "", "", "", "edit this flac track"}).WithGenres([]string{
+		"", "", "", "", "Classic Rock"}).WithYears([]string{
+		"", "", "", "", "2022"}).WithTrackNumbers([]int{0, 0, 0, 0, 2}).WithErrorCauses(
+		[]string{"", notApplicable, notApplicable, notApplicable, ""}).WithPrimarySource(
+		files.FLAC)
 	defer func() {
 		destroyDirectory(fnName, testDir)
 	}()
@@ -959,17 +1279,24 @@ func TestTrack_UpdateMetadata(t *testing.T) {
 		"error checking": {
 			t: deletedTrack,
 			wantE: []string{
-				"open updateMetadata\\no such file: " +
-					"The system cannot find the file specified.",
-				"open updateMetadata\\no such file: " +
-					"The system cannot find the file specified.",
+				"open " + filepath.Join("updateMetadata", "no such file") + ": " +
+					fileNotFound,
+				"open " + filepath.Join("updateMetadata", "no such file") + ": " +
+					fileNotFound,
 			},
 		},
 		"no edit required": {
 			t:     files.NewEmptyTrack().WithMetadata(nil),
 			wantE: []string{files.ErrNoEditNeeded.Error()},
 		},
-		"edit required": {t: track, wantTm: editedTm},
+		"edit required":             {t: track, wantTm: editedTm},
+		"edit required, with APEV2": {t: apeTrack, wantTm: editedApeTm},
+		"edit required, FLAC":       {t: flacTrack, wantTm: editedFlacTm},
+		"edit required, disc":       {t: discTrack, wantTm: editedDiscTm},
+		"edit required, compilation": {
+			t:      compilationTrack,
+			wantTm: editedCompilationTm,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -982,7 +1309,7 @@ func TestTrack_UpdateMetadata(t *testing.T) {
 				t.Errorf("%s = %v, want %v", fnName, eStrings, tt.wantE)
 			} else if len(gotE) == 0 && tt.t.GetMetadata() != nil {
 				// verify file was correctly rewritten
-				gotTm := files.ReadRawMetadata(tt.t.Path())
+				gotTm := files.ReadRawMetadata(tt.t.Path(), files.NewReadSettings())
 				if !reflect.DeepEqual(gotTm, tt.wantTm) {
 					t.Errorf("%s read %#v, want %#v", fnName, gotTm, tt.wantTm)
 				}
@@ -1218,7 +1545,7 @@ func TestTrack_reportMetadataErrors(t *testing.T) {
 		"error handling": {
 			args: args{
 				t: files.NewEmptyTrack().WithName("silly track").WithFullPath(
-					"Music\\silly artist\\silly album\\01 silly track.mp3").WithMetadata(
+					filepath.Join("Music", "silly artist", "silly album", "01 silly track.mp3")).WithMetadata(
 					files.NewTrackMetadata().WithErrorCauses(
 						[]string{"", "id3v1 error!", "id3v2 error!"})).WithAlbum(
 					files.NewEmptyAlbum().WithTitle("silly album").WithArtist(
@@ -1228,12 +1555,12 @@ func TestTrack_reportMetadataErrors(t *testing.T) {
 				Log: "level='error'" +
 					" error='id3v1 error!'" +
 					" metadata='ID3V1'" +
-					" track='Music\\silly artist\\silly album\\01 silly track.mp3'" +
+					" track='" + filepath.Join("Music", "silly artist", "silly album", "01 silly track.mp3") + "'" +
 					" msg='metadata read error'\n" +
 					"level='error'" +
 					" error='id3v2 error!'" +
 					" metadata='ID3V2'" +
-					" track='Music\\silly artist\\silly album\\01 silly track.mp3'" +
+					" track='" + filepath.Join("Music", "silly artist", "silly album", "01 silly track.mp3") + "'" +
 					" msg='metadata read error'\n",
 			},
 		},
@@ -1282,6 +1609,28 @@ func TestTrack_Details(t *testing.T) {
 			t.Errorf("%s failed to delete ./goodFile.mp3: %v", fnName, err)
 		}
 	}()
+	flacContent := createFlacData([]string{"KEY=D Major", "SUBTITLE=Part II"}, 0, audio)
+	if err := createFileWithContent(".", "goodFile.flac", flacContent); err != nil {
+		t.Errorf("%s failed to create ./goodFile.flac: %v", fnName, err)
+	}
+	defer func() {
+		if err := os.Remove("./goodFile.flac"); err != nil {
+			t.Errorf("%s failed to delete ./goodFile.flac: %v", fnName, err)
+		}
+	}()
+	var mpegAudio []byte
+	for k := 0; k < 10; k++ {
+		mpegAudio = append(mpegAudio, createMpegFrame(192, nil)...)
+	}
+	mpegContent := createID3v2TaggedData(mpegAudio, map[string]string{"TKEY": "D Major"})
+	if err := createFileWithContent(".", "mpegFile.mp3", mpegContent); err != nil {
+		t.Errorf("%s failed to create ./mpegFile.mp3: %v", fnName, err)
+	}
+	defer func() {
+		if err := os.Remove("./mpegFile.mp3"); err != nil {
+			t.Errorf("%s failed to delete ./mpegFile.mp3: %v", fnName, err)
+		}
+	}()
 	tests := map[string]struct {
 		t       *files.Track
 		want    map[string]string
@@ -1292,7 +1641,7 @@ func TestTrack_Details(t *testing.T) {
 			wantErr: true,
 		},
 		"good case": {
-			t: files.NewEmptyTrack().WithFullPath("./goodfile.mp3"),
+			t: files.NewEmptyTrack().WithFullPath("./goodFile.mp3"),
 			want: map[string]string{
 				"Composer":       "a couple of idiots",
 				"Lyricist":       "An infinite number of monkeys with a typewriter",
@@ -1302,6 +1651,22 @@ func TestTrack_Details(t *testing.T) {
 				"Conductor":      "Someone with a stick",
 			},
 		},
+		"FLAC case": {
+			t:    files.NewEmptyTrack().WithFullPath("./goodFile.flac"),
+			want: map[string]string{"Key": "D Major", "Subtitle": "Part II"},
+		},
+		"MPEG audio case": {
+			t: files.NewEmptyTrack().WithFullPath("./mpegFile.mp3"),
+			want: map[string]string{
+				"Key":          "D Major",
+				"Audio Format": "MPEG-1 Layer III",
+				"Bitrate":      "192 kbps",
+				"Bitrate Mode": "CBR",
+				"Channel Mode": "Joint Stereo",
+				"Duration":     "0:00",
+				"Sample Rate":  "44100 Hz",
+			},
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -1317,6 +1682,58 @@ func TestTrack_Details(t *testing.T) {
 	}
 }
 
+func TestTrack_ReportAudioProblems(t *testing.T) {
+	const fnName = "Track.ReportAudioProblems()"
+	testDir := "reportAudioProblems"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	var mpegAudio []byte
+	for k := 0; k < 10; k++ {
+		mpegAudio = append(mpegAudio, createMpegFrame(128, nil)...)
+	}
+	fileContents := map[string][]byte{
+		"clean.mp3":     mpegAudio,
+		"truncated.mp3": mpegAudio[:len(mpegAudio)-17],
+		"noise.flac":    {1, 2, 3, 4},
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		t    *files.Track
+		want []string
+	}{
+		"clean": {t: files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, "clean.mp3"))},
+		"truncated": {
+			t: files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, "truncated.mp3")),
+			want: []string{
+				"the final frame, at offset 3753, is truncated: 400 of 417 bytes are present",
+			},
+		},
+		"not MPEG audio": {
+			t: files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, "noise.flac")),
+		},
+		"missing": {
+			t: files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, "missing.mp3")),
+			want: []string{"audio integrity cannot be determined: open " +
+				filepath.Join(testDir, "missing.mp3") + ": " + fileNotFound},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.t.ReportAudioProblems(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
 type sampleWriter struct {
 	name string
 }
@@ -1415,3 +1832,254 @@ func createNamedFile(fileName string, content []byte) (err error) {
 func createFile(dir, name string) (err error) {
 	return createFileWithContent(dir, name, []byte("file contents for "+name))
 }
+
+func TestTrack_MissingTags(t *testing.T) {
+	const fnName = "Track.MissingTags()"
+	testDir := "missingTags"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := createMpegFrame(128, nil)
+	frames := map[string]string{"TIT2": "my track"}
+	fileContents := map[string][]byte{
+		"untagged.mp3": audio,
+		"v1.mp3":       append(append([]byte{}, audio...), id3v1DataSet1...),
+		"v2.mp3":       createID3v2TaggedData(audio, frames),
+		"both.mp3":     append(createID3v2TaggedData(audio, frames), id3v1DataSet1...),
+		"audio.flac":   createFlacData(nil, 0, audio),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name string
+		want []string
+	}{
+		"untagged":       {name: "untagged.mp3", want: []string{"ID3V1", "ID3V2"}},
+		"ID3V1 only":     {name: "v1.mp3", want: []string{"ID3V2"}},
+		"ID3V2 only":     {name: "v2.mp3", want: []string{"ID3V1"}},
+		"both":           {name: "both.mp3"},
+		"not MPEG audio": {name: "audio.flac"},
+		"missing file":   {name: "missing.mp3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			track := files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, tt.name))
+			var got []string
+			for _, src := range track.MissingTags() {
+				got = append(got, src.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrack_CreateMissingTags(t *testing.T) {
+	const fnName = "Track.CreateMissingTags()"
+	testDir := "createMissingTags"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	var audio []byte
+	for k := 0; k < 5; k++ {
+		audio = append(audio, createMpegFrame(128, nil)...)
+	}
+	fileContents := map[string][]byte{
+		"3 untagged.mp3": audio,
+		"4 untagged.mp3": audio,
+		"5 v1.mp3":       append(append([]byte{}, audio...), id3v1DataSet1...),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	artist := files.NewArtist("my artist", "")
+	album := files.NewAlbum("my album", artist, testDir).WithCanonicalGenre(
+		"Rock").WithCanonicalYear("1999")
+	albumWithoutGenre := files.NewAlbum("my album", artist, testDir).WithCanonicalYear(
+		"1999")
+	tests := map[string]struct {
+		t          *files.Track
+		wantID3V1  []string
+		wantFrames []string
+	}{
+		"untagged": {
+			t: files.NewTrack(album, "3 untagged.mp3", "untagged", 3),
+			wantID3V1: []string{
+				"Artist: \"my artist\"",
+				"Album: \"my album\"",
+				"Title: \"untagged\"",
+				"Track: 3",
+				"Year: \"1999\"",
+				"Genre: \"Rock\"",
+			},
+			wantFrames: []string{
+				"TALB = \"my album\"",
+				"TCON = \"Rock\"",
+				"TDRC = \"1999\"",
+				"TIT2 = \"untagged\"",
+				"TPE1 = \"my artist\"",
+				"TRCK = \"3\"",
+			},
+		},
+		"no genre": {
+			t: files.NewTrack(albumWithoutGenre, "4 untagged.mp3", "untagged", 4),
+			wantID3V1: []string{
+				"Artist: \"my artist\"",
+				"Album: \"my album\"",
+				"Title: \"untagged\"",
+				"Track: 4",
+				"Year: \"1999\"",
+			},
+			wantFrames: []string{
+				"TALB = \"my album\"",
+				"TDRC = \"1999\"",
+				"TIT2 = \"untagged\"",
+				"TPE1 = \"my artist\"",
+				"TRCK = \"4\"",
+			},
+		},
+		"existing ID3V1 tag is preserved": {
+			t: files.NewTrack(album, "5 v1.mp3", "v1", 5),
+			wantID3V1: []string{
+				"Artist: \"The Beatles\"",
+				"Album: \"On Air: Live At The BBC, Volum\"",
+				"Title: \"Ringo - Pop Profile [Interview\"",
+				"Track: 29",
+				"Year: \"2013\"",
+				"Genre: \"Other\"",
+			},
+			wantFrames: []string{
+				"TALB = \"my album\"",
+				"TCON = \"Rock\"",
+				"TDRC = \"1999\"",
+				"TIT2 = \"v1\"",
+				"TPE1 = \"my artist\"",
+				"TRCK = \"5\"",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := tt.t.Path()
+			wantFingerprint, _ := files.AudioFingerprint(path)
+			if got := tt.t.CreateMissingTags(); len(got) != 0 {
+				t.Errorf("%s = %v, want no errors", fnName, got)
+			}
+			if got := tt.t.MissingTags(); len(got) != 0 {
+				t.Errorf("%s left tags missing: %v", fnName, got)
+			}
+			if got, err := files.ReadID3v1Metadata(path); err != nil ||
+				!reflect.DeepEqual(got, tt.wantID3V1) {
+				t.Errorf("%s ID3V1 = %v (%v), want %v", fnName, got, err, tt.wantID3V1)
+			}
+			if _, _, got, _, err := files.ReadID3V2Metadata(path); err != nil ||
+				!reflect.DeepEqual(got, tt.wantFrames) {
+				t.Errorf("%s ID3V2 = %v (%v), want %v", fnName, got, err, tt.wantFrames)
+			}
+			if got, _ := files.AudioFingerprint(path); got != wantFingerprint {
+				t.Errorf("%s changed the audio", fnName)
+			}
+		})
+	}
+}
+
+func TestTrack_RedundantTags(t *testing.T) {
+	const fnName = "Track.RedundantTags()"
+	testDir := "redundantTags"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	audio := createMpegFrame(128, nil)
+	frames := map[string]string{"TIT2": "my track", "TRCK": "1"}
+	fileContents := map[string][]byte{
+		"no ID3V2.mp3": append(append([]byte{}, audio...), id3v1DataSet1...),
+		"bad ID3V2.mp3": append(createID3v2TaggedData(audio,
+			map[string]string{"TRCK": "first"}), id3v1DataSet1...),
+		"redundant.mp3": append(createID3v2TaggedData(audio, frames), id3v1DataSet1...),
+		"clean.mp3":     createID3v2TaggedData(audio, frames),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		name string
+		want []string
+	}{
+		"no ID3V2 tag":        {name: "no ID3V2.mp3"},
+		"unreadable ID3V2":    {name: "bad ID3V2.mp3"},
+		"redundant ID3V1 tag": {name: "redundant.mp3", want: []string{"ID3V1"}},
+		"no other tags":       {name: "clean.mp3"},
+		"missing file":        {name: "missing.mp3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			track := files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, tt.name))
+			if got := track.RedundantTags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrack_NormalizeID3V2(t *testing.T) {
+	const fnName = "Track.NormalizeID3V2()"
+	testDir := "normalizeTrack"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	tagged := createID3v2TaggedData(createMpegFrame(128, nil),
+		map[string]string{"TIT2": "my track"})
+	fileContents := map[string][]byte{
+		"track.mp3":  tagged,
+		"track.flac": createFlacData([]string{"TITLE=my track"}, 0, tagged),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	target, _ := files.NewId3v2Target(4, "UTF-8")
+	tests := map[string]struct {
+		name        string
+		wantChanges int
+	}{
+		"mp3":          {name: "track.mp3", wantChanges: 2},
+		"not mp3":      {name: "track.flac"},
+		"missing file": {name: "missing.flac"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			track := files.NewEmptyTrack().WithFullPath(filepath.Join(testDir, tt.name))
+			if got, _ := track.ID3V2Normalization(target); len(got) != tt.wantChanges {
+				t.Errorf("Track.ID3V2Normalization() = %v, want %d changes", got,
+					tt.wantChanges)
+			}
+			if err := track.NormalizeID3V2(target); err != nil {
+				t.Errorf("%s error = %v", fnName, err)
+			}
+			if got, _ := track.ID3V2Normalization(target); len(got) != 0 {
+				t.Errorf("%s left changes %v", fnName, got)
+			}
+		})
+	}
+}
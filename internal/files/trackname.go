@@ -0,0 +1,100 @@
+package files
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the named groups that a track name pattern may use
+const (
+	TrackNameArtist = "artist"
+	TrackNameDisc   = "disc"
+	TrackNameSide   = "side"
+	TrackNameTitle  = "title"
+	TrackNameTrack  = "track"
+)
+
+var (
+	// the built-in track name patterns: a one-digit disc number, a hyphen, a
+	// two-digit track number, and a space, e.g., "2-01 Intro", or just a track
+	// number, e.g., "01 Intro"; the disc form is kept that narrow so that names
+	// such as "10-20 Years" and "01-99 Luftballons" keep their leading digits in
+	// their titles
+	defaultTrackNamePatterns = []string{
+		`^(?P<disc>\d)-(?P<track>\d{2})\s(?P<title>.+)$`,
+		`^(?P<track>\d+)[\s-](?P<title>.+)$`,
+	}
+)
+
+// DefaultTrackNamePatterns returns the built-in patterns used to parse track
+// file names
+func DefaultTrackNamePatterns() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(defaultTrackNamePatterns))
+	for _, pattern := range defaultTrackNamePatterns {
+		patterns = append(patterns, regexp.MustCompile(pattern))
+	}
+	return patterns
+}
+
+// ParseTrackNamePattern compiles a track name pattern, a regular expression
+// that matches a track file name without its extension. It must name the track
+// number and title with the {track} and {title} groups, e.g.,
+// "(?P<track>\d+)"; it may also name the disc number ({disc}), the vinyl side
+// as a letter ({side}), and the performing artist ({artist}), whom the artist
+// name metadata of a compilation's tracks is checked against.
+func ParseTrackNamePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, group := range re.SubexpNames() {
+		switch group {
+		case "":
+		case TrackNameArtist, TrackNameDisc, TrackNameSide, TrackNameTitle, TrackNameTrack:
+			found[group] = true
+		default:
+			return nil, fmt.Errorf("the track name pattern %q names the group %q, which is"+
+				" not one of %q, %q, %q, %q, or %q", pattern, group, TrackNameArtist,
+				TrackNameDisc, TrackNameSide, TrackNameTitle, TrackNameTrack)
+		}
+	}
+	for _, group := range []string{TrackNameTrack, TrackNameTitle} {
+		if !found[group] {
+			return nil, fmt.Errorf("the track name pattern %q does not name the group %q",
+				pattern, group)
+		}
+	}
+	return re, nil
+}
+
+// matchTrackName matches the track file name, stripped of its extension,
+// against the track name patterns, in order; a vinyl side is numbered as a disc, side A
+// being disc 1, side B disc 2, and so on
+func matchTrackName(name string, patterns []*regexp.Regexp) (title, performer string, disc,
+	track int, matched bool) {
+	for _, pattern := range patterns {
+		matches := pattern.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+		track, _ = strconv.Atoi(matches[pattern.SubexpIndex(TrackNameTrack)])
+		title = matches[pattern.SubexpIndex(TrackNameTitle)]
+		if index := pattern.SubexpIndex(TrackNameArtist); index != -1 {
+			performer = strings.TrimSpace(matches[index])
+		}
+		if index := pattern.SubexpIndex(TrackNameDisc); index != -1 {
+			disc, _ = strconv.Atoi(matches[index])
+		}
+		if index := pattern.SubexpIndex(TrackNameSide); index != -1 && disc == 0 {
+			if side := strings.ToUpper(matches[index]); len(side) == 1 &&
+				side[0] >= 'A' && side[0] <= 'Z' {
+				disc = int(side[0]-'A') + 1
+			}
+		}
+		return title, performer, disc, track, true
+	}
+	return "", "", 0, 0, false
+}
@@ -1,7 +1,55 @@
 package files
 
+import (
+	"bytes"
+	"os"
+)
+
 const backupDirName = "pre-repair-backup"
 
+// rewriteFile replaces the file's content, preserving its permissions; the new
+// content is written to a temporary file first, so that a failed write does not
+// corrupt the original file
+func rewriteFile(path string, content []byte) (err error) {
+	var stat os.FileInfo
+	if stat, err = os.Stat(path); err != nil {
+		return
+	}
+	tmpPath := path + "-rewrite"
+	if err = os.WriteFile(tmpPath, content, stat.Mode()); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+	}
+	return
+}
+
+// IdenticalFiles returns true if the two files have the same content
+func IdenticalFiles(path1, path2 string) (bool, error) {
+	stat1, err := os.Stat(path1)
+	if err != nil {
+		return false, err
+	}
+	stat2, err := os.Stat(path2)
+	if err != nil {
+		return false, err
+	}
+	if stat1.Size() != stat2.Size() {
+		return false, nil
+	}
+	content1, err := os.ReadFile(path1)
+	if err != nil {
+		return false, err
+	}
+	content2, err := os.ReadFile(path2)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(content1, content2), nil
+}
+
 // per https://docs.microsoft.com/en-us/windows/win32/fileio/naming-a-file
 func IsIllegalRuneForFileNames(r rune) bool {
 	if r >= 0 && r <= 31 {
@@ -2,7 +2,10 @@ package files_test
 
 import (
 	"mp3/internal/files"
+	"path/filepath"
 	"testing"
+
+	cmd_toolkit "github.com/majohn-r/cmd-toolkit"
 )
 
 func Test_isIllegalRuneForFileNames(t *testing.T) {
@@ -170,3 +173,50 @@ func Test_isIllegalRuneForFileNames(t *testing.T) {
 		})
 	}
 }
+
+func TestIdenticalFiles(t *testing.T) {
+	const fnName = "IdenticalFiles()"
+	testDir := "identicalFiles"
+	if err := cmd_toolkit.Mkdir(testDir); err != nil {
+		t.Errorf("%s error creating %q: %v", fnName, testDir, err)
+	}
+	defer func() {
+		destroyDirectory(fnName, testDir)
+	}()
+	fileContents := map[string][]byte{
+		"a":     []byte("some content"),
+		"b":     []byte("some content"),
+		"c":     []byte("more content"),
+		"short": []byte("content"),
+	}
+	for name, content := range fileContents {
+		if err := createFileWithContent(testDir, name, content); err != nil {
+			t.Errorf("%s error creating %q: %v", fnName, name, err)
+		}
+	}
+	tests := map[string]struct {
+		path1   string
+		path2   string
+		want    bool
+		wantErr bool
+	}{
+		"identical":         {path1: "a", path2: "b", want: true},
+		"same size":         {path1: "a", path2: "c"},
+		"different size":    {path1: "a", path2: "short"},
+		"first is missing":  {path1: "missing", path2: "a", wantErr: true},
+		"second is missing": {path1: "a", path2: "missing", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := files.IdenticalFiles(filepath.Join(testDir, tt.path1),
+				filepath.Join(testDir, tt.path2))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s error = %v, wantErr %v", fnName, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("%s = %v, want %v", fnName, got, tt.want)
+			}
+		})
+	}
+}
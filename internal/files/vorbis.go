@@ -0,0 +1,263 @@
+package files
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// values per https://www.xiph.org/vorbis/doc/v-comment.html
+const (
+	vorbisAlbumKey  = "ALBUM"
+	vorbisArtistKey = "ARTIST"
+	vorbisGenreKey  = "GENRE"
+	vorbisTitleKey  = "TITLE"
+	vorbisTrackKey  = "TRACKNUMBER"
+	vorbisYearKey   = "DATE"
+)
+
+// maps Vorbis comment field names to the descriptions used by Track.Details()
+var vorbisDescriptions = map[string]string{
+	"COMPOSER":  "Composer",
+	"CONDUCTOR": "Conductor",
+	"ENSEMBLE":  "Orchestra/Band",
+	"KEY":       "Key",
+	"LYRICIST":  "Lyricist",
+	"SUBTITLE":  "Subtitle",
+}
+
+// VorbisComments holds the contents of a Vorbis comment block, as used by FLAC
+// and Ogg files: a vendor string, and a list of comments of the form
+// "NAME=value"
+type VorbisComments struct {
+	vendor   string
+	comments []string
+}
+
+func (vc *VorbisComments) WithVendor(s string) *VorbisComments {
+	vc.vendor = s
+	return vc
+}
+
+func (vc *VorbisComments) WithComments(s []string) *VorbisComments {
+	vc.comments = s
+	return vc
+}
+
+func NewVorbisComments() *VorbisComments {
+	return &VorbisComments{}
+}
+
+// Comments returns the comments, in the order in which they appear in the block
+func (vc *VorbisComments) Comments() []string {
+	return vc.comments
+}
+
+// Value returns the value of the first comment with the specified field name;
+// field names are case-insensitive
+func (vc *VorbisComments) Value(name string) string {
+	for _, comment := range vc.comments {
+		if field, value, found := strings.Cut(comment, "="); found &&
+			strings.EqualFold(field, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// SetValue replaces all the comments with the specified field name with a
+// single comment holding the specified value
+func (vc *VorbisComments) SetValue(name, value string) {
+	comments := make([]string, 0, len(vc.comments)+1)
+	replaced := false
+	for _, comment := range vc.comments {
+		if field, _, found := strings.Cut(comment, "="); found &&
+			strings.EqualFold(field, name) {
+			if !replaced {
+				comments = append(comments, field+"="+value)
+				replaced = true
+			}
+		} else {
+			comments = append(comments, comment)
+		}
+	}
+	if !replaced {
+		comments = append(comments, name+"="+value)
+	}
+	vc.comments = comments
+}
+
+// Bytes returns the block's binary representation; unlike the block in an Ogg
+// Vorbis stream, there is no trailing framing bit
+func (vc *VorbisComments) Bytes() []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(vc.vendor)))
+	b = append(b, vc.vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(vc.comments)))
+	for _, comment := range vc.comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(comment)))
+		b = append(b, comment...)
+	}
+	return b
+}
+
+// Diagnostics returns the vendor string and the comments, formatted in the form
+// "NAME = \"value\"".
+func (vc *VorbisComments) Diagnostics() []string {
+	output := make([]string, 0, len(vc.comments)+1)
+	output = append(output, fmt.Sprintf("vendor = %q", vc.vendor))
+	for _, comment := range vc.comments {
+		field, value, _ := strings.Cut(comment, "=")
+		output = append(output, fmt.Sprintf("%s = %q", field, value))
+	}
+	return output
+}
+
+// details returns the values of the fields described in vorbisDescriptions
+func (vc *VorbisComments) details() map[string]string {
+	m := map[string]string{}
+	for name, description := range vorbisDescriptions {
+		if value := vc.Value(name); value != "" {
+			m[description] = value
+		}
+	}
+	return m
+}
+
+func parseVorbisComments(data []byte) (*VorbisComments, error) {
+	vc := &VorbisComments{}
+	offset := 0
+	next := func() (string, bool) {
+		if len(data)-offset < 4 {
+			return "", false
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		if size > len(data)-offset {
+			return "", false
+		}
+		s := string(data[offset : offset+size])
+		offset += size
+		return s, true
+	}
+	var ok bool
+	if vc.vendor, ok = next(); !ok {
+		return nil, fmt.Errorf("vorbis comment vendor string is corrupt")
+	}
+	if len(data)-offset < 4 {
+		return nil, fmt.Errorf("vorbis comment count is missing")
+	}
+	count := int(binary.LittleEndian.Uint32(data[offset:]))
+	offset += 4
+	// each comment requires at least 4 bytes, so don't trust a huge count
+	vc.comments = make([]string, 0, min(count, (len(data)-offset)/4))
+	for k := 0; k < count; k++ {
+		comment, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("vorbis comment %d of %d is corrupt", k+1, count)
+		}
+		vc.comments = append(vc.comments, comment)
+	}
+	return vc, nil
+}
+
+// VorbisMetadata holds the metadata values read from a Vorbis comment block
+type VorbisMetadata struct {
+	albumName   string
+	artistName  string
+	err         error
+	genre       string
+	trackName   string
+	trackNumber int
+	year        string
+}
+
+func (vm *VorbisMetadata) HasError() bool {
+	return vm.err != nil
+}
+
+func (vm *VorbisMetadata) WithAlbumName(s string) *VorbisMetadata {
+	vm.albumName = s
+	return vm
+}
+
+func (vm *VorbisMetadata) WithArtistName(s string) *VorbisMetadata {
+	vm.artistName = s
+	return vm
+}
+
+func (vm *VorbisMetadata) WithErr(e error) *VorbisMetadata {
+	vm.err = e
+	return vm
+}
+
+func (vm *VorbisMetadata) WithGenre(s string) *VorbisMetadata {
+	vm.genre = s
+	return vm
+}
+
+func (vm *VorbisMetadata) WithTrackName(s string) *VorbisMetadata {
+	vm.trackName = s
+	return vm
+}
+
+func (vm *VorbisMetadata) WithTrackNumber(i int) *VorbisMetadata {
+	vm.trackNumber = i
+	return vm
+}
+
+func (vm *VorbisMetadata) WithYear(s string) *VorbisMetadata {
+	vm.year = s
+	return vm
+}
+
+func NewVorbisMetadata() *VorbisMetadata {
+	return &VorbisMetadata{}
+}
+
+func newVorbisMetadata(vc *VorbisComments) (vm *VorbisMetadata) {
+	vm = &VorbisMetadata{}
+	if trackNumber, err := ToTrackNumber(vc.Value(vorbisTrackKey)); err != nil {
+		vm.err = err
+	} else {
+		vm.albumName = vc.Value(vorbisAlbumKey)
+		vm.artistName = vc.Value(vorbisArtistKey)
+		vm.genre = vc.Value(vorbisGenreKey)
+		vm.trackName = vc.Value(vorbisTitleKey)
+		vm.trackNumber = trackNumber
+		vm.year = vc.Value(vorbisYearKey)
+	}
+	return
+}
+
+// applyCorrections sets the comments for the corrected values recorded in tM
+func (vc *VorbisComments) applyCorrections(tM *TrackMetadata, sT SourceType) {
+	if album := tM.correctedAlbumName[sT]; album != "" {
+		vc.SetValue(vorbisAlbumKey, album)
+	}
+	if artist := tM.correctedArtistName[sT]; artist != "" {
+		vc.SetValue(vorbisArtistKey, artist)
+	}
+	if title := tM.correctedTrackName[sT]; title != "" {
+		vc.SetValue(vorbisTitleKey, title)
+	}
+	if track := tM.correctedTrackNumber[sT]; track != 0 {
+		vc.SetValue(vorbisTrackKey, fmt.Sprintf("%d", track))
+	}
+	if genre := tM.correctedGenre[sT]; genre != "" {
+		vc.SetValue(vorbisGenreKey, genre)
+	}
+	if year := tM.correctedYear[sT]; year != "" {
+		vc.SetValue(vorbisYearKey, year)
+	}
+}
+
+// Vorbis comments are free-form UTF-8, so names in them are held to the ID3V2
+// standard
+func VorbisNameDiffers(cS *ComparableStrings) bool {
+	return Id3v2NameDiffers(cS)
+}
+
+func VorbisGenreDiffers(cS *ComparableStrings) bool {
+	return Id3v2GenreDiffers(cS)
+}
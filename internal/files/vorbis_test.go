@@ -0,0 +1,49 @@
+package files_test
+
+import (
+	"mp3/internal/files"
+	"reflect"
+	"testing"
+)
+
+func TestVorbisComments_SetValue(t *testing.T) {
+	const fnName = "VorbisComments.SetValue()"
+	tests := map[string]struct {
+		vc           *files.VorbisComments
+		name         string
+		value        string
+		wantComments []string
+	}{
+		"new field": {
+			vc:           files.NewVorbisComments().WithComments([]string{"TITLE=my track"}),
+			name:         "ALBUM",
+			value:        "new album",
+			wantComments: []string{"TITLE=my track", "ALBUM=new album"},
+		},
+		"existing field, different case": {
+			vc:           files.NewVorbisComments().WithComments([]string{"album=old album"}),
+			name:         "ALBUM",
+			value:        "new album",
+			wantComments: []string{"album=new album"},
+		},
+		"repeated field": {
+			vc: files.NewVorbisComments().WithComments([]string{
+				"ARTIST=first artist", "TITLE=my track", "ARTIST=second artist",
+			}),
+			name:         "ARTIST",
+			value:        "new artist",
+			wantComments: []string{"ARTIST=new artist", "TITLE=my track"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.vc.SetValue(tt.name, tt.value)
+			if got := tt.vc.Value(tt.name); got != tt.value {
+				t.Errorf("%s got %q, want %q", fnName, got, tt.value)
+			}
+			if got := tt.vc.Comments(); !reflect.DeepEqual(got, tt.wantComments) {
+				t.Errorf("%s got %v, want %v", fnName, got, tt.wantComments)
+			}
+		})
+	}
+}